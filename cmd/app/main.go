@@ -21,6 +21,7 @@ import (
 	"github.com/revrost/counterspell/internal/config"
 	"github.com/revrost/counterspell/internal/db"
 	"github.com/revrost/counterspell/internal/handlers"
+	"github.com/revrost/counterspell/internal/logging"
 	"github.com/revrost/counterspell/internal/services"
 	"github.com/revrost/counterspell/internal/tunnel"
 	"github.com/revrost/counterspell/ui"
@@ -32,10 +33,22 @@ type contextKey string
 const subdomainKey contextKey = "subdomain"
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate-data" {
+		os.Exit(runMigrateData(os.Args[2:]))
+	}
+
 	// Parse flags
 	addr := flag.String("addr", ":8710", "Server address")
+	tlsCert := flag.String("tls-cert", os.Getenv("TLS_CERT_FILE"), "TLS certificate file (enables HTTPS together with -tls-key)")
+	tlsKey := flag.String("tls-key", os.Getenv("TLS_KEY_FILE"), "TLS private key file")
 	flag.Parse()
 
+	if (*tlsCert == "") != (*tlsKey == "") {
+		slog.Error("Both -tls-cert and -tls-key must be set to enable TLS")
+		os.Exit(1)
+	}
+	useTLS := *tlsCert != ""
+
 	// Setup log output: always write to both stdout and server.log
 	logFile, err := os.OpenFile("server.log", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
 	if err != nil {
@@ -45,15 +58,17 @@ func main() {
 	defer func() { _ = logFile.Close() }()
 	logOutput := io.MultiWriter(os.Stdout, logFile)
 
+	// Load configuration
+	cfg := config.Load()
+
 	// Setup logger
-	logger := slog.New(slog.NewTextHandler(logOutput, &slog.HandlerOptions{
-		Level:     slog.LevelInfo,
-		AddSource: true,
-	}))
+	logger, err := logging.New(logOutput, cfg.LogLevel, cfg.LogModuleLevels)
+	if err != nil {
+		slog.Error("Invalid log configuration", "error", err)
+		os.Exit(1)
+	}
 	slog.SetDefault(logger)
 
-	// Load configuration
-	cfg := config.Load()
 	if err := cfg.Validate(); err != nil {
 		logger.Error("Invalid configuration", "error", err)
 		os.Exit(1)
@@ -64,6 +79,7 @@ func main() {
 		"data_dir", cfg.DataDir,
 		"worker_pool_size", cfg.WorkerPoolSize,
 		"max_tasks_per_user", cfg.MaxTasksPerUser,
+		"result_processors", cfg.ResultProcessors,
 	)
 
 	// Ensure directory structure
@@ -71,6 +87,12 @@ func main() {
 		logger.Error("Failed to create directories", "error", err)
 		os.Exit(1)
 	}
+	dataDirStatus := config.CheckDataDir(cfg)
+	if !dataDirStatus.OK() {
+		logger.Error("Data directory unusable", "error", dataDirStatus.Error)
+		os.Exit(1)
+	}
+	logger.Info("Data directory ready", "path", dataDirStatus.Path, "free_mb", dataDirStatus.FreeBytes>>20)
 
 	// Connect to SQLite
 	ctx := context.Background()
@@ -87,6 +109,32 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Self-check external binaries: git is required, the agent CLI only warns
+	deps := services.CheckDependencies(ctx, services.NewSettingsService(database).AgentBackend(ctx))
+	for _, dep := range deps.Dependencies {
+		if dep.Found {
+			logger.Info("Dependency found", "name", dep.Name, "path", dep.Path, "version", dep.Version)
+		}
+	}
+	for _, dep := range deps.Missing() {
+		if dep.Required {
+			logger.Error("Required dependency missing", "name", dep.Name, "error", dep.Error)
+		} else {
+			logger.Warn("Configured agent CLI missing, tasks using it will fail", "name", dep.Name, "error", dep.Error)
+		}
+	}
+	if !deps.OK {
+		logger.Error("Refusing to start without required dependencies")
+		os.Exit(1)
+	}
+
+	// Tasks still in_progress were orphaned by the previous process
+	if n, err := services.NewRepository(database).ResetInProgress(ctx); err != nil {
+		logger.Warn("Failed to reset interrupted tasks", "error", err)
+	} else if n > 0 {
+		logger.Info("Marked interrupted tasks as failed", "count", n)
+	}
+
 	// Ensure auth + machine identity before starting server (Handshake starts here)
 	authService := services.NewOAuthService(database, cfg)
 	authResult, err := authService.EnsureAuthenticated(ctx)
@@ -111,6 +159,12 @@ func main() {
 		logger.Error("Failed to create handlers", "error", err)
 		os.Exit(1)
 	}
+	if err := h.StartIssuePolling(ctx); err != nil {
+		logger.Warn("Failed to start issue polling", "error", err)
+	}
+	if err := h.StartSlack(ctx); err != nil {
+		logger.Warn("Failed to start Slack integration", "error", err)
+	}
 
 	// Setup router
 	slog.Info("Setting up router")
@@ -146,7 +200,21 @@ func main() {
 	r.Group(func(r chi.Router) {
 		// Health check
 		r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
-			render.JSON(w, r, map[string]string{"status": "ok"})
+			disk := config.CheckDataDir(cfg)
+			status := "ok"
+			if !disk.OK() {
+				status = "degraded"
+				render.Status(r, http.StatusServiceUnavailable)
+			}
+			render.JSON(w, r, map[string]any{
+				"status": status,
+				"disk": map[string]any{
+					"free_bytes":     disk.FreeBytes,
+					"total_bytes":    disk.TotalBytes,
+					"min_free_bytes": disk.MinFree,
+					"error":          disk.Error,
+				},
+			})
 		})
 
 		// Debug endpoint to show subdomain
@@ -161,24 +229,79 @@ func main() {
 		// Auth + session endpoints (needed before auth)
 		r.Get("/api/v1/session", h.HandleGetSession)
 		r.Get("/api/v1/auth/login", h.HandleAuthLogin)
+
+		// Read-only task view behind a share token
+		r.Get("/api/v1/shared/{token}", h.HandleGetSharedTask)
+
+		// GitHub webhook deliveries, authenticated by their signature
+		r.Post("/api/v1/github/webhook", h.HandleGitHubWebhook)
+
+		// Slack slash commands, authenticated by their signature
+		r.Post("/integrations/slack/command", h.HandleSlackCommand)
 	})
 
 	// Protected routes (require machine auth)
 	r.Group(func(r chi.Router) {
 		r.Use(h.RequireMachineAuth)
+		// Dependency self-check
+		r.Get("/debug/deps", h.HandleDebugDeps)
+		// Buffered SSE events for a task
+		r.Get("/debug/tasks/{id}/events", h.HandleDebugTaskEvents)
+		// Not for API tokens: a leaked token must not be able to mint
+		// replacements, link accounts or kill runs
+		r.Group(func(r chi.Router) {
+			r.Use(h.RequireOperator)
+			// Runs executing on this server, and killing a runaway one
+			r.Get("/admin/running", h.HandleAdminRunning)
+			r.Post("/admin/kill/{id}", h.HandleAdminKill)
+			// Personal API tokens for scripts and CI
+			r.Post("/api/v1/tokens", h.HandleCreateAPIToken)
+			r.Delete("/api/v1/tokens/{id}", h.HandleRevokeAPIToken)
+			r.Post("/api/v1/integrations/slack/link-code", h.HandleCreateSlackLinkCode)
+		})
+		r.Get("/api/v1/stats", h.HandleGetStats)
+
+		r.Get("/api/v1/tokens", h.HandleListAPITokens)
+		r.Get("/api/v1/stats/cost", h.HandleGetCostStats)
+
 		// GitHub OAuth routes
 		r.Get("/api/v1/github/authorize", h.HandleGitHubLogin)
 		r.Get("/api/v1/github/callback", h.HandleGitHubCallback)
 		r.Get("/api/v1/github/repos", h.HandleGitHubRepos)
+		r.Post("/api/v1/projects", h.HandleCreateProject)
+		r.Delete("/api/v1/projects/{id}", h.HandleDeleteProject)
+		r.Get("/api/v1/github/issue-watch", h.HandleListIssueWatches)
+		r.Put("/api/v1/github/repos/{id}/issue-watch", h.HandleSetIssueWatch)
+		r.Get("/api/v1/github/commit-statuses", h.HandleListCommitStatuses)
+		r.Put("/api/v1/github/repos/{id}/commit-statuses", h.HandleSetCommitStatuses)
+		r.Get("/api/v1/github/commit-signing", h.HandleListCommitSigning)
+		r.Put("/api/v1/github/repos/{id}/commit-signing", h.HandleSetCommitSigning)
+		r.Get("/api/v1/github/auto-merge", h.HandleListAutoMerge)
+		r.Put("/api/v1/github/repos/{id}/auto-merge", h.HandleSetAutoMerge)
 
 		// Unified SSE endpoint
 		r.Get("/api/v1/events", h.HandleSSE)
 
 		// Home page actions, tasks are like inbox
 		r.Get("/api/v1/tasks", h.HandleListTask)
+		r.Get("/api/v1/tasks/list", h.HandleListTasksFlat)
+		r.Get("/api/v1/tasks/done", h.HandleListDoneTasks)
+		r.Get("/api/v1/tasks/active", h.HandleListActiveTasks)
+		r.Get("/api/v1/feed/status", h.HandleFeedStatus)
 		r.Post("/api/v1/tasks", h.HandleAddTask)
 		r.Get("/api/v1/tasks/{id}", h.HandleGetTask)
+		r.Delete("/api/v1/tasks/{id}", h.HandleDeleteTask)
 		r.Get("/api/v1/tasks/{id}/diff", h.HandleGetTaskDiff)
+		r.Get("/api/v1/tasks/{id}/diff/full", h.HandleGetTaskDiffFull)
+		r.Get("/api/v1/tasks/{id}/diff/stat", h.HandleGetTaskDiffStat)
+		r.Get("/api/v1/tasks/{id}/todos", h.HandleGetTaskTodos)
+		r.Get("/api/v1/tasks/{id}/logs", h.HandleGetTaskLogs)
+		r.Get("/api/v1/tasks/{id}/raw-log", h.HandleGetTaskRawLog)
+		r.Get("/api/v1/tasks/{id}/runs/{runID}/prompt", h.HandleGetRunPrompt)
+		r.Get("/api/v1/tasks/{id}/messages/{messageID}/full", h.HandleGetMessageFull)
+		r.Get("/api/v1/tasks/{id}/artifacts", h.HandleListTaskArtifacts)
+		r.Get("/api/v1/tasks/{id}/artifacts/{artifactID}/download", h.HandleDownloadArtifact)
+		r.Get("/api/v1/tasks/{id}/shares", h.HandleListTaskShares)
 		r.Get("/api/v1/sessions", h.HandleListSessions)
 		r.Post("/api/v1/sessions", h.HandleCreateSession)
 		r.Get("/api/v1/sessions/{id}", h.HandleGetSessionDetail)
@@ -193,11 +316,18 @@ func main() {
 
 		// Task Actions
 		r.Post("/api/v1/tasks/{id}/chat", h.HandleActionChat)
+		r.Post("/api/v1/tasks/{id}/interrupt", h.HandleActionInterrupt)
 		r.Post("/api/v1/tasks/{id}/clear", h.HandleActionClear)
 		r.Post("/api/v1/tasks/{id}/retry", h.HandleActionRetry)
+		r.Post("/api/v1/tasks/{id}/rerun", h.HandleActionRerun)
 		r.Post("/api/v1/tasks/{id}/merge", h.HandleActionMerge)
 		r.Post("/api/v1/tasks/{id}/pr", h.HandleActionPR)
 		r.Post("/api/v1/tasks/{id}/discard", h.HandleActionDiscard)
+		r.Post("/api/v1/tasks/{id}/move-repo", h.HandleActionMoveRepo)
+		r.Post("/api/v1/tasks/{id}/share", h.HandleCreateTaskShare)
+		r.Delete("/api/v1/tasks/{id}/shares/{shareID}", h.HandleRevokeTaskShare)
+		r.Post("/api/v1/action/command", h.HandleActionCommand)
+		r.Post("/api/v1/action/cleanup-worktrees", h.HandleActionCleanupWorkspaces)
 
 	})
 
@@ -219,8 +349,14 @@ func main() {
 
 	// Start server in goroutine
 	go func() {
-		logger.Info("Server starting", "addr", *addr)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		logger.Info("Server starting", "addr", *addr, "tls", useTLS)
+		var err error
+		if useTLS {
+			err = server.ListenAndServeTLS(*tlsCert, *tlsKey)
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			logger.Error("Server failed", "error", err)
 			os.Exit(1)
 		}
@@ -228,6 +364,9 @@ func main() {
 
 	// Start Cloudflare tunnel (best effort)
 	localURL := localURLFromAddr(*addr)
+	if useTLS {
+		localURL = "https://" + strings.TrimPrefix(localURL, "http://")
+	}
 	var tunnelProc *tunnel.CloudflareTunnel
 	if authResult.TunnelToken != "" {
 		proc, err := tunnel.StartCloudflare(ctx, authResult.TunnelToken, localURL, "", logger)
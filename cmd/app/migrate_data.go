@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/revrost/counterspell/internal/config"
+	"github.com/revrost/counterspell/internal/db"
+	"github.com/revrost/counterspell/internal/services"
+)
+
+// runMigrateData implements `counterspell migrate-data --to <dir>`, moving
+// DATA_DIR to a new location with the server stopped: repos, worktrees,
+// outputs, and the database when it lives in the data dir. Worktree links
+// and stored artifact paths are fixed up to match. DATA_DIR (and
+// DATABASE_PATH) still have to be updated by hand afterwards, as they come
+// from the environment.
+func runMigrateData(args []string) int {
+	fs := flag.NewFlagSet("migrate-data", flag.ContinueOnError)
+	to := fs.String("to", "", "New data directory (must not exist or be empty)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *to == "" {
+		fmt.Fprintln(os.Stderr, "usage: counterspell migrate-data --to <dir>")
+		return 2
+	}
+
+	cfg := config.Load()
+	ctx := context.Background()
+	from, err := filepath.Abs(cfg.DataDir)
+	if err != nil {
+		slog.Error("Invalid data dir", "error", err)
+		return 1
+	}
+	newDir, err := filepath.Abs(*to)
+	if err != nil {
+		slog.Error("Invalid target dir", "error", err)
+		return 1
+	}
+
+	// The database moves along when it is inside the data dir
+	dbPath, err := filepath.Abs(cfg.DatabasePath)
+	if err != nil {
+		slog.Error("Invalid database path", "error", err)
+		return 1
+	}
+	dbMoved := false
+	if rel, err := filepath.Rel(from, dbPath); err == nil && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && rel != ".." {
+		dbPath, dbMoved = filepath.Join(newDir, rel), true
+	}
+
+	slog.Info("Moving data dir", "from", from, "to", newDir)
+	copied, err := services.MoveDataDir(from, newDir)
+	if err != nil {
+		slog.Error("Failed to move data dir", "error", err)
+		return 1
+	}
+
+	failed := false
+	repaired, err := services.RepairWorktrees(ctx, newDir)
+	if err != nil {
+		slog.Error("Some worktrees could not be re-linked", "error", err)
+		failed = true
+	}
+	slog.Info("Re-linked task worktrees", "count", repaired)
+
+	relocated, err := relocateArtifacts(ctx, dbPath, cfg.DataDir, from, newDir)
+	if err != nil {
+		slog.Error("Failed to update stored paths", "database", dbPath, "error", err)
+		failed = true
+	}
+	slog.Info("Updated stored artifact paths", "count", relocated)
+
+	if copied {
+		if failed {
+			slog.Warn("Keeping the old data dir, the new copy needs attention", "path", from)
+		} else if err := os.RemoveAll(from); err != nil {
+			slog.Warn("Failed to remove the old data dir", "path", from, "error", err)
+		}
+	}
+
+	fmt.Printf("\nData moved to %s. Before starting the server, set:\n  DATA_DIR=%s\n", newDir, newDir)
+	if dbMoved {
+		fmt.Printf("  DATABASE_PATH=%s\n", dbPath)
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// relocateArtifacts points artifact paths stored under the old data dir,
+// given as configured and made absolute, to the new one.
+func relocateArtifacts(ctx context.Context, dbPath, configured, from, to string) (int64, error) {
+	database, err := db.Connect(ctx, dbPath)
+	if err != nil {
+		return 0, err
+	}
+	defer database.Close()
+	if err := database.RunMigrations(ctx); err != nil {
+		return 0, err
+	}
+
+	repo := services.NewRepository(database)
+	var total int64
+	for _, old := range []string{filepath.Clean(configured), from} {
+		n, err := repo.RelocateArtifacts(ctx, old, to)
+		if err != nil {
+			return total, err
+		}
+		total += n
+	}
+	return total, nil
+}
@@ -23,6 +23,8 @@ package agent
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/revrost/counterspell/internal/agent/tools"
 )
@@ -79,6 +81,18 @@ type Backend interface {
 	Info() BackendInfo
 }
 
+// ErrNotRunning is returned by Steer when the backend has no run in
+// progress.
+var ErrNotRunning = errors.New("agent: no run in progress")
+
+// Steerable is implemented by backends that accept messages while a run is
+// in progress. The message reaches the model before its next call, without
+// restarting the run. Backends without it can only take a follow-up once
+// the run has stopped.
+type Steerable interface {
+	Steer(message string) error
+}
+
 // --- Backend info for introspection ---
 
 // BackendType identifies the agent implementation.
@@ -88,8 +102,13 @@ const (
 	BackendNative     BackendType = "native"      // Go-based agent loop
 	BackendClaudeCode BackendType = "claude-code" // Claude Code CLI
 	BackendCodex      BackendType = "codex"       // OpenAI Codex CLI
+	BackendMock       BackendType = "mock"        // Scripted events, for tests
 )
 
+// cliWaitDelay bounds how long Wait on a cancelled CLI backend process blocks
+// on output pipes still held by commands it spawned.
+const cliWaitDelay = 5 * time.Second
+
 // BackendInfo describes a backend implementation.
 type BackendInfo struct {
 	Type    BackendType
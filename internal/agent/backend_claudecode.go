@@ -9,8 +9,11 @@ import (
 	"log/slog"
 	"os"
 	"os/exec"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/lithammer/shortuuid/v4"
 	"github.com/revrost/counterspell/internal/agent/tools"
@@ -41,6 +44,11 @@ type ClaudeCodeBackend struct {
 	model        string
 	sessionID    string // Claude Code session ID
 	systemPrompt string
+	container    *ContainerConfig
+	env          []string // extra NAME=value pairs for the CLI process
+	rawLog       *RawLog
+	// commandTimeout caps the CLI's Bash tool commands; 0 keeps its default
+	commandTimeout time.Duration
 
 	streamCtx     context.Context
 	events        chan<- StreamEvent
@@ -52,7 +60,8 @@ type ClaudeCodeBackend struct {
 	cmd          *exec.Cmd
 	cancel       context.CancelFunc
 	finalMessage string
-	messages     []Message // Track conversation for UI updates
+	messages     []Message         // Track conversation for UI updates
+	pendingEdits map[string]string // file editing tool call ID -> file, until its result
 }
 
 // ClaudeCodeOption configures a ClaudeCodeBackend.
@@ -107,6 +116,37 @@ func WithClaudeSystemPrompt(prompt string) ClaudeCodeOption {
 	}
 }
 
+// WithClaudeContainer runs the claude CLI inside a container that only
+// bind-mounts the working directory.
+func WithClaudeContainer(cfg ContainerConfig) ClaudeCodeOption {
+	return func(b *ClaudeCodeBackend) {
+		b.container = &cfg
+	}
+}
+
+// WithClaudeEnv adds NAME=value pairs to the CLI's environment. Provider
+// credentials set by the backend take precedence.
+func WithClaudeEnv(env []string) ClaudeCodeOption {
+	return func(b *ClaudeCodeBackend) {
+		b.env = env
+	}
+}
+
+// WithClaudeRawLog records the CLI's unparsed stdout and stderr to log.
+func WithClaudeRawLog(log *RawLog) ClaudeCodeOption {
+	return func(b *ClaudeCodeBackend) {
+		b.rawLog = log
+	}
+}
+
+// WithClaudeCommandTimeout caps how long the CLI lets a Bash tool command
+// run, via its BASH_DEFAULT_TIMEOUT_MS and BASH_MAX_TIMEOUT_MS settings.
+func WithClaudeCommandTimeout(timeout time.Duration) ClaudeCodeOption {
+	return func(b *ClaudeCodeBackend) {
+		b.commandTimeout = timeout
+	}
+}
+
 // NewClaudeCodeBackend creates a Claude Code CLI backend.
 //
 // Example:
@@ -124,8 +164,12 @@ func NewClaudeCodeBackend(opts ...ClaudeCodeOption) (*ClaudeCodeBackend, error)
 		opt(b)
 	}
 
-	// Verify binary exists
-	if _, err := exec.LookPath(b.binaryPath); err != nil {
+	// Verify binary exists (inside a container the image provides it)
+	if b.container != nil {
+		if err := b.container.lookPath(); err != nil {
+			return nil, err
+		}
+	} else if _, err := exec.LookPath(b.binaryPath); err != nil {
 		return nil, fmt.Errorf("%w: %s", ErrNoBinaryPath, b.binaryPath)
 	}
 
@@ -250,6 +294,7 @@ func (b *ClaudeCodeBackend) execute(ctx context.Context, prompt string) error {
 		scanner := bufio.NewScanner(stderr)
 		for scanner.Scan() {
 			line := scanner.Text()
+			b.rawLog.Line("stderr", line)
 			if line != "" {
 				slog.Warn("[CLAUDE-CODE] stderr", "line", line)
 				stderrMu.Lock()
@@ -277,6 +322,7 @@ func (b *ClaudeCodeBackend) execute(ctx context.Context, prompt string) error {
 func (b *ClaudeCodeBackend) parseOutput(scanner *bufio.Scanner) {
 	for scanner.Scan() {
 		line := scanner.Text()
+		b.rawLog.Line("stdout", line)
 		if line == "" {
 			continue
 		}
@@ -323,6 +369,11 @@ func (b *ClaudeCodeBackend) processClaudeEvent(event map[string]any) {
 						if text, ok := blockMap["text"].(string); ok {
 							blocks = append(blocks, ContentBlock{Type: "text", Text: text})
 						}
+						if blockMap["type"] == "tool_result" {
+							toolUseID, _ := blockMap["tool_use_id"].(string)
+							isError, _ := blockMap["is_error"].(bool)
+							b.finishEdit(toolUseID, isError)
+						}
 					}
 				}
 				if len(blocks) > 0 {
@@ -371,6 +422,7 @@ func (b *ClaudeCodeBackend) processClaudeEvent(event map[string]any) {
 							name, _ := blockMap["name"].(string)
 							id, _ := blockMap["id"].(string)
 							input, _ := blockMap["input"].(map[string]any)
+							b.trackEdit(name, id, input)
 							blocks = append(blocks, ContentBlock{
 								Type:  "tool_use",
 								Name:  name,
@@ -415,6 +467,7 @@ func (b *ClaudeCodeBackend) processClaudeEvent(event map[string]any) {
 		name, _ := event["name"].(string)
 		id, _ := event["id"].(string)
 		input, _ := event["input"].(map[string]any)
+		b.trackEdit(name, id, input)
 
 		// Add tool use to messages
 		b.mu.Lock()
@@ -467,6 +520,8 @@ func (b *ClaudeCodeBackend) processClaudeEvent(event map[string]any) {
 		b.emit(StreamEvent{Type: EventContentStart, MessageID: msgID, BlockType: "tool_result", Block: &ContentBlock{Type: "tool_result", ToolUseID: toolUseID, Content: content}})
 		b.emit(StreamEvent{Type: EventContentEnd, MessageID: msgID, BlockType: "tool_result", Block: &ContentBlock{Type: "tool_result", ToolUseID: toolUseID, Content: content}})
 		b.emit(StreamEvent{Type: EventMessageEnd, MessageID: msgID, Role: "user"})
+		isError, _ := event["is_error"].(bool)
+		b.finishEdit(toolUseID, isError)
 
 	case "result":
 		// Check if this is an error result
@@ -494,6 +549,45 @@ func (b *ClaudeCodeBackend) processClaudeEvent(event map[string]any) {
 	}
 }
 
+// claudeEditTools maps the CLI's file editing tools to the input field
+// naming the file.
+var claudeEditTools = map[string]string{
+	"Edit":         "file_path",
+	"MultiEdit":    "file_path",
+	"Write":        "file_path",
+	"NotebookEdit": "notebook_path",
+}
+
+// trackEdit remembers the file a file editing tool call is about to change.
+func (b *ClaudeCodeBackend) trackEdit(name, id string, input map[string]any) {
+	field, ok := claudeEditTools[name]
+	if !ok || id == "" {
+		return
+	}
+	path, _ := input[field].(string)
+	if path == "" {
+		return
+	}
+	b.mu.Lock()
+	if b.pendingEdits == nil {
+		b.pendingEdits = make(map[string]string)
+	}
+	b.pendingEdits[id] = path
+	b.mu.Unlock()
+}
+
+// finishEdit reports the file of a file editing tool call once its result
+// arrives, unless the call failed.
+func (b *ClaudeCodeBackend) finishEdit(toolUseID string, isError bool) {
+	b.mu.Lock()
+	path, ok := b.pendingEdits[toolUseID]
+	delete(b.pendingEdits, toolUseID)
+	b.mu.Unlock()
+	if ok && !isError {
+		b.emit(StreamEvent{Type: EventFileEdit, Path: path})
+	}
+}
+
 func (b *ClaudeCodeBackend) emit(event StreamEvent) {
 	b.mu.Lock()
 	ctx := b.streamCtx
@@ -663,12 +757,9 @@ func (b *ClaudeCodeBackend) buildCmd(ctx context.Context, prompt string) (*exec.
 	// TODO: Wrap with bubblewrap for sandboxing
 	// args = b.wrapWithBubblewrap(args)
 
-	cmd := exec.CommandContext(ctx, b.binaryPath, args...)
-	cmd.Dir = b.workDir
-
 	// Set environment variables for API authentication
 	// For Z.AI/OpenRouter, use ANTHROPIC_AUTH_TOKEN and ANTHROPIC_BASE_URL
-	env := os.Environ()
+	env := slices.Clone(b.env)
 	if b.baseURL != "" {
 		env = append(env, "ANTHROPIC_BASE_URL="+b.baseURL)
 		// Custom providers use ANTHROPIC_AUTH_TOKEN
@@ -682,6 +773,11 @@ func (b *ClaudeCodeBackend) buildCmd(ctx context.Context, prompt string) (*exec.
 		env = append(env, "ANTHROPIC_API_KEY="+b.apiKey)
 	}
 
+	if b.commandTimeout > 0 {
+		ms := strconv.FormatInt(b.commandTimeout.Milliseconds(), 10)
+		env = append(env, "BASH_DEFAULT_TIMEOUT_MS="+ms, "BASH_MAX_TIMEOUT_MS="+ms)
+	}
+
 	// Set model environment variables for GLM-4.7 compatibility
 	if b.model == "glm-4.7" {
 		env = append(env, "ANTHROPIC_DEFAULT_HAIKU_MODEL=glm-4.7")
@@ -689,6 +785,14 @@ func (b *ClaudeCodeBackend) buildCmd(ctx context.Context, prompt string) (*exec.
 		env = append(env, "ANTHROPIC_DEFAULT_OPUS_MODEL=glm-4.7")
 	}
 
-	cmd.Env = env
+	binary := b.binaryPath
+	if b.container != nil {
+		binary, args = b.container.wrap(b.workDir, binary, args, env)
+	}
+
+	cmd := exec.CommandContext(ctx, binary, args...)
+	cmd.Dir = b.workDir
+	cmd.Env = append(os.Environ(), env...)
+	cmd.WaitDelay = cliWaitDelay
 	return cmd, nil
 }
@@ -4,8 +4,10 @@ import (
 	"bufio"
 	"context"
 	"os"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestClaudeCodeBackend_EnvVariables(t *testing.T) {
@@ -81,6 +83,57 @@ func TestClaudeCodeBackend_EnvVariables(t *testing.T) {
 	}
 }
 
+func TestClaudeCodeBackend_ExtraEnv(t *testing.T) {
+	b, err := NewClaudeCodeBackend(
+		WithBinaryPath("/bin/true"),
+		WithAPIKey("sk-provider"),
+		WithClaudeEnv([]string{"CI=true", "ANTHROPIC_API_KEY=from-project"}),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+
+	cmd, err := b.buildCmd(context.Background(), "test prompt")
+	if err != nil {
+		t.Fatalf("Failed to build command: %v", err)
+	}
+
+	// exec keeps the last value of a duplicated key, so provider credentials win
+	env := cmd.Env
+	if !slices.Contains(env, "CI=true") {
+		t.Errorf("expected CI=true in env")
+	}
+	last := ""
+	for _, kv := range env {
+		if strings.HasPrefix(kv, "ANTHROPIC_API_KEY=") {
+			last = kv
+		}
+	}
+	if last != "ANTHROPIC_API_KEY=sk-provider" {
+		t.Errorf("provider key should override project env, got %q", last)
+	}
+}
+
+func TestClaudeCodeBackend_CommandTimeout(t *testing.T) {
+	b, err := NewClaudeCodeBackend(
+		WithBinaryPath("/bin/true"),
+		WithClaudeCommandTimeout(90*time.Second),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+
+	cmd, err := b.buildCmd(context.Background(), "test prompt")
+	if err != nil {
+		t.Fatalf("Failed to build command: %v", err)
+	}
+	for _, want := range []string{"BASH_DEFAULT_TIMEOUT_MS=90000", "BASH_MAX_TIMEOUT_MS=90000"} {
+		if !slices.Contains(cmd.Env, want) {
+			t.Errorf("expected %s in env", want)
+		}
+	}
+}
+
 func TestClaudeCodeBackend_EventParsing(t *testing.T) {
 	b := &ClaudeCodeBackend{}
 	events := make(chan StreamEvent, 64)
@@ -117,3 +170,26 @@ func TestClaudeCodeBackend_EventParsing(t *testing.T) {
 		t.Errorf("expected done event")
 	}
 }
+
+func TestClaudeCodeBackend_FileEdits(t *testing.T) {
+	b := &ClaudeCodeBackend{}
+	events := make(chan StreamEvent, 64)
+	b.setStream(context.Background(), events)
+	defer b.clearStream()
+
+	rawEvents := []string{
+		`{"type": "assistant", "message": {"content": [{"type": "tool_use", "name": "Edit", "id": "1", "input": {"file_path": "main.go"}}, {"type": "tool_use", "name": "Write", "id": "2", "input": {"file_path": "denied.go"}}, {"type": "tool_use", "name": "Read", "id": "3", "input": {"file_path": "README.md"}}]}}`,
+		`{"type": "user", "message": {"content": [{"type": "tool_result", "tool_use_id": "1", "content": "ok"}, {"type": "tool_result", "tool_use_id": "2", "is_error": true, "content": "denied"}, {"type": "tool_result", "tool_use_id": "3", "content": "# readme"}]}}`,
+	}
+	b.parseOutput(bufio.NewScanner(strings.NewReader(strings.Join(rawEvents, "\n"))))
+
+	var edited []string
+	for len(events) > 0 {
+		if ev := <-events; ev.Type == EventFileEdit {
+			edited = append(edited, ev.Path)
+		}
+	}
+	if len(edited) != 1 || edited[0] != "main.go" {
+		t.Errorf("file edits = %v, want only the successful Edit of main.go", edited)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"log/slog"
 	"os"
 	"os/exec"
+	"slices"
 	"strings"
 	"sync"
 
@@ -35,6 +36,11 @@ type CodexBackend struct {
 	sessionID    string
 	extraArgs    []string
 	systemPrompt string
+	container    *ContainerConfig
+	env          []string // extra NAME=value pairs for the CLI process
+	rawLog       *RawLog
+	// showReasoning emits reasoning items as thinking blocks
+	showReasoning bool
 
 	streamCtx     context.Context
 	events        chan<- StreamEvent
@@ -109,6 +115,38 @@ func WithCodexSystemPrompt(prompt string) CodexOption {
 	}
 }
 
+// WithCodexEnv adds NAME=value pairs to the CLI's environment. Provider
+// credentials set by the backend take precedence.
+func WithCodexEnv(env []string) CodexOption {
+	return func(b *CodexBackend) {
+		b.env = env
+	}
+}
+
+// WithCodexRawLog records the CLI's unparsed stdout and stderr to log.
+func WithCodexRawLog(log *RawLog) CodexOption {
+	return func(b *CodexBackend) {
+		b.rawLog = log
+	}
+}
+
+// WithCodexReasoning keeps the model's reasoning items as thinking blocks
+// instead of dropping them. Off by default, so reasoning isn't shown or
+// stored unless asked for.
+func WithCodexReasoning(show bool) CodexOption {
+	return func(b *CodexBackend) {
+		b.showReasoning = show
+	}
+}
+
+// WithCodexContainer runs the codex CLI inside a container that only
+// bind-mounts the working directory.
+func WithCodexContainer(cfg ContainerConfig) CodexOption {
+	return func(b *CodexBackend) {
+		b.container = &cfg
+	}
+}
+
 // NewCodexBackend creates a Codex CLI backend.
 func NewCodexBackend(opts ...CodexOption) (*CodexBackend, error) {
 	b := &CodexBackend{
@@ -119,8 +157,12 @@ func NewCodexBackend(opts ...CodexOption) (*CodexBackend, error) {
 		opt(b)
 	}
 
-	// Verify binary exists
-	if _, err := exec.LookPath(b.binaryPath); err != nil {
+	// Verify binary exists (inside a container the image provides it)
+	if b.container != nil {
+		if err := b.container.lookPath(); err != nil {
+			return nil, err
+		}
+	} else if _, err := exec.LookPath(b.binaryPath); err != nil {
 		return nil, fmt.Errorf("%w: %s", ErrCodexBinaryPath, b.binaryPath)
 	}
 
@@ -239,6 +281,7 @@ func (b *CodexBackend) execute(ctx context.Context, prompt string) error {
 	wg.Go(func() {
 		scanner := bufio.NewScanner(stderr)
 		for scanner.Scan() {
+			b.rawLog.Line("stderr", scanner.Text())
 			line := strings.TrimSpace(scanner.Text())
 			if line != "" {
 				slog.Warn("[CODEX] stderr", "line", line)
@@ -265,6 +308,7 @@ func (b *CodexBackend) execute(ctx context.Context, prompt string) error {
 
 func (b *CodexBackend) parseOutput(scanner *bufio.Scanner) {
 	for scanner.Scan() {
+		b.rawLog.Line("stdout", scanner.Text())
 		line := strings.TrimSpace(scanner.Text())
 		if line == "" {
 			continue
@@ -356,7 +400,16 @@ func (b *CodexBackend) processCodexItem(eventType string, item map[string]any) {
 		b.emitTextMessage("assistant", text)
 		return
 	case "reasoning":
-		// Intentionally ignored to avoid leaking reasoning content.
+		// Dropped unless asked for, to avoid leaking reasoning content.
+		if !isCompleted || !b.showReasoning {
+			return
+		}
+		text := extractTextFromContent(item["content"])
+		if text == "" {
+			text = getString(item, "text")
+		}
+		b.finalizeStreamText("assistant")
+		b.emitThinkingMessage(strings.TrimSpace(text))
 		return
 	case "plan_update":
 		// Treat plan updates as status text for now.
@@ -396,6 +449,9 @@ func (b *CodexBackend) processCodexItem(eventType string, item map[string]any) {
 	if isCompleted {
 		b.finalizeStreamText("assistant")
 		b.emitCodexToolResult(itemType, item)
+		if itemType == "file_change" {
+			b.emitFileChanges(item)
+		}
 		return
 	}
 
@@ -547,6 +603,24 @@ func (b *CodexBackend) emitCodexToolResult(itemType string, item map[string]any)
 	b.emit(StreamEvent{Type: EventMessageEnd, MessageID: msgID, Role: "user"})
 }
 
+// emitFileChanges reports each file a completed file_change item changed,
+// unless applying the change failed.
+func (b *CodexBackend) emitFileChanges(item map[string]any) {
+	if getString(item, "status") == "failed" {
+		return
+	}
+	changes, _ := item["changes"].([]any)
+	for _, change := range changes {
+		changeMap, ok := change.(map[string]any)
+		if !ok {
+			continue
+		}
+		if path := getString(changeMap, "path"); path != "" {
+			b.emit(StreamEvent{Type: EventFileEdit, Path: path})
+		}
+	}
+}
+
 func (b *CodexBackend) emit(event StreamEvent) {
 	b.mu.Lock()
 	ctx := b.streamCtx
@@ -652,6 +726,20 @@ func (b *CodexBackend) emitTextMessage(role, text string) {
 	b.emit(StreamEvent{Type: EventMessageEnd, MessageID: msgID, Role: role})
 }
 
+// emitThinkingMessage records reasoning as an assistant thinking block. It
+// is not part of the final message.
+func (b *CodexBackend) emitThinkingMessage(text string) {
+	if text == "" {
+		return
+	}
+	b.appendMessage("assistant", []ContentBlock{{Type: "thinking", Text: text}})
+	msgID := shortuuid.New()
+	b.emit(StreamEvent{Type: EventMessageStart, MessageID: msgID, Role: "assistant"})
+	b.emit(StreamEvent{Type: EventContentStart, MessageID: msgID, BlockType: "thinking", Block: &ContentBlock{Type: "thinking"}})
+	b.emit(StreamEvent{Type: EventContentEnd, MessageID: msgID, BlockType: "thinking", Block: &ContentBlock{Type: "thinking", Text: text}})
+	b.emit(StreamEvent{Type: EventMessageEnd, MessageID: msgID, Role: "assistant"})
+}
+
 func (b *CodexBackend) setSessionID(sessionID string) {
 	b.mu.Lock()
 	if sessionID == "" || b.sessionID == sessionID {
@@ -756,10 +844,7 @@ func (b *CodexBackend) buildCmd(ctx context.Context, prompt string) (*exec.Cmd,
 		args = append(args, prompt)
 	}
 
-	cmd := exec.CommandContext(ctx, b.binaryPath, args...)
-	cmd.Dir = b.workDir
-
-	env := os.Environ()
+	env := slices.Clone(b.env)
 	if b.apiKey != "" {
 		env = append(env, "CODEX_API_KEY="+b.apiKey)
 		env = append(env, "OPENAI_API_KEY="+b.apiKey)
@@ -768,7 +853,16 @@ func (b *CodexBackend) buildCmd(ctx context.Context, prompt string) (*exec.Cmd,
 		env = append(env, "OPENAI_BASE_URL="+b.baseURL)
 		env = append(env, "OPENAI_API_BASE="+b.baseURL)
 	}
-	cmd.Env = env
+
+	binary := b.binaryPath
+	if b.container != nil {
+		binary, args = b.container.wrap(b.workDir, binary, args, env)
+	}
+
+	cmd := exec.CommandContext(ctx, binary, args...)
+	cmd.Dir = b.workDir
+	cmd.Env = append(os.Environ(), env...)
+	cmd.WaitDelay = cliWaitDelay
 	return cmd, nil
 }
 
@@ -47,3 +47,53 @@ func TestCodexBackend_EventParsing(t *testing.T) {
 		t.Fatalf("expected done event")
 	}
 }
+
+func TestCodexBackend_Reasoning(t *testing.T) {
+	input := strings.Join([]string{
+		`{"type":"item.completed","item":{"id":"item_0","type":"reasoning","text":"**Listing files**"}}`,
+		`{"type":"item.completed","item":{"id":"item_1","type":"agent_message","text":"Done."}}`,
+	}, "\n")
+
+	for _, show := range []bool{false, true} {
+		b := &CodexBackend{showReasoning: show}
+		events := make(chan StreamEvent, 64)
+		b.setStream(context.Background(), events)
+		b.parseOutput(bufio.NewScanner(strings.NewReader(input)))
+		b.clearStream()
+
+		var received []StreamEvent
+		for len(events) > 0 {
+			received = append(received, <-events)
+		}
+		if got := hasBlockType(received, "thinking"); got != show {
+			t.Errorf("showReasoning=%v: thinking block emitted = %v", show, got)
+		}
+		if b.FinalMessage() != "Done." {
+			t.Errorf("showReasoning=%v: final message = %q, want reasoning left out", show, b.FinalMessage())
+		}
+	}
+}
+
+func TestCodexBackend_FileChanges(t *testing.T) {
+	input := strings.Join([]string{
+		`{"type":"item.started","item":{"id":"item_0","type":"file_change","changes":[{"path":"a.go","kind":"update"}],"status":"in_progress"}}`,
+		`{"type":"item.completed","item":{"id":"item_0","type":"file_change","changes":[{"path":"a.go","kind":"update"},{"path":"b.go","kind":"add"}],"status":"completed"}}`,
+		`{"type":"item.completed","item":{"id":"item_1","type":"file_change","changes":[{"path":"c.go","kind":"update"}],"status":"failed"}}`,
+	}, "\n")
+
+	b := &CodexBackend{}
+	events := make(chan StreamEvent, 64)
+	b.setStream(context.Background(), events)
+	b.parseOutput(bufio.NewScanner(strings.NewReader(input)))
+	b.clearStream()
+
+	var edited []string
+	for len(events) > 0 {
+		if ev := <-events; ev.Type == EventFileEdit {
+			edited = append(edited, ev.Path)
+		}
+	}
+	if strings.Join(edited, ",") != "a.go,b.go" {
+		t.Errorf("file edits = %v, want a.go and b.go from the completed change only", edited)
+	}
+}
@@ -0,0 +1,246 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/revrost/counterspell/internal/agent/tools"
+)
+
+// Compile-time interface check
+var _ Backend = (*MockBackend)(nil)
+
+// MockBackend is a Backend that replays a scripted sequence of StreamEvents
+// instead of calling an LLM, for deterministic tests of everything around
+// the agent: persistence, SSE, commits and diffs. It can also write files
+// into its work directory to stand in for the agent's edits.
+type MockBackend struct {
+	events  []StreamEvent
+	err     error
+	workDir string
+	files   map[string]string
+	hold    bool
+
+	mu       sync.Mutex
+	tasks    []string
+	messages []Message
+	final    string
+	todos    []tools.TodoItem
+	closed   bool
+}
+
+// MockBackendOption configures a MockBackend.
+type MockBackendOption func(*MockBackend)
+
+// WithMockEvents sets the events each Stream call replays, in order.
+func WithMockEvents(events ...StreamEvent) MockBackendOption {
+	return func(b *MockBackend) {
+		b.events = append(b.events, events...)
+	}
+}
+
+// WithMockError makes Stream finish with err after replaying its events.
+func WithMockError(err error) MockBackendOption {
+	return func(b *MockBackend) {
+		b.err = err
+	}
+}
+
+// WithMockHold keeps Stream running after its events are replayed until
+// the context is cancelled, like an agent still at work.
+func WithMockHold() MockBackendOption {
+	return func(b *MockBackend) {
+		b.hold = true
+	}
+}
+
+// WithMockWorkDir sets the directory WithMockFiles writes into.
+func WithMockWorkDir(dir string) MockBackendOption {
+	return func(b *MockBackend) {
+		b.workDir = dir
+	}
+}
+
+// WithMockFiles writes files (path relative to the work dir -> content)
+// before the events are replayed, as if the agent had edited them.
+func WithMockFiles(files map[string]string) MockBackendOption {
+	return func(b *MockBackend) {
+		b.files = files
+	}
+}
+
+// NewMockBackend creates a MockBackend.
+func NewMockBackend(opts ...MockBackendOption) *MockBackend {
+	b := &MockBackend{}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// MockTextMessage returns the events of an assistant message holding text,
+// as the native backend would stream it.
+func MockTextMessage(messageID, text string) []StreamEvent {
+	return []StreamEvent{
+		{Type: EventMessageStart, MessageID: messageID, Role: "assistant"},
+		{Type: EventContentStart, MessageID: messageID, BlockType: "text"},
+		{Type: EventContentDelta, MessageID: messageID, BlockType: "text", Delta: text},
+		{Type: EventContentEnd, MessageID: messageID, BlockType: "text", Block: &ContentBlock{Type: "text", Text: text}},
+		{Type: EventMessageEnd, MessageID: messageID, Role: "assistant"},
+	}
+}
+
+// Stream writes the configured files, then replays the scripted events.
+func (b *MockBackend) Stream(ctx context.Context, task string) *Stream {
+	// Unbuffered, so every event has been received before Done fires
+	events := make(chan StreamEvent)
+	done := make(chan error, 1)
+
+	b.mu.Lock()
+	b.tasks = append(b.tasks, task)
+	b.messages = append(b.messages, Message{Role: "user", Content: []ContentBlock{{Type: "text", Text: task}}})
+	b.mu.Unlock()
+
+	go func() {
+		defer close(events)
+		defer close(done)
+
+		if err := b.writeFiles(); err != nil {
+			done <- err
+			return
+		}
+		for _, event := range b.events {
+			if ctx.Err() != nil {
+				done <- ctx.Err()
+				return
+			}
+			b.record(event)
+			emitEvent(ctx, events, event)
+		}
+		if b.hold {
+			<-ctx.Done()
+			done <- ctx.Err()
+			return
+		}
+		done <- b.err
+	}()
+
+	return &Stream{Events: events, Done: done}
+}
+
+// Run executes a task and blocks until completion.
+func (b *MockBackend) Run(ctx context.Context, task string) error {
+	return drainStream(ctx, b.Stream(ctx, task))
+}
+
+func (b *MockBackend) writeFiles() error {
+	for name, content := range b.files {
+		path := filepath.Join(b.workDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("mock backend: %w", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			return fmt.Errorf("mock backend: %w", err)
+		}
+	}
+	return nil
+}
+
+// record folds a replayed event into the conversation history.
+func (b *MockBackend) record(event StreamEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch event.Type {
+	case EventMessageStart:
+		b.messages = append(b.messages, Message{Role: event.Role})
+	case EventContentEnd:
+		if event.Block == nil || len(b.messages) == 0 {
+			return
+		}
+		last := &b.messages[len(b.messages)-1]
+		last.Content = append(last.Content, *event.Block)
+		if last.Role == "assistant" && event.Block.Type == "text" {
+			b.final = event.Block.Text
+		}
+	case EventTodo:
+		b.todos = event.Todos
+	}
+}
+
+// Tasks returns the task of every Stream call so far.
+func (b *MockBackend) Tasks() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.tasks...)
+}
+
+// Closed reports whether Close was called.
+func (b *MockBackend) Closed() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.closed
+}
+
+// Close marks the backend closed.
+func (b *MockBackend) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	return nil
+}
+
+// GetState returns the conversation history as JSON.
+func (b *MockBackend) GetState() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.messages) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(b.messages)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// RestoreState sets the conversation history from JSON.
+func (b *MockBackend) RestoreState(stateJSON string) error {
+	var messages []Message
+	if err := json.Unmarshal([]byte(stateJSON), &messages); err != nil {
+		return fmt.Errorf("mock backend: invalid state: %w", err)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.messages = messages
+	return nil
+}
+
+// Messages returns the raw conversation history.
+func (b *MockBackend) Messages() []Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]Message(nil), b.messages...)
+}
+
+// FinalMessage returns the text of the last assistant text block replayed.
+func (b *MockBackend) FinalMessage() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.final
+}
+
+// Todos returns the todos of the last todo event replayed.
+func (b *MockBackend) Todos() []tools.TodoItem {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.todos
+}
+
+// Info returns backend metadata.
+func (b *MockBackend) Info() BackendInfo {
+	return BackendInfo{Type: BackendMock, Version: "1.0.0"}
+}
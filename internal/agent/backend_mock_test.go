@@ -0,0 +1,46 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMockBackend_ReplaysScript(t *testing.T) {
+	dir := t.TempDir()
+	backend := NewMockBackend(
+		WithMockWorkDir(dir),
+		WithMockFiles(map[string]string{"sub/a.txt": "edited"}),
+		WithMockEvents(MockTextMessage("msg-1", "done editing")...),
+		WithMockError(errors.New("boom")),
+	)
+
+	events, err := collectStream(backend.Stream(context.Background(), "edit a.txt"))
+	if err == nil || err.Error() != "boom" {
+		t.Fatalf("expected scripted error, got %v", err)
+	}
+	if len(events) != 5 || !hasEventType(events, EventContentDelta) {
+		t.Errorf("expected the 5 scripted events, got %d", len(events))
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "sub", "a.txt"))
+	if err != nil || string(data) != "edited" {
+		t.Errorf("expected scripted file edit, got %q (%v)", data, err)
+	}
+	if got := backend.FinalMessage(); got != "done editing" {
+		t.Errorf("FinalMessage() = %q", got)
+	}
+	if msgs := backend.Messages(); len(msgs) != 2 || msgs[0].Role != "user" || msgs[1].Role != "assistant" {
+		t.Errorf("unexpected history: %+v", msgs)
+	}
+
+	restored := NewMockBackend()
+	if err := restored.RestoreState(backend.GetState()); err != nil {
+		t.Fatalf("RestoreState: %v", err)
+	}
+	if len(restored.Messages()) != 2 {
+		t.Errorf("expected restored history")
+	}
+}
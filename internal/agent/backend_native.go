@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"time"
 
 	"github.com/revrost/counterspell/internal/agent/tools"
 	"github.com/revrost/counterspell/internal/llm"
@@ -14,6 +15,7 @@ var ErrProviderRequired = errors.New("agent: llm.Provider is required for native
 
 // Compile-time interface check
 var _ Backend = (*NativeBackend)(nil)
+var _ Steerable = (*NativeBackend)(nil)
 
 // NativeBackend wraps the Go-based Runner to implement Backend.
 //
@@ -35,6 +37,12 @@ type nativeBackendConfig struct {
 	provider     llm.Provider
 	workDir      string
 	systemPrompt string
+	llmTimeouts  *LLMTimeouts
+	env          []string
+	protected    []string
+	toolTimeout  time.Duration
+	maxIter      int
+	maxToolCalls int
 }
 
 // WithProvider sets the LLM provider.
@@ -58,6 +66,46 @@ func WithSystemPrompt(prompt string) NativeBackendOption {
 	}
 }
 
+// WithLLMTimeouts sets request and stream-idle timeouts for provider calls.
+func WithLLMTimeouts(timeouts LLMTimeouts) NativeBackendOption {
+	return func(c *nativeBackendConfig) {
+		c.llmTimeouts = &timeouts
+	}
+}
+
+// WithEnv adds NAME=value pairs to the environment of tool commands.
+func WithEnv(env []string) NativeBackendOption {
+	return func(c *nativeBackendConfig) {
+		c.env = env
+	}
+}
+
+// WithProtectedPaths makes the write and edit tools refuse paths matching
+// patterns, such as ".env" or "**/secrets/**". .git is always protected.
+func WithProtectedPaths(patterns []string) NativeBackendOption {
+	return func(c *nativeBackendConfig) {
+		c.protected = patterns
+	}
+}
+
+// WithToolTimeout kills a bash tool command running longer than timeout and
+// hands the model a tool error with its partial output, instead of letting
+// it stall the run. 0 disables.
+func WithToolTimeout(timeout time.Duration) NativeBackendOption {
+	return func(c *nativeBackendConfig) {
+		c.toolTimeout = timeout
+	}
+}
+
+// WithIterationLimits caps the LLM calls and tool calls of each run; zero
+// keeps DefaultMaxIterations or DefaultMaxToolCalls.
+func WithIterationLimits(maxIterations, maxToolCalls int) NativeBackendOption {
+	return func(c *nativeBackendConfig) {
+		c.maxIter = maxIterations
+		c.maxToolCalls = maxToolCalls
+	}
+}
+
 // NewNativeBackend creates a native Go agent backend.
 //
 // Example:
@@ -78,7 +126,21 @@ func NewNativeBackend(opts ...NativeBackendOption) (*NativeBackend, error) {
 		return nil, ErrProviderRequired
 	}
 
-	runner := NewRunner(cfg.provider, cfg.workDir, WithRunnerSystemPrompt(cfg.systemPrompt))
+	runnerOpts := []RunnerOption{WithRunnerSystemPrompt(cfg.systemPrompt)}
+	if cfg.llmTimeouts != nil {
+		runnerOpts = append(runnerOpts, WithRunnerLLMTimeouts(*cfg.llmTimeouts))
+	}
+	if len(cfg.env) > 0 {
+		runnerOpts = append(runnerOpts, WithRunnerEnv(cfg.env))
+	}
+	if cfg.toolTimeout > 0 {
+		runnerOpts = append(runnerOpts, WithRunnerToolTimeout(cfg.toolTimeout))
+	}
+	if len(cfg.protected) > 0 {
+		runnerOpts = append(runnerOpts, WithRunnerProtectedPaths(cfg.protected))
+	}
+	runnerOpts = append(runnerOpts, WithRunnerLimits(cfg.maxIter, cfg.maxToolCalls))
+	runner := NewRunner(cfg.provider, cfg.workDir, runnerOpts...)
 
 	return &NativeBackend{runner: runner}, nil
 }
@@ -100,6 +162,11 @@ func (b *NativeBackend) Stream(ctx context.Context, task string) *Stream {
 // 	return b.runner.Continue(ctx, message)
 // }
 
+// Steer queues a message for the run in progress.
+func (b *NativeBackend) Steer(message string) error {
+	return b.runner.Steer(message)
+}
+
 // Close releases resources (no-op for native, context cancellation handles cleanup).
 func (b *NativeBackend) Close() error {
 	return nil
@@ -0,0 +1,66 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ContainerConfig runs a CLI backend inside a throwaway container instead of
+// directly on the host.
+//
+// Only the task workspace is bind-mounted (at the same absolute path, so paths
+// in prompts and tool output stay valid). The agent can still reach the network
+// to talk to its model provider, but it cannot see the base repository, the
+// data directory or the rest of the host filesystem.
+type ContainerConfig struct {
+	// Image must provide the backend CLI (claude or codex) on its PATH.
+	Image string
+	// Runtime is the container CLI to invoke. Defaults to "docker".
+	Runtime string
+}
+
+func (c *ContainerConfig) runtime() string {
+	if c.Runtime == "" {
+		return "docker"
+	}
+	return c.Runtime
+}
+
+// lookPath verifies the container runtime is installed.
+func (c *ContainerConfig) lookPath() error {
+	if c.Image == "" {
+		return fmt.Errorf("agent: container image is required")
+	}
+	if _, err := exec.LookPath(c.runtime()); err != nil {
+		return fmt.Errorf("agent: container runtime %q not found in PATH", c.runtime())
+	}
+	return nil
+}
+
+// wrap rewrites binary+args so they execute inside the container. The binary
+// is resolved by name inside the image, not by its host path.
+//
+// Each entry in env (KEY=VALUE) is forwarded with a bare `-e KEY`, which makes
+// the runtime copy the value from its own environment. Secrets therefore never
+// show up on the command line; the caller must put env on the runtime process.
+func (c *ContainerConfig) wrap(workDir, binary string, args, env []string) (string, []string) {
+	runArgs := []string{
+		"run", "--rm", "-i",
+		"--user", fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid()),
+		"-v", workDir + ":" + workDir,
+		"-w", workDir,
+		"-e", "HOME=/tmp",
+	}
+	for _, kv := range env {
+		key, _, _ := strings.Cut(kv, "=")
+		if key != "" {
+			runArgs = append(runArgs, "-e", key)
+		}
+	}
+	runArgs = append(runArgs, c.Image, filepath.Base(binary))
+	runArgs = append(runArgs, args...)
+	return c.runtime(), runArgs
+}
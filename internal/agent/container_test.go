@@ -0,0 +1,29 @@
+package agent
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestContainerConfigWrap(t *testing.T) {
+	c := &ContainerConfig{Image: "counterspell/agent:latest"}
+	bin, args := c.wrap("/data/worktrees/task-1", "/usr/local/bin/codex", []string{"exec", "--json"}, []string{"OPENAI_API_KEY=sk-secret"})
+
+	if bin != "docker" {
+		t.Fatalf("expected docker runtime, got %s", bin)
+	}
+	joined := strings.Join(args, " ")
+	if !strings.Contains(joined, "-v /data/worktrees/task-1:/data/worktrees/task-1") {
+		t.Errorf("expected workspace bind mount, got %s", joined)
+	}
+	if strings.Contains(joined, "sk-secret") {
+		t.Errorf("secret leaked into container args: %s", joined)
+	}
+	if !slices.Contains(args, "OPENAI_API_KEY") {
+		t.Errorf("expected env key to be forwarded, got %s", joined)
+	}
+	if !strings.HasSuffix(joined, "counterspell/agent:latest codex exec --json") {
+		t.Errorf("expected image, binary name and args at the end, got %s", joined)
+	}
+}
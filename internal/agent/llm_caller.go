@@ -5,11 +5,13 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/revrost/counterspell/internal/agent/tools"
 	"github.com/revrost/counterspell/internal/llm"
@@ -58,24 +60,68 @@ type LLMStream struct {
 	Done   <-chan error
 }
 
+// APIError is an error reported by an LLM provider, either as a non-200
+// response (StatusCode set) or as an error event mid-stream (Type set).
+type APIError struct {
+	StatusCode int
+	Type       string // e.g. "overloaded_error", "rate_limit_error"
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("llm error: %s", e.Message)
+	}
+	return fmt.Sprintf("api error %d: %s", e.StatusCode, e.Message)
+}
+
+// Retryable reports whether another provider might succeed where this one
+// failed: overload, rate limiting and server errors. Auth and request errors
+// are deterministic and would fail anywhere.
+func (e *APIError) Retryable() bool {
+	switch e.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout, 529: // 529: Anthropic overloaded
+		return true
+	}
+	switch e.Type {
+	case "overloaded_error", "rate_limit_error", "api_error":
+		return true
+	}
+	return false
+}
+
+// IsRetryableLLMError reports whether err is a transient provider failure
+// worth retrying on a different provider.
+func IsRetryableLLMError(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Retryable()
+	}
+	return errors.Is(err, ErrStreamIdle)
+}
+
 // LLMCaller is an interface for calling LLM APIs.
 type LLMCaller interface {
 	Stream(ctx context.Context, messages []Message, allTools map[string]tools.Tool, systemPrompt string) (*LLMStream, error)
 }
 
 // NewLLMCaller creates an LLMCaller based on the provider type.
-func NewLLMCaller(provider llm.Provider) LLMCaller {
+func NewLLMCaller(provider llm.Provider, timeouts LLMTimeouts) LLMCaller {
+	client := timeouts.httpClient()
 	switch provider.Type() {
 	case "openai":
-		return &OpenAICaller{provider: provider}
+		return &OpenAICaller{provider: provider, client: client, idleTimeout: timeouts.StreamIdle}
 	default:
-		return &AnthropicCaller{provider: provider}
+		return &AnthropicCaller{provider: provider, client: client, idleTimeout: timeouts.StreamIdle}
 	}
 }
 
 // AnthropicCaller implements LLMCaller for Anthropic-compatible APIs.
 type AnthropicCaller struct {
-	provider llm.Provider
+	provider    llm.Provider
+	client      *http.Client
+	idleTimeout time.Duration
 }
 
 func (c *AnthropicCaller) Stream(ctx context.Context, messages []Message, allTools map[string]tools.Tool, systemPrompt string) (*LLMStream, error) {
@@ -102,8 +148,11 @@ func (c *AnthropicCaller) Stream(ctx context.Context, messages []Message, allToo
 	)
 	slog.Debug("[LLM STREAM] Full payload", "body", string(prettyBody))
 
+	// Cancelled by the idle watchdog, or when the stream goroutine finishes
+	ctx, cancel := context.WithCancel(ctx)
 	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.provider.APIURL(), bytes.NewReader(body))
 	if err != nil {
+		cancel()
 		return nil, fmt.Errorf("create request: %w", err)
 	}
 
@@ -119,16 +168,18 @@ func (c *AnthropicCaller) Stream(ctx context.Context, messages []Message, allToo
 		httpReq.Header.Set("HTTP-Referer", "https://counterspell.dev")
 	}
 
-	client := &http.Client{}
-	resp, err := client.Do(httpReq)
+	resp, err := c.client.Do(httpReq)
 	if err != nil {
+		cancel()
 		return nil, fmt.Errorf("do request: %w", err)
 	}
 	if resp.StatusCode != http.StatusOK {
+		defer cancel()
 		defer resp.Body.Close()
 		respBody, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("api error %d: %s", resp.StatusCode, string(respBody))
+		return nil, &APIError{StatusCode: resp.StatusCode, Message: string(respBody)}
 	}
+	resp.Body = withIdleTimeout(resp.Body, c.idleTimeout, cancel)
 
 	events := make(chan LLMEvent, 32)
 	done := make(chan error, 1)
@@ -136,6 +187,7 @@ func (c *AnthropicCaller) Stream(ctx context.Context, messages []Message, allToo
 	go func() {
 		defer close(events)
 		defer close(done)
+		defer cancel()
 		defer resp.Body.Close()
 
 		blockTypes := map[int]string{}
@@ -227,11 +279,12 @@ func (c *AnthropicCaller) Stream(ctx context.Context, messages []Message, allToo
 			case "error":
 				var evt struct {
 					Error struct {
+						Type    string `json:"type"`
 						Message string `json:"message"`
 					} `json:"error"`
 				}
 				if err := json.Unmarshal([]byte(payload), &evt); err == nil {
-					done <- fmt.Errorf("llm error: %s", evt.Error.Message)
+					done <- &APIError{Type: evt.Error.Type, Message: evt.Error.Message}
 					return false
 				}
 			}
@@ -270,7 +323,9 @@ func (c *AnthropicCaller) Stream(ctx context.Context, messages []Message, allToo
 
 // OpenAICaller implements LLMCaller for OpenAI-compatible APIs.
 type OpenAICaller struct {
-	provider llm.Provider
+	provider    llm.Provider
+	client      *http.Client
+	idleTimeout time.Duration
 }
 
 // OpenAI-specific request/response types
@@ -346,6 +401,20 @@ func (c *OpenAICaller) Stream(ctx context.Context, messages []Message, allTools
 		}
 
 		if isToolResult {
+			// Text sent along with tool results (steered messages) follows
+			// the tool messages as a user turn
+			var contentBuilder strings.Builder
+			for _, block := range msg.Content {
+				if block.Type == "text" {
+					contentBuilder.WriteString(block.Text)
+				}
+			}
+			if contentBuilder.Len() > 0 {
+				openAIMessages = append(openAIMessages, OpenAIMessage{
+					Role:    "user",
+					Content: contentBuilder.String(),
+				})
+			}
 			continue
 		}
 
@@ -418,24 +487,29 @@ func (c *OpenAICaller) Stream(ctx context.Context, messages []Message, allTools
 		return nil, fmt.Errorf("marshal request: %w", err)
 	}
 
+	// Cancelled by the idle watchdog, or when the stream goroutine finishes
+	ctx, cancel := context.WithCancel(ctx)
 	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.provider.APIURL(), bytes.NewReader(body))
 	if err != nil {
+		cancel()
 		return nil, fmt.Errorf("create request: %w", err)
 	}
 
 	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.Header.Set("Authorization", "Bearer "+c.provider.APIKey())
 
-	client := &http.Client{}
-	resp, err := client.Do(httpReq)
+	resp, err := c.client.Do(httpReq)
 	if err != nil {
+		cancel()
 		return nil, fmt.Errorf("do request: %w", err)
 	}
 	if resp.StatusCode != http.StatusOK {
+		defer cancel()
 		defer resp.Body.Close()
 		respBody, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("api error %d: %s", resp.StatusCode, string(respBody))
+		return nil, &APIError{StatusCode: resp.StatusCode, Message: string(respBody)}
 	}
+	resp.Body = withIdleTimeout(resp.Body, c.idleTimeout, cancel)
 
 	events := make(chan LLMEvent, 32)
 	done := make(chan error, 1)
@@ -443,6 +517,7 @@ func (c *OpenAICaller) Stream(ctx context.Context, messages []Message, allTools
 	go func() {
 		defer close(events)
 		defer close(done)
+		defer cancel()
 		defer resp.Body.Close()
 
 		textActive := false
@@ -0,0 +1,77 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// ErrStreamIdle is returned when a provider stops sending data mid-stream.
+var ErrStreamIdle = errors.New("llm stream idle timeout")
+
+// LLMTimeouts bounds how long an LLM provider may stall a run. Zero values
+// disable the corresponding limit.
+type LLMTimeouts struct {
+	// Request caps the wait for response headers after sending a request.
+	Request time.Duration
+	// StreamIdle fails a streaming response when no bytes arrive for this
+	// long. The timer resets on every chunk, so long generations are fine.
+	StreamIdle time.Duration
+}
+
+// DefaultLLMTimeouts are used when no timeouts are configured.
+var DefaultLLMTimeouts = LLMTimeouts{
+	Request:    60 * time.Second,
+	StreamIdle: 60 * time.Second,
+}
+
+// httpClient returns a client whose transport enforces the request timeout.
+// http.Client.Timeout is not used because it would also cap the stream body.
+func (t LLMTimeouts) httpClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = t.Request
+	return &http.Client{Transport: transport}
+}
+
+// idleTimeoutBody cancels the request when the body goes quiet for longer
+// than timeout, turning a hung connection into a read error.
+type idleTimeoutBody struct {
+	io.ReadCloser
+	timeout time.Duration
+	timer   *time.Timer
+	expired atomic.Bool
+}
+
+// withIdleTimeout wraps body so that reads fail with ErrStreamIdle after
+// timeout without data. cancel must cancel the request's context.
+func withIdleTimeout(body io.ReadCloser, timeout time.Duration, cancel context.CancelFunc) io.ReadCloser {
+	if timeout <= 0 {
+		return body
+	}
+	b := &idleTimeoutBody{ReadCloser: body, timeout: timeout}
+	b.timer = time.AfterFunc(timeout, func() {
+		b.expired.Store(true)
+		cancel()
+	})
+	return b
+}
+
+func (b *idleTimeoutBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 && !b.expired.Load() {
+		b.timer.Reset(b.timeout)
+	}
+	if err != nil && b.expired.Load() {
+		return n, fmt.Errorf("%w: no data for %s", ErrStreamIdle, b.timeout)
+	}
+	return n, err
+}
+
+func (b *idleTimeoutBody) Close() error {
+	b.timer.Stop()
+	return b.ReadCloser.Close()
+}
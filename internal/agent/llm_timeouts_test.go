@@ -0,0 +1,99 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testProvider points a caller at a local test server.
+type testProvider struct {
+	url, typ string
+}
+
+func (p testProvider) APIURL() string     { return p.url }
+func (p testProvider) APIVersion() string { return "" }
+func (p testProvider) APIKey() string     { return "test" }
+func (p testProvider) Model() string      { return "test-model" }
+func (p testProvider) SetModel(string)    {}
+func (p testProvider) Type() string       { return p.typ }
+
+func TestLLMCaller_StreamIdleTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"hi\"}}]}\n\n")
+		w.(http.Flusher).Flush()
+		// Stall mid-stream without closing the connection
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	caller := NewLLMCaller(testProvider{url: server.URL, typ: "openai"}, LLMTimeouts{StreamIdle: 100 * time.Millisecond})
+	stream, err := caller.Stream(context.Background(), nil, nil, "")
+	require.NoError(t, err)
+
+	for range stream.Events {
+	}
+	select {
+	case err := <-stream.Done:
+		require.True(t, errors.Is(err, ErrStreamIdle), "got %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream did not time out")
+	}
+}
+
+func TestLLMCaller_RequestTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	caller := NewLLMCaller(testProvider{url: server.URL, typ: "anthropic"}, LLMTimeouts{Request: 100 * time.Millisecond})
+	start := time.Now()
+	_, err := caller.Stream(context.Background(), nil, nil, "")
+	require.Error(t, err)
+	require.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestIsRetryableLLMError(t *testing.T) {
+	require.True(t, IsRetryableLLMError(&APIError{StatusCode: 529, Message: "overloaded"}))
+	require.True(t, IsRetryableLLMError(&APIError{StatusCode: http.StatusTooManyRequests}))
+	require.True(t, IsRetryableLLMError(fmt.Errorf("stream: %w", &APIError{Type: "overloaded_error"})))
+	require.True(t, IsRetryableLLMError(fmt.Errorf("%w: no data", ErrStreamIdle)))
+
+	// Deterministic failures would fail on any provider.
+	require.False(t, IsRetryableLLMError(&APIError{StatusCode: http.StatusUnauthorized, Message: "invalid x-api-key"}))
+	require.False(t, IsRetryableLLMError(&APIError{StatusCode: http.StatusBadRequest}))
+	require.False(t, IsRetryableLLMError(errors.New("tool failed")))
+}
+
+func TestLLMCaller_ReturnsAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(529)
+		fmt.Fprint(w, `{"type":"error","error":{"type":"overloaded_error"}}`)
+	}))
+	defer server.Close()
+
+	caller := NewLLMCaller(testProvider{url: server.URL, typ: "anthropic"}, LLMTimeouts{})
+	_, err := caller.Stream(context.Background(), nil, nil, "")
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, 529, apiErr.StatusCode)
+	require.True(t, apiErr.Retryable())
+}
@@ -0,0 +1,47 @@
+package agent
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// RawLog records the unparsed stdout and stderr of a CLI backend, one line
+// per entry, so output the parsers don't recognize can still be inspected.
+// It is safe for concurrent use; a nil *RawLog discards everything.
+type RawLog struct {
+	mu sync.Mutex
+	w  io.Writer
+	// limit caps the bytes written; lines past it are dropped after a marker
+	limit     int64
+	written   int64
+	truncated bool
+}
+
+// NewRawLog returns a RawLog writing to w. Once limit bytes have been
+// written, a truncation marker is written and later lines are dropped;
+// 0 means no limit.
+func NewRawLog(w io.Writer, limit int64) *RawLog {
+	return &RawLog{w: w, limit: limit}
+}
+
+// Line records one line read from stream ("stdout" or "stderr").
+func (l *RawLog) Line(stream, line string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.truncated {
+		return
+	}
+	entry := fmt.Sprintf("%s %s | %s\n", time.Now().UTC().Format(time.RFC3339Nano), stream, line)
+	if l.limit > 0 && l.written+int64(len(entry)) > l.limit {
+		l.truncated = true
+		_, _ = fmt.Fprintf(l.w, "[raw log truncated at %d bytes]\n", l.limit)
+		return
+	}
+	n, _ := io.WriteString(l.w, entry)
+	l.written += int64(n)
+}
@@ -0,0 +1,35 @@
+package agent
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRawLog_Limit(t *testing.T) {
+	var buf bytes.Buffer
+	log := NewRawLog(&buf, 120)
+	for range 10 {
+		log.Line("stdout", "0123456789")
+	}
+
+	out := buf.String()
+	if got := strings.Count(out, "stdout | 0123456789"); got != 2 {
+		t.Errorf("expected 2 lines under the limit, got %d:\n%s", got, out)
+	}
+	if !strings.HasSuffix(out, "[raw log truncated at 120 bytes]\n") {
+		t.Errorf("expected a single trailing truncation marker, got:\n%s", out)
+	}
+	if got := strings.Count(out, "truncated"); got != 1 {
+		t.Errorf("expected the marker once, got %d", got)
+	}
+
+	buf.Reset()
+	unlimited := NewRawLog(&buf, 0)
+	for range 10 {
+		unlimited.Line("stderr", "0123456789")
+	}
+	if got := strings.Count(buf.String(), "\n"); got != 10 {
+		t.Errorf("expected all 10 lines without a limit, got %d", got)
+	}
+}
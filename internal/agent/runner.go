@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"log/slog"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/lithammer/shortuuid/v4"
 	"github.com/revrost/counterspell/internal/agent/tools"
@@ -54,37 +56,104 @@ func WithRunnerSystemPrompt(prompt string) RunnerOption {
 	}
 }
 
+// WithRunnerLLMTimeouts overrides DefaultLLMTimeouts for provider calls.
+func WithRunnerLLMTimeouts(timeouts LLMTimeouts) RunnerOption {
+	return func(r *Runner) {
+		r.llmTimeouts = timeouts
+	}
+}
+
+// WithRunnerEnv adds NAME=value pairs to the environment of tool commands.
+func WithRunnerEnv(env []string) RunnerOption {
+	return func(r *Runner) {
+		r.env = env
+	}
+}
+
+// WithRunnerProtectedPaths sets path patterns the file-editing tools refuse
+// to modify.
+func WithRunnerProtectedPaths(patterns []string) RunnerOption {
+	return func(r *Runner) {
+		r.protectedPaths = patterns
+	}
+}
+
+// WithRunnerToolTimeout kills bash tool commands running longer than
+// timeout, reporting a tool error to the model. 0 disables.
+func WithRunnerToolTimeout(timeout time.Duration) RunnerOption {
+	return func(r *Runner) {
+		r.toolTimeout = timeout
+	}
+}
+
+// Default limits on a single run of the agent loop, so a model stuck
+// calling tools can't burn tokens forever.
+const (
+	DefaultMaxIterations = 100 // LLM calls per run
+	DefaultMaxToolCalls  = 300 // tool calls per run
+)
+
+// WithRunnerLimits caps the LLM calls and tool calls of each run. Zero keeps
+// the default for that limit.
+func WithRunnerLimits(maxIterations, maxToolCalls int) RunnerOption {
+	return func(r *Runner) {
+		if maxIterations > 0 {
+			r.maxIterations = maxIterations
+		}
+		if maxToolCalls > 0 {
+			r.maxToolCalls = maxToolCalls
+		}
+	}
+}
+
 // Runner executes agent tasks with streaming output.
 type Runner struct {
 	provider       llm.Provider
 	llmCaller      LLMCaller
+	llmTimeouts    LLMTimeouts
 	workDir        string
 	systemPrompt   string
+	env            []string
+	protectedPaths []string
+	toolTimeout    time.Duration
+	maxIterations  int
+	maxToolCalls   int
 	finalMessage   string
 	messageHistory []Message
 	todoState      *tools.TodoState
 	toolRegistry   *tools.Registry
 	toolCtx        *tools.Context
+
+	// Messages steered into the run in progress, see Steer
+	steerMu sync.Mutex
+	active  bool
+	steered []string
 }
 
 // NewRunner creates a new agent runner.
 func NewRunner(provider llm.Provider, workDir string, opts ...RunnerOption) *Runner {
 	r := &Runner{
-		provider:     provider,
-		llmCaller:    NewLLMCaller(provider),
-		workDir:      workDir,
-		systemPrompt: fmt.Sprintf("You are a coding assistant. Work directory: %s. Be concise. Make changes directly.", workDir),
-		todoState:    tools.NewTodoState(),
+		provider:      provider,
+		llmTimeouts:   DefaultLLMTimeouts,
+		workDir:       workDir,
+		systemPrompt:  fmt.Sprintf("You are a coding assistant. Work directory: %s. Be concise. Make changes directly.", workDir),
+		maxIterations: DefaultMaxIterations,
+		maxToolCalls:  DefaultMaxToolCalls,
+		todoState:     tools.NewTodoState(),
 	}
 
 	for _, opt := range opts {
 		opt(r)
 	}
+	r.llmCaller = NewLLMCaller(provider, r.llmTimeouts)
 
 	// Create tool registry with context
 	toolCtx := &tools.Context{
-		WorkDir:   workDir,
-		TodoState: r.todoState,
+		WorkDir:        workDir,
+		Env:            r.env,
+		ProtectedPaths: r.protectedPaths,
+		CommandTimeout: r.toolTimeout,
+		TodoState:      r.todoState,
 	}
 	r.toolCtx = toolCtx
 	r.toolRegistry = tools.NewRegistry(toolCtx)
@@ -240,6 +309,52 @@ func (b *messageBuilder) finalizeAll() {
 	_ = b.finalizeCurrent()
 }
 
+// Steer queues a message for the run in progress. It is added to the
+// conversation before the next LLM call, after any pending tool results, or
+// keeps the run going when the model was about to finish. Returns
+// ErrNotRunning when no run is in progress.
+func (r *Runner) Steer(message string) error {
+	r.steerMu.Lock()
+	defer r.steerMu.Unlock()
+	if !r.active {
+		return ErrNotRunning
+	}
+	r.steered = append(r.steered, message)
+	return nil
+}
+
+// takeSteered returns and clears the queued messages. With finishing set and
+// nothing queued, the run is marked done so later Steer calls fail rather
+// than being lost.
+func (r *Runner) takeSteered(finishing bool) []string {
+	r.steerMu.Lock()
+	defer r.steerMu.Unlock()
+	steered := r.steered
+	r.steered = nil
+	if finishing && len(steered) == 0 {
+		r.active = false
+	}
+	return steered
+}
+
+// steeredBlocks turns queued messages into text blocks, emitted as a user
+// message so they show up in the chat like any other.
+func steeredBlocks(ctx context.Context, events chan<- StreamEvent, steered []string) []ContentBlock {
+	blocks := make([]ContentBlock, 0, len(steered))
+	for _, text := range steered {
+		slog.Info("[RUNNER] Adding steered message to the run", "length", len(text))
+		block := ContentBlock{Type: "text", Text: text}
+		blocks = append(blocks, block)
+
+		msgID := shortuuid.New()
+		emitEvent(ctx, events, StreamEvent{Type: EventMessageStart, MessageID: msgID, Role: "user"})
+		emitEvent(ctx, events, StreamEvent{Type: EventContentStart, MessageID: msgID, BlockType: "text", Block: &block})
+		emitEvent(ctx, events, StreamEvent{Type: EventContentEnd, MessageID: msgID, BlockType: "text", Block: &block})
+		emitEvent(ctx, events, StreamEvent{Type: EventMessageEnd, MessageID: msgID, Role: "user"})
+	}
+	return blocks
+}
+
 // runWithMessage is the core loop that handles both new runs and continuations.
 func (r *Runner) runWithMessage(ctx context.Context, userMessage string, isContinuation bool, events chan<- StreamEvent, todoEvents chan []tools.TodoItem) error {
 	allTools := r.toolRegistry.All()
@@ -268,6 +383,19 @@ func (r *Runner) runWithMessage(ctx context.Context, userMessage string, isConti
 		return fmt.Errorf("agent: cannot start task with empty message")
 	}
 
+	r.steerMu.Lock()
+	r.active = true
+	r.steerMu.Unlock()
+	defer func() {
+		r.steerMu.Lock()
+		defer r.steerMu.Unlock()
+		if len(r.steered) > 0 {
+			slog.Warn("[RUNNER] Run ended before steered messages were read", "dropped", len(r.steered))
+		}
+		r.active = false
+		r.steered = nil
+	}()
+
 	todoDone := make(chan struct{})
 	go func() {
 		defer close(todoDone)
@@ -279,6 +407,8 @@ func (r *Runner) runWithMessage(ctx context.Context, userMessage string, isConti
 	defer close(todoEvents)
 
 	// Agent loop
+	var iterations, toolCalls int
+	limitNotice := ""
 	for {
 		select {
 		case <-ctx.Done():
@@ -287,6 +417,12 @@ func (r *Runner) runWithMessage(ctx context.Context, userMessage string, isConti
 		default:
 		}
 
+		if iterations >= r.maxIterations {
+			limitNotice = fmt.Sprintf("iteration limit reached: stopped after %d LLM calls", iterations)
+			break
+		}
+		iterations++
+
 		slog.Info("[RUNNER] Calling LLM API", "message_count", len(messages), "tool_count", len(allTools), "system_prompt", r.systemPrompt)
 		stream, err := r.llmCaller.Stream(ctx, messages, allTools, r.systemPrompt)
 		if err != nil {
@@ -346,6 +482,9 @@ func (r *Runner) runWithMessage(ctx context.Context, userMessage string, isConti
 					continue
 				}
 				if err != nil {
+					// The partial reply is dropped; the history ends where
+					// the next model call would pick up
+					r.messageHistory = messages
 					emitEvent(ctx, events, StreamEvent{Type: EventError, Error: err.Error()})
 					return err
 				}
@@ -371,13 +510,27 @@ func (r *Runner) runWithMessage(ctx context.Context, userMessage string, isConti
 		}
 
 		if len(builder.toolCalls) == 0 {
+			if steered := r.takeSteered(true); len(steered) > 0 {
+				messages = append(messages, Message{Role: "user", Content: steeredBlocks(ctx, events, steered)})
+				continue
+			}
 			slog.Info("[RUNNER] No more tools to run, completing task")
 			break
 		}
 
 		toolResults := []ContentBlock{}
 		for _, block := range builder.toolCalls {
-			result := r.runTool(block.Name, block.Input, allTools)
+			// Every tool_use still gets a result, keeping the history valid
+			// for a follow-up after the limit stops the run
+			var result, edited string
+			if toolCalls >= r.maxToolCalls {
+				limitNotice = fmt.Sprintf("tool call limit reached: stopped after %d tool calls", toolCalls)
+				result = "error: tool call limit reached, the run is stopping"
+			} else {
+				toolCalls++
+				result = r.runTool(block.Name, block.Input, allTools)
+				edited = editedPath(block.Name, block.Input, result)
+			}
 			toolResults = append(toolResults, ContentBlock{
 				Type:      "tool_result",
 				ToolUseID: block.ID,
@@ -407,9 +560,19 @@ func (r *Runner) runWithMessage(ctx context.Context, userMessage string, isConti
 				},
 			})
 			emitEvent(ctx, events, StreamEvent{Type: EventMessageEnd, MessageID: toolMsgID, Role: "user"})
+			if edited != "" {
+				emitEvent(ctx, events, StreamEvent{Type: EventFileEdit, Path: edited})
+			}
 		}
 
 		slog.Info("[RUNNER] Running %d tool result(s) through agent loop", "len_tool_results", len(toolResults))
+		if limitNotice != "" {
+			messages = append(messages, Message{Role: "user", Content: toolResults})
+			break
+		}
+		// Steered messages ride along with the tool results, keeping user
+		// and assistant turns alternating
+		toolResults = append(toolResults, steeredBlocks(ctx, events, r.takeSteered(false))...)
 		toolResultMsg := Message{Role: "user", Content: toolResults}
 		messages = append(messages, toolResultMsg)
 	}
@@ -417,6 +580,11 @@ func (r *Runner) runWithMessage(ctx context.Context, userMessage string, isConti
 	// Store message history for future continuations
 	r.messageHistory = messages
 
+	if limitNotice != "" {
+		slog.Warn("[RUNNER] Stopping run at limit", "notice", limitNotice)
+		emitEvent(ctx, events, StreamEvent{Type: EventIterationLimit, Notice: limitNotice})
+	}
+
 	emitEvent(ctx, events, StreamEvent{Type: EventDone})
 	return nil
 }
@@ -429,6 +597,25 @@ func emitEvent(ctx context.Context, events chan<- StreamEvent, event StreamEvent
 	}
 }
 
+// editedPath returns the file a successful write, edit or multiedit call
+// changed, or "" for other calls.
+func editedPath(name string, args map[string]any, result string) string {
+	if strings.HasPrefix(result, "error") {
+		return ""
+	}
+	var key string
+	switch name {
+	case "write", "edit":
+		key = "path"
+	case "multiedit":
+		key = "file_path"
+	default:
+		return ""
+	}
+	path, _ := args[key].(string)
+	return path
+}
+
 func (r *Runner) runTool(name string, args map[string]any, allTools map[string]tools.Tool) string {
 	tool, ok := allTools[name]
 	if !ok {
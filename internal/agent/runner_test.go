@@ -2,7 +2,13 @@ package agent
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/revrost/counterspell/internal/agent/tools"
 	"github.com/revrost/counterspell/internal/llm"
@@ -116,3 +122,280 @@ func (m *mockLLMProvider) Model() string         { return "test-model" }
 func (m *mockLLMProvider) APIURL() string        { return "" }
 func (m *mockLLMProvider) APIKey() string        { return "" }
 func (m *mockLLMProvider) APIVersion() string    { return "" }
+
+// toolLoopStream is an assistant turn that calls an unknown tool n times,
+// as a model stuck in a loop would.
+func toolLoopStream(n int) *LLMStream {
+	var events []LLMEvent
+	for i := range n {
+		block := &ContentBlock{Type: "tool_use", Name: "nope", ID: fmt.Sprintf("call-%d", i)}
+		events = append(events,
+			LLMEvent{Type: LLMContentStart, BlockType: "tool_use", Block: block},
+			LLMEvent{Type: LLMContentDelta, BlockType: "tool_use", Delta: "{}"},
+			LLMEvent{Type: LLMContentEnd, BlockType: "tool_use"},
+		)
+	}
+	return makeLLMStream(append(events, LLMEvent{Type: LLMMessageEnd}))
+}
+
+func TestRunner_IterationLimits(t *testing.T) {
+	tests := []struct {
+		name          string
+		maxIterations int
+		maxToolCalls  int
+		callsPerTurn  int
+		wantLLMCalls  int
+		wantNotice    string
+	}{
+		{"iterations", 3, 0, 1, 3, "iteration limit reached: stopped after 3 LLM calls"},
+		{"tool calls", 0, 4, 3, 2, "tool call limit reached: stopped after 4 tool calls"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockCaller := NewMockLLMCaller(ctrl)
+			mockCaller.EXPECT().
+				Stream(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+				DoAndReturn(func(context.Context, []Message, map[string]tools.Tool, string) (*LLMStream, error) {
+					return toolLoopStream(tt.callsPerTurn), nil
+				}).
+				Times(tt.wantLLMCalls)
+
+			r := NewRunner(&mockLLMProvider{}, ".", WithRunnerLimits(tt.maxIterations, tt.maxToolCalls))
+			r.llmCaller = mockCaller
+
+			events, err := collectStream(r.Stream(context.Background(), "loop forever"))
+			if err != nil {
+				t.Fatalf("run should finish cleanly at the limit, got %v", err)
+			}
+			var notices []string
+			for _, ev := range events {
+				if ev.Type == EventIterationLimit {
+					notices = append(notices, ev.Notice)
+				}
+			}
+			if len(notices) != 1 || notices[0] != tt.wantNotice {
+				t.Errorf("notices = %q, want [%q]", notices, tt.wantNotice)
+			}
+			if last := events[len(events)-1]; last.Type != EventDone {
+				t.Errorf("last event = %s, want done", last.Type)
+			}
+
+			// Every tool_use has a result, so the run can be continued
+			history := r.messageHistory
+			last := history[len(history)-1]
+			if last.Role != "user" || len(last.Content) != tt.callsPerTurn {
+				t.Errorf("last message = %+v, want %d tool results", last, tt.callsPerTurn)
+			}
+		})
+	}
+}
+
+func TestRunner_ResumesAfterFailedModelCall(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockCaller := NewMockLLMCaller(ctrl)
+	r := NewRunner(&mockLLMProvider{}, ".")
+	r.llmCaller = mockCaller
+
+	overloaded := errors.New("overloaded")
+	gomock.InOrder(
+		mockCaller.EXPECT().Stream(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(toolLoopStream(1), nil),
+		mockCaller.EXPECT().Stream(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(context.Context, []Message, map[string]tools.Tool, string) (*LLMStream, error) {
+				events := make(chan LLMEvent, 1)
+				done := make(chan error, 1)
+				events <- LLMEvent{Type: LLMContentDelta, BlockType: "text", Delta: "Half a repl"}
+				close(events)
+				done <- overloaded
+				return &LLMStream{Events: events, Done: done}, nil
+			}),
+	)
+	if _, err := collectStream(r.Stream(context.Background(), "fix the bug")); !errors.Is(err, overloaded) {
+		t.Fatalf("run error = %v, want %v", err, overloaded)
+	}
+
+	// The work done before the failure is kept, without the partial reply,
+	// so another model can pick it up without the task being sent again
+	next := NewRunner(&mockLLMProvider{}, ".")
+	next.llmCaller = mockCaller
+	if err := next.SetMessageHistory(r.GetMessageHistory()); err != nil {
+		t.Fatalf("SetMessageHistory: %v", err)
+	}
+	mockCaller.EXPECT().Stream(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, messages []Message, _ map[string]tools.Tool, _ string) (*LLMStream, error) {
+			roles := make([]string, len(messages))
+			for i, msg := range messages {
+				roles[i] = msg.Role
+			}
+			if got := strings.Join(roles, ","); got != "user,assistant,user" {
+				t.Errorf("resumed with roles %s, want user,assistant,user", got)
+			}
+			return makeLLMStream([]LLMEvent{
+				{Type: LLMContentStart, BlockType: "text"},
+				{Type: LLMContentDelta, BlockType: "text", Delta: "Done"},
+				{Type: LLMContentEnd, BlockType: "text"},
+				{Type: LLMMessageEnd},
+			}), nil
+		})
+	if _, err := collectStream(next.Stream(context.Background(), "")); err != nil {
+		t.Fatalf("resumed run: %v", err)
+	}
+	if got := next.finalMessage; got != "Done" {
+		t.Errorf("final message = %q, want Done", got)
+	}
+}
+
+func TestRunner_Steer(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockCaller := NewMockLLMCaller(ctrl)
+	r := NewRunner(&mockLLMProvider{}, ".")
+	r.llmCaller = mockCaller
+
+	if err := r.Steer("too early"); err != ErrNotRunning {
+		t.Errorf("Steer before the run = %v, want ErrNotRunning", err)
+	}
+
+	lastText := func(messages []Message) string {
+		last := messages[len(messages)-1]
+		if last.Role != "user" {
+			return ""
+		}
+		block := last.Content[len(last.Content)-1]
+		if block.Type != "text" {
+			return ""
+		}
+		return block.Text
+	}
+	gomock.InOrder(
+		// Steered while a tool call is pending: sent with its result
+		mockCaller.EXPECT().
+			Stream(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(context.Context, []Message, map[string]tools.Tool, string) (*LLMStream, error) {
+				if err := r.Steer("also update the tests"); err != nil {
+					t.Errorf("Steer during the run = %v", err)
+				}
+				return toolLoopStream(1), nil
+			}),
+		// Steered as the model finishes: the run goes on
+		mockCaller.EXPECT().
+			Stream(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, messages []Message, _ map[string]tools.Tool, _ string) (*LLMStream, error) {
+				last := messages[len(messages)-1]
+				if last.Content[0].Type != "tool_result" || lastText(messages) != "also update the tests" {
+					t.Errorf("last message = %+v, want the tool result and the steered message", last)
+				}
+				if err := r.Steer("and the docs"); err != nil {
+					t.Errorf("Steer during the run = %v", err)
+				}
+				return makeLLMStream([]LLMEvent{
+					{Type: LLMContentStart, BlockType: "text", Block: &ContentBlock{Type: "text"}},
+					{Type: LLMContentDelta, BlockType: "text", Delta: "done"},
+					{Type: LLMContentEnd, BlockType: "text"},
+					{Type: LLMMessageEnd},
+				}), nil
+			}),
+		mockCaller.EXPECT().
+			Stream(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, messages []Message, _ map[string]tools.Tool, _ string) (*LLMStream, error) {
+				if got := lastText(messages); got != "and the docs" {
+					t.Errorf("last user text = %q, want the steered message", got)
+				}
+				return makeLLMStream([]LLMEvent{{Type: LLMMessageEnd}}), nil
+			}),
+	)
+
+	events, err := collectStream(r.Stream(context.Background(), "fix the bug"))
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	var steered []string
+	for _, ev := range events {
+		if ev.Type == EventContentEnd && ev.BlockType == "text" && ev.Block != nil &&
+			(ev.Block.Text == "also update the tests" || ev.Block.Text == "and the docs") {
+			steered = append(steered, ev.Block.Text)
+		}
+	}
+	if len(steered) != 2 {
+		t.Errorf("steered message events = %q, want both", steered)
+	}
+	if err := r.Steer("too late"); err != ErrNotRunning {
+		t.Errorf("Steer after the run = %v, want ErrNotRunning", err)
+	}
+}
+
+func TestRunner_ProtectedPaths(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r := NewRunner(&mockLLMProvider{}, dir, WithRunnerProtectedPaths([]string{".env", "**/secrets/**"}))
+	write, _ := r.toolRegistry.Get("write")
+	edit, _ := r.toolRegistry.Get("edit")
+	multiedit, _ := r.toolRegistry.Get("multiedit")
+
+	for _, path := range []string{".env", "config/.env", "secrets/key", "app/secrets/key.pem", ".git/config", "sub/.git/HEAD", ".git", filepath.Join(dir, ".env")} {
+		if got := write.Func(map[string]any{"path": path, "content": "x"}); !strings.Contains(got, "path is protected") {
+			t.Errorf("write %s = %q, want protected", path, got)
+		}
+		if _, err := os.Stat(filepath.Join(dir, path)); err == nil && !filepath.IsAbs(path) {
+			t.Errorf("write %s created the file", path)
+		}
+	}
+	if got := edit.Func(map[string]any{"path": ".git/config", "old": "a", "new": "b"}); !strings.Contains(got, "path is protected") {
+		t.Errorf("edit = %q, want protected", got)
+	}
+	if got := multiedit.Func(map[string]any{"file_path": "secrets/key", "edits": []any{}}); !strings.Contains(got, "path is protected") {
+		t.Errorf("multiedit = %q, want protected", got)
+	}
+
+	// Everything else stays writable
+	for _, path := range []string{"main.go", ".env.example", "secrets.go", "docs/gitignore"} {
+		if got := write.Func(map[string]any{"path": path, "content": "x"}); got != "ok" {
+			t.Errorf("write %s = %q, want ok", path, got)
+		}
+	}
+}
+
+func TestRunner_ToolTimeout(t *testing.T) {
+	r := NewRunner(&mockLLMProvider{}, t.TempDir(), WithRunnerToolTimeout(300*time.Millisecond))
+	bash, _ := r.toolRegistry.Get("bash")
+
+	start := time.Now()
+	// The background child must die with the shell, or its open stdout
+	// would keep the tool waiting
+	got := bash.Func(map[string]any{"cmd": "(sleep 30) & echo started; wait"})
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("tool took %s, want it killed at the timeout", elapsed)
+	}
+	if !strings.HasPrefix(got, "error: command timed out after 300ms") {
+		t.Errorf("result = %q, want a timeout error", got)
+	}
+	if !strings.Contains(got, "started") {
+		t.Errorf("result = %q, want the partial output kept", got)
+	}
+
+	if got := bash.Func(map[string]any{"cmd": "echo quick"}); got != "quick\n" {
+		t.Errorf("result = %q, want quick commands unaffected", got)
+	}
+}
+
+func TestEditedPath(t *testing.T) {
+	cases := []struct {
+		name   string
+		args   map[string]any
+		result string
+		want   string
+	}{
+		{"write", map[string]any{"path": "a.go"}, "ok", "a.go"},
+		{"edit", map[string]any{"path": "b.go"}, "ok", "b.go"},
+		{"multiedit", map[string]any{"file_path": "c.go"}, "applied 2 edits", "c.go"},
+		{"edit", map[string]any{"path": "b.go"}, "error: old_string not found", ""},
+		{"read", map[string]any{"path": "a.go"}, "package a", ""},
+	}
+	for _, c := range cases {
+		if got := editedPath(c.name, c.args, c.result); got != c.want {
+			t.Errorf("editedPath(%s, %v, %q) = %q, want %q", c.name, c.args, c.result, got, c.want)
+		}
+	}
+}
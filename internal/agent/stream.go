@@ -21,6 +21,12 @@ const (
 	EventError        StreamEventType = "error"
 	EventDone         StreamEventType = "done"
 	EventSession      StreamEventType = "session"
+	// EventIterationLimit reports that the run was stopped by its iteration
+	// or tool call limit; Notice says which.
+	EventIterationLimit StreamEventType = "iteration_limit"
+	// EventFileEdit reports a successful file edit by a tool; Path is the
+	// file as the tool named it, relative to the work dir or absolute.
+	EventFileEdit StreamEventType = "file_edit"
 )
 
 // StreamEvent represents a single event in the agent execution.
@@ -34,4 +40,6 @@ type StreamEvent struct {
 	SessionID string           `json:"session_id,omitempty"`
 	Todos     []tools.TodoItem `json:"todos,omitempty"`
 	Error     string           `json:"error,omitempty"`
+	Notice    string           `json:"notice,omitempty"`
+	Path      string           `json:"path,omitempty"`
 }
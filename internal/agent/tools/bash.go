@@ -2,11 +2,19 @@ package tools
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"os"
 	"os/exec"
 	"strings"
+	"time"
 )
 
+// commandWaitDelay bounds how long a killed command's output pipes may stay
+// open, in case something escaped its process group.
+const commandWaitDelay = 2 * time.Second
+
 func (r *Registry) makeBashTool() Tool {
 	return Tool{
 		Description: "Run shell command",
@@ -16,8 +24,20 @@ func (r *Registry) makeBashTool() Tool {
 		Func: func(args map[string]any) string {
 			cmdStr := args["cmd"].(string)
 
-			cmd := exec.Command("bash", "-c", cmdStr)
+			ctx := context.Background()
+			if r.ctx.CommandTimeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, r.ctx.CommandTimeout)
+				defer cancel()
+			}
+
+			cmd := exec.CommandContext(ctx, "bash", "-c", cmdStr)
 			cmd.Dir = r.ctx.WorkDir
+			cmd.WaitDelay = commandWaitDelay
+			killProcessGroup(cmd)
+			if len(r.ctx.Env) > 0 {
+				cmd.Env = append(os.Environ(), r.ctx.Env...)
+			}
 
 			var stdout, stderr bytes.Buffer
 			cmd.Stdout = &stdout
@@ -26,6 +46,10 @@ func (r *Registry) makeBashTool() Tool {
 			err := cmd.Run()
 			output := stdout.String() + stderr.String()
 
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				// Keep what it printed, so the agent can tell how far it got
+				return fmt.Sprintf("error: command timed out after %s and was killed\n%s", r.ctx.CommandTimeout, output)
+			}
 			if err != nil {
 				output += fmt.Sprintf("\n(exit: %v)", err)
 			}
@@ -19,6 +19,9 @@ func (r *Registry) makeEditTool() Tool {
 			path := r.resolvePath(args["path"].(string))
 			oldStr := args["old"].(string)
 			newStr := args["new"].(string)
+			if err := r.checkWritable(path); err != nil {
+				return fmt.Sprintf("error: %v", err)
+			}
 
 			doAll := false
 			if a, ok := args["all"].(bool); ok {
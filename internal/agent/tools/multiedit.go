@@ -56,6 +56,9 @@ func (r *Registry) toolMultiedit(args map[string]any) string {
 		return "error: file_path is required"
 	}
 	filePath := r.resolvePath(filePathRaw.(string))
+	if err := r.checkWritable(filePath); err != nil {
+		return fmt.Sprintf("error: %v", err)
+	}
 
 	// Get edits array
 	editsRaw, ok := args["edits"]
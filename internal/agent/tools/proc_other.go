@@ -0,0 +1,9 @@
+//go:build !unix
+
+package tools
+
+import "os/exec"
+
+// killProcessGroup is a no-op where process groups aren't available;
+// cancelling cmd kills only the shell.
+func killProcessGroup(cmd *exec.Cmd) {}
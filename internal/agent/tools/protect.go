@@ -0,0 +1,66 @@
+package tools
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// alwaysProtected is refused whatever the configured patterns are: the
+// agent's work is committed by the orchestrator, not by rewriting git state.
+var alwaysProtected = []string{".git", "**/.git/**"}
+
+// checkWritable returns an error when path, as resolved by resolvePath,
+// matches a protected pattern. Patterns are slash-separated and relative
+// to the work directory; "**" matches any number of directories, and a
+// pattern without a slash matches the file name at any depth.
+func (r *Registry) checkWritable(path string) error {
+	rel, err := filepath.Rel(r.ctx.WorkDir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		// Outside the work dir: only the file name can match
+		rel = filepath.Base(path)
+	}
+	rel = filepath.ToSlash(rel)
+
+	patterns := append(append([]string(nil), alwaysProtected...), r.ctx.ProtectedPaths...)
+	for _, pattern := range patterns {
+		if matchProtected(pattern, rel) {
+			return fmt.Errorf("path is protected: %s", rel)
+		}
+	}
+	return nil
+}
+
+// matchProtected reports whether the slash-separated relative path matches
+// pattern.
+func matchProtected(pattern, rel string) bool {
+	pattern = strings.Trim(pattern, "/")
+	if pattern == "" {
+		return false
+	}
+	if !strings.Contains(pattern, "/") {
+		pattern = "**/" + pattern
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(rel, "/"))
+}
+
+func matchSegments(pattern, path []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(path); i++ {
+				if matchSegments(pattern[1:], path[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(path) == 0 {
+			return false
+		}
+		if ok, err := filepath.Match(pattern[0], path[0]); err != nil || !ok {
+			return false
+		}
+		pattern, path = pattern[1:], path[1:]
+	}
+	return len(path) == 0
+}
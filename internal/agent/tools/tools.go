@@ -4,6 +4,7 @@ package tools
 import (
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 // ToolDef is the schema for a single tool, sent to the LLM.
@@ -34,6 +35,13 @@ type Tool struct {
 // Context provides tools with access to runner state.
 type Context struct {
 	WorkDir string
+	// Env holds extra NAME=value pairs for commands run by the bash tool
+	Env []string
+	// ProtectedPaths holds patterns the write and edit tools refuse to
+	// modify, on top of .git which is always protected
+	ProtectedPaths []string
+	// CommandTimeout, when > 0, kills a bash tool command running longer
+	CommandTimeout time.Duration
 	// TodoState is set by the runner for the todo tool
 	TodoState *TodoState
 	// TodoEvents receives the latest todo list when it changes.
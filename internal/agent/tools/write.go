@@ -16,6 +16,9 @@ func (r *Registry) makeWriteTool() Tool {
 		Func: func(args map[string]any) string {
 			path := r.resolvePath(args["path"].(string))
 			content := args["content"].(string)
+			if err := r.checkWritable(path); err != nil {
+				return fmt.Sprintf("error: %v", err)
+			}
 
 			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
 				return fmt.Sprintf("error: %v", err)
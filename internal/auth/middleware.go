@@ -11,6 +11,9 @@ type contextKey string
 const (
 	// UserIDKey is context key for user ID.
 	UserIDKey contextKey = "user_id"
+	// APITokenIDKey is context key for the ID of the API token a request
+	// authenticated with; absent for the machine's own login.
+	APITokenIDKey contextKey = "api_token_id"
 )
 
 // Middleware provides authentication middleware.
@@ -47,6 +50,13 @@ func UserIDFromContext(ctx context.Context) string {
 	return userID
 }
 
+// APITokenIDFromContext returns the ID of the API token the request
+// authenticated with, or "" when it used the machine's own login.
+func APITokenIDFromContext(ctx context.Context) string {
+	tokenID, _ := ctx.Value(APITokenIDKey).(string)
+	return tokenID
+}
+
 // MustUserID extracts user ID from context, panics if not present.
 func MustUserID(ctx context.Context) string {
 	userID := UserIDFromContext(ctx)
@@ -15,23 +15,99 @@ type Config struct {
 	// Database
 	DatabasePath string
 
+	// Logging: default level, plus "MODULE=level" overrides for messages
+	// prefixed "[MODULE]" (see logging.New)
+	LogLevel        string
+	LogModuleLevels []string
+
 	// Native backend allowlist (comma-separated commands)
 	NativeAllowlist []string
 
 	// Worker pool configuration
 	WorkerPoolSize  int
 	MaxTasksPerUser int
+	// Tasks each user may create per TaskRateWindow, refilled gradually
+	// (0 = no limit)
+	TaskRateLimit  int
+	TaskRateWindow time.Duration
+	// Goroutines recording finished runs; a task's results always go to the
+	// same one, so they apply in order
+	ResultProcessors int
 
 	// Sandbox configuration
 	SandboxTimeout     time.Duration
 	SandboxOutputLimit int64
 
+	// Persisted agent messages and diffs are truncated past these sizes in
+	// bytes, with the full versions kept under OutputsPath (0 = no limit)
+	MaxMessageBytes int
+	MaxDiffBytes    int
+	// Raw CLI backend logs stop growing past this size in bytes, per run
+	// (0 = no limit)
+	RawLogMaxBytes int64
+	// Diffs changing more lines than this are summarized instead of sent
+	// for inline review, which points to a pull request (0 = no limit)
+	DiffInlineMaxLines int
+
+	// LLM provider timeouts (native backend): wait for response headers, and
+	// max silence mid-stream before the run fails. 0 disables.
+	LLMRequestTimeout    time.Duration
+	LLMStreamIdleTimeout time.Duration
+	// Longest a single agent shell command may run before it is killed and
+	// reported to the agent as a tool error. 0 disables.
+	ToolTimeout time.Duration
+
+	// How often idle SSE streams send something to keep proxies from closing
+	// them: a ": keepalive" comment, or with SSEHeartbeatEvents a heartbeat
+	// event clients can watch for to detect a dead connection
+	SSEKeepaliveInterval time.Duration
+	SSEHeartbeatEvents   bool
+
 	// Data directories (for repos and workspaces)
 	DataDir string
 
+	// Workspace isolation: "worktree" (default), "clone" or "container".
+	// See services.IsolationMode for the tradeoffs of each mode.
+	IsolationMode string
+	// Image used to run CLI backends when IsolationMode is "container"
+	ContainerImage string
+	// Refuse per-task clones of repositories larger than this (0 = no limit)
+	MaxRepoSizeMB int64
+	// Private key for git fetch/push over SSH remotes (empty = ssh defaults)
+	GitSSHKeyPath string
+	// Key that signs commits of projects with signing enabled, and its git
+	// gpg.format: ssh (default), openpgp or x509
+	GitSigningKey    string
+	GitSigningFormat string
+	// Path patterns the native backend's write/edit tools refuse to modify
+	// (.git is always protected)
+	ProtectedPaths []string
+	// Safety preamble put ahead of every agent run's system prompt, which
+	// users can't override; AGENT_PROMPT_PREFIX_FILE takes precedence
+	AgentPromptPrefix     string
+	AgentPromptPrefixFile string
+	// Remove workspaces of finished tasks idle for this many days (0 = keep forever)
+	WorkspaceRetentionDays int
+	// Refuse to start, and report unhealthy, below this much free disk (0 = no check)
+	MinFreeDiskMB int64
+
 	// GitHub OAuth
 	GitHubClientID     string
 	GitHubClientSecret string
+	// GitHub Enterprise host (default github.com) and optional API base URL
+	// override (default https://<host>/api/v3, or api.github.com)
+	GitHubHost   string
+	GitHubAPIURL string
+	// Secret of the repository webhook sending pull_request events to
+	// /api/v1/github/webhook (empty = endpoint disabled)
+	GitHubWebhookSecret string
+	// Signing secret of the Slack app whose /counterspell slash command posts
+	// to /integrations/slack/command (empty = endpoint disabled)
+	SlackSigningSecret string
+	// Poll watched projects for issues with IssueLabel and turn them into
+	// tasks every IssuePollInterval (0 = disabled)
+	IssuePollInterval time.Duration
+	IssueLabel        string
 
 	// OAuth callback configuration
 	OAuthCallbackPort string
@@ -52,6 +128,10 @@ func Load() *Config {
 		// Database
 		DatabasePath: getEnvString("DATABASE_PATH", "./data/counterspell.db"),
 
+		// Logging
+		LogLevel:        getEnvString("LOG_LEVEL", "info"),
+		LogModuleLevels: getEnvStringSlice("LOG_MODULE_LEVELS", nil),
+
 		// Native allowlist
 		NativeAllowlist: getEnvStringSlice("NATIVE_ALLOWLIST", []string{
 			"git", "ls", "cat", "head", "tail", "grep", "find", "wc", "sort", "uniq",
@@ -60,17 +140,65 @@ func Load() *Config {
 		// Worker pool
 		WorkerPoolSize:  getEnvInt("WORKER_POOL_SIZE", 20),
 		MaxTasksPerUser: getEnvInt("MAX_TASKS_PER_USER", 5),
+		TaskRateLimit:   getEnvInt("TASK_RATE_LIMIT", 20),
+		TaskRateWindow:  getEnvDuration("TASK_RATE_WINDOW", time.Hour),
+
+		ResultProcessors: getEnvInt("RESULT_PROCESSORS", 1),
 
 		// Sandbox
 		SandboxTimeout:     getEnvDuration("SANDBOX_TIMEOUT", 10*time.Minute),
 		SandboxOutputLimit: getEnvInt64("SANDBOX_OUTPUT_LIMIT", 1048576), // 1MB
 
+		// SSE keepalive
+		SSEKeepaliveInterval: getEnvDuration("SSE_KEEPALIVE_INTERVAL", 10*time.Second),
+		SSEHeartbeatEvents:   getEnvBool("SSE_HEARTBEAT_EVENTS", false),
+
+		// Output caps
+		MaxMessageBytes: getEnvInt("MAX_MESSAGE_BYTES", 256<<10),
+		MaxDiffBytes:    getEnvInt("MAX_DIFF_BYTES", 2<<20),
+		RawLogMaxBytes:  getEnvInt64("RAW_LOG_MAX_BYTES", 20<<20),
+
+		DiffInlineMaxLines: getEnvInt("DIFF_INLINE_MAX_LINES", 20000),
+
+		// LLM timeouts
+		LLMRequestTimeout:    getEnvDuration("LLM_REQUEST_TIMEOUT", 60*time.Second),
+		LLMStreamIdleTimeout: getEnvDuration("LLM_STREAM_IDLE_TIMEOUT", 60*time.Second),
+		ToolTimeout:          getEnvDuration("TOOL_TIMEOUT", 10*time.Minute),
+
 		// Data directory
 		DataDir: getEnvString("DATA_DIR", "./data"),
 
+		// Isolation
+		IsolationMode:  getEnvString("ISOLATION_MODE", "worktree"),
+		ContainerImage: os.Getenv("CONTAINER_IMAGE"),
+		MaxRepoSizeMB:  getEnvInt64("MAX_REPO_SIZE_MB", 0),
+		GitSSHKeyPath:  os.Getenv("GIT_SSH_KEY"),
+
+		GitSigningKey:    os.Getenv("GIT_SIGNING_KEY"),
+		GitSigningFormat: getEnvString("GIT_SIGNING_FORMAT", "ssh"),
+
+		ProtectedPaths:        getEnvStringSlice("PROTECTED_PATHS", []string{".env", "**/secrets/**"}),
+		AgentPromptPrefix:     os.Getenv("AGENT_PROMPT_PREFIX"),
+		AgentPromptPrefixFile: os.Getenv("AGENT_PROMPT_PREFIX_FILE"),
+
+		WorkspaceRetentionDays: getEnvInt("WORKSPACE_RETENTION_DAYS", 0),
+		MinFreeDiskMB:          getEnvInt64("MIN_FREE_DISK_MB", 512),
+
 		// GitHub OAuth
 		GitHubClientID:     os.Getenv("GITHUB_CLIENT_ID"),
 		GitHubClientSecret: os.Getenv("GITHUB_CLIENT_SECRET"),
+		GitHubHost:         getEnvString("GITHUB_HOST", "github.com"),
+		GitHubAPIURL:       os.Getenv("GITHUB_API_URL"),
+
+		// GitHub webhook
+		GitHubWebhookSecret: os.Getenv("GITHUB_WEBHOOK_SECRET"),
+
+		// Slack slash command
+		SlackSigningSecret: os.Getenv("SLACK_SIGNING_SECRET"),
+
+		// Issue polling
+		IssuePollInterval: getEnvDuration("ISSUE_POLL_INTERVAL", 5*time.Minute),
+		IssueLabel:        getEnvString("ISSUE_LABEL", "agent"),
 
 		// OAuth callback
 		OAuthCallbackPort: getEnvString("OAUTH_CALLBACK_PORT", "8711"),
@@ -99,9 +227,45 @@ func (c *Config) Validate() error {
 	if c.DatabasePath == "" {
 		return &ConfigError{Field: "DATABASE_PATH", Message: "required"}
 	}
+	switch c.IsolationMode {
+	case "worktree", "clone":
+	case "container":
+		if c.ContainerImage == "" {
+			return &ConfigError{Field: "CONTAINER_IMAGE", Message: "required when ISOLATION_MODE=container"}
+		}
+	default:
+		return &ConfigError{Field: "ISOLATION_MODE", Message: "must be worktree, clone or container"}
+	}
+	if c.ResultProcessors < 1 {
+		return &ConfigError{Field: "RESULT_PROCESSORS", Message: "must be at least 1"}
+	}
+	if c.SSEKeepaliveInterval < time.Second {
+		return &ConfigError{Field: "SSE_KEEPALIVE_INTERVAL", Message: "must be at least 1s"}
+	}
+	if _, err := c.PromptPrefix(); err != nil {
+		return &ConfigError{Field: "AGENT_PROMPT_PREFIX_FILE", Message: err.Error()}
+	}
 	return nil
 }
 
+// PromptPrefix returns the safety preamble for agent runs: the contents of
+// AgentPromptPrefixFile when set, else AgentPromptPrefix.
+func (c *Config) PromptPrefix() (string, error) {
+	if c.AgentPromptPrefixFile == "" {
+		return strings.TrimSpace(c.AgentPromptPrefix), nil
+	}
+	data, err := os.ReadFile(c.AgentPromptPrefixFile)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// WorkspaceRetention returns WorkspaceRetentionDays as a duration.
+func (c *Config) WorkspaceRetention() time.Duration {
+	return time.Duration(c.WorkspaceRetentionDays) * 24 * time.Hour
+}
+
 // ConfigError represents a configuration error.
 type ConfigError struct {
 	Field   string
@@ -199,6 +363,17 @@ func (c *Config) ReposPath() string {
 	return c.DataDir + "/repos"
 }
 
+// RawLogsPath returns the directory holding raw CLI backend output.
+func (c *Config) RawLogsPath() string {
+	return c.DataDir + "/raw-logs"
+}
+
+// OutputsPath returns the directory holding full versions of truncated agent
+// messages and diffs.
+func (c *Config) OutputsPath() string {
+	return c.DataDir + "/outputs"
+}
+
 // WorkspacesPath returns the workspaces directory for a user.
 func (c *Config) WorkspacesPath(machineID string) string {
 	return c.DataDir + "/workspaces/" + machineID
@@ -12,11 +12,17 @@ import (
 //
 //	data/
 //	├── repos/        # Shared bare git repos
-//	└── workspaces/   # User-isolated worktrees
+//	├── worktrees/    # Per-task worktrees and clones
+//	├── workspaces/   # User-isolated worktrees
+//	├── raw-logs/     # Raw CLI backend output per run
+//	└── outputs/      # Full versions of truncated messages and diffs
 func EnsureDirectories(cfg *Config) error {
 	dirs := []string{
 		filepath.Join(cfg.DataDir, "repos"),
+		filepath.Join(cfg.DataDir, "worktrees"),
 		filepath.Join(cfg.DataDir, "workspaces"),
+		cfg.RawLogsPath(),
+		cfg.OutputsPath(),
 	}
 
 	for _, dir := range dirs {
@@ -30,6 +36,67 @@ func EnsureDirectories(cfg *Config) error {
 	return nil
 }
 
+// DataDirStatus reports whether the data directory can hold new workspaces.
+type DataDirStatus struct {
+	Path       string `json:"path"`
+	FreeBytes  uint64 `json:"free_bytes"`
+	TotalBytes uint64 `json:"total_bytes"`
+	MinFree    uint64 `json:"min_free_bytes"`
+	Error      string `json:"error,omitempty"`
+}
+
+// OK reports whether the data dir is writable and has at least MinFree bytes.
+func (s DataDirStatus) OK() bool {
+	return s.Error == ""
+}
+
+// CheckDataDir verifies that the data dir and its repos/worktrees subdirs are
+// writable and that the filesystem has at least MinFreeDiskMB free. Call it
+// after EnsureDirectories so the subdirs exist.
+func CheckDataDir(cfg *Config) DataDirStatus {
+	status := DataDirStatus{Path: cfg.DataDir, MinFree: uint64(cfg.MinFreeDiskMB) << 20}
+	if abs, err := filepath.Abs(cfg.DataDir); err == nil {
+		status.Path = abs
+	}
+
+	for _, dir := range []string{
+		cfg.DataDir,
+		filepath.Join(cfg.DataDir, "repos"),
+		filepath.Join(cfg.DataDir, "worktrees"),
+	} {
+		if err := checkWritable(dir); err != nil {
+			status.Error = fmt.Sprintf("%s is not writable: %v", dir, err)
+			return status
+		}
+	}
+
+	free, total, err := DiskSpace(cfg.DataDir)
+	if err != nil {
+		// Unknown free space isn't fatal; writability was confirmed above
+		slog.Warn("Failed to check free disk space", "path", cfg.DataDir, "error", err)
+		return status
+	}
+	status.FreeBytes, status.TotalBytes = free, total
+	if free < status.MinFree {
+		status.Error = fmt.Sprintf("only %d MB free on %s (MIN_FREE_DISK_MB=%d)", free>>20, status.Path, cfg.MinFreeDiskMB)
+	}
+	return status
+}
+
+// checkWritable creates and removes a temporary file in dir.
+func checkWritable(dir string) error {
+	f, err := os.CreateTemp(dir, ".write-check-*")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	if err := f.Close(); err != nil {
+		_ = os.Remove(name)
+		return err
+	}
+	return os.Remove(name)
+}
+
 // EnsureUserWorkspace creates the workspace directory for a specific user.
 func EnsureUserWorkspace(cfg *Config, userID string) error {
 	workspacePath := cfg.WorkspacesPath(userID)
@@ -0,0 +1,31 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckDataDir(t *testing.T) {
+	cfg := &Config{DataDir: t.TempDir()}
+	require.NoError(t, EnsureDirectories(cfg))
+
+	status := CheckDataDir(cfg)
+	require.True(t, status.OK(), status.Error)
+	assert.Positive(t, status.FreeBytes)
+
+	// No filesystem has an exabyte free
+	cfg.MinFreeDiskMB = 1 << 40
+	status = CheckDataDir(cfg)
+	assert.False(t, status.OK())
+	assert.Contains(t, status.Error, "MIN_FREE_DISK_MB")
+}
+
+func TestCheckDataDir_MissingSubdir(t *testing.T) {
+	cfg := &Config{DataDir: t.TempDir()}
+
+	status := CheckDataDir(cfg)
+	assert.False(t, status.OK())
+	assert.Contains(t, status.Error, "repos")
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package config
+
+import "errors"
+
+// DiskSpace is not implemented on this platform.
+func DiskSpace(path string) (free, total uint64, err error) {
+	return 0, 0, errors.New("disk space check not supported on this platform")
+}
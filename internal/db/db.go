@@ -65,11 +65,65 @@ func (db *DB) RunMigrations(ctx context.Context) error {
 		return fmt.Errorf("failed to execute schema: %w", err)
 	}
 
+	if err := db.ensureColumns(ctx); err != nil {
+		return err
+	}
+
 	slog.Info("Database schema initialized")
 
 	return nil
 }
 
+// addedColumns lists columns added to existing tables after their first
+// release. CREATE TABLE IF NOT EXISTS doesn't alter tables created by an older
+// schema, so these are added explicitly when missing.
+var addedColumns = []struct {
+	table, column, definition string
+}{
+	{"tasks", "version", "INTEGER NOT NULL DEFAULT 0"},
+	{"settings", "fallback_models", "TEXT"},
+	{"tasks", "pr_url", "TEXT"},
+	{"settings", "project_env", "TEXT"},
+	{"messages", "artifact_path", "TEXT"},
+	{"task_diffs", "artifact_path", "TEXT"},
+	{"settings", "summary_model", "TEXT"},
+	{"agent_runs", "system_prompt", "TEXT"},
+	{"tasks", "base_branch", "TEXT"},
+	{"settings", "auto_resolve_conflicts", "INTEGER NOT NULL DEFAULT 0"},
+	{"settings", "max_iterations", "INTEGER NOT NULL DEFAULT 0"},
+	{"settings", "max_tool_calls", "INTEGER NOT NULL DEFAULT 0"},
+	{"agent_runs", "limit_reached", "TEXT"},
+	{"settings", "commit_author_name", "TEXT"},
+	{"settings", "commit_author_email", "TEXT"},
+	{"settings", "show_thinking", "INTEGER NOT NULL DEFAULT 0"},
+	{"tasks", "no_changes", "INTEGER NOT NULL DEFAULT 0"},
+	{"tasks", "pr_state", "TEXT"},
+	{"repositories", "language", "TEXT NOT NULL DEFAULT ''"},
+	{"repositories", "size_kb", "INTEGER NOT NULL DEFAULT 0"},
+	{"task_diffs", "stat", "TEXT"},
+}
+
+// ensureColumns adds any addedColumns missing from an existing database.
+func (db *DB) ensureColumns(ctx context.Context) error {
+	for _, c := range addedColumns {
+		var count int
+		if err := db.DB.QueryRowContext(ctx,
+			"SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", c.table, c.column,
+		).Scan(&count); err != nil {
+			return fmt.Errorf("failed to inspect %s: %w", c.table, err)
+		}
+		if count > 0 {
+			continue
+		}
+		stmt := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", c.table, c.column, c.definition)
+		if _, err := db.DB.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to add %s.%s: %w", c.table, c.column, err)
+		}
+		slog.Info("Added column", "table", c.table, "column", c.column)
+	}
+	return nil
+}
+
 // Close closes database connection.
 func (db *DB) Close() {
 	if err := db.DB.Close(); err != nil {
@@ -5,6 +5,15 @@ VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);
 -- name: UpdateAgentRunCompleted :exec
 UPDATE agent_runs SET completed_at = ? WHERE id = ?;
 
+-- name: UpdateAgentRunModel :exec
+UPDATE agent_runs SET provider = ?, model = ? WHERE id = ?;
+
+-- name: UpdateAgentRunSystemPrompt :exec
+UPDATE agent_runs SET system_prompt = ? WHERE id = ?;
+
+-- name: UpdateAgentRunLimitReached :exec
+UPDATE agent_runs SET limit_reached = ? WHERE id = ?;
+
 -- name: UpdateAgentRunBackendSessionID :exec
 UPDATE agent_runs SET backend_session_id = ? WHERE id = ?;
 
@@ -24,3 +33,29 @@ LIMIT 1;
 
 -- name: DeleteAgentRunsByTask :exec
 DELETE FROM agent_runs WHERE task_id = ?;
+
+-- name: SumAgentRunCostByProject :many
+SELECT COALESCE(t.repository_id, '') AS repository_id,
+       COALESCE(r.full_name, '') AS project,
+       COUNT(ar.id) AS run_count,
+       CAST(COALESCE(SUM(ar.cost), 0) AS REAL) AS cost,
+       CAST(COALESCE(SUM(ar.prompt_tokens), 0) AS INTEGER) AS prompt_tokens,
+       CAST(COALESCE(SUM(ar.completion_tokens), 0) AS INTEGER) AS completion_tokens
+FROM agent_runs ar
+JOIN tasks t ON t.id = ar.task_id
+LEFT JOIN repositories r ON r.id = t.repository_id
+WHERE ar.created_at >= ?
+GROUP BY t.repository_id
+ORDER BY cost DESC, project ASC;
+
+-- name: SumAgentRunCostByModel :many
+SELECT COALESCE(ar.provider, '') AS provider,
+       COALESCE(ar.model, '') AS model,
+       COUNT(ar.id) AS run_count,
+       CAST(COALESCE(SUM(ar.cost), 0) AS REAL) AS cost,
+       CAST(COALESCE(SUM(ar.prompt_tokens), 0) AS INTEGER) AS prompt_tokens,
+       CAST(COALESCE(SUM(ar.completion_tokens), 0) AS INTEGER) AS completion_tokens
+FROM agent_runs ar
+WHERE ar.created_at >= ?
+GROUP BY ar.provider, ar.model
+ORDER BY cost DESC, provider ASC, model ASC;
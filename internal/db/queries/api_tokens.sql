@@ -0,0 +1,17 @@
+-- name: CreateAPIToken :exec
+INSERT INTO api_tokens (id, user_id, name, token_hash, created_at)
+VALUES (?, ?, ?, ?, ?);
+
+-- name: GetAPITokenByHash :one
+SELECT id, user_id, name, token_hash, last_used_at, created_at FROM api_tokens WHERE token_hash = ?;
+
+-- name: ListAPITokens :many
+SELECT id, user_id, name, token_hash, last_used_at, created_at FROM api_tokens
+WHERE user_id = ?
+ORDER BY created_at DESC;
+
+-- name: TouchAPIToken :exec
+UPDATE api_tokens SET last_used_at = ? WHERE id = ?;
+
+-- name: DeleteAPIToken :execrows
+DELETE FROM api_tokens WHERE id = ? AND user_id = ?;
@@ -16,3 +16,13 @@ ORDER BY a.created_at ASC;
 
 -- name: DeleteArtifactsByRun :exec
 DELETE FROM artifacts WHERE run_id = ?;
+
+-- name: GetTaskArtifact :one
+SELECT a.* FROM artifacts a
+JOIN agent_runs ar ON a.run_id = ar.id
+WHERE a.id = ? AND ar.task_id = ?;
+
+-- name: GetLatestArtifactVersion :one
+SELECT CAST(COALESCE(MAX(a.version), 0) AS INTEGER) FROM artifacts a
+JOIN agent_runs ar ON a.run_id = ar.id
+WHERE ar.task_id = ? AND a.path = ?;
@@ -0,0 +1,13 @@
+-- name: EnableAutoMerge :exec
+INSERT INTO auto_merge_repositories (repository_id, created_at)
+VALUES (?, ?)
+ON CONFLICT(repository_id) DO NOTHING;
+
+-- name: DisableAutoMerge :exec
+DELETE FROM auto_merge_repositories WHERE repository_id = ?;
+
+-- name: CountAutoMergeRepository :one
+SELECT COUNT(*) FROM auto_merge_repositories WHERE repository_id = ?;
+
+-- name: ListAutoMergeRepositoryIDs :many
+SELECT repository_id FROM auto_merge_repositories ORDER BY created_at ASC;
@@ -0,0 +1,13 @@
+-- name: EnableCommitSigning :exec
+INSERT INTO commit_signing_repositories (repository_id, created_at)
+VALUES (?, ?)
+ON CONFLICT(repository_id) DO NOTHING;
+
+-- name: DisableCommitSigning :exec
+DELETE FROM commit_signing_repositories WHERE repository_id = ?;
+
+-- name: CountCommitSigningRepository :one
+SELECT COUNT(*) FROM commit_signing_repositories WHERE repository_id = ?;
+
+-- name: ListCommitSigningRepositoryIDs :many
+SELECT repository_id FROM commit_signing_repositories ORDER BY created_at ASC;
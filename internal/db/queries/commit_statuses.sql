@@ -0,0 +1,13 @@
+-- name: EnableCommitStatuses :exec
+INSERT INTO commit_status_repositories (repository_id, created_at)
+VALUES (?, ?)
+ON CONFLICT(repository_id) DO NOTHING;
+
+-- name: DisableCommitStatuses :exec
+DELETE FROM commit_status_repositories WHERE repository_id = ?;
+
+-- name: CountCommitStatusRepository :one
+SELECT COUNT(*) FROM commit_status_repositories WHERE repository_id = ?;
+
+-- name: ListCommitStatusRepositoryIDs :many
+SELECT repository_id FROM commit_status_repositories ORDER BY created_at ASC;
@@ -0,0 +1,29 @@
+-- name: UpsertTaskDiff :exec
+INSERT INTO task_diffs (task_id, diff, artifact_path, stat, updated_at)
+VALUES (?, ?, ?, ?, ?)
+ON CONFLICT(task_id) DO UPDATE SET
+    diff = excluded.diff,
+    artifact_path = excluded.artifact_path,
+    stat = excluded.stat,
+    updated_at = excluded.updated_at;
+
+-- name: GetTaskDiff :one
+SELECT task_id, diff, updated_at, artifact_path, stat FROM task_diffs WHERE task_id = ?;
+
+-- name: GetTaskDiffStat :one
+SELECT stat FROM task_diffs WHERE task_id = ?;
+
+-- name: SetTaskDiffStat :exec
+UPDATE task_diffs SET stat = ? WHERE task_id = ?;
+
+-- name: ListTasksWithStaleWorkspace :many
+SELECT t.id, t.status
+FROM tasks t
+JOIN task_diffs d ON d.task_id = t.id
+WHERE t.status IN ('review', 'done', 'failed') AND d.updated_at < ?
+ORDER BY d.updated_at ASC;
+
+-- name: RelocateTaskDiffArtifacts :execrows
+UPDATE task_diffs
+SET artifact_path = sqlc.arg(new_prefix) || substr(artifact_path, length(sqlc.arg(old_prefix)) + 1)
+WHERE substr(artifact_path, 1, length(sqlc.arg(old_prefix))) = sqlc.arg(old_prefix);
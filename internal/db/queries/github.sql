@@ -23,6 +23,11 @@ DELETE FROM github_connections WHERE id = ?;
 -- name: ListRepositories :many
 SELECT * FROM repositories WHERE connection_id = ? ORDER BY full_name ASC;
 
+-- name: FindRepositoriesByName :many
+SELECT * FROM repositories
+WHERE lower(full_name) = lower(sqlc.arg(name)) OR lower(name) = lower(sqlc.arg(name))
+ORDER BY full_name ASC;
+
 -- name: GetRepository :one
 SELECT * FROM repositories WHERE id = ?;
 
@@ -35,9 +40,9 @@ INSERT INTO repositories (
 
 -- name: UpsertRepository :one
 INSERT INTO repositories (
-    id, connection_id, name, full_name, owner, is_private, html_url, clone_url, local_path, created_at, updated_at
+    id, connection_id, name, full_name, owner, is_private, html_url, clone_url, local_path, created_at, updated_at, language, size_kb
 ) VALUES (
-    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
 )
 ON CONFLICT(connection_id, full_name) DO UPDATE SET
     name = excluded.name,
@@ -45,8 +50,13 @@ ON CONFLICT(connection_id, full_name) DO UPDATE SET
     html_url = excluded.html_url,
     clone_url = excluded.clone_url,
     local_path = excluded.local_path,
-    updated_at = excluded.updated_at
+    updated_at = excluded.updated_at,
+    language = excluded.language,
+    size_kb = excluded.size_kb
 RETURNING *;
 
+-- name: DeleteRepository :exec
+DELETE FROM repositories WHERE id = ?;
+
 -- name: DeleteRepositoriesByConnection :exec
 DELETE FROM repositories WHERE connection_id = ?;
@@ -0,0 +1,37 @@
+-- name: EnableIssueWatch :exec
+INSERT INTO issue_watches (repository_id, created_at)
+VALUES (?, ?)
+ON CONFLICT(repository_id) DO NOTHING;
+
+-- name: DisableIssueWatch :exec
+DELETE FROM issue_watches WHERE repository_id = ?;
+
+-- name: ListWatchedRepositories :many
+SELECT r.id, r.owner, r.name
+FROM repositories r
+JOIN issue_watches w ON w.repository_id = r.id
+ORDER BY w.created_at ASC;
+
+-- name: GetIssueTaskID :one
+SELECT task_id FROM issue_tasks WHERE repository_id = ? AND issue_number = ?;
+
+-- name: ClaimIssueTask :execrows
+INSERT INTO issue_tasks (repository_id, issue_number, task_id, created_at)
+VALUES (?, ?, '', ?)
+ON CONFLICT(repository_id, issue_number) DO NOTHING;
+
+-- name: SetIssueTaskID :exec
+UPDATE issue_tasks SET task_id = ? WHERE repository_id = ? AND issue_number = ?;
+
+-- name: ReleaseIssueTask :exec
+DELETE FROM issue_tasks WHERE repository_id = ? AND issue_number = ? AND task_id = '';
+
+-- name: ListIssueTasksAwaitingComment :many
+SELECT i.repository_id, i.issue_number, i.task_id, t.pr_url, r.owner, r.name
+FROM issue_tasks i
+JOIN tasks t ON t.id = i.task_id
+JOIN repositories r ON r.id = i.repository_id
+WHERE i.commented_at IS NULL AND t.pr_url IS NOT NULL AND t.pr_url != '';
+
+-- name: MarkIssueTaskCommented :exec
+UPDATE issue_tasks SET commented_at = ? WHERE repository_id = ? AND issue_number = ?;
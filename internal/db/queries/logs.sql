@@ -0,0 +1,7 @@
+-- name: CreateTaskLog :one
+INSERT INTO task_logs (task_id, level, message, created_at)
+VALUES (?, ?, ?, ?)
+RETURNING *;
+
+-- name: ListTaskLogs :many
+SELECT * FROM task_logs WHERE task_id = ? ORDER BY id ASC;
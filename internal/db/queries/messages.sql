@@ -1,6 +1,6 @@
 -- name: CreateMessage :exec
-INSERT INTO messages (id, task_id, run_id, role, content, parts, model, provider, created_at, updated_at)
-VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
+INSERT INTO messages (id, task_id, run_id, role, content, parts, model, provider, artifact_path, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
 
 -- name: GetMessage :one
 SELECT * FROM messages WHERE id = ?;
@@ -16,3 +16,8 @@ SELECT * FROM messages WHERE task_id = ? ORDER BY created_at DESC LIMIT ?;
 
 -- name: DeleteMessagesByTask :exec
 DELETE FROM messages WHERE task_id = ?;
+
+-- name: RelocateMessageArtifacts :execrows
+UPDATE messages
+SET artifact_path = sqlc.arg(new_prefix) || substr(artifact_path, length(sqlc.arg(old_prefix)) + 1)
+WHERE substr(artifact_path, 1, length(sqlc.arg(old_prefix))) = sqlc.arg(old_prefix);
@@ -3,6 +3,15 @@ SELECT openrouter_key, zai_key, anthropic_key, openai_key,
        COALESCE(agent_backend, 'native') as agent_backend,
        COALESCE(provider, 'anthropic') as provider,
        COALESCE(model, 'claude-opus-4-5') as model,
+       fallback_models,
+       project_env,
+       summary_model,
+       auto_resolve_conflicts,
+       max_iterations,
+       max_tool_calls,
+       commit_author_name,
+       commit_author_email,
+       show_thinking,
        updated_at
 FROM settings WHERE id = 1;
 
@@ -16,6 +25,15 @@ INSERT INTO settings (
     agent_backend,
     provider,
     model,
+    fallback_models,
+    project_env,
+    summary_model,
+    auto_resolve_conflicts,
+    max_iterations,
+    max_tool_calls,
+    commit_author_name,
+    commit_author_email,
+    show_thinking,
     updated_at
 ) VALUES (
     1,
@@ -26,6 +44,15 @@ INSERT INTO settings (
     ?,
     ?,
     ?,
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
     ?
 )
 ON CONFLICT(id) DO UPDATE SET
@@ -36,4 +63,13 @@ ON CONFLICT(id) DO UPDATE SET
     agent_backend = excluded.agent_backend,
     provider = excluded.provider,
     model = excluded.model,
+    fallback_models = excluded.fallback_models,
+    project_env = excluded.project_env,
+    summary_model = excluded.summary_model,
+    auto_resolve_conflicts = excluded.auto_resolve_conflicts,
+    max_iterations = excluded.max_iterations,
+    max_tool_calls = excluded.max_tool_calls,
+    commit_author_name = excluded.commit_author_name,
+    commit_author_email = excluded.commit_author_email,
+    show_thinking = excluded.show_thinking,
     updated_at = excluded.updated_at;
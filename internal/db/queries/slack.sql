@@ -0,0 +1,26 @@
+-- name: LinkSlackUser :exec
+INSERT INTO slack_users (team_id, user_id, user_name, created_at)
+VALUES (?, ?, ?, ?)
+ON CONFLICT(team_id, user_id) DO UPDATE SET user_name = excluded.user_name;
+
+-- name: UnlinkSlackUser :execrows
+DELETE FROM slack_users WHERE team_id = ? AND user_id = ?;
+
+-- name: GetSlackUser :one
+SELECT * FROM slack_users WHERE team_id = ? AND user_id = ?;
+
+-- name: CreateSlackTask :exec
+INSERT INTO slack_tasks (task_id, response_url, notified_status, created_at)
+VALUES (?, ?, ?, ?);
+
+-- name: ListSlackTaskUpdates :many
+SELECT s.task_id, s.response_url, t.title, t.status, t.pr_url
+FROM slack_tasks s
+JOIN tasks t ON t.id = s.task_id
+WHERE t.status != s.notified_status AND t.status IN ('review', 'done', 'failed');
+
+-- name: MarkSlackTaskNotified :exec
+UPDATE slack_tasks SET notified_status = ? WHERE task_id = ?;
+
+-- name: DeleteSlackTask :exec
+DELETE FROM slack_tasks WHERE task_id = ?;
@@ -0,0 +1,14 @@
+-- name: CreateTaskShare :exec
+INSERT INTO task_shares (id, task_id, token_hash, expires_at, created_at)
+VALUES (?, ?, ?, ?, ?);
+
+-- name: GetTaskShareByTokenHash :one
+SELECT id, task_id, token_hash, expires_at, created_at FROM task_shares WHERE token_hash = ?;
+
+-- name: ListTaskShares :many
+SELECT id, task_id, token_hash, expires_at, created_at FROM task_shares
+WHERE task_id = ? AND expires_at > ?
+ORDER BY created_at DESC;
+
+-- name: DeleteTaskShare :execrows
+DELETE FROM task_shares WHERE id = ? AND task_id = ?;
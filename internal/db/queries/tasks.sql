@@ -14,6 +14,11 @@ SELECT
     t.position,
     t.created_at,
     t.updated_at,
+    t.version,
+    t.pr_url,
+    t.base_branch,
+    t.no_changes,
+    t.pr_state,
     r.full_name as repository_name
 FROM tasks t
 LEFT JOIN repositories r ON t.repository_id = r.id
@@ -28,14 +33,37 @@ SELECT * FROM tasks
 WHERE status = ?
 ORDER BY status ASC, position ASC, created_at DESC;
 
--- name: UpdateTaskStatus :exec
-UPDATE tasks SET status = ? WHERE id = ?;
+-- name: UpdateTaskStatus :one
+UPDATE tasks SET status = ?, version = version + 1 WHERE id = ?
+RETURNING version;
+
+-- name: UpdateTaskStatusIfVersion :one
+UPDATE tasks SET status = ?, version = version + 1 WHERE id = ? AND version = ?
+RETURNING version;
 
 -- name: UpdateTaskPosition :exec
 UPDATE tasks SET position = ? WHERE id = ?;
 
 -- name: UpdateTaskPositionAndStatus :exec
-UPDATE tasks SET status = ?, position = ? WHERE id = ?;
+UPDATE tasks SET status = ?, position = ?, version = version + 1 WHERE id = ?;
+
+-- name: UpdateTaskBaseBranch :exec
+UPDATE tasks SET base_branch = ? WHERE id = ?;
+
+-- name: UpdateTaskRepository :exec
+UPDATE tasks SET repository_id = ?, base_branch = NULL WHERE id = ?;
+
+-- name: DetachRepositoryTasks :exec
+UPDATE tasks SET repository_id = NULL WHERE repository_id = ?;
+
+-- name: UpdateTaskPRURL :exec
+UPDATE tasks SET pr_url = ? WHERE id = ?;
+
+-- name: UpdateTaskPRState :exec
+UPDATE tasks SET pr_state = ? WHERE id = ?;
+
+-- name: UpdateTaskNoChanges :exec
+UPDATE tasks SET no_changes = ? WHERE id = ?;
 
 -- name: DeleteTask :exec
 DELETE FROM tasks WHERE id = ?;
@@ -52,6 +80,7 @@ SELECT
     t.position,
     t.created_at,
     t.updated_at,
+    t.pr_url,
     r.full_name as repository_name,
     COALESCE((SELECT m.content FROM messages m WHERE m.task_id = t.id AND m.role = 'assistant' ORDER BY m.created_at DESC LIMIT 1), '') as last_assistant_message
 FROM tasks t
@@ -63,3 +92,6 @@ SELECT * FROM tasks WHERE session_id = ?;
 
 -- name: UpdateTaskTitleIntent :exec
 UPDATE tasks SET title = ?, intent = ? WHERE id = ?;
+
+-- name: CountActiveTasks :one
+SELECT COUNT(*) FROM tasks WHERE status IN ('pending', 'planning', 'in_progress');
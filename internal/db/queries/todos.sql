@@ -0,0 +1,15 @@
+-- name: UpsertTaskTodos :exec
+INSERT INTO task_todos (task_id, todos, updated_at)
+VALUES (?, ?, ?)
+ON CONFLICT(task_id) DO UPDATE SET
+    todos = excluded.todos,
+    updated_at = excluded.updated_at;
+
+-- name: GetTaskTodos :one
+SELECT task_id, todos, updated_at FROM task_todos WHERE task_id = ?;
+
+-- name: ListOpenTaskProgress :many
+SELECT t.id, t.repository_id, t.status, t.version, tt.todos
+FROM tasks t
+LEFT JOIN task_todos tt ON tt.task_id = t.id
+WHERE t.status NOT IN ('done', 'failed');
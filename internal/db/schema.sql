@@ -72,6 +72,11 @@ CREATE TABLE IF NOT EXISTS tasks (
     position INTEGER DEFAULT 0,
     created_at INTEGER NOT NULL, -- timestampz replacement is unix in milli,
     updated_at INTEGER NOT NULL, -- timestampz replacement is unix in milli
+    version INTEGER NOT NULL DEFAULT 0, -- bumped on every status change, for compare-and-set
+    pr_url TEXT, -- set once a pull request is opened for the task
+    base_branch TEXT, -- branch the task starts from and merges into; NULL means the default branch
+    no_changes INTEGER NOT NULL DEFAULT 0, -- 1 = the last successful run left an empty diff
+    pr_state TEXT, -- open, merged or closed, from GitHub pull_request webhooks
     UNIQUE(session_id)
 );
 
@@ -98,7 +103,9 @@ CREATE TABLE IF NOT EXISTS agent_runs (
     completion_tokens  INTEGER NOT NULL DEFAULT 0 CHECK (completion_tokens>= 0),
     completed_at DATETIME,
     created_at INTEGER NOT NULL, -- timestampz replacement is unix in milli,
-    updated_at INTEGER NOT NULL -- timestampz replacement is unix in milli
+    updated_at INTEGER NOT NULL, -- timestampz replacement is unix in milli
+    system_prompt TEXT, -- effective system prompt sent to the agent
+    limit_reached TEXT -- why the agent loop was stopped early by its iteration or tool call limit
 );
 
 CREATE TRIGGER IF NOT EXISTS update_agent_runs_updated_at
@@ -140,7 +147,8 @@ CREATE TABLE IF NOT EXISTS messages (
     tool_id TEXT,  -- For tool messages: which tool was called
     created_at INTEGER NOT NULL, -- timestampz replacement is unix in milli,
     updated_at INTEGER NOT NULL, -- timestampz replacement is unix in milli,
-    finished_at INTEGER
+    finished_at INTEGER,
+    artifact_path TEXT -- full content when content was truncated
 );
 
 CREATE TRIGGER IF NOT EXISTS update_messages_updated_at
@@ -166,6 +174,31 @@ UPDATE agent_runs SET
 WHERE id = old.run_id;
 END;
 
+-- Task Todos: Latest todo list reported by the agent, one row per task
+CREATE TABLE IF NOT EXISTS task_todos (
+    task_id TEXT PRIMARY KEY REFERENCES tasks(id) ON DELETE CASCADE,
+    todos TEXT NOT NULL DEFAULT '[]', -- JSON array of tools.TodoItem
+    updated_at INTEGER NOT NULL -- Unix ms
+);
+
+-- Task Logs: Activity log entries emitted while a task runs
+CREATE TABLE IF NOT EXISTS task_logs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_id TEXT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    level TEXT NOT NULL CHECK(level IN ('info', 'success', 'warn', 'error')),
+    message TEXT NOT NULL,
+    created_at INTEGER NOT NULL -- Unix ms
+);
+
+-- Task Diffs: Last diff produced by a task, kept so the workspace can be removed
+CREATE TABLE IF NOT EXISTS task_diffs (
+    task_id TEXT PRIMARY KEY REFERENCES tasks(id) ON DELETE CASCADE,
+    diff TEXT NOT NULL,
+    updated_at INTEGER NOT NULL, -- Unix ms
+    artifact_path TEXT, -- full diff when diff was truncated
+    stat TEXT -- JSON DiffStat of the full diff
+);
+
 -- Settings: API keys and configuration (no user_id - single-tenant)
 CREATE TABLE IF NOT EXISTS settings (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -176,6 +209,15 @@ CREATE TABLE IF NOT EXISTS settings (
     agent_backend TEXT NOT NULL CHECK(agent_backend IN ('native', 'claude-code', 'codex')),
     provider TEXT,
     model TEXT,
+    fallback_models TEXT, -- JSON array of {provider, model}, tried in order
+    project_env TEXT, -- JSON object of project id -> {NAME: value} for agent processes
+    summary_model TEXT, -- "provider#model" for titles and summaries, empty = task model
+    auto_resolve_conflicts INTEGER NOT NULL DEFAULT 0, -- 1 = let the LLM attempt merge conflict resolution
+    max_iterations INTEGER NOT NULL DEFAULT 0, -- LLM calls per native agent run, 0 = default
+    max_tool_calls INTEGER NOT NULL DEFAULT 0, -- tool calls per native agent run, 0 = default
+    commit_author_name TEXT, -- name on agent commits, empty = Counterspell Agent
+    commit_author_email TEXT, -- email on agent commits, empty = agent@counterspell
+    show_thinking INTEGER NOT NULL DEFAULT 0, -- 1 = keep and show agent thinking/reasoning blocks
     updated_at INTEGER NOT NULL -- timestampz replacement is unix in milli
 );
 
@@ -217,6 +259,8 @@ CREATE TABLE IF NOT EXISTS repositories (
     local_path TEXT,
     created_at INTEGER NOT NULL, -- Unix ms
     updated_at INTEGER NOT NULL, -- Unix ms
+    language TEXT NOT NULL DEFAULT '', -- primary language reported by GitHub, refreshed on sync
+    size_kb INTEGER NOT NULL DEFAULT 0, -- repository size reported by GitHub, in KB
     UNIQUE(connection_id, full_name)
 );
 
@@ -227,6 +271,83 @@ UPDATE repositories SET updated_at = strftime('%s', 'now')
 WHERE id = new.id;
 END;
 
+-- Issue watches: projects whose labeled GitHub issues become tasks
+CREATE TABLE IF NOT EXISTS issue_watches (
+    repository_id TEXT PRIMARY KEY REFERENCES repositories(id) ON DELETE CASCADE,
+    created_at INTEGER NOT NULL -- Unix ms
+);
+
+-- Issue tasks: the task created for each watched issue. Kept when the task
+-- is deleted so the issue isn't picked up again.
+CREATE TABLE IF NOT EXISTS issue_tasks (
+    repository_id TEXT NOT NULL REFERENCES repositories(id) ON DELETE CASCADE,
+    issue_number INTEGER NOT NULL,
+    task_id TEXT NOT NULL,
+    commented_at INTEGER, -- Unix ms, set once the PR link is posted
+    created_at INTEGER NOT NULL, -- Unix ms
+    PRIMARY KEY (repository_id, issue_number)
+);
+
+-- Auto-merge repositories: projects whose task pull requests are merged once
+-- GitHub reports their checks green (check_suite webhooks)
+CREATE TABLE IF NOT EXISTS auto_merge_repositories (
+    repository_id TEXT PRIMARY KEY REFERENCES repositories(id) ON DELETE CASCADE,
+    created_at INTEGER NOT NULL -- Unix ms
+);
+
+-- Commit status repositories: projects that get a GitHub commit status while
+-- an agent runs on them
+CREATE TABLE IF NOT EXISTS commit_status_repositories (
+    repository_id TEXT PRIMARY KEY REFERENCES repositories(id) ON DELETE CASCADE,
+    created_at INTEGER NOT NULL -- Unix ms
+);
+
+-- Commit signing repositories: projects whose agent commits are signed with
+-- the server's GIT_SIGNING_KEY
+CREATE TABLE IF NOT EXISTS commit_signing_repositories (
+    repository_id TEXT PRIMARY KEY REFERENCES repositories(id) ON DELETE CASCADE,
+    created_at INTEGER NOT NULL -- Unix ms
+);
+
+-- Slack users: Slack accounts linked with `/counterspell link <code>`, the
+-- only ones whose slash commands are run
+CREATE TABLE IF NOT EXISTS slack_users (
+    team_id TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    user_name TEXT NOT NULL,
+    created_at INTEGER NOT NULL, -- Unix ms
+    PRIMARY KEY (team_id, user_id)
+);
+
+-- Slack tasks: tasks started from Slack and the response_url their progress
+-- is reported to
+CREATE TABLE IF NOT EXISTS slack_tasks (
+    task_id TEXT PRIMARY KEY,
+    response_url TEXT NOT NULL,
+    notified_status TEXT NOT NULL, -- last task status reported
+    created_at INTEGER NOT NULL -- Unix ms
+);
+
+-- Task shares: read-only links to one task, found by the SHA-256 of their
+-- token. Revoking a share deletes its row.
+CREATE TABLE IF NOT EXISTS task_shares (
+    id TEXT PRIMARY KEY,
+    task_id TEXT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    token_hash TEXT NOT NULL UNIQUE,
+    expires_at INTEGER NOT NULL, -- Unix ms
+    created_at INTEGER NOT NULL -- Unix ms
+);
+
+-- Personal access tokens for scripts and CI; only the token's hash is kept
+CREATE TABLE IF NOT EXISTS api_tokens (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL DEFAULT 'default',
+    name TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    last_used_at INTEGER, -- Unix ms
+    created_at INTEGER NOT NULL -- Unix ms
+);
+
 -- Insert default settings row
 INSERT OR IGNORE INTO settings (id, agent_backend, provider, model) VALUES (1, 'native', 'anthropic', 'claude-opus-4-5');
 
@@ -255,6 +376,7 @@ CREATE INDEX IF NOT EXISTS idx_agent_runs_task ON agent_runs(task_id);
 CREATE INDEX IF NOT EXISTS idx_messages_task ON messages(task_id);
 CREATE INDEX IF NOT EXISTS idx_messages_task_created ON messages(task_id, created_at DESC);
 CREATE INDEX IF NOT EXISTS idx_messages_run ON messages(run_id);
+CREATE INDEX IF NOT EXISTS idx_task_logs_task ON task_logs(task_id, id);
 CREATE INDEX IF NOT EXISTS idx_sessions_last_message_at ON sessions(last_message_at DESC);
 CREATE INDEX IF NOT EXISTS idx_session_messages_session ON session_messages(session_id, sequence);
 CREATE INDEX IF NOT EXISTS idx_runs_created_at ON agent_runs (created_at);
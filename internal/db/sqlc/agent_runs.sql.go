@@ -52,7 +52,7 @@ func (q *Queries) DeleteAgentRunsByTask(ctx context.Context, taskID string) erro
 }
 
 const getAgentRun = `-- name: GetAgentRun :one
-SELECT id, task_id, prompt, agent_backend, provider, model, summary_message_id, backend_session_id, cost, message_count, prompt_tokens, completion_tokens, completed_at, created_at, updated_at FROM agent_runs WHERE id = ?
+SELECT id, task_id, prompt, agent_backend, provider, model, summary_message_id, backend_session_id, cost, message_count, prompt_tokens, completion_tokens, completed_at, created_at, updated_at, system_prompt, limit_reached FROM agent_runs WHERE id = ?
 `
 
 func (q *Queries) GetAgentRun(ctx context.Context, id string) (AgentRun, error) {
@@ -74,12 +74,14 @@ func (q *Queries) GetAgentRun(ctx context.Context, id string) (AgentRun, error)
 		&i.CompletedAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.SystemPrompt,
+		&i.LimitReached,
 	)
 	return i, err
 }
 
 const getLatestRun = `-- name: GetLatestRun :one
-SELECT id, task_id, prompt, agent_backend, provider, model, summary_message_id, backend_session_id, cost, message_count, prompt_tokens, completion_tokens, completed_at, created_at, updated_at FROM agent_runs
+SELECT id, task_id, prompt, agent_backend, provider, model, summary_message_id, backend_session_id, cost, message_count, prompt_tokens, completion_tokens, completed_at, created_at, updated_at, system_prompt, limit_reached FROM agent_runs
 WHERE task_id = ?
 ORDER BY created_at DESC
 LIMIT 1
@@ -104,12 +106,14 @@ func (q *Queries) GetLatestRun(ctx context.Context, taskID string) (AgentRun, er
 		&i.CompletedAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.SystemPrompt,
+		&i.LimitReached,
 	)
 	return i, err
 }
 
 const listAgentRunsByTask = `-- name: ListAgentRunsByTask :many
-SELECT id, task_id, prompt, agent_backend, provider, model, summary_message_id, backend_session_id, cost, message_count, prompt_tokens, completion_tokens, completed_at, created_at, updated_at FROM agent_runs
+SELECT id, task_id, prompt, agent_backend, provider, model, summary_message_id, backend_session_id, cost, message_count, prompt_tokens, completion_tokens, completed_at, created_at, updated_at, system_prompt, limit_reached FROM agent_runs
 WHERE task_id = ?
 ORDER BY created_at ASC
 `
@@ -139,6 +143,114 @@ func (q *Queries) ListAgentRunsByTask(ctx context.Context, taskID string) ([]Age
 			&i.CompletedAt,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.SystemPrompt,
+			&i.LimitReached,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const sumAgentRunCostByModel = `-- name: SumAgentRunCostByModel :many
+SELECT COALESCE(ar.provider, '') AS provider,
+       COALESCE(ar.model, '') AS model,
+       COUNT(ar.id) AS run_count,
+       CAST(COALESCE(SUM(ar.cost), 0) AS REAL) AS cost,
+       CAST(COALESCE(SUM(ar.prompt_tokens), 0) AS INTEGER) AS prompt_tokens,
+       CAST(COALESCE(SUM(ar.completion_tokens), 0) AS INTEGER) AS completion_tokens
+FROM agent_runs ar
+WHERE ar.created_at >= ?
+GROUP BY ar.provider, ar.model
+ORDER BY cost DESC, provider ASC, model ASC
+`
+
+type SumAgentRunCostByModelRow struct {
+	Provider         string  `json:"provider"`
+	Model            string  `json:"model"`
+	RunCount         int64   `json:"run_count"`
+	Cost             float64 `json:"cost"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+}
+
+func (q *Queries) SumAgentRunCostByModel(ctx context.Context, createdAt int64) ([]SumAgentRunCostByModelRow, error) {
+	rows, err := q.db.QueryContext(ctx, sumAgentRunCostByModel, createdAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []SumAgentRunCostByModelRow{}
+	for rows.Next() {
+		var i SumAgentRunCostByModelRow
+		if err := rows.Scan(
+			&i.Provider,
+			&i.Model,
+			&i.RunCount,
+			&i.Cost,
+			&i.PromptTokens,
+			&i.CompletionTokens,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const sumAgentRunCostByProject = `-- name: SumAgentRunCostByProject :many
+SELECT COALESCE(t.repository_id, '') AS repository_id,
+       COALESCE(r.full_name, '') AS project,
+       COUNT(ar.id) AS run_count,
+       CAST(COALESCE(SUM(ar.cost), 0) AS REAL) AS cost,
+       CAST(COALESCE(SUM(ar.prompt_tokens), 0) AS INTEGER) AS prompt_tokens,
+       CAST(COALESCE(SUM(ar.completion_tokens), 0) AS INTEGER) AS completion_tokens
+FROM agent_runs ar
+JOIN tasks t ON t.id = ar.task_id
+LEFT JOIN repositories r ON r.id = t.repository_id
+WHERE ar.created_at >= ?
+GROUP BY t.repository_id
+ORDER BY cost DESC, project ASC
+`
+
+type SumAgentRunCostByProjectRow struct {
+	RepositoryID     string  `json:"repository_id"`
+	Project          string  `json:"project"`
+	RunCount         int64   `json:"run_count"`
+	Cost             float64 `json:"cost"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+}
+
+func (q *Queries) SumAgentRunCostByProject(ctx context.Context, createdAt int64) ([]SumAgentRunCostByProjectRow, error) {
+	rows, err := q.db.QueryContext(ctx, sumAgentRunCostByProject, createdAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []SumAgentRunCostByProjectRow{}
+	for rows.Next() {
+		var i SumAgentRunCostByProjectRow
+		if err := rows.Scan(
+			&i.RepositoryID,
+			&i.Project,
+			&i.RunCount,
+			&i.Cost,
+			&i.PromptTokens,
+			&i.CompletionTokens,
 		); err != nil {
 			return nil, err
 		}
@@ -180,3 +292,46 @@ func (q *Queries) UpdateAgentRunCompleted(ctx context.Context, arg UpdateAgentRu
 	_, err := q.db.ExecContext(ctx, updateAgentRunCompleted, arg.CompletedAt, arg.ID)
 	return err
 }
+
+const updateAgentRunLimitReached = `-- name: UpdateAgentRunLimitReached :exec
+UPDATE agent_runs SET limit_reached = ? WHERE id = ?
+`
+
+type UpdateAgentRunLimitReachedParams struct {
+	LimitReached sql.NullString `json:"limit_reached"`
+	ID           string         `json:"id"`
+}
+
+func (q *Queries) UpdateAgentRunLimitReached(ctx context.Context, arg UpdateAgentRunLimitReachedParams) error {
+	_, err := q.db.ExecContext(ctx, updateAgentRunLimitReached, arg.LimitReached, arg.ID)
+	return err
+}
+
+const updateAgentRunModel = `-- name: UpdateAgentRunModel :exec
+UPDATE agent_runs SET provider = ?, model = ? WHERE id = ?
+`
+
+type UpdateAgentRunModelParams struct {
+	Provider sql.NullString `json:"provider"`
+	Model    sql.NullString `json:"model"`
+	ID       string         `json:"id"`
+}
+
+func (q *Queries) UpdateAgentRunModel(ctx context.Context, arg UpdateAgentRunModelParams) error {
+	_, err := q.db.ExecContext(ctx, updateAgentRunModel, arg.Provider, arg.Model, arg.ID)
+	return err
+}
+
+const updateAgentRunSystemPrompt = `-- name: UpdateAgentRunSystemPrompt :exec
+UPDATE agent_runs SET system_prompt = ? WHERE id = ?
+`
+
+type UpdateAgentRunSystemPromptParams struct {
+	SystemPrompt sql.NullString `json:"system_prompt"`
+	ID           string         `json:"id"`
+}
+
+func (q *Queries) UpdateAgentRunSystemPrompt(ctx context.Context, arg UpdateAgentRunSystemPromptParams) error {
+	_, err := q.db.ExecContext(ctx, updateAgentRunSystemPrompt, arg.SystemPrompt, arg.ID)
+	return err
+}
@@ -137,3 +137,47 @@ func (q *Queries) GetArtifactsByTask(ctx context.Context, taskID string) ([]Arti
 	}
 	return items, nil
 }
+
+const getTaskArtifact = `-- name: GetTaskArtifact :one
+SELECT a.id, a.run_id, a.path, a.content, a.version, a.created_at, a.updated_at FROM artifacts a
+JOIN agent_runs ar ON a.run_id = ar.id
+WHERE a.id = ? AND ar.task_id = ?
+`
+
+type GetTaskArtifactParams struct {
+	ID     string `json:"id"`
+	TaskID string `json:"task_id"`
+}
+
+func (q *Queries) GetTaskArtifact(ctx context.Context, arg GetTaskArtifactParams) (Artifact, error) {
+	row := q.db.QueryRowContext(ctx, getTaskArtifact, arg.ID, arg.TaskID)
+	var i Artifact
+	err := row.Scan(
+		&i.ID,
+		&i.RunID,
+		&i.Path,
+		&i.Content,
+		&i.Version,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getLatestArtifactVersion = `-- name: GetLatestArtifactVersion :one
+SELECT CAST(COALESCE(MAX(a.version), 0) AS INTEGER) FROM artifacts a
+JOIN agent_runs ar ON a.run_id = ar.id
+WHERE ar.task_id = ? AND a.path = ?
+`
+
+type GetLatestArtifactVersionParams struct {
+	TaskID string `json:"task_id"`
+	Path   string `json:"path"`
+}
+
+func (q *Queries) GetLatestArtifactVersion(ctx context.Context, arg GetLatestArtifactVersionParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, getLatestArtifactVersion, arg.TaskID, arg.Path)
+	var column_1 int64
+	err := row.Scan(&column_1)
+	return column_1, err
+}
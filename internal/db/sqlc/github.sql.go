@@ -56,7 +56,7 @@ INSERT INTO repositories (
     id, connection_id, name, full_name, owner, is_private, html_url, clone_url, local_path, created_at, updated_at
 ) VALUES (
     ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
-) RETURNING id, connection_id, name, full_name, owner, is_private, html_url, clone_url, local_path, created_at, updated_at
+) RETURNING id, connection_id, name, full_name, owner, is_private, html_url, clone_url, local_path, created_at, updated_at, language, size_kb
 `
 
 type CreateRepositoryParams struct {
@@ -100,6 +100,8 @@ func (q *Queries) CreateRepository(ctx context.Context, arg CreateRepositoryPara
 		&i.LocalPath,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Language,
+		&i.SizeKb,
 	)
 	return i, err
 }
@@ -113,6 +115,15 @@ func (q *Queries) DeleteGithubConnection(ctx context.Context, id string) error {
 	return err
 }
 
+const deleteRepository = `-- name: DeleteRepository :exec
+DELETE FROM repositories WHERE id = ?
+`
+
+func (q *Queries) DeleteRepository(ctx context.Context, id string) error {
+	_, err := q.db.ExecContext(ctx, deleteRepository, id)
+	return err
+}
+
 const deleteRepositoriesByConnection = `-- name: DeleteRepositoriesByConnection :exec
 DELETE FROM repositories WHERE connection_id = ?
 `
@@ -122,6 +133,49 @@ func (q *Queries) DeleteRepositoriesByConnection(ctx context.Context, connection
 	return err
 }
 
+const findRepositoriesByName = `-- name: FindRepositoriesByName :many
+SELECT id, connection_id, name, full_name, owner, is_private, html_url, clone_url, local_path, created_at, updated_at, language, size_kb FROM repositories
+WHERE lower(full_name) = lower(?1) OR lower(name) = lower(?1)
+ORDER BY full_name ASC
+`
+
+func (q *Queries) FindRepositoriesByName(ctx context.Context, name string) ([]Repository, error) {
+	rows, err := q.db.QueryContext(ctx, findRepositoriesByName, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Repository{}
+	for rows.Next() {
+		var i Repository
+		if err := rows.Scan(
+			&i.ID,
+			&i.ConnectionID,
+			&i.Name,
+			&i.FullName,
+			&i.Owner,
+			&i.IsPrivate,
+			&i.HtmlUrl,
+			&i.CloneUrl,
+			&i.LocalPath,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Language,
+			&i.SizeKb,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getGithubConnection = `-- name: GetGithubConnection :one
 SELECT id, github_user_id, access_token, username, avatar_url, created_at, updated_at FROM github_connections LIMIT 1
 `
@@ -161,7 +215,7 @@ func (q *Queries) GetGithubConnectionByID(ctx context.Context, id string) (Githu
 }
 
 const getRepository = `-- name: GetRepository :one
-SELECT id, connection_id, name, full_name, owner, is_private, html_url, clone_url, local_path, created_at, updated_at FROM repositories WHERE id = ?
+SELECT id, connection_id, name, full_name, owner, is_private, html_url, clone_url, local_path, created_at, updated_at, language, size_kb FROM repositories WHERE id = ?
 `
 
 func (q *Queries) GetRepository(ctx context.Context, id string) (Repository, error) {
@@ -179,12 +233,14 @@ func (q *Queries) GetRepository(ctx context.Context, id string) (Repository, err
 		&i.LocalPath,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Language,
+		&i.SizeKb,
 	)
 	return i, err
 }
 
 const listRepositories = `-- name: ListRepositories :many
-SELECT id, connection_id, name, full_name, owner, is_private, html_url, clone_url, local_path, created_at, updated_at FROM repositories WHERE connection_id = ? ORDER BY full_name ASC
+SELECT id, connection_id, name, full_name, owner, is_private, html_url, clone_url, local_path, created_at, updated_at, language, size_kb FROM repositories WHERE connection_id = ? ORDER BY full_name ASC
 `
 
 func (q *Queries) ListRepositories(ctx context.Context, connectionID string) ([]Repository, error) {
@@ -208,6 +264,8 @@ func (q *Queries) ListRepositories(ctx context.Context, connectionID string) ([]
 			&i.LocalPath,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.Language,
+			&i.SizeKb,
 		); err != nil {
 			return nil, err
 		}
@@ -260,9 +318,9 @@ func (q *Queries) UpdateGithubConnection(ctx context.Context, arg UpdateGithubCo
 
 const upsertRepository = `-- name: UpsertRepository :one
 INSERT INTO repositories (
-    id, connection_id, name, full_name, owner, is_private, html_url, clone_url, local_path, created_at, updated_at
+    id, connection_id, name, full_name, owner, is_private, html_url, clone_url, local_path, created_at, updated_at, language, size_kb
 ) VALUES (
-    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
 )
 ON CONFLICT(connection_id, full_name) DO UPDATE SET
     name = excluded.name,
@@ -270,8 +328,10 @@ ON CONFLICT(connection_id, full_name) DO UPDATE SET
     html_url = excluded.html_url,
     clone_url = excluded.clone_url,
     local_path = excluded.local_path,
-    updated_at = excluded.updated_at
-RETURNING id, connection_id, name, full_name, owner, is_private, html_url, clone_url, local_path, created_at, updated_at
+    updated_at = excluded.updated_at,
+    language = excluded.language,
+    size_kb = excluded.size_kb
+RETURNING id, connection_id, name, full_name, owner, is_private, html_url, clone_url, local_path, created_at, updated_at, language, size_kb
 `
 
 type UpsertRepositoryParams struct {
@@ -286,6 +346,8 @@ type UpsertRepositoryParams struct {
 	LocalPath    sql.NullString `json:"local_path"`
 	CreatedAt    int64          `json:"created_at"`
 	UpdatedAt    int64          `json:"updated_at"`
+	Language     string         `json:"language"`
+	SizeKb       int64          `json:"size_kb"`
 }
 
 func (q *Queries) UpsertRepository(ctx context.Context, arg UpsertRepositoryParams) (Repository, error) {
@@ -301,6 +363,8 @@ func (q *Queries) UpsertRepository(ctx context.Context, arg UpsertRepositoryPara
 		arg.LocalPath,
 		arg.CreatedAt,
 		arg.UpdatedAt,
+		arg.Language,
+		arg.SizeKb,
 	)
 	var i Repository
 	err := row.Scan(
@@ -315,6 +379,8 @@ func (q *Queries) UpsertRepository(ctx context.Context, arg UpsertRepositoryPara
 		&i.LocalPath,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Language,
+		&i.SizeKb,
 	)
 	return i, err
 }
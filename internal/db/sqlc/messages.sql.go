@@ -11,21 +11,22 @@ import (
 )
 
 const createMessage = `-- name: CreateMessage :exec
-INSERT INTO messages (id, task_id, run_id, role, content, parts, model, provider, created_at, updated_at)
-VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+INSERT INTO messages (id, task_id, run_id, role, content, parts, model, provider, artifact_path, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 `
 
 type CreateMessageParams struct {
-	ID        string         `json:"id"`
-	TaskID    string         `json:"task_id"`
-	RunID     string         `json:"run_id"`
-	Role      string         `json:"role"`
-	Content   string         `json:"content"`
-	Parts     string         `json:"parts"`
-	Model     sql.NullString `json:"model"`
-	Provider  sql.NullString `json:"provider"`
-	CreatedAt int64          `json:"created_at"`
-	UpdatedAt int64          `json:"updated_at"`
+	ID           string         `json:"id"`
+	TaskID       string         `json:"task_id"`
+	RunID        string         `json:"run_id"`
+	Role         string         `json:"role"`
+	Content      string         `json:"content"`
+	Parts        string         `json:"parts"`
+	Model        sql.NullString `json:"model"`
+	Provider     sql.NullString `json:"provider"`
+	ArtifactPath sql.NullString `json:"artifact_path"`
+	CreatedAt    int64          `json:"created_at"`
+	UpdatedAt    int64          `json:"updated_at"`
 }
 
 func (q *Queries) CreateMessage(ctx context.Context, arg CreateMessageParams) error {
@@ -38,6 +39,7 @@ func (q *Queries) CreateMessage(ctx context.Context, arg CreateMessageParams) er
 		arg.Parts,
 		arg.Model,
 		arg.Provider,
+		arg.ArtifactPath,
 		arg.CreatedAt,
 		arg.UpdatedAt,
 	)
@@ -54,7 +56,7 @@ func (q *Queries) DeleteMessagesByTask(ctx context.Context, taskID string) error
 }
 
 const getMessage = `-- name: GetMessage :one
-SELECT id, task_id, run_id, role, parts, model, provider, content, tool_id, created_at, updated_at, finished_at FROM messages WHERE id = ?
+SELECT id, task_id, run_id, role, parts, model, provider, content, tool_id, created_at, updated_at, finished_at, artifact_path FROM messages WHERE id = ?
 `
 
 func (q *Queries) GetMessage(ctx context.Context, id string) (Message, error) {
@@ -73,12 +75,13 @@ func (q *Queries) GetMessage(ctx context.Context, id string) (Message, error) {
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.FinishedAt,
+		&i.ArtifactPath,
 	)
 	return i, err
 }
 
 const getMessagesByRun = `-- name: GetMessagesByRun :many
-SELECT id, task_id, run_id, role, parts, model, provider, content, tool_id, created_at, updated_at, finished_at FROM messages WHERE run_id = ? ORDER BY created_at ASC
+SELECT id, task_id, run_id, role, parts, model, provider, content, tool_id, created_at, updated_at, finished_at, artifact_path FROM messages WHERE run_id = ? ORDER BY created_at ASC
 `
 
 func (q *Queries) GetMessagesByRun(ctx context.Context, runID string) ([]Message, error) {
@@ -103,6 +106,7 @@ func (q *Queries) GetMessagesByRun(ctx context.Context, runID string) ([]Message
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.FinishedAt,
+			&i.ArtifactPath,
 		); err != nil {
 			return nil, err
 		}
@@ -118,7 +122,7 @@ func (q *Queries) GetMessagesByRun(ctx context.Context, runID string) ([]Message
 }
 
 const getMessagesByTask = `-- name: GetMessagesByTask :many
-SELECT id, task_id, run_id, role, parts, model, provider, content, tool_id, created_at, updated_at, finished_at FROM messages WHERE task_id = ? ORDER BY created_at ASC
+SELECT id, task_id, run_id, role, parts, model, provider, content, tool_id, created_at, updated_at, finished_at, artifact_path FROM messages WHERE task_id = ? ORDER BY created_at ASC
 `
 
 func (q *Queries) GetMessagesByTask(ctx context.Context, taskID string) ([]Message, error) {
@@ -143,6 +147,7 @@ func (q *Queries) GetMessagesByTask(ctx context.Context, taskID string) ([]Messa
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.FinishedAt,
+			&i.ArtifactPath,
 		); err != nil {
 			return nil, err
 		}
@@ -158,7 +163,7 @@ func (q *Queries) GetMessagesByTask(ctx context.Context, taskID string) ([]Messa
 }
 
 const getRecentMessages = `-- name: GetRecentMessages :many
-SELECT id, task_id, run_id, role, parts, model, provider, content, tool_id, created_at, updated_at, finished_at FROM messages WHERE task_id = ? ORDER BY created_at DESC LIMIT ?
+SELECT id, task_id, run_id, role, parts, model, provider, content, tool_id, created_at, updated_at, finished_at, artifact_path FROM messages WHERE task_id = ? ORDER BY created_at DESC LIMIT ?
 `
 
 type GetRecentMessagesParams struct {
@@ -188,6 +193,7 @@ func (q *Queries) GetRecentMessages(ctx context.Context, arg GetRecentMessagesPa
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.FinishedAt,
+			&i.ArtifactPath,
 		); err != nil {
 			return nil, err
 		}
@@ -201,3 +207,22 @@ func (q *Queries) GetRecentMessages(ctx context.Context, arg GetRecentMessagesPa
 	}
 	return items, nil
 }
+
+const relocateMessageArtifacts = `-- name: RelocateMessageArtifacts :execrows
+UPDATE messages
+SET artifact_path = ?1 || substr(artifact_path, length(?2) + 1)
+WHERE substr(artifact_path, 1, length(?2)) = ?2
+`
+
+type RelocateMessageArtifactsParams struct {
+	NewPrefix string `json:"new_prefix"`
+	OldPrefix string `json:"old_prefix"`
+}
+
+func (q *Queries) RelocateMessageArtifacts(ctx context.Context, arg RelocateMessageArtifactsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, relocateMessageArtifacts, arg.NewPrefix, arg.OldPrefix)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
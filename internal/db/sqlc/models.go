@@ -24,6 +24,17 @@ type AgentRun struct {
 	CompletedAt      sql.NullTime   `json:"completed_at"`
 	CreatedAt        int64          `json:"created_at"`
 	UpdatedAt        int64          `json:"updated_at"`
+	SystemPrompt     sql.NullString `json:"system_prompt"`
+	LimitReached     sql.NullString `json:"limit_reached"`
+}
+
+type ApiToken struct {
+	ID         string        `json:"id"`
+	UserID     string        `json:"user_id"`
+	Name       string        `json:"name"`
+	TokenHash  string        `json:"token_hash"`
+	LastUsedAt sql.NullInt64 `json:"last_used_at"`
+	CreatedAt  int64         `json:"created_at"`
 }
 
 type Artifact struct {
@@ -36,6 +47,21 @@ type Artifact struct {
 	UpdatedAt int64  `json:"updated_at"`
 }
 
+type AutoMergeRepository struct {
+	RepositoryID string `json:"repository_id"`
+	CreatedAt    int64  `json:"created_at"`
+}
+
+type CommitSigningRepository struct {
+	RepositoryID string `json:"repository_id"`
+	CreatedAt    int64  `json:"created_at"`
+}
+
+type CommitStatusRepository struct {
+	RepositoryID string `json:"repository_id"`
+	CreatedAt    int64  `json:"created_at"`
+}
+
 type GithubConnection struct {
 	ID           string         `json:"id"`
 	GithubUserID string         `json:"github_user_id"`
@@ -46,6 +72,19 @@ type GithubConnection struct {
 	UpdatedAt    int64          `json:"updated_at"`
 }
 
+type IssueTask struct {
+	RepositoryID string        `json:"repository_id"`
+	IssueNumber  int64         `json:"issue_number"`
+	TaskID       string        `json:"task_id"`
+	CommentedAt  sql.NullInt64 `json:"commented_at"`
+	CreatedAt    int64         `json:"created_at"`
+}
+
+type IssueWatch struct {
+	RepositoryID string `json:"repository_id"`
+	CreatedAt    int64  `json:"created_at"`
+}
+
 type MachineIdentity struct {
 	MachineID      string         `json:"machine_id"`
 	MachineJwt     sql.NullString `json:"machine_jwt"`
@@ -58,18 +97,19 @@ type MachineIdentity struct {
 }
 
 type Message struct {
-	ID         string         `json:"id"`
-	TaskID     string         `json:"task_id"`
-	RunID      string         `json:"run_id"`
-	Role       string         `json:"role"`
-	Parts      string         `json:"parts"`
-	Model      sql.NullString `json:"model"`
-	Provider   sql.NullString `json:"provider"`
-	Content    string         `json:"content"`
-	ToolID     sql.NullString `json:"tool_id"`
-	CreatedAt  int64          `json:"created_at"`
-	UpdatedAt  int64          `json:"updated_at"`
-	FinishedAt sql.NullInt64  `json:"finished_at"`
+	ID           string         `json:"id"`
+	TaskID       string         `json:"task_id"`
+	RunID        string         `json:"run_id"`
+	Role         string         `json:"role"`
+	Parts        string         `json:"parts"`
+	Model        sql.NullString `json:"model"`
+	Provider     sql.NullString `json:"provider"`
+	Content      string         `json:"content"`
+	ToolID       sql.NullString `json:"tool_id"`
+	CreatedAt    int64          `json:"created_at"`
+	UpdatedAt    int64          `json:"updated_at"`
+	FinishedAt   sql.NullInt64  `json:"finished_at"`
+	ArtifactPath sql.NullString `json:"artifact_path"`
 }
 
 type OauthLoginAttempt struct {
@@ -91,6 +131,8 @@ type Repository struct {
 	LocalPath    sql.NullString `json:"local_path"`
 	CreatedAt    int64          `json:"created_at"`
 	UpdatedAt    int64          `json:"updated_at"`
+	Language     string         `json:"language"`
+	SizeKb       int64          `json:"size_kb"`
 }
 
 type Session struct {
@@ -119,15 +161,38 @@ type SessionMessage struct {
 }
 
 type Setting struct {
-	ID            int64          `json:"id"`
-	OpenrouterKey sql.NullString `json:"openrouter_key"`
-	ZaiKey        sql.NullString `json:"zai_key"`
-	AnthropicKey  sql.NullString `json:"anthropic_key"`
-	OpenaiKey     sql.NullString `json:"openai_key"`
-	AgentBackend  string         `json:"agent_backend"`
-	Provider      sql.NullString `json:"provider"`
-	Model         sql.NullString `json:"model"`
-	UpdatedAt     int64          `json:"updated_at"`
+	ID                   int64          `json:"id"`
+	OpenrouterKey        sql.NullString `json:"openrouter_key"`
+	ZaiKey               sql.NullString `json:"zai_key"`
+	AnthropicKey         sql.NullString `json:"anthropic_key"`
+	OpenaiKey            sql.NullString `json:"openai_key"`
+	AgentBackend         string         `json:"agent_backend"`
+	Provider             sql.NullString `json:"provider"`
+	Model                sql.NullString `json:"model"`
+	FallbackModels       sql.NullString `json:"fallback_models"`
+	ProjectEnv           sql.NullString `json:"project_env"`
+	SummaryModel         sql.NullString `json:"summary_model"`
+	AutoResolveConflicts int64          `json:"auto_resolve_conflicts"`
+	MaxIterations        int64          `json:"max_iterations"`
+	MaxToolCalls         int64          `json:"max_tool_calls"`
+	CommitAuthorName     sql.NullString `json:"commit_author_name"`
+	CommitAuthorEmail    sql.NullString `json:"commit_author_email"`
+	ShowThinking         int64          `json:"show_thinking"`
+	UpdatedAt            int64          `json:"updated_at"`
+}
+
+type SlackTask struct {
+	TaskID         string `json:"task_id"`
+	ResponseUrl    string `json:"response_url"`
+	NotifiedStatus string `json:"notified_status"`
+	CreatedAt      int64  `json:"created_at"`
+}
+
+type SlackUser struct {
+	TeamID    string `json:"team_id"`
+	UserID    string `json:"user_id"`
+	UserName  string `json:"user_name"`
+	CreatedAt int64  `json:"created_at"`
 }
 
 type Task struct {
@@ -141,4 +206,39 @@ type Task struct {
 	Position         sql.NullInt64  `json:"position"`
 	CreatedAt        int64          `json:"created_at"`
 	UpdatedAt        int64          `json:"updated_at"`
+	Version          int64          `json:"version"`
+	PrUrl            sql.NullString `json:"pr_url"`
+	BaseBranch       sql.NullString `json:"base_branch"`
+	NoChanges        int64          `json:"no_changes"`
+	PrState          sql.NullString `json:"pr_state"`
+}
+
+type TaskDiff struct {
+	TaskID       string         `json:"task_id"`
+	Diff         string         `json:"diff"`
+	UpdatedAt    int64          `json:"updated_at"`
+	ArtifactPath sql.NullString `json:"artifact_path"`
+	Stat         sql.NullString `json:"stat"`
+}
+
+type TaskLog struct {
+	ID        int64  `json:"id"`
+	TaskID    string `json:"task_id"`
+	Level     string `json:"level"`
+	Message   string `json:"message"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+type TaskShare struct {
+	ID        string `json:"id"`
+	TaskID    string `json:"task_id"`
+	TokenHash string `json:"token_hash"`
+	ExpiresAt int64  `json:"expires_at"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+type TaskTodo struct {
+	TaskID    string `json:"task_id"`
+	Todos     string `json:"todos"`
+	UpdatedAt int64  `json:"updated_at"`
 }
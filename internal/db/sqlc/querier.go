@@ -10,7 +10,13 @@ import (
 )
 
 type Querier interface {
+	ClaimIssueTask(ctx context.Context, arg ClaimIssueTaskParams) (int64, error)
 	CleanupExpiredOAuthAttempts(ctx context.Context, createdAt int64) error
+	CountActiveTasks(ctx context.Context) (int64, error)
+	CountAutoMergeRepository(ctx context.Context, repositoryID string) (int64, error)
+	CountCommitSigningRepository(ctx context.Context, repositoryID string) (int64, error)
+	CountCommitStatusRepository(ctx context.Context, repositoryID string) (int64, error)
+	CreateAPIToken(ctx context.Context, arg CreateAPITokenParams) error
 	CreateAgentRun(ctx context.Context, arg CreateAgentRunParams) error
 	CreateArtifact(ctx context.Context, arg CreateArtifactParams) error
 	CreateGithubConnection(ctx context.Context, arg CreateGithubConnectionParams) (GithubConnection, error)
@@ -21,20 +27,40 @@ type Querier interface {
 	// Sessions
 	CreateSession(ctx context.Context, arg CreateSessionParams) error
 	CreateSessionMessage(ctx context.Context, arg CreateSessionMessageParams) error
+	CreateSlackTask(ctx context.Context, arg CreateSlackTaskParams) error
 	CreateTask(ctx context.Context, arg CreateTaskParams) error
+	CreateTaskLog(ctx context.Context, arg CreateTaskLogParams) (TaskLog, error)
+	CreateTaskShare(ctx context.Context, arg CreateTaskShareParams) error
+	DeleteAPIToken(ctx context.Context, arg DeleteAPITokenParams) (int64, error)
 	DeleteAgentRunsByTask(ctx context.Context, taskID string) error
 	DeleteArtifactsByRun(ctx context.Context, runID string) error
 	DeleteGithubConnection(ctx context.Context, id string) error
 	DeleteMessagesByTask(ctx context.Context, taskID string) error
 	DeleteOAuthLoginAttempt(ctx context.Context, state string) error
 	DeleteRepositoriesByConnection(ctx context.Context, connectionID string) error
+	DeleteRepository(ctx context.Context, id string) error
+	DeleteSlackTask(ctx context.Context, taskID string) error
 	DeleteTask(ctx context.Context, id string) error
+	DeleteTaskShare(ctx context.Context, arg DeleteTaskShareParams) (int64, error)
+	DetachRepositoryTasks(ctx context.Context, repositoryID sql.NullString) error
+	DisableAutoMerge(ctx context.Context, repositoryID string) error
+	DisableCommitSigning(ctx context.Context, repositoryID string) error
+	DisableCommitStatuses(ctx context.Context, repositoryID string) error
+	DisableIssueWatch(ctx context.Context, repositoryID string) error
+	EnableAutoMerge(ctx context.Context, arg EnableAutoMergeParams) error
+	EnableCommitSigning(ctx context.Context, arg EnableCommitSigningParams) error
+	EnableCommitStatuses(ctx context.Context, arg EnableCommitStatusesParams) error
+	EnableIssueWatch(ctx context.Context, arg EnableIssueWatchParams) error
+	FindRepositoriesByName(ctx context.Context, name string) ([]Repository, error)
+	GetAPITokenByHash(ctx context.Context, tokenHash string) (ApiToken, error)
 	GetAgentRun(ctx context.Context, id string) (AgentRun, error)
 	GetArtifact(ctx context.Context, id string) (Artifact, error)
 	GetArtifactsByRun(ctx context.Context, runID string) ([]Artifact, error)
 	GetArtifactsByTask(ctx context.Context, taskID string) ([]Artifact, error)
 	GetGithubConnection(ctx context.Context) (GithubConnection, error)
 	GetGithubConnectionByID(ctx context.Context, id string) (GithubConnection, error)
+	GetIssueTaskID(ctx context.Context, arg GetIssueTaskIDParams) (string, error)
+	GetLatestArtifactVersion(ctx context.Context, arg GetLatestArtifactVersionParams) (int64, error)
 	GetLatestRun(ctx context.Context, taskID string) (AgentRun, error)
 	GetMachineByUserID(ctx context.Context, userID string) (MachineIdentity, error)
 	GetMachineIdentity(ctx context.Context, machineID string) (MachineIdentity, error)
@@ -48,30 +74,70 @@ type Querier interface {
 	GetSessionByBackendExternal(ctx context.Context, arg GetSessionByBackendExternalParams) (Session, error)
 	GetSessionNextSequence(ctx context.Context, sessionID string) (int64, error)
 	GetSettings(ctx context.Context) (GetSettingsRow, error)
+	GetSlackUser(ctx context.Context, arg GetSlackUserParams) (SlackUser, error)
 	GetTask(ctx context.Context, id string) (GetTaskRow, error)
+	GetTaskArtifact(ctx context.Context, arg GetTaskArtifactParams) (Artifact, error)
 	GetTaskBySessionID(ctx context.Context, sessionID sql.NullString) (Task, error)
+	GetTaskDiff(ctx context.Context, taskID string) (TaskDiff, error)
+	GetTaskDiffStat(ctx context.Context, taskID string) (sql.NullString, error)
+	GetTaskShareByTokenHash(ctx context.Context, tokenHash string) (TaskShare, error)
+	GetTaskTodos(ctx context.Context, taskID string) (TaskTodo, error)
+	LinkSlackUser(ctx context.Context, arg LinkSlackUserParams) error
+	ListAPITokens(ctx context.Context, userID string) ([]ApiToken, error)
 	ListAgentRunsByTask(ctx context.Context, taskID string) ([]AgentRun, error)
+	ListAutoMergeRepositoryIDs(ctx context.Context) ([]string, error)
+	ListCommitSigningRepositoryIDs(ctx context.Context) ([]string, error)
+	ListCommitStatusRepositoryIDs(ctx context.Context) ([]string, error)
+	ListIssueTasksAwaitingComment(ctx context.Context) ([]ListIssueTasksAwaitingCommentRow, error)
+	ListOpenTaskProgress(ctx context.Context) ([]ListOpenTaskProgressRow, error)
 	ListRepositories(ctx context.Context, connectionID string) ([]Repository, error)
 	ListSessionMessages(ctx context.Context, sessionID string) ([]SessionMessage, error)
 	ListSessions(ctx context.Context) ([]Session, error)
+	ListSlackTaskUpdates(ctx context.Context) ([]ListSlackTaskUpdatesRow, error)
+	ListTaskLogs(ctx context.Context, taskID string) ([]TaskLog, error)
+	ListTaskShares(ctx context.Context, arg ListTaskSharesParams) ([]TaskShare, error)
 	ListTasks(ctx context.Context) ([]Task, error)
 	ListTasksByStatus(ctx context.Context, status string) ([]Task, error)
 	ListTasksWithRepository(ctx context.Context) ([]ListTasksWithRepositoryRow, error)
+	ListTasksWithStaleWorkspace(ctx context.Context, updatedAt int64) ([]ListTasksWithStaleWorkspaceRow, error)
+	ListWatchedRepositories(ctx context.Context) ([]ListWatchedRepositoriesRow, error)
+	MarkIssueTaskCommented(ctx context.Context, arg MarkIssueTaskCommentedParams) error
+	MarkSlackTaskNotified(ctx context.Context, arg MarkSlackTaskNotifiedParams) error
+	ReleaseIssueTask(ctx context.Context, arg ReleaseIssueTaskParams) error
+	RelocateMessageArtifacts(ctx context.Context, arg RelocateMessageArtifactsParams) (int64, error)
+	RelocateTaskDiffArtifacts(ctx context.Context, arg RelocateTaskDiffArtifactsParams) (int64, error)
+	SetIssueTaskID(ctx context.Context, arg SetIssueTaskIDParams) error
+	SetTaskDiffStat(ctx context.Context, arg SetTaskDiffStatParams) error
+	SumAgentRunCostByModel(ctx context.Context, createdAt int64) ([]SumAgentRunCostByModelRow, error)
+	SumAgentRunCostByProject(ctx context.Context, createdAt int64) ([]SumAgentRunCostByProjectRow, error)
+	TouchAPIToken(ctx context.Context, arg TouchAPITokenParams) error
+	UnlinkSlackUser(ctx context.Context, arg UnlinkSlackUserParams) (int64, error)
 	UpdateAgentRunBackendSessionID(ctx context.Context, arg UpdateAgentRunBackendSessionIDParams) error
 	UpdateAgentRunCompleted(ctx context.Context, arg UpdateAgentRunCompletedParams) error
+	UpdateAgentRunLimitReached(ctx context.Context, arg UpdateAgentRunLimitReachedParams) error
+	UpdateAgentRunModel(ctx context.Context, arg UpdateAgentRunModelParams) error
+	UpdateAgentRunSystemPrompt(ctx context.Context, arg UpdateAgentRunSystemPromptParams) error
 	UpdateGithubConnection(ctx context.Context, arg UpdateGithubConnectionParams) (GithubConnection, error)
 	UpdateMachineIdentityJWT(ctx context.Context, arg UpdateMachineIdentityJWTParams) error
 	UpdateMachineIdentityLastSeen(ctx context.Context, arg UpdateMachineIdentityLastSeenParams) error
 	UpdateSession(ctx context.Context, arg UpdateSessionParams) error
 	UpdateSessionBackendSessionID(ctx context.Context, arg UpdateSessionBackendSessionIDParams) error
 	UpdateSessionTitle(ctx context.Context, arg UpdateSessionTitleParams) error
+	UpdateTaskBaseBranch(ctx context.Context, arg UpdateTaskBaseBranchParams) error
+	UpdateTaskNoChanges(ctx context.Context, arg UpdateTaskNoChangesParams) error
+	UpdateTaskPRState(ctx context.Context, arg UpdateTaskPRStateParams) error
+	UpdateTaskPRURL(ctx context.Context, arg UpdateTaskPRURLParams) error
 	UpdateTaskPosition(ctx context.Context, arg UpdateTaskPositionParams) error
 	UpdateTaskPositionAndStatus(ctx context.Context, arg UpdateTaskPositionAndStatusParams) error
-	UpdateTaskStatus(ctx context.Context, arg UpdateTaskStatusParams) error
+	UpdateTaskRepository(ctx context.Context, arg UpdateTaskRepositoryParams) error
+	UpdateTaskStatus(ctx context.Context, arg UpdateTaskStatusParams) (int64, error)
+	UpdateTaskStatusIfVersion(ctx context.Context, arg UpdateTaskStatusIfVersionParams) (int64, error)
 	UpdateTaskTitleIntent(ctx context.Context, arg UpdateTaskTitleIntentParams) error
 	UpsertMachineIdentity(ctx context.Context, arg UpsertMachineIdentityParams) (MachineIdentity, error)
 	UpsertRepository(ctx context.Context, arg UpsertRepositoryParams) (Repository, error)
 	UpsertSettings(ctx context.Context, arg UpsertSettingsParams) error
+	UpsertTaskDiff(ctx context.Context, arg UpsertTaskDiffParams) error
+	UpsertTaskTodos(ctx context.Context, arg UpsertTaskTodosParams) error
 }
 
 var _ Querier = (*Queries)(nil)
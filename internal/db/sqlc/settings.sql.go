@@ -15,19 +15,37 @@ SELECT openrouter_key, zai_key, anthropic_key, openai_key,
        COALESCE(agent_backend, 'native') as agent_backend,
        COALESCE(provider, 'anthropic') as provider,
        COALESCE(model, 'claude-opus-4-5') as model,
+       fallback_models,
+       project_env,
+       summary_model,
+       auto_resolve_conflicts,
+       max_iterations,
+       max_tool_calls,
+       commit_author_name,
+       commit_author_email,
+       show_thinking,
        updated_at
 FROM settings WHERE id = 1
 `
 
 type GetSettingsRow struct {
-	OpenrouterKey sql.NullString `json:"openrouter_key"`
-	ZaiKey        sql.NullString `json:"zai_key"`
-	AnthropicKey  sql.NullString `json:"anthropic_key"`
-	OpenaiKey     sql.NullString `json:"openai_key"`
-	AgentBackend  string         `json:"agent_backend"`
-	Provider      string         `json:"provider"`
-	Model         string         `json:"model"`
-	UpdatedAt     int64          `json:"updated_at"`
+	OpenrouterKey        sql.NullString `json:"openrouter_key"`
+	ZaiKey               sql.NullString `json:"zai_key"`
+	AnthropicKey         sql.NullString `json:"anthropic_key"`
+	OpenaiKey            sql.NullString `json:"openai_key"`
+	AgentBackend         string         `json:"agent_backend"`
+	Provider             string         `json:"provider"`
+	Model                string         `json:"model"`
+	FallbackModels       sql.NullString `json:"fallback_models"`
+	ProjectEnv           sql.NullString `json:"project_env"`
+	SummaryModel         sql.NullString `json:"summary_model"`
+	AutoResolveConflicts int64          `json:"auto_resolve_conflicts"`
+	MaxIterations        int64          `json:"max_iterations"`
+	MaxToolCalls         int64          `json:"max_tool_calls"`
+	CommitAuthorName     sql.NullString `json:"commit_author_name"`
+	CommitAuthorEmail    sql.NullString `json:"commit_author_email"`
+	ShowThinking         int64          `json:"show_thinking"`
+	UpdatedAt            int64          `json:"updated_at"`
 }
 
 func (q *Queries) GetSettings(ctx context.Context) (GetSettingsRow, error) {
@@ -41,6 +59,15 @@ func (q *Queries) GetSettings(ctx context.Context) (GetSettingsRow, error) {
 		&i.AgentBackend,
 		&i.Provider,
 		&i.Model,
+		&i.FallbackModels,
+		&i.ProjectEnv,
+		&i.SummaryModel,
+		&i.AutoResolveConflicts,
+		&i.MaxIterations,
+		&i.MaxToolCalls,
+		&i.CommitAuthorName,
+		&i.CommitAuthorEmail,
+		&i.ShowThinking,
 		&i.UpdatedAt,
 	)
 	return i, err
@@ -56,6 +83,15 @@ INSERT INTO settings (
     agent_backend,
     provider,
     model,
+    fallback_models,
+    project_env,
+    summary_model,
+    auto_resolve_conflicts,
+    max_iterations,
+    max_tool_calls,
+    commit_author_name,
+    commit_author_email,
+    show_thinking,
     updated_at
 ) VALUES (
     1,
@@ -66,6 +102,15 @@ INSERT INTO settings (
     ?,
     ?,
     ?,
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
+    ?,
     ?
 )
 ON CONFLICT(id) DO UPDATE SET
@@ -76,18 +121,36 @@ ON CONFLICT(id) DO UPDATE SET
     agent_backend = excluded.agent_backend,
     provider = excluded.provider,
     model = excluded.model,
+    fallback_models = excluded.fallback_models,
+    project_env = excluded.project_env,
+    summary_model = excluded.summary_model,
+    auto_resolve_conflicts = excluded.auto_resolve_conflicts,
+    max_iterations = excluded.max_iterations,
+    max_tool_calls = excluded.max_tool_calls,
+    commit_author_name = excluded.commit_author_name,
+    commit_author_email = excluded.commit_author_email,
+    show_thinking = excluded.show_thinking,
     updated_at = excluded.updated_at
 `
 
 type UpsertSettingsParams struct {
-	OpenrouterKey sql.NullString `json:"openrouter_key"`
-	ZaiKey        sql.NullString `json:"zai_key"`
-	AnthropicKey  sql.NullString `json:"anthropic_key"`
-	OpenaiKey     sql.NullString `json:"openai_key"`
-	AgentBackend  string         `json:"agent_backend"`
-	Provider      sql.NullString `json:"provider"`
-	Model         sql.NullString `json:"model"`
-	UpdatedAt     int64          `json:"updated_at"`
+	OpenrouterKey        sql.NullString `json:"openrouter_key"`
+	ZaiKey               sql.NullString `json:"zai_key"`
+	AnthropicKey         sql.NullString `json:"anthropic_key"`
+	OpenaiKey            sql.NullString `json:"openai_key"`
+	AgentBackend         string         `json:"agent_backend"`
+	Provider             sql.NullString `json:"provider"`
+	Model                sql.NullString `json:"model"`
+	FallbackModels       sql.NullString `json:"fallback_models"`
+	ProjectEnv           sql.NullString `json:"project_env"`
+	SummaryModel         sql.NullString `json:"summary_model"`
+	AutoResolveConflicts int64          `json:"auto_resolve_conflicts"`
+	MaxIterations        int64          `json:"max_iterations"`
+	MaxToolCalls         int64          `json:"max_tool_calls"`
+	CommitAuthorName     sql.NullString `json:"commit_author_name"`
+	CommitAuthorEmail    sql.NullString `json:"commit_author_email"`
+	ShowThinking         int64          `json:"show_thinking"`
+	UpdatedAt            int64          `json:"updated_at"`
 }
 
 func (q *Queries) UpsertSettings(ctx context.Context, arg UpsertSettingsParams) error {
@@ -99,6 +162,15 @@ func (q *Queries) UpsertSettings(ctx context.Context, arg UpsertSettingsParams)
 		arg.AgentBackend,
 		arg.Provider,
 		arg.Model,
+		arg.FallbackModels,
+		arg.ProjectEnv,
+		arg.SummaryModel,
+		arg.AutoResolveConflicts,
+		arg.MaxIterations,
+		arg.MaxToolCalls,
+		arg.CommitAuthorName,
+		arg.CommitAuthorEmail,
+		arg.ShowThinking,
 		arg.UpdatedAt,
 	)
 	return err
@@ -10,6 +10,17 @@ import (
 	"database/sql"
 )
 
+const countActiveTasks = `-- name: CountActiveTasks :one
+SELECT COUNT(*) FROM tasks WHERE status IN ('pending', 'planning', 'in_progress')
+`
+
+func (q *Queries) CountActiveTasks(ctx context.Context) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countActiveTasks)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createTask = `-- name: CreateTask :exec
 INSERT INTO tasks (id, repository_id, session_id, title, intent, promoted_snapshot, status, created_at, updated_at)
 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
@@ -63,6 +74,11 @@ SELECT
     t.position,
     t.created_at,
     t.updated_at,
+    t.version,
+    t.pr_url,
+    t.base_branch,
+    t.no_changes,
+    t.pr_state,
     r.full_name as repository_name
 FROM tasks t
 LEFT JOIN repositories r ON t.repository_id = r.id
@@ -80,6 +96,11 @@ type GetTaskRow struct {
 	Position         sql.NullInt64  `json:"position"`
 	CreatedAt        int64          `json:"created_at"`
 	UpdatedAt        int64          `json:"updated_at"`
+	Version          int64          `json:"version"`
+	PrUrl            sql.NullString `json:"pr_url"`
+	BaseBranch       sql.NullString `json:"base_branch"`
+	NoChanges        int64          `json:"no_changes"`
+	PrState          sql.NullString `json:"pr_state"`
 	RepositoryName   sql.NullString `json:"repository_name"`
 }
 
@@ -97,13 +118,18 @@ func (q *Queries) GetTask(ctx context.Context, id string) (GetTaskRow, error) {
 		&i.Position,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Version,
+		&i.PrUrl,
+		&i.BaseBranch,
+		&i.NoChanges,
+		&i.PrState,
 		&i.RepositoryName,
 	)
 	return i, err
 }
 
 const getTaskBySessionID = `-- name: GetTaskBySessionID :one
-SELECT id, repository_id, session_id, title, intent, promoted_snapshot, status, position, created_at, updated_at FROM tasks WHERE session_id = ?
+SELECT id, repository_id, session_id, title, intent, promoted_snapshot, status, position, created_at, updated_at, version, pr_url, base_branch, no_changes, pr_state FROM tasks WHERE session_id = ?
 `
 
 func (q *Queries) GetTaskBySessionID(ctx context.Context, sessionID sql.NullString) (Task, error) {
@@ -120,12 +146,17 @@ func (q *Queries) GetTaskBySessionID(ctx context.Context, sessionID sql.NullStri
 		&i.Position,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Version,
+		&i.PrUrl,
+		&i.BaseBranch,
+		&i.NoChanges,
+		&i.PrState,
 	)
 	return i, err
 }
 
 const listTasks = `-- name: ListTasks :many
-SELECT id, repository_id, session_id, title, intent, promoted_snapshot, status, position, created_at, updated_at FROM tasks
+SELECT id, repository_id, session_id, title, intent, promoted_snapshot, status, position, created_at, updated_at, version, pr_url, base_branch, no_changes, pr_state FROM tasks
 ORDER BY status ASC, position ASC, created_at DESC
 `
 
@@ -149,6 +180,11 @@ func (q *Queries) ListTasks(ctx context.Context) ([]Task, error) {
 			&i.Position,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.Version,
+			&i.PrUrl,
+			&i.BaseBranch,
+			&i.NoChanges,
+			&i.PrState,
 		); err != nil {
 			return nil, err
 		}
@@ -164,7 +200,7 @@ func (q *Queries) ListTasks(ctx context.Context) ([]Task, error) {
 }
 
 const listTasksByStatus = `-- name: ListTasksByStatus :many
-SELECT id, repository_id, session_id, title, intent, promoted_snapshot, status, position, created_at, updated_at FROM tasks
+SELECT id, repository_id, session_id, title, intent, promoted_snapshot, status, position, created_at, updated_at, version, pr_url, base_branch, no_changes, pr_state FROM tasks
 WHERE status = ?
 ORDER BY status ASC, position ASC, created_at DESC
 `
@@ -189,6 +225,11 @@ func (q *Queries) ListTasksByStatus(ctx context.Context, status string) ([]Task,
 			&i.Position,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.Version,
+			&i.PrUrl,
+			&i.BaseBranch,
+			&i.NoChanges,
+			&i.PrState,
 		); err != nil {
 			return nil, err
 		}
@@ -215,6 +256,7 @@ SELECT
     t.position,
     t.created_at,
     t.updated_at,
+    t.pr_url,
     r.full_name as repository_name,
     COALESCE((SELECT m.content FROM messages m WHERE m.task_id = t.id AND m.role = 'assistant' ORDER BY m.created_at DESC LIMIT 1), '') as last_assistant_message
 FROM tasks t
@@ -233,6 +275,7 @@ type ListTasksWithRepositoryRow struct {
 	Position             sql.NullInt64  `json:"position"`
 	CreatedAt            int64          `json:"created_at"`
 	UpdatedAt            int64          `json:"updated_at"`
+	PrUrl                sql.NullString `json:"pr_url"`
 	RepositoryName       sql.NullString `json:"repository_name"`
 	LastAssistantMessage interface{}    `json:"last_assistant_message"`
 }
@@ -257,6 +300,7 @@ func (q *Queries) ListTasksWithRepository(ctx context.Context) ([]ListTasksWithR
 			&i.Position,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.PrUrl,
 			&i.RepositoryName,
 			&i.LastAssistantMessage,
 		); err != nil {
@@ -273,6 +317,34 @@ func (q *Queries) ListTasksWithRepository(ctx context.Context) ([]ListTasksWithR
 	return items, nil
 }
 
+const updateTaskPRState = `-- name: UpdateTaskPRState :exec
+UPDATE tasks SET pr_state = ? WHERE id = ?
+`
+
+type UpdateTaskPRStateParams struct {
+	PrState sql.NullString `json:"pr_state"`
+	ID      string         `json:"id"`
+}
+
+func (q *Queries) UpdateTaskPRState(ctx context.Context, arg UpdateTaskPRStateParams) error {
+	_, err := q.db.ExecContext(ctx, updateTaskPRState, arg.PrState, arg.ID)
+	return err
+}
+
+const updateTaskNoChanges = `-- name: UpdateTaskNoChanges :exec
+UPDATE tasks SET no_changes = ? WHERE id = ?
+`
+
+type UpdateTaskNoChangesParams struct {
+	NoChanges int64  `json:"no_changes"`
+	ID        string `json:"id"`
+}
+
+func (q *Queries) UpdateTaskNoChanges(ctx context.Context, arg UpdateTaskNoChangesParams) error {
+	_, err := q.db.ExecContext(ctx, updateTaskNoChanges, arg.NoChanges, arg.ID)
+	return err
+}
+
 const updateTaskPosition = `-- name: UpdateTaskPosition :exec
 UPDATE tasks SET position = ? WHERE id = ?
 `
@@ -288,7 +360,7 @@ func (q *Queries) UpdateTaskPosition(ctx context.Context, arg UpdateTaskPosition
 }
 
 const updateTaskPositionAndStatus = `-- name: UpdateTaskPositionAndStatus :exec
-UPDATE tasks SET status = ?, position = ? WHERE id = ?
+UPDATE tasks SET status = ?, position = ?, version = version + 1 WHERE id = ?
 `
 
 type UpdateTaskPositionAndStatusParams struct {
@@ -302,8 +374,60 @@ func (q *Queries) UpdateTaskPositionAndStatus(ctx context.Context, arg UpdateTas
 	return err
 }
 
-const updateTaskStatus = `-- name: UpdateTaskStatus :exec
-UPDATE tasks SET status = ? WHERE id = ?
+const updateTaskBaseBranch = `-- name: UpdateTaskBaseBranch :exec
+UPDATE tasks SET base_branch = ? WHERE id = ?
+`
+
+type UpdateTaskBaseBranchParams struct {
+	BaseBranch sql.NullString `json:"base_branch"`
+	ID         string         `json:"id"`
+}
+
+func (q *Queries) UpdateTaskBaseBranch(ctx context.Context, arg UpdateTaskBaseBranchParams) error {
+	_, err := q.db.ExecContext(ctx, updateTaskBaseBranch, arg.BaseBranch, arg.ID)
+	return err
+}
+
+const updateTaskPRURL = `-- name: UpdateTaskPRURL :exec
+UPDATE tasks SET pr_url = ? WHERE id = ?
+`
+
+type UpdateTaskPRURLParams struct {
+	PrUrl sql.NullString `json:"pr_url"`
+	ID    string         `json:"id"`
+}
+
+func (q *Queries) UpdateTaskPRURL(ctx context.Context, arg UpdateTaskPRURLParams) error {
+	_, err := q.db.ExecContext(ctx, updateTaskPRURL, arg.PrUrl, arg.ID)
+	return err
+}
+
+const updateTaskRepository = `-- name: UpdateTaskRepository :exec
+UPDATE tasks SET repository_id = ?, base_branch = NULL WHERE id = ?
+`
+
+type UpdateTaskRepositoryParams struct {
+	RepositoryID sql.NullString `json:"repository_id"`
+	ID           string         `json:"id"`
+}
+
+func (q *Queries) UpdateTaskRepository(ctx context.Context, arg UpdateTaskRepositoryParams) error {
+	_, err := q.db.ExecContext(ctx, updateTaskRepository, arg.RepositoryID, arg.ID)
+	return err
+}
+
+const detachRepositoryTasks = `-- name: DetachRepositoryTasks :exec
+UPDATE tasks SET repository_id = NULL WHERE repository_id = ?
+`
+
+func (q *Queries) DetachRepositoryTasks(ctx context.Context, repositoryID sql.NullString) error {
+	_, err := q.db.ExecContext(ctx, detachRepositoryTasks, repositoryID)
+	return err
+}
+
+const updateTaskStatus = `-- name: UpdateTaskStatus :one
+UPDATE tasks SET status = ?, version = version + 1 WHERE id = ?
+RETURNING version
 `
 
 type UpdateTaskStatusParams struct {
@@ -311,9 +435,29 @@ type UpdateTaskStatusParams struct {
 	ID     string `json:"id"`
 }
 
-func (q *Queries) UpdateTaskStatus(ctx context.Context, arg UpdateTaskStatusParams) error {
-	_, err := q.db.ExecContext(ctx, updateTaskStatus, arg.Status, arg.ID)
-	return err
+func (q *Queries) UpdateTaskStatus(ctx context.Context, arg UpdateTaskStatusParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, updateTaskStatus, arg.Status, arg.ID)
+	var version int64
+	err := row.Scan(&version)
+	return version, err
+}
+
+const updateTaskStatusIfVersion = `-- name: UpdateTaskStatusIfVersion :one
+UPDATE tasks SET status = ?, version = version + 1 WHERE id = ? AND version = ?
+RETURNING version
+`
+
+type UpdateTaskStatusIfVersionParams struct {
+	Status  string `json:"status"`
+	ID      string `json:"id"`
+	Version int64  `json:"version"`
+}
+
+func (q *Queries) UpdateTaskStatusIfVersion(ctx context.Context, arg UpdateTaskStatusIfVersionParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, updateTaskStatusIfVersion, arg.Status, arg.ID, arg.Version)
+	var version int64
+	err := row.Scan(&version)
+	return version, err
 }
 
 const updateTaskTitleIntent = `-- name: UpdateTaskTitleIntent :exec
@@ -1,11 +1,22 @@
 package handlers
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
+	"math"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
+	"github.com/revrost/counterspell/internal/auth"
 )
 
 // HandleAddTask creates a new task from frontend.
@@ -14,17 +25,18 @@ func (h *Handlers) HandleAddTask(w http.ResponseWriter, r *http.Request) {
 	//	userID := "default"
 
 	var req struct {
-		Intent    string `json:"intent"`
-		ProjectID string `json:"project_id"`
-		ModelID   string `json:"model_id"`
+		Intent     string `json:"intent"`
+		ProjectID  string `json:"project_id"`
+		ModelID    string `json:"model_id"`
+		BaseBranch string `json:"base_branch"` // optional; defaults to the repo's default branch
 	}
 	if err := render.DecodeJSON(r.Body, &req); err != nil {
-		http.Error(w, "Invalid request", http.StatusBadRequest)
+		_ = render.Render(w, r, ErrInvalidRequest(err))
 		return
 	}
 
 	if req.Intent == "" {
-		http.Error(w, "Intent required", http.StatusBadRequest)
+		_ = render.Render(w, r, ErrInvalidRequest(fmt.Errorf("intent required")))
 		return
 	}
 
@@ -35,16 +47,41 @@ func (h *Handlers) HandleAddTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	slog.Info("[HANDLER] Starting task submission", "project_id", req.ProjectID, "intent", req.Intent, "model_id", req.ModelID)
-	taskID, err := orch.StartTask(ctx, req.ProjectID, req.Intent, req.ModelID)
+	// Submissions are rate limited per user, on top of the concurrency cap
+	userID := auth.UserIDFromContext(ctx)
+	remaining := -1
+	if h.taskRateLimit != nil {
+		left, retryAfter, ok := h.taskRateLimit.Take(userID, time.Now())
+		if !ok {
+			wait := time.Duration(math.Ceil(retryAfter.Seconds())) * time.Second
+			slog.Warn("Task creation rate limited", "user_id", userID, "retry_after", wait)
+			w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())))
+			_ = render.Render(w, r, ErrTooManyRequests(fmt.Sprintf(
+				"Task limit reached (%d per %s), try again in %s", h.cfg.TaskRateLimit, h.cfg.TaskRateWindow, wait)))
+			return
+		}
+		remaining = left
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	}
+
+	slog.Info("[HANDLER] Starting task submission", "project_id", req.ProjectID, "intent", req.Intent, "model_id", req.ModelID, "base_branch", req.BaseBranch)
+	taskID, err := orch.StartTaskFromBranch(ctx, req.ProjectID, req.Intent, req.ModelID, strings.TrimSpace(req.BaseBranch))
 	if err != nil {
 		slog.Error("Failed to start task", "error", err)
-		_ = render.Render(w, r, ErrInternalServer("Failed to start task", err))
+		if h.taskRateLimit != nil {
+			// Refused submissions don't count against the limit
+			h.taskRateLimit.Refund(userID, time.Now())
+		}
+		_ = render.Render(w, r, ErrTaskAction("Failed to start task", err))
 		return
 	}
 
 	slog.Info("[HANDLER] Task submitted successfully", "task_id", taskID)
-	render.JSON(w, r, map[string]string{"task_id": taskID})
+	resp := map[string]any{"task_id": taskID}
+	if remaining >= 0 {
+		resp["rate_limit_remaining"] = remaining
+	}
+	render.JSON(w, r, resp)
 }
 
 func (h *Handlers) HandleActionChat(w http.ResponseWriter, r *http.Request) {
@@ -56,12 +93,12 @@ func (h *Handlers) HandleActionChat(w http.ResponseWriter, r *http.Request) {
 		ModelID string `json:"model_id"`
 	}
 	if err := render.DecodeJSON(r.Body, &req); err != nil {
-		http.Error(w, "Invalid request", http.StatusBadRequest)
+		_ = render.Render(w, r, ErrInvalidRequest(err))
 		return
 	}
 
 	if req.Intent == "" {
-		http.Error(w, "Intent required", http.StatusBadRequest)
+		_ = render.Render(w, r, ErrInvalidRequest(fmt.Errorf("intent required")))
 		return
 	}
 
@@ -76,7 +113,7 @@ func (h *Handlers) HandleActionChat(w http.ResponseWriter, r *http.Request) {
 	err = orch.ContinueTask(ctx, req.TaskID, req.Intent, req.ModelID)
 	if err != nil {
 		slog.Error("Failed to start task", "error", err)
-		_ = render.Render(w, r, ErrInternalServer("Failed to start task", err))
+		_ = render.Render(w, r, ErrTaskAction("Failed to start task", err))
 		return
 	}
 
@@ -84,6 +121,44 @@ func (h *Handlers) HandleActionChat(w http.ResponseWriter, r *http.Request) {
 	render.JSON(w, r, map[string]string{"task_id": req.TaskID, "status": "in_progress"})
 }
 
+// HandleActionInterrupt sends a message to a task's running agent. Backends
+// that can be steered take it mid-run; others are stopped and the task
+// continued with the message. The response's mode says which happened.
+func (h *Handlers) HandleActionInterrupt(w http.ResponseWriter, r *http.Request) {
+	taskID := chi.URLParam(r, "id")
+
+	var req struct {
+		Message string `json:"message"`
+	}
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		_ = render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	if strings.TrimSpace(req.Message) == "" {
+		_ = render.Render(w, r, ErrInvalidRequest(fmt.Errorf("message required")))
+		return
+	}
+
+	orch, err := h.getOrchestrator()
+	if err != nil {
+		_ = render.Render(w, r, ErrInternalServer("Failed to interrupt task", err))
+		return
+	}
+
+	injected, err := orch.InterruptTask(r.Context(), taskID, req.Message)
+	if err != nil {
+		slog.Error("Failed to interrupt task", "task_id", taskID, "error", err)
+		_ = render.Render(w, r, ErrTaskAction("Failed to interrupt task", err))
+		return
+	}
+
+	mode := "restarted"
+	if injected {
+		mode = "injected"
+	}
+	render.JSON(w, r, map[string]string{"task_id": taskID, "mode": mode})
+}
+
 // HandleActionClear clears a task.
 func (h *Handlers) HandleActionClear(w http.ResponseWriter, r *http.Request) {
 	taskID := chi.URLParam(r, "id")
@@ -104,12 +179,24 @@ func (h *Handlers) HandleActionClear(w http.ResponseWriter, r *http.Request) {
 	render.JSON(w, r, map[string]string{"status": "ok"})
 }
 
-// HandleActionRetry retries a failed task.
+// HandleActionRetry retries a failed task as a new task with the same
+// intent. With an "intent" body it instead reruns this task with the edited
+// intent on a clean workspace.
 func (h *Handlers) HandleActionRetry(w http.ResponseWriter, r *http.Request) {
 	taskID := chi.URLParam(r, "id")
 	ctx := r.Context()
 	//	userID := "default"
 
+	var req struct {
+		Intent string `json:"intent"`
+	}
+	if r.ContentLength > 0 {
+		if err := render.DecodeJSON(r.Body, &req); err != nil {
+			_ = render.Render(w, r, ErrInvalidRequest(err))
+			return
+		}
+	}
+
 	orch, err := h.getOrchestrator()
 	if err != nil {
 		slog.Error("Failed to create orchestrator", "error", err)
@@ -117,10 +204,22 @@ func (h *Handlers) HandleActionRetry(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Edit and retry: rerun this task with the new intent on a clean workspace
+	if strings.TrimSpace(req.Intent) != "" {
+		slog.Info("[HANDLER] Retry with edited intent", "task_id", taskID)
+		if err := orch.RetryTaskWithIntent(ctx, taskID, req.Intent); err != nil {
+			slog.Error("Failed to retry task", "error", err)
+			_ = render.Render(w, r, ErrTaskAction("Failed to retry task", err))
+			return
+		}
+		render.JSON(w, r, map[string]string{"task_id": taskID, "status": "in_progress"})
+		return
+	}
+
 	// For retry, we just start a new task with same intent
 	task, err := h.taskService.Get(ctx, taskID)
 	if err != nil {
-		http.Error(w, "Task not found", http.StatusNotFound)
+		_ = render.Render(w, r, ErrNotFound("Task not found"))
 		return
 	}
 
@@ -128,7 +227,11 @@ func (h *Handlers) HandleActionRetry(w http.ResponseWriter, r *http.Request) {
 	if task.RepositoryID != nil {
 		repoID = *task.RepositoryID
 	}
-	newTaskID, err := orch.StartTask(ctx, repoID, task.Intent, "")
+	baseBranch := ""
+	if task.BaseBranch != nil {
+		baseBranch = *task.BaseBranch
+	}
+	newTaskID, err := orch.StartTaskFromBranch(ctx, repoID, task.Intent, "", baseBranch)
 	if err != nil {
 		slog.Error("Failed to retry task", "error", err)
 		_ = render.Render(w, r, ErrInternalServer("Failed to retry task", err))
@@ -138,12 +241,68 @@ func (h *Handlers) HandleActionRetry(w http.ResponseWriter, r *http.Request) {
 	render.JSON(w, r, map[string]string{"task_id": newTaskID})
 }
 
-// HandleActionMerge attempts to merge task changes.
+// HandleActionRerun runs the task's intent again as a new agent run on a
+// fresh workspace, optionally with a different model or system prompt.
+// Earlier runs are kept.
+func (h *Handlers) HandleActionRerun(w http.ResponseWriter, r *http.Request) {
+	taskID := chi.URLParam(r, "id")
+	ctx := r.Context()
+
+	var req struct {
+		ModelID      string `json:"model_id"`
+		SystemPrompt string `json:"system_prompt"`
+	}
+	if r.ContentLength > 0 {
+		if err := render.DecodeJSON(r.Body, &req); err != nil {
+			_ = render.Render(w, r, ErrInvalidRequest(err))
+			return
+		}
+	}
+
+	orch, err := h.getOrchestrator()
+	if err != nil {
+		slog.Error("Failed to create orchestrator", "error", err)
+		_ = render.Render(w, r, ErrInternalServer("Failed to rerun task", err))
+		return
+	}
+
+	slog.Info("[HANDLER] Rerun submission", "task_id", taskID, "model_id", req.ModelID, "custom_prompt", req.SystemPrompt != "")
+	if req.SystemPrompt != "" {
+		err = orch.RerunTaskWithSystemPrompt(ctx, taskID, req.ModelID, req.SystemPrompt)
+	} else {
+		err = orch.RerunTask(ctx, taskID, req.ModelID)
+	}
+	if err != nil {
+		slog.Error("Failed to rerun task", "error", err)
+		_ = render.Render(w, r, ErrTaskAction("Failed to rerun task", err))
+		return
+	}
+
+	render.JSON(w, r, map[string]string{"task_id": taskID, "status": "in_progress"})
+}
+
+// protectedMergeMessage tells the user a merge became a pull request.
+const protectedMergeMessage = "Target branch is protected — opened a PR instead"
+
+// HandleActionMerge attempts to merge task changes. An optional "files" body
+// merges only the changes to those files; the response then lists the
+// changed files that were left out. When the target branch is protected the
+// response carries the pull request opened instead.
 func (h *Handlers) HandleActionMerge(w http.ResponseWriter, r *http.Request) {
 	taskID := chi.URLParam(r, "id")
 	ctx := r.Context()
 	//	userID := "default"
 
+	var req struct {
+		Files []string `json:"files"`
+	}
+	if r.ContentLength > 0 {
+		if err := render.DecodeJSON(r.Body, &req); err != nil {
+			_ = render.Render(w, r, ErrInvalidRequest(err))
+			return
+		}
+	}
+
 	orch, err := h.getOrchestrator()
 	if err != nil {
 		slog.Error("Failed to create orchestrator", "error", err)
@@ -151,9 +310,30 @@ func (h *Handlers) HandleActionMerge(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := orch.MergeTask(ctx, taskID); err != nil {
+	if req.Files != nil {
+		excluded, prURL, err := orch.MergeTaskFiles(ctx, taskID, req.Files)
+		if err != nil {
+			slog.Error("Failed to merge task", "error", err)
+			_ = render.Render(w, r, ErrTaskAction("Failed to merge task", err))
+			return
+		}
+		resp := map[string]any{"status": "ok", "excluded": excluded}
+		if prURL != "" {
+			resp["pr_url"] = prURL
+			resp["message"] = protectedMergeMessage
+		}
+		render.JSON(w, r, resp)
+		return
+	}
+
+	prURL, err := orch.MergeTask(ctx, taskID)
+	if err != nil {
 		slog.Error("Failed to merge task", "error", err)
-		_ = render.Render(w, r, ErrInternalServer("Failed to merge task", err))
+		_ = render.Render(w, r, ErrTaskAction("Failed to merge task", err))
+		return
+	}
+	if prURL != "" {
+		render.JSON(w, r, map[string]string{"status": "ok", "pr_url": prURL, "message": protectedMergeMessage})
 		return
 	}
 
@@ -174,7 +354,7 @@ func (h *Handlers) HandleActionPR(w http.ResponseWriter, r *http.Request) {
 	prURL, err := orch.CreatePR(r.Context(), taskID)
 	if err != nil {
 		slog.Error("Failed to create PR", "error", err)
-		_ = render.Render(w, r, ErrInternalServer("Failed to create PR", err))
+		_ = render.Render(w, r, ErrTaskAction("Failed to create PR", err))
 		return
 	}
 
@@ -200,3 +380,149 @@ func (h *Handlers) HandleActionDiscard(w http.ResponseWriter, r *http.Request) {
 
 	render.JSON(w, r, map[string]string{"status": "ok"})
 }
+
+// HandleDeleteTask deletes a task that isn't queued or running, with its
+// workspace and the logs and outputs kept for it on disk.
+func (h *Handlers) HandleDeleteTask(w http.ResponseWriter, r *http.Request) {
+	taskID := chi.URLParam(r, "id")
+
+	if _, err := h.taskService.Get(r.Context(), taskID); err != nil {
+		_ = render.Render(w, r, ErrNotFound("Task not found"))
+		return
+	}
+
+	orch, err := h.getOrchestrator()
+	if err != nil {
+		slog.Error("Failed to create orchestrator", "error", err)
+		_ = render.Render(w, r, ErrInternalServer("Failed to delete task", err))
+		return
+	}
+
+	if err := orch.DeleteTask(r.Context(), taskID); err != nil {
+		slog.Error("Failed to delete task", "error", err)
+		_ = render.Render(w, r, ErrTaskAction("Failed to delete task", err))
+		return
+	}
+
+	render.JSON(w, r, map[string]string{"status": "ok"})
+}
+
+// HandleActionMoveRepo reassigns a task that hasn't done any work yet to
+// another project.
+func (h *Handlers) HandleActionMoveRepo(w http.ResponseWriter, r *http.Request) {
+	taskID := chi.URLParam(r, "id")
+
+	var req struct {
+		ProjectID string `json:"project_id"`
+	}
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		_ = render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	if strings.TrimSpace(req.ProjectID) == "" {
+		_ = render.Render(w, r, ErrInvalidRequest(fmt.Errorf("project_id is required")))
+		return
+	}
+
+	orch, err := h.getOrchestrator()
+	if err != nil {
+		_ = render.Render(w, r, ErrInternalServer("Failed to move task", err))
+		return
+	}
+
+	err = orch.MoveTaskRepository(r.Context(), taskID, req.ProjectID)
+	if errors.Is(err, os.ErrNotExist) {
+		_ = render.Render(w, r, ErrNotFound("Project not found"))
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to move task", "task_id", taskID, "error", err)
+		_ = render.Render(w, r, ErrTaskAction("Failed to move task", err))
+		return
+	}
+
+	render.JSON(w, r, map[string]string{"status": "ok"})
+}
+
+// HandleActionCleanupWorkspaces removes workspaces of finished tasks now.
+// older_than_days defaults to WORKSPACE_RETENTION_DAYS; 0 removes every
+// eligible workspace.
+func (h *Handlers) HandleActionCleanupWorkspaces(w http.ResponseWriter, r *http.Request) {
+	olderThan := h.cfg.WorkspaceRetention()
+	if raw := r.URL.Query().Get("older_than_days"); raw != "" {
+		days, err := strconv.Atoi(raw)
+		if err != nil || days < 0 {
+			_ = render.Render(w, r, ErrInvalidRequest(fmt.Errorf("older_than_days must be a non-negative integer")))
+			return
+		}
+		olderThan = time.Duration(days) * 24 * time.Hour
+	}
+
+	orch, err := h.getOrchestrator()
+	if err != nil {
+		_ = render.Render(w, r, ErrInternalServer("Failed to clean up workspaces", err))
+		return
+	}
+
+	removed, err := orch.CleanupWorkspaces(r.Context(), olderThan)
+	if err != nil {
+		_ = render.Render(w, r, ErrInternalServer("Failed to clean up workspaces", err))
+		return
+	}
+	if removed == nil {
+		removed = []string{}
+	}
+
+	render.JSON(w, r, map[string]any{"status": "ok", "removed": removed})
+}
+
+// commandActions maps the actions accepted by HandleActionCommand to the
+// handlers behind their per-task routes.
+func (h *Handlers) commandActions() map[string]http.HandlerFunc {
+	return map[string]http.HandlerFunc{
+		"merge":   h.HandleActionMerge,
+		"pr":      h.HandleActionPR,
+		"retry":   h.HandleActionRetry,
+		"rerun":   h.HandleActionRerun,
+		"discard": h.HandleActionDiscard,
+		"chat":    h.HandleActionChat,
+	}
+}
+
+// HandleActionCommand dispatches {action, task_id} to the matching task
+// action so keyboard shortcuts can trigger any of them through one route.
+// The body is passed on unchanged, so chat and rerun read their usual fields
+// from it.
+func (h *Handlers) HandleActionCommand(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		_ = render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	var req struct {
+		Action string `json:"action"`
+		TaskID string `json:"task_id"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		_ = render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	if req.TaskID == "" {
+		_ = render.Render(w, r, ErrInvalidRequest(fmt.Errorf("task_id is required")))
+		return
+	}
+	handler, ok := h.commandActions()[req.Action]
+	if !ok {
+		_ = render.Render(w, r, ErrInvalidRequest(fmt.Errorf("unknown action %q", req.Action)))
+		return
+	}
+
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		rctx.URLParams.Add("id", req.TaskID)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	slog.Info("[HANDLER] Command", "action", req.Action, "task_id", req.TaskID)
+	handler(w, r)
+}
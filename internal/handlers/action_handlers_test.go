@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/revrost/counterspell/internal/db"
+	"github.com/revrost/counterspell/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleActionCommand(t *testing.T) {
+	ctx := context.Background()
+	testDB, err := db.Connect(ctx, ":memory:")
+	require.NoError(t, err)
+	defer testDB.Close()
+	require.NoError(t, testDB.RunMigrations(ctx))
+
+	repo := services.NewRepository(testDB)
+	orch, err := services.NewOrchestrator(repo, services.NewEventBus(), services.NewSettingsService(testDB), nil,
+		services.NewGitManager(t.TempDir(), t.TempDir()))
+	require.NoError(t, err)
+	t.Cleanup(orch.Shutdown)
+	h := &Handlers{taskService: repo, orchestrators: map[string]*services.Orchestrator{"shared": orch}}
+
+	pending, err := repo.Create(ctx, "", "Fix the login bug")
+	require.NoError(t, err)
+	done, err := repo.Create(ctx, "", "Add a changelog")
+	require.NoError(t, err)
+	for _, status := range []string{"in_progress", "review", "done"} {
+		_, err = repo.UpdateStatus(ctx, done.ID, status)
+		require.NoError(t, err)
+	}
+
+	r := chi.NewRouter()
+	r.Post("/api/v1/action/command", h.HandleActionCommand)
+
+	tests := []struct {
+		name    string
+		body    string
+		code    int
+		message string
+	}{
+		{"merge", `{"action":"merge","task_id":"` + pending.ID + `"}`, http.StatusConflict, "pending to done"},
+		{"pr", `{"action":"pr","task_id":"` + pending.ID + `"}`, http.StatusConflict, "pending to done"},
+		{"retry", `{"action":"retry","task_id":"missing"}`, http.StatusNotFound, "Task not found"},
+		{"rerun", `{"action":"rerun","task_id":"` + done.ID + `"}`, http.StatusConflict, "done to in_progress"},
+		{"chat without intent", `{"action":"chat","task_id":"` + done.ID + `"}`, http.StatusBadRequest, "intent required"},
+		{"chat", `{"action":"chat","task_id":"` + done.ID + `","intent":"Also update the docs"}`, http.StatusConflict, "done to in_progress"},
+		{"discard", `{"action":"discard","task_id":"` + pending.ID + `"}`, http.StatusOK, ""},
+		{"unknown action", `{"action":"deploy","task_id":"` + pending.ID + `"}`, http.StatusBadRequest, `unknown action "deploy"`},
+		{"missing task", `{"action":"merge"}`, http.StatusBadRequest, "task_id is required"},
+		{"invalid json", `{"action":`, http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/action/command", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.code, rec.Code, rec.Body.String())
+			var resp struct {
+				Status  string `json:"status"`
+				Message string `json:"message"`
+			}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp), rec.Body.String())
+			if tt.code == http.StatusOK {
+				assert.Equal(t, "ok", resp.Status)
+				return
+			}
+			// Every refusal uses the JSON error envelope
+			assert.Equal(t, "error", resp.Status)
+			assert.Contains(t, resp.Message, tt.message)
+		})
+	}
+}
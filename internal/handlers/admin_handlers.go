@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+// HandleAdminRunning lists the tasks with a run executing or queued on this
+// server, for spotting a runaway task. Elapsed time is measured from when a
+// worker picked the run up.
+func (h *Handlers) HandleAdminRunning(w http.ResponseWriter, r *http.Request) {
+	orch, err := h.getOrchestrator()
+	if err != nil {
+		_ = render.Render(w, r, ErrInternalServer("Failed to list running tasks", err))
+		return
+	}
+
+	type runningTask struct {
+		TaskID         string `json:"task_id"`
+		Title          string `json:"title,omitempty"`
+		RepositoryName string `json:"repository_name,omitempty"`
+		State          string `json:"state"`
+		StartedAt      int64  `json:"started_at,omitempty"`
+		ElapsedMs      int64  `json:"elapsed_ms"`
+		Backend        string `json:"backend,omitempty"`
+		ModelID        string `json:"model_id,omitempty"`
+	}
+
+	now := time.Now()
+	running := orch.RunningTasks()
+	out := make([]runningTask, 0, len(running))
+	for _, run := range running {
+		item := runningTask{
+			TaskID:  run.TaskID,
+			State:   "running",
+			Backend: run.Backend,
+			ModelID: run.ModelID,
+		}
+		if run.Queued {
+			item.State = "queued"
+		} else {
+			item.StartedAt = run.StartedAt.UnixMilli()
+			item.ElapsedMs = now.Sub(run.StartedAt).Milliseconds()
+		}
+		// The run may finish and its task be deleted while listing
+		if task, err := h.taskService.Get(r.Context(), run.TaskID); err == nil {
+			item.Title = task.Title
+			if task.RepositoryName != nil {
+				item.RepositoryName = *task.RepositoryName
+			}
+		}
+		out = append(out, item)
+	}
+	render.JSON(w, r, map[string]any{"tasks": out})
+}
+
+// HandleAdminKill cancels the run executing a task. The task fails like any
+// cancelled run and can be retried or continued afterwards.
+func (h *Handlers) HandleAdminKill(w http.ResponseWriter, r *http.Request) {
+	taskID := chi.URLParam(r, "id")
+
+	orch, err := h.getOrchestrator()
+	if err != nil {
+		_ = render.Render(w, r, ErrInternalServer("Failed to kill task", err))
+		return
+	}
+
+	if err := orch.KillTask(taskID); err != nil {
+		_ = render.Render(w, r, ErrTaskAction("Failed to kill task", err))
+		return
+	}
+	slog.Warn("Killed running task", "task_id", taskID)
+	render.JSON(w, r, map[string]string{"task_id": taskID, "status": "killed"})
+}
@@ -1,8 +1,19 @@
 package handlers
 
 import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"maps"
 	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
@@ -10,9 +21,11 @@ import (
 	"github.com/revrost/counterspell/internal/services"
 )
 
-// HandleListTask returns tasks.
+// HandleListTask returns tasks. Repeated or comma-separated project params
+// limit the feed to those projects; none means all projects.
 func (h *Handlers) HandleListTask(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
+	projects := parseListParam(r, "project")
 	tasks, err := h.taskService.ListWithRepository(ctx)
 	if err != nil {
 		slog.Error("Failed to get tasks", "error", err)
@@ -29,6 +42,9 @@ func (h *Handlers) HandleListTask(w http.ResponseWriter, r *http.Request) {
 	}
 
 	for _, t := range tasks {
+		if len(projects) > 0 && (t.RepositoryID == nil || !projects[*t.RepositoryID]) {
+			continue
+		}
 		switch t.Status {
 		case "pending", "in_progress":
 			feed.Active = append(feed.Active, t)
@@ -41,12 +57,217 @@ func (h *Handlers) HandleListTask(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Finished tasks only grow; send the newest and let the client page back
+	feed.Done, feed.DoneCursor = pageDoneTasks(feed.Done, doneCursor{}, feedDonePageSize)
+
 	if err := render.Render(w, r, feed); err != nil {
 		http.Error(w, "Failed to render response", http.StatusInternalServerError)
 		return
 	}
 }
 
+// HandleListActiveTasks returns the feed's active, planning and review rows
+// with the feed's project filter. It is the polling fallback for clients
+// whose proxies buffer or drop the SSE stream.
+func (h *Handlers) HandleListActiveTasks(w http.ResponseWriter, r *http.Request) {
+	projects := parseListParam(r, "project")
+	tasks, err := h.taskService.ListWithRepository(r.Context())
+	if err != nil {
+		slog.Error("Failed to get tasks", "error", err)
+		_ = render.Render(w, r, ErrInternalServer("Failed to load tasks", err))
+		return
+	}
+
+	rows := &ActiveRows{
+		Active:   []*models.Task{},
+		Planning: []*models.Task{},
+		Reviews:  []*models.Task{},
+	}
+	for _, t := range tasks {
+		if len(projects) > 0 && (t.RepositoryID == nil || !projects[*t.RepositoryID]) {
+			continue
+		}
+		switch t.Status {
+		case "pending", "in_progress":
+			rows.Active = append(rows.Active, t)
+		case "planning":
+			rows.Planning = append(rows.Planning, t)
+		case "review":
+			rows.Reviews = append(rows.Reviews, t)
+		}
+	}
+
+	// Polls must see fresh rows, not a proxy's cached copy
+	w.Header().Set("Cache-Control", "no-store")
+	render.JSON(w, r, rows)
+}
+
+// HandleFeedStatus returns the status and todo progress of every unfinished
+// task, keyed by task ID, with the feed's project filter. The feed calls it
+// on reconnect and while polling to reconcile its rows in one small request.
+func (h *Handlers) HandleFeedStatus(w http.ResponseWriter, r *http.Request) {
+	projects := parseListParam(r, "project")
+	progress, err := h.taskService.ListOpenTaskProgress(r.Context())
+	if err != nil {
+		_ = render.Render(w, r, ErrInternalServer("Failed to load task status", err))
+		return
+	}
+	if len(projects) > 0 {
+		maps.DeleteFunc(progress, func(_ string, p models.TaskProgress) bool {
+			return p.RepositoryID == nil || !projects[*p.RepositoryID]
+		})
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	render.JSON(w, r, progress)
+}
+
+// feedDonePageSize is how many finished tasks the feed and each page of
+// HandleListDoneTasks return.
+const feedDonePageSize = 20
+
+// HandleListDoneTasks returns the next page of finished tasks older than the
+// before cursor, with the feed's project filter.
+func (h *Handlers) HandleListDoneTasks(w http.ResponseWriter, r *http.Request) {
+	before, err := parseDoneCursor(r.URL.Query().Get("before"))
+	if err != nil {
+		_ = render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	projects := parseListParam(r, "project")
+
+	tasks, err := h.taskService.ListWithRepository(r.Context())
+	if err != nil {
+		slog.Error("Failed to get tasks", "error", err)
+		_ = render.Render(w, r, ErrInternalServer("Failed to load tasks", err))
+		return
+	}
+
+	var done []*models.Task
+	for _, t := range tasks {
+		if len(projects) > 0 && (t.RepositoryID == nil || !projects[*t.RepositoryID]) {
+			continue
+		}
+		if t.Status == "done" || t.Status == "failed" {
+			done = append(done, t)
+		}
+	}
+
+	page := &DonePage{}
+	page.Tasks, page.Cursor = pageDoneTasks(done, before, feedDonePageSize)
+	render.JSON(w, r, page)
+}
+
+// doneCursor marks the last task of a page of finished tasks. The zero value
+// starts from the newest.
+type doneCursor struct {
+	updatedAt int64
+	id        string
+}
+
+func (c doneCursor) String() string {
+	return strconv.FormatInt(c.updatedAt, 10) + ":" + c.id
+}
+
+// after reports whether t sorts after the cursor, i.e. is older.
+func (c doneCursor) after(t *models.Task) bool {
+	if c.id == "" {
+		return true
+	}
+	return t.UpdatedAt < c.updatedAt || (t.UpdatedAt == c.updatedAt && t.ID < c.id)
+}
+
+func parseDoneCursor(raw string) (doneCursor, error) {
+	if raw == "" {
+		return doneCursor{}, nil
+	}
+	ts, id, ok := strings.Cut(raw, ":")
+	updatedAt, err := strconv.ParseInt(ts, 10, 64)
+	if !ok || err != nil || id == "" {
+		return doneCursor{}, fmt.Errorf("invalid cursor %q", raw)
+	}
+	return doneCursor{updatedAt: updatedAt, id: id}, nil
+}
+
+// pageDoneTasks sorts tasks newest first and returns up to limit of those
+// after before, with the cursor for the following page ("" if none).
+func pageDoneTasks(tasks []*models.Task, before doneCursor, limit int) ([]*models.Task, string) {
+	slices.SortFunc(tasks, func(a, b *models.Task) int {
+		if a.UpdatedAt != b.UpdatedAt {
+			return cmp.Compare(b.UpdatedAt, a.UpdatedAt)
+		}
+		return strings.Compare(b.ID, a.ID)
+	})
+	page := []*models.Task{}
+	for _, t := range tasks {
+		if !before.after(t) {
+			continue
+		}
+		if len(page) == limit {
+			last := page[len(page)-1]
+			return page, doneCursor{updatedAt: last.UpdatedAt, id: last.ID}.String()
+		}
+		page = append(page, t)
+	}
+	return page, ""
+}
+
+// parseListParam collects the values of a query param given as ?key=a&key=b
+// or ?key=a,b. An empty set means no filter.
+func parseListParam(r *http.Request, key string) map[string]bool {
+	values := map[string]bool{}
+	for _, v := range r.URL.Query()[key] {
+		for item := range strings.SplitSeq(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values[item] = true
+			}
+		}
+	}
+	return values
+}
+
+// HandleListTasksFlat returns tasks as a flat JSON list for scripts and
+// external dashboards. It accepts the feed's project filter plus a status
+// filter in the same repeated or comma-separated form.
+func (h *Handlers) HandleListTasksFlat(w http.ResponseWriter, r *http.Request) {
+	projects := parseListParam(r, "project")
+	statuses := parseListParam(r, "status")
+	for status := range statuses {
+		if !services.IsValidStatus(status) {
+			_ = render.Render(w, r, ErrInvalidRequest(fmt.Errorf("unknown status %q", status)))
+			return
+		}
+	}
+
+	tasks, err := h.taskService.ListWithRepository(r.Context())
+	if err != nil {
+		_ = render.Render(w, r, ErrInternalServer("Failed to load tasks", err))
+		return
+	}
+
+	items := []TaskListItem{}
+	for _, t := range tasks {
+		if len(projects) > 0 && (t.RepositoryID == nil || !projects[*t.RepositoryID]) {
+			continue
+		}
+		if len(statuses) > 0 && !statuses[t.Status] {
+			continue
+		}
+		items = append(items, TaskListItem{
+			ID:             t.ID,
+			Title:          t.Title,
+			Status:         t.Status,
+			RepositoryID:   t.RepositoryID,
+			RepositoryName: t.RepositoryName,
+			PRURL:          t.PRURL,
+			CreatedAt:      t.CreatedAt,
+			UpdatedAt:      t.UpdatedAt,
+		})
+	}
+
+	render.JSON(w, r, items)
+}
+
 // HandleGetTask returns a single task with full details including messages and artifacts.
 func (h *Handlers) HandleGetTask(w http.ResponseWriter, r *http.Request) {
 	taskID := chi.URLParam(r, "id")
@@ -66,7 +287,10 @@ func (h *Handlers) HandleGetTask(w http.ResponseWriter, r *http.Request) {
 	render.JSON(w, r, taskResp)
 }
 
-// HandleGetTaskDiff returns the git diff for a task.
+// HandleGetTaskDiff returns the git diff for a task, raw and parsed into
+// files. The optional context param sets the lines kept around each change.
+// A diff changing more than DiffInlineMaxLines lines comes back empty, with
+// too_large set and its per-file stat instead.
 func (h *Handlers) HandleGetTaskDiff(w http.ResponseWriter, r *http.Request) {
 	taskID := chi.URLParam(r, "id")
 	if taskID == "" {
@@ -74,14 +298,304 @@ func (h *Handlers) HandleGetTaskDiff(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	gitDiff, err := h.repoManager.GetDiff(r.Context(), taskID)
+	opts, err := diffOptions(r)
+	if err != nil {
+		_ = render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	// Past the inline limit only the numstat summary is sent; the diff
+	// itself is neither loaded nor parsed
+	if h.cfg.DiffInlineMaxLines > 0 {
+		stat, err := h.taskDiffStat(r.Context(), taskID)
+		if err != nil {
+			slog.Warn("Failed to get diff stat", "task_id", taskID, "error", err)
+		} else if stat.TotalAdditions+stat.TotalDeletions > h.cfg.DiffInlineMaxLines {
+			render.JSON(w, r, map[string]any{
+				"git_diff":  "",
+				"files":     []services.DiffFile{},
+				"truncated": false,
+				"too_large": true,
+				"max_lines": h.cfg.DiffInlineMaxLines,
+				"stat":      stat,
+			})
+			return
+		}
+	}
+
+	gitDiff, truncated, err := h.taskDiff(r.Context(), taskID)
 	if err != nil {
 		slog.Error("Failed to get git diff", "task_id", taskID, "error", err)
 		http.Error(w, "Failed to get git diff", http.StatusInternalServerError)
 		return
 	}
 
-	render.JSON(w, r, map[string]string{"git_diff": gitDiff})
+	render.JSON(w, r, map[string]any{
+		"git_diff":  gitDiff,
+		"files":     services.ParseDiff(gitDiff, opts),
+		"truncated": truncated,
+	})
+}
+
+// maxDiffContext caps the context query param of HandleGetTaskDiff.
+const maxDiffContext = 50
+
+// diffOptions returns DefaultDiffOptions with the lines of context kept
+// around each change set by the optional context query param. Longer
+// unchanged runs are still collapsed.
+func diffOptions(r *http.Request) (services.DiffOptions, error) {
+	opts := services.DefaultDiffOptions
+	raw := r.URL.Query().Get("context")
+	if raw == "" {
+		return opts, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 0 || n > maxDiffContext {
+		return opts, fmt.Errorf("context must be between 0 and %d", maxDiffContext)
+	}
+	opts.Context = n
+	opts.CollapseAfter = max(opts.CollapseAfter, 2*n)
+	return opts, nil
+}
+
+// taskDiff returns a task's diff capped at MaxDiffBytes: the live workspace
+// diff, or the one saved at the end of the last run once the workspace was
+// cleaned up.
+func (h *Handlers) taskDiff(ctx context.Context, taskID string) (string, bool, error) {
+	gitDiff, err := h.repoManager.GetDiff(ctx, taskID)
+	if err != nil {
+		return "", false, err
+	}
+	if gitDiff != "" {
+		gitDiff, truncated := services.TruncateOutput(gitDiff, h.cfg.MaxDiffBytes)
+		return gitDiff, truncated, nil
+	}
+	if gitDiff, err = h.taskService.GetSavedDiff(ctx, taskID); err != nil {
+		slog.Warn("Failed to get saved diff", "task_id", taskID, "error", err)
+	}
+	artifactPath, _ := h.taskService.GetSavedDiffArtifact(ctx, taskID)
+	return gitDiff, artifactPath != "", nil
+}
+
+// HandleGetTaskDiffFull downloads the untruncated git diff of a task: the
+// live workspace diff, or the full copy kept when the saved diff was cut.
+func (h *Handlers) HandleGetTaskDiffFull(w http.ResponseWriter, r *http.Request) {
+	taskID := chi.URLParam(r, "id")
+	filename := taskID + ".patch"
+
+	gitDiff, err := h.repoManager.GetDiff(r.Context(), taskID)
+	if err != nil {
+		_ = render.Render(w, r, ErrInternalServer("Failed to get git diff", err))
+		return
+	}
+	if gitDiff == "" {
+		artifactPath, err := h.taskService.GetSavedDiffArtifact(r.Context(), taskID)
+		if err != nil {
+			_ = render.Render(w, r, ErrInternalServer("Failed to get git diff", err))
+			return
+		}
+		if artifactPath != "" {
+			serveDownloadFile(w, r, artifactPath, filename)
+			return
+		}
+		if gitDiff, err = h.taskService.GetSavedDiff(r.Context(), taskID); err != nil {
+			_ = render.Render(w, r, ErrInternalServer("Failed to get git diff", err))
+			return
+		}
+	}
+	serveDownload(w, filename, gitDiff)
+}
+
+// HandleGetTaskDiffStat returns per-file added/deleted line counts of a
+// task's diff, so a summary can be shown without loading the diff.
+func (h *Handlers) HandleGetTaskDiffStat(w http.ResponseWriter, r *http.Request) {
+	stat, err := h.taskDiffStat(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		_ = render.Render(w, r, ErrInternalServer("Failed to get diff stat", err))
+		return
+	}
+	render.JSON(w, r, stat)
+}
+
+// taskDiffStat returns the diff stat of a task's workspace, or the one saved
+// with its diff once the workspace was cleaned up. Diffs saved before stats
+// were recorded are counted, in full if they were cut, once.
+func (h *Handlers) taskDiffStat(ctx context.Context, taskID string) (*services.DiffStat, error) {
+	stat, err := h.repoManager.DiffStat(ctx, taskID)
+	if err != nil || stat != nil {
+		return stat, err
+	}
+	if stat, err = h.taskService.GetSavedDiffStat(ctx, taskID); err != nil || stat != nil {
+		return stat, err
+	}
+
+	var savedDiff string
+	artifactPath, err := h.taskService.GetSavedDiffArtifact(ctx, taskID)
+	if err == nil && artifactPath != "" {
+		var data []byte
+		data, err = os.ReadFile(artifactPath)
+		savedDiff = string(data)
+	} else if err == nil {
+		savedDiff, err = h.taskService.GetSavedDiff(ctx, taskID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	stat = services.DiffStatFromPatch(savedDiff)
+	if savedDiff != "" {
+		if err := h.taskService.SetSavedDiffStat(ctx, taskID, stat); err != nil {
+			slog.Warn("Failed to record diff stat", "task_id", taskID, "error", err)
+		}
+	}
+	return stat, nil
+}
+
+// HandleGetMessageFull downloads the full content of a message whose stored
+// content was truncated.
+func (h *Handlers) HandleGetMessageFull(w http.ResponseWriter, r *http.Request) {
+	taskID := chi.URLParam(r, "id")
+	messageID := chi.URLParam(r, "messageID")
+
+	msg, err := h.taskService.GetMessage(r.Context(), messageID)
+	if err != nil || msg.TaskID != taskID {
+		_ = render.Render(w, r, ErrNotFound("Message not found"))
+		return
+	}
+	filename := messageID + ".txt"
+	if msg.ArtifactPath.Valid {
+		serveDownloadFile(w, r, msg.ArtifactPath.String, filename)
+		return
+	}
+	serveDownload(w, filename, msg.Content)
+}
+
+// HandleListTaskArtifacts lists the files the task's runs handed back, every
+// version of each, without their content.
+func (h *Handlers) HandleListTaskArtifacts(w http.ResponseWriter, r *http.Request) {
+	taskID := chi.URLParam(r, "id")
+
+	artifacts, err := h.taskService.ListArtifacts(r.Context(), taskID)
+	if err != nil {
+		_ = render.Render(w, r, ErrInternalServer("Failed to list artifacts", err))
+		return
+	}
+
+	render.JSON(w, r, map[string]any{"artifacts": artifacts})
+}
+
+// HandleDownloadArtifact downloads one version of a task's artifact.
+func (h *Handlers) HandleDownloadArtifact(w http.ResponseWriter, r *http.Request) {
+	taskID := chi.URLParam(r, "id")
+	artifactID := chi.URLParam(r, "artifactID")
+
+	artifact, err := h.taskService.GetArtifact(r.Context(), taskID, artifactID)
+	if err != nil {
+		_ = render.Render(w, r, ErrInternalServer("Failed to get artifact", err))
+		return
+	}
+	if artifact == nil {
+		_ = render.Render(w, r, ErrNotFound("Artifact not found"))
+		return
+	}
+	serveDownload(w, filepath.Base(artifact.Path), artifact.Content)
+}
+
+// serveDownload writes content as a plain-text attachment.
+func serveDownload(w http.ResponseWriter, filename, content string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	_, _ = w.Write([]byte(content))
+}
+
+// serveDownloadFile serves the file at path as a plain-text attachment.
+func serveDownloadFile(w http.ResponseWriter, r *http.Request, path, filename string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	http.ServeFile(w, r, path)
+}
+
+// HandleGetTaskTodos returns the latest todo list reported by the task's agent.
+func (h *Handlers) HandleGetTaskTodos(w http.ResponseWriter, r *http.Request) {
+	taskID := chi.URLParam(r, "id")
+	if taskID == "" {
+		http.Error(w, "Task ID required", http.StatusBadRequest)
+		return
+	}
+
+	todos, err := h.taskService.GetTodos(r.Context(), taskID)
+	if err != nil {
+		_ = render.Render(w, r, ErrInternalServer("Failed to get todos", err))
+		return
+	}
+
+	render.JSON(w, r, map[string]any{"todos": todos})
+}
+
+// HandleGetTaskLogs returns the full activity log history for a task.
+func (h *Handlers) HandleGetTaskLogs(w http.ResponseWriter, r *http.Request) {
+	taskID := chi.URLParam(r, "id")
+	if taskID == "" {
+		http.Error(w, "Task ID required", http.StatusBadRequest)
+		return
+	}
+
+	logs, err := h.taskService.GetLogs(r.Context(), taskID)
+	if err != nil {
+		_ = render.Render(w, r, ErrInternalServer("Failed to get logs", err))
+		return
+	}
+
+	render.JSON(w, r, map[string]any{"logs": logs})
+}
+
+// HandleGetTaskRawLog downloads the unparsed stdout/stderr of a CLI backend
+// run. ?run=<id> selects the run; the latest run is used by default.
+func (h *Handlers) HandleGetTaskRawLog(w http.ResponseWriter, r *http.Request) {
+	taskID := chi.URLParam(r, "id")
+	runID := r.URL.Query().Get("run")
+
+	orch, err := h.getOrchestrator()
+	if err != nil {
+		_ = render.Render(w, r, ErrInternalServer("Failed to get raw log", err))
+		return
+	}
+
+	path, err := orch.RawLogPath(r.Context(), taskID, runID)
+	if errors.Is(err, os.ErrNotExist) {
+		_ = render.Render(w, r, ErrNotFound("No raw log for this run"))
+		return
+	}
+	if err != nil {
+		_ = render.Render(w, r, ErrInternalServer("Failed to get raw log", err))
+		return
+	}
+
+	serveDownloadFile(w, r, path, filepath.Base(path))
+}
+
+// HandleGetRunPrompt returns the system prompt the agent was given for one
+// run of a task.
+func (h *Handlers) HandleGetRunPrompt(w http.ResponseWriter, r *http.Request) {
+	taskID := chi.URLParam(r, "id")
+	runID := chi.URLParam(r, "runID")
+
+	orch, err := h.getOrchestrator()
+	if err != nil {
+		_ = render.Render(w, r, ErrInternalServer("Failed to get system prompt", err))
+		return
+	}
+
+	prompt, err := orch.GetRunSystemPrompt(r.Context(), taskID, runID)
+	if errors.Is(err, os.ErrNotExist) {
+		_ = render.Render(w, r, ErrNotFound("No system prompt recorded for this run"))
+		return
+	}
+	if err != nil {
+		_ = render.Render(w, r, ErrInternalServer("Failed to get system prompt", err))
+		return
+	}
+
+	render.JSON(w, r, map[string]string{"run_id": runID, "system_prompt": prompt})
 }
 
 // HandleGetSession returns session info based on machine auth status.
@@ -117,12 +631,33 @@ func (h *Handlers) HandleGetSession(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// HandleFileSearch searches files.
+// HandleFileSearch searches files. With project_id it fuzzy-matches paths in
+// the project's repo and returns them as strings; otherwise it searches file
+// names under directory (default: the data dir).
 func (h *Handlers) HandleFileSearch(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query().Get("q")
 	directory := r.URL.Query().Get("directory")
 
 	ctx := r.Context()
+	if projectID := r.URL.Query().Get("project_id"); projectID != "" {
+		orch, err := h.getOrchestrator()
+		if err != nil {
+			_ = render.Render(w, r, ErrInternalServer("Failed to search files", err))
+			return
+		}
+		paths, err := orch.SearchProjectFiles(ctx, projectID, query, 20)
+		if err != nil {
+			slog.Error("Failed to search project files", "error", err)
+			_ = render.Render(w, r, ErrInternalServer("Failed to search files", err))
+			return
+		}
+		if paths == nil {
+			paths = []string{}
+		}
+		render.JSON(w, r, paths)
+		return
+	}
+
 	files, err := h.fileService.Search(ctx, query, directory, 50)
 	if err != nil {
 		slog.Error("Failed to search files", "error", err)
@@ -164,6 +699,143 @@ func (h *Handlers) HandleSaveSettings(w http.ResponseWriter, r *http.Request) {
 	render.JSON(w, r, map[string]string{"status": "ok"})
 }
 
+// HandleDebugDeps re-runs the startup dependency self-check so operators can
+// verify the environment without running a task.
+func (h *Handlers) HandleDebugDeps(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	report := services.CheckDependencies(ctx, h.settingsService.AgentBackend(ctx))
+	if !report.OK {
+		render.Status(r, http.StatusServiceUnavailable)
+	}
+	render.JSON(w, r, report)
+}
+
+// debugEventPayloadBytes is the default payload size shown by
+// HandleDebugTaskEvents; the payload_bytes query param overrides it.
+const debugEventPayloadBytes = 512
+
+// HandleDebugTaskEvents returns the events still buffered for a task, oldest
+// first, with payloads truncated. It shows what SSE clients were (or would be
+// on reconnect) sent, for debugging the live view.
+func (h *Handlers) HandleDebugTaskEvents(w http.ResponseWriter, r *http.Request) {
+	limit := debugEventPayloadBytes
+	if raw := r.URL.Query().Get("payload_bytes"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			_ = render.Render(w, r, ErrInvalidRequest(errors.New("payload_bytes must be a non-negative integer")))
+			return
+		}
+		limit = n
+	}
+
+	type debugEvent struct {
+		ID        int64  `json:"id"`
+		Type      string `json:"type"`
+		CreatedAt int64  `json:"created_at"`
+		Data      string `json:"data"`
+		Bytes     int    `json:"bytes"`
+		Truncated bool   `json:"truncated"`
+	}
+	events := h.events.TaskEvents(chi.URLParam(r, "id"))
+	out := make([]debugEvent, 0, len(events))
+	for _, e := range events {
+		data, truncated := services.TruncateOutput(e.Data, limit)
+		out = append(out, debugEvent{
+			ID:        e.ID,
+			Type:      e.Type,
+			CreatedAt: e.CreatedAt.UnixMilli(),
+			Data:      data,
+			Bytes:     len(e.Data),
+			Truncated: truncated,
+		})
+	}
+	render.JSON(w, r, map[string]any{"events": out})
+}
+
+// HandleGetStats reports server resource usage and the result processors' lag.
+func (h *Handlers) HandleGetStats(w http.ResponseWriter, r *http.Request) {
+	usage, err := services.GetDataDirUsage(h.cfg.DataDir)
+	if err != nil {
+		_ = render.Render(w, r, ErrInternalServer("Failed to measure data dir", err))
+		return
+	}
+	stats := map[string]any{
+		"data_dir":         usage,
+		"max_repo_size_mb": h.cfg.MaxRepoSizeMB,
+		"isolation_mode":   h.cfg.IsolationMode,
+	}
+	if orch, err := h.getOrchestrator(); err == nil {
+		stats["result_processors"] = orch.ResultProcessorStats()
+	}
+	render.JSON(w, r, stats)
+}
+
+// HandleGetCostStats sums agent run cost and tokens over a time window.
+// Query params: window ("30d", "12h"; default 30d) and group_by ("project"
+// or "model"; default project).
+func (h *Handlers) HandleGetCostStats(w http.ResponseWriter, r *http.Request) {
+	window := 30 * 24 * time.Hour
+	if v := r.URL.Query().Get("window"); v != "" {
+		d, err := parseWindow(v)
+		if err != nil {
+			_ = render.Render(w, r, ErrInvalidRequest(err))
+			return
+		}
+		window = d
+	}
+	groupBy := r.URL.Query().Get("group_by")
+	if groupBy == "" {
+		groupBy = services.CostGroupProject
+	}
+	if groupBy != services.CostGroupProject && groupBy != services.CostGroupModel {
+		_ = render.Render(w, r, ErrInvalidRequest(fmt.Errorf("group_by must be %q or %q", services.CostGroupProject, services.CostGroupModel)))
+		return
+	}
+
+	since := time.Now().Add(-window)
+	buckets, err := h.taskService.CostSummary(r.Context(), since, groupBy)
+	if err != nil {
+		_ = render.Render(w, r, ErrInternalServer("Failed to sum cost", err))
+		return
+	}
+
+	var total services.CostBucket
+	for _, b := range buckets {
+		total.RunCount += b.RunCount
+		total.Cost += b.Cost
+		total.PromptTokens += b.PromptTokens
+		total.CompletionTokens += b.CompletionTokens
+	}
+	render.JSON(w, r, map[string]any{
+		"since":    since.UnixMilli(),
+		"group_by": groupBy,
+		"groups":   buckets,
+		"total": map[string]any{
+			"run_count":         total.RunCount,
+			"cost":              total.Cost,
+			"prompt_tokens":     total.PromptTokens,
+			"completion_tokens": total.CompletionTokens,
+		},
+	})
+}
+
+// parseWindow parses a lookback window: a number of days ("30d") or a Go
+// duration ("12h").
+func parseWindow(v string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(v, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid window: %s", v)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid window: %s", v)
+	}
+	return d, nil
+}
+
 // HandleTranscribe handles transcription.
 func (h *Handlers) HandleTranscribe(w http.ResponseWriter, r *http.Request) {
 	// Placeholder
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/revrost/counterspell/internal/config"
+	"github.com/revrost/counterspell/internal/db"
+	"github.com/revrost/counterspell/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleGetTaskDiff_TooLargeFromSavedStat(t *testing.T) {
+	ctx := context.Background()
+	testDB, err := db.Connect(ctx, ":memory:")
+	require.NoError(t, err)
+	defer testDB.Close()
+	require.NoError(t, testDB.RunMigrations(ctx))
+
+	repo := services.NewRepository(testDB)
+	h := &Handlers{
+		taskService: repo,
+		repoManager: services.NewGitManager(t.TempDir(), t.TempDir()),
+		cfg:         &config.Config{DiffInlineMaxLines: 5},
+	}
+	r := chi.NewRouter()
+	r.Get("/api/v1/tasks/{id}/diff", h.HandleGetTaskDiff)
+	getDiff := func(taskID string) map[string]any {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/tasks/"+taskID+"/diff", nil))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var resp map[string]any
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return resp
+	}
+
+	// The workspace is gone and the full diff's file with it: the check only
+	// needs the saved numstat
+	large, err := repo.Create(ctx, "", "big change")
+	require.NoError(t, err)
+	stat := &services.DiffStat{
+		Files:          []services.FileStat{{Path: "gen.go", Additions: 9}},
+		TotalAdditions: 9,
+	}
+	require.NoError(t, repo.SaveDiff(ctx, large.ID, "diff --git a/gen.go b/gen.go\n", filepath.Join(t.TempDir(), "missing.patch"), stat))
+	resp := getDiff(large.ID)
+	assert.Equal(t, true, resp["too_large"])
+	assert.Equal(t, float64(9), resp["stat"].(map[string]any)["total_additions"])
+
+	// Diffs saved before stats were recorded are counted, once
+	legacy, err := repo.Create(ctx, "", "small change")
+	require.NoError(t, err)
+	patch := strings.Join([]string{
+		"diff --git a/x.go b/x.go",
+		"--- a/x.go",
+		"+++ b/x.go",
+		"@@ -1,1 +1,2 @@",
+		" package x",
+		"+var y = 1",
+		"",
+	}, "\n")
+	require.NoError(t, repo.SaveDiff(ctx, legacy.ID, patch, "", nil))
+	resp = getDiff(legacy.ID)
+	assert.Nil(t, resp["too_large"])
+	assert.Equal(t, patch, resp["git_diff"])
+	saved, err := repo.GetSavedDiffStat(ctx, legacy.ID)
+	require.NoError(t, err)
+	require.NotNil(t, saved)
+	assert.Equal(t, 1, saved.TotalAdditions)
+}
@@ -1,10 +1,15 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"log/slog"
 	"net/http"
+	"strings"
 
 	"github.com/go-chi/render"
+	"github.com/revrost/counterspell/internal/auth"
+	"github.com/revrost/counterspell/internal/services"
 )
 
 // HandleAuthLogin starts the browser OAuth flow via the Invoker control plane.
@@ -27,9 +32,28 @@ func (h *Handlers) HandleAuthLogin(w http.ResponseWriter, r *http.Request) {
 }
 
 // RequireMachineAuth blocks API access until the machine is authenticated.
+// Scripts and CI may instead send an API token as "Authorization: Bearer
+// cs_..."; a bad token is rejected rather than falling back to the machine's
+// login. Other bearer values are left to the machine check.
 func (h *Handlers) RequireMachineAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
+		if secret, ok := apiTokenFromRequest(r); ok {
+			token, err := h.taskService.ResolveAPIToken(ctx, secret)
+			if errors.Is(err, services.ErrAPITokenInvalid) {
+				_ = render.Render(w, r, ErrUnauthorized("Invalid API token"))
+				return
+			}
+			if err != nil {
+				slog.Error("API token check failed", "error", err)
+				_ = render.Render(w, r, ErrInternalServer("Authentication failed", err))
+				return
+			}
+			ctx = context.WithValue(ctx, auth.UserIDKey, token.UserID)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, auth.APITokenIDKey, token.ID)))
+			return
+		}
+
 		authenticated, _, err := h.oauthService.IsAuthenticated(ctx)
 		if err != nil {
 			slog.Error("Auth check failed", "error", err)
@@ -40,6 +64,35 @@ func (h *Handlers) RequireMachineAuth(next http.Handler) http.Handler {
 			_ = render.Render(w, r, ErrUnauthorized("Authentication required"))
 			return
 		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, auth.UserIDKey, defaultUserID)))
+	})
+}
+
+// RequireOperator restricts a route to the machine's own login, after
+// RequireMachineAuth. API tokens are refused with 403: they are handed to
+// scripts and CI, which must not see or stop other tasks' runs, mint or
+// revoke tokens, or link Slack users to the account.
+func (h *Handlers) RequireOperator(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if tokenID := auth.APITokenIDFromContext(r.Context()); tokenID != "" {
+			slog.Warn("Refused API token on operator route", "token_id", tokenID, "path", r.URL.Path)
+			_ = render.Render(w, r, ErrForbidden("API tokens can't use operator endpoints"))
+			return
+		}
 		next.ServeHTTP(w, r)
 	})
 }
+
+// defaultUserID owns everything in local-first, single-user mode.
+const defaultUserID = "default"
+
+// apiTokenFromRequest returns the API token in the Authorization header, if
+// the header carries one.
+func apiTokenFromRequest(r *http.Request) (string, bool) {
+	scheme, secret, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	secret = strings.TrimSpace(secret)
+	return secret, strings.HasPrefix(secret, services.APITokenPrefix)
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/revrost/counterspell/internal/auth"
+	"github.com/revrost/counterspell/internal/db"
+	"github.com/revrost/counterspell/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequireOperatorRefusesAPITokens(t *testing.T) {
+	ctx := context.Background()
+	testDB, err := db.Connect(ctx, ":memory:")
+	require.NoError(t, err)
+	defer testDB.Close()
+	require.NoError(t, testDB.RunMigrations(ctx))
+
+	h := &Handlers{taskService: services.NewRepository(testDB)}
+	token, err := h.taskService.CreateAPIToken(ctx, defaultUserID, "ci")
+	require.NoError(t, err)
+
+	reached := false
+	r := chi.NewRouter()
+	r.Use(h.RequireMachineAuth, h.RequireOperator)
+	r.Get("/admin/running", func(w http.ResponseWriter, r *http.Request) { reached = true })
+	r.Post("/admin/kill/{id}", func(w http.ResponseWriter, r *http.Request) { reached = true })
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/admin/running", nil),
+		httptest.NewRequest(http.MethodPost, "/admin/kill/task-1", nil),
+	} {
+		req.Header.Set("Authorization", "Bearer "+token.Token)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusForbidden, rec.Code, req.URL.Path)
+		assert.Contains(t, rec.Body.String(), "API tokens")
+	}
+	assert.False(t, reached)
+
+	// The machine's own login passes
+	req := httptest.NewRequest(http.MethodGet, "/admin/running", nil)
+	req = req.WithContext(context.WithValue(req.Context(), auth.UserIDKey, defaultUserID))
+	rec := httptest.NewRecorder()
+	h.RequireOperator(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { reached = true })).ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, reached)
+}
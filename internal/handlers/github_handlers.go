@@ -1,12 +1,18 @@
 package handlers
 
 import (
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"net/url"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
+	"github.com/revrost/counterspell/internal/services"
 )
 
 // HandleGitHubLogin redirects to GitHub OAuth.
@@ -25,7 +31,8 @@ func (h *Handlers) HandleGitHubLogin(w http.ResponseWriter, r *http.Request) {
 	callbackURL := fmt.Sprintf("%s://%s/api/v1/github/callback", scheme, r.Host)
 
 	scope := "repo,user"
-	githubURL := fmt.Sprintf("https://github.com/login/oauth/authorize?client_id=%s&scope=%s&redirect_uri=%s&state=%s",
+	githubURL := fmt.Sprintf("%s/login/oauth/authorize?client_id=%s&scope=%s&redirect_uri=%s&state=%s",
+		h.githubService.WebURL(),
 		h.cfg.GitHubClientID,
 		scope,
 		url.QueryEscape(callbackURL),
@@ -87,3 +94,242 @@ func (h *Handlers) HandleGitHubRepos(w http.ResponseWriter, r *http.Request) {
 
 	render.JSON(w, r, repos)
 }
+
+// HandleCreateProject registers the GitHub repository owner/repo from the
+// JSON body as a project, after checking the connected account can read it.
+func (h *Handlers) HandleCreateProject(w http.ResponseWriter, r *http.Request) {
+	var req ActivateProjectRequest
+	if err := render.Bind(r, &req); err != nil {
+		_ = render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	repo, err := h.githubService.RegisterRepo(r.Context(), req.Owner, req.Repo)
+	if errors.Is(err, sql.ErrNoRows) {
+		_ = render.Render(w, r, ErrInvalidRequest(errors.New("connect GitHub first")))
+		return
+	}
+	if errors.Is(err, services.ErrRepoNotAccessible) {
+		_ = render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	if err != nil {
+		_ = render.Render(w, r, ErrInternalServer("Failed to register project", err))
+		return
+	}
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, repo)
+}
+
+// HandleDeleteProject removes a project. Its tasks are kept.
+func (h *Handlers) HandleDeleteProject(w http.ResponseWriter, r *http.Request) {
+	err := h.githubService.DeleteRepo(r.Context(), chi.URLParam(r, "id"))
+	if errors.Is(err, sql.ErrNoRows) {
+		_ = render.Render(w, r, ErrNotFound("Project not found"))
+		return
+	}
+	if err != nil {
+		_ = render.Render(w, r, ErrInternalServer("Failed to delete project", err))
+		return
+	}
+	render.JSON(w, r, map[string]string{"status": "ok"})
+}
+
+// HandleListIssueWatches returns the IDs of projects with issue polling enabled.
+func (h *Handlers) HandleListIssueWatches(w http.ResponseWriter, r *http.Request) {
+	repos, err := h.taskService.ListWatchedRepositories(r.Context())
+	if err != nil {
+		_ = render.Render(w, r, ErrInternalServer("Failed to list issue watches", err))
+		return
+	}
+
+	ids := make([]string, 0, len(repos))
+	for _, repo := range repos {
+		ids = append(ids, repo.ID)
+	}
+	render.JSON(w, r, map[string]any{"project_ids": ids})
+}
+
+// HandleSetIssueWatch turns issue polling for a project on or off.
+func (h *Handlers) HandleSetIssueWatch(w http.ResponseWriter, r *http.Request) {
+	projectID := chi.URLParam(r, "id")
+
+	var req struct {
+		Enabled bool `json:"enabled"`
+	}
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		_ = render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	if err := h.taskService.SetIssueWatch(r.Context(), projectID, req.Enabled); err != nil {
+		_ = render.Render(w, r, ErrInternalServer("Failed to update issue watch", err))
+		return
+	}
+	render.JSON(w, r, map[string]any{"status": "ok", "enabled": req.Enabled})
+}
+
+// HandleListCommitStatuses returns the IDs of projects with commit statuses
+// enabled.
+func (h *Handlers) HandleListCommitStatuses(w http.ResponseWriter, r *http.Request) {
+	ids, err := h.taskService.ListCommitStatusProjects(r.Context())
+	if err != nil {
+		_ = render.Render(w, r, ErrInternalServer("Failed to list commit status projects", err))
+		return
+	}
+	if ids == nil {
+		ids = []string{}
+	}
+	render.JSON(w, r, map[string]any{"project_ids": ids})
+}
+
+// HandleSetCommitStatuses turns GitHub commit statuses for a project's agent
+// runs on or off.
+func (h *Handlers) HandleSetCommitStatuses(w http.ResponseWriter, r *http.Request) {
+	projectID := chi.URLParam(r, "id")
+
+	var req struct {
+		Enabled bool `json:"enabled"`
+	}
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		_ = render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	if err := h.taskService.SetCommitStatuses(r.Context(), projectID, req.Enabled); err != nil {
+		_ = render.Render(w, r, ErrInternalServer("Failed to update commit statuses", err))
+		return
+	}
+	render.JSON(w, r, map[string]any{"status": "ok", "enabled": req.Enabled})
+}
+
+// HandleListCommitSigning returns the IDs of projects whose agent commits are
+// signed.
+func (h *Handlers) HandleListCommitSigning(w http.ResponseWriter, r *http.Request) {
+	ids, err := h.taskService.ListCommitSigningProjects(r.Context())
+	if err != nil {
+		_ = render.Render(w, r, ErrInternalServer("Failed to list commit signing projects", err))
+		return
+	}
+	if ids == nil {
+		ids = []string{}
+	}
+	render.JSON(w, r, map[string]any{"project_ids": ids})
+}
+
+// HandleSetCommitSigning turns signing of a project's agent commits on or
+// off. The key itself is server config (GIT_SIGNING_KEY).
+func (h *Handlers) HandleSetCommitSigning(w http.ResponseWriter, r *http.Request) {
+	projectID := chi.URLParam(r, "id")
+
+	var req struct {
+		Enabled bool `json:"enabled"`
+	}
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		_ = render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	if err := h.taskService.SetCommitSigning(r.Context(), projectID, req.Enabled); err != nil {
+		_ = render.Render(w, r, ErrInternalServer("Failed to update commit signing", err))
+		return
+	}
+	render.JSON(w, r, map[string]any{"status": "ok", "enabled": req.Enabled})
+}
+
+// HandleListAutoMerge returns the IDs of projects whose task pull requests
+// are merged once their checks pass.
+func (h *Handlers) HandleListAutoMerge(w http.ResponseWriter, r *http.Request) {
+	ids, err := h.taskService.ListAutoMergeProjects(r.Context())
+	if err != nil {
+		_ = render.Render(w, r, ErrInternalServer("Failed to list auto-merge projects", err))
+		return
+	}
+	if ids == nil {
+		ids = []string{}
+	}
+	render.JSON(w, r, map[string]any{"project_ids": ids})
+}
+
+// HandleSetAutoMerge turns auto-merge of a project's task pull requests on
+// or off. It only takes effect with the GitHub webhook configured to send
+// check_suite events.
+func (h *Handlers) HandleSetAutoMerge(w http.ResponseWriter, r *http.Request) {
+	projectID := chi.URLParam(r, "id")
+
+	var req struct {
+		Enabled bool `json:"enabled"`
+	}
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		_ = render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	if err := h.taskService.SetAutoMerge(r.Context(), projectID, req.Enabled); err != nil {
+		_ = render.Render(w, r, ErrInternalServer("Failed to update auto-merge", err))
+		return
+	}
+	render.JSON(w, r, map[string]any{"status": "ok", "enabled": req.Enabled})
+}
+
+// maxWebhookBytes is GitHub's own cap on webhook payloads.
+const maxWebhookBytes = 25 << 20
+
+// HandleGitHubWebhook receives GitHub webhook deliveries, signed with
+// GITHUB_WEBHOOK_SECRET. pull_request events for task branches update their
+// task, and passing check_suite events merge the task's PR in projects with
+// auto-merge enabled; other events are acknowledged and ignored.
+func (h *Handlers) HandleGitHubWebhook(w http.ResponseWriter, r *http.Request) {
+	if h.cfg.GitHubWebhookSecret == "" {
+		_ = render.Render(w, r, ErrNotFound("Webhook not configured"))
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBytes))
+	if err != nil {
+		_ = render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	if err := services.VerifyGitHubWebhook(h.cfg.GitHubWebhookSecret, body, r.Header.Get("X-Hub-Signature-256")); err != nil {
+		slog.Warn("Rejected GitHub webhook", "delivery", r.Header.Get("X-GitHub-Delivery"), "error", err)
+		_ = render.Render(w, r, ErrUnauthorized(err.Error()))
+		return
+	}
+	eventType := r.Header.Get("X-GitHub-Event")
+	if eventType != "pull_request" && eventType != "check_suite" {
+		render.JSON(w, r, map[string]string{"status": "ignored"})
+		return
+	}
+	orch, err := h.getOrchestrator()
+	if err != nil {
+		_ = render.Render(w, r, ErrInternalServer("Failed to handle webhook", err))
+		return
+	}
+
+	var taskID string
+	if eventType == "check_suite" {
+		var event services.CheckSuiteEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			_ = render.Render(w, r, ErrInvalidRequest(err))
+			return
+		}
+		if taskID, err = orch.AutoMergePullRequest(r.Context(), event); err != nil {
+			_ = render.Render(w, r, ErrInternalServer("Failed to auto-merge pull request", err))
+			return
+		}
+	} else {
+		var event services.PullRequestEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			_ = render.Render(w, r, ErrInvalidRequest(err))
+			return
+		}
+		if taskID, err = orch.SyncPullRequest(r.Context(), event); err != nil {
+			_ = render.Render(w, r, ErrInternalServer("Failed to sync pull request", err))
+			return
+		}
+	}
+	if taskID == "" {
+		render.JSON(w, r, map[string]string{"status": "ignored"})
+		return
+	}
+	render.JSON(w, r, map[string]string{"status": "ok", "task_id": taskID})
+}
@@ -1,9 +1,12 @@
 package handlers
 
 import (
+	"context"
+	"fmt"
 	"log/slog"
 	"sync"
 
+	"github.com/revrost/counterspell/internal/agent"
 	"github.com/revrost/counterspell/internal/config"
 	"github.com/revrost/counterspell/internal/db"
 	"github.com/revrost/counterspell/internal/services"
@@ -23,6 +26,9 @@ type Handlers struct {
 	githubService   *services.GitHubService
 	oauthService    *services.OAuthService
 	repoManager     services.RepoManager
+	issuePoller     *services.IssuePoller
+	slack           *services.SlackIntegration
+	taskRateLimit   *services.RateLimiter // nil when task creation is unlimited
 
 	// Track active orchestrators for shutdown
 	orchestrators map[string]*services.Orchestrator
@@ -34,8 +40,20 @@ func NewHandlers(database *db.DB, events *services.EventBus, cfg *config.Config)
 	transcriptionService := services.NewTranscriptionService()
 	repo := services.NewRepository(database)
 	settingsService := services.NewSettingsService(database)
+	githubService := services.NewGitHubService(database, cfg.GitHubClientID, cfg.GitHubClientSecret,
+		services.WithGitHubHost(cfg.GitHubHost, cfg.GitHubAPIURL))
 
-	repoManager, err := services.NewRepoManager(cfg.DataDir)
+	isolation, err := services.ParseIsolationMode(cfg.IsolationMode)
+	if err != nil {
+		return nil, err
+	}
+	repoManager, err := services.NewRepoManager(cfg.DataDir, services.RepoOptions{
+		Isolation:     isolation,
+		MaxCloneSize:  cfg.MaxRepoSizeMB << 20,
+		SSHKeyPath:    cfg.GitSSHKeyPath,
+		SigningKey:    cfg.GitSigningKey,
+		SigningFormat: cfg.GitSigningFormat,
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -50,9 +68,10 @@ func NewHandlers(database *db.DB, events *services.EventBus, cfg *config.Config)
 		sessionService:  services.NewSessionService(repo, settingsService, cfg.DataDir),
 		settingsService: settingsService,
 		fileService:     services.NewFileService(cfg.DataDir),
-		githubService:   services.NewGitHubService(database, cfg.GitHubClientID, cfg.GitHubClientSecret),
+		githubService:   githubService,
 		oauthService:    services.NewOAuthService(database, cfg),
 		repoManager:     repoManager,
+		taskRateLimit:   services.NewRateLimiter(cfg.TaskRateLimit, cfg.TaskRateWindow),
 
 		// Initialize orchestrator tracking
 		orchestrators: make(map[string]*services.Orchestrator),
@@ -71,6 +90,32 @@ func (h *Handlers) getOrchestrator() (*services.Orchestrator, error) {
 		return orch, nil
 	}
 
+	opts := []services.OrchestratorOption{
+		services.WithLLMTimeouts(agent.LLMTimeouts{
+			Request:    h.cfg.LLMRequestTimeout,
+			StreamIdle: h.cfg.LLMStreamIdleTimeout,
+		}),
+		services.WithRawLogDir(h.cfg.RawLogsPath(), h.cfg.RawLogMaxBytes),
+		services.WithToolTimeout(h.cfg.ToolTimeout),
+		services.WithResultProcessors(h.cfg.ResultProcessors),
+		services.WithOutputLimits(services.OutputLimits{
+			Message: h.cfg.MaxMessageBytes,
+			Diff:    h.cfg.MaxDiffBytes,
+		}, h.cfg.OutputsPath()),
+		services.WithDiffInlineMaxLines(h.cfg.DiffInlineMaxLines),
+	}
+	if h.cfg.IsolationMode == string(services.IsolationContainer) {
+		opts = append(opts, services.WithContainerIsolation(h.cfg.ContainerImage))
+	}
+	if h.cfg.WorkspaceRetentionDays > 0 {
+		opts = append(opts, services.WithWorkspaceRetention(h.cfg.WorkspaceRetention()))
+	}
+	promptPrefix, err := h.cfg.PromptPrefix()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prompt prefix: %w", err)
+	}
+	opts = append(opts, services.WithPromptPrefix(promptPrefix), services.WithProtectedPaths(h.cfg.ProtectedPaths))
+
 	// Create the shared orchestrator
 	orch, err := services.NewOrchestrator(
 		h.taskService,
@@ -78,6 +123,7 @@ func (h *Handlers) getOrchestrator() (*services.Orchestrator, error) {
 		h.settingsService,
 		h.githubService,
 		h.repoManager,
+		opts...,
 	)
 	if err != nil {
 		return nil, err
@@ -87,8 +133,51 @@ func (h *Handlers) getOrchestrator() (*services.Orchestrator, error) {
 	return orch, nil
 }
 
-// Shutdown gracefully shuts down all active orchestrators.
+// StartIssuePolling starts turning labeled GitHub issues of watched projects
+// into tasks. It is a no-op when ISSUE_POLL_INTERVAL is 0.
+func (h *Handlers) StartIssuePolling(ctx context.Context) error {
+	if h.cfg.IssuePollInterval <= 0 {
+		return nil
+	}
+	orch, err := h.getOrchestrator()
+	if err != nil {
+		return err
+	}
+	h.issuePoller = services.NewIssuePoller(h.taskService, h.githubService, orch, services.IssuePollerOptions{
+		Interval:       h.cfg.IssuePollInterval,
+		Label:          h.cfg.IssueLabel,
+		MaxActiveTasks: h.cfg.MaxTasksPerUser,
+	})
+	h.issuePoller.Start(ctx)
+	return nil
+}
+
+// StartSlack enables the /counterspell slash command and starts reporting
+// the progress of tasks started with it. It is a no-op when
+// SLACK_SIGNING_SECRET is unset.
+func (h *Handlers) StartSlack(ctx context.Context) error {
+	if h.cfg.SlackSigningSecret == "" {
+		return nil
+	}
+	orch, err := h.getOrchestrator()
+	if err != nil {
+		return err
+	}
+	h.slack = services.NewSlackIntegration(h.taskService, orch, services.SlackOptions{})
+	h.slack.Start(ctx)
+	return nil
+}
+
+// Shutdown gracefully shuts down the issue poller, the Slack integration and
+// all active orchestrators.
 func (h *Handlers) Shutdown() {
+	if h.issuePoller != nil {
+		h.issuePoller.Shutdown()
+	}
+	if h.slack != nil {
+		h.slack.Shutdown()
+	}
+
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
@@ -6,6 +6,7 @@ import (
 	"net/http"
 
 	"github.com/go-chi/render"
+	"github.com/revrost/counterspell/internal/llm"
 	"github.com/revrost/counterspell/internal/models"
 	"github.com/revrost/counterspell/internal/services"
 )
@@ -63,7 +64,7 @@ func (rc *ResolveConflictRequest) Bind(r *http.Request) error {
 	return nil
 }
 
-// ActivateProjectRequest is the request payload for activating a project.
+// ActivateProjectRequest is the request payload for registering a project.
 type ActivateProjectRequest struct {
 	Owner string `json:"owner"`
 	Repo  string `json:"repo"`
@@ -122,12 +123,41 @@ type FeedData struct {
 	Done     []*models.Task `json:"done"`
 	Todo     []*models.Task `json:"todo"`
 	Planning []*models.Task `json:"planning"`
+	// DoneCursor fetches the next page of Done from /api/v1/tasks/done; empty
+	// when Done holds every finished task.
+	DoneCursor string `json:"done_cursor,omitempty"`
+}
+
+// DonePage is one page of finished tasks, most recently updated first.
+type DonePage struct {
+	Tasks  []*models.Task `json:"tasks"`
+	Cursor string         `json:"cursor,omitempty"` // empty on the last page
+}
+
+// ActiveRows are the feed's rows that change while tasks run, for clients
+// polling because SSE doesn't get through to them.
+type ActiveRows struct {
+	Active   []*models.Task `json:"active"`
+	Planning []*models.Task `json:"planning"`
+	Reviews  []*models.Task `json:"reviews"`
 }
 
 func (f *FeedData) Render(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
+// TaskListItem is one task in the flat JSON listing for external tools.
+type TaskListItem struct {
+	ID             string  `json:"id"`
+	Title          string  `json:"title"`
+	Status         string  `json:"status"`
+	RepositoryID   *string `json:"repository_id,omitempty"`
+	RepositoryName *string `json:"repository_name,omitempty"`
+	PRURL          *string `json:"pr_url,omitempty"`
+	CreatedAt      int64   `json:"created_at"`
+	UpdatedAt      int64   `json:"updated_at"`
+}
+
 // ProjectResponse matches the frontend Project interface.
 type ProjectResponse struct {
 	ID    string `json:"id"`
@@ -186,6 +216,97 @@ func ErrInternalServer(msg string, err error) render.Renderer {
 	}
 }
 
+// ErrTaskAction returns a 409 Conflict when err is an illegal task status
+// transition (e.g. merging a failed task), the task already has a run in
+// flight or can't be moved, or commit signing is missing; a 400 for an
+// invalid base branch or model ID; otherwise a 500 with msg.
+func ErrTaskAction(msg string, err error) render.Renderer {
+	var invalid services.ErrInvalidTransition
+	if errors.As(err, &invalid) {
+		return &ErrResponse{
+			Err:            err,
+			HTTPStatusCode: http.StatusConflict,
+			Status:         "error",
+			Message:        invalid.Error(),
+		}
+	}
+	var base services.ErrInvalidBaseBranch
+	if errors.As(err, &base) {
+		return &ErrResponse{
+			Err:            err,
+			HTTPStatusCode: http.StatusBadRequest,
+			Status:         "error",
+			Message:        base.Error(),
+		}
+	}
+	var model llm.ErrInvalidModelID
+	if errors.As(err, &model) {
+		return &ErrResponse{
+			Err:            err,
+			HTTPStatusCode: http.StatusBadRequest,
+			Status:         "error",
+			Message:        model.Error(),
+		}
+	}
+	var notMovable services.ErrTaskNotMovable
+	if errors.As(err, &notMovable) {
+		return &ErrResponse{
+			Err:            err,
+			HTTPStatusCode: http.StatusConflict,
+			Status:         "error",
+			Message:        notMovable.Error(),
+		}
+	}
+	var running services.ErrTaskRunning
+	if errors.As(err, &running) {
+		return &ErrResponse{
+			Err:            err,
+			HTTPStatusCode: http.StatusConflict,
+			Status:         "error",
+			Message:        "Task already running",
+		}
+	}
+	var notRunning services.ErrTaskNotRunning
+	if errors.As(err, &notRunning) {
+		return &ErrResponse{
+			Err:            err,
+			HTTPStatusCode: http.StatusConflict,
+			Status:         "error",
+			Message:        "Task is not running",
+		}
+	}
+	for _, signing := range []error{services.ErrCommitsMustBeSigned, services.ErrSigningKeyMissing} {
+		if errors.Is(err, signing) {
+			return &ErrResponse{
+				Err:            err,
+				HTTPStatusCode: http.StatusConflict,
+				Status:         "error",
+				Message:        msg + ": " + signing.Error(),
+			}
+		}
+	}
+	return ErrInternalServer(msg, err)
+}
+
+// ErrTooManyRequests returns a 429 Too Many Requests error. Callers set
+// Retry-After.
+func ErrTooManyRequests(msg string) render.Renderer {
+	return &ErrResponse{
+		HTTPStatusCode: http.StatusTooManyRequests,
+		Status:         "error",
+		Message:        msg,
+	}
+}
+
+// ErrForbidden returns a 403 Forbidden error.
+func ErrForbidden(msg string) render.Renderer {
+	return &ErrResponse{
+		HTTPStatusCode: http.StatusForbidden,
+		Status:         "error",
+		Message:        msg,
+	}
+}
+
 // ErrUnauthorized returns a 401 Unauthorized error.
 func ErrUnauthorized(msg string) render.Renderer {
 	return &ErrResponse{
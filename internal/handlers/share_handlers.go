@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/revrost/counterspell/internal/services"
+)
+
+// HandleCreateTaskShare creates a read-only link to a task. The optional
+// expires_in_hours query param sets its lifetime, up to MaxShareTTL. The
+// token is only returned here.
+func (h *Handlers) HandleCreateTaskShare(w http.ResponseWriter, r *http.Request) {
+	taskID := chi.URLParam(r, "id")
+
+	ttl := services.DefaultShareTTL
+	if raw := r.URL.Query().Get("expires_in_hours"); raw != "" {
+		hours, err := strconv.Atoi(raw)
+		if err != nil || hours <= 0 || time.Duration(hours)*time.Hour > services.MaxShareTTL {
+			_ = render.Render(w, r, ErrInvalidRequest(fmt.Errorf("expires_in_hours must be between 1 and %d", int(services.MaxShareTTL.Hours()))))
+			return
+		}
+		ttl = time.Duration(hours) * time.Hour
+	}
+
+	if _, err := h.taskService.Get(r.Context(), taskID); err != nil {
+		_ = render.Render(w, r, ErrNotFound("Task not found"))
+		return
+	}
+	share, err := h.taskService.CreateTaskShare(r.Context(), taskID, ttl)
+	if err != nil {
+		_ = render.Render(w, r, ErrInternalServer("Failed to create share link", err))
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, map[string]any{
+		"share": share,
+		"url":   "/shared/" + share.Token,
+	})
+}
+
+// HandleListTaskShares returns a task's unexpired share links, without
+// their tokens.
+func (h *Handlers) HandleListTaskShares(w http.ResponseWriter, r *http.Request) {
+	shares, err := h.taskService.ListTaskShares(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		_ = render.Render(w, r, ErrInternalServer("Failed to list share links", err))
+		return
+	}
+	render.JSON(w, r, map[string]any{"shares": shares})
+}
+
+// HandleRevokeTaskShare revokes a share link; it stops working at once.
+func (h *Handlers) HandleRevokeTaskShare(w http.ResponseWriter, r *http.Request) {
+	revoked, err := h.taskService.RevokeTaskShare(r.Context(), chi.URLParam(r, "id"), chi.URLParam(r, "shareID"))
+	if err != nil {
+		_ = render.Render(w, r, ErrInternalServer("Failed to revoke share link", err))
+		return
+	}
+	if !revoked {
+		_ = render.Render(w, r, ErrNotFound("Share link not found"))
+		return
+	}
+	render.JSON(w, r, map[string]string{"status": "ok"})
+}
+
+// HandleGetSharedTask returns the read-only view of the task a share token
+// gives access to: the task, its conversation, activity log and diff. It
+// needs no login, so it returns nothing beyond that one task.
+func (h *Handlers) HandleGetSharedTask(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	taskID, err := h.taskService.ResolveTaskShare(ctx, chi.URLParam(r, "token"))
+	if errors.Is(err, services.ErrShareNotFound) {
+		_ = render.Render(w, r, ErrNotFound(err.Error()))
+		return
+	}
+	if err != nil {
+		_ = render.Render(w, r, ErrInternalServer("Failed to open share link", err))
+		return
+	}
+
+	details, err := h.taskService.GetTaskWithDetails(ctx, taskID)
+	if err != nil {
+		_ = render.Render(w, r, ErrNotFound("Task not found"))
+		return
+	}
+	gitDiff, truncated, err := h.taskDiff(ctx, taskID)
+	if err != nil {
+		_ = render.Render(w, r, ErrInternalServer("Failed to get git diff", err))
+		return
+	}
+
+	// Server paths and artifacts stay private
+	for i := range details.AgentRuns {
+		details.AgentRuns[i].Artifacts = nil
+		for j := range details.AgentRuns[i].Messages {
+			details.AgentRuns[i].Messages[j].ArtifactPath = nil
+		}
+	}
+	for i := range details.Messages {
+		details.Messages[i].ArtifactPath = nil
+	}
+
+	render.JSON(w, r, map[string]any{
+		"task":       details.Task,
+		"agent_runs": details.AgentRuns,
+		"messages":   details.Messages,
+		"logs":       details.Logs,
+		"files":      services.ParseDiff(gitDiff, services.DefaultDiffOptions),
+		"truncated":  truncated,
+	})
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-chi/render"
+	"github.com/revrost/counterspell/internal/services"
+)
+
+// maxSlackCommandBytes bounds a slash command request, a few small form
+// fields.
+const maxSlackCommandBytes = 64 << 10
+
+// HandleSlackCommand receives /counterspell slash commands from Slack,
+// signed with SLACK_SIGNING_SECRET. Replies are rendered with status 200 even
+// for refused commands, since Slack only shows the text of successful
+// responses.
+func (h *Handlers) HandleSlackCommand(w http.ResponseWriter, r *http.Request) {
+	if h.slack == nil {
+		_ = render.Render(w, r, ErrNotFound("Slack integration not configured"))
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxSlackCommandBytes))
+	if err != nil {
+		_ = render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	if err := services.VerifySlackRequest(h.cfg.SlackSigningSecret, body,
+		r.Header.Get("X-Slack-Request-Timestamp"), r.Header.Get("X-Slack-Signature"), time.Now()); err != nil {
+		slog.Warn("Rejected Slack command", "error", err)
+		_ = render.Render(w, r, ErrUnauthorized(err.Error()))
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		_ = render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	reply := h.slack.HandleCommand(r.Context(), services.SlackCommand{
+		TeamID:      form.Get("team_id"),
+		UserID:      form.Get("user_id"),
+		UserName:    form.Get("user_name"),
+		Text:        form.Get("text"),
+		ResponseURL: form.Get("response_url"),
+	})
+	render.JSON(w, r, reply)
+}
+
+// HandleCreateSlackLinkCode returns a one-time code that links the Slack
+// account running `/counterspell link <code>` to this server.
+func (h *Handlers) HandleCreateSlackLinkCode(w http.ResponseWriter, r *http.Request) {
+	if h.slack == nil {
+		_ = render.Render(w, r, ErrNotFound("Slack integration not configured"))
+		return
+	}
+	code, expiresAt, err := h.slack.NewLinkCode()
+	if err != nil {
+		_ = render.Render(w, r, ErrInternalServer("Failed to create link code", err))
+		return
+	}
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, map[string]any{
+		"code":       code,
+		"command":    "/counterspell link " + code,
+		"expires_at": expiresAt.UnixMilli(),
+	})
+}
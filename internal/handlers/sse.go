@@ -2,13 +2,17 @@ package handlers
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/revrost/counterspell/internal/models"
+	"github.com/revrost/counterspell/internal/services"
 )
 
 // HandleSSE handles Server-Sent Events for real-time updates.
@@ -36,24 +40,61 @@ func (h *Handlers) HandleSSE(w http.ResponseWriter, r *http.Request) {
 
 	// Track last sent event ID for client-side deduplication
 	var lastSentID int64
+	// The run being tailed, if it was still active after its replay
+	var tailRunID string
 
 	if taskID != "" {
 		// Check if task exists
-		if _, err := h.taskService.Get(ctx, taskID); err != nil {
+		task, err := h.taskService.Get(ctx, taskID)
+		if err != nil {
 			http.Error(w, "Task not found", http.StatusNotFound)
 			return
 		}
 
-		// Send initial state
-		h.sendInitialState(w, flusher, ctx, taskID)
+		if runID := r.URL.Query().Get("run"); runID != "" {
+			// A specific run: replay its stored messages, then close unless
+			// it is still running, whose live events follow until it ends
+			active, err := h.runIsActive(ctx, task, runID)
+			if err != nil {
+				http.Error(w, "Run not found", http.StatusNotFound)
+				return
+			}
+			if err := h.replayRunMessages(w, flusher, ctx, runID); err != nil {
+				slog.Error("Failed to replay run messages", "run_id", runID, "error", err)
+				return
+			}
+			if !active {
+				sendRunComplete(w, flusher, runID)
+				return
+			}
+			tailRunID = runID
+		} else {
+			// Send initial state
+			h.sendInitialState(w, flusher, ctx, taskID)
+		}
 	} else {
 		// Feed page: send initial ping
 		_, _ = fmt.Fprintf(w, "event: ping\ndata: connected\n\n")
 		flusher.Flush()
+
+		// Reconnecting client: replay what it missed. We subscribed above, so
+		// anything published during the replay is deduplicated by lastSentID.
+		if lastEventID, ok := parseLastEventID(r); ok {
+			missed, complete := h.events.GetFeedEventsSince(lastEventID)
+			if !complete {
+				// Gap is older than the ring buffer; have the client refetch.
+				_, _ = fmt.Fprintf(w, "event: resync\ndata: {}\n\n")
+				flusher.Flush()
+			}
+			for _, event := range missed {
+				h.sendSSEEvent(w, flusher, event)
+				lastSentID = event.ID
+			}
+		}
 	}
 
 	// Keepalive ticker
-	keepalive := time.NewTicker(10 * time.Second)
+	keepalive := time.NewTicker(h.cfg.SSEKeepaliveInterval)
 	defer keepalive.Stop()
 
 	for {
@@ -68,6 +109,11 @@ func (h *Handlers) HandleSSE(w http.ResponseWriter, r *http.Request) {
 			if taskID != "" && event.TaskID != taskID {
 				continue
 			}
+			// A tailed run gets only its own events, up to its end
+			if tailRunID != "" && h.runEnded(ctx, taskID, tailRunID, event) {
+				sendRunComplete(w, flusher, tailRunID)
+				return
+			}
 
 			if event.ID <= lastSentID && event.ID != 0 {
 				continue
@@ -78,12 +124,120 @@ func (h *Handlers) HandleSSE(w http.ResponseWriter, r *http.Request) {
 			h.sendSSEEvent(w, flusher, event)
 
 		case <-keepalive.C:
-			_, _ = fmt.Fprintf(w, ": keepalive\n\n")
-			flusher.Flush()
+			if tailRunID != "" && h.runEnded(ctx, taskID, tailRunID, models.Event{}) {
+				sendRunComplete(w, flusher, tailRunID)
+				return
+			}
+			h.sendKeepalive(w, flusher)
 		}
 	}
 }
 
+// parseLastEventID reads the id EventSource sends on reconnect. The
+// last_event_id query parameter is accepted for clients that can't set headers.
+func parseLastEventID(r *http.Request) (int64, bool) {
+	raw := r.Header.Get("Last-Event-ID")
+	if raw == "" {
+		raw = r.URL.Query().Get("last_event_id")
+	}
+	if raw == "" {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || id < 0 {
+		return 0, false
+	}
+	return id, true
+}
+
+// runIsActive reports whether runID is the run the agent is working on now.
+// It fails if the run does not belong to task.
+func (h *Handlers) runIsActive(ctx context.Context, task *models.Task, runID string) (bool, error) {
+	run, err := h.taskService.GetAgentRun(ctx, runID)
+	if err != nil {
+		return false, err
+	}
+	if run.TaskID != task.ID {
+		return false, sql.ErrNoRows
+	}
+	if task.Status != "in_progress" {
+		return false, nil
+	}
+	latest, err := h.taskService.GetLatestAgentRun(ctx, task.ID)
+	if err != nil {
+		return false, err
+	}
+	return latest != nil && latest.ID == runID, nil
+}
+
+// runEnded reports whether the tailed run runID is over as of event: a newer
+// run started, or the task left in_progress. Streamed output comes from the
+// run itself and is passed through without a lookup; other events, and the
+// keepalive tick (a zero event), check the task.
+func (h *Handlers) runEnded(ctx context.Context, taskID, runID string, event models.Event) bool {
+	switch event.Type {
+	case string(services.EventTypeAgentUpdate), string(services.EventTypeDiffUpdate):
+		return false
+	case string(services.EventTypeAgentRunStarted):
+		var started struct {
+			RunID string `json:"run_id"`
+		}
+		if json.Unmarshal([]byte(event.Data), &started) == nil && started.RunID != "" {
+			return started.RunID != runID
+		}
+	}
+
+	task, err := h.taskService.Get(ctx, taskID)
+	if err == nil {
+		var active bool
+		active, err = h.runIsActive(ctx, task, runID)
+		if err == nil {
+			return !active
+		}
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return true // the task or run was deleted
+	}
+	slog.Warn("Failed to check tailed run", "task_id", taskID, "run_id", runID, "error", err)
+	return false
+}
+
+// sendRunComplete tells a client tailing runID that the run is over.
+func sendRunComplete(w http.ResponseWriter, flusher http.Flusher, runID string) {
+	_, _ = fmt.Fprintf(w, "event: run_complete\ndata: {\"run_id\":%q}\n\n", runID)
+	flusher.Flush()
+}
+
+// replayRunMessages sends the stored messages of a run as run_message events.
+func (h *Handlers) replayRunMessages(w http.ResponseWriter, flusher http.Flusher, ctx context.Context, runID string) error {
+	messages, err := h.taskService.GetMessagesByRun(ctx, runID)
+	if err != nil {
+		return err
+	}
+	for _, msg := range messages {
+		data, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(w, "event: run_message\ndata: %s\n\n", string(data))
+	}
+	flusher.Flush()
+	return nil
+}
+
+// sendKeepalive keeps an idle stream open: a comment by default, or with
+// SSE_HEARTBEAT_EVENTS a heartbeat event carrying the server time and the
+// interval, so clients can reconnect once heartbeats stop arriving.
+func (h *Handlers) sendKeepalive(w http.ResponseWriter, flusher http.Flusher) {
+	if h.cfg.SSEHeartbeatEvents {
+		_, _ = fmt.Fprintf(w, "event: heartbeat\ndata: {\"time\":%d,\"interval_ms\":%d}\n\n",
+			time.Now().UnixMilli(), h.cfg.SSEKeepaliveInterval.Milliseconds())
+	} else {
+		_, _ = fmt.Fprintf(w, ": keepalive\n\n")
+	}
+	flusher.Flush()
+}
+
 func (h *Handlers) sendInitialState(w http.ResponseWriter, flusher http.Flusher, ctx context.Context, taskID string) {
 	task, err := h.taskService.Get(ctx, taskID)
 	if err != nil {
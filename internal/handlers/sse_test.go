@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/revrost/counterspell/internal/config"
+	"github.com/revrost/counterspell/internal/db"
+	"github.com/revrost/counterspell/internal/models"
+	"github.com/revrost/counterspell/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleSSE_TailedRunEnds(t *testing.T) {
+	ctx := context.Background()
+	testDB, err := db.Connect(ctx, ":memory:")
+	require.NoError(t, err)
+	defer testDB.Close()
+	require.NoError(t, testDB.RunMigrations(ctx))
+
+	repo := services.NewRepository(testDB)
+	events := services.NewEventBus()
+	h := &Handlers{taskService: repo, events: events, cfg: &config.Config{SSEKeepaliveInterval: time.Hour}}
+	srv := httptest.NewServer(http.HandlerFunc(h.HandleSSE))
+	defer srv.Close()
+
+	task, err := repo.Create(ctx, "", "add tests")
+	require.NoError(t, err)
+	_, err = repo.UpdateStatus(ctx, task.ID, "in_progress")
+	require.NoError(t, err)
+
+	// tail opens a stream on runID and returns its event names as they
+	// arrive, once the replay is through
+	tail := func(runID string) <-chan string {
+		resp, err := http.Get(srv.URL + "?task_id=" + task.ID + "&run=" + runID)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		names := make(chan string, 16)
+		scanner := bufio.NewScanner(resp.Body)
+		// The replay is written before the handler waits for live events
+		for scanner.Scan() {
+			if name, ok := strings.CutPrefix(scanner.Text(), "event: "); ok {
+				names <- name
+				break
+			}
+		}
+		go func() {
+			defer close(names)
+			for scanner.Scan() {
+				if name, ok := strings.CutPrefix(scanner.Text(), "event: "); ok {
+					names <- name
+				}
+			}
+		}()
+		return names
+	}
+	next := func(names <-chan string) string {
+		select {
+		case name, ok := <-names:
+			if !ok {
+				return "closed"
+			}
+			return name
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for an event")
+			return ""
+		}
+	}
+
+	first, err := repo.CreateAgentRun(ctx, task.ID, "add tests", "native", "", "")
+	require.NoError(t, err)
+	require.NoError(t, repo.CreateMessage(ctx, task.ID, first, "user", "add tests"))
+
+	// A newer run ends the tailed one; its events aren't forwarded
+	names := tail(first)
+	assert.Equal(t, "run_message", next(names))
+	events.Publish(models.Event{TaskID: task.ID, Type: string(services.EventTypeAgentUpdate), Data: "{}"})
+	assert.Equal(t, string(services.EventTypeAgentUpdate), next(names))
+	second, err := repo.CreateAgentRun(ctx, task.ID, "also docs", "native", "", "")
+	require.NoError(t, err)
+	events.Publish(models.Event{TaskID: task.ID, Type: string(services.EventTypeAgentRunStarted), Data: `{"run_id":"` + second + `"}`})
+	events.Publish(models.Event{TaskID: task.ID, Type: string(services.EventTypeAgentUpdate), Data: "{}"})
+	assert.Equal(t, "run_complete", next(names))
+	assert.Equal(t, "closed", next(names))
+
+	// So does the task leaving in_progress
+	require.NoError(t, repo.CreateMessage(ctx, task.ID, second, "user", "also docs"))
+	names = tail(second)
+	assert.Equal(t, "run_message", next(names))
+	_, err = repo.UpdateStatus(ctx, task.ID, "review")
+	require.NoError(t, err)
+	events.Publish(models.Event{TaskID: task.ID, Type: string(services.EventTypeLog), Data: "{}"})
+	assert.Equal(t, "run_complete", next(names))
+	assert.Equal(t, "closed", next(names))
+}
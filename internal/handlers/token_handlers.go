@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/revrost/counterspell/internal/auth"
+)
+
+// maxAPITokenNameLength bounds the label given to an API token.
+const maxAPITokenNameLength = 100
+
+// HandleCreateAPIToken creates a personal API token named by the JSON body's
+// "name". The token is only returned here.
+func (h *Handlers) HandleCreateAPIToken(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		_ = render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > maxAPITokenNameLength {
+		_ = render.Render(w, r, ErrInvalidRequest(fmt.Errorf("name is required and must be at most %d characters", maxAPITokenNameLength)))
+		return
+	}
+
+	token, err := h.taskService.CreateAPIToken(r.Context(), auth.UserIDFromContext(r.Context()), req.Name)
+	if err != nil {
+		_ = render.Render(w, r, ErrInternalServer("Failed to create API token", err))
+		return
+	}
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, token)
+}
+
+// HandleListAPITokens returns the caller's API tokens, without their secrets.
+func (h *Handlers) HandleListAPITokens(w http.ResponseWriter, r *http.Request) {
+	tokens, err := h.taskService.ListAPITokens(r.Context(), auth.UserIDFromContext(r.Context()))
+	if err != nil {
+		_ = render.Render(w, r, ErrInternalServer("Failed to list API tokens", err))
+		return
+	}
+	render.JSON(w, r, map[string]any{"tokens": tokens})
+}
+
+// HandleRevokeAPIToken revokes one of the caller's API tokens; it stops
+// working at once.
+func (h *Handlers) HandleRevokeAPIToken(w http.ResponseWriter, r *http.Request) {
+	revoked, err := h.taskService.RevokeAPIToken(r.Context(), auth.UserIDFromContext(r.Context()), chi.URLParam(r, "id"))
+	if err != nil {
+		_ = render.Render(w, r, ErrInternalServer("Failed to revoke API token", err))
+		return
+	}
+	if !revoked {
+		_ = render.Render(w, r, ErrNotFound("API token not found"))
+		return
+	}
+	render.JSON(w, r, map[string]string{"status": "ok"})
+}
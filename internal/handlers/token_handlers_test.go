@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/revrost/counterspell/internal/db"
+	"github.com/revrost/counterspell/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPITokensCannotManageTokensOrLinkSlack(t *testing.T) {
+	ctx := context.Background()
+	testDB, err := db.Connect(ctx, ":memory:")
+	require.NoError(t, err)
+	defer testDB.Close()
+	require.NoError(t, testDB.RunMigrations(ctx))
+
+	h := &Handlers{taskService: services.NewRepository(testDB)}
+	token, err := h.taskService.CreateAPIToken(ctx, defaultUserID, "ci")
+	require.NoError(t, err)
+	other, err := h.taskService.CreateAPIToken(ctx, defaultUserID, "deploy")
+	require.NoError(t, err)
+
+	r := chi.NewRouter()
+	r.Use(h.RequireMachineAuth, h.RequireOperator)
+	r.Post("/api/v1/tokens", h.HandleCreateAPIToken)
+	r.Delete("/api/v1/tokens/{id}", h.HandleRevokeAPIToken)
+	r.Post("/api/v1/integrations/slack/link-code", h.HandleCreateSlackLinkCode)
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodPost, "/api/v1/tokens", strings.NewReader(`{"name":"replacement"}`)),
+		httptest.NewRequest(http.MethodDelete, "/api/v1/tokens/"+other.ID, nil),
+		httptest.NewRequest(http.MethodPost, "/api/v1/integrations/slack/link-code", nil),
+	} {
+		req.Header.Set("Authorization", "Bearer "+token.Token)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusForbidden, rec.Code, req.Method+" "+req.URL.Path)
+		assert.Contains(t, rec.Body.String(), "API tokens")
+	}
+
+	// Nothing was minted or revoked
+	tokens, err := h.taskService.ListAPITokens(ctx, defaultUserID)
+	require.NoError(t, err)
+	assert.Len(t, tokens, 2)
+	_, err = h.taskService.ResolveAPIToken(ctx, other.Token)
+	assert.NoError(t, err)
+}
@@ -1,5 +1,10 @@
 package llm
 
+import (
+	"fmt"
+	"strings"
+)
+
 // Models are Available models with provider prefix
 var Models = []Model{
 	// OpenRouter models
@@ -48,27 +53,55 @@ type Model struct {
 	Provider string
 }
 
-// ParseModelID parses model ID and returns provider and model name
-func ParseModelID(modelID string) (provider, model string) {
-	parts := []string{}
-	if len(modelID) > 0 {
-		// Split by # to get provider
-		if idx := findIndex(modelID, "#"); idx != -1 {
-			parts = append(parts, modelID[:idx])
-			model = modelID[idx+1:]
-		}
+// providerPrefixes maps the prefix of a model ID to the provider it selects.
+var providerPrefixes = map[string]string{
+	"o":          "openrouter",
+	"openrouter": "openrouter",
+	"zai":        "zai",
+	"anthropic":  "anthropic",
+	"openai":     "openai",
+}
+
+// ErrInvalidModelID is returned for a model ID naming an unknown provider or
+// no model.
+type ErrInvalidModelID struct {
+	ModelID string
+	Reason  string
+}
+
+func (e ErrInvalidModelID) Error() string {
+	return fmt.Sprintf("invalid model ID %q: %s", e.ModelID, e.Reason)
+}
+
+// ParseModelID splits a "provider#model" ID, e.g. "o#anthropic/claude-sonnet-4.5",
+// into the provider it selects and the model name. An ID without a prefix is
+// just a model name: provider is empty and gets picked from settings.
+func ParseModelID(modelID string) (provider, model string, err error) {
+	prefix, model, ok := strings.Cut(strings.TrimSpace(modelID), "#")
+	if !ok {
+		return "", prefix, nil
 	}
-	if len(parts) > 0 {
-		provider = parts[0]
+	prefix = strings.ToLower(strings.TrimSpace(prefix))
+	provider, known := providerPrefixes[prefix]
+	if !known {
+		return "", "", ErrInvalidModelID{ModelID: modelID, Reason: fmt.Sprintf("unknown provider '%s'", prefix)}
 	}
-	return
+	model = strings.TrimSpace(model)
+	if model == "" {
+		return "", "", ErrInvalidModelID{ModelID: modelID, Reason: "no model name"}
+	}
+	return provider, model, nil
 }
 
-func findIndex(s, substr string) int {
-	for i := 0; i <= len(s)-len(substr); i++ {
-		if s[i:i+len(substr)] == substr {
-			return i
-		}
+// NormalizeModelID validates modelID with ParseModelID and returns it
+// trimmed, with its provider prefix lowercased.
+func NormalizeModelID(modelID string) (string, error) {
+	if _, _, err := ParseModelID(modelID); err != nil {
+		return "", err
+	}
+	prefix, model, ok := strings.Cut(strings.TrimSpace(modelID), "#")
+	if !ok {
+		return prefix, nil
 	}
-	return -1
+	return strings.ToLower(strings.TrimSpace(prefix)) + "#" + strings.TrimSpace(model), nil
 }
@@ -0,0 +1,49 @@
+package llm
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseModelID(t *testing.T) {
+	tests := []struct {
+		modelID, provider, model string
+		wantErr                  bool
+	}{
+		{"", "", "", false},
+		{"claude-opus-4-5", "", "claude-opus-4-5", false},
+		{"o#anthropic/claude-sonnet-4.5", "openrouter", "anthropic/claude-sonnet-4.5", false},
+		{"zai#glm-4.7", "zai", "glm-4.7", false},
+		{" Anthropic#claude-opus-4-5 ", "anthropic", "claude-opus-4-5", false},
+		{"anthropics#claude", "", "", true},
+		{"openai#", "", "", true},
+	}
+	for _, tt := range tests {
+		provider, model, err := ParseModelID(tt.modelID)
+		if tt.wantErr {
+			var invalid ErrInvalidModelID
+			if !errors.As(err, &invalid) {
+				t.Errorf("ParseModelID(%q) error = %v, want ErrInvalidModelID", tt.modelID, err)
+			}
+			continue
+		}
+		if err != nil || provider != tt.provider || model != tt.model {
+			t.Errorf("ParseModelID(%q) = %q, %q, %v; want %q, %q", tt.modelID, provider, model, err, tt.provider, tt.model)
+		}
+	}
+
+	_, _, err := ParseModelID("anthropics#claude")
+	if want := `invalid model ID "anthropics#claude": unknown provider 'anthropics'`; err == nil || err.Error() != want {
+		t.Errorf("error = %v, want %s", err, want)
+	}
+}
+
+func TestNormalizeModelID(t *testing.T) {
+	got, err := NormalizeModelID(" ZAI # glm-4.7 ")
+	if err != nil || got != "zai#glm-4.7" {
+		t.Errorf("NormalizeModelID = %q, %v; want zai#glm-4.7", got, err)
+	}
+	if _, err := NormalizeModelID("anthropics#claude"); err == nil {
+		t.Error("NormalizeModelID accepted an unknown provider")
+	}
+}
@@ -0,0 +1,136 @@
+// Package logging sets up the application's slog logger with a global level
+// and per-module level overrides.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// New returns a text logger writing to w. level is the default level
+// ("debug", "info", "warn" or "error"; empty means info). modules holds
+// "MODULE=level" overrides for messages logged with a "[MODULE]" prefix,
+// e.g. "ORCHESTRATOR=warn" or "AGENT LOOP=error".
+func New(w io.Writer, level string, modules []string) (*slog.Logger, error) {
+	def, err := ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	overrides, err := ParseModuleLevels(modules)
+	if err != nil {
+		return nil, err
+	}
+
+	handler := NewModuleHandler(slog.NewTextHandler(w, &slog.HandlerOptions{
+		Level:     minLevel(def, overrides),
+		AddSource: true,
+	}), def, overrides)
+	return slog.New(handler), nil
+}
+
+// ParseLevel parses a level name, case-insensitively. Empty means info.
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if strings.TrimSpace(s) == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return 0, fmt.Errorf("invalid log level %q", s)
+	}
+	return level, nil
+}
+
+// ParseModuleLevels parses "MODULE=level" overrides. Module names are
+// matched case-insensitively and may be given with or without brackets.
+func ParseModuleLevels(specs []string) (map[string]slog.Level, error) {
+	levels := make(map[string]slog.Level, len(specs))
+	for _, spec := range specs {
+		name, lvl, ok := strings.Cut(spec, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid module log level %q: want MODULE=level", spec)
+		}
+		name = strings.ToUpper(strings.Trim(strings.TrimSpace(name), "[]"))
+		if name == "" {
+			return nil, fmt.Errorf("invalid module log level %q: empty module", spec)
+		}
+		level, err := ParseLevel(lvl)
+		if err != nil {
+			return nil, fmt.Errorf("module %s: %w", name, err)
+		}
+		levels[name] = level
+	}
+	return levels, nil
+}
+
+// ModuleHandler filters records by level before passing them to the next
+// handler. Messages starting with a "[MODULE]" prefix use that module's
+// level when one is set, everything else uses the default level.
+type ModuleHandler struct {
+	next    slog.Handler
+	level   slog.Level
+	modules map[string]slog.Level
+	min     slog.Level
+}
+
+// NewModuleHandler wraps next. next must accept every level the overrides
+// can let through.
+func NewModuleHandler(next slog.Handler, level slog.Level, modules map[string]slog.Level) *ModuleHandler {
+	return &ModuleHandler{
+		next:    next,
+		level:   level,
+		modules: modules,
+		min:     minLevel(level, modules),
+	}
+}
+
+// Enabled reports whether any module could log at l.
+func (h *ModuleHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return l >= h.min && h.next.Enabled(ctx, l)
+}
+
+// Handle drops r when it is below its module's level.
+func (h *ModuleHandler) Handle(ctx context.Context, r slog.Record) error {
+	level := h.level
+	if override, ok := h.modules[module(r.Message)]; ok {
+		level = override
+	}
+	if r.Level < level {
+		return nil
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h *ModuleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.next = h.next.WithAttrs(attrs)
+	return &clone
+}
+
+func (h *ModuleHandler) WithGroup(name string) slog.Handler {
+	clone := *h
+	clone.next = h.next.WithGroup(name)
+	return &clone
+}
+
+// module returns the upper-cased name in a message's "[MODULE]" prefix, or "".
+func module(msg string) string {
+	if !strings.HasPrefix(msg, "[") {
+		return ""
+	}
+	end := strings.IndexByte(msg, ']')
+	if end < 0 {
+		return ""
+	}
+	return strings.ToUpper(msg[1:end])
+}
+
+func minLevel(level slog.Level, modules map[string]slog.Level) slog.Level {
+	lowest := level
+	for _, l := range modules {
+		lowest = min(lowest, l)
+	}
+	return lowest
+}
@@ -0,0 +1,47 @@
+package logging
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew_ModuleOverrides(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "info", []string{"ORCHESTRATOR=warn", "[git]=debug"})
+	require.NoError(t, err)
+
+	logger.Info("[ORCHESTRATOR] task started")
+	logger.Warn("[ORCHESTRATOR] task slow")
+	logger.Debug("[GIT] running fetch")
+	logger.Debug("[FILE] scanning")
+	logger.Info("Server starting")
+	logger.With("task_id", "t1").Info("[ORCHESTRATOR] hidden too")
+
+	out := buf.String()
+	assert.NotContains(t, out, "task started")
+	assert.Contains(t, out, "task slow")
+	assert.Contains(t, out, "running fetch")
+	assert.NotContains(t, out, "scanning")
+	assert.Contains(t, out, "Server starting")
+	assert.NotContains(t, out, "hidden too")
+}
+
+func TestParseLevel(t *testing.T) {
+	level, err := ParseLevel("")
+	require.NoError(t, err)
+	assert.Equal(t, slog.LevelInfo, level)
+
+	level, err = ParseLevel("WARN")
+	require.NoError(t, err)
+	assert.Equal(t, slog.LevelWarn, level)
+
+	_, err = ParseLevel("loud")
+	assert.Error(t, err)
+
+	_, err = ParseModuleLevels([]string{"GIT"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,13 @@
+package models
+
+// APIToken is a personal access token for calling the API from scripts and
+// CI. Token is only set when it is created; the database keeps just its
+// hash.
+type APIToken struct {
+	ID         string `json:"id"`
+	UserID     string `json:"user_id"`
+	Name       string `json:"name"`
+	Token      string `json:"token,omitempty"`
+	LastUsedAt *int64 `json:"last_used_at,omitempty"`
+	CreatedAt  int64  `json:"created_at"`
+}
@@ -26,6 +26,25 @@ type Task struct {
 	LastAssistantMessage *string `json:"last_assistant_message,omitempty"`
 	CreatedAt            int64   `json:"created_at"`
 	UpdatedAt            int64   `json:"updated_at"`
+	Version              int64   `json:"version"` // incremented on every status change
+	PRURL                *string `json:"pr_url,omitempty"`
+	PRState              *string `json:"pr_state,omitempty"`    // open, merged or closed; nil until GitHub reports it
+	BaseBranch           *string `json:"base_branch,omitempty"` // nil means the default branch
+	// NoChanges is set when the last successful run left the task's diff
+	// empty: there is nothing to merge.
+	NoChanges bool `json:"no_changes,omitempty"`
+}
+
+// TaskProgress is the live state of a task that isn't finished: enough for
+// the feed to reconcile its rows without reloading them.
+type TaskProgress struct {
+	RepositoryID *string `json:"repository_id,omitempty"`
+	Status       string  `json:"status"`
+	Version      int64   `json:"version"`
+	// TodosDone of TodosTotal items of the agent's todo list are completed;
+	// both are 0 before the agent reports one.
+	TodosDone  int `json:"todos_done"`
+	TodosTotal int `json:"todos_total"`
 }
 
 // AgentRun represents an execution of an agent.
@@ -58,17 +77,28 @@ type Settings struct {
 	UpdatedAt     int64   `json:"updated_at"`
 }
 
-// Artifact represents a file uploaded by an agent.
+// Artifact represents a file uploaded by an agent. Versions count up from 1
+// each time the task's runs write the same path.
 type Artifact struct {
 	ID        string `json:"id"`
 	RunID     string `json:"run_id"`
 	Path      string `json:"path"`
-	Content   string `json:"content"`
+	Content   string `json:"content,omitempty"`
 	Version   int64  `json:"version"`
 	CreatedAt int64  `json:"created_at"`
 	UpdatedAt int64  `json:"updated_at"`
 }
 
+// TaskShare is a read-only link to one task. Token is only set when the
+// share is created; the database keeps just its hash.
+type TaskShare struct {
+	ID        string `json:"id"`
+	TaskID    string `json:"task_id"`
+	Token     string `json:"token,omitempty"`
+	ExpiresAt int64  `json:"expires_at"`
+	CreatedAt int64  `json:"created_at"`
+}
+
 // Message represents a chat message for agent conversation history.
 type Message struct {
 	ID         string  `json:"id"`
@@ -83,6 +113,8 @@ type Message struct {
 	CreatedAt  int64   `json:"created_at"`
 	UpdatedAt  int64   `json:"updated_at"`
 	FinishedAt *int64  `json:"finished_at,omitempty"`
+	// ArtifactPath holds the full content when Content was truncated
+	ArtifactPath *string `json:"artifact_path,omitempty"`
 }
 
 // AgentRunWithDetails represents an agent run with nested messages and artifacts.
@@ -97,6 +129,7 @@ type AgentRunWithDetails struct {
 	PromptTokens     int64      `json:"prompt_tokens"`
 	CompletionTokens int64      `json:"completion_tokens"`
 	CompletedAt      *int64     `json:"completed_at,omitempty"`
+	LimitReached     *string    `json:"limit_reached,omitempty"` // set when the iteration limit stopped the run
 	CreatedAt        int64      `json:"created_at"`
 	UpdatedAt        int64      `json:"updated_at"`
 	Messages         []Message  `json:"messages,omitempty"`
@@ -116,6 +149,18 @@ type TaskResponse struct {
 
 	// Git diff from the worktree (if available)
 	GitDiff string `json:"git_diff,omitempty"`
+
+	// Activity log history, oldest first
+	Logs []LogEntry `json:"logs"`
+}
+
+// LogEntry is a single activity log line for a task.
+type LogEntry struct {
+	ID        int64  `json:"id"`
+	TaskID    string `json:"task_id"`
+	Level     string `json:"level"` // info, success, warn, error
+	Message   string `json:"message"`
+	Timestamp int64  `json:"timestamp"` // Unix ms
 }
 
 // Repository represents a GitHub repository.
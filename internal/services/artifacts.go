@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"unicode/utf8"
+)
+
+// ArtifactsDir is where agents write files to hand back with a run, such as
+// a build log or a generated report, relative to the task's workspace. The
+// directory is collected after each run and removed, so it is never
+// committed.
+const ArtifactsDir = ".counterspell/artifacts"
+
+// maxArtifactSize caps a single artifact; larger files are skipped.
+const maxArtifactSize = 1 << 20
+
+// collectArtifacts stores the text files under the workspace's ArtifactsDir
+// as artifacts of runID and removes the directory. Binary and oversized
+// files are skipped with a warning.
+func (o *Orchestrator) collectArtifacts(ctx context.Context, taskID, runID, workspacePath string) {
+	logger := taskLogger(ctx, taskID)
+	root := filepath.Join(workspacePath, ArtifactsDir)
+	if _, err := os.Stat(root); err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			logger.Warn("[ORCHESTRATOR] Failed to read artifacts", "error", err)
+		}
+		return
+	}
+
+	var saved int
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.Size() > maxArtifactSize {
+			logger.Warn("[ORCHESTRATOR] Skipping oversized artifact", "path", rel, "size", info.Size())
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if !utf8.Valid(content) {
+			logger.Warn("[ORCHESTRATOR] Skipping binary artifact", "path", rel)
+			return nil
+		}
+		artifact, err := o.repo.SaveArtifact(ctx, taskID, runID, rel, string(content))
+		if err != nil {
+			return err
+		}
+		saved++
+		logger.Info("[ORCHESTRATOR] Saved artifact", "path", rel, "version", artifact.Version)
+		return nil
+	})
+	if err != nil {
+		logger.Error("[ORCHESTRATOR] Failed to collect artifacts", "error", err)
+	}
+	if saved > 0 {
+		o.logTask(taskID, LogLevelInfo, fmt.Sprintf("Saved %d artifact(s)", saved))
+	}
+
+	// The parent .counterspell dir goes too when nothing else lives there
+	if err := os.RemoveAll(root); err != nil {
+		logger.Warn("[ORCHESTRATOR] Failed to remove artifacts dir", "error", err)
+	}
+	_ = os.Remove(filepath.Dir(root))
+}
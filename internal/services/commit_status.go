@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// runCommitStatus tracks the GitHub commit status of one run on the task's
+// agent branch. Statuses belong to commits, not branches, so only commits
+// the agent made are marked: a new task's branch starts at the base
+// branch's head, which must not be marked pending or failed. The status
+// context names the task, so concurrent tasks don't overwrite each other.
+type runCommitStatus struct {
+	o        *Orchestrator
+	job      TaskJob
+	startSHA string // workspace HEAD when the run started
+	sha      string // commit marked pending, if any
+	finished bool
+}
+
+// startCommitStatus begins tracking a run's commit status when the task's
+// project has commit statuses enabled, marking the agent branch's pushed
+// head pending if an earlier run already pushed it. It returns nil, on which
+// finish is a no-op, otherwise.
+func (o *Orchestrator) startCommitStatus(ctx context.Context, job TaskJob) *runCommitStatus {
+	if o.github == nil || job.Owner == "" || job.ProjectID == "" {
+		return nil
+	}
+	if _, denied := o.statusDenied.Load(job.Owner + "/" + job.Repo); denied {
+		return nil
+	}
+	enabled, err := o.repo.CommitStatusesEnabled(ctx, job.ProjectID)
+	if err != nil || !enabled {
+		return nil
+	}
+	logger := taskLogger(ctx, job.TaskID)
+	sha, err := o.repoManager.HeadCommit(ctx, job.TaskID)
+	if err != nil || sha == "" {
+		logger.Warn("[ORCHESTRATOR] Failed to resolve commit for status", "error", err)
+		return nil
+	}
+
+	s := &runCommitStatus{o: o, job: job, startSHA: sha}
+	pushed, err := o.repoManager.RemoteBranchExists(ctx, TaskBranchName(job.TaskID))
+	if err != nil {
+		logger.Warn("[ORCHESTRATOR] Failed to check agent branch for status", "error", err)
+	}
+	if pushed && o.postCommitStatus(ctx, job, sha, "pending", "Agent running") {
+		s.sha = sha
+	}
+	return s
+}
+
+// finish sets the run's final status, once. After a successful run that
+// committed, the agent branch is pushed so the new commit can be marked.
+func (s *runCommitStatus) finish(ctx context.Context, success bool) {
+	if s == nil || s.finished {
+		return
+	}
+	s.finished = true
+	// The run's context may have timed out; the final state still matters
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	defer cancel()
+	logger := taskLogger(ctx, s.job.TaskID)
+
+	sha := s.sha
+	if success {
+		head, err := s.o.repoManager.HeadCommit(ctx, s.job.TaskID)
+		if err != nil {
+			logger.Warn("[ORCHESTRATOR] Failed to resolve commit for status", "error", err)
+		} else if head != s.startSHA {
+			if err := s.o.repoManager.PushBranch(ctx, s.job.TaskID); err != nil {
+				logger.Warn("[ORCHESTRATOR] Failed to push agent branch for status", "error", err)
+			} else {
+				sha = head
+			}
+		}
+	}
+	state, description := "failure", "Agent failed"
+	if success {
+		state, description = "success", "Agent completed"
+	}
+	// The commit marked pending is resolved too, rather than left pending
+	// behind the new one
+	if s.sha != "" && s.sha != sha {
+		s.o.postCommitStatus(ctx, s.job, s.sha, state, description)
+	}
+	if sha != "" {
+		s.o.postCommitStatus(ctx, s.job, sha, state, description)
+	}
+}
+
+// postCommitStatus posts one commit status and reports whether it was
+// accepted. A token without access to statuses disables them for the
+// repository until restart instead of failing every run.
+func (o *Orchestrator) postCommitStatus(ctx context.Context, job TaskJob, sha, state, description string) bool {
+	logger := taskLogger(ctx, job.TaskID)
+	err := o.github.CreateCommitStatus(ctx, job.Owner, job.Repo, sha, CommitStatusContext(job.TaskID), state, description)
+	if errors.Is(err, ErrStatusesForbidden) {
+		o.statusDenied.Store(job.Owner+"/"+job.Repo, struct{}{})
+		logger.Warn("[ORCHESTRATOR] Token cannot post commit statuses, skipping them for this repository", "error", err)
+		return false
+	}
+	if err != nil {
+		logger.Warn("[ORCHESTRATOR] Failed to post commit status", "state", state, "error", err)
+		return false
+	}
+	return true
+}
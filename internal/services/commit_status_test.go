@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/revrost/counterspell/internal/db/sqlc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// headRepoManager is a workspace whose HEAD and agent branch push state
+// the test controls.
+type headRepoManager struct {
+	stubRepoManager
+	head   *string
+	pushed *bool
+	pushes *int
+}
+
+func (m headRepoManager) HeadCommit(ctx context.Context, taskID string) (string, error) {
+	return *m.head, nil
+}
+
+func (m headRepoManager) RemoteBranchExists(ctx context.Context, branch string) (bool, error) {
+	return *m.pushed, nil
+}
+
+func (m headRepoManager) PushBranch(ctx context.Context, taskID string) error {
+	*m.pushes++
+	*m.pushed = true
+	return nil
+}
+
+func TestStartCommitStatus(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	type status struct{ sha, state string }
+	var statuses []status
+	forbidden := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if forbidden {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "counterspell/agent/task-1", body["context"])
+		statuses = append(statuses, status{strings.TrimPrefix(r.URL.Path, "/repos/test/repo-1/statuses/"), body["state"]})
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	head, pushed, pushes := "base111", false, 0
+	github := NewGitHubService(testDB, "", "", WithGitHubHost("", srv.URL))
+	orch, err := NewOrchestrator(NewRepository(testDB), NewEventBus(), nil, github,
+		headRepoManager{head: &head, pushed: &pushed, pushes: &pushes})
+	require.NoError(t, err)
+
+	conn, err := testDB.Queries.CreateGithubConnection(ctx, sqlc.CreateGithubConnectionParams{
+		ID:           "conn-1",
+		GithubUserID: "user-1",
+		AccessToken:  "token",
+		Username:     "testuser",
+	})
+	require.NoError(t, err)
+	_, err = testDB.Queries.CreateRepository(ctx, sqlc.CreateRepositoryParams{
+		ID:           "repo-1",
+		ConnectionID: conn.ID,
+		Name:         "repo-1",
+		FullName:     "test/repo-1",
+		Owner:        "test",
+	})
+	require.NoError(t, err)
+	job := TaskJob{TaskID: "task-1", ProjectID: "repo-1", Owner: "test", Repo: "repo-1"}
+
+	// Disabled by default
+	orch.startCommitStatus(ctx, job).finish(ctx, true)
+	assert.Empty(t, statuses)
+
+	// A new task's branch is the base branch's head: nothing is marked
+	// while it runs, nor when it fails
+	require.NoError(t, orch.repo.SetCommitStatuses(ctx, "repo-1", true))
+	orch.startCommitStatus(ctx, job).finish(ctx, false)
+	assert.Empty(t, statuses)
+
+	// Once the agent commits, its branch is pushed and the commit marked
+	run := orch.startCommitStatus(ctx, job)
+	head = "agent222"
+	run.finish(ctx, true)
+	run.finish(ctx, false)
+	assert.Equal(t, []status{{"agent222", "success"}}, statuses)
+	assert.Equal(t, 1, pushes)
+
+	// Later runs mark the pushed agent commit pending first
+	statuses = nil
+	run = orch.startCommitStatus(ctx, job)
+	run.finish(ctx, false)
+	assert.Equal(t, []status{{"agent222", "pending"}, {"agent222", "failure"}}, statuses)
+
+	statuses = nil
+	run = orch.startCommitStatus(ctx, job)
+	head = "agent333"
+	run.finish(ctx, true)
+	assert.Equal(t, []status{{"agent222", "pending"}, {"agent222", "success"}, {"agent333", "success"}}, statuses)
+	assert.Equal(t, 2, pushes)
+
+	// A token without status access turns them off for the repository
+	forbidden = true
+	orch.startCommitStatus(ctx, job).finish(ctx, true)
+	_, denied := orch.statusDenied.Load("test/repo-1")
+	assert.True(t, denied)
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/revrost/counterspell/internal/agent"
+)
+
+// ErrAutoResolveDisabled is returned by AutoResolveConflicts when the
+// auto_resolve_conflicts setting is off.
+var ErrAutoResolveDisabled = errors.New("automatic conflict resolution is disabled in settings")
+
+// conflictResolveTimeout bounds the LLM call for a single conflicted file.
+const conflictResolveTimeout = 2 * time.Minute
+
+const conflictResolveSystemPrompt = `You resolve git merge conflicts. You are given one file containing conflict markers.
+Reply with the complete merged file content and nothing else: no explanation, no code fences.
+Keep the intent of both sides. If the two sides cannot be combined safely, reply with exactly UNRESOLVED.`
+
+// ConflictResolution reports the outcome of AutoResolveConflicts.
+type ConflictResolution struct {
+	// Resolved files were rewritten and staged; the merge is not committed.
+	Resolved []string `json:"resolved"`
+	// Unresolved files still hold conflict markers for the human to fix.
+	Unresolved []string `json:"unresolved"`
+}
+
+// AutoResolveConflicts asks the LLM to merge each conflicted file in the
+// task's workspace, writing and staging the files it resolved. Nothing is
+// committed: the user reviews the result and finishes with
+// CompleteMergeResolution, or fixes the unresolved files first.
+func (o *Orchestrator) AutoResolveConflicts(ctx context.Context, taskID string) (*ConflictResolution, error) {
+	logger := taskLogger(ctx, taskID)
+	if o.settings == nil || !o.settings.AutoResolveConflicts(ctx) {
+		return nil, ErrAutoResolveDisabled
+	}
+
+	conflicts, err := o.GetConflictDetails(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	workspacePath := o.repoManager.WorkspacePath(taskID)
+	result := &ConflictResolution{Resolved: []string{}, Unresolved: []string{}}
+	for _, conflict := range conflicts {
+		content, err := os.ReadFile(filepath.Join(workspacePath, conflict.Path))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", conflict.Path, err)
+		}
+		merged, err := o.resolveConflictFile(ctx, conflict, string(content))
+		if err != nil {
+			logger.Warn("[ORCHESTRATOR] Conflict left for review", "path", conflict.Path, "error", err)
+			result.Unresolved = append(result.Unresolved, conflict.Path)
+			continue
+		}
+		if err := o.ResolveConflict(ctx, taskID, conflict.Path, merged); err != nil {
+			return nil, err
+		}
+		result.Resolved = append(result.Resolved, conflict.Path)
+	}
+	if err := o.repoManager.StageFiles(ctx, taskID, result.Resolved); err != nil {
+		return nil, fmt.Errorf("failed to stage resolved files: %w", err)
+	}
+
+	if len(result.Resolved) > 0 {
+		o.logTask(taskID, LogLevelInfo, "Auto-resolved conflicts (review before merging): "+strings.Join(result.Resolved, ", "))
+	}
+	if len(result.Unresolved) > 0 {
+		o.logTask(taskID, LogLevelWarn, "Conflicts need manual resolution: "+strings.Join(result.Unresolved, ", "))
+	}
+	return result, nil
+}
+
+// resolveConflictFile returns the LLM's merged content for conflict, whose
+// file holds content, or an error when it declined or left conflict markers.
+func (o *Orchestrator) resolveConflictFile(ctx context.Context, conflict ConflictFile, content string) (string, error) {
+	// An empty work dir: the native backend has file tools, and the answer
+	// should come back as text rather than as edits to the workspace.
+	workDir, err := os.MkdirTemp("", "counterspell-resolve-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(workDir)
+
+	backend, err := o.auxiliaryBackend(ctx, workDir, conflictResolveSystemPrompt)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = backend.Close() }()
+
+	ctx, cancel := context.WithTimeout(ctx, conflictResolveTimeout)
+	defer cancel()
+	if err := backend.Run(ctx, buildConflictPrompt(conflict, content)); err != nil {
+		return "", err
+	}
+
+	merged := stripCodeFence(backend.FinalMessage())
+	switch {
+	case strings.TrimSpace(merged) == "":
+		return "", errors.New("empty response")
+	case strings.TrimSpace(merged) == "UNRESOLVED":
+		return "", errors.New("model declined to resolve")
+	case hasConflictMarkers(merged):
+		return "", errors.New("response still contains conflict markers")
+	}
+	if !strings.HasSuffix(merged, "\n") {
+		merged += "\n"
+	}
+	return merged, nil
+}
+
+// auxiliaryBackend creates a backend for one-off LLM calls that answer in
+// text, on the summary model when one is configured.
+func (o *Orchestrator) auxiliaryBackend(ctx context.Context, workDir, systemPrompt string) (agent.Backend, error) {
+	if o.newBackend != nil {
+		return o.newBackend(workDir, systemPrompt)
+	}
+
+	provider, model := "", ""
+	if modelID := o.settings.SummaryModel(ctx); modelID != "" {
+		if prefix, name, ok := strings.Cut(modelID, "#"); ok {
+			provider, model = prefix, name
+			if provider == "o" {
+				provider = "openrouter"
+			}
+		} else {
+			model = modelID
+		}
+	}
+	apiKey, actualProvider, actualModel, err := o.settings.GetAPIKeyForProvider(ctx, provider)
+	if err != nil {
+		return nil, err
+	}
+	if model == "" {
+		model = actualModel
+	}
+	llmProvider, err := newLLMProvider(actualProvider, apiKey)
+	if err != nil {
+		return nil, err
+	}
+	llmProvider.SetModel(model)
+	return o.newNativeBackend(ctx, llmProvider, workDir, systemPrompt, nil)
+}
+
+func buildConflictPrompt(conflict ConflictFile, content string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Resolve the merge conflicts in %s.\n\n", conflict.Path)
+	b.WriteString("Ours (the task branch):\n<<<\n" + conflict.Current + ">>>\n\n")
+	b.WriteString("Theirs (the incoming branch):\n<<<\n" + conflict.Incoming + ">>>\n\n")
+	b.WriteString("Base (the file outside the conflicted hunks):\n<<<\n" + conflict.Base + ">>>\n\n")
+	b.WriteString("Full file with conflict markers:\n<<<\n" + content + ">>>\n")
+	return b.String()
+}
+
+// stripCodeFence removes a single fence wrapping the whole reply, which
+// models add despite being told not to.
+func stripCodeFence(s string) string {
+	trimmed := strings.TrimSpace(s)
+	if !strings.HasPrefix(trimmed, "```") || !strings.HasSuffix(trimmed, "```") {
+		return s
+	}
+	_, body, ok := strings.Cut(trimmed, "\n")
+	if !ok {
+		return s
+	}
+	return strings.TrimSuffix(body, "```")
+}
+
+func hasConflictMarkers(s string) bool {
+	for _, line := range strings.Split(s, "\n") {
+		if strings.HasPrefix(line, "<<<<<<<") || strings.HasPrefix(line, "=======") || strings.HasPrefix(line, ">>>>>>>") {
+			return true
+		}
+	}
+	return false
+}
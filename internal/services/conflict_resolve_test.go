@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/revrost/counterspell/internal/agent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// conflictedTask runs a task that adds hello.txt, lands a different
+// hello.txt on the remote's main and pulls it into the task's workspace.
+func conflictedTask(t *testing.T, h *e2eHarness) string {
+	t.Helper()
+	ctx := context.Background()
+	taskID, err := h.orch.StartTask(ctx, "proj-1", "add a greeting file", "")
+	require.NoError(t, err)
+	h.waitForStatus(t, taskID, "review")
+
+	require.NoError(t, os.WriteFile(filepath.Join(h.root, "hello.txt"), []byte("hello from main\n"), 0644))
+	runGit(t, h.root, "add", "hello.txt")
+	runGit(t, h.root, "commit", "-m", "greet from main")
+	runGit(t, h.root, "push", "origin", "main")
+
+	var conflict *ErrMergeConflict
+	require.ErrorAs(t, h.orch.repoManager.(*GitManager).PullMainIntoWorktree(ctx, taskID), &conflict)
+	return taskID
+}
+
+func enableAutoResolve(t *testing.T, h *e2eHarness) {
+	t.Helper()
+	require.NoError(t, h.orch.settings.UpdateSettings(context.Background(), &Settings{
+		AgentBackend: "native", AnthropicKey: "test-key", AutoResolveConflicts: true,
+	}))
+}
+
+func TestAutoResolveConflicts(t *testing.T) {
+	h := newE2EHarness(t,
+		agent.WithMockFiles(map[string]string{"hello.txt": "hello from the agent\n"}),
+		agent.WithMockEvents(agent.MockTextMessage("msg-1", "```\nhello from the agent and main\n```")...),
+	)
+	taskID := conflictedTask(t, h)
+	enableAutoResolve(t, h)
+	ctx := context.Background()
+
+	result, err := h.orch.AutoResolveConflicts(ctx, taskID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"hello.txt"}, result.Resolved)
+	assert.Empty(t, result.Unresolved)
+
+	// Resolved and staged, but the merge is left for the user to commit
+	workspace := h.orch.repoManager.WorkspacePath(taskID)
+	content, err := os.ReadFile(filepath.Join(workspace, "hello.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "hello from the agent and main\n", string(content))
+	assert.Empty(t, runGit(t, workspace, "diff", "--name-only", "--diff-filter=U"))
+	runGit(t, workspace, "rev-parse", "-q", "--verify", "MERGE_HEAD")
+}
+
+func TestAutoResolveConflicts_LeavesUnmergeableFiles(t *testing.T) {
+	h := newE2EHarness(t,
+		agent.WithMockFiles(map[string]string{"hello.txt": "hello from the agent\n"}),
+		agent.WithMockEvents(agent.MockTextMessage("msg-1", "UNRESOLVED")...),
+	)
+	taskID := conflictedTask(t, h)
+	enableAutoResolve(t, h)
+
+	result, err := h.orch.AutoResolveConflicts(context.Background(), taskID)
+	require.NoError(t, err)
+	assert.Empty(t, result.Resolved)
+	assert.Equal(t, []string{"hello.txt"}, result.Unresolved)
+
+	workspace := h.orch.repoManager.WorkspacePath(taskID)
+	assert.Equal(t, "hello.txt", runGit(t, workspace, "diff", "--name-only", "--diff-filter=U"))
+}
+
+func TestAutoResolveConflicts_Disabled(t *testing.T) {
+	h := newE2EHarness(t, agent.WithMockFiles(map[string]string{"hello.txt": "hello from the agent\n"}))
+	taskID := conflictedTask(t, h)
+
+	_, err := h.orch.AutoResolveConflicts(context.Background(), taskID)
+	assert.ErrorIs(t, err, ErrAutoResolveDisabled)
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Cost groupings supported by CostSummary.
+const (
+	CostGroupProject = "project"
+	CostGroupModel   = "model"
+)
+
+// CostBucket is the summed usage of the agent runs in one group. Runs whose
+// backend didn't report usage count towards RunCount with zero cost/tokens.
+type CostBucket struct {
+	Key              string  `json:"key"`   // repository ID, or "provider/model"
+	Label            string  `json:"label"` // repository full name, or "provider/model"
+	RunCount         int64   `json:"run_count"`
+	Cost             float64 `json:"cost"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+}
+
+// CostSummary sums cost and token usage of agent runs started since the
+// given time, grouped by project or by model, most expensive first.
+func (s *Repository) CostSummary(ctx context.Context, since time.Time, groupBy string) ([]CostBucket, error) {
+	switch groupBy {
+	case CostGroupProject:
+		rows, err := s.db.Queries.SumAgentRunCostByProject(ctx, since.UnixMilli())
+		if err != nil {
+			return nil, fmt.Errorf("failed to sum cost by project: %w", err)
+		}
+		buckets := make([]CostBucket, len(rows))
+		for i, row := range rows {
+			label := row.Project
+			if row.RepositoryID == "" {
+				label = "(no project)"
+			}
+			buckets[i] = CostBucket{
+				Key:              row.RepositoryID,
+				Label:            label,
+				RunCount:         row.RunCount,
+				Cost:             row.Cost,
+				PromptTokens:     row.PromptTokens,
+				CompletionTokens: row.CompletionTokens,
+			}
+		}
+		return buckets, nil
+	case CostGroupModel:
+		rows, err := s.db.Queries.SumAgentRunCostByModel(ctx, since.UnixMilli())
+		if err != nil {
+			return nil, fmt.Errorf("failed to sum cost by model: %w", err)
+		}
+		buckets := make([]CostBucket, len(rows))
+		for i, row := range rows {
+			key := row.Provider + "/" + row.Model
+			buckets[i] = CostBucket{
+				Key:              key,
+				Label:            key,
+				RunCount:         row.RunCount,
+				Cost:             row.Cost,
+				PromptTokens:     row.PromptTokens,
+				CompletionTokens: row.CompletionTokens,
+			}
+		}
+		return buckets, nil
+	default:
+		return nil, fmt.Errorf("unknown cost grouping: %s", groupBy)
+	}
+}
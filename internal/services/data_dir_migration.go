@@ -0,0 +1,199 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/revrost/counterspell/internal/db/sqlc"
+)
+
+// MoveDataDir moves the data directory from to to, e.g. onto a bigger disk.
+// The server must not be running. to must not exist yet or be empty. A
+// rename is tried first; across filesystems the tree is copied instead and
+// copied reports true: from is then left in place for the caller to remove
+// once the new copy has been checked.
+func MoveDataDir(from, to string) (copied bool, err error) {
+	from, err = filepath.Abs(from)
+	if err != nil {
+		return false, err
+	}
+	to, err = filepath.Abs(to)
+	if err != nil {
+		return false, err
+	}
+	if info, err := os.Stat(from); err != nil {
+		return false, fmt.Errorf("data dir: %w", err)
+	} else if !info.IsDir() {
+		return false, fmt.Errorf("data dir %s is not a directory", from)
+	}
+	if from == to || isWithin(from, to) || isWithin(to, from) {
+		return false, fmt.Errorf("%s and %s overlap", from, to)
+	}
+	if entries, err := os.ReadDir(to); err == nil {
+		if len(entries) > 0 {
+			return false, fmt.Errorf("%s is not empty", to)
+		}
+		if err := os.Remove(to); err != nil {
+			return false, err
+		}
+	} else if !os.IsNotExist(err) {
+		return false, err
+	}
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return false, err
+	}
+
+	err = os.Rename(from, to)
+	if err == nil {
+		return false, nil
+	}
+	if !errors.Is(err, syscall.EXDEV) {
+		return false, err
+	}
+	if err := copyTree(from, to); err != nil {
+		_ = os.RemoveAll(to)
+		return false, fmt.Errorf("failed to copy data dir: %w", err)
+	}
+	return true, nil
+}
+
+// isWithin reports whether path is inside dir.
+func isWithin(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// copyTree copies the directory from to to, keeping file modes and symlinks.
+func copyTree(from, to string) error {
+	return filepath.WalkDir(from, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(from, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(to, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case info.Mode()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case info.Mode().IsRegular():
+			return copyFile(path, target, info.Mode().Perm())
+		default:
+			// Sockets and the like don't survive a restart anyway
+			return nil
+		}
+	})
+}
+
+func copyFile(from, to string, mode fs.FileMode) error {
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(to, os.O_CREATE|os.O_WRONLY|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		_ = dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+// RepairWorktrees re-links the task worktrees under dataDir/worktrees with
+// their base repository after the data dir moved: each records its own
+// absolute path in the base repo, which `git worktree repair` run inside it
+// updates. It returns how many worktrees it repaired. Clone workspaces hold
+// no such path and are skipped.
+func RepairWorktrees(ctx context.Context, dataDir string) (int, error) {
+	markers, err := filepath.Glob(filepath.Join(dataDir, "worktrees", "*", ".git"))
+	if err != nil {
+		return 0, err
+	}
+	repaired := 0
+	var failed []string
+	for _, marker := range markers {
+		if info, err := os.Lstat(marker); err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		worktree := filepath.Dir(marker)
+		if err := repairWorktree(ctx, worktree); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", filepath.Base(worktree), err))
+			continue
+		}
+		repaired++
+	}
+	if len(failed) > 0 {
+		return repaired, fmt.Errorf("failed to repair worktrees: %s", strings.Join(failed, "; "))
+	}
+	return repaired, nil
+}
+
+// repairWorktree repairs one worktree, then checks its base repo entry
+// points back at it.
+func repairWorktree(ctx context.Context, worktree string) error {
+	if out, err := exec.CommandContext(ctx, "git", "-C", worktree, "worktree", "repair").CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	out, err := exec.CommandContext(ctx, "git", "-C", worktree, "rev-parse", "--absolute-git-dir").Output()
+	if err != nil {
+		return fmt.Errorf("base repository not found: %w", err)
+	}
+	recorded, err := os.ReadFile(filepath.Join(strings.TrimSpace(string(out)), "gitdir"))
+	if err != nil {
+		return err
+	}
+	want, err := filepath.EvalSymlinks(filepath.Join(worktree, ".git"))
+	if err != nil {
+		return err
+	}
+	got, err := filepath.EvalSymlinks(strings.TrimSpace(string(recorded)))
+	if err != nil || got != want {
+		return fmt.Errorf("base repository still points at %s", strings.TrimSpace(string(recorded)))
+	}
+	return nil
+}
+
+// RelocateArtifacts rewrites the stored paths of truncated message and diff
+// artifacts under oldDir to point under newDir, after the data dir moved.
+// It returns how many paths it changed.
+func (s *Repository) RelocateArtifacts(ctx context.Context, oldDir, newDir string) (int64, error) {
+	oldPrefix := filepath.Clean(oldDir) + string(filepath.Separator)
+	newPrefix := filepath.Clean(newDir) + string(filepath.Separator)
+	messages, err := s.db.Queries.RelocateMessageArtifacts(ctx, sqlc.RelocateMessageArtifactsParams{
+		NewPrefix: newPrefix,
+		OldPrefix: oldPrefix,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to relocate message artifacts: %w", err)
+	}
+	diffs, err := s.db.Queries.RelocateTaskDiffArtifacts(ctx, sqlc.RelocateTaskDiffArtifactsParams{
+		NewPrefix: newPrefix,
+		OldPrefix: oldPrefix,
+	})
+	if err != nil {
+		return messages, fmt.Errorf("failed to relocate diff artifacts: %w", err)
+	}
+	return messages + diffs, nil
+}
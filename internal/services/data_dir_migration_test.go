@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMoveDataDir_RepairsWorktrees(t *testing.T) {
+	ctx := context.Background()
+	repoRoot := initTestGitRepo(t)
+	from := filepath.Join(t.TempDir(), "data")
+	to := filepath.Join(t.TempDir(), "disk", "data")
+
+	gm := NewGitManager(repoRoot, from)
+	oldPath, err := gm.CreateWorkspace(ctx, "task-1", TaskBranchName("task-1"), "")
+	require.NoError(t, err)
+
+	copied, err := MoveDataDir(from, to)
+	require.NoError(t, err)
+	assert.False(t, copied)
+	assert.NoDirExists(t, from)
+
+	repaired, err := RepairWorktrees(ctx, to)
+	require.NoError(t, err)
+	assert.Equal(t, 1, repaired)
+
+	// The base repo finds the worktree at its new path, so a prune keeps it
+	runGit(t, repoRoot, "worktree", "prune")
+	newPath := filepath.Join(to, "worktrees", filepath.Base(oldPath))
+	assert.Contains(t, runGit(t, repoRoot, "worktree", "list", "--porcelain"), newPath)
+	assert.Equal(t, TaskBranchName("task-1"), runGit(t, newPath, "rev-parse", "--abbrev-ref", "HEAD"))
+}
+
+func TestMoveDataDir_Refuses(t *testing.T) {
+	from := t.TempDir()
+	nonEmpty := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(nonEmpty, "keep"), []byte("x"), 0644))
+
+	_, err := MoveDataDir(from, nonEmpty)
+	assert.ErrorContains(t, err, "not empty")
+	_, err = MoveDataDir(from, filepath.Join(from, "nested"))
+	assert.ErrorContains(t, err, "overlap")
+	_, err = MoveDataDir(filepath.Join(from, "missing"), t.TempDir())
+	assert.Error(t, err)
+}
+
+func TestCopyTree(t *testing.T) {
+	from := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(from, "outputs", "task-1"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(from, "outputs", "task-1", "diff.patch"), []byte("diff"), 0600))
+	require.NoError(t, os.Symlink("outputs", filepath.Join(from, "link")))
+
+	to := filepath.Join(t.TempDir(), "data")
+	require.NoError(t, copyTree(from, to))
+
+	data, err := os.ReadFile(filepath.Join(to, "outputs", "task-1", "diff.patch"))
+	require.NoError(t, err)
+	assert.Equal(t, "diff", string(data))
+	info, err := os.Stat(filepath.Join(to, "outputs", "task-1", "diff.patch"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	link, err := os.Readlink(filepath.Join(to, "link"))
+	require.NoError(t, err)
+	assert.Equal(t, "outputs", link)
+}
+
+func TestRelocateArtifacts(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+	repo := NewRepository(testDB)
+	ctx := context.Background()
+
+	require.NoError(t, repo.SaveDiff(ctx, "task-1", "diff", "/old/data/outputs/task-1/diff.patch", nil))
+	require.NoError(t, repo.SaveDiff(ctx, "task-2", "diff", "/old/data2/outputs/task-2/diff.patch", nil))
+
+	n, err := repo.RelocateArtifacts(ctx, "/old/data", "/new/data")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	path, err := repo.GetSavedDiffArtifact(ctx, "task-1")
+	require.NoError(t, err)
+	assert.Equal(t, "/new/data/outputs/task-1/diff.patch", path)
+	// Only paths under the old dir itself change
+	path, err = repo.GetSavedDiffArtifact(ctx, "task-2")
+	require.NoError(t, err)
+	assert.Equal(t, "/old/data2/outputs/task-2/diff.patch", path)
+}
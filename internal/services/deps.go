@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Dependency describes an external binary the server shells out to.
+type Dependency struct {
+	Name       string `json:"name"`
+	Required   bool   `json:"required"`
+	Configured bool   `json:"configured"` // used by the configured agent backend
+	Found      bool   `json:"found"`
+	Path       string `json:"path,omitempty"`
+	Version    string `json:"version,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// DependencyReport is the result of a dependency self-check.
+type DependencyReport struct {
+	OK           bool         `json:"ok"` // false if a required dependency is missing
+	Dependencies []Dependency `json:"dependencies"`
+}
+
+// Missing returns the dependencies that were not found and matter for the
+// current configuration (required or used by the configured backend).
+func (r DependencyReport) Missing() []Dependency {
+	var missing []Dependency
+	for _, d := range r.Dependencies {
+		if !d.Found && (d.Required || d.Configured) {
+			missing = append(missing, d)
+		}
+	}
+	return missing
+}
+
+// backendBinaries maps agent backends to the CLI they execute.
+var backendBinaries = map[string]string{
+	"claude-code": "claude",
+	"codex":       "codex",
+}
+
+// CheckDependencies probes for git (required) and the agent CLIs, recording
+// their paths and versions. agentBackend marks which CLI is actually in use.
+func CheckDependencies(ctx context.Context, agentBackend string) DependencyReport {
+	report := DependencyReport{OK: true}
+
+	report.Dependencies = append(report.Dependencies, probeBinary(ctx, "git", true, true))
+	for _, backend := range []string{"claude-code", "codex"} {
+		bin := backendBinaries[backend]
+		report.Dependencies = append(report.Dependencies, probeBinary(ctx, bin, false, backend == agentBackend))
+	}
+	report.Dependencies = append(report.Dependencies, probeBinary(ctx, "jj", false, false))
+
+	for _, d := range report.Dependencies {
+		if d.Required && !d.Found {
+			report.OK = false
+		}
+	}
+	return report
+}
+
+// probeBinary looks up name on PATH and runs `name --version`.
+func probeBinary(ctx context.Context, name string, required, configured bool) Dependency {
+	dep := Dependency{Name: name, Required: required, Configured: configured}
+
+	path, err := exec.LookPath(name)
+	if err != nil {
+		dep.Error = "not found in PATH"
+		return dep
+	}
+	dep.Found = true
+	dep.Path = path
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, path, "--version").Output()
+	if err != nil {
+		dep.Error = "version check failed: " + err.Error()
+		return dep
+	}
+	version, _, _ := strings.Cut(strings.TrimSpace(string(output)), "\n")
+	dep.Version = version
+	return dep
+}
@@ -0,0 +1,31 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckDependencies(t *testing.T) {
+	report := CheckDependencies(context.Background(), "codex")
+	require.True(t, report.OK, "git must be available in the test environment")
+
+	byName := map[string]Dependency{}
+	for _, d := range report.Dependencies {
+		byName[d.Name] = d
+	}
+	require.True(t, byName["git"].Required)
+	require.True(t, byName["git"].Found)
+	require.NotEmpty(t, byName["git"].Version)
+	require.True(t, byName["codex"].Configured)
+	require.False(t, byName["claude"].Configured)
+}
+
+func TestCheckDependenciesMissingGit(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	report := CheckDependencies(context.Background(), "native")
+	require.False(t, report.OK)
+	require.Len(t, report.Missing(), 1)
+	require.Equal(t, "git", report.Missing()[0].Name)
+}
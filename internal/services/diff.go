@@ -0,0 +1,466 @@
+package services
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Diff line kinds.
+const (
+	DiffLineContext   = "context"
+	DiffLineAdd       = "add"
+	DiffLineDel       = "del"
+	DiffLineCollapsed = "collapsed" // a run of unchanged lines, see DiffLine.Hidden
+)
+
+// Diff file statuses for changes that aren't plain edits.
+const (
+	DiffFileRenamed = "renamed"
+	DiffFileCopied  = "copied"
+)
+
+// DiffFile is one file of a parsed unified diff.
+type DiffFile struct {
+	OldPath string `json:"old_path"` // "/dev/null" for added files
+	NewPath string `json:"new_path"` // "/dev/null" for deleted files
+	// Status is DiffFileRenamed or DiffFileCopied when git detected one,
+	// with Similarity the percentage of content kept.
+	Status     string `json:"status,omitempty"`
+	Similarity int    `json:"similarity,omitempty"`
+	// Binary files have no hunks, only a marker that they changed.
+	Binary bool       `json:"binary,omitempty"`
+	Hunks  []DiffHunk `json:"hunks"`
+}
+
+// DiffHunk is one "@@" section of a file diff.
+type DiffHunk struct {
+	Header   string     `json:"header"`
+	OldStart int        `json:"old_start"`
+	NewStart int        `json:"new_start"`
+	Lines    []DiffLine `json:"lines"`
+}
+
+// DiffLine is a single line of a hunk. Line numbers are 1-based and 0 when
+// the line doesn't exist on that side. A collapsed line stands for Count
+// unchanged lines starting at OldLine/NewLine; they are kept in Hidden so the
+// client can expand them without another request.
+type DiffLine struct {
+	Kind    string     `json:"kind"`
+	Content string     `json:"content,omitempty"`
+	OldLine int        `json:"old_line,omitempty"`
+	NewLine int        `json:"new_line,omitempty"`
+	Count   int        `json:"count,omitempty"`
+	Hidden  []DiffLine `json:"hidden,omitempty"`
+	// Segments splits a changed line that pairs with a line on the other
+	// side into unchanged and changed spans. Empty when the change is
+	// highlighted as a whole line.
+	Segments []DiffSegment `json:"segments,omitempty"`
+}
+
+// DiffSegment is a span of a changed line.
+type DiffSegment struct {
+	Text    string `json:"text"`
+	Changed bool   `json:"changed,omitempty"`
+}
+
+// DiffOptions controls how ParseDiff shapes hunks for display.
+type DiffOptions struct {
+	// CollapseAfter collapses runs of more than this many unchanged lines,
+	// keeping Context lines at each end. 0 disables collapsing.
+	CollapseAfter int
+	Context       int
+}
+
+// DefaultDiffOptions collapses unchanged runs longer than 8 lines down to 3
+// lines of context around each change.
+var DefaultDiffOptions = DiffOptions{CollapseAfter: 8, Context: 3}
+
+// ParseDiff parses git's unified diff output into files and hunks.
+func ParseDiff(diff string, opts DiffOptions) []DiffFile {
+	files := []DiffFile{}
+	var file *DiffFile
+	var hunk *DiffHunk
+	oldLine, newLine := 0, 0
+
+	flushHunk := func() {
+		if file != nil && hunk != nil {
+			highlightWords(hunk.Lines)
+			hunk.Lines = collapseContext(hunk.Lines, opts)
+			file.Hunks = append(file.Hunks, *hunk)
+		}
+		hunk = nil
+	}
+	flushFile := func() {
+		flushHunk()
+		if file != nil {
+			files = append(files, *file)
+		}
+		file = nil
+	}
+
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			flushFile()
+			file = &DiffFile{Hunks: []DiffHunk{}}
+			if a, b, ok := parseDiffGitPaths(line); ok {
+				file.OldPath, file.NewPath = a, b
+			}
+		case file == nil:
+			// Preamble before the first file header
+		case hunk == nil && strings.HasPrefix(line, "--- "):
+			file.OldPath = trimDiffPath(line[4:])
+		case hunk == nil && strings.HasPrefix(line, "+++ "):
+			file.NewPath = trimDiffPath(line[4:])
+		case strings.HasPrefix(line, "@@"):
+			flushHunk()
+			hunk = &DiffHunk{Header: line}
+			hunk.OldStart, hunk.NewStart = parseHunkHeader(line)
+			oldLine, newLine = hunk.OldStart, hunk.NewStart
+		case hunk == nil:
+			parseExtendedHeader(file, line)
+		case strings.HasPrefix(line, "+"):
+			hunk.Lines = append(hunk.Lines, DiffLine{Kind: DiffLineAdd, Content: line[1:], NewLine: newLine})
+			newLine++
+		case strings.HasPrefix(line, "-"):
+			hunk.Lines = append(hunk.Lines, DiffLine{Kind: DiffLineDel, Content: line[1:], OldLine: oldLine})
+			oldLine++
+		case strings.HasPrefix(line, " "):
+			hunk.Lines = append(hunk.Lines, DiffLine{Kind: DiffLineContext, Content: line[1:], OldLine: oldLine, NewLine: newLine})
+			oldLine++
+			newLine++
+		}
+	}
+	flushFile()
+	return files
+}
+
+// parseExtendedHeader records what the extended header lines between
+// "diff --git" and the first hunk say about file: renames, copies, added or
+// deleted files and binary changes. index and mode lines are ignored.
+func parseExtendedHeader(file *DiffFile, line string) {
+	switch {
+	case strings.HasPrefix(line, "rename from "):
+		file.Status, file.OldPath = DiffFileRenamed, line[len("rename from "):]
+	case strings.HasPrefix(line, "rename to "):
+		file.Status, file.NewPath = DiffFileRenamed, line[len("rename to "):]
+	case strings.HasPrefix(line, "copy from "):
+		file.Status, file.OldPath = DiffFileCopied, line[len("copy from "):]
+	case strings.HasPrefix(line, "copy to "):
+		file.Status, file.NewPath = DiffFileCopied, line[len("copy to "):]
+	case strings.HasPrefix(line, "similarity index "):
+		file.Similarity, _ = strconv.Atoi(strings.TrimSuffix(line[len("similarity index "):], "%"))
+	case strings.HasPrefix(line, "new file mode "):
+		// Binary and empty files have no ---/+++ lines to say so
+		file.OldPath = "/dev/null"
+	case strings.HasPrefix(line, "deleted file mode "):
+		file.NewPath = "/dev/null"
+	case strings.HasPrefix(line, "Binary files ") && strings.HasSuffix(line, " differ"),
+		line == "GIT binary patch":
+		file.Binary = true
+	}
+}
+
+// collapseContext replaces long runs of context lines with a collapsed line,
+// keeping opts.Context lines next to each change. Runs at the start or end of
+// a hunk only keep context on the side facing the change.
+func collapseContext(lines []DiffLine, opts DiffOptions) []DiffLine {
+	if opts.CollapseAfter <= 0 {
+		return lines
+	}
+	out := make([]DiffLine, 0, len(lines))
+	for i := 0; i < len(lines); {
+		if lines[i].Kind != DiffLineContext {
+			out = append(out, lines[i])
+			i++
+			continue
+		}
+		j := i
+		for j < len(lines) && lines[j].Kind == DiffLineContext {
+			j++
+		}
+		run := lines[i:j]
+		if len(run) <= opts.CollapseAfter {
+			out = append(out, run...)
+			i = j
+			continue
+		}
+
+		keepBefore, keepAfter := opts.Context, opts.Context
+		if i == 0 {
+			keepBefore = 0
+		}
+		if j == len(lines) {
+			keepAfter = 0
+		}
+		hidden := run[keepBefore : len(run)-keepAfter]
+		if len(hidden) == 0 {
+			out = append(out, run...)
+			i = j
+			continue
+		}
+		out = append(out, run[:keepBefore]...)
+		out = append(out, DiffLine{
+			Kind:    DiffLineCollapsed,
+			OldLine: hidden[0].OldLine,
+			NewLine: hidden[0].NewLine,
+			Count:   len(hidden),
+			Hidden:  hidden,
+		})
+		out = append(out, run[len(run)-keepAfter:]...)
+		i = j
+	}
+	return out
+}
+
+// maxWordDiffTokens bounds the O(n*m) token comparison per line pair.
+const maxWordDiffTokens = 200
+
+// highlightWords pairs each block of deleted lines with the block of added
+// lines right after it and, when both have the same number of lines, marks
+// the changed tokens of each pair. Blocks that don't pair up, and pairs that
+// share too little to be worth it, keep whole-line highlighting.
+func highlightWords(lines []DiffLine) {
+	for i := 0; i < len(lines); {
+		if lines[i].Kind != DiffLineDel {
+			i++
+			continue
+		}
+		delStart := i
+		for i < len(lines) && lines[i].Kind == DiffLineDel {
+			i++
+		}
+		addStart := i
+		for i < len(lines) && lines[i].Kind == DiffLineAdd {
+			i++
+		}
+		dels, adds := lines[delStart:addStart], lines[addStart:i]
+		if len(dels) != len(adds) {
+			continue
+		}
+		for k := range dels {
+			dels[k].Segments, adds[k].Segments = wordDiff(dels[k].Content, adds[k].Content)
+		}
+	}
+}
+
+// wordDiff returns the segments of old and new with tokens not in their
+// longest common subsequence marked as changed. It returns nil segments when
+// the lines are too long or have less than half their tokens in common.
+func wordDiff(oldText, newText string) ([]DiffSegment, []DiffSegment) {
+	a, b := tokenize(oldText), tokenize(newText)
+	if len(a) == 0 || len(b) == 0 || len(a) > maxWordDiffTokens || len(b) > maxWordDiffTokens {
+		return nil, nil
+	}
+
+	// lcs[i][j] is the LCS length of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	if 2*lcs[0][0] < max(len(a), len(b)) {
+		return nil, nil
+	}
+
+	var oldSegs, newSegs []DiffSegment
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			oldSegs = appendSegment(oldSegs, a[i], false)
+			newSegs = appendSegment(newSegs, b[j], false)
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+			newSegs = appendSegment(newSegs, b[j], true)
+			j++
+		default:
+			oldSegs = appendSegment(oldSegs, a[i], true)
+			i++
+		}
+	}
+	return oldSegs, newSegs
+}
+
+// appendSegment appends text, merging it into the last segment when both
+// have the same changed state.
+func appendSegment(segs []DiffSegment, text string, changed bool) []DiffSegment {
+	if n := len(segs); n > 0 && segs[n-1].Changed == changed {
+		segs[n-1].Text += text
+		return segs
+	}
+	return append(segs, DiffSegment{Text: text, Changed: changed})
+}
+
+// tokenize splits s into runs of word characters, runs of whitespace, and
+// single punctuation characters.
+func tokenize(s string) []string {
+	var tokens []string
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		j := i + 1
+		switch {
+		case isWordRune(runes[i]):
+			for j < len(runes) && isWordRune(runes[j]) {
+				j++
+			}
+		case unicode.IsSpace(runes[i]):
+			for j < len(runes) && unicode.IsSpace(runes[j]) {
+				j++
+			}
+		}
+		tokens = append(tokens, string(runes[i:j]))
+		i = j
+	}
+	return tokens
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// parseDiffGitPaths extracts the paths from "diff --git a/x b/y". Paths with
+// spaces are ambiguous here; the ---/+++ lines override them when present.
+func parseDiffGitPaths(line string) (string, string, bool) {
+	rest := strings.TrimPrefix(line, "diff --git ")
+	idx := strings.Index(rest, " b/")
+	if idx < 0 || !strings.HasPrefix(rest, "a/") {
+		return "", "", false
+	}
+	return rest[2:idx], rest[idx+3:], true
+}
+
+// trimDiffPath strips the a/ or b/ prefix and any trailing tab-separated
+// timestamp from a ---/+++ path.
+func trimDiffPath(p string) string {
+	p, _, _ = strings.Cut(p, "\t")
+	if p == "/dev/null" {
+		return p
+	}
+	if strings.HasPrefix(p, "a/") || strings.HasPrefix(p, "b/") {
+		return p[2:]
+	}
+	return p
+}
+
+// parseHunkHeader returns the old and new start lines of "@@ -a,b +c,d @@".
+func parseHunkHeader(line string) (int, int) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 {
+		return 0, 0
+	}
+	return parseHunkStart(fields[1], "-"), parseHunkStart(fields[2], "+")
+}
+
+func parseHunkStart(field, prefix string) int {
+	field = strings.TrimPrefix(field, prefix)
+	start, _, _ := strings.Cut(field, ",")
+	n, err := strconv.Atoi(start)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// DiffStat summarizes a diff per file, for showing "5 files, +120/-30"
+// without loading the diff itself.
+type DiffStat struct {
+	Files          []FileStat `json:"files"`
+	TotalAdditions int        `json:"total_additions"`
+	TotalDeletions int        `json:"total_deletions"`
+}
+
+// FileStat is the added and deleted line counts of one file. Binary files
+// count as zero lines.
+type FileStat struct {
+	Path      string `json:"path"`
+	Additions int    `json:"additions"`
+	Deletions int    `json:"deletions"`
+	Binary    bool   `json:"binary,omitempty"`
+}
+
+func (s *DiffStat) add(file FileStat) {
+	s.Files = append(s.Files, file)
+	s.TotalAdditions += file.Additions
+	s.TotalDeletions += file.Deletions
+}
+
+// ParseNumstat parses the output of git diff --numstat.
+func ParseNumstat(output string) *DiffStat {
+	stat := &DiffStat{Files: []FileStat{}}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		file := FileStat{Path: fields[2]}
+		if fields[0] == "-" && fields[1] == "-" {
+			file.Binary = true
+		} else {
+			file.Additions, _ = strconv.Atoi(fields[0])
+			file.Deletions, _ = strconv.Atoi(fields[1])
+		}
+		stat.add(file)
+	}
+	return stat
+}
+
+// CountDiffLines returns the added plus deleted lines of a unified diff
+// without parsing it.
+func CountDiffLines(diff string) int {
+	n := 0
+	for line := range strings.SplitSeq(diff, "\n") {
+		if isDiffFileHeader(line) {
+			continue
+		}
+		if strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-") {
+			n++
+		}
+	}
+	return n
+}
+
+// isDiffFileHeader reports whether line is a "--- a/x" or "+++ b/x" header
+// rather than a changed line.
+func isDiffFileHeader(line string) bool {
+	for _, prefix := range []string{"--- a/", "+++ b/", "--- /dev/null", "+++ /dev/null"} {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// DiffStatFromPatch counts the lines of a unified diff, for saved diffs
+// whose workspace is gone.
+func DiffStatFromPatch(diff string) *DiffStat {
+	stat := &DiffStat{Files: []FileStat{}}
+	for _, file := range ParseDiff(diff, DiffOptions{}) {
+		fileStat := FileStat{Path: file.NewPath, Binary: file.Binary}
+		if fileStat.Path == "/dev/null" {
+			fileStat.Path = file.OldPath
+		}
+		for _, hunk := range file.Hunks {
+			for _, line := range hunk.Lines {
+				switch line.Kind {
+				case DiffLineAdd:
+					fileStat.Additions++
+				case DiffLineDel:
+					fileStat.Deletions++
+				}
+			}
+		}
+		stat.add(fileStat)
+	}
+	return stat
+}
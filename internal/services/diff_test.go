@@ -0,0 +1,195 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDiff(t *testing.T) {
+	diff := `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -10,4 +10,4 @@ func main() {
+ 	a := 1
+-	b := 2
++	b := 3
+ 	c := 4
+ 	d := 5
+diff --git a/new.txt b/new.txt
+new file mode 100644
+--- /dev/null
++++ b/new.txt
+@@ -0,0 +1 @@
++hello
+`
+	files := ParseDiff(diff, DefaultDiffOptions)
+	require.Len(t, files, 2)
+
+	assert.Equal(t, "main.go", files[0].OldPath)
+	require.Len(t, files[0].Hunks, 1)
+	hunk := files[0].Hunks[0]
+	assert.Equal(t, 10, hunk.OldStart)
+	require.Len(t, hunk.Lines, 5)
+	assert.Equal(t, DiffLineDel, hunk.Lines[1].Kind)
+	assert.Equal(t, "\tb := 2", hunk.Lines[1].Content)
+	assert.Equal(t, 11, hunk.Lines[1].OldLine)
+	assert.Equal(t, DiffLineAdd, hunk.Lines[2].Kind)
+	assert.Equal(t, 11, hunk.Lines[2].NewLine)
+	assert.Zero(t, hunk.Lines[2].OldLine)
+	assert.Equal(t, 13, hunk.Lines[4].NewLine)
+
+	assert.Equal(t, "/dev/null", files[1].OldPath)
+	assert.Equal(t, "new.txt", files[1].NewPath)
+}
+
+func TestParseDiff_RenamesAndBinaries(t *testing.T) {
+	diff := `diff --git a/old_name.go b/new_name.go
+similarity index 100%
+rename from old_name.go
+rename to new_name.go
+diff --git a/lib/a.go b/lib/b.go
+similarity index 90%
+copy from lib/a.go
+copy to lib/b.go
+--- a/lib/a.go
++++ b/lib/b.go
+@@ -1 +1 @@
+-package a
++package b
+diff --git a/logo.png b/logo.png
+index 1111111..2222222 100644
+Binary files a/logo.png and b/logo.png differ
+diff --git a/icon.png b/icon.png
+new file mode 100644
+index 0000000..3333333
+Binary files /dev/null and b/icon.png differ
+`
+	files := ParseDiff(diff, DefaultDiffOptions)
+	require.Len(t, files, 4)
+
+	assert.Equal(t, DiffFile{
+		OldPath: "old_name.go", NewPath: "new_name.go",
+		Status: DiffFileRenamed, Similarity: 100, Hunks: []DiffHunk{},
+	}, files[0])
+
+	assert.Equal(t, DiffFileCopied, files[1].Status)
+	assert.Equal(t, 90, files[1].Similarity)
+	assert.Equal(t, "lib/a.go", files[1].OldPath)
+	assert.Equal(t, "lib/b.go", files[1].NewPath)
+	assert.Len(t, files[1].Hunks, 1)
+
+	assert.True(t, files[2].Binary)
+	assert.Equal(t, "logo.png", files[2].OldPath)
+	assert.Empty(t, files[2].Hunks)
+
+	assert.True(t, files[3].Binary)
+	assert.Equal(t, "/dev/null", files[3].OldPath)
+	assert.Equal(t, "icon.png", files[3].NewPath)
+}
+
+func TestParseDiff_CollapsesLongContext(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("diff --git a/f b/f\n--- a/f\n+++ b/f\n@@ -1,42 +1,42 @@\n")
+	b.WriteString("-old first\n+new first\n")
+	for i := 2; i <= 41; i++ {
+		fmt.Fprintf(&b, " line %d\n", i)
+	}
+	b.WriteString("-old last\n+new last\n")
+
+	lines := ParseDiff(b.String(), DefaultDiffOptions)[0].Hunks[0].Lines
+	// 2 changed + 3 context + collapsed + 3 context + 2 changed
+	require.Len(t, lines, 11)
+	collapsed := lines[5]
+	assert.Equal(t, DiffLineCollapsed, collapsed.Kind)
+	assert.Equal(t, 34, collapsed.Count)
+	assert.Equal(t, 5, collapsed.OldLine)
+	require.Len(t, collapsed.Hidden, 34)
+	assert.Equal(t, "line 5", collapsed.Hidden[0].Content)
+	assert.Equal(t, "line 39", lines[6].Content)
+
+	// Short runs and disabled collapsing are left alone
+	lines = ParseDiff(b.String(), DiffOptions{})[0].Hunks[0].Lines
+	assert.Len(t, lines, 44)
+}
+
+func TestParseDiff_WordHighlight(t *testing.T) {
+	diff := `diff --git a/f b/f
+--- a/f
++++ b/f
+@@ -1,3 +1,2 @@
+-timeout := 30 * time.Second
++timeout := 60 * time.Second
+-fmt.Println("a")
+-fmt.Println("b")
++log.Print("ab")
+`
+	lines := ParseDiff(diff, DefaultDiffOptions)[0].Hunks[0].Lines
+	require.Len(t, lines, 5)
+
+	assert.Equal(t, []DiffSegment{
+		{Text: "timeout := "},
+		{Text: "30", Changed: true},
+		{Text: " * time.Second"},
+	}, lines[0].Segments)
+	assert.Equal(t, []DiffSegment{
+		{Text: "timeout := "},
+		{Text: "60", Changed: true},
+		{Text: " * time.Second"},
+	}, lines[1].Segments)
+
+	// Two deleted lines against one added line don't pair up
+	for _, l := range lines[2:] {
+		assert.Empty(t, l.Segments)
+	}
+}
+
+func TestWordDiff_UnrelatedLines(t *testing.T) {
+	oldSegs, newSegs := wordDiff("return nil", "for _, f := range files {")
+	assert.Nil(t, oldSegs)
+	assert.Nil(t, newSegs)
+}
+
+func TestParseNumstat(t *testing.T) {
+	stat := ParseNumstat("10\t2\tmain.go\n-\t-\tlogo.png\n3\t0\tdocs/new.md\n")
+	assert.Equal(t, []FileStat{
+		{Path: "main.go", Additions: 10, Deletions: 2},
+		{Path: "logo.png", Binary: true},
+		{Path: "docs/new.md", Additions: 3},
+	}, stat.Files)
+	assert.Equal(t, 13, stat.TotalAdditions)
+	assert.Equal(t, 2, stat.TotalDeletions)
+
+	empty := ParseNumstat("")
+	assert.Empty(t, empty.Files)
+	assert.NotNil(t, empty.Files)
+}
+
+func TestDiffStatFromPatch(t *testing.T) {
+	diff := `diff --git a/main.go b/main.go
+--- a/main.go
++++ b/main.go
+@@ -1,3 +1,3 @@
+ a
+-b
++c
++d
+diff --git a/old.txt b/old.txt
+deleted file mode 100644
+--- a/old.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-gone
+`
+	stat := DiffStatFromPatch(diff)
+	assert.Equal(t, []FileStat{
+		{Path: "main.go", Additions: 2, Deletions: 1},
+		{Path: "old.txt", Deletions: 1},
+	}, stat.Files)
+	assert.Equal(t, 2, stat.TotalAdditions)
+	assert.Equal(t, 2, stat.TotalDeletions)
+}
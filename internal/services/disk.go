@@ -0,0 +1,59 @@
+package services
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// DataDirUsage reports how much disk the data directory uses.
+type DataDirUsage struct {
+	Path           string `json:"path"`
+	TotalBytes     int64  `json:"total_bytes"`
+	WorkspaceBytes int64  `json:"workspace_bytes"` // task worktrees and clones
+}
+
+// GetDataDirUsage walks dataDir and sums file sizes. It can be slow on large
+// data dirs, so call it on demand rather than on a hot path.
+func GetDataDirUsage(dataDir string) (DataDirUsage, error) {
+	abs, err := filepath.Abs(dataDir)
+	if err != nil {
+		abs = dataDir
+	}
+	usage := DataDirUsage{Path: abs}
+
+	if usage.TotalBytes, err = dirSize(abs); err != nil {
+		return usage, err
+	}
+	if usage.WorkspaceBytes, err = dirSize(filepath.Join(abs, "worktrees")); err != nil {
+		return usage, err
+	}
+	return usage, nil
+}
+
+// dirSize returns the total size of regular files under root. A missing root
+// counts as empty; files that vanish mid-walk are skipped.
+func dirSize(root string) (int64, error) {
+	var total int64
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		total += info.Size()
+		return nil
+	})
+	return total, err
+}
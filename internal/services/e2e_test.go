@@ -0,0 +1,441 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/revrost/counterspell/internal/agent"
+	"github.com/revrost/counterspell/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// e2eHarness runs the real orchestrator and GitManager against a local bare
+// repository standing in for GitHub, with a scripted agent.MockBackend in
+// place of an LLM.
+type e2eHarness struct {
+	orch   *Orchestrator
+	repo   *Repository
+	remote string
+	root   string
+
+	mu       sync.Mutex
+	events   []models.Event
+	backends []*agent.MockBackend
+}
+
+func newE2EHarness(t *testing.T, opts ...agent.MockBackendOption) *e2eHarness {
+	t.Helper()
+	testDB := setupTestDB(t)
+	t.Cleanup(testDB.Close)
+
+	remote := filepath.Join(t.TempDir(), "remote.git")
+	runGit(t, initTestGitRepo(t), "clone", "--bare", ".", remote)
+	root := filepath.Join(t.TempDir(), "root")
+	runGit(t, filepath.Dir(root), "clone", remote, root)
+	runGit(t, root, "config", "user.name", "test")
+	runGit(t, root, "config", "user.email", "test@example.com")
+
+	ctx := context.Background()
+	settings := NewSettingsService(testDB)
+	require.NoError(t, settings.UpdateSettings(ctx, &Settings{AgentBackend: "native", AnthropicKey: "test-key"}))
+
+	h := &e2eHarness{remote: remote, root: root}
+	eventBus := NewEventBus()
+	orch, err := NewOrchestrator(NewRepository(testDB), eventBus, settings, nil,
+		NewGitManager(root, t.TempDir()),
+		WithBackendFactory(func(workDir, systemPrompt string) (agent.Backend, error) {
+			backend := agent.NewMockBackend(append([]agent.MockBackendOption{agent.WithMockWorkDir(workDir)}, opts...)...)
+			h.mu.Lock()
+			h.backends = append(h.backends, backend)
+			h.mu.Unlock()
+			return backend, nil
+		}),
+	)
+	require.NoError(t, err)
+	t.Cleanup(orch.Shutdown)
+
+	h.orch, h.repo = orch, orch.repo
+	sub := eventBus.Subscribe()
+	t.Cleanup(func() { eventBus.Unsubscribe(sub) })
+	go func() {
+		for event := range sub {
+			h.mu.Lock()
+			h.events = append(h.events, event)
+			h.mu.Unlock()
+		}
+	}()
+	return h
+}
+
+// waitForStatus waits until the task reaches status.
+func (h *e2eHarness) waitForStatus(t *testing.T, taskID, status string) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		task, err := h.repo.Get(context.Background(), taskID)
+		return err == nil && task.Status == status
+	}, 10*time.Second, 20*time.Millisecond)
+}
+
+func (h *e2eHarness) eventTypes(taskID string) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var types []string
+	for _, event := range h.events {
+		if event.TaskID == taskID {
+			types = append(types, event.Type)
+		}
+	}
+	return types
+}
+
+func TestE2E_RunCommitDiffReviewMerge(t *testing.T) {
+	h := newE2EHarness(t,
+		agent.WithMockFiles(map[string]string{"hello.txt": "hello from the agent\n"}),
+		agent.WithMockEvents(agent.MockTextMessage("msg-1", "Added hello.txt")...),
+	)
+	ctx := context.Background()
+
+	taskID, err := h.orch.StartTask(ctx, "proj-1", "add a greeting file", "")
+	require.NoError(t, err)
+	h.waitForStatus(t, taskID, "review")
+
+	// The agent's edit is committed on the task branch and saved as the diff
+	diff, err := h.repo.GetSavedDiff(ctx, taskID)
+	require.NoError(t, err)
+	assert.Contains(t, diff, "+hello from the agent")
+	stat, err := h.orch.repoManager.DiffStat(ctx, taskID)
+	require.NoError(t, err)
+	assert.Equal(t, []FileStat{{Path: "hello.txt", Additions: 1}}, stat.Files)
+	assert.Equal(t, "Task: add a greeting file",
+		runGit(t, h.root, "log", "-1", "--format=%s", TaskBranchName(taskID)))
+	task, err := h.repo.Get(ctx, taskID)
+	require.NoError(t, err)
+	assert.False(t, task.NoChanges)
+
+	// The streamed reply is persisted and the UI was told about the run
+	messages, err := h.repo.GetMessagesByTask(ctx, taskID)
+	require.NoError(t, err)
+	var contents []string
+	for _, msg := range messages {
+		contents = append(contents, msg.Role+": "+msg.Content)
+	}
+	assert.Contains(t, contents, "user: add a greeting file")
+	assert.Contains(t, contents, "assistant: Added hello.txt")
+	require.Eventually(t, func() bool {
+		types := h.eventTypes(taskID)
+		return slices.Contains(types, string(EventTypeTaskStarted)) &&
+			slices.Contains(types, string(EventTypeAgentRunStarted))
+	}, 5*time.Second, 20*time.Millisecond)
+
+	// Approving the review lands the change on the remote's main
+	_, err = h.orch.MergeTask(ctx, taskID)
+	require.NoError(t, err)
+	h.waitForStatus(t, taskID, "done")
+	assert.Equal(t, "hello from the agent", runGit(t, h.remote, "show", "main:hello.txt"))
+}
+
+func TestE2E_ContinueRestoresConversation(t *testing.T) {
+	h := newE2EHarness(t,
+		agent.WithMockFiles(map[string]string{"hello.txt": "hello from the agent\n"}),
+		agent.WithMockEvents(agent.MockTextMessage("msg-1", "Added hello.txt")...),
+	)
+	ctx := context.Background()
+
+	taskID, err := h.orch.StartTask(ctx, "proj-1", "add a greeting file", "")
+	require.NoError(t, err)
+	h.waitForStatus(t, taskID, "review")
+
+	require.NoError(t, h.orch.ContinueTask(ctx, taskID, "now make it louder", ""))
+	require.Eventually(t, func() bool {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		return len(h.backends) == 2 && len(h.backends[1].Tasks()) == 1
+	}, 10*time.Second, 20*time.Millisecond)
+	h.waitForStatus(t, taskID, "review")
+
+	// The second run starts from the first run's conversation, not just the follow-up
+	var texts []string
+	for _, msg := range h.backends[1].Messages() {
+		for _, block := range msg.Content {
+			texts = append(texts, msg.Role+": "+block.Text)
+		}
+	}
+	assert.Equal(t, []string{
+		"user: add a greeting file",
+		"assistant: Added hello.txt",
+		"user: now make it louder",
+		"assistant: Added hello.txt",
+	}, texts)
+}
+
+func TestE2E_FailedRun(t *testing.T) {
+	h := newE2EHarness(t,
+		agent.WithMockEvents(agent.MockTextMessage("msg-1", "Trying")...),
+		agent.WithMockError(errors.New("tool crashed")),
+	)
+	ctx := context.Background()
+
+	taskID, err := h.orch.StartTask(ctx, "proj-1", "do something", "")
+	require.NoError(t, err)
+	h.waitForStatus(t, taskID, "failed")
+
+	diff, err := h.repo.GetSavedDiff(ctx, taskID)
+	require.NoError(t, err)
+	assert.Empty(t, diff)
+}
+
+func TestE2E_NoChangesRun(t *testing.T) {
+	h := newE2EHarness(t,
+		agent.WithMockEvents(agent.MockTextMessage("msg-1", "The greeting is already there")...),
+	)
+	ctx := context.Background()
+
+	taskID, err := h.orch.StartTask(ctx, "proj-1", "add a greeting file", "")
+	require.NoError(t, err)
+	h.waitForStatus(t, taskID, "review")
+
+	// The run is flagged, and the agent's explanation is still kept
+	task, err := h.repo.Get(ctx, taskID)
+	require.NoError(t, err)
+	assert.True(t, task.NoChanges)
+	messages, err := h.repo.GetMessagesByTask(ctx, taskID)
+	require.NoError(t, err)
+	var contents []string
+	for _, msg := range messages {
+		contents = append(contents, msg.Content)
+	}
+	assert.Contains(t, contents, "The greeting is already there")
+}
+
+func TestE2E_InterruptRestartsUnsteerableBackend(t *testing.T) {
+	h := newE2EHarness(t,
+		agent.WithMockEvents(agent.MockTextMessage("msg-1", "Working on it")...),
+		agent.WithMockHold(),
+	)
+	ctx := context.Background()
+
+	taskID, err := h.orch.StartTask(ctx, "proj-1", "add a greeting file", "")
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		return len(h.backends) == 1 && len(h.backends[0].Tasks()) == 1
+	}, 10*time.Second, 20*time.Millisecond)
+
+	// The mock backend can't be steered, so the run is stopped and the task
+	// continued with the message
+	injected, err := h.orch.InterruptTask(ctx, taskID, "also update the tests")
+	require.NoError(t, err)
+	assert.False(t, injected)
+	require.Eventually(t, func() bool {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		return len(h.backends) == 2 && len(h.backends[1].Tasks()) == 1
+	}, 10*time.Second, 20*time.Millisecond)
+	assert.Equal(t, []string{"also update the tests"}, h.backends[1].Tasks())
+
+	h.orch.CancelTask(taskID)
+	h.waitForStatus(t, taskID, "failed")
+	require.Eventually(t, func() bool { return !h.orch.isActive(taskID) }, 5*time.Second, 20*time.Millisecond)
+	_, err = h.orch.InterruptTask(ctx, taskID, "too late")
+	assert.ErrorAs(t, err, &ErrTaskNotRunning{})
+}
+
+func TestE2E_KillRunningTask(t *testing.T) {
+	h := newE2EHarness(t,
+		agent.WithMockEvents(agent.MockTextMessage("msg-1", "Working on it")...),
+		agent.WithMockHold(),
+	)
+	ctx := context.Background()
+
+	taskID, err := h.orch.StartTask(ctx, "proj-1", "add a greeting file", "")
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		return len(h.backends) == 1 && len(h.backends[0].Tasks()) == 1
+	}, 10*time.Second, 20*time.Millisecond)
+
+	running := h.orch.RunningTasks()
+	require.Len(t, running, 1)
+	assert.Equal(t, taskID, running[0].TaskID)
+	assert.False(t, running[0].Queued)
+	assert.False(t, running[0].StartedAt.IsZero())
+	assert.Equal(t, string(agent.BackendMock), running[0].Backend)
+
+	require.NoError(t, h.orch.KillTask(taskID))
+	h.waitForStatus(t, taskID, "failed")
+	require.Eventually(t, func() bool { return len(h.orch.RunningTasks()) == 0 }, 5*time.Second, 20*time.Millisecond)
+	assert.ErrorAs(t, h.orch.KillTask(taskID), &ErrTaskNotRunning{})
+}
+
+func TestE2E_LiveDiff(t *testing.T) {
+	h := newE2EHarness(t,
+		agent.WithMockFiles(map[string]string{"hello.txt": "hello from the agent\n"}),
+		agent.WithMockEvents(append(
+			[]agent.StreamEvent{{Type: agent.EventFileEdit, Path: "hello.txt"}},
+			agent.MockTextMessage("msg-1", "Added hello.txt")...)...),
+	)
+	ctx := context.Background()
+
+	taskID, err := h.orch.StartTask(ctx, "proj-1", "add a greeting file", "")
+	require.NoError(t, err)
+	h.waitForStatus(t, taskID, "review")
+
+	// The edit was diffed against the base while the run was going
+	var update string
+	require.Eventually(t, func() bool {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		for _, event := range h.events {
+			if event.TaskID == taskID && event.Type == string(EventTypeDiffUpdate) {
+				update = event.Data
+				return true
+			}
+		}
+		return false
+	}, 5*time.Second, 20*time.Millisecond)
+	assert.Contains(t, update, `"path":"hello.txt"`)
+	assert.Contains(t, update, "hello from the agent")
+
+	// The numstat is saved with the final diff
+	stat, err := h.repo.GetSavedDiffStat(ctx, taskID)
+	require.NoError(t, err)
+	require.NotNil(t, stat)
+	assert.Equal(t, []FileStat{{Path: "hello.txt", Additions: 1}}, stat.Files)
+}
+
+func TestE2E_BaseBranch(t *testing.T) {
+	h := newE2EHarness(t,
+		agent.WithMockFiles(map[string]string{"hello.txt": "hello from the agent\n"}),
+	)
+	ctx := context.Background()
+
+	// Give the remote a release branch that main doesn't have
+	runGit(t, h.root, "checkout", "-b", "release")
+	runGit(t, h.root, "commit", "--allow-empty", "-m", "release only")
+	runGit(t, h.root, "push", "origin", "release")
+	runGit(t, h.root, "checkout", "main")
+	mainHead := runGit(t, h.remote, "rev-parse", "main")
+
+	taskID, err := h.orch.StartTaskFromBranch(ctx, "proj-1", "add a greeting file", "", "release")
+	require.NoError(t, err)
+	h.waitForStatus(t, taskID, "review")
+
+	task, err := h.repo.Get(ctx, taskID)
+	require.NoError(t, err)
+	require.NotNil(t, task.BaseBranch)
+	assert.Equal(t, "release", *task.BaseBranch)
+	assert.Equal(t, "release only",
+		runGit(t, h.root, "log", "-1", "--format=%s", TaskBranchName(taskID)+"~1"))
+	diff, err := h.repo.GetSavedDiff(ctx, taskID)
+	require.NoError(t, err)
+	assert.Contains(t, diff, "+hello from the agent")
+
+	// The merge lands on the base branch and leaves main alone
+	_, err = h.orch.MergeTask(ctx, taskID)
+	require.NoError(t, err)
+	h.waitForStatus(t, taskID, "done")
+	assert.Equal(t, "hello from the agent", runGit(t, h.remote, "show", "release:hello.txt"))
+	assert.Equal(t, mainHead, runGit(t, h.remote, "rev-parse", "main"))
+}
+
+func TestE2E_BaseBranchValidation(t *testing.T) {
+	h := newE2EHarness(t)
+	ctx := context.Background()
+
+	for _, branch := range []string{"missing", "-x", "a..b"} {
+		_, err := h.orch.StartTaskFromBranch(ctx, "proj-1", "do something", "", branch)
+		var invalid ErrInvalidBaseBranch
+		require.ErrorAs(t, err, &invalid, branch)
+		assert.Equal(t, branch, invalid.Branch)
+	}
+
+	tasks, err := h.repo.List(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, tasks)
+}
+
+func TestE2E_IterationLimitRecordedOnRun(t *testing.T) {
+	notice := "iteration limit reached: stopped after 3 LLM calls"
+	h := newE2EHarness(t,
+		agent.WithMockFiles(map[string]string{"hello.txt": "partial\n"}),
+		agent.WithMockEvents(append(agent.MockTextMessage("msg-1", "Still working"),
+			agent.StreamEvent{Type: agent.EventIterationLimit, Notice: notice})...),
+	)
+	ctx := context.Background()
+
+	taskID, err := h.orch.StartTask(ctx, "proj-1", "loop", "")
+	require.NoError(t, err)
+	// The run finishes with the work done so far
+	h.waitForStatus(t, taskID, "review")
+
+	details, err := h.repo.GetTaskWithDetails(ctx, taskID)
+	require.NoError(t, err)
+	require.Len(t, details.AgentRuns, 1)
+	require.NotNil(t, details.AgentRuns[0].LimitReached)
+	assert.Equal(t, notice, *details.AgentRuns[0].LimitReached)
+}
+
+func TestE2E_ArtifactsVersionedAndNotCommitted(t *testing.T) {
+	h := newE2EHarness(t,
+		agent.WithMockFiles(map[string]string{
+			"hello.txt":                   "hello from the agent\n",
+			ArtifactsDir + "/build.log":   "build ok\n",
+			ArtifactsDir + "/blob.bin":    "\xff\xfe\x00",
+			ArtifactsDir + "/report.md":   "# Report\n",
+			ArtifactsDir + "/nested/x.md": "nested\n",
+		}),
+	)
+	ctx := context.Background()
+
+	taskID, err := h.orch.StartTask(ctx, "proj-1", "build it", "")
+	require.NoError(t, err)
+	h.waitForStatus(t, taskID, "review")
+
+	artifacts, err := h.repo.ListArtifacts(ctx, taskID)
+	require.NoError(t, err)
+	paths := make([]string, 0, len(artifacts))
+	for _, artifact := range artifacts {
+		paths = append(paths, artifact.Path)
+		assert.Equal(t, int64(1), artifact.Version)
+		assert.Empty(t, artifact.Content)
+	}
+	assert.ElementsMatch(t, []string{"build.log", "report.md", "nested/x.md"}, paths)
+
+	// Artifacts are handed back, not committed
+	diff, err := h.repo.GetSavedDiff(ctx, taskID)
+	require.NoError(t, err)
+	assert.Contains(t, diff, "+hello from the agent")
+	assert.NotContains(t, diff, ".counterspell")
+
+	// A second run writing the same paths adds new versions
+	require.NoError(t, h.orch.ContinueTask(ctx, taskID, "build again", ""))
+	require.Eventually(t, func() bool {
+		artifacts, err = h.repo.ListArtifacts(ctx, taskID)
+		return err == nil && len(artifacts) == 6
+	}, 10*time.Second, 20*time.Millisecond)
+
+	var latest models.Artifact
+	for _, artifact := range artifacts {
+		if artifact.Path == "build.log" && artifact.Version > latest.Version {
+			latest = artifact
+		}
+	}
+	assert.Equal(t, int64(2), latest.Version)
+	got, err := h.repo.GetArtifact(ctx, taskID, latest.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, "build ok\n", got.Content)
+
+	// Artifacts are scoped to their task
+	got, err = h.repo.GetArtifact(ctx, "other-task", latest.ID)
+	require.NoError(t, err)
+	assert.Nil(t, got)
+}
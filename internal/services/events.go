@@ -19,6 +19,7 @@ const (
 	EventTypeTaskStarted     EventType = "task_started"
 	EventTypeLog             EventType = "log"
 	EventTypeAgentUpdate     EventType = "agent_update"
+	EventTypeDiffUpdate      EventType = "diff_update"
 )
 
 // EventBus handles pub/sub for real-time events via SSE.
@@ -34,6 +35,11 @@ type EventBus struct {
 	eventLog   map[string][]models.Event
 	eventLogMu sync.RWMutex
 
+	// feedLog is a ring of the most recent events across all tasks, used to
+	// replay the feed stream when a client reconnects with Last-Event-ID.
+	feedLog   []models.Event
+	feedLogMu sync.RWMutex
+
 	// lastAgentState stores the most recent agent_update for each task
 	// This is the full message history JSON for quick reconnection
 	lastAgentState   map[string]string
@@ -42,6 +48,7 @@ type EventBus struct {
 
 const (
 	maxEventsPerTask = 100 // Keep last 100 events per task
+	maxFeedEvents    = 500 // Keep last 500 events across all tasks
 	eventLogTTL      = 30 * time.Minute
 )
 
@@ -79,8 +86,18 @@ func (b *EventBus) cleanupLoop() {
 
 // Publish sends an event to all subscribers.
 func (b *EventBus) Publish(event models.Event) {
-	// Assign sequence ID for deduplication
+	// Assign sequence ID for deduplication. Done under feedLogMu so the feed
+	// ring stays ordered by ID.
+	b.feedLogMu.Lock()
 	event.ID = atomic.AddInt64(&b.sequence, 1)
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	b.feedLog = append(b.feedLog, event)
+	if len(b.feedLog) > maxFeedEvents {
+		b.feedLog = b.feedLog[len(b.feedLog)-maxFeedEvents:]
+	}
+	b.feedLogMu.Unlock()
 
 	// Store event in log for reconnection replay
 	if event.TaskID != "" {
@@ -144,6 +161,41 @@ func (b *EventBus) GetEventsSince(taskID string, lastEventID int64) []models.Eve
 	return result
 }
 
+// TaskEvents returns a copy of the events retained for a task, oldest first,
+// for debugging what the UI was sent.
+func (b *EventBus) TaskEvents(taskID string) []models.Event {
+	b.eventLogMu.RLock()
+	defer b.eventLogMu.RUnlock()
+	return append([]models.Event(nil), b.eventLog[taskID]...)
+}
+
+// GetFeedEventsSince returns all retained events (for any task) since the
+// given event ID. complete is false when the ring no longer holds every event
+// after lastEventID, or when lastEventID was issued by a previous process; the
+// caller should then tell the client to resync from the API.
+func (b *EventBus) GetFeedEventsSince(lastEventID int64) (events []models.Event, complete bool) {
+	b.feedLogMu.RLock()
+	defer b.feedLogMu.RUnlock()
+
+	if lastEventID > atomic.LoadInt64(&b.sequence) {
+		return append([]models.Event(nil), b.feedLog...), false
+	}
+
+	complete = true
+	for i, e := range b.feedLog {
+		if e.ID <= lastEventID {
+			continue
+		}
+		// The oldest retained event must directly follow lastEventID,
+		// otherwise events were evicted in between.
+		if i == 0 && e.ID > lastEventID+1 {
+			complete = false
+		}
+		events = append(events, e)
+	}
+	return events, complete
+}
+
 // GetLastEventID returns the most recent event ID for a task.
 func (b *EventBus) GetLastEventID(taskID string) int64 {
 	b.eventLogMu.RLock()
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/revrost/counterspell/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetFeedEventsSince(t *testing.T) {
+	b := NewEventBus()
+	defer b.Shutdown()
+
+	b.Publish(models.Event{TaskID: "a", Type: string(EventTypeTaskStarted)})
+	b.Publish(models.Event{TaskID: "b", Type: string(EventTypeTaskStarted)})
+	b.Publish(models.Event{TaskID: "a", Type: string(EventTypeTaskUpdated)})
+
+	events, complete := b.GetFeedEventsSince(1)
+	require.True(t, complete)
+	require.Len(t, events, 2)
+	assert.Equal(t, int64(2), events[0].ID)
+	assert.Equal(t, "b", events[0].TaskID)
+	assert.Equal(t, int64(3), events[1].ID)
+
+	events, complete = b.GetFeedEventsSince(3)
+	assert.True(t, complete)
+	assert.Empty(t, events)
+
+	// An id from a previous server process can't be resumed from.
+	events, complete = b.GetFeedEventsSince(99)
+	assert.False(t, complete)
+	assert.Len(t, events, 3)
+}
+
+func TestGetFeedEventsSince_EvictedGap(t *testing.T) {
+	b := NewEventBus()
+	defer b.Shutdown()
+
+	for range maxFeedEvents + 10 {
+		b.Publish(models.Event{TaskID: "a", Type: string(EventTypeLog)})
+	}
+
+	events, complete := b.GetFeedEventsSince(5)
+	assert.False(t, complete)
+	assert.Len(t, events, maxFeedEvents)
+
+	events, complete = b.GetFeedEventsSince(10)
+	assert.True(t, complete)
+	assert.Len(t, events, maxFeedEvents)
+}
+
+func TestTaskEvents(t *testing.T) {
+	b := NewEventBus()
+	defer b.Shutdown()
+
+	b.Publish(models.Event{TaskID: "a", Type: string(EventTypeTaskStarted)})
+	b.Publish(models.Event{TaskID: "b", Type: string(EventTypeTaskStarted)})
+	b.Publish(models.Event{TaskID: "a", Type: string(EventTypeLog), Data: "hello"})
+
+	events := b.TaskEvents("a")
+	require.Len(t, events, 2)
+	assert.Equal(t, int64(1), events[0].ID)
+	assert.Equal(t, "hello", events[1].Data)
+	for _, e := range events {
+		assert.False(t, e.CreatedAt.IsZero())
+	}
+
+	// The result is a copy
+	events[0].Type = "changed"
+	assert.Equal(t, string(EventTypeTaskStarted), b.TaskEvents("a")[0].Type)
+	assert.Empty(t, b.TaskEvents("missing"))
+}
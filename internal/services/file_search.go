@@ -0,0 +1,260 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fileListTTL bounds how stale a cached file list can get when nothing
+// invalidates it (e.g. files added to the base checkout by hand).
+const fileListTTL = time.Minute
+
+// fileListCache caches each repo's file list so search-as-you-type doesn't
+// walk the repo on every keystroke.
+type fileListCache struct {
+	mu      sync.Mutex
+	entries map[string]fileListEntry
+}
+
+type fileListEntry struct {
+	files    []string
+	loadedAt time.Time
+}
+
+func newFileListCache() *fileListCache {
+	return &fileListCache{entries: make(map[string]fileListEntry)}
+}
+
+// Get returns the cached file list for root, loading it with load when
+// missing or older than fileListTTL.
+func (c *fileListCache) Get(root string, load func(root string) ([]string, error)) ([]string, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[root]
+	c.mu.Unlock()
+	if ok && time.Since(entry.loadedAt) < fileListTTL {
+		return entry.files, nil
+	}
+
+	files, err := load(root)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.entries[root] = fileListEntry{files: files, loadedAt: time.Now()}
+	c.mu.Unlock()
+	return files, nil
+}
+
+// Invalidate drops the cached list for root, e.g. after a fetch or merge
+// changed the checkout.
+func (c *fileListCache) Invalidate(root string) {
+	c.mu.Lock()
+	delete(c.entries, root)
+	c.mu.Unlock()
+}
+
+// SearchProjectFiles searches for files in a project using fuzzy matching.
+// Returns a list of file paths relative to the repo root, sorted by match score.
+func (o *Orchestrator) SearchProjectFiles(ctx context.Context, projectID, query string, limit int) ([]string, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+
+	repoPath := o.repoManager.RootPath()
+	if repoPath == "" {
+		return nil, fmt.Errorf("repository root not found")
+	}
+	if _, err := os.Stat(repoPath); err != nil {
+		return nil, fmt.Errorf("repository root not found: %w", err)
+	}
+
+	files, err := o.fileLists.Get(repoPath, func(root string) ([]string, error) {
+		return listRepoFiles(ctx, root)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// If no query, return first N files sorted alphabetically
+	if query == "" {
+		if len(files) > limit {
+			files = files[:limit]
+		}
+		return files, nil
+	}
+
+	// Fuzzy search files
+	matches := fuzzySearch(files, query, limit)
+	return matches, nil
+}
+
+// listRepoFiles lists the repo's tracked and untracked-but-not-ignored files
+// with git ls-files, which honours .gitignore and avoids walking build output.
+// It falls back to walking the tree when git can't list the directory.
+func listRepoFiles(ctx context.Context, repoPath string) ([]string, error) {
+	cmd := exec.CommandContext(ctx, "git", "ls-files", "-z", "--cached", "--others", "--exclude-standard")
+	cmd.Dir = repoPath
+	output, err := cmd.Output()
+	if err != nil {
+		slog.Warn("[FILE] git ls-files failed, walking repo instead", "path", repoPath, "error", err)
+		return walkRepoFiles(repoPath)
+	}
+
+	files := []string{}
+	seen := make(map[string]struct{})
+	for _, f := range strings.Split(string(output), "\x00") {
+		if f == "" {
+			continue
+		}
+		// Files with unresolved conflicts are listed once per stage
+		if _, ok := seen[f]; ok {
+			continue
+		}
+		seen[f] = struct{}{}
+		files = append(files, f)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// walkRepoFiles lists the files under repoPath relative to it, skipping
+// hidden entries and common dependency/build directories.
+func walkRepoFiles(repoPath string) ([]string, error) {
+	var files []string
+	err := filepath.Walk(repoPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil // skip errors
+		}
+		// Skip hidden directories and common non-source dirs
+		name := info.Name()
+		if info.IsDir() {
+			if path != repoPath && (strings.HasPrefix(name, ".") || name == "node_modules" || name == "vendor" || name == "__pycache__" || name == "dist" || name == "build") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		// Skip hidden files
+		if strings.HasPrefix(name, ".") {
+			return nil
+		}
+		// Get relative path from repo root
+		relPath, _ := filepath.Rel(repoPath, path)
+		files = append(files, filepath.ToSlash(relPath))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk repo: %w", err)
+	}
+	return files, nil
+}
+
+// fuzzySearch performs fuzzy matching on file paths and returns top N matches.
+// Ties go to the shorter path, then alphabetical order.
+func fuzzySearch(files []string, query string, limit int) []string {
+	type scored struct {
+		path  string
+		score int
+	}
+
+	var results []scored
+	queryLower := strings.ToLower(query)
+
+	for _, f := range files {
+		fLower := strings.ToLower(f)
+		score := fuzzyScore(fLower, queryLower)
+		if score > 0 {
+			score += basenameBonus(path.Base(fLower), queryLower)
+			results = append(results, scored{path: f, score: score})
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].score != results[j].score {
+			return results[i].score > results[j].score
+		}
+		if len(results[i].path) != len(results[j].path) {
+			return len(results[i].path) < len(results[j].path)
+		}
+		return results[i].path < results[j].path
+	})
+
+	// Return top N
+	var out []string
+	for i := 0; i < len(results) && i < limit; i++ {
+		out = append(out, results[i].path)
+	}
+	return out
+}
+
+// basenameBonus rewards queries that match the file name itself, since that
+// is usually what the user is typing: an exact name (with or without
+// extension) beats a prefix, which beats a substring or a fuzzy match.
+func basenameBonus(base, query string) int {
+	stem := strings.TrimSuffix(base, path.Ext(base))
+	switch {
+	case base == query || stem == query:
+		return 40
+	case strings.HasPrefix(base, query):
+		return 25
+	case strings.Contains(base, query):
+		return 15
+	}
+	if s := fuzzyScore(base, query); s > 0 {
+		return 5 + s/2
+	}
+	return 0
+}
+
+// fuzzyScore computes a simple fuzzy match score.
+// Higher score = better match. 0 = no match.
+func fuzzyScore(text, pattern string) int {
+	if len(pattern) == 0 {
+		return 1
+	}
+	if len(text) == 0 {
+		return 0
+	}
+
+	score := 0
+	patternIdx := 0
+	prevMatchIdx := -1
+	consecutiveBonus := 0
+
+	for i := 0; i < len(text) && patternIdx < len(pattern); i++ {
+		if text[i] == pattern[patternIdx] {
+			score += 1
+
+			// Bonus for consecutive matches
+			if prevMatchIdx == i-1 {
+				consecutiveBonus++
+				score += consecutiveBonus
+			} else {
+				consecutiveBonus = 0
+			}
+
+			// Bonus for matching at start or after separator
+			if i == 0 || text[i-1] == '/' || text[i-1] == '_' || text[i-1] == '-' || text[i-1] == '.' {
+				score += 2
+			}
+
+			prevMatchIdx = i
+			patternIdx++
+		}
+	}
+
+	// All pattern characters must match
+	if patternIdx < len(pattern) {
+		return 0
+	}
+
+	return score
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFuzzySearch_PrefersBasenameAndShortPaths(t *testing.T) {
+	files := []string{
+		"internal/domain/maintenance/handlers/main_test_helpers.go",
+		"ui/src/lib/components/MainLayout.svelte",
+		"cmd/app/main.go",
+		"internal/remaining.go",
+		"docs/main.md",
+	}
+
+	got := fuzzySearch(files, "main", 10)
+	require.Len(t, got, 5)
+	// Exact stem matches first, shorter path wins the tie
+	assert.Equal(t, []string{"docs/main.md", "cmd/app/main.go"}, got[:2])
+	assert.Equal(t, "internal/remaining.go", got[4])
+
+	assert.Empty(t, fuzzySearch(files, "zzz", 10))
+	assert.Len(t, fuzzySearch(files, "main", 2), 2)
+}
+
+func TestFileListCache_Invalidate(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "a.go"), nil, 0644))
+
+	cache := newFileListCache()
+	loads := 0
+	load := func(root string) ([]string, error) {
+		loads++
+		return walkRepoFiles(root)
+	}
+
+	files, err := cache.Get(root, load)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.go"}, files)
+
+	require.NoError(t, os.WriteFile(filepath.Join(root, "b.go"), nil, 0644))
+	files, err = cache.Get(root, load)
+	require.NoError(t, err)
+	assert.Len(t, files, 1, "served from cache")
+
+	cache.Invalidate(root)
+	files, err = cache.Get(root, load)
+	require.NoError(t, err)
+	assert.Len(t, files, 2)
+	assert.Equal(t, 2, loads)
+}
+
+func benchmarkFiles(n int) []string {
+	files := make([]string, n)
+	for i := range files {
+		files[i] = fmt.Sprintf("pkg%d/sub%d/module_%d/file_%d.go", i%37, i%101, i%997, i)
+	}
+	return files
+}
+
+func BenchmarkFuzzySearch(b *testing.B) {
+	for _, n := range []int{1_000, 50_000} {
+		files := benchmarkFiles(n)
+		b.Run(fmt.Sprintf("files=%d", n), func(b *testing.B) {
+			for b.Loop() {
+				fuzzySearch(files, "mod12file", 20)
+			}
+		})
+	}
+}
+
+func TestListRepoFiles_RespectsGitignore(t *testing.T) {
+	root := initTestGitRepo(t)
+	require.NoError(t, os.WriteFile(filepath.Join(root, ".gitignore"), []byte("dist/\n"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "dist"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "dist", "bundle.js"), nil, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "untracked.go"), nil, 0644))
+
+	files, err := listRepoFiles(context.Background(), root)
+	require.NoError(t, err)
+	assert.Equal(t, []string{".gitignore", "README.md", "untracked.go"}, files)
+
+	// Not a git repo: falls back to walking
+	plain := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(plain, "a.go"), nil, 0644))
+	files, err = listRepoFiles(context.Background(), plain)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.go"}, files)
+}
@@ -4,10 +4,13 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -15,18 +18,66 @@ import (
 	"github.com/revrost/counterspell/internal/db/sqlc"
 )
 
+// DefaultGitHubHost is the host used when no GitHub Enterprise host is set.
+const DefaultGitHubHost = "github.com"
+
 type GitHubService struct {
 	db           *db.DB
 	clientID     string
 	clientSecret string
+	// webURL serves OAuth pages, apiURL the REST API, e.g.
+	// https://ghe.example.com and https://ghe.example.com/api/v3
+	webURL string
+	apiURL string
+	// protection caches BranchProtected results by "owner/repo/branch"
+	protection sync.Map
 }
 
-func NewGitHubService(database *db.DB, clientID, clientSecret string) *GitHubService {
-	return &GitHubService{
+// GitHubOption configures a GitHubService.
+type GitHubOption func(*GitHubService)
+
+// WithGitHubHost points the service at a GitHub Enterprise host. An empty
+// apiURL uses the host's standard /api/v3 endpoint.
+func WithGitHubHost(host, apiURL string) GitHubOption {
+	return func(s *GitHubService) {
+		s.webURL, s.apiURL = gitHubURLs(host, apiURL)
+	}
+}
+
+func NewGitHubService(database *db.DB, clientID, clientSecret string, opts ...GitHubOption) *GitHubService {
+	s := &GitHubService{
 		db:           database,
 		clientID:     clientID,
 		clientSecret: clientSecret,
 	}
+	s.webURL, s.apiURL = gitHubURLs(DefaultGitHubHost, "")
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// gitHubURLs returns the web and API base URLs for host. github.com serves
+// its API from api.github.com; Enterprise hosts serve it under /api/v3.
+func gitHubURLs(host, apiURL string) (string, string) {
+	host = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(host), "https://"), "/")
+	if host == "" {
+		host = DefaultGitHubHost
+	}
+	webURL := "https://" + host
+	if apiURL == "" {
+		if host == DefaultGitHubHost {
+			apiURL = "https://api.github.com"
+		} else {
+			apiURL = webURL + "/api/v3"
+		}
+	}
+	return webURL, strings.TrimSuffix(apiURL, "/")
+}
+
+// WebURL returns the base URL of the GitHub web UI, e.g. https://github.com.
+func (s *GitHubService) WebURL() string {
+	return s.webURL
 }
 
 func (s *GitHubService) ExchangeCode(ctx context.Context, code string) (string, error) {
@@ -35,7 +86,7 @@ func (s *GitHubService) ExchangeCode(ctx context.Context, code string) (string,
 	data.Set("client_secret", s.clientSecret)
 	data.Set("code", code)
 
-	req, err := http.NewRequestWithContext(ctx, "POST", "https://github.com/login/oauth/access_token", strings.NewReader(data.Encode()))
+	req, err := http.NewRequestWithContext(ctx, "POST", s.webURL+"/login/oauth/access_token", strings.NewReader(data.Encode()))
 	if err != nil {
 		return "", err
 	}
@@ -68,7 +119,7 @@ type GitHubUser struct {
 }
 
 func (s *GitHubService) GetGitHubUser(ctx context.Context, accessToken string) (*GitHubUser, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", "https://api.github.com/user", nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", s.apiURL+"/user", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -145,10 +196,12 @@ type GitHubRepo struct {
 	Private  bool   `json:"private"`
 	HTMLURL  string `json:"html_url"`
 	CloneURL string `json:"clone_url"`
+	Language string `json:"language"` // null when GitHub hasn't detected one
+	Size     int64  `json:"size"`     // in KB
 }
 
 func (s *GitHubService) FetchRepos(ctx context.Context, accessToken string) ([]GitHubRepo, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", "https://api.github.com/user/repos?visibility=all&affiliation=owner,collaborator", nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", s.apiURL+"/user/repos?visibility=all&affiliation=owner,collaborator", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -187,23 +240,8 @@ func (s *GitHubService) SyncRepos(ctx context.Context, connectionID string) erro
 	}
 
 	// Sync repos - upsert based on connection_id and full_name
-	now := time.Now().UnixMilli()
 	for _, r := range repos {
-		// Use GitHub repo ID as our database ID for consistency
-		repoID := fmt.Sprintf("%d", r.ID)
-		if _, err := s.db.Queries.UpsertRepository(ctx, sqlc.UpsertRepositoryParams{
-			ID:           repoID,
-			ConnectionID: connectionID,
-			Name:         r.Name,
-			FullName:     r.FullName,
-			Owner:        r.Owner.Login,
-			IsPrivate:    r.Private,
-			HtmlUrl:      r.HTMLURL,
-			CloneUrl:     r.CloneURL,
-			LocalPath:    sql.NullString{},
-			CreatedAt:    now,
-			UpdatedAt:    now,
-		}); err != nil {
+		if _, err := s.upsertRepo(ctx, connectionID, r); err != nil {
 			return fmt.Errorf("failed to upsert repo %s: %w", r.FullName, err)
 		}
 	}
@@ -211,6 +249,88 @@ func (s *GitHubService) SyncRepos(ctx context.Context, connectionID string) erro
 	return nil
 }
 
+func (s *GitHubService) upsertRepo(ctx context.Context, connectionID string, r GitHubRepo) (sqlc.Repository, error) {
+	now := time.Now().UnixMilli()
+	return s.db.Queries.UpsertRepository(ctx, sqlc.UpsertRepositoryParams{
+		// Use GitHub repo ID as our database ID for consistency
+		ID:           fmt.Sprintf("%d", r.ID),
+		ConnectionID: connectionID,
+		Name:         r.Name,
+		FullName:     r.FullName,
+		Owner:        r.Owner.Login,
+		IsPrivate:    r.Private,
+		HtmlUrl:      r.HTMLURL,
+		CloneUrl:     r.CloneURL,
+		LocalPath:    sql.NullString{},
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		Language:     r.Language,
+		SizeKb:       r.Size,
+	})
+}
+
+// ErrRepoNotAccessible is returned by RegisterRepo when the connected
+// account's token can't read the repository, or it doesn't exist.
+var ErrRepoNotAccessible = errors.New("repository not found or not accessible with the GitHub token")
+
+// FetchRepo looks up a single repository with accessToken.
+func (s *GitHubService) FetchRepo(ctx context.Context, accessToken, owner, repo string) (*GitHubRepo, error) {
+	apiURL := fmt.Sprintf("%s/repos/%s/%s", s.apiURL, url.PathEscape(owner), url.PathEscape(repo))
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusForbidden, http.StatusNotFound:
+		return nil, fmt.Errorf("%w: %s/%s", ErrRepoNotAccessible, owner, repo)
+	default:
+		return nil, fmt.Errorf("failed to get repo: %s", resp.Status)
+	}
+
+	var r GitHubRepo
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return nil, fmt.Errorf("failed to decode repo: %w", err)
+	}
+	return &r, nil
+}
+
+// RegisterRepo adds owner/repo as a project without a full repo sync, e.g.
+// an organization repository /user/repos doesn't list. The connected
+// account's token must be able to read it. Registering a project again
+// refreshes it.
+func (s *GitHubService) RegisterRepo(ctx context.Context, owner, repo string) (sqlc.Repository, error) {
+	conn, err := s.db.Queries.GetGithubConnection(ctx)
+	if err != nil {
+		return sqlc.Repository{}, fmt.Errorf("failed to get connection: %w", err)
+	}
+	r, err := s.FetchRepo(ctx, conn.AccessToken, owner, repo)
+	if err != nil {
+		return sqlc.Repository{}, err
+	}
+	return s.upsertRepo(ctx, conn.ID, *r)
+}
+
+// DeleteRepo removes a project. Its tasks are kept, without a project.
+func (s *GitHubService) DeleteRepo(ctx context.Context, id string) error {
+	if _, err := s.db.Queries.GetRepository(ctx, id); err != nil {
+		return err
+	}
+	if err := s.db.Queries.DetachRepositoryTasks(ctx, sql.NullString{String: id, Valid: true}); err != nil {
+		return fmt.Errorf("failed to detach tasks: %w", err)
+	}
+	return s.db.Queries.DeleteRepository(ctx, id)
+}
+
 func (s *GitHubService) GetRepos(ctx context.Context) ([]sqlc.Repository, error) {
 	conn, err := s.db.Queries.GetGithubConnection(ctx)
 	if err != nil {
@@ -223,8 +343,9 @@ func (s *GitHubService) GetConnection(ctx context.Context) (sqlc.GithubConnectio
 	return s.db.Queries.GetGithubConnection(ctx)
 }
 
-// CreatePullRequest creates a GitHub Pull Request.
-func (s *GitHubService) CreatePullRequest(ctx context.Context, owner, repo, branch, title, body string) (string, error) {
+// CreatePullRequest creates a GitHub Pull Request from branch into base,
+// or into main when base is empty.
+func (s *GitHubService) CreatePullRequest(ctx context.Context, owner, repo, branch, base, title, body string) (string, error) {
 	// Get connection
 	conn, err := s.db.Queries.GetGithubConnection(ctx)
 	if err != nil {
@@ -239,11 +360,14 @@ func (s *GitHubService) CreatePullRequest(ctx context.Context, owner, repo, bran
 		Base  string `json:"base"`
 	}
 
+	if base == "" {
+		base = "main"
+	}
 	prReq := PRRequest{
 		Title: title,
 		Body:  body,
 		Head:  branch,
-		Base:  "main",
+		Base:  base,
 	}
 
 	reqBody, err := json.Marshal(prReq)
@@ -252,7 +376,7 @@ func (s *GitHubService) CreatePullRequest(ctx context.Context, owner, repo, bran
 	}
 
 	// Create PR
-	apiURL := fmt.Sprintf("https://api.github.com/repos/%s/%s/pulls", owner, repo)
+	apiURL := fmt.Sprintf("%s/repos/%s/%s/pulls", s.apiURL, owner, repo)
 	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, strings.NewReader(string(reqBody)))
 	if err != nil {
 		return "", err
@@ -281,6 +405,301 @@ func (s *GitHubService) CreatePullRequest(ctx context.Context, owner, repo, bran
 	return result.HTMLURL, nil
 }
 
+// GitHubPullRequest is the part of a pull request the auto-merge uses.
+type GitHubPullRequest struct {
+	Number int64  `json:"number"`
+	State  string `json:"state"` // open or closed
+	Merged bool   `json:"merged"`
+	// MergeableState is "clean" when the PR can be merged and every check
+	// passed; "blocked", "unstable", "behind" and "dirty" say why not, and
+	// "unknown" means GitHub is still computing it.
+	MergeableState string `json:"mergeable_state"`
+	Head           struct {
+		Ref string `json:"ref"`
+		SHA string `json:"sha"`
+	} `json:"head"`
+}
+
+// GetPullRequest looks up pull request number of owner/repo.
+func (s *GitHubService) GetPullRequest(ctx context.Context, owner, repo string, number int64) (*GitHubPullRequest, error) {
+	conn, err := s.db.Queries.GetGithubConnection(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection: %w", err)
+	}
+
+	apiURL := fmt.Sprintf("%s/repos/%s/%s/pulls/%d", s.apiURL, owner, repo, number)
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+conn.AccessToken)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get PR: %s", resp.Status)
+	}
+
+	var pr GitHubPullRequest
+	if err := json.NewDecoder(resp.Body).Decode(&pr); err != nil {
+		return nil, fmt.Errorf("failed to decode PR: %w", err)
+	}
+	return &pr, nil
+}
+
+// MergePullRequest merges pull request number of owner/repo, as long as its
+// head is still sha; GitHub refuses the merge if it moved since.
+func (s *GitHubService) MergePullRequest(ctx context.Context, owner, repo string, number int64, sha string) error {
+	conn, err := s.db.Queries.GetGithubConnection(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+
+	reqBody, err := json.Marshal(map[string]string{"sha": sha})
+	if err != nil {
+		return fmt.Errorf("failed to marshal merge request: %w", err)
+	}
+
+	apiURL := fmt.Sprintf("%s/repos/%s/%s/pulls/%d/merge", s.apiURL, owner, repo, number)
+	req, err := http.NewRequestWithContext(ctx, "PUT", apiURL, strings.NewReader(string(reqBody)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+conn.AccessToken)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var result struct {
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&result)
+		if result.Message != "" {
+			return fmt.Errorf("failed to merge PR: %s: %s", resp.Status, result.Message)
+		}
+		return fmt.Errorf("failed to merge PR: %s", resp.Status)
+	}
+	return nil
+}
+
+// pullRequestNumber returns the number of the pull request at htmlURL, as
+// in https://github.com/owner/repo/pull/12.
+func pullRequestNumber(htmlURL string) (int64, bool) {
+	_, number, ok := strings.Cut(htmlURL, "/pull/")
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(strings.TrimRight(number, "/"), 10, 64)
+	return n, err == nil && n > 0
+}
+
+// branchProtectionTTL is how long BranchProtected trusts a cached answer;
+// protection rules rarely change, and a stale "unprotected" only costs a
+// failed push.
+const branchProtectionTTL = 10 * time.Minute
+
+type branchProtection struct {
+	protected bool
+	checkedAt time.Time
+}
+
+// BranchProtected reports whether branch of owner/repo has protection rules,
+// such as required reviews, that reject a direct push. The answer is cached
+// per branch for branchProtectionTTL.
+func (s *GitHubService) BranchProtected(ctx context.Context, owner, repo, branch string) (bool, error) {
+	key := owner + "/" + repo + "/" + branch
+	if cached, ok := s.protection.Load(key); ok {
+		if entry := cached.(branchProtection); time.Since(entry.checkedAt) < branchProtectionTTL {
+			return entry.protected, nil
+		}
+	}
+
+	conn, err := s.db.Queries.GetGithubConnection(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to get connection: %w", err)
+	}
+
+	// The branch endpoint reports protection to any reader; the protection
+	// endpoint itself needs admin rights.
+	apiURL := fmt.Sprintf("%s/repos/%s/%s/branches/%s", s.apiURL, owner, repo, url.PathEscape(branch))
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Authorization", "Bearer "+conn.AccessToken)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("failed to get branch: %s", resp.Status)
+	}
+
+	var result struct {
+		Protected bool `json:"protected"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, fmt.Errorf("failed to decode branch: %w", err)
+	}
+	s.protection.Store(key, branchProtection{protected: result.Protected, checkedAt: time.Now()})
+	return result.Protected, nil
+}
+
+// GitHubIssue is an open issue as returned by the issues API.
+type GitHubIssue struct {
+	Number int64  `json:"number"`
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+	// PullRequest is set when the "issue" is a pull request
+	PullRequest *struct{} `json:"pull_request,omitempty"`
+}
+
+// ListLabeledIssues returns the open issues of owner/repo carrying label,
+// oldest first. Pull requests, which the issues API also returns, are
+// skipped.
+func (s *GitHubService) ListLabeledIssues(ctx context.Context, owner, repo, label string) ([]GitHubIssue, error) {
+	conn, err := s.db.Queries.GetGithubConnection(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection: %w", err)
+	}
+
+	query := url.Values{}
+	query.Set("labels", label)
+	query.Set("state", "open")
+	query.Set("sort", "created")
+	query.Set("direction", "asc")
+	query.Set("per_page", "50")
+	apiURL := fmt.Sprintf("%s/repos/%s/%s/issues?%s", s.apiURL, owner, repo, query.Encode())
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+conn.AccessToken)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to list issues: %s", resp.Status)
+	}
+
+	var all []GitHubIssue
+	if err := json.NewDecoder(resp.Body).Decode(&all); err != nil {
+		return nil, fmt.Errorf("failed to decode issues: %w", err)
+	}
+	issues := make([]GitHubIssue, 0, len(all))
+	for _, issue := range all {
+		if issue.PullRequest == nil {
+			issues = append(issues, issue)
+		}
+	}
+	return issues, nil
+}
+
+// CommentOnIssue posts a comment on an issue or pull request.
+func (s *GitHubService) CommentOnIssue(ctx context.Context, owner, repo string, number int64, body string) error {
+	conn, err := s.db.Queries.GetGithubConnection(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+
+	reqBody, err := json.Marshal(map[string]string{"body": body})
+	if err != nil {
+		return fmt.Errorf("failed to marshal comment: %w", err)
+	}
+
+	apiURL := fmt.Sprintf("%s/repos/%s/%s/issues/%d/comments", s.apiURL, owner, repo, number)
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, strings.NewReader(string(reqBody)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+conn.AccessToken)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("failed to comment on issue: %s", resp.Status)
+	}
+	return nil
+}
+
+// CommitStatusContext labels the commit statuses posted for taskID's runs.
+func CommitStatusContext(taskID string) string {
+	return "counterspell/agent/" + taskID
+}
+
+// ErrStatusesForbidden is returned by CreateCommitStatus when the token may
+// not write commit statuses on the repository, e.g. it lacks the repo:status
+// scope.
+var ErrStatusesForbidden = errors.New("token cannot write commit statuses")
+
+// CreateCommitStatus sets the statusContext status of a commit. state is
+// "pending", "success", "failure" or "error".
+func (s *GitHubService) CreateCommitStatus(ctx context.Context, owner, repo, sha, statusContext, state, description string) error {
+	conn, err := s.db.Queries.GetGithubConnection(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+
+	reqBody, err := json.Marshal(map[string]string{
+		"state":       state,
+		"description": description,
+		"context":     statusContext,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal status: %w", err)
+	}
+
+	apiURL := fmt.Sprintf("%s/repos/%s/%s/statuses/%s", s.apiURL, owner, repo, sha)
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, strings.NewReader(string(reqBody)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+conn.AccessToken)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusCreated:
+		return nil
+	case http.StatusForbidden, http.StatusNotFound:
+		return fmt.Errorf("%w: %s", ErrStatusesForbidden, resp.Status)
+	default:
+		return fmt.Errorf("failed to create commit status: %s", resp.Status)
+	}
+}
+
 // GetUserInfo returns GitHub user info for the connected account.
 func (s *GitHubService) GetUserInfo(ctx context.Context) (*GitHubUser, error) {
 	conn, err := s.db.Queries.GetGithubConnection(ctx)
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/revrost/counterspell/internal/db/sqlc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitHubURLs(t *testing.T) {
+	tests := []struct {
+		name, host, apiURL string
+		wantWeb, wantAPI   string
+	}{
+		{"default", "", "", "https://github.com", "https://api.github.com"},
+		{"github.com", "github.com", "", "https://github.com", "https://api.github.com"},
+		{"enterprise", "ghe.internal.corp", "", "https://ghe.internal.corp", "https://ghe.internal.corp/api/v3"},
+		{"enterprise with scheme", "https://ghe.internal.corp/", "", "https://ghe.internal.corp", "https://ghe.internal.corp/api/v3"},
+		{"api override", "ghe.internal.corp", "https://api.ghe.internal.corp/", "https://ghe.internal.corp", "https://api.ghe.internal.corp"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			web, api := gitHubURLs(tt.host, tt.apiURL)
+			assert.Equal(t, tt.wantWeb, web)
+			assert.Equal(t, tt.wantAPI, api)
+		})
+	}
+}
+
+func TestRegisterAndDeleteRepo(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+	ctx := context.Background()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		if r.URL.Path != "/repos/acme/widgets" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`{"id": 42, "name": "widgets", "full_name": "acme/widgets", "owner": {"login": "acme"},
+			"private": true, "html_url": "https://github.com/acme/widgets", "clone_url": "https://github.com/acme/widgets.git"}`))
+	}))
+	defer srv.Close()
+	github := NewGitHubService(testDB, "", "", WithGitHubHost("", srv.URL))
+
+	_, err := testDB.Queries.CreateGithubConnection(ctx, sqlc.CreateGithubConnectionParams{
+		ID:           "conn-1",
+		GithubUserID: "user-1",
+		AccessToken:  "token",
+		Username:     "testuser",
+	})
+	require.NoError(t, err)
+
+	repo, err := github.RegisterRepo(ctx, "acme", "widgets")
+	require.NoError(t, err)
+	assert.Equal(t, "42", repo.ID)
+	assert.Equal(t, "acme/widgets", repo.FullName)
+	assert.True(t, repo.IsPrivate)
+
+	_, err = github.RegisterRepo(ctx, "acme", "secret")
+	assert.ErrorIs(t, err, ErrRepoNotAccessible)
+
+	// Deleting the project keeps its tasks
+	tasks := NewRepository(testDB)
+	task, err := tasks.Create(ctx, repo.ID, "fix the bug")
+	require.NoError(t, err)
+	require.NoError(t, github.DeleteRepo(ctx, repo.ID))
+	_, err = testDB.Queries.GetRepository(ctx, repo.ID)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	task, err = tasks.Get(ctx, task.ID)
+	require.NoError(t, err)
+	assert.Nil(t, task.RepositoryID)
+
+	assert.ErrorIs(t, github.DeleteRepo(ctx, repo.ID), sql.ErrNoRows)
+}
+
+func TestSyncReposRefreshesLanguageAndSize(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+	ctx := context.Background()
+
+	reposJSON := `[{"id": 42, "name": "widgets", "full_name": "acme/widgets", "owner": {"login": "acme"}, "language": "Go", "size": 2048},
+		{"id": 43, "name": "notes", "full_name": "acme/notes", "owner": {"login": "acme"}, "language": null, "size": 0}]`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(reposJSON))
+	}))
+	defer srv.Close()
+	github := NewGitHubService(testDB, "", "", WithGitHubHost("", srv.URL))
+
+	_, err := testDB.Queries.CreateGithubConnection(ctx, sqlc.CreateGithubConnectionParams{
+		ID:           "conn-1",
+		GithubUserID: "user-1",
+		AccessToken:  "token",
+		Username:     "testuser",
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, github.SyncRepos(ctx, "conn-1"))
+	repos, err := github.GetRepos(ctx)
+	require.NoError(t, err)
+	require.Len(t, repos, 2)
+	assert.Equal(t, "", repos[0].Language)
+	assert.Equal(t, "Go", repos[1].Language)
+	assert.Equal(t, int64(2048), repos[1].SizeKb)
+
+	reposJSON = `[{"id": 42, "name": "widgets", "full_name": "acme/widgets", "owner": {"login": "acme"}, "language": "Rust", "size": 4096}]`
+	require.NoError(t, github.SyncRepos(ctx, "conn-1"))
+	repo, err := testDB.Queries.GetRepository(ctx, "42")
+	require.NoError(t, err)
+	assert.Equal(t, "Rust", repo.Language)
+	assert.Equal(t, int64(4096), repo.SizeKb)
+}
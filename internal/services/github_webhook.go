@@ -0,0 +1,230 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/revrost/counterspell/internal/models"
+)
+
+// ErrWebhookSignature is returned for a webhook delivery whose signature
+// doesn't match the configured secret.
+var ErrWebhookSignature = errors.New("invalid webhook signature")
+
+// Pull request states recorded on tasks.
+const (
+	PRStateOpen   = "open"
+	PRStateMerged = "merged"
+	PRStateClosed = "closed"
+)
+
+// VerifyGitHubWebhook checks the X-Hub-Signature-256 header of a GitHub
+// webhook delivery: the HMAC-SHA256 of the body keyed with the webhook
+// secret.
+func VerifyGitHubWebhook(secret string, body []byte, signature string) error {
+	hexSig, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok || secret == "" {
+		return ErrWebhookSignature
+	}
+	got, err := hex.DecodeString(hexSig)
+	if err != nil {
+		return ErrWebhookSignature
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return ErrWebhookSignature
+	}
+	return nil
+}
+
+// PullRequestEvent is the part of a GitHub pull_request webhook payload the
+// task sync uses.
+type PullRequestEvent struct {
+	Action      string `json:"action"`
+	PullRequest struct {
+		HTMLURL string `json:"html_url"`
+		Merged  bool   `json:"merged"`
+		Head    struct {
+			Ref string `json:"ref"`
+		} `json:"head"`
+	} `json:"pull_request"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+// State is the PR state the event leaves behind, or "" for actions that
+// don't change it (edits, new pushes, reviews).
+func (e PullRequestEvent) State() string {
+	switch e.Action {
+	case "opened", "reopened":
+		return PRStateOpen
+	case "closed":
+		if e.PullRequest.Merged {
+			return PRStateMerged
+		}
+		return PRStateClosed
+	}
+	return ""
+}
+
+// SyncPullRequest applies a pull_request webhook event to the task whose
+// branch the PR is from and returns the task's ID, or "" when the PR isn't
+// from a task branch or the event changes nothing. A merged PR moves a task
+// still in review to done. A PR closed without merging only records the
+// state: the task keeps its branch and workspace for another attempt.
+func (o *Orchestrator) SyncPullRequest(ctx context.Context, event PullRequestEvent) (string, error) {
+	state := event.State()
+	taskID, ok := strings.CutPrefix(event.PullRequest.Head.Ref, TaskBranchName(""))
+	if state == "" || !ok || taskID == "" {
+		return "", nil
+	}
+	task, err := o.repo.Get(ctx, taskID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	// Branch names are only unique per repository
+	if task.RepositoryName != nil && !strings.EqualFold(*task.RepositoryName, event.Repository.FullName) {
+		return "", nil
+	}
+
+	if err := o.repo.UpdateTaskPRState(ctx, taskID, state); err != nil {
+		return "", err
+	}
+	if task.PRURL == nil && event.PullRequest.HTMLURL != "" {
+		// Opened by hand from the task branch
+		if err := o.repo.UpdateTaskPRURL(ctx, taskID, event.PullRequest.HTMLURL); err != nil {
+			return "", err
+		}
+		o.logTask(taskID, LogLevelInfo, "Pull request opened: "+event.PullRequest.HTMLURL)
+	}
+
+	switch state {
+	case PRStateMerged:
+		o.logTask(taskID, LogLevelSuccess, "Pull request merged")
+		if task.Status != "done" && CanTransition(task.Status, "done") {
+			if _, err := o.repo.UpdateStatus(ctx, taskID, "done"); err != nil {
+				return "", err
+			}
+		}
+	case PRStateClosed:
+		o.logTask(taskID, LogLevelWarn, "Pull request closed without merging")
+	}
+	slog.Info("[ORCHESTRATOR] Synced pull request state", "task_id", taskID, "state", state)
+	o.eventBus.Publish(models.Event{TaskID: taskID, Type: string(EventTypeTaskUpdated), Data: ""})
+	return taskID, nil
+}
+
+// CheckSuiteEvent is the part of a GitHub check_suite webhook payload the
+// auto-merge uses.
+type CheckSuiteEvent struct {
+	Action     string `json:"action"`
+	CheckSuite struct {
+		HeadBranch string `json:"head_branch"`
+		HeadSHA    string `json:"head_sha"`
+		Conclusion string `json:"conclusion"`
+	} `json:"check_suite"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+// mergeableStateAttempts and mergeableStateDelay bound how long
+// AutoMergePullRequest waits for GitHub to work out whether a PR can merge.
+const mergeableStateAttempts = 3
+
+var mergeableStateDelay = time.Second
+
+// AutoMergePullRequest merges the open pull request of the task whose branch
+// a check suite just passed on, when the task's project opted into
+// auto-merge, and returns the task's ID. Other check suites, and PRs GitHub
+// doesn't report as clean (a required check pending or failing, a review
+// missing, conflicts), are left alone and "" is returned; the next passing
+// suite tries again. The merge is pinned to the commit the checks ran on.
+func (o *Orchestrator) AutoMergePullRequest(ctx context.Context, event CheckSuiteEvent) (string, error) {
+	if event.Action != "completed" || event.CheckSuite.Conclusion != "success" || o.github == nil {
+		return "", nil
+	}
+	taskID, ok := strings.CutPrefix(event.CheckSuite.HeadBranch, TaskBranchName(""))
+	if !ok || taskID == "" {
+		return "", nil
+	}
+	task, err := o.repo.Get(ctx, taskID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if task.RepositoryName != nil && !strings.EqualFold(*task.RepositoryName, event.Repository.FullName) {
+		return "", nil
+	}
+	if task.PRURL == nil || (task.PRState != nil && *task.PRState != PRStateOpen) {
+		return "", nil
+	}
+	enabled, err := o.repo.AutoMergeEnabled(ctx, projectIDOf(task))
+	if err != nil || !enabled {
+		return "", err
+	}
+	number, ok := pullRequestNumber(*task.PRURL)
+	if !ok {
+		return "", nil
+	}
+
+	owner, repoName, err := o.taskGitHubRepo(ctx, task)
+	if err != nil {
+		return "", err
+	}
+	var pr *GitHubPullRequest
+	for attempt := 1; ; attempt++ {
+		pr, err = o.github.GetPullRequest(ctx, owner, repoName, number)
+		if err != nil {
+			return "", err
+		}
+		// GitHub computes mergeability in the background; asking starts it
+		if pr.MergeableState != "unknown" || attempt == mergeableStateAttempts {
+			break
+		}
+		select {
+		case <-time.After(mergeableStateDelay):
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+	// Checks on an older commit say nothing about the current head
+	if pr.State != "open" || pr.Merged || pr.Head.SHA != event.CheckSuite.HeadSHA {
+		return "", nil
+	}
+	if pr.MergeableState != "clean" {
+		slog.Info("[ORCHESTRATOR] Not auto-merging pull request yet", "task_id", taskID, "mergeable_state", pr.MergeableState)
+		return "", nil
+	}
+
+	if err := o.github.MergePullRequest(ctx, owner, repoName, number, pr.Head.SHA); err != nil {
+		o.logTask(taskID, LogLevelWarn, "Auto-merge failed: "+err.Error())
+		return "", err
+	}
+	if err := o.repo.UpdateTaskPRState(ctx, taskID, PRStateMerged); err != nil {
+		return "", err
+	}
+	o.logTask(taskID, LogLevelSuccess, "Pull request merged automatically after checks passed")
+	if task.Status != "done" && CanTransition(task.Status, "done") {
+		if _, err := o.repo.UpdateStatus(ctx, taskID, "done"); err != nil {
+			return "", err
+		}
+	}
+	slog.Info("[ORCHESTRATOR] Auto-merged pull request", "task_id", taskID, "pr_url", *task.PRURL)
+	o.eventBus.Publish(models.Event{TaskID: taskID, Type: string(EventTypeTaskUpdated), Data: ""})
+	return taskID, nil
+}
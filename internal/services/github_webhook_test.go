@@ -0,0 +1,222 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/revrost/counterspell/internal/db/sqlc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyGitHubWebhook(t *testing.T) {
+	body := []byte(`{"action":"closed"}`)
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(body)
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	assert.NoError(t, VerifyGitHubWebhook("s3cret", body, signature))
+	assert.ErrorIs(t, VerifyGitHubWebhook("other", body, signature), ErrWebhookSignature)
+	assert.ErrorIs(t, VerifyGitHubWebhook("s3cret", []byte(`{"action":"opened"}`), signature), ErrWebhookSignature)
+	assert.ErrorIs(t, VerifyGitHubWebhook("s3cret", body, ""), ErrWebhookSignature)
+	assert.ErrorIs(t, VerifyGitHubWebhook("", body, signature), ErrWebhookSignature)
+}
+
+func TestSyncPullRequest(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	repo := NewRepository(testDB)
+	orch, err := NewOrchestrator(repo, NewEventBus(), nil, nil, stubRepoManager{})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	conn, err := testDB.Queries.CreateGithubConnection(ctx, sqlc.CreateGithubConnectionParams{
+		ID:           "conn-1",
+		GithubUserID: "user-1",
+		AccessToken:  "token",
+		Username:     "testuser",
+	})
+	require.NoError(t, err)
+	_, err = testDB.Queries.CreateRepository(ctx, sqlc.CreateRepositoryParams{
+		ID:           "repo-1",
+		ConnectionID: conn.ID,
+		Name:         "repo-1",
+		FullName:     "test/repo-1",
+		Owner:        "test",
+	})
+	require.NoError(t, err)
+
+	newEvent := func(action, branch string, merged bool) PullRequestEvent {
+		var event PullRequestEvent
+		event.Action = action
+		event.PullRequest.HTMLURL = "https://github.com/test/repo-1/pull/3"
+		event.PullRequest.Merged = merged
+		event.PullRequest.Head.Ref = branch
+		event.Repository.FullName = "test/repo-1"
+		return event
+	}
+
+	// A PR opened by hand from a task in review is recorded on the task
+	task, err := repo.Create(ctx, "repo-1", "sync me")
+	require.NoError(t, err)
+	for _, status := range []string{"in_progress", "review"} {
+		_, err = repo.UpdateStatus(ctx, task.ID, status)
+		require.NoError(t, err)
+	}
+	taskID, err := orch.SyncPullRequest(ctx, newEvent("opened", TaskBranchName(task.ID), false))
+	require.NoError(t, err)
+	assert.Equal(t, task.ID, taskID)
+	task, err = repo.Get(ctx, task.ID)
+	require.NoError(t, err)
+	require.NotNil(t, task.PRURL)
+	require.NotNil(t, task.PRState)
+	assert.Equal(t, PRStateOpen, *task.PRState)
+	assert.Equal(t, "review", task.Status)
+
+	// Merging it finishes the task
+	_, err = orch.SyncPullRequest(ctx, newEvent("closed", TaskBranchName(task.ID), true))
+	require.NoError(t, err)
+	task, err = repo.Get(ctx, task.ID)
+	require.NoError(t, err)
+	assert.Equal(t, PRStateMerged, *task.PRState)
+	assert.Equal(t, "done", task.Status)
+
+	// Closing without merging only records the state
+	other, err := repo.Create(ctx, "repo-1", "close me")
+	require.NoError(t, err)
+	for _, status := range []string{"in_progress", "review"} {
+		_, err = repo.UpdateStatus(ctx, other.ID, status)
+		require.NoError(t, err)
+	}
+	_, err = orch.SyncPullRequest(ctx, newEvent("closed", TaskBranchName(other.ID), false))
+	require.NoError(t, err)
+	other, err = repo.Get(ctx, other.ID)
+	require.NoError(t, err)
+	assert.Equal(t, PRStateClosed, *other.PRState)
+	assert.Equal(t, "review", other.Status)
+
+	// Other branches, repositories and actions are ignored
+	for _, event := range []PullRequestEvent{
+		newEvent("closed", "feature/login", true),
+		newEvent("closed", TaskBranchName("missing"), true),
+		newEvent("edited", TaskBranchName(other.ID), false),
+	} {
+		taskID, err := orch.SyncPullRequest(ctx, event)
+		require.NoError(t, err)
+		assert.Empty(t, taskID)
+	}
+	foreign := newEvent("closed", TaskBranchName(other.ID), true)
+	foreign.Repository.FullName = "someone/else"
+	taskID, err = orch.SyncPullRequest(ctx, foreign)
+	require.NoError(t, err)
+	assert.Empty(t, taskID)
+}
+
+func TestAutoMergePullRequest(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	mergeableState := "blocked"
+	var mergedSHAs []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/repos/test/repo-1/pulls/3":
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"number": 3, "state": "open", "mergeable_state": mergeableState,
+				"head": map[string]string{"ref": "agent/task-x", "sha": "abc"},
+			})
+		case r.Method == "PUT" && r.URL.Path == "/repos/test/repo-1/pulls/3/merge":
+			var req struct {
+				SHA string `json:"sha"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			mergedSHAs = append(mergedSHAs, req.SHA)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	repo := NewRepository(testDB)
+	github := NewGitHubService(testDB, "", "", WithGitHubHost("", srv.URL))
+	orch, err := NewOrchestrator(repo, NewEventBus(), nil, github, stubRepoManager{})
+	require.NoError(t, err)
+
+	conn, err := testDB.Queries.CreateGithubConnection(ctx, sqlc.CreateGithubConnectionParams{
+		ID:           "conn-1",
+		GithubUserID: "user-1",
+		AccessToken:  "token",
+		Username:     "testuser",
+	})
+	require.NoError(t, err)
+	_, err = testDB.Queries.CreateRepository(ctx, sqlc.CreateRepositoryParams{
+		ID:           "repo-1",
+		ConnectionID: conn.ID,
+		Name:         "repo-1",
+		FullName:     "test/repo-1",
+		Owner:        "test",
+	})
+	require.NoError(t, err)
+
+	task, err := repo.Create(ctx, "repo-1", "merge me")
+	require.NoError(t, err)
+	for _, status := range []string{"in_progress", "review"} {
+		_, err = repo.UpdateStatus(ctx, task.ID, status)
+		require.NoError(t, err)
+	}
+	require.NoError(t, repo.UpdateTaskPRURL(ctx, task.ID, "https://github.com/test/repo-1/pull/3"))
+	require.NoError(t, repo.UpdateTaskPRState(ctx, task.ID, PRStateOpen))
+
+	newEvent := func(conclusion, sha string) CheckSuiteEvent {
+		var event CheckSuiteEvent
+		event.Action = "completed"
+		event.CheckSuite.HeadBranch = TaskBranchName(task.ID)
+		event.CheckSuite.HeadSHA = sha
+		event.CheckSuite.Conclusion = conclusion
+		event.Repository.FullName = "test/repo-1"
+		return event
+	}
+	autoMerge := func(event CheckSuiteEvent) string {
+		t.Helper()
+		taskID, err := orch.AutoMergePullRequest(ctx, event)
+		require.NoError(t, err)
+		return taskID
+	}
+
+	// Projects have to opt in
+	assert.Empty(t, autoMerge(newEvent("success", "abc")))
+	require.NoError(t, repo.SetAutoMerge(ctx, "repo-1", true))
+
+	// Failed suites, checks on an older commit and PRs GitHub won't call
+	// clean are left alone
+	assert.Empty(t, autoMerge(newEvent("failure", "abc")))
+	assert.Empty(t, autoMerge(newEvent("success", "old")))
+	assert.Empty(t, autoMerge(newEvent("success", "abc")))
+	assert.Empty(t, mergedSHAs)
+
+	mu.Lock()
+	mergeableState = "clean"
+	mu.Unlock()
+	assert.Equal(t, task.ID, autoMerge(newEvent("success", "abc")))
+	assert.Equal(t, []string{"abc"}, mergedSHAs)
+	task, err = repo.Get(ctx, task.ID)
+	require.NoError(t, err)
+	assert.Equal(t, PRStateMerged, *task.PRState)
+	assert.Equal(t, "done", task.Status)
+
+	// A merged PR isn't merged again
+	assert.Empty(t, autoMerge(newEvent("success", "abc")))
+	assert.Len(t, mergedSHAs, 1)
+}
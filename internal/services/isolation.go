@@ -0,0 +1,46 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+)
+
+// IsolationMode controls how strongly a task's agent is separated from the
+// base repository and the host.
+//
+//   - worktree: a git worktree under DATA_DIR/worktrees. Cheapest option (no
+//     object copy, creation is near-instant) but the worktree shares the base
+//     clone's object store and refs, so a misbehaving agent can damage them.
+//   - clone: an independent `git clone --no-hardlinks` per task. Nothing is
+//     shared with the base repo; costs a full copy of the object store on disk
+//     per task and a slower first run on large repositories.
+//   - container: same workspace as clone, plus CLI backends (claude, codex)
+//     run inside a container that bind-mounts only the workspace. Strongest
+//     isolation; requires docker and an image that ships the CLI, adds
+//     container start-up latency per run, and CLI session state does not
+//     survive between runs. The native backend still runs in-process.
+type IsolationMode string
+
+const (
+	IsolationWorktree  IsolationMode = "worktree"
+	IsolationClone     IsolationMode = "clone"
+	IsolationContainer IsolationMode = "container"
+)
+
+// ParseIsolationMode validates an isolation mode string. Empty means worktree.
+func ParseIsolationMode(s string) (IsolationMode, error) {
+	switch mode := IsolationMode(strings.ToLower(strings.TrimSpace(s))); mode {
+	case "":
+		return IsolationWorktree, nil
+	case IsolationWorktree, IsolationClone, IsolationContainer:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown isolation mode %q (want worktree, clone or container)", s)
+	}
+}
+
+// independentWorkspace reports whether the mode needs a self-contained clone
+// rather than a worktree.
+func (m IsolationMode) independentWorkspace() bool {
+	return m == IsolationClone || m == IsolationContainer
+}
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultIssueLabel marks the GitHub issues the poller turns into tasks.
+const DefaultIssueLabel = "agent"
+
+// issueTracker is the part of GitHubService the issue poller uses.
+type issueTracker interface {
+	ListLabeledIssues(ctx context.Context, owner, repo, label string) ([]GitHubIssue, error)
+	CommentOnIssue(ctx context.Context, owner, repo string, number int64, body string) error
+}
+
+// taskStarter is the part of Orchestrator the issue poller uses.
+type taskStarter interface {
+	StartTask(ctx context.Context, projectID, intent, modelID string) (string, error)
+}
+
+// IssuePollerOptions configures an IssuePoller.
+type IssuePollerOptions struct {
+	Interval time.Duration
+	// Label selects the issues to pick up (default DefaultIssueLabel)
+	Label string
+	// MaxActiveTasks defers new issues while this many tasks are pending or
+	// running. 0 means no limit.
+	MaxActiveTasks int
+}
+
+// IssuePoller turns labeled GitHub issues of watched projects into tasks,
+// one task per issue, and comments the PR link back on the issue once the
+// task has one.
+type IssuePoller struct {
+	repo   *Repository
+	issues issueTracker
+	tasks  taskStarter
+	opts   IssuePollerOptions
+
+	stopCh chan struct{}
+	pollMu sync.Mutex
+}
+
+// NewIssuePoller creates an issue poller. github and orch are usually the
+// shared GitHubService and Orchestrator.
+func NewIssuePoller(repo *Repository, github issueTracker, orch taskStarter, opts IssuePollerOptions) *IssuePoller {
+	if opts.Label == "" {
+		opts.Label = DefaultIssueLabel
+	}
+	return &IssuePoller{
+		repo:   repo,
+		issues: github,
+		tasks:  orch,
+		opts:   opts,
+		stopCh: make(chan struct{}),
+	}
+}
+
+// Start polls every Interval until ctx is cancelled or Shutdown is called.
+func (p *IssuePoller) Start(ctx context.Context) {
+	slog.Info("[ISSUE-POLLER] starting", "interval", p.opts.Interval.String(), "label", p.opts.Label)
+
+	go func() {
+		ticker := time.NewTicker(p.opts.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-p.stopCh:
+				return
+			case <-ticker.C:
+				p.Poll(ctx)
+			}
+		}
+	}()
+}
+
+// Shutdown stops the polling loop.
+func (p *IssuePoller) Shutdown() {
+	close(p.stopCh)
+}
+
+// Poll runs one pass: new issues become tasks, and tasks with a PR get the
+// link posted on their issue.
+func (p *IssuePoller) Poll(ctx context.Context) {
+	p.pollMu.Lock()
+	defer p.pollMu.Unlock()
+
+	p.createTasks(ctx)
+	p.postPRLinks(ctx)
+}
+
+func (p *IssuePoller) createTasks(ctx context.Context) {
+	repos, err := p.repo.ListWatchedRepositories(ctx)
+	if err != nil {
+		slog.Error("[ISSUE-POLLER] Failed to list watched projects", "error", err)
+		return
+	}
+
+	for _, r := range repos {
+		issues, err := p.issues.ListLabeledIssues(ctx, r.Owner, r.Name, p.opts.Label)
+		if err != nil {
+			slog.Warn("[ISSUE-POLLER] Failed to list issues", "repo", r.Owner+"/"+r.Name, "error", err)
+			continue
+		}
+		for _, issue := range issues {
+			seen, err := p.repo.HasIssueTask(ctx, r.ID, issue.Number)
+			if err != nil || seen {
+				continue
+			}
+			if !p.hasCapacity(ctx) {
+				slog.Info("[ISSUE-POLLER] Task limit reached, deferring issues", "max_active_tasks", p.opts.MaxActiveTasks)
+				return
+			}
+
+			// Claimed first, so a task that started is never created again
+			claimed, err := p.repo.ClaimIssueTask(ctx, r.ID, issue.Number)
+			if err != nil || !claimed {
+				if err != nil {
+					slog.Error("[ISSUE-POLLER] Failed to claim issue", "repo", r.Owner+"/"+r.Name, "issue", issue.Number, "error", err)
+				}
+				continue
+			}
+			taskID, err := p.tasks.StartTask(ctx, r.ID, issueIntent(issue), "")
+			if err != nil {
+				slog.Error("[ISSUE-POLLER] Failed to start task", "repo", r.Owner+"/"+r.Name, "issue", issue.Number, "error", err)
+				if err := p.repo.ReleaseIssueTask(ctx, r.ID, issue.Number); err != nil {
+					slog.Error("[ISSUE-POLLER] Failed to release issue", "issue", issue.Number, "error", err)
+				}
+				continue
+			}
+			if err := p.repo.RecordIssueTask(ctx, r.ID, issue.Number, taskID); err != nil {
+				// The issue stays claimed; only its PR link comment is lost
+				slog.Error("[ISSUE-POLLER] Failed to record issue task", "task_id", taskID, "issue", issue.Number, "error", err)
+				continue
+			}
+			slog.Info("[ISSUE-POLLER] Created task from issue", "repo", r.Owner+"/"+r.Name, "issue", issue.Number, "task_id", taskID)
+		}
+	}
+}
+
+func (p *IssuePoller) hasCapacity(ctx context.Context) bool {
+	if p.opts.MaxActiveTasks <= 0 {
+		return true
+	}
+	active, err := p.repo.CountActiveTasks(ctx)
+	if err != nil {
+		slog.Warn("[ISSUE-POLLER] Failed to count active tasks", "error", err)
+		return false
+	}
+	return active < int64(p.opts.MaxActiveTasks)
+}
+
+func (p *IssuePoller) postPRLinks(ctx context.Context) {
+	pending, err := p.repo.ListIssueTasksAwaitingComment(ctx)
+	if err != nil {
+		slog.Error("[ISSUE-POLLER] Failed to list issue tasks", "error", err)
+		return
+	}
+
+	for _, it := range pending {
+		body := "Opened a pull request for this issue: " + it.PrUrl.String
+		if err := p.issues.CommentOnIssue(ctx, it.Owner, it.Name, it.IssueNumber, body); err != nil {
+			slog.Warn("[ISSUE-POLLER] Failed to comment on issue", "repo", it.Owner+"/"+it.Name, "issue", it.IssueNumber, "error", err)
+			continue
+		}
+		if err := p.repo.MarkIssueCommented(ctx, it.RepositoryID, it.IssueNumber); err != nil {
+			slog.Error("[ISSUE-POLLER] Failed to mark issue commented", "issue", it.IssueNumber, "error", err)
+		}
+	}
+}
+
+// issueIntent builds a task intent from an issue's title and body.
+func issueIntent(issue GitHubIssue) string {
+	intent := issue.Title
+	if body := strings.TrimSpace(issue.Body); body != "" {
+		intent += "\n\n" + body
+	}
+	return intent + fmt.Sprintf("\n\n(GitHub issue #%d)", issue.Number)
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/revrost/counterspell/internal/db/sqlc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeIssueTracker struct {
+	issues   []GitHubIssue
+	comments map[int64]string
+}
+
+func (f *fakeIssueTracker) ListLabeledIssues(ctx context.Context, owner, repo, label string) ([]GitHubIssue, error) {
+	return f.issues, nil
+}
+
+func (f *fakeIssueTracker) CommentOnIssue(ctx context.Context, owner, repo string, number int64, body string) error {
+	f.comments[number] = body
+	return nil
+}
+
+type fakeTaskStarter struct {
+	repo    *Repository
+	intents []string
+	taskIDs []string
+	err     error
+	// claimed records, per start, whether its issue was already claimed
+	claimed []bool
+}
+
+func (f *fakeTaskStarter) StartTask(ctx context.Context, projectID, intent, modelID string) (string, error) {
+	if number, ok := issueNumberOf(intent); ok {
+		claimed, _ := f.repo.HasIssueTask(ctx, projectID, number)
+		f.claimed = append(f.claimed, claimed)
+	}
+	f.intents = append(f.intents, intent)
+	if f.err != nil {
+		return "", f.err
+	}
+	task, err := f.repo.Create(ctx, projectID, intent)
+	if err != nil {
+		return "", err
+	}
+	f.taskIDs = append(f.taskIDs, task.ID)
+	return task.ID, nil
+}
+
+func TestIssuePoller_Poll(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	ctx := context.Background()
+	repo := NewRepository(testDB)
+
+	conn, err := testDB.Queries.CreateGithubConnection(ctx, sqlc.CreateGithubConnectionParams{
+		ID:           "conn-1",
+		GithubUserID: "user-1",
+		AccessToken:  "token",
+		Username:     "testuser",
+	})
+	require.NoError(t, err)
+	for _, id := range []string{"repo-1", "repo-2"} {
+		_, err = testDB.Queries.CreateRepository(ctx, sqlc.CreateRepositoryParams{
+			ID:           id,
+			ConnectionID: conn.ID,
+			Name:         id,
+			FullName:     "test/" + id,
+			Owner:        "test",
+		})
+		require.NoError(t, err)
+	}
+	require.NoError(t, repo.SetIssueWatch(ctx, "repo-1", true))
+
+	tracker := &fakeIssueTracker{
+		issues: []GitHubIssue{
+			{Number: 1, Title: "Fix login", Body: "It crashes"},
+			{Number: 2, Title: "Add dark mode"},
+			{Number: 3, Title: "Update docs"},
+		},
+		comments: map[int64]string{},
+	}
+	starter := &fakeTaskStarter{repo: repo}
+	poller := NewIssuePoller(repo, tracker, starter, IssuePollerOptions{MaxActiveTasks: 2})
+
+	// Only the watched project is polled, and the task cap defers issue 3
+	poller.Poll(ctx)
+	require.Len(t, starter.intents, 2)
+	assert.Equal(t, "Fix login\n\nIt crashes\n\n(GitHub issue #1)", starter.intents[0])
+	assert.Equal(t, "Add dark mode\n\n(GitHub issue #2)", starter.intents[1])
+
+	// Issues already turned into tasks are not picked up again
+	poller.opts.MaxActiveTasks = 0
+	poller.Poll(ctx)
+	require.Len(t, starter.intents, 3)
+	assert.Contains(t, starter.intents[2], "Update docs")
+
+	// The PR link is posted once the task has one, and only once
+	assert.Empty(t, tracker.comments)
+	require.NoError(t, repo.UpdateTaskPRURL(ctx, starter.taskIDs[0], "https://github.com/test/repo-1/pull/7"))
+
+	poller.Poll(ctx)
+	assert.Equal(t, map[int64]string{1: "Opened a pull request for this issue: https://github.com/test/repo-1/pull/7"}, tracker.comments)
+
+	delete(tracker.comments, 1)
+	poller.Poll(ctx)
+	assert.Empty(t, tracker.comments)
+	assert.Len(t, starter.intents, 3)
+
+	// Each issue was claimed before its task started
+	assert.Equal(t, []bool{true, true, true}, starter.claimed)
+
+	// An issue whose task fails to start is tried again on the next poll
+	tracker.issues = append(tracker.issues, GitHubIssue{Number: 4, Title: "Flaky test"})
+	starter.err = errors.New("queue full")
+	poller.Poll(ctx)
+	require.Len(t, starter.intents, 4)
+	starter.err = nil
+	poller.Poll(ctx)
+	require.Len(t, starter.intents, 5)
+	assert.Contains(t, starter.intents[4], "Flaky test")
+	poller.Poll(ctx)
+	assert.Len(t, starter.intents, 5)
+}
+
+// issueNumberOf parses the issue number issueIntent appends to an intent.
+func issueNumberOf(intent string) (int64, bool) {
+	_, suffix, ok := strings.Cut(intent, "(GitHub issue #")
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(strings.TrimSuffix(suffix, ")"), 10, 64)
+	return n, err == nil
+}
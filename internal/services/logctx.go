@@ -0,0 +1,32 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+)
+
+type loggerKey struct{}
+
+// WithLogger returns a copy of ctx carrying logger. executeTask uses it to
+// tag every line logged on behalf of a task with task_id, run_id and repo.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// LoggerFrom returns the logger carried by ctx, or slog.Default().
+func LoggerFrom(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// taskLogger returns the task-scoped logger carried by ctx, or the default
+// logger tagged with taskID for calls made outside a task run (merges, PRs,
+// cleanup).
+func taskLogger(ctx context.Context, taskID string) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default().With("task_id", taskID)
+}
@@ -0,0 +1,28 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskLogger(t *testing.T) {
+	var buf bytes.Buffer
+	scoped := slog.New(slog.NewTextHandler(&buf, nil)).With("task_id", "t1", "run_id", "r1")
+
+	// A logger on the context wins and keeps its run_id
+	taskLogger(WithLogger(context.Background(), scoped), "t1").Info("[GIT] Committed successfully")
+	assert.Contains(t, buf.String(), "task_id=t1 run_id=r1")
+
+	// Without one, the default logger is tagged with the task
+	prev := slog.Default()
+	defer slog.SetDefault(prev)
+	buf.Reset()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	taskLogger(context.Background(), "t2").Info("[GIT] Aborted merge")
+	assert.Contains(t, buf.String(), "task_id=t2")
+	assert.Same(t, slog.Default(), LoggerFrom(context.Background()))
+}
@@ -1,19 +1,26 @@
 package services
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/lithammer/shortuuid/v4"
 	"github.com/panjf2000/ants/v2"
 	"github.com/revrost/counterspell/internal/agent"
+	"github.com/revrost/counterspell/internal/agent/tools"
+	"github.com/revrost/counterspell/internal/db/sqlc"
 	"github.com/revrost/counterspell/internal/llm"
 	"github.com/revrost/counterspell/internal/models"
 )
@@ -29,9 +36,11 @@ type ConflictFile struct {
 // TaskResult represents result of a completed task.
 type TaskResult struct {
 	TaskID      string
+	Version     int64 // task version after the run set in_progress; 0 if it never did
 	Success     bool
 	AgentOutput string
 	GitDiff     string
+	NoChanges   bool // the run succeeded but the task's diff is empty
 	Error       string
 }
 
@@ -40,14 +49,67 @@ type TaskJob struct {
 	TaskID         string
 	ProjectID      string
 	Intent         string
-	ModelID        string // Format: "provider:model" e.g., "anthropic:claude-opus-4-5"
+	ModelID        string // Format: "provider#model" e.g., "anthropic#claude-opus-4-5"
 	Owner          string
 	Repo           string
 	Token          string
 	MessageHistory string // Only for continuations
+	Rerun          bool   // Fresh attempt: don't resume the previous run's backend session
+	SystemPrompt   string // Replaces the assembled system prompt when set (reruns only)
 	ResultCh       chan<- TaskResult
 }
 
+// jobKind is how a submitted job relates to the task's previous runs.
+type jobKind int
+
+const (
+	jobNew      jobKind = iota // first run of a new task
+	jobContinue                // follow-up appended to the conversation
+	jobRerun                   // fresh attempt on a clean workspace, prior runs kept
+)
+
+// ErrTaskRunning is returned when a run is requested for a task that already
+// has one queued or executing; two agents would edit the same workspace.
+type ErrTaskRunning struct {
+	TaskID string
+}
+
+func (e ErrTaskRunning) Error() string {
+	return fmt.Sprintf("task %s is already running", e.TaskID)
+}
+
+// ErrTaskNotRunning is returned when a task has to be running for an
+// action, such as interrupting it with a message, and isn't.
+type ErrTaskNotRunning struct {
+	TaskID string
+}
+
+func (e ErrTaskNotRunning) Error() string {
+	return fmt.Sprintf("task %s is not running", e.TaskID)
+}
+
+// ErrTaskNotMovable is returned when a task can't be moved to another
+// project because its workspace already belongs to the current one.
+type ErrTaskNotMovable struct {
+	TaskID string
+	Reason string
+}
+
+func (e ErrTaskNotMovable) Error() string {
+	return fmt.Sprintf("task %s cannot be moved: %s", e.TaskID, e.Reason)
+}
+
+// ErrInvalidBaseBranch is returned when a task is started from a base
+// branch that is malformed or that the project's remote doesn't have.
+type ErrInvalidBaseBranch struct {
+	Branch string
+	Reason string
+}
+
+func (e ErrInvalidBaseBranch) Error() string {
+	return fmt.Sprintf("invalid base branch %q: %s", e.Branch, e.Reason)
+}
+
 // Orchestrator manages task execution with agents.
 type Orchestrator struct {
 	repo        *Repository
@@ -58,7 +120,168 @@ type Orchestrator struct {
 	workerPool  *ants.Pool
 	resultCh    chan TaskResult
 	running     map[string]context.CancelFunc
+	runDone     map[string]chan struct{} // closed once a running task's executeTask returns
+	queued      map[string]struct{}      // submitted to the pool, not yet executing
+	live        map[string]liveRun       // backend and model of each executing task
 	mu          sync.Mutex
+
+	// fileLists caches the base repo's file list for SearchProjectFiles
+	fileLists *fileListCache
+
+	// container, when set, runs CLI backends inside a container (isolation mode "container")
+	container *agent.ContainerConfig
+
+	// llmTimeouts overrides agent.DefaultLLMTimeouts for the native backend
+	llmTimeouts *agent.LLMTimeouts
+
+	// rawLogDir, when set, receives the unparsed output of CLI backends as
+	// <rawLogDir>/<task id>/<run id>.log, each capped at rawLogMaxBytes
+	rawLogDir      string
+	rawLogMaxBytes int64
+
+	// promptPrefix is the admin's preamble put ahead of every run's system
+	// prompt, including user overrides
+	promptPrefix string
+
+	// protectedPaths holds patterns the native backend's file tools refuse
+	// to modify
+	protectedPaths []string
+
+	// toolTimeout, when > 0, kills a single agent shell command running
+	// longer, so it fails as a tool error instead of stalling the run
+	toolTimeout time.Duration
+
+	// outputLimits caps persisted messages and diffs; the full versions of
+	// truncated ones are kept under outputDir
+	outputLimits OutputLimits
+	outputDir    string
+	// diffInlineMaxLines, when > 0, stops live file diffs once the task's
+	// diff changes more lines, as the diff view won't render it
+	diffInlineMaxLines int
+	// liveDiffSizes holds, per running task, a *liveDiffSize
+	liveDiffSizes sync.Map
+
+	// statusDenied holds "owner/repo" keys whose commit statuses were
+	// rejected for lack of token access
+	statusDenied sync.Map
+
+	// redactors holds, per running task, a replacer hiding its project env
+	// values in streamed and persisted agent output
+	redactors sync.Map
+
+	// workspaceRetention, when > 0, enables a background sweep removing
+	// workspaces of finished tasks idle for longer than this
+	workspaceRetention time.Duration
+	stopCh             chan struct{}
+
+	// newBackend, when set, replaces backend selection for every run
+	newBackend BackendFactory
+
+	// resultProcessors is how many goroutines record results from resultCh;
+	// see startResultProcessors
+	resultProcessors int
+	resultQueues     []chan queuedResult
+	resultsDone      chan struct{} // closed once every processor has exited
+	resultStats      resultStats
+}
+
+// BackendFactory creates the agent backend for a run in workDir.
+type BackendFactory func(workDir, systemPrompt string) (agent.Backend, error)
+
+// OrchestratorOption configures an Orchestrator.
+type OrchestratorOption func(*Orchestrator)
+
+// WithContainerIsolation runs CLI backends inside containers built from image,
+// bind-mounting only the task workspace.
+func WithContainerIsolation(image string) OrchestratorOption {
+	return func(o *Orchestrator) {
+		o.container = &agent.ContainerConfig{Image: image}
+	}
+}
+
+// WithLLMTimeouts sets the provider request and stream-idle timeouts used by
+// the native backend.
+func WithLLMTimeouts(timeouts agent.LLMTimeouts) OrchestratorOption {
+	return func(o *Orchestrator) {
+		o.llmTimeouts = &timeouts
+	}
+}
+
+// WithWorkspaceRetention periodically removes workspaces of review/done/failed
+// tasks whose last run finished more than retention ago. 0 disables the sweep.
+func WithWorkspaceRetention(retention time.Duration) OrchestratorOption {
+	return func(o *Orchestrator) {
+		o.workspaceRetention = retention
+	}
+}
+
+// WithRawLogDir keeps the raw stdout/stderr of CLI backend runs under dir,
+// for debugging output the stream parsers drop. Each run's log stops growing
+// at maxBytes; 0 means no limit.
+func WithRawLogDir(dir string, maxBytes int64) OrchestratorOption {
+	return func(o *Orchestrator) {
+		o.rawLogDir = dir
+		o.rawLogMaxBytes = maxBytes
+	}
+}
+
+// WithPromptPrefix prepends prefix to the system prompt of every agent run,
+// ahead of any prompt a user sets, so org policy can't be overridden.
+func WithPromptPrefix(prefix string) OrchestratorOption {
+	return func(o *Orchestrator) {
+		o.promptPrefix = strings.TrimSpace(prefix)
+	}
+}
+
+// WithProtectedPaths makes the native backend's write and edit tools refuse
+// paths matching patterns, whatever the model asks for.
+func WithProtectedPaths(patterns []string) OrchestratorOption {
+	return func(o *Orchestrator) {
+		o.protectedPaths = patterns
+	}
+}
+
+// WithToolTimeout caps each shell command an agent runs: the native
+// backend's bash tool, and the Claude Code CLI's Bash tool.
+func WithToolTimeout(timeout time.Duration) OrchestratorOption {
+	return func(o *Orchestrator) {
+		o.toolTimeout = timeout
+	}
+}
+
+// WithOutputLimits truncates agent messages and diffs longer than limits
+// before they are persisted, keeping the full versions as files under dir.
+func WithOutputLimits(limits OutputLimits, dir string) OrchestratorOption {
+	return func(o *Orchestrator) {
+		o.outputLimits = limits
+		o.outputDir = dir
+	}
+}
+
+// WithDiffInlineMaxLines stops streaming parsed file diffs while a task's
+// diff changes more than maxLines lines; 0 always streams them.
+func WithDiffInlineMaxLines(maxLines int) OrchestratorOption {
+	return func(o *Orchestrator) {
+		o.diffInlineMaxLines = maxLines
+	}
+}
+
+// WithResultProcessors records finished runs with n goroutines instead of
+// one, so a slow result doesn't hold up the others. Results of the same task
+// still apply in order.
+func WithResultProcessors(n int) OrchestratorOption {
+	return func(o *Orchestrator) {
+		o.resultProcessors = max(n, 1)
+	}
+}
+
+// WithBackendFactory makes every run use the backend returned by factory
+// instead of the one configured in settings. Tests use it to drive the full
+// run pipeline with an agent.MockBackend.
+func WithBackendFactory(factory BackendFactory) OrchestratorOption {
+	return func(o *Orchestrator) {
+		o.newBackend = factory
+	}
 }
 
 // NewOrchestrator creates a new orchestrator.
@@ -68,6 +291,7 @@ func NewOrchestrator(
 	settings *SettingsService,
 	github *GitHubService,
 	repoManager RepoManager,
+	opts ...OrchestratorOption,
 ) (*Orchestrator, error) {
 	userID := "default" // Hardcoded for local-first single-tenant mode
 	if repoManager == nil {
@@ -92,47 +316,114 @@ func NewOrchestrator(
 		workerPool: pool,
 		resultCh:   make(chan TaskResult, 100),
 		running:    make(map[string]context.CancelFunc),
+		runDone:    make(map[string]chan struct{}),
+		queued:     make(map[string]struct{}),
+		live:       make(map[string]liveRun),
+		stopCh:     make(chan struct{}),
+
+		fileLists: newFileListCache(),
+
+		resultProcessors: 1,
+	}
+
+	for _, opt := range opts {
+		opt(orch)
 	}
 
 	slog.Info("[ORCHESTRATOR] Worker pool created", "workers", 5, "prealloc", false)
 
-	// Start result processor goroutine
-	go orch.processResults()
+	orch.startResultProcessors()
+
+	if orch.workspaceRetention > 0 {
+		go orch.workspaceSweepLoop()
+	}
 
 	slog.Info("[ORCHESTRATOR] Orchestrator initialized")
 	return orch, nil
 }
 
+// shutdownGracePeriod is how long Shutdown waits for cancelled runs to
+// close their backends, killing CLI subprocesses.
+const shutdownGracePeriod = 10 * time.Second
+
 // Shutdown gracefully shuts down orchestrator.
 func (o *Orchestrator) Shutdown() {
 	slog.Info("[ORCHESTRATOR] Shutting down")
 
+	close(o.stopCh)
+
 	// Cancel all running tasks
 	o.mu.Lock()
+	runDone := o.runDone
 	for taskID, cancel := range o.running {
 		slog.Info("[ORCHESTRATOR] Cancelling running task", "task_id", taskID)
 		cancel()
 	}
 	o.running = make(map[string]context.CancelFunc)
+	o.runDone = make(map[string]chan struct{})
 	o.mu.Unlock()
 
 	// Release worker pool (prevents new tasks from starting)
 	o.workerPool.Release()
 
-	// Close result channel to unblock processResults goroutine
-	close(o.resultCh)
+	// Cancelled runs close their backends on the way out; give them time to
+	// do so, since that is what kills CLI backend subprocesses
+	exited := true
+	deadline := time.After(shutdownGracePeriod)
+	for taskID, done := range runDone {
+		select {
+		case <-done:
+		case <-deadline:
+			slog.Warn("[ORCHESTRATOR] Task did not stop before shutdown", "task_id", taskID)
+			exited = false
+		}
+	}
+
+	// Close the result channel so the processors drain it and exit. A run
+	// still going could send to it, so it is left open in that case.
+	if exited {
+		close(o.resultCh)
+		select {
+		case <-o.resultsDone:
+		case <-time.After(shutdownGracePeriod):
+			slog.Warn("[ORCHESTRATOR] Result processors did not finish before shutdown")
+		}
+	}
 
 	slog.Info("[ORCHESTRATOR] Shutdown complete")
 }
 
 // StartTask creates a task and begins execution.
 func (o *Orchestrator) StartTask(ctx context.Context, projectID, intent, modelID string) (string, error) {
+	return o.StartTaskFromBranch(ctx, projectID, intent, modelID, "")
+}
+
+// StartTaskFromBranch is StartTask with the agent branching off baseBranch
+// instead of the default branch; merges and PRs then target baseBranch too.
+// An empty baseBranch means the default branch.
+func (o *Orchestrator) StartTaskFromBranch(ctx context.Context, projectID, intent, modelID, baseBranch string) (string, error) {
 	// 1. Resolve projectID to a repository and ensure it's cloned
 	var token string
 	var owner, repoName string
 	if projectID == "" {
 		return "", fmt.Errorf("project_id is required")
 	}
+	modelID, err := llm.NormalizeModelID(modelID)
+	if err != nil {
+		return "", err
+	}
+	if baseBranch != "" {
+		if err := validateBranchName(baseBranch); err != nil {
+			return "", err
+		}
+		exists, err := o.repoManager.RemoteBranchExists(ctx, baseBranch)
+		if err != nil {
+			return "", fmt.Errorf("failed to check base branch: %w", err)
+		}
+		if !exists {
+			return "", ErrInvalidBaseBranch{Branch: baseBranch, Reason: "not found on the remote"}
+		}
+	}
 	// Look up repo in DB
 	repo, err := o.repo.GetRepository(ctx, projectID)
 	if err == nil {
@@ -153,10 +444,15 @@ func (o *Orchestrator) StartTask(ctx context.Context, projectID, intent, modelID
 		return "", err
 	}
 	taskID := task.ID
+	if baseBranch != "" {
+		if err := o.repo.UpdateTaskBaseBranch(ctx, taskID, baseBranch); err != nil {
+			return "", fmt.Errorf("failed to record base branch: %w", err)
+		}
+	}
 
-	slog.Info("[ORCHESTRATOR] Task created", "task_id", taskID, "project_id", projectID, "intent", intent)
+	slog.Info("[ORCHESTRATOR] Task created", "task_id", taskID, "project_id", projectID, "intent", intent, "base_branch", baseBranch)
 
-	if err := o.submitTaskJob(ctx, taskID, projectID, intent, modelID, owner, repoName, token, false); err != nil {
+	if err := o.submitTaskJob(ctx, taskID, projectID, intent, modelID, owner, repoName, token, "", jobNew); err != nil {
 		return "", err
 	}
 
@@ -168,12 +464,22 @@ func (o *Orchestrator) ContinueTask(ctx context.Context, taskID, followUpMsg, mo
 	if followUpMsg == "" {
 		return fmt.Errorf("follow-up message cannot be empty")
 	}
+	modelID, err := llm.NormalizeModelID(modelID)
+	if err != nil {
+		return err
+	}
 
 	// Get task info
 	task, err := o.repo.Get(ctx, taskID)
 	if err != nil {
 		return fmt.Errorf("task not found: %w", err)
 	}
+	if !CanTransition(task.Status, "in_progress") {
+		return ErrInvalidTransition{TaskID: taskID, From: task.Status, To: "in_progress"}
+	}
+	if o.isActive(taskID) {
+		return ErrTaskRunning{TaskID: taskID}
+	}
 
 	// Get project info
 	var token, owner, repoName string
@@ -191,12 +497,93 @@ func (o *Orchestrator) ContinueTask(ctx context.Context, taskID, followUpMsg, mo
 		}
 	}
 
-	return o.submitTaskJob(ctx, taskID, projectID, followUpMsg, modelID, owner, repoName, token, true)
+	return o.submitTaskJob(ctx, taskID, projectID, followUpMsg, modelID, owner, repoName, token, "", jobContinue)
+}
+
+// RerunTask runs the task's original intent again, typically with a different
+// model, as a new agent run. Unlike ContinueTask it starts from a clean
+// workspace and an empty conversation; the previous attempt's branch is kept
+// as TaskBranchName(taskID)+"-run-<runID>" so the two results can be diffed.
+func (o *Orchestrator) RerunTask(ctx context.Context, taskID, modelID string) error {
+	return o.rerunTask(ctx, taskID, "", modelID, "")
+}
+
+// RerunTaskWithSystemPrompt reruns the task like RerunTask, sending
+// systemPrompt to the agent instead of the assembled one. Use it to try a
+// tweak of a prompt inspected with GetRunSystemPrompt.
+func (o *Orchestrator) RerunTaskWithSystemPrompt(ctx context.Context, taskID, modelID, systemPrompt string) error {
+	if strings.TrimSpace(systemPrompt) == "" {
+		return fmt.Errorf("system prompt cannot be empty")
+	}
+	return o.rerunTask(ctx, taskID, "", modelID, systemPrompt)
+}
+
+// RetryTaskWithIntent replaces the task's intent, and with it the title, then
+// reruns it from a clean workspace like RerunTask. Use it when the original
+// intent was slightly off and the task is worth keeping.
+func (o *Orchestrator) RetryTaskWithIntent(ctx context.Context, taskID, intent string) error {
+	intent = strings.TrimSpace(intent)
+	if intent == "" {
+		return fmt.Errorf("intent cannot be empty")
+	}
+	return o.rerunTask(ctx, taskID, intent, "", "")
+}
+
+// rerunTask implements RerunTask, first replacing the task's intent when
+// intent is set. A non-empty systemPrompt overrides the assembled one.
+func (o *Orchestrator) rerunTask(ctx context.Context, taskID, intent, modelID, systemPrompt string) error {
+	modelID, err := llm.NormalizeModelID(modelID)
+	if err != nil {
+		return err
+	}
+	task, err := o.repo.Get(ctx, taskID)
+	if err != nil {
+		return fmt.Errorf("task not found: %w", err)
+	}
+	if !CanTransition(task.Status, "in_progress") {
+		return ErrInvalidTransition{TaskID: taskID, From: task.Status, To: "in_progress"}
+	}
+	if o.isActive(taskID) {
+		return ErrTaskRunning{TaskID: taskID}
+	}
+
+	var token, owner, repoName string
+	var projectID string
+	if task.RepositoryID != nil {
+		projectID = *task.RepositoryID
+		repo, err := o.repo.GetRepository(ctx, projectID)
+		if err == nil {
+			conn, err := o.repo.GetGithubConnectionByID(ctx, repo.ConnectionID)
+			if err == nil {
+				token = conn.AccessToken
+				owner = repo.Owner
+				repoName = repo.Name
+			}
+		}
+	}
+
+	if intent != "" && intent != task.Intent {
+		if err := o.repo.UpdateTaskTitleIntent(ctx, taskID, intent, intent); err != nil {
+			return fmt.Errorf("failed to update intent: %w", err)
+		}
+		task.Intent = intent
+		o.logTask(taskID, LogLevelInfo, "Intent updated for retry")
+	}
+
+	if previousRun, err := o.repo.GetLatestAgentRun(ctx, taskID); err == nil && previousRun != nil {
+		archive := TaskBranchName(taskID) + "-run-" + previousRun.ID
+		if err := o.repoManager.ArchiveWorkspace(ctx, taskID, archive); err != nil {
+			return fmt.Errorf("failed to archive previous workspace: %w", err)
+		}
+		o.logTask(taskID, LogLevelInfo, "Previous attempt kept on "+archive)
+	}
+
+	return o.submitTaskJob(ctx, taskID, projectID, task.Intent, modelID, owner, repoName, token, systemPrompt, jobRerun)
 }
 
-func (o *Orchestrator) submitTaskJob(ctx context.Context, taskID, projectID, intent, modelID, owner, repoName, token string, isContinuation bool) error {
+func (o *Orchestrator) submitTaskJob(ctx context.Context, taskID, projectID, intent, modelID, owner, repoName, token, systemPrompt string, kind jobKind) error {
 	messageHistoryJSON := ""
-	if isContinuation {
+	if kind == jobContinue {
 		// Load existing messages for state restoration
 		messages, err := o.repo.GetMessagesByTask(ctx, taskID)
 		if err != nil {
@@ -220,20 +607,34 @@ func (o *Orchestrator) submitTaskJob(ctx context.Context, taskID, projectID, int
 		Repo:           repoName,
 		Token:          token,
 		MessageHistory: messageHistoryJSON,
+		Rerun:          kind == jobRerun,
+		SystemPrompt:   systemPrompt,
 		ResultCh:       o.resultCh,
 	}
 
 	slog.Info("[ORCHESTRATOR] Submitting job to worker pool", "task_id", taskID)
+	o.mu.Lock()
+	_, running := o.running[taskID]
+	_, queued := o.queued[taskID]
+	if running || queued {
+		o.mu.Unlock()
+		return ErrTaskRunning{TaskID: taskID}
+	}
+	o.queued[taskID] = struct{}{}
+	o.mu.Unlock()
 	if err := o.workerPool.Submit(func() {
 		o.executeTask(context.Background(), job)
 	}); err != nil {
+		o.mu.Lock()
+		delete(o.queued, taskID)
+		o.mu.Unlock()
 		slog.Error("[ORCHESTRATOR] Failed to submit job to worker pool", "error", err, "task_id", taskID)
 		return err
 	}
 
 	// Publish appropriate events
 	eventType := EventTypeTaskStarted
-	if job.MessageHistory != "" {
+	if kind != jobNew {
 		eventType = EventTypeTaskUpdated
 	}
 
@@ -255,11 +656,18 @@ func (o *Orchestrator) submitTaskJob(ctx context.Context, taskID, projectID, int
 
 // executeTask executes a single task.
 func (o *Orchestrator) executeTask(ctx context.Context, job TaskJob) {
-	slog.Info("[ORCHESTRATOR] Executing task", "task_id", job.TaskID, "intent", job.Intent)
+	logger := slog.Default().With("task_id", job.TaskID)
+	if job.Owner != "" {
+		logger = logger.With("repo", job.Owner+"/"+job.Repo)
+	}
+	logger.Info("[ORCHESTRATOR] Executing task", "intent", job.Intent)
 
 	// Check if incoming context is already cancelled
 	if ctx.Err() != nil {
-		slog.Error("[ORCHESTRATOR] Incoming context already cancelled", "task_id", job.TaskID, "error", ctx.Err())
+		o.mu.Lock()
+		delete(o.queued, job.TaskID)
+		o.mu.Unlock()
+		logger.Error("[ORCHESTRATOR] Incoming context already cancelled", "error", ctx.Err())
 		job.ResultCh <- TaskResult{TaskID: job.TaskID, Success: false, Error: fmt.Sprintf("context cancelled before execution: %v", ctx.Err())}
 		return
 	}
@@ -267,38 +675,40 @@ func (o *Orchestrator) executeTask(ctx context.Context, job TaskJob) {
 	// Create a fresh context with timeout (don't inherit from request context which may be cancelled)
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
 	defer cancel()
+	ctx = WithLogger(ctx, logger)
 
 	// Track running task
+	done := make(chan struct{})
 	o.mu.Lock()
+	delete(o.queued, job.TaskID)
 	o.running[job.TaskID] = cancel
+	o.runDone[job.TaskID] = done
+	o.live[job.TaskID] = liveRun{modelID: job.ModelID, startedAt: time.Now()}
 	o.mu.Unlock()
 
 	defer func() {
 		o.mu.Lock()
 		delete(o.running, job.TaskID)
+		delete(o.runDone, job.TaskID)
+		delete(o.live, job.TaskID)
 		o.mu.Unlock()
+		close(done)
 	}()
 
 	// Update task to in_progress
-	slog.Info("[ORCHESTRATOR] Updating task status to in_progress", "task_id", job.TaskID)
-	if err := o.repo.UpdateStatus(ctx, job.TaskID, "in_progress"); err != nil {
-		slog.Error("[ORCHESTRATOR] Failed to update status", "error", err)
-		job.ResultCh <- TaskResult{TaskID: job.TaskID, Success: false, Error: err.Error()}
+	logger.Info("[ORCHESTRATOR] Updating task status to in_progress")
+	version, err := o.repo.UpdateStatus(ctx, job.TaskID, "in_progress")
+	if err != nil {
+		logger.Error("[ORCHESTRATOR] Failed to update status", "error", err)
+		job.ResultCh <- TaskResult{TaskID: job.TaskID, Version: version, Success: false, Error: err.Error()}
 		return
 	}
-	slog.Info("[ORCHESTRATOR] Task status updated successfully", "task_id", job.TaskID)
-
-	// Publish agent_run_started event
-	o.eventBus.Publish(models.Event{
-		TaskID: job.TaskID,
-		Type:   string(EventTypeAgentRunStarted),
-		Data:   "",
-	})
+	logger.Info("[ORCHESTRATOR] Task status updated successfully")
 
 	// Get settings for backend preference
 	settings, err := o.settings.GetSettings(ctx)
 	if err != nil {
-		slog.Warn("[ORCHESTRATOR] Failed to get settings, defaulting to native backend", "error", err)
+		logger.Warn("[ORCHESTRATOR] Failed to get settings, defaulting to native backend", "error", err)
 	}
 	backendType := "native"
 	if settings != nil && settings.AgentBackend != "" {
@@ -307,77 +717,100 @@ func (o *Orchestrator) executeTask(ctx context.Context, job TaskJob) {
 
 	// Get backend_session_id from previous run BEFORE creating new one
 	var backendSessionID string
-	slog.Info("[ORCHESTRATOR] Getting previous run for session ID", "task_id", job.TaskID)
+	logger.Info("[ORCHESTRATOR] Getting previous run for session ID")
 	previousRun, err := o.repo.GetLatestAgentRun(ctx, job.TaskID)
 	if err != nil && err != sql.ErrNoRows {
-		slog.Error("[ORCHESTRATOR] Failed to get previous agent run", "error", err)
+		logger.Error("[ORCHESTRATOR] Failed to get previous agent run", "error", err)
 	}
-	if previousRun != nil && previousRun.BackendSessionID.Valid {
+	if previousRun != nil && previousRun.BackendSessionID.Valid && !job.Rerun {
 		backendSessionID = previousRun.BackendSessionID.String
-		slog.Info("[ORCHESTRATOR] Found previous backend session", "task_id", job.TaskID, "session_id", backendSessionID)
+		logger.Info("[ORCHESTRATOR] Found previous backend session", "session_id", backendSessionID)
 	}
 
 	// Create agent run
-	slog.Info("[ORCHESTRATOR] Creating agent run", "task_id", job.TaskID, "intent", job.Intent, "backend", backendType)
+	logger.Info("[ORCHESTRATOR] Creating agent run", "intent", job.Intent, "backend", backendType)
 	runID, err := o.repo.CreateAgentRun(ctx, job.TaskID, job.Intent, backendType, "", "")
 	if err != nil {
-		slog.Error("[ORCHESTRATOR] Failed to create agent run", "error", err, "task_id", job.TaskID)
-		job.ResultCh <- TaskResult{TaskID: job.TaskID, Success: false, Error: err.Error()}
+		logger.Error("[ORCHESTRATOR] Failed to create agent run", "error", err)
+		job.ResultCh <- TaskResult{TaskID: job.TaskID, Version: version, Success: false, Error: err.Error()}
 		return
 	}
-	slog.Info("[ORCHESTRATOR] Agent run created successfully", "task_id", job.TaskID)
+	logger = logger.With("run_id", runID)
+	ctx = WithLogger(ctx, logger)
+	logger.Info("[ORCHESTRATOR] Agent run created successfully")
+
+	// Published once the run exists, so clients tailing the previous run
+	// can tell it was superseded
+	o.eventBus.Publish(models.Event{
+		TaskID: job.TaskID,
+		Type:   string(EventTypeAgentRunStarted),
+		Data:   fmt.Sprintf(`{"run_id":%q}`, runID),
+	})
 
 	// Append user message to DB immediately
 	if err := o.repo.CreateMessage(ctx, job.TaskID, runID, "user", job.Intent); err != nil {
-		slog.Error("[ORCHESTRATOR] Failed to create user message", "error", err)
+		logger.Error("[ORCHESTRATOR] Failed to create user message", "error", err)
 	}
 
 	// Create workspace for isolated execution
 	branchName := TaskBranchName(job.TaskID)
-	slog.Info("[ORCHESTRATOR] Creating workspace", "task_id", job.TaskID, "branch", branchName)
-	workspacePath, err := o.repoManager.CreateWorkspace(ctx, job.TaskID, branchName)
+	logger.Info("[ORCHESTRATOR] Creating workspace", "branch", branchName)
+	workspacePath, err := o.repoManager.CreateWorkspace(ctx, job.TaskID, branchName, o.taskBaseBranch(ctx, job.TaskID))
 	if err != nil {
-		slog.Error("[ORCHESTRATOR] Failed to create workspace", "error", err)
-		job.ResultCh <- TaskResult{TaskID: job.TaskID, Success: false, Error: err.Error()}
+		logger.Error("[ORCHESTRATOR] Failed to create workspace", "error", err)
+		job.ResultCh <- TaskResult{TaskID: job.TaskID, Version: version, Success: false, Error: err.Error()}
 		return
 	}
-	slog.Info("[ORCHESTRATOR] Workspace created", "task_id", job.TaskID, "path", workspacePath)
+	logger.Info("[ORCHESTRATOR] Workspace created", "path", workspacePath)
+	o.logTask(job.TaskID, LogLevelInfo, "Workspace ready on branch "+branchName)
+
+	commitStatus := o.startCommitStatus(ctx, job)
+	defer commitStatus.finish(ctx, false)
 
 	systemPrompt := buildSystemPrompt(o.repoManager, workspacePath)
+	if job.SystemPrompt != "" {
+		systemPrompt = job.SystemPrompt
+		o.logTask(job.TaskID, LogLevelInfo, "Using overridden system prompt")
+	}
+	if o.promptPrefix != "" {
+		systemPrompt = applyPromptPrefix(o.promptPrefix, systemPrompt)
+		logger.Info("[ORCHESTRATOR] Applied prompt prefix", "bytes", len(o.promptPrefix))
+		o.logTask(job.TaskID, LogLevelInfo, "Applied the server's prompt prefix")
+	}
+	if err := o.repo.UpdateAgentRunSystemPrompt(ctx, runID, systemPrompt); err != nil {
+		logger.Warn("[ORCHESTRATOR] Failed to record system prompt", "error", err)
+	}
+
+	projectEnv := o.settings.ProjectEnv(ctx, job.ProjectID)
+	if len(projectEnv) > 0 {
+		o.setRedactor(job.TaskID, projectEnv)
+		defer o.redactors.Delete(job.TaskID)
+	}
+	defer o.liveDiffSizes.Delete(job.TaskID)
 
 	// Parse ModelID first to determine provider (format: "provider#model" e.g., "zai#glm-4.7" or "o#anthropic/claude-sonnet-4.5")
 	provider := ""
 	model := ""
 	if job.ModelID != "" && backendType == "native" {
-		parts := strings.SplitN(job.ModelID, "#", 2)
-		if len(parts) == 2 {
-			providerPrefix := parts[0]
-			model = parts[1]
-			// Map provider prefix to actual provider name
-			switch providerPrefix {
-			case "o":
-				provider = "openrouter"
-			case "zai":
-				provider = "zai"
-			default:
-				provider = providerPrefix
-			}
-		} else {
-			model = parts[0]
+		provider, model, err = llm.ParseModelID(job.ModelID)
+		if err != nil {
+			logger.Error("[ORCHESTRATOR] Invalid model ID", "error", err)
+			job.ResultCh <- TaskResult{TaskID: job.TaskID, Version: version, Success: false, Error: err.Error()}
+			return
 		}
 	}
-	slog.Info("[ORCHESTRATOR] Provider and model determined", "task_id", job.TaskID, "provider", provider, "model", model)
+	logger.Info("[ORCHESTRATOR] Provider and model determined", "provider", provider, "model", model)
 
 	// Get API key for the provider (or default if provider is empty)
-	slog.Info("[ORCHESTRATOR] Getting API key from settings", "task_id", job.TaskID, "provider", provider)
+	logger.Info("[ORCHESTRATOR] Getting API key from settings", "provider", provider)
 	apiKey, actualProvider, actualModel, err := o.settings.GetAPIKeyForProvider(ctx, provider)
 	if err != nil {
 		if backendType != "codex" {
-			slog.Error("[ORCHESTRATOR] Failed to get API key", "error", err)
-			job.ResultCh <- TaskResult{TaskID: job.TaskID, Success: false, Error: err.Error()}
+			logger.Error("[ORCHESTRATOR] Failed to get API key", "error", err)
+			job.ResultCh <- TaskResult{TaskID: job.TaskID, Version: version, Success: false, Error: err.Error()}
 			return
 		}
-		slog.Warn("[ORCHESTRATOR] Codex backend proceeding without settings API key", "error", err)
+		logger.Warn("[ORCHESTRATOR] Codex backend proceeding without settings API key", "error", err)
 		actualProvider = provider
 		actualModel = model
 	}
@@ -391,8 +824,8 @@ func (o *Orchestrator) executeTask(ctx context.Context, job TaskJob) {
 		if provider != "openai" && provider != "openrouter" {
 			apiKey, _, _, err = o.settings.GetAPIKeyForProvider(ctx, "openai")
 			if err != nil && backendType != "codex" {
-				slog.Error("[ORCHESTRATOR] Failed to get OpenAI API key", "error", err)
-				job.ResultCh <- TaskResult{TaskID: job.TaskID, Success: false, Error: err.Error()}
+				logger.Error("[ORCHESTRATOR] Failed to get OpenAI API key", "error", err)
+				job.ResultCh <- TaskResult{TaskID: job.TaskID, Version: version, Success: false, Error: err.Error()}
 				return
 			}
 			provider = "openai"
@@ -402,19 +835,32 @@ func (o *Orchestrator) executeTask(ctx context.Context, job TaskJob) {
 	if backendType == "claude-code" {
 		model = fixedClaudeCodeModel(provider)
 	}
-	slog.Info("[ORCHESTRATOR] Retrieved API settings", "task_id", job.TaskID, "provider", provider, "model", model)
+	logger.Info("[ORCHESTRATOR] Retrieved API settings", "provider", provider, "model", model)
+	if err := o.repo.UpdateAgentRunModel(ctx, runID, provider, model); err != nil {
+		logger.Warn("[ORCHESTRATOR] Failed to record run model", "error", err)
+	}
+
+	var rawLog *agent.RawLog
+	if backendType == "codex" || backendType == "claude-code" {
+		var closeRawLog func()
+		rawLog, closeRawLog = o.openRawLog(job.TaskID, runID)
+		defer closeRawLog()
+	}
 
 	// Create agent backend
 	var backend agent.Backend
-	if backendType == "codex" {
-		slog.Info("[ORCHESTRATOR] Initializing Codex backend", "task_id", job.TaskID)
+	if o.newBackend != nil {
+		logger.Info("[ORCHESTRATOR] Initializing backend from factory")
+		backend, err = o.newBackend(workspacePath, systemPrompt)
+	} else if backendType == "codex" {
+		logger.Info("[ORCHESTRATOR] Initializing Codex backend")
 		baseURL := ""
 		switch provider {
 		case "openrouter":
 			baseURL = "https://openrouter.ai/api/v1"
 		case "openai":
 		default:
-			job.ResultCh <- TaskResult{TaskID: job.TaskID, Success: false, Error: fmt.Sprintf("unsupported provider for codex backend: %s", provider)}
+			job.ResultCh <- TaskResult{TaskID: job.TaskID, Version: version, Success: false, Error: fmt.Sprintf("unsupported provider for codex backend: %s", provider)}
 			return
 		}
 
@@ -430,27 +876,30 @@ func (o *Orchestrator) executeTask(ctx context.Context, job TaskJob) {
 		if backendSessionID != "" {
 			codexOpts = append(codexOpts, agent.WithCodexSessionID(backendSessionID))
 		}
+		if o.container != nil {
+			codexOpts = append(codexOpts, agent.WithCodexContainer(*o.container))
+		}
+		if len(projectEnv) > 0 {
+			codexOpts = append(codexOpts, agent.WithCodexEnv(projectEnv))
+		}
+		if rawLog != nil {
+			codexOpts = append(codexOpts, agent.WithCodexRawLog(rawLog))
+		}
+		if o.settings != nil && o.settings.ShowThinking(ctx) {
+			codexOpts = append(codexOpts, agent.WithCodexReasoning(true))
+		}
 
-		slog.Info("[ORCHESTRATOR] Using existing session ID", "task_id", job.TaskID, "session_id", backendSessionID)
+		logger.Info("[ORCHESTRATOR] Using existing session ID", "session_id", backendSessionID)
 
 		backend, err = agent.NewCodexBackend(codexOpts...)
 	} else if backendType == "claude-code" {
 		// Create LLM provider
-		var llmProvider llm.Provider
-		switch provider {
-		case "anthropic":
-			llmProvider = llm.NewAnthropicProvider(apiKey)
-		case "openrouter":
-			llmProvider = llm.NewOpenRouterProvider(apiKey)
-		case "zai":
-			llmProvider = llm.NewZaiProvider(apiKey)
-		default:
-			job.ResultCh <- TaskResult{TaskID: job.TaskID, Success: false, Error: fmt.Sprintf("unsupported provider: %s", provider)}
+		if _, err := newLLMProvider(provider, apiKey); err != nil {
+			job.ResultCh <- TaskResult{TaskID: job.TaskID, Version: version, Success: false, Error: err.Error()}
 			return
 		}
-		llmProvider.SetModel(model)
 
-		slog.Info("[ORCHESTRATOR] Initializing Claude Code backend", "task_id", job.TaskID)
+		logger.Info("[ORCHESTRATOR] Initializing Claude Code backend")
 		baseURL := ""
 		switch provider {
 		case "zai":
@@ -471,88 +920,205 @@ func (o *Orchestrator) executeTask(ctx context.Context, job TaskJob) {
 		if backendSessionID != "" {
 			claudeOpts = append(claudeOpts, agent.WithSessionID(backendSessionID))
 		}
+		if o.container != nil {
+			claudeOpts = append(claudeOpts, agent.WithClaudeContainer(*o.container))
+		}
+		if len(projectEnv) > 0 {
+			claudeOpts = append(claudeOpts, agent.WithClaudeEnv(projectEnv))
+		}
+		if o.toolTimeout > 0 {
+			claudeOpts = append(claudeOpts, agent.WithClaudeCommandTimeout(o.toolTimeout))
+		}
+		if rawLog != nil {
+			claudeOpts = append(claudeOpts, agent.WithClaudeRawLog(rawLog))
+		}
 
-		slog.Info("[ORCHESTRATOR] Using existing session ID", "task_id", job.TaskID, "session_id", backendSessionID)
+		logger.Info("[ORCHESTRATOR] Using existing session ID", "session_id", backendSessionID)
 
 		backend, err = agent.NewClaudeCodeBackend(claudeOpts...)
 	} else {
 		// Create LLM provider
-		var llmProvider llm.Provider
-		switch provider {
-		case "anthropic":
-			llmProvider = llm.NewAnthropicProvider(apiKey)
-		case "openrouter":
-			llmProvider = llm.NewOpenRouterProvider(apiKey)
-		case "zai":
-			llmProvider = llm.NewZaiProvider(apiKey)
-		default:
-			job.ResultCh <- TaskResult{TaskID: job.TaskID, Success: false, Error: fmt.Sprintf("unsupported provider: %s", provider)}
+		llmProvider, err := newLLMProvider(provider, apiKey)
+		if err != nil {
+			job.ResultCh <- TaskResult{TaskID: job.TaskID, Version: version, Success: false, Error: err.Error()}
 			return
 		}
 		llmProvider.SetModel(model)
 
 		// Default to native
-		slog.Info("[ORCHESTRATOR] Initializing Native backend", "task_id", job.TaskID)
-		backend, err = agent.NewNativeBackend(
-			agent.WithProvider(llmProvider),
-			agent.WithWorkDir(workspacePath),
-			agent.WithSystemPrompt(systemPrompt),
-		)
+		logger.Info("[ORCHESTRATOR] Initializing Native backend")
+		backend, err = o.newNativeBackend(ctx, llmProvider, workspacePath, systemPrompt, projectEnv)
 	}
 
 	if err != nil {
-		slog.Error("[ORCHESTRATOR] Failed to create backend", "error", err, "backend", backendType)
-		job.ResultCh <- TaskResult{TaskID: job.TaskID, Success: false, Error: err.Error()}
+		logger.Error("[ORCHESTRATOR] Failed to create backend", "error", err, "backend", backendType)
+		job.ResultCh <- TaskResult{TaskID: job.TaskID, Version: version, Success: false, Error: err.Error()}
 		return
 	}
+	// Kills a CLI backend's subprocess if the run is cut short
+	defer func() { _ = backend.Close() }()
+	o.setLiveBackend(job.TaskID, backend)
 
 	// Restore state if continuing
 	if job.MessageHistory != "" {
 		if err := backend.RestoreState(job.MessageHistory); err != nil {
-			slog.Error("[ORCHESTRATOR] Failed to restore state", "error", err)
+			logger.Error("[ORCHESTRATOR] Failed to restore state", "error", err)
 		}
 	}
 
 	// Execute task
-	slog.Info("[ORCHESTRATOR] Starting agent execution", "task_id", job.TaskID)
+	logger.Info("[ORCHESTRATOR] Starting agent execution")
+	o.logTask(job.TaskID, LogLevelInfo, fmt.Sprintf("Agent started (%s backend)", backendType))
 	stream := backend.Stream(ctx, job.Intent)
 	execErr := o.consumeAgentStream(ctx, job.TaskID, runID, stream)
+	if execErr != nil && backendType == "native" && agent.IsRetryableLLMError(execErr) {
+		backend, execErr = o.runFallbacks(ctx, job, runID, provider, model, workspacePath, systemPrompt, backend, execErr)
+	}
+	// Before the commit, so the artifacts dir isn't committed; a failed
+	// run's build log is worth keeping too
+	o.collectArtifacts(ctx, job.TaskID, runID, workspacePath)
 	if execErr != nil {
-		slog.Error("[ORCHESTRATOR] Agent execution failed", "error", execErr, "task_id", job.TaskID)
-		job.ResultCh <- TaskResult{TaskID: job.TaskID, Success: false, Error: execErr.Error()}
+		logger.Error("[ORCHESTRATOR] Agent execution failed", "error", execErr)
+		o.logTask(job.TaskID, LogLevelError, "Agent failed: "+execErr.Error())
+		job.ResultCh <- TaskResult{TaskID: job.TaskID, Version: version, Success: false, Error: execErr.Error()}
 		return
 	}
 
-	slog.Info("[ORCHESTRATOR] Agent execution completed", "task_id", job.TaskID)
+	logger.Info("[ORCHESTRATOR] Agent execution completed")
 
 	// Commit changes dont push just yet
 	commitMessage := fmt.Sprintf("Task: %s", job.Intent)
-	if err := o.repoManager.Commit(ctx, job.TaskID, commitMessage); err != nil {
-		slog.Error("[ORCHESTRATOR] Failed to commit and push", "error", err)
-		// Don't fail task - commit might fail if no changes
+	if err := o.repoManager.Commit(ctx, job.TaskID, commitMessage, o.commitIdentity(ctx, job.ProjectID)); err != nil {
+		logger.Error("[ORCHESTRATOR] Failed to commit and push", "error", err)
+		// Don't fail task; the changes stay in the workspace for the diff
+		o.logTask(job.TaskID, LogLevelError, "Commit failed: "+err.Error())
 	}
 
 	// Get git diff
 	gitDiff, err := o.repoManager.GetDiff(ctx, job.TaskID)
+	noChanges := err == nil && strings.TrimSpace(gitDiff) == ""
 	if err != nil {
-		slog.Warn("[ORCHESTRATOR] Failed to get git diff", "task_id", job.TaskID, "error", err)
+		logger.Warn("[ORCHESTRATOR] Failed to get git diff", "error", err)
+	} else {
+		gitDiff = o.redact(job.TaskID, gitDiff)
+		savedDiff, artifactPath := o.capOutput(job.TaskID, "diff.patch", gitDiff, o.outputLimits.Diff)
+		// The numstat is saved alongside, so the diff's size can be checked
+		// once the workspace is gone without reading the full diff back
+		stat, err := o.repoManager.DiffStat(ctx, job.TaskID)
+		if err != nil || stat == nil {
+			stat = DiffStatFromPatch(gitDiff)
+		}
+		if err := o.repo.SaveDiff(ctx, job.TaskID, savedDiff, artifactPath, stat); err != nil {
+			logger.Warn("[ORCHESTRATOR] Failed to save git diff", "error", err)
+		}
 	}
 	if gitDiff != "" {
-		slog.Info("[ORCHESTRATOR] Git diff generated", "task_id", job.TaskID, "diff_size", len(gitDiff))
+		logger.Info("[ORCHESTRATOR] Git diff generated", "diff_size", len(gitDiff))
+		o.logTask(job.TaskID, LogLevelInfo, "Changes committed to "+branchName)
+	}
+	if noChanges {
+		logger.Info("[ORCHESTRATOR] Agent made no changes")
 	}
 
 	// Get final message from backend
 	finalMessage := backend.FinalMessage()
 
+	// Before the result, which may create a PR pushing the same branch
+	commitStatus.finish(ctx, true)
+
 	// Send result
 	job.ResultCh <- TaskResult{
 		TaskID:      job.TaskID,
+		Version:     version,
 		Success:     true,
 		AgentOutput: finalMessage,
 		GitDiff:     gitDiff,
+		NoChanges:   noChanges,
 	}
 
-	slog.Info("[ORCHESTRATOR] Task completed", "task_id", job.TaskID, "success", true)
+	logger.Info("[ORCHESTRATOR] Task completed", "success", true)
+}
+
+// runFallbacks retries a native run that failed with a transient provider
+// error on each configured fallback model in turn. It stops at the first
+// success or at an error another provider can't fix (bad key, bad request),
+// returning the backend that ran last and its error.
+func (o *Orchestrator) runFallbacks(ctx context.Context, job TaskJob, runID, provider, model, workspacePath, systemPrompt string, backend agent.Backend, execErr error) (agent.Backend, error) {
+	logger := taskLogger(ctx, job.TaskID)
+	settings, err := o.settings.GetSettings(ctx)
+	if err != nil || settings == nil {
+		return backend, execErr
+	}
+	for _, fb := range settings.FallbackModels {
+		if fb.Provider == provider && fb.Model == model {
+			continue
+		}
+		apiKey, _, _, err := o.settings.GetAPIKeyForProvider(ctx, fb.Provider)
+		if err != nil || apiKey == "" {
+			logger.Warn("[ORCHESTRATOR] Skipping fallback without API key", "provider", fb.Provider)
+			continue
+		}
+		llmProvider, err := newLLMProvider(fb.Provider, apiKey)
+		if err != nil {
+			continue
+		}
+		llmProvider.SetModel(fb.Model)
+		next, err := o.newNativeBackend(ctx, llmProvider, workspacePath, systemPrompt, o.settings.ProjectEnv(ctx, job.ProjectID))
+		if err != nil {
+			logger.Error("[ORCHESTRATOR] Failed to create fallback backend", "error", err, "provider", fb.Provider)
+			continue
+		}
+		// The failed model may already have edited the workspace, so the
+		// fallback picks its conversation up rather than starting over
+		intent := job.Intent
+		state := backend.GetState()
+		if state == "" {
+			state = job.MessageHistory
+		} else {
+			intent = ""
+		}
+		if state != "" {
+			if err := next.RestoreState(state); err != nil {
+				logger.Error("[ORCHESTRATOR] Failed to restore state", "error", err)
+				intent = job.Intent
+			}
+		}
+
+		logger.Warn("[ORCHESTRATOR] Falling back to next model", "provider", fb.Provider, "model", fb.Model, "error", execErr)
+		o.logTask(job.TaskID, LogLevelWarn, fmt.Sprintf("%s/%s failed (%v), falling back to %s/%s", provider, model, execErr, fb.Provider, fb.Model))
+		_ = backend.Close()
+		backend, provider, model = next, fb.Provider, fb.Model
+		o.setLiveBackend(job.TaskID, backend)
+		if err := o.repo.UpdateAgentRunModel(ctx, runID, provider, model); err != nil {
+			logger.Warn("[ORCHESTRATOR] Failed to record run model", "error", err)
+		}
+
+		execErr = o.consumeAgentStream(ctx, job.TaskID, runID, backend.Stream(ctx, intent))
+		if execErr == nil || !agent.IsRetryableLLMError(execErr) {
+			break
+		}
+	}
+	return backend, execErr
+}
+
+// newNativeBackend creates a native backend running in workspacePath. env is
+// added to the environment of its tool commands.
+func (o *Orchestrator) newNativeBackend(ctx context.Context, llmProvider llm.Provider, workspacePath, systemPrompt string, env []string) (agent.Backend, error) {
+	nativeOpts := []agent.NativeBackendOption{
+		agent.WithProvider(llmProvider),
+		agent.WithWorkDir(workspacePath),
+		agent.WithSystemPrompt(systemPrompt),
+		agent.WithEnv(env),
+		agent.WithProtectedPaths(o.protectedPaths),
+		agent.WithToolTimeout(o.toolTimeout),
+	}
+	if o.settings != nil {
+		nativeOpts = append(nativeOpts, agent.WithIterationLimits(o.settings.IterationLimits(ctx)))
+	}
+	if o.llmTimeouts != nil {
+		nativeOpts = append(nativeOpts, agent.WithLLMTimeouts(*o.llmTimeouts))
+	}
+	return agent.NewNativeBackend(nativeOpts...)
 }
 
 // consumeAgentStream drains a stream of agent events, persists assembled messages, and publishes SSE updates.
@@ -576,8 +1142,18 @@ func (o *Orchestrator) consumeAgentStream(ctx context.Context, taskID, runID str
 				if event.SessionID != "" {
 					o.saveBackendSessionID(taskID, event.SessionID)
 				}
+			case agent.EventIterationLimit:
+				o.logTask(taskID, LogLevelWarn, "Agent stopped: "+event.Notice)
+				if err := o.repo.UpdateAgentRunLimitReached(ctx, runID, event.Notice); err != nil {
+					taskLogger(ctx, taskID).Error("[ORCHESTRATOR] Failed to record run limit", "error", err)
+				}
+			case agent.EventFileEdit:
+				o.publishFileDiff(ctx, taskID, event.Path)
 			case agent.EventTodo:
 				if event.Todos != nil {
+					if err := o.repo.SaveTodos(ctx, taskID, event.Todos); err != nil {
+						taskLogger(ctx, taskID).Error("[ORCHESTRATOR] Failed to save todos", "error", err)
+					}
 					if data, err := json.Marshal(event.Todos); err == nil {
 						o.eventBus.Publish(models.Event{TaskID: taskID, Type: "todo", Data: string(data)})
 					}
@@ -599,8 +1175,225 @@ func (o *Orchestrator) consumeAgentStream(ctx context.Context, taskID, runID str
 	return nil
 }
 
+// rawLogPath returns where the raw output of a run is kept.
+func (o *Orchestrator) rawLogPath(taskID, runID string) string {
+	return filepath.Join(o.rawLogDir, taskID, runID+".log")
+}
+
+// openRawLog creates the raw log file for a run. It returns a nil log when
+// raw logs are disabled or the file can't be created; the run goes on either
+// way.
+func (o *Orchestrator) openRawLog(taskID, runID string) (*agent.RawLog, func()) {
+	if o.rawLogDir == "" {
+		return nil, func() {}
+	}
+	path := o.rawLogPath(taskID, runID)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		slog.Warn("[ORCHESTRATOR] Failed to create raw log dir", "task_id", taskID, "error", err)
+		return nil, func() {}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		slog.Warn("[ORCHESTRATOR] Failed to create raw log", "task_id", taskID, "error", err)
+		return nil, func() {}
+	}
+	return agent.NewRawLog(redactWriter{o: o, taskID: taskID, w: f}, o.rawLogMaxBytes), func() { _ = f.Close() }
+}
+
+// RawLogPath returns the raw output file of a task's run, or of its latest
+// run when runID is empty. It fails with os.ErrNotExist when the run has no
+// raw log, e.g. because it used the native backend.
+func (o *Orchestrator) RawLogPath(ctx context.Context, taskID, runID string) (string, error) {
+	if o.rawLogDir == "" {
+		return "", os.ErrNotExist
+	}
+	var run *sqlc.AgentRun
+	var err error
+	if runID == "" {
+		run, err = o.repo.GetLatestAgentRun(ctx, taskID)
+	} else {
+		run, err = o.repo.GetAgentRun(ctx, runID)
+	}
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && (run == nil || run.TaskID != taskID)) {
+		return "", os.ErrNotExist
+	}
+	if err != nil {
+		return "", err
+	}
+	path := o.rawLogPath(taskID, run.ID)
+	if _, err := os.Stat(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// GetRunSystemPrompt returns the system prompt a task's run was given. It
+// fails with os.ErrNotExist when the run is unknown, belongs to another task
+// or predates prompts being recorded.
+func (o *Orchestrator) GetRunSystemPrompt(ctx context.Context, taskID, runID string) (string, error) {
+	run, err := o.repo.GetAgentRun(ctx, runID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && (run == nil || run.TaskID != taskID)) {
+		return "", os.ErrNotExist
+	}
+	if err != nil {
+		return "", err
+	}
+	if !run.SystemPrompt.Valid {
+		return "", os.ErrNotExist
+	}
+	return run.SystemPrompt.String, nil
+}
+
+// redactWriter hides a task's project env values in what it writes. RawLog
+// writes a whole line per call, so values are never split across writes.
+type redactWriter struct {
+	o      *Orchestrator
+	taskID string
+	w      io.Writer
+}
+
+func (r redactWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(r.w, r.o.redact(r.taskID, string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// minRedactLen is the shortest env value redacted from agent output. Shorter
+// values like CI=true or DEBUG=1 would blank out ordinary words.
+const minRedactLen = 8
+
+// setRedactor registers the values of env ("NAME=value" pairs) for
+// redaction from taskID's agent output. Values are also matched as escaped
+// inside a JSON string, the way they appear in CLI output and streamed tool
+// arguments.
+func (o *Orchestrator) setRedactor(taskID string, env []string) {
+	var pairs []string
+	for _, kv := range env {
+		_, value, _ := strings.Cut(kv, "=")
+		if len(value) < minRedactLen {
+			continue
+		}
+		pairs = append(pairs, value, "[REDACTED]")
+		for _, escaped := range jsonEscapes(value) {
+			if escaped != value {
+				pairs = append(pairs, escaped, "[REDACTED]")
+			}
+		}
+	}
+	if len(pairs) > 0 {
+		o.redactors.Store(taskID, strings.NewReplacer(pairs...))
+	}
+}
+
+// jsonEscapes returns s as escaped inside a JSON string, with and without
+// HTML characters escaped.
+func jsonEscapes(s string) []string {
+	var escapes []string
+	for _, escapeHTML := range []bool{true, false} {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(escapeHTML)
+		if err := enc.Encode(s); err != nil {
+			continue
+		}
+		quoted := strings.TrimSpace(buf.String())
+		escapes = append(escapes, quoted[1:len(quoted)-1])
+	}
+	return escapes
+}
+
+// redact hides taskID's project env values in s.
+func (o *Orchestrator) redact(taskID, s string) string {
+	if r, ok := o.redactors.Load(taskID); ok {
+		return r.(*strings.Replacer).Replace(s)
+	}
+	return s
+}
+
+// redactEvent returns a copy of event with taskID's project env values
+// hidden in its strings. Values are matched before JSON encoding, which
+// would escape characters like & or " they may contain.
+func (o *Orchestrator) redactEvent(taskID string, event agent.StreamEvent) agent.StreamEvent {
+	if _, ok := o.redactors.Load(taskID); !ok {
+		return event
+	}
+	event.Delta = o.redact(taskID, event.Delta)
+	event.Error = o.redact(taskID, event.Error)
+	event.Notice = o.redact(taskID, event.Notice)
+	event.Path = o.redact(taskID, event.Path)
+	if event.Block != nil {
+		block := o.redactBlock(taskID, *event.Block)
+		event.Block = &block
+	}
+	if len(event.Todos) > 0 {
+		todos := make([]tools.TodoItem, len(event.Todos))
+		for i, todo := range event.Todos {
+			todo.Content = o.redact(taskID, todo.Content)
+			todo.ActiveForm = o.redact(taskID, todo.ActiveForm)
+			todos[i] = todo
+		}
+		event.Todos = todos
+	}
+	return event
+}
+
+// redactBlock returns a copy of block with taskID's project env values
+// hidden in its text, tool input and tool output.
+func (o *Orchestrator) redactBlock(taskID string, block agent.ContentBlock) agent.ContentBlock {
+	if _, ok := o.redactors.Load(taskID); !ok {
+		return block
+	}
+	block.Text = o.redact(taskID, block.Text)
+	block.Content = o.redact(taskID, block.Content)
+	if block.Input != nil {
+		block.Input = o.redactValue(taskID, block.Input).(map[string]any)
+	}
+	return block
+}
+
+// redactValue hides taskID's project env values in the strings of a decoded
+// JSON value, copying maps and slices rather than changing them.
+func (o *Orchestrator) redactValue(taskID string, v any) any {
+	switch v := v.(type) {
+	case string:
+		return o.redact(taskID, v)
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, item := range v {
+			out[k] = o.redactValue(taskID, item)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = o.redactValue(taskID, item)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// commitIdentity returns the configured author for agent commits on
+// projectID, signed when the project has commit signing enabled.
+func (o *Orchestrator) commitIdentity(ctx context.Context, projectID string) CommitIdentity {
+	identity := DefaultCommitIdentity
+	if o.settings != nil {
+		identity = o.settings.CommitIdentity(ctx)
+	}
+	if projectID != "" {
+		sign, err := o.repo.CommitSigningEnabled(ctx, projectID)
+		if err != nil {
+			slog.Warn("[ORCHESTRATOR] Failed to read commit signing setting", "project_id", projectID, "error", err)
+		}
+		identity.Sign = sign
+	}
+	return identity
+}
+
 func (o *Orchestrator) publishAgentStream(taskID string, event agent.StreamEvent) {
-	data, err := json.Marshal(event)
+	data, err := json.Marshal(o.redactEvent(taskID, event))
 	if err != nil {
 		slog.Warn("[ORCHESTRATOR] Failed to marshal agent event", "error", err)
 		return
@@ -612,6 +1405,79 @@ func (o *Orchestrator) publishAgentStream(taskID string, event agent.StreamEvent
 	})
 }
 
+// publishFileDiff sends the diff of a file the agent just edited, so the
+// diff view fills in while the run goes on. The diff saved when the run
+// ends supersedes these. Once the task's diff is past the inline limit
+// only a too_large marker is sent, without parsing the file's diff.
+func (o *Orchestrator) publishFileDiff(ctx context.Context, taskID, path string) {
+	logger := taskLogger(ctx, taskID)
+	rel, err := workspaceRelPath(o.repoManager.WorkspacePath(taskID), path)
+	if err != nil {
+		logger.Debug("[ORCHESTRATOR] Skipping diff of edited file", "path", path, "error", err)
+		return
+	}
+	diff, err := o.repoManager.FileDiff(ctx, taskID, rel)
+	if err != nil {
+		logger.Warn("[ORCHESTRATOR] Failed to diff edited file", "path", rel, "error", err)
+		return
+	}
+
+	update := map[string]any{"path": rel}
+	switch {
+	case o.liveDiffTooLarge(ctx, taskID, rel, diff):
+		update["too_large"] = true
+		update["max_lines"] = o.diffInlineMaxLines
+	case o.outputLimits.Diff > 0 && len(diff) > o.outputLimits.Diff:
+		update["truncated"] = true
+	default:
+		update["files"] = ParseDiff(o.redact(taskID, diff), DefaultDiffOptions)
+	}
+	data, err := json.Marshal(update)
+	if err != nil {
+		logger.Warn("[ORCHESTRATOR] Failed to marshal diff update", "error", err)
+		return
+	}
+	o.eventBus.Publish(models.Event{TaskID: taskID, Type: string(EventTypeDiffUpdate), Data: string(data)})
+}
+
+// liveDiffSize tracks the changed lines of a running task's diff per file:
+// the committed numstat at the first edit, updated by each edited file's
+// diff. Edits aren't committed until the run ends, so the numstat alone
+// would miss them.
+type liveDiffSize struct {
+	mu    sync.Mutex
+	files map[string]int // path -> added plus deleted lines
+}
+
+// liveDiffTooLarge records diff as rel's current diff and reports whether
+// the task's diff now changes more lines than the inline limit.
+func (o *Orchestrator) liveDiffTooLarge(ctx context.Context, taskID, rel, diff string) bool {
+	if o.diffInlineMaxLines <= 0 {
+		return false
+	}
+	v, loaded := o.liveDiffSizes.LoadOrStore(taskID, &liveDiffSize{files: map[string]int{}})
+	size := v.(*liveDiffSize)
+	size.mu.Lock()
+	defer size.mu.Unlock()
+	if !loaded {
+		stat, err := o.repoManager.DiffStat(ctx, taskID)
+		if err != nil {
+			taskLogger(ctx, taskID).Warn("[ORCHESTRATOR] Failed to get diff stat", "error", err)
+		} else if stat != nil {
+			for _, file := range stat.Files {
+				size.files[file.Path] = file.Additions + file.Deletions
+			}
+		}
+	}
+	size.files[rel] = CountDiffLines(diff)
+
+	total := 0
+	for _, n := range size.files {
+		total += n
+	}
+	return total > o.diffInlineMaxLines
+}
+
 func (o *Orchestrator) persistAgentMessage(ctx context.Context, taskID, runID string, msg *streamMessage) {
 	if msg == nil || len(msg.blocks) == 0 {
 		return
@@ -642,14 +1508,15 @@ func (o *Orchestrator) persistAgentMessage(ctx context.Context, taskID, runID st
 		}
 	}
 
-	partsJSON, err := json.Marshal(msg.blocks)
+	partsJSON, err := json.Marshal(o.capBlocks(taskID, msg.blocks))
 	if err != nil {
 		slog.Warn("[ORCHESTRATOR] Failed to marshal message parts", "error", err)
 		partsJSON = []byte("[]")
 	}
 
 	slog.Info("[ORCHESTRATOR] Saving message", "task_id", taskID, "run_id", runID, "role", role, "content_len", len(content))
-	if err := o.repo.CreateMessageWithParts(ctx, taskID, runID, role, content, string(partsJSON)); err != nil {
+	content, artifactPath := o.capOutput(taskID, "message-"+shortuuid.New()+".txt", o.redact(taskID, content), o.outputLimits.Message)
+	if err := o.repo.CreateMessageWithParts(ctx, taskID, runID, role, content, string(partsJSON), artifactPath); err != nil {
 		slog.Error("[ORCHESTRATOR] Failed to save message", "error", err)
 	}
 }
@@ -679,58 +1546,187 @@ func (o *Orchestrator) saveBackendSessionID(taskID, sessionID string) {
 	}
 }
 
-// processResults processes task results from the worker pool.
-func (o *Orchestrator) processResults() {
-	for result := range o.resultCh {
-		// Update task status based on result
-		ctx := context.Background()
+// Activity log levels.
+const (
+	LogLevelInfo    = "info"
+	LogLevelSuccess = "success"
+	LogLevelWarn    = "warn"
+	LogLevelError   = "error"
+)
 
-		if result.Success {
-			if err := o.repo.UpdateStatus(ctx, result.TaskID, "review"); err != nil {
-				slog.Error("[ORCHESTRATOR] Failed to update task status", "error", err)
-			}
-			// Update agent run as completed
-			if run, err := o.repo.GetLatestAgentRun(ctx, result.TaskID); err == nil && run != nil {
-				if err := o.repo.UpdateAgentRunCompleted(ctx, run.ID); err != nil {
-					slog.Error("[ORCHESTRATOR] Failed to update agent run completed", "error", err)
-				}
-			}
+// logTask persists an activity log entry for a task and pushes it to live subscribers.
+func (o *Orchestrator) logTask(taskID, level, message string) {
+	entry, err := o.repo.AppendLog(context.Background(), taskID, level, message)
+	if err != nil {
+		slog.Error("[ORCHESTRATOR] Failed to persist task log", "task_id", taskID, "error", err)
+		return
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		slog.Warn("[ORCHESTRATOR] Failed to marshal task log", "error", err)
+		return
+	}
+	o.eventBus.Publish(models.Event{TaskID: taskID, Type: string(EventTypeLog), Data: string(data)})
+}
+
+// processResult records the outcome of a run from the worker pool.
+func (o *Orchestrator) processResult(result TaskResult) {
+	// Update task status based on result
+	ctx := context.Background()
+
+	status := "failed"
+	if result.Success {
+		status = "review"
+		// Before the status change, so the UI's reload on it sees the
+		// flag. A stale result's flag is overwritten by the newer run.
+		if err := o.repo.UpdateTaskNoChanges(ctx, result.TaskID, result.NoChanges); err != nil {
+			slog.Error("[ORCHESTRATOR] Failed to record no-op run", "task_id", result.TaskID, "error", err)
+		}
+	}
+	if err := o.setResultStatus(ctx, result, status); err != nil {
+		var conflict ErrStatusConflict
+		var invalid ErrInvalidTransition
+		if errors.As(err, &conflict) || errors.As(err, &invalid) {
+			slog.Warn("[ORCHESTRATOR] Ignoring stale result", "task_id", result.TaskID, "version", result.Version, "success", result.Success, "error", err)
+			return
+		}
+		slog.Error("[ORCHESTRATOR] Failed to update task status", "error", err)
+	}
+
+	if result.Success {
+		if result.NoChanges {
+			o.logTask(result.TaskID, LogLevelWarn, "Agent made no changes")
 		} else {
-			if err := o.repo.UpdateStatus(ctx, result.TaskID, "failed"); err != nil {
-				slog.Error("[ORCHESTRATOR] Failed to update task status", "error", err)
+			o.logTask(result.TaskID, LogLevelSuccess, "Ready for review")
+		}
+		// Update agent run as completed
+		if run, err := o.repo.GetLatestAgentRun(ctx, result.TaskID); err == nil && run != nil {
+			if err := o.repo.UpdateAgentRunCompleted(ctx, run.ID); err != nil {
+				slog.Error("[ORCHESTRATOR] Failed to update agent run completed", "error", err)
 			}
 		}
+	} else {
+		o.logTask(result.TaskID, LogLevelError, "Task failed: "+result.Error)
+	}
+
+	slog.Info("[ORCHESTRATOR] Result processed", "task_id", result.TaskID, "success", result.Success)
+}
+
+// setResultStatus records a run's outcome. Results carrying a version only
+// apply if nothing else (a merge, a newer run) has touched the task since the
+// run started.
+func (o *Orchestrator) setResultStatus(ctx context.Context, result TaskResult, status string) error {
+	if result.Version == 0 {
+		_, err := o.repo.UpdateStatus(ctx, result.TaskID, status)
+		return err
+	}
+	_, err := o.repo.UpdateStatusIfVersion(ctx, result.TaskID, status, result.Version)
+	return err
+}
+
+// MergeTaskFiles merges only the task's changes to files, discarding the
+// rest from the task branch first. It returns the files that were dropped
+// and, like MergeTask, the URL of the PR opened for a protected branch.
+// The drop is committed on the task branch, so it stays in effect if the
+// merge itself then fails on a conflict.
+func (o *Orchestrator) MergeTaskFiles(ctx context.Context, taskID string, files []string) ([]string, string, error) {
+	if len(files) == 0 {
+		return nil, "", fmt.Errorf("no files approved for merge")
+	}
+	task, err := o.repo.Get(ctx, taskID)
+	if err != nil {
+		return nil, "", fmt.Errorf("task not found: %w", err)
+	}
+	if !CanTransition(task.Status, "done") {
+		return nil, "", ErrInvalidTransition{TaskID: taskID, From: task.Status, To: "done"}
+	}
+	// A queued continuation leaves the task in review until it starts
+	if o.isActive(taskID) {
+		return nil, "", ErrTaskRunning{TaskID: taskID}
+	}
 
-		slog.Info("[ORCHESTRATOR] Result processed", "task_id", result.TaskID, "success", result.Success)
+	excluded, err := o.repoManager.KeepOnlyFiles(ctx, taskID, files, o.commitIdentity(ctx, projectIDOf(task)))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to drop unapproved files: %w", err)
 	}
+	if len(excluded) > 0 {
+		o.logTask(taskID, LogLevelInfo, fmt.Sprintf("Excluded from merge: %s", strings.Join(excluded, ", ")))
+	}
+	prURL, err := o.MergeTask(ctx, taskID)
+	return excluded, prURL, err
 }
 
-// MergeTask merges task branch to main and pushes.
-func (o *Orchestrator) MergeTask(ctx context.Context, taskID string) error {
+// MergeTask merges task branch to main and pushes. When the target branch
+// is protected on GitHub, where a direct push would be rejected, it opens a
+// pull request instead and returns its URL; after a direct merge the URL is
+// empty. It fails with ErrTaskRunning while the task has a run queued or
+// executing, which would still edit the workspace.
+func (o *Orchestrator) MergeTask(ctx context.Context, taskID string) (string, error) {
 	// Get task info
-	if _, err := o.repo.Get(ctx, taskID); err != nil {
-		return fmt.Errorf("task not found: %w", err)
+	task, err := o.repo.Get(ctx, taskID)
+	if err != nil {
+		return "", fmt.Errorf("task not found: %w", err)
+	}
+	if !CanTransition(task.Status, "done") {
+		return "", ErrInvalidTransition{TaskID: taskID, From: task.Status, To: "done"}
+	}
+	if o.isActive(taskID) {
+		return "", ErrTaskRunning{TaskID: taskID}
+	}
+	target := "main"
+	if base := baseBranchOf(task); base != "" {
+		target = base
+	}
+
+	if o.branchProtected(ctx, task, target) {
+		prURL, err := o.CreatePR(ctx, taskID)
+		if err != nil {
+			return "", fmt.Errorf("%s is protected and opening a PR failed: %w", target, err)
+		}
+		o.logTask(taskID, LogLevelInfo, target+" is protected — opened a PR instead")
+		return prURL, nil
 	}
 
 	// Merge to main
-	_, err := o.repoManager.MergeToMain(ctx, taskID)
+	_, err = o.repoManager.MergeToMain(ctx, taskID, baseBranchOf(task))
+	o.fileLists.Invalidate(o.repoManager.RootPath()) // merging pulls and fetches the base checkout
 	if err != nil {
 		// Check for merge conflict
 		if _, isConflict := err.(*ErrMergeConflict); isConflict {
-			return err
+			return "", err
 		}
-		return fmt.Errorf("failed to merge: %w", err)
+		return "", fmt.Errorf("failed to merge: %w", err)
 	}
 
 	// Update task status to done
-	if err := o.repo.UpdateStatus(ctx, taskID, "done"); err != nil {
-		return fmt.Errorf("failed to update status: %w", err)
+	if _, err := o.repo.UpdateStatus(ctx, taskID, "done"); err != nil {
+		return "", fmt.Errorf("failed to update status: %w", err)
 	}
+	o.logTask(taskID, LogLevelSuccess, "Merged into "+target)
 
 	// Publish task_updated event
 	o.eventBus.Publish(models.Event{TaskID: taskID, Type: string(EventTypeTaskUpdated), Data: ""})
 
-	return nil
+	return "", nil
+}
+
+// branchProtected reports whether branch of task's GitHub repository is
+// protected. Projects not on GitHub and failed lookups count as unprotected:
+// the direct merge then goes ahead as before.
+func (o *Orchestrator) branchProtected(ctx context.Context, task *models.Task, branch string) bool {
+	if o.github == nil || task.RepositoryID == nil {
+		return false
+	}
+	owner, repoName, err := o.taskGitHubRepo(ctx, task)
+	if err != nil {
+		return false
+	}
+	protected, err := o.github.BranchProtected(ctx, owner, repoName, branch)
+	if err != nil {
+		taskLogger(ctx, task.ID).Warn("[ORCHESTRATOR] Failed to check branch protection", "branch", branch, "error", err)
+		return false
+	}
+	return protected
 }
 
 // GetConflictDetails returns conflict details for a task.
@@ -746,6 +1742,9 @@ func (o *Orchestrator) GetConflictDetails(ctx context.Context, taskID string) ([
 			return nil // skip errors
 		}
 		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
 			return nil // skip directories
 		}
 
@@ -791,22 +1790,28 @@ func (o *Orchestrator) ResolveConflict(ctx context.Context, taskID, filePath, re
 // CompleteMergeResolution completes merge conflict resolution and merges to main.
 func (o *Orchestrator) CompleteMergeResolution(ctx context.Context, taskID string) error {
 	// Get task info
-	if _, err := o.repo.Get(ctx, taskID); err != nil {
+	task, err := o.repo.Get(ctx, taskID)
+	if err != nil {
 		return fmt.Errorf("task not found: %w", err)
 	}
+	if !CanTransition(task.Status, "done") {
+		return ErrInvalidTransition{TaskID: taskID, From: task.Status, To: "done"}
+	}
 
 	// Commit resolution
-	if err := o.repoManager.CommitMergeResolution(ctx, taskID, "Resolved merge conflicts"); err != nil {
+	if err := o.repoManager.CommitMergeResolution(ctx, taskID, "Resolved merge conflicts", o.commitIdentity(ctx, projectIDOf(task))); err != nil {
 		return fmt.Errorf("failed to commit resolution: %w", err)
 	}
 
 	// Merge to main
-	if _, err := o.repoManager.MergeToMain(ctx, taskID); err != nil {
+	_, err = o.repoManager.MergeToMain(ctx, taskID, baseBranchOf(task))
+	o.fileLists.Invalidate(o.repoManager.RootPath())
+	if err != nil {
 		return fmt.Errorf("failed to merge: %w", err)
 	}
 
 	// Update task status to done
-	if err := o.repo.UpdateStatus(ctx, taskID, "done"); err != nil {
+	if _, err := o.repo.UpdateStatus(ctx, taskID, "done"); err != nil {
 		return fmt.Errorf("failed to update status: %w", err)
 	}
 
@@ -828,24 +1833,17 @@ func (o *Orchestrator) CreatePR(ctx context.Context, taskID string) (string, err
 	if err != nil {
 		return "", fmt.Errorf("task not found: %w", err)
 	}
-
-	// Get project info
-	repos, err := o.github.GetRepos(ctx)
-	if err != nil {
-		return "", fmt.Errorf("failed to get projects: %w", err)
+	if !CanTransition(task.Status, "done") {
+		return "", ErrInvalidTransition{TaskID: taskID, From: task.Status, To: "done"}
 	}
-
-	var owner, repoName string
-	for _, p := range repos {
-		if p.ID == *task.RepositoryID {
-			owner = p.Owner
-			repoName = p.Name
-			break
-		}
+	if o.isActive(taskID) {
+		return "", ErrTaskRunning{TaskID: taskID}
 	}
 
-	if owner == "" {
-		return "", fmt.Errorf("project not found for task")
+	// Get project info
+	owner, repoName, err := o.taskGitHubRepo(ctx, task)
+	if err != nil {
+		return "", err
 	}
 
 	// Get branch name from workspace
@@ -861,17 +1859,21 @@ func (o *Orchestrator) CreatePR(ctx context.Context, taskID string) (string, err
 	}
 
 	// Create PR
-	prURL, err := o.github.CreatePullRequest(ctx, owner, repoName, branchName, task.Title, task.Intent)
+	prURL, err := o.github.CreatePullRequest(ctx, owner, repoName, branchName, baseBranchOf(task), task.Title, task.Intent)
 	if err != nil {
 		return "", fmt.Errorf("failed to create PR: %w", err)
 	}
 
 	slog.Info("[ORCHESTRATOR] Created PR", "task_id", taskID, "pr_url", prURL)
+	if err := o.repo.UpdateTaskPRURL(ctx, taskID, prURL); err != nil {
+		slog.Warn("[ORCHESTRATOR] Failed to record PR URL", "task_id", taskID, "error", err)
+	}
 
 	// Update task status to done
-	if err := o.repo.UpdateStatus(ctx, taskID, "done"); err != nil {
+	if _, err := o.repo.UpdateStatus(ctx, taskID, "done"); err != nil {
 		return prURL, fmt.Errorf("PR created but failed to update task status: %w", err)
 	}
+	o.logTask(taskID, LogLevelSuccess, "Pull request opened: "+prURL)
 
 	// Emit status change event
 	o.eventBus.Publish(models.Event{TaskID: taskID, Type: string(EventTypeTaskUpdated), Data: ""})
@@ -879,6 +1881,75 @@ func (o *Orchestrator) CreatePR(ctx context.Context, taskID string) (string, err
 	return prURL, nil
 }
 
+// taskGitHubRepo returns the owner and name of the GitHub repository task
+// belongs to.
+func (o *Orchestrator) taskGitHubRepo(ctx context.Context, task *models.Task) (string, string, error) {
+	if task.RepositoryID == nil {
+		return "", "", fmt.Errorf("project not found for task")
+	}
+	repos, err := o.github.GetRepos(ctx)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get projects: %w", err)
+	}
+	for _, p := range repos {
+		if p.ID == *task.RepositoryID {
+			return p.Owner, p.Name, nil
+		}
+	}
+	return "", "", fmt.Errorf("project not found for task")
+}
+
+// taskBaseBranch returns the base branch a task's workspace starts from, or
+// "" for the default branch.
+func (o *Orchestrator) taskBaseBranch(ctx context.Context, taskID string) string {
+	task, err := o.repo.Get(ctx, taskID)
+	if err != nil {
+		return ""
+	}
+	return baseBranchOf(task)
+}
+
+// baseBranchOf returns task's base branch, or "" for the default branch.
+func baseBranchOf(task *models.Task) string {
+	if task.BaseBranch == nil {
+		return ""
+	}
+	return *task.BaseBranch
+}
+
+// projectIDOf returns the ID of task's project, or "" when it has none.
+func projectIDOf(task *models.Task) string {
+	if task.RepositoryID == nil {
+		return ""
+	}
+	return *task.RepositoryID
+}
+
+// validateBranchName rejects base branch names git would refuse or could
+// take for an option.
+func validateBranchName(name string) error {
+	if strings.HasPrefix(name, "-") || strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/") ||
+		strings.HasSuffix(name, ".lock") || strings.Contains(name, "..") || strings.Contains(name, "@{") ||
+		strings.ContainsAny(name, " ~^:?*[\\\x7f") {
+		return ErrInvalidBaseBranch{Branch: name, Reason: "not a valid branch name"}
+	}
+	for _, r := range name {
+		if r < 0x20 {
+			return ErrInvalidBaseBranch{Branch: name, Reason: "not a valid branch name"}
+		}
+	}
+	return nil
+}
+
+// isActive reports whether a task has a run queued or executing.
+func (o *Orchestrator) isActive(taskID string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	_, running := o.running[taskID]
+	_, queued := o.queued[taskID]
+	return running || queued
+}
+
 // CancelTask cancels a running task.
 func (o *Orchestrator) CancelTask(taskID string) {
 	o.mu.Lock()
@@ -890,144 +1961,277 @@ func (o *Orchestrator) CancelTask(taskID string) {
 	}
 }
 
-// CleanupTask removes the workspace for a task.
-func (o *Orchestrator) CleanupTask(ctx context.Context, taskID string) error {
-	return o.repoManager.RemoveWorkspace(ctx, taskID)
+// KillTask cancels the run executing taskID, like CancelTask, and records
+// who stopped it in the task log. Returns ErrTaskNotRunning when the task
+// has no run executing.
+func (o *Orchestrator) KillTask(taskID string) error {
+	o.mu.Lock()
+	cancel, ok := o.running[taskID]
+	o.mu.Unlock()
+	if !ok {
+		return ErrTaskNotRunning{TaskID: taskID}
+	}
+	o.logTask(taskID, LogLevelWarn, "Run killed by the operator")
+	cancel()
+	return nil
 }
 
-// SearchProjectFiles searches for files in a project using fuzzy matching.
-// Returns a list of file paths relative to the repo root, sorted by match score.
-func (o *Orchestrator) SearchProjectFiles(ctx context.Context, projectID, query string, limit int) ([]string, error) {
-	if limit <= 0 {
-		limit = 20
-	}
+// RunningTask is a task with a run queued or executing on this server.
+type RunningTask struct {
+	TaskID    string
+	Queued    bool      // waiting for a worker
+	StartedAt time.Time // zero while queued
+	Backend   string    // backend type, empty until the backend is created
+	ModelID   string
+}
 
-	repoPath := o.repoManager.RootPath()
-	if repoPath == "" {
-		return nil, fmt.Errorf("repository root not found")
-	}
-	if _, err := os.Stat(repoPath); err != nil {
-		return nil, fmt.Errorf("repository root not found: %w", err)
-	}
+// RunningTasks lists the tasks with a run executing, longest running first,
+// followed by the queued ones.
+func (o *Orchestrator) RunningTasks() []RunningTask {
+	o.mu.Lock()
+	defer o.mu.Unlock()
 
-	// Collect all file paths
-	var files []string
-	err := filepath.Walk(repoPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil // skip errors
+	tasks := make([]RunningTask, 0, len(o.live)+len(o.queued))
+	for taskID, run := range o.live {
+		task := RunningTask{TaskID: taskID, StartedAt: run.startedAt, ModelID: run.modelID}
+		if run.backend != nil {
+			task.Backend = string(run.backend.Info().Type)
 		}
-		// Skip hidden directories and common non-source dirs
-		name := info.Name()
-		if info.IsDir() {
-			if strings.HasPrefix(name, ".") || name == "node_modules" || name == "vendor" || name == "__pycache__" || name == "dist" || name == "build" {
-				return filepath.SkipDir
-			}
-			return nil
+		tasks = append(tasks, task)
+	}
+	for taskID := range o.queued {
+		tasks = append(tasks, RunningTask{TaskID: taskID, Queued: true})
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		a, b := tasks[i], tasks[j]
+		if a.Queued != b.Queued {
+			return !a.Queued
 		}
-		// Skip hidden files
-		if strings.HasPrefix(name, ".") {
-			return nil
+		if !a.StartedAt.Equal(b.StartedAt) {
+			return a.StartedAt.Before(b.StartedAt)
 		}
-		// Get relative path from repo root
-		relPath, _ := filepath.Rel(repoPath, path)
-		files = append(files, relPath)
-		return nil
+		return a.TaskID < b.TaskID
 	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to walk repo: %w", err)
+	return tasks
+}
+
+// liveRun is what InterruptTask needs to reach an executing task.
+type liveRun struct {
+	backend   agent.Backend // nil until the backend is created
+	modelID   string
+	startedAt time.Time
+}
+
+// setLiveBackend records the backend now running taskID.
+func (o *Orchestrator) setLiveBackend(taskID string, backend agent.Backend) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if run, ok := o.live[taskID]; ok {
+		run.backend = backend
+		o.live[taskID] = run
+	}
+}
+
+// interruptStopTimeout bounds how long InterruptTask waits for a cancelled
+// run's result to be processed before continuing the task.
+const interruptStopTimeout = 30 * time.Second
+
+// InterruptTask sends message to the agent running taskID. Backends that
+// implement agent.Steerable get it mid-run, before their next model call,
+// and injected is true. Others are stopped and the task continued with the
+// message as a follow-up, on the same model. Returns ErrTaskNotRunning when
+// the task has no run executing.
+func (o *Orchestrator) InterruptTask(ctx context.Context, taskID, message string) (injected bool, err error) {
+	if strings.TrimSpace(message) == "" {
+		return false, fmt.Errorf("message cannot be empty")
+	}
+
+	o.mu.Lock()
+	run, ok := o.live[taskID]
+	cancel := o.running[taskID]
+	done := o.runDone[taskID]
+	o.mu.Unlock()
+	if !ok {
+		return false, ErrTaskNotRunning{TaskID: taskID}
 	}
 
-	// If no query, return first N files sorted alphabetically
-	if query == "" {
-		if len(files) > limit {
-			files = files[:limit]
+	if steerable, ok := run.backend.(agent.Steerable); ok {
+		if err := steerable.Steer(message); err == nil {
+			o.logTask(taskID, LogLevelInfo, "Message sent to the running agent")
+			return true, nil
+		} else if !errors.Is(err, agent.ErrNotRunning) {
+			return false, err
 		}
-		return files, nil
+		// The run is wrapping up; its follow-up has to wait for it
 	}
 
-	// Fuzzy search files
-	matches := fuzzySearch(files, query, limit)
-	return matches, nil
+	o.logTask(taskID, LogLevelInfo, "Stopping the agent to apply the new message")
+	cancel()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+	// The cancelled run's result is processed asynchronously; the task
+	// can only be continued once it has left in_progress
+	deadline := time.Now().Add(interruptStopTimeout)
+	for {
+		task, err := o.repo.Get(ctx, taskID)
+		if err != nil {
+			return false, fmt.Errorf("task not found: %w", err)
+		}
+		if task.Status != "in_progress" {
+			break
+		}
+		if time.Now().After(deadline) {
+			return false, fmt.Errorf("timed out waiting for task %s to stop", taskID)
+		}
+		select {
+		case <-time.After(50 * time.Millisecond):
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+	}
+	return false, o.ContinueTask(ctx, taskID, message, run.modelID)
 }
 
-// fuzzySearch performs fuzzy matching on file paths and returns top N matches.
-func fuzzySearch(files []string, query string, limit int) []string {
-	type scored struct {
-		path  string
-		score int
-	}
+// workspaceSweepInterval is how often the background workspace sweep runs.
+const workspaceSweepInterval = time.Hour
 
-	var results []scored
-	queryLower := strings.ToLower(query)
+// workspaceSweepLoop runs CleanupWorkspaces until the orchestrator shuts down.
+func (o *Orchestrator) workspaceSweepLoop() {
+	ticker := time.NewTicker(workspaceSweepInterval)
+	defer ticker.Stop()
 
-	for _, f := range files {
-		fLower := strings.ToLower(f)
-		score := fuzzyScore(fLower, queryLower)
-		if score > 0 {
-			results = append(results, scored{path: f, score: score})
+	for {
+		select {
+		case <-o.stopCh:
+			return
+		case <-ticker.C:
+			if _, err := o.CleanupWorkspaces(context.Background(), o.workspaceRetention); err != nil {
+				slog.Error("[ORCHESTRATOR] Workspace sweep failed", "error", err)
+			}
 		}
 	}
+}
+
+// CleanupWorkspaces removes the workspaces of review/done/failed tasks whose
+// last run finished more than olderThan ago and whose diff is saved in the DB,
+// along with their raw logs and full outputs. Running tasks are always
+// skipped. Returns the IDs of tasks whose workspace was removed.
+func (o *Orchestrator) CleanupWorkspaces(ctx context.Context, olderThan time.Duration) ([]string, error) {
+	taskIDs, err := o.repo.ListStaleWorkspaceTasks(ctx, time.Now().Add(-olderThan))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stale workspaces: %w", err)
+	}
 
-	// Sort by score descending
-	for i := 0; i < len(results)-1; i++ {
-		for j := i + 1; j < len(results); j++ {
-			if results[j].score > results[i].score {
-				results[i], results[j] = results[j], results[i]
-			}
+	var removed []string
+	for _, taskID := range taskIDs {
+		if o.isActive(taskID) {
+			continue
 		}
+		// Files outlive the workspace when it was discarded earlier
+		o.removeTaskFiles(taskID)
+		if _, err := os.Stat(o.repoManager.WorkspacePath(taskID)); err != nil {
+			continue // already gone
+		}
+		if err := o.repoManager.RemoveWorkspace(ctx, taskID); err != nil {
+			slog.Warn("[ORCHESTRATOR] Failed to remove stale workspace", "task_id", taskID, "error", err)
+			continue
+		}
+		removed = append(removed, taskID)
 	}
 
-	// Return top N
-	var out []string
-	for i := 0; i < len(results) && i < limit; i++ {
-		out = append(out, results[i].path)
+	if len(removed) > 0 {
+		slog.Info("[ORCHESTRATOR] Removed stale workspaces", "count", len(removed), "older_than", olderThan)
 	}
-	return out
+	return removed, nil
 }
 
-// fuzzyScore computes a simple fuzzy match score.
-// Higher score = better match. 0 = no match.
-func fuzzyScore(text, pattern string) int {
-	if len(pattern) == 0 {
-		return 1
+// MoveTaskRepository reassigns a task to projectID. Only tasks that haven't
+// run yet (or were reset to pending) can move, and never ones with
+// committed work, whose branch would be orphaned. Any existing workspace is
+// removed since it was created from the old repository.
+func (o *Orchestrator) MoveTaskRepository(ctx context.Context, taskID, projectID string) error {
+	task, err := o.repo.Get(ctx, taskID)
+	if err != nil {
+		return fmt.Errorf("task not found: %w", err)
 	}
-	if len(text) == 0 {
-		return 0
+	if o.isActive(taskID) {
+		return ErrTaskRunning{TaskID: taskID}
+	}
+	if task.Status != "pending" && task.Status != "planning" {
+		return ErrTaskNotMovable{TaskID: taskID, Reason: "task is " + task.Status}
+	}
+	if task.RepositoryID != nil && *task.RepositoryID == projectID {
+		return nil
+	}
+	if _, err := o.repo.GetRepository(ctx, projectID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("project %s: %w", projectID, os.ErrNotExist)
+		}
+		return fmt.Errorf("failed to get project: %w", err)
 	}
 
-	score := 0
-	patternIdx := 0
-	prevMatchIdx := -1
-	consecutiveBonus := 0
-
-	for i := 0; i < len(text) && patternIdx < len(pattern); i++ {
-		if text[i] == pattern[patternIdx] {
-			score += 1
+	savedDiff, err := o.repo.GetSavedDiff(ctx, taskID)
+	if err != nil {
+		return fmt.Errorf("failed to check for committed work: %w", err)
+	}
+	diff, err := o.repoManager.GetDiff(ctx, taskID)
+	if err != nil {
+		return fmt.Errorf("failed to check for committed work: %w", err)
+	}
+	if strings.TrimSpace(savedDiff) != "" || strings.TrimSpace(diff) != "" {
+		return ErrTaskNotMovable{TaskID: taskID, Reason: "it has committed work"}
+	}
 
-			// Bonus for consecutive matches
-			if prevMatchIdx == i-1 {
-				consecutiveBonus++
-				score += consecutiveBonus
-			} else {
-				consecutiveBonus = 0
-			}
+	if err := o.repoManager.RemoveWorkspace(ctx, taskID); err != nil {
+		return fmt.Errorf("failed to remove workspace: %w", err)
+	}
+	if err := o.repo.UpdateTaskRepository(ctx, taskID, projectID); err != nil {
+		return fmt.Errorf("failed to move task: %w", err)
+	}
+	o.logTask(taskID, LogLevelInfo, "Moved to project "+projectID)
+	o.eventBus.Publish(models.Event{TaskID: taskID, Type: string(EventTypeTaskUpdated), Data: ""})
+	return nil
+}
 
-			// Bonus for matching at start or after separator
-			if i == 0 || text[i-1] == '/' || text[i-1] == '_' || text[i-1] == '-' || text[i-1] == '.' {
-				score += 2
-			}
+// CleanupTask removes the workspace for a task.
+func (o *Orchestrator) CleanupTask(ctx context.Context, taskID string) error {
+	return o.repoManager.RemoveWorkspace(ctx, taskID)
+}
 
-			prevMatchIdx = i
-			patternIdx++
-		}
+// DeleteTask removes a task with its workspace, raw logs and full outputs.
+// A queued or running task must finish or be killed first.
+func (o *Orchestrator) DeleteTask(ctx context.Context, taskID string) error {
+	if _, err := o.repo.Get(ctx, taskID); err != nil {
+		return fmt.Errorf("task not found: %w", err)
 	}
-
-	// All pattern characters must match
-	if patternIdx < len(pattern) {
-		return 0
+	if o.isActive(taskID) {
+		return ErrTaskRunning{TaskID: taskID}
+	}
+	if err := o.repoManager.RemoveWorkspace(ctx, taskID); err != nil {
+		return fmt.Errorf("failed to remove workspace: %w", err)
 	}
+	o.removeTaskFiles(taskID)
+	if err := o.repo.Delete(ctx, taskID); err != nil {
+		return fmt.Errorf("failed to delete task: %w", err)
+	}
+	o.eventBus.Publish(models.Event{TaskID: taskID, Type: string(EventTypeTaskUpdated), Data: ""})
+	return nil
+}
 
-	return score
+// removeTaskFiles deletes the raw logs and full outputs kept on disk for a
+// task. Failures are logged; the files are only diagnostics.
+func (o *Orchestrator) removeTaskFiles(taskID string) {
+	for _, dir := range []string{o.rawLogDir, o.outputDir} {
+		if dir == "" {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(dir, taskID)); err != nil {
+			slog.Warn("[ORCHESTRATOR] Failed to remove task files", "task_id", taskID, "dir", dir, "error", err)
+		}
+	}
 }
 
 // parseConflictFile parses a file with git conflict markers.
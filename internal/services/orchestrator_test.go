@@ -3,10 +3,19 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/revrost/counterspell/internal/agent"
 	"github.com/revrost/counterspell/internal/db/sqlc"
+	"github.com/revrost/counterspell/internal/llm"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -132,6 +141,130 @@ func TestConsumeAgentStream_PersistsMessages(t *testing.T) {
 	assert.Equal(t, "tool", toolResultMsg.Role)
 }
 
+func TestConsumeAgentStream_RedactsProjectEnv(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	orch, err := NewOrchestrator(NewRepository(testDB), NewEventBus(), nil, nil, stubRepoManager{})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	task, err := orch.repo.Create(ctx, "", "run tests")
+	require.NoError(t, err)
+	runID, err := orch.repo.CreateAgentRun(ctx, task.ID, "run tests", "native", "anthropic", "claude-3")
+	require.NoError(t, err)
+
+	orch.setRedactor(task.ID, []string{"CI=true", "DATABASE_URL=postgres://secret@db"})
+	defer orch.redactors.Delete(task.ID)
+
+	events := make(chan agent.StreamEvent, 8)
+	events <- agent.StreamEvent{Type: agent.EventMessageStart, MessageID: "msg-1", Role: "assistant"}
+	events <- agent.StreamEvent{Type: agent.EventContentStart, MessageID: "msg-1", BlockType: "text"}
+	events <- agent.StreamEvent{Type: agent.EventContentDelta, MessageID: "msg-1", BlockType: "text", Delta: "CI is true, db at postgres://secret@db"}
+	events <- agent.StreamEvent{Type: agent.EventContentEnd, MessageID: "msg-1", BlockType: "text"}
+	events <- agent.StreamEvent{Type: agent.EventMessageEnd, MessageID: "msg-1", Role: "assistant"}
+	close(events)
+
+	require.NoError(t, orch.consumeAgentStream(ctx, task.ID, runID, &agent.Stream{Events: events}))
+
+	messages, err := orch.repo.GetMessagesByTask(ctx, task.ID)
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	assert.Equal(t, "CI is true, db at [REDACTED]", messages[0].Content)
+	assert.NotContains(t, messages[0].Parts, "secret")
+}
+
+func TestConsumeAgentStream_RedactsSecretsJSONWouldEscape(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	orch, err := NewOrchestrator(NewRepository(testDB), NewEventBus(), nil, nil, stubRepoManager{})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	task, err := orch.repo.Create(ctx, "", "run tests")
+	require.NoError(t, err)
+	runID, err := orch.repo.CreateAgentRun(ctx, task.ID, "run tests", "native", "anthropic", "claude-3")
+	require.NoError(t, err)
+
+	secret := `postgres://app:pa"ss@db/app?sslmode=require&tz=<utc>`
+	orch.setRedactor(task.ID, []string{"DATABASE_URL=" + secret})
+	defer orch.redactors.Delete(task.ID)
+
+	args, err := json.Marshal(map[string]any{"command": "psql " + secret})
+	require.NoError(t, err)
+	events := make(chan agent.StreamEvent, 16)
+	events <- agent.StreamEvent{Type: agent.EventMessageStart, MessageID: "msg-1", Role: "assistant"}
+	events <- agent.StreamEvent{Type: agent.EventContentStart, MessageID: "msg-1", BlockType: "text"}
+	events <- agent.StreamEvent{Type: agent.EventContentDelta, MessageID: "msg-1", BlockType: "text", Delta: "db at " + secret}
+	events <- agent.StreamEvent{Type: agent.EventContentEnd, MessageID: "msg-1", BlockType: "text"}
+	events <- agent.StreamEvent{Type: agent.EventContentStart, MessageID: "msg-1", BlockType: "tool_use",
+		Block: &agent.ContentBlock{Type: "tool_use", Name: "bash", ID: "call-1"}}
+	events <- agent.StreamEvent{Type: agent.EventContentDelta, MessageID: "msg-1", BlockType: "tool_use", Delta: string(args)}
+	events <- agent.StreamEvent{Type: agent.EventContentEnd, MessageID: "msg-1", BlockType: "tool_use"}
+	events <- agent.StreamEvent{Type: agent.EventMessageEnd, MessageID: "msg-1", Role: "assistant"}
+	close(events)
+
+	require.NoError(t, orch.consumeAgentStream(ctx, task.ID, runID, &agent.Stream{Events: events}))
+
+	// No message limit is set, so parts are stored whole but still redacted
+	messages, err := orch.repo.GetMessagesByTask(ctx, task.ID)
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	assert.Equal(t, "db at [REDACTED]", messages[0].Content)
+	assert.Contains(t, messages[0].Parts, "psql [REDACTED]")
+	assert.NotContains(t, messages[0].Parts, "sslmode")
+
+	published := orch.eventBus.TaskEvents(task.ID)
+	require.NotEmpty(t, published)
+	for _, event := range published {
+		assert.NotContains(t, event.Data, "sslmode")
+	}
+}
+
+func TestRawLog_WriteAndLookup(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	orch, err := NewOrchestrator(NewRepository(testDB), NewEventBus(), nil, nil, stubRepoManager{},
+		WithRawLogDir(t.TempDir(), 0))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	task, err := orch.repo.Create(ctx, "", "run tests")
+	require.NoError(t, err)
+	other, err := orch.repo.Create(ctx, "", "something else")
+	require.NoError(t, err)
+	runID, err := orch.repo.CreateAgentRun(ctx, task.ID, "run tests", "codex", "openai", "gpt-5")
+	require.NoError(t, err)
+
+	orch.setRedactor(task.ID, []string{"API_TOKEN=tok-0123456789"})
+	defer orch.redactors.Delete(task.ID)
+
+	rawLog, closeRawLog := orch.openRawLog(task.ID, runID)
+	require.NotNil(t, rawLog)
+	rawLog.Line("stdout", `{"type":"turn.started"}`)
+	rawLog.Line("stderr", "auth with tok-0123456789 failed")
+	closeRawLog()
+
+	path, err := orch.RawLogPath(ctx, task.ID, "")
+	require.NoError(t, err)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), ` stdout | {"type":"turn.started"}`)
+	assert.Contains(t, string(data), " stderr | auth with [REDACTED] failed")
+	assert.NotContains(t, string(data), "tok-0123456789")
+
+	explicit, err := orch.RawLogPath(ctx, task.ID, runID)
+	require.NoError(t, err)
+	assert.Equal(t, path, explicit)
+
+	_, err = orch.RawLogPath(ctx, other.ID, runID)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	_, err = orch.RawLogPath(ctx, other.ID, "")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
 func TestContinueTask_Validation(t *testing.T) {
 	testDB := setupTestDB(t)
 	defer testDB.Close()
@@ -155,6 +288,88 @@ func TestContinueTask_Validation(t *testing.T) {
 	err = orch.ContinueTask(ctx, "non-existent", "hi", "model-1")
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "task not found")
+
+	// Test unknown provider
+	var invalid llm.ErrInvalidModelID
+	err = orch.ContinueTask(ctx, "task-1", "hi", "anthropics#claude")
+	assert.ErrorAs(t, err, &invalid)
+}
+
+func TestStartTask_RejectsUnknownProvider(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	orch, err := NewOrchestrator(NewRepository(testDB), NewEventBus(), nil, nil, stubRepoManager{})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	_, err = orch.StartTask(ctx, "proj-1", "fix the bug", "anthropics#claude")
+	assert.EqualError(t, err, `invalid model ID "anthropics#claude": unknown provider 'anthropics'`)
+
+	// Nothing was created
+	tasks, err := orch.repo.List(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, tasks)
+}
+
+func TestRunGuard_RefusesSecondRun(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	orch, err := NewOrchestrator(NewRepository(testDB), NewEventBus(), nil, nil, stubRepoManager{})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	task, err := orch.repo.Create(ctx, "", "fix the bug")
+	require.NoError(t, err)
+
+	// Simulate a run in flight
+	orch.mu.Lock()
+	orch.running[task.ID] = func() {}
+	orch.mu.Unlock()
+
+	var running ErrTaskRunning
+	err = orch.ContinueTask(ctx, task.ID, "also fix the test", "")
+	assert.ErrorAs(t, err, &running)
+	err = orch.RerunTask(ctx, task.ID, "")
+	assert.ErrorAs(t, err, &running)
+	err = orch.submitTaskJob(ctx, task.ID, "", "fix the bug", "", "", "", "", "", jobNew)
+	assert.ErrorAs(t, err, &running)
+
+	orch.mu.Lock()
+	_, queued := orch.queued[task.ID]
+	orch.mu.Unlock()
+	assert.False(t, queued)
+}
+
+func TestRetryTaskWithIntent_Validation(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	orch, err := NewOrchestrator(NewRepository(testDB), NewEventBus(), nil, nil, stubRepoManager{})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	task, err := orch.repo.Create(ctx, "", "fix the bug")
+	require.NoError(t, err)
+
+	err = orch.RetryTaskWithIntent(ctx, task.ID, "  ")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "cannot be empty")
+
+	err = orch.RetryTaskWithIntent(ctx, "non-existent", "fix the other bug")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "task not found")
+
+	// A task that can't be rerun keeps its intent
+	_, err = orch.repo.ForceStatus(ctx, task.ID, "done")
+	require.NoError(t, err)
+	err = orch.RetryTaskWithIntent(ctx, task.ID, "fix the other bug")
+	var invalid ErrInvalidTransition
+	assert.ErrorAs(t, err, &invalid)
+	got, err := orch.repo.Get(ctx, task.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "fix the bug", got.Intent)
 }
 
 func TestExecuteTask_BackendSelection(t *testing.T) {
@@ -284,7 +499,7 @@ func TestContinueTask_WithMessageHistory(t *testing.T) {
 	// Test that submitTaskJob loads message history correctly
 	// We test this by verifying the job is submitted (async, so no immediate error)
 	// The actual execution will fail due to missing git/API, but loading should work
-	err = orch.submitTaskJob(ctx, task.ID, repoRow.ID, "continue", "model-1", "test", "test", "", true)
+	err = orch.submitTaskJob(ctx, task.ID, repoRow.ID, "continue", "model-1", "test", "test", "", "", jobContinue)
 	require.NoError(t, err, "submitTaskJob should successfully load message history")
 }
 
@@ -336,6 +551,384 @@ func TestContinueTask_NoMessages(t *testing.T) {
 
 	// ContinueTask should work fine even with no message history
 	// It will fail during execution (no git, no API keys) but the message history loading should succeed
-	err = orch.submitTaskJob(ctx, task.ID, repoRow.ID, "continue", "model-1", "test", "test", "", true)
+	err = orch.submitTaskJob(ctx, task.ID, repoRow.ID, "continue", "model-1", "test", "test", "", "", jobContinue)
 	require.NoError(t, err, "submitTaskJob should work even with no message history")
 }
+
+func TestCleanupWorkspaces_RemovesOnlyFinishedTasksWithSavedDiff(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	repo := NewRepository(testDB)
+	gm := NewGitManager(initTestGitRepo(t), t.TempDir())
+	rawLogDir, outputDir := t.TempDir(), t.TempDir()
+	orch, err := NewOrchestrator(repo, NewEventBus(), nil, nil, gm,
+		WithRawLogDir(rawLogDir, 0), WithOutputLimits(OutputLimits{}, outputDir))
+	require.NoError(t, err)
+	defer orch.Shutdown()
+
+	writeTaskFiles := func(taskID string) {
+		for _, dir := range []string{rawLogDir, outputDir} {
+			require.NoError(t, os.MkdirAll(filepath.Join(dir, taskID), 0755))
+			require.NoError(t, os.WriteFile(filepath.Join(dir, taskID, "run.log"), []byte("output"), 0644))
+		}
+	}
+
+	ctx := context.Background()
+	newTaskWithWorkspace := func(status string, saveDiff bool) string {
+		task, err := repo.Create(ctx, "", "test task")
+		require.NoError(t, err)
+		_, err = repo.ForceStatus(ctx, task.ID, status)
+		require.NoError(t, err)
+		_, err = gm.CreateWorkspace(ctx, task.ID, TaskBranchName(task.ID), "")
+		require.NoError(t, err)
+		if saveDiff {
+			require.NoError(t, repo.SaveDiff(ctx, task.ID, "diff --git a/x b/x\n", "", nil))
+		}
+		return task.ID
+	}
+
+	review := newTaskWithWorkspace("review", true)
+	running := newTaskWithWorkspace("in_progress", true)
+	unsaved := newTaskWithWorkspace("failed", false)
+	discarded := newTaskWithWorkspace("done", true)
+	require.NoError(t, orch.CleanupTask(ctx, discarded))
+	for _, taskID := range []string{review, running, discarded} {
+		writeTaskFiles(taskID)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	removed, err := orch.CleanupWorkspaces(ctx, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{review}, removed)
+	assert.NoDirExists(t, gm.WorkspacePath(review))
+	assert.DirExists(t, gm.WorkspacePath(running))
+	assert.DirExists(t, gm.WorkspacePath(unsaved))
+
+	// Raw logs and full outputs go with the workspace, or after it when it
+	// was discarded earlier
+	for _, dir := range []string{rawLogDir, outputDir} {
+		assert.NoDirExists(t, filepath.Join(dir, review))
+		assert.NoDirExists(t, filepath.Join(dir, discarded))
+		assert.DirExists(t, filepath.Join(dir, running))
+	}
+
+	// The diff is still served from the DB
+	diff, err := repo.GetSavedDiff(ctx, review)
+	require.NoError(t, err)
+	assert.NotEmpty(t, diff)
+
+	// Nothing is old enough under a real retention window
+	removed, err = orch.CleanupWorkspaces(ctx, 24*time.Hour)
+	require.NoError(t, err)
+	assert.Empty(t, removed)
+}
+
+func TestDeleteTask_RemovesWorkspaceAndFiles(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	repo := NewRepository(testDB)
+	gm := NewGitManager(initTestGitRepo(t), t.TempDir())
+	rawLogDir, outputDir := t.TempDir(), t.TempDir()
+	orch, err := NewOrchestrator(repo, NewEventBus(), nil, nil, gm,
+		WithRawLogDir(rawLogDir, 0), WithOutputLimits(OutputLimits{Message: 8}, outputDir))
+	require.NoError(t, err)
+	defer orch.Shutdown()
+
+	ctx := context.Background()
+	task, err := repo.Create(ctx, "", "test task")
+	require.NoError(t, err)
+	_, err = gm.CreateWorkspace(ctx, task.ID, TaskBranchName(task.ID), "")
+	require.NoError(t, err)
+	runID, err := repo.CreateAgentRun(ctx, task.ID, "test task", "codex", "openai", "gpt-5")
+	require.NoError(t, err)
+	rawLog, closeRawLog := orch.openRawLog(task.ID, runID)
+	rawLog.Line("stdout", "hello")
+	closeRawLog()
+	_, fullPath := orch.capOutput(task.ID, "message.txt", "longer than eight bytes", 8)
+	require.FileExists(t, fullPath)
+
+	// Active tasks are refused
+	orch.mu.Lock()
+	orch.queued[task.ID] = struct{}{}
+	orch.mu.Unlock()
+	assert.ErrorAs(t, orch.DeleteTask(ctx, task.ID), &ErrTaskRunning{})
+	orch.mu.Lock()
+	delete(orch.queued, task.ID)
+	orch.mu.Unlock()
+
+	require.NoError(t, orch.DeleteTask(ctx, task.ID))
+	_, err = repo.Get(ctx, task.ID)
+	assert.Error(t, err)
+	assert.NoDirExists(t, gm.WorkspacePath(task.ID))
+	assert.NoDirExists(t, filepath.Join(rawLogDir, task.ID))
+	assert.NoDirExists(t, filepath.Join(outputDir, task.ID))
+
+	assert.Error(t, orch.DeleteTask(ctx, task.ID))
+}
+
+func TestPublishFileDiff_StopsPastInlineLimit(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	repo := NewRepository(testDB)
+	gm := NewGitManager(initTestGitRepo(t), t.TempDir())
+	orch, err := NewOrchestrator(repo, NewEventBus(), nil, nil, gm, WithDiffInlineMaxLines(3))
+	require.NoError(t, err)
+	defer orch.Shutdown()
+
+	ctx := context.Background()
+	task, err := repo.Create(ctx, "", "test task")
+	require.NoError(t, err)
+	workspace, err := gm.CreateWorkspace(ctx, task.ID, TaskBranchName(task.ID), "")
+	require.NoError(t, err)
+
+	edit := func(name, content string) map[string]any {
+		path := filepath.Join(workspace, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		orch.publishFileDiff(ctx, task.ID, path)
+		events := orch.eventBus.TaskEvents(task.ID)
+		require.NotEmpty(t, events)
+		var update map[string]any
+		require.NoError(t, json.Unmarshal([]byte(events[len(events)-1].Data), &update))
+		return update
+	}
+
+	small := edit("small.txt", "one\ntwo\n")
+	assert.NotNil(t, small["files"])
+	assert.Nil(t, small["too_large"])
+
+	// Both files together change more lines than the limit
+	big := edit("big.txt", "three\nfour\n")
+	assert.Equal(t, true, big["too_large"])
+	assert.Equal(t, float64(3), big["max_lines"])
+	assert.Nil(t, big["files"])
+
+	// Shrinking a file brings the diff back under it
+	small = edit("small.txt", "one\n")
+	assert.NotNil(t, small["files"])
+}
+
+func TestGetRunSystemPrompt(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	orch, err := NewOrchestrator(NewRepository(testDB), NewEventBus(), nil, nil, stubRepoManager{})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	task, err := orch.repo.Create(ctx, "", "add tests")
+	require.NoError(t, err)
+	runID, err := orch.repo.CreateAgentRun(ctx, task.ID, "add tests", "native", "anthropic", "claude-3")
+	require.NoError(t, err)
+
+	// Runs from before prompts were recorded have none
+	_, err = orch.GetRunSystemPrompt(ctx, task.ID, runID)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	require.NoError(t, orch.repo.UpdateAgentRunSystemPrompt(ctx, runID, "You are careful."))
+	prompt, err := orch.GetRunSystemPrompt(ctx, task.ID, runID)
+	require.NoError(t, err)
+	assert.Equal(t, "You are careful.", prompt)
+
+	// A run is only visible through its own task
+	_, err = orch.GetRunSystemPrompt(ctx, "other-task", runID)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	assert.Error(t, orch.RerunTaskWithSystemPrompt(ctx, task.ID, "", "  "))
+}
+
+func TestShutdown_KillsCLIBackendProcess(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	ctx := context.Background()
+	settings := NewSettingsService(testDB)
+	require.NoError(t, settings.UpdateSettings(ctx, &Settings{AgentBackend: "native", AnthropicKey: "test-key"}))
+
+	// A stand-in CLI that records its PID and hangs
+	dir := t.TempDir()
+	pidFile := filepath.Join(dir, "pid")
+	binary := filepath.Join(dir, "codex")
+	require.NoError(t, os.WriteFile(binary, []byte("#!/bin/sh\necho $$ > "+pidFile+"\nexec sleep 60\n"), 0755))
+
+	orch, err := NewOrchestrator(NewRepository(testDB), NewEventBus(), settings, nil, stubRepoManager{},
+		WithBackendFactory(func(workDir, systemPrompt string) (agent.Backend, error) {
+			return agent.NewCodexBackend(agent.WithCodexBinaryPath(binary), agent.WithCodexWorkDir(dir))
+		}),
+	)
+	require.NoError(t, err)
+
+	task, err := orch.repo.Create(ctx, "", "hang")
+	require.NoError(t, err)
+	require.NoError(t, orch.submitTaskJob(ctx, task.ID, "", "hang", "", "", "", "", "", jobNew))
+
+	var pid int
+	require.Eventually(t, func() bool {
+		data, err := os.ReadFile(pidFile)
+		if err != nil {
+			return false
+		}
+		pid, err = strconv.Atoi(strings.TrimSpace(string(data)))
+		return err == nil
+	}, 5*time.Second, 20*time.Millisecond)
+
+	orch.Shutdown()
+
+	// The process is killed and reaped, so signalling it fails
+	require.Eventually(t, func() bool {
+		return syscall.Kill(pid, 0) != nil
+	}, 5*time.Second, 20*time.Millisecond)
+	assert.False(t, orch.isActive(task.ID))
+}
+
+func TestMoveTaskRepository(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	repo := NewRepository(testDB)
+	orch, err := NewOrchestrator(repo, NewEventBus(), nil, nil, stubRepoManager{})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	conn, err := testDB.Queries.CreateGithubConnection(ctx, sqlc.CreateGithubConnectionParams{
+		ID:           "conn-1",
+		GithubUserID: "user-1",
+		AccessToken:  "token",
+		Username:     "testuser",
+	})
+	require.NoError(t, err)
+	for _, id := range []string{"repo-1", "repo-2"} {
+		_, err := testDB.Queries.CreateRepository(ctx, sqlc.CreateRepositoryParams{
+			ID:           id,
+			ConnectionID: conn.ID,
+			Name:         id,
+			FullName:     "test/" + id,
+			Owner:        "test",
+		})
+		require.NoError(t, err)
+	}
+
+	task, err := repo.Create(ctx, "repo-1", "start")
+	require.NoError(t, err)
+	require.NoError(t, repo.UpdateTaskBaseBranch(ctx, task.ID, "release"))
+
+	// A pending task moves, and loses the old repository's base branch
+	require.NoError(t, orch.MoveTaskRepository(ctx, task.ID, "repo-2"))
+	moved, err := repo.Get(ctx, task.ID)
+	require.NoError(t, err)
+	require.NotNil(t, moved.RepositoryID)
+	assert.Equal(t, "repo-2", *moved.RepositoryID)
+	assert.Nil(t, moved.BaseBranch)
+
+	// Unknown projects are rejected
+	err = orch.MoveTaskRepository(ctx, task.ID, "repo-missing")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	// So are tasks with committed work, and tasks past pending
+	require.NoError(t, repo.SaveDiff(ctx, task.ID, "diff --git a/x b/x", "", nil))
+	var notMovable ErrTaskNotMovable
+	assert.ErrorAs(t, orch.MoveTaskRepository(ctx, task.ID, "repo-1"), &notMovable)
+
+	_, err = repo.UpdateStatus(ctx, task.ID, "in_progress")
+	require.NoError(t, err)
+	_, err = repo.UpdateStatus(ctx, task.ID, "review")
+	require.NoError(t, err)
+	assert.ErrorAs(t, orch.MoveTaskRepository(ctx, task.ID, "repo-1"), &notMovable)
+}
+
+func TestMergeAndPR_RefuseActiveTask(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	orch, err := NewOrchestrator(NewRepository(testDB), NewEventBus(), nil, nil, stubRepoManager{})
+	require.NoError(t, err)
+	defer orch.Shutdown()
+
+	ctx := context.Background()
+	task, err := orch.repo.Create(ctx, "", "add tests")
+	require.NoError(t, err)
+	_, err = orch.repo.ForceStatus(ctx, task.ID, "review")
+	require.NoError(t, err)
+
+	// A continuation waiting for a worker leaves the task in review
+	orch.mu.Lock()
+	orch.queued[task.ID] = struct{}{}
+	orch.mu.Unlock()
+
+	_, err = orch.MergeTask(ctx, task.ID)
+	assert.ErrorAs(t, err, &ErrTaskRunning{})
+	_, _, err = orch.MergeTaskFiles(ctx, task.ID, []string{"main.go"})
+	assert.ErrorAs(t, err, &ErrTaskRunning{})
+	_, err = orch.CreatePR(ctx, task.ID)
+	assert.ErrorAs(t, err, &ErrTaskRunning{})
+
+	got, err := orch.repo.Get(ctx, task.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "review", got.Status)
+}
+
+func TestMergeTask_ProtectedBranchOpensPR(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	branchChecks := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/test/repo-1/branches/main":
+			branchChecks++
+			_, _ = w.Write([]byte(`{"name": "main", "protected": true}`))
+		case "/repos/test/repo-1/pulls":
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"html_url": "https://github.com/test/repo-1/pull/7"}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	github := NewGitHubService(testDB, "", "", WithGitHubHost("", srv.URL))
+	orch, err := NewOrchestrator(NewRepository(testDB), NewEventBus(), nil, github, stubRepoManager{})
+	require.NoError(t, err)
+
+	conn, err := testDB.Queries.CreateGithubConnection(ctx, sqlc.CreateGithubConnectionParams{
+		ID:           "conn-1",
+		GithubUserID: "user-1",
+		AccessToken:  "token",
+		Username:     "testuser",
+	})
+	require.NoError(t, err)
+	_, err = testDB.Queries.CreateRepository(ctx, sqlc.CreateRepositoryParams{
+		ID:           "repo-1",
+		ConnectionID: conn.ID,
+		Name:         "repo-1",
+		FullName:     "test/repo-1",
+		Owner:        "test",
+	})
+	require.NoError(t, err)
+	task, err := orch.repo.Create(ctx, "repo-1", "protected merge")
+	require.NoError(t, err)
+	for _, status := range []string{"in_progress", "review"} {
+		_, err = orch.repo.UpdateStatus(ctx, task.ID, status)
+		require.NoError(t, err)
+	}
+
+	prURL, err := orch.MergeTask(ctx, task.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "https://github.com/test/repo-1/pull/7", prURL)
+
+	task, err = orch.repo.Get(ctx, task.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "done", task.Status)
+	require.NotNil(t, task.PRURL)
+	assert.Equal(t, prURL, *task.PRURL)
+
+	// The protection status is cached
+	protected, err := github.BranchProtected(ctx, "test", "repo-1", "main")
+	require.NoError(t, err)
+	assert.True(t, protected)
+	assert.Equal(t, 1, branchChecks)
+}
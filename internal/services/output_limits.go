@@ -0,0 +1,71 @@
+package services
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"unicode/utf8"
+
+	"github.com/revrost/counterspell/internal/agent"
+)
+
+// OutputLimits caps how much agent output is stored in the database. Longer
+// content is truncated with a marker and kept whole in a file. Zero disables
+// the corresponding cap.
+type OutputLimits struct {
+	// Message caps the content of each persisted agent message, in bytes.
+	Message int
+	// Diff caps the saved git diff of a task, in bytes.
+	Diff int
+}
+
+// TruncateOutput cuts s to at most limit bytes, on a UTF-8 boundary, and
+// appends a "[truncated N bytes]" marker. It returns s unchanged and false
+// when s fits or limit is 0.
+func TruncateOutput(s string, limit int) (string, bool) {
+	if limit <= 0 || len(s) <= limit {
+		return s, false
+	}
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s\n[truncated %d bytes]", s[:cut], len(s)-cut), true
+}
+
+// capBlocks returns a copy of blocks with project env values redacted and
+// text and tool output truncated to the message limit, so the stored parts
+// stay valid JSON. Values are redacted first so a cut can't leave part of a
+// secret behind.
+func (o *Orchestrator) capBlocks(taskID string, blocks []agent.ContentBlock) []agent.ContentBlock {
+	capped := make([]agent.ContentBlock, len(blocks))
+	for i, block := range blocks {
+		block = o.redactBlock(taskID, block)
+		block.Text, _ = TruncateOutput(block.Text, o.outputLimits.Message)
+		block.Content, _ = TruncateOutput(block.Content, o.outputLimits.Message)
+		capped[i] = block
+	}
+	return capped
+}
+
+// capOutput truncates content to limit and, when it was cut, writes the full
+// content to <outputDir>/<taskID>/<name>. It returns the content to persist
+// and the full content's path, or "" when nothing was cut or there is
+// nowhere to keep it.
+func (o *Orchestrator) capOutput(taskID, name, content string, limit int) (string, string) {
+	short, truncated := TruncateOutput(content, limit)
+	if !truncated || o.outputDir == "" {
+		return short, ""
+	}
+	path := filepath.Join(o.outputDir, taskID, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		slog.Warn("[ORCHESTRATOR] Failed to create output dir", "task_id", taskID, "error", err)
+		return short, ""
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		slog.Warn("[ORCHESTRATOR] Failed to save full output", "task_id", taskID, "path", path, "error", err)
+		return short, ""
+	}
+	return short, path
+}
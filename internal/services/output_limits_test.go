@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/revrost/counterspell/internal/agent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTruncateOutput(t *testing.T) {
+	out, truncated := TruncateOutput("short", 10)
+	assert.False(t, truncated)
+	assert.Equal(t, "short", out)
+
+	out, truncated = TruncateOutput("anything", 0)
+	assert.False(t, truncated)
+	assert.Equal(t, "anything", out)
+
+	out, truncated = TruncateOutput("0123456789abcdef", 10)
+	assert.True(t, truncated)
+	assert.Equal(t, "0123456789\n[truncated 6 bytes]", out)
+
+	// Never cuts a multi-byte rune in half
+	out, truncated = TruncateOutput("aé€b", 3)
+	assert.True(t, truncated)
+	assert.Equal(t, "aé\n[truncated 4 bytes]", out)
+}
+
+func TestPersistAgentMessage_TruncatesLongOutput(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	outputDir := t.TempDir()
+	orch, err := NewOrchestrator(NewRepository(testDB), NewEventBus(), nil, nil, stubRepoManager{},
+		WithOutputLimits(OutputLimits{Message: 64}, outputDir))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	task, err := orch.repo.Create(ctx, "", "dump logs")
+	require.NoError(t, err)
+	runID, err := orch.repo.CreateAgentRun(ctx, task.ID, "dump logs", "native", "anthropic", "claude-3")
+	require.NoError(t, err)
+
+	long := strings.Repeat("log line\n", 100)
+	orch.persistAgentMessage(ctx, task.ID, runID, &streamMessage{
+		role:   "assistant",
+		blocks: []agent.ContentBlock{{Type: "text", Text: long}},
+	})
+	orch.persistAgentMessage(ctx, task.ID, runID, &streamMessage{
+		role:   "assistant",
+		blocks: []agent.ContentBlock{{Type: "text", Text: "done"}},
+	})
+
+	messages, err := orch.repo.GetMessagesByTask(ctx, task.ID)
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+	if messages[0].Content == "done" {
+		messages[0], messages[1] = messages[1], messages[0]
+	}
+
+	assert.Contains(t, messages[0].Content, "[truncated 836 bytes]")
+	assert.Less(t, len(messages[0].Content), len(long))
+	assert.Contains(t, messages[0].Parts, "[truncated 836 bytes]")
+	require.True(t, messages[0].ArtifactPath.Valid)
+	full, err := os.ReadFile(messages[0].ArtifactPath.String)
+	require.NoError(t, err)
+	assert.Equal(t, long, string(full))
+
+	assert.Equal(t, "done", messages[1].Content)
+	assert.False(t, messages[1].ArtifactPath.Valid)
+}
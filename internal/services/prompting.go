@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log/slog"
 	"path/filepath"
+	"strings"
 
 	"github.com/revrost/counterspell/internal/prompt"
 )
@@ -11,6 +12,7 @@ import (
 func buildSystemPrompt(repoManager RepoManager, workDir string) string {
 	b := prompt.NewBuilder()
 	b.AddLine(fmt.Sprintf("You are a coding assistant. Work directory: %s. Be concise. Make changes directly.", workDir))
+	b.AddLine(fmt.Sprintf("To hand back a file that should not be committed, such as a build log or a report, write it under %s/ in the work directory.", ArtifactsDir))
 
 	if repoManager != nil && repoManager.Kind() == RepoKindJJ {
 		agentsPath := filepath.Join(repoManager.RootPath(), "AGENTS.md")
@@ -21,3 +23,17 @@ func buildSystemPrompt(repoManager RepoManager, workDir string) string {
 
 	return b.String()
 }
+
+// applyPromptPrefix puts prefix ahead of systemPrompt. An override copied
+// from a recorded run prompt already starts with the prefix, so that copy
+// is dropped rather than repeated.
+func applyPromptPrefix(prefix, systemPrompt string) string {
+	if prefix == "" {
+		return systemPrompt
+	}
+	rest := strings.TrimLeft(strings.TrimPrefix(systemPrompt, prefix), "\n")
+	if rest == "" {
+		return prefix
+	}
+	return prefix + "\n\n" + rest
+}
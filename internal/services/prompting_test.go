@@ -18,3 +18,13 @@ func TestBuildSystemPromptIncludesAgentsForJJ(t *testing.T) {
 	require.Contains(t, prompt, "TEST-AGENTS")
 	require.Contains(t, prompt, "AGENTS.md")
 }
+
+func TestApplyPromptPrefix(t *testing.T) {
+	prefix := "Never push to main."
+
+	require.Equal(t, "base", applyPromptPrefix("", "base"))
+	require.Equal(t, prefix+"\n\nbase", applyPromptPrefix(prefix, "base"))
+	require.Equal(t, prefix, applyPromptPrefix(prefix, ""))
+	// An override copied from a recorded prompt keeps a single prefix
+	require.Equal(t, prefix+"\n\nbase", applyPromptPrefix(prefix, prefix+"\n\nbase"))
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket per key: each key may take Limit tokens in a
+// burst, and the bucket refills at Limit per Window. It bounds how fast
+// tasks are submitted, independently of how many may run at once.
+type RateLimiter struct {
+	limit  float64
+	window time.Duration
+
+	mu      sync.Mutex
+	buckets map[string]*rateBucket
+}
+
+// rateBucket holds a key's tokens as of updated.
+type rateBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// NewRateLimiter returns a limiter allowing limit takes per window for each
+// key. It returns nil, allowing everything, when limit or window is not
+// positive.
+func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
+	if limit <= 0 || window <= 0 {
+		return nil
+	}
+	return &RateLimiter{
+		limit:   float64(limit),
+		window:  window,
+		buckets: make(map[string]*rateBucket),
+	}
+}
+
+// Take consumes a token for key at now. It returns the whole tokens left and
+// true, or false and how long until a token is available.
+func (l *RateLimiter) Take(key string, now time.Time) (remaining int, retryAfter time.Duration, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.refill(key, now)
+	if b.tokens < 1 {
+		perToken := float64(l.window) / l.limit
+		return 0, time.Duration(math.Ceil((1 - b.tokens) * perToken)), false
+	}
+	b.tokens--
+	return int(b.tokens), 0, true
+}
+
+// Refund returns a token taken for key, for an action that failed before
+// doing anything.
+func (l *RateLimiter) Refund(key string, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.refill(key, now)
+	b.tokens = math.Min(l.limit, b.tokens+1)
+}
+
+// refill returns key's bucket with the tokens earned since its last update
+// added. Callers hold l.mu.
+func (l *RateLimiter) refill(key string, now time.Time) *rateBucket {
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &rateBucket{tokens: l.limit, updated: now}
+		l.buckets[key] = b
+		return b
+	}
+	if elapsed := now.Sub(b.updated); elapsed > 0 {
+		b.tokens = math.Min(l.limit, b.tokens+l.limit*float64(elapsed)/float64(l.window))
+		b.updated = now
+	}
+	return b
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	l := NewRateLimiter(3, time.Hour)
+
+	for want := 2; want >= 0; want-- {
+		remaining, _, ok := l.Take("alice", now)
+		assert.True(t, ok)
+		assert.Equal(t, want, remaining)
+	}
+	_, retryAfter, ok := l.Take("alice", now)
+	assert.False(t, ok)
+	assert.Equal(t, 20*time.Minute, retryAfter)
+
+	// Keys have their own buckets
+	_, _, ok = l.Take("bob", now)
+	assert.True(t, ok)
+
+	// Tokens come back at limit per window
+	_, retryAfter, ok = l.Take("alice", now.Add(15*time.Minute))
+	assert.False(t, ok)
+	assert.Equal(t, 5*time.Minute, retryAfter)
+	remaining, _, ok := l.Take("alice", now.Add(20*time.Minute))
+	assert.True(t, ok)
+	assert.Equal(t, 0, remaining)
+
+	// A refund gives the token back, up to the limit
+	l.Refund("alice", now.Add(20*time.Minute))
+	remaining, _, ok = l.Take("alice", now.Add(20*time.Minute))
+	assert.True(t, ok)
+	assert.Equal(t, 0, remaining)
+	l.Refund("bob", now.Add(10*time.Hour))
+	remaining, _, _ = l.Take("bob", now.Add(10*time.Hour))
+	assert.Equal(t, 2, remaining)
+
+	assert.Nil(t, NewRateLimiter(0, time.Hour))
+}
@@ -2,11 +2,15 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 )
@@ -21,11 +25,50 @@ func (e *ErrMergeConflict) Error() string {
 	return fmt.Sprintf("merge conflict in %d files: %s", len(e.ConflictedFiles), strings.Join(e.ConflictedFiles, ", "))
 }
 
+// ErrSigningKeyMissing is returned when a project requires signed commits but
+// no signing key is configured.
+var ErrSigningKeyMissing = errors.New("commit signing is enabled for this project but GIT_SIGNING_KEY is not set")
+
+// ErrCommitsMustBeSigned is returned when the remote rejects a push because
+// its branch protection requires signed commits.
+var ErrCommitsMustBeSigned = errors.New("the remote requires signed commits: enable commit signing for this project and set GIT_SIGNING_KEY")
+
+// signingRejections are lowercased fragments of push rejections for unsigned
+// commits (GitHub, GitLab and Bitbucket wordings).
+var signingRejections = []string{"must have verified signatures", "must be signed", "unsigned commit"}
+
+// pushError describes a failed push, as ErrCommitsMustBeSigned when the
+// remote refused unsigned commits.
+func pushError(what string, err error, output []byte) error {
+	if signingRejected(output) {
+		err = ErrCommitsMustBeSigned
+	}
+	return fmt.Errorf("%s: %w\nOutput: %s", what, err, string(output))
+}
+
+func signingRejected(output []byte) bool {
+	lower := strings.ToLower(string(output))
+	for _, fragment := range signingRejections {
+		if strings.Contains(lower, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
 // GitManager handles git worktree operations.
 type GitManager struct {
-	repoRoot string
-	dataDir  string
-	mu       sync.Mutex
+	repoRoot  string
+	dataDir   string
+	isolation IsolationMode
+	// maxCloneSize caps the object store size of repos cloned per task (0 = no limit)
+	maxCloneSize int64
+	// sshKeyPath, when set, is the identity used for fetches and pushes
+	sshKeyPath string
+	// signingKey and signingFormat sign commits that ask for it
+	signingKey    string
+	signingFormat string
+	mu            sync.Mutex
 }
 
 // NewGitManager creates a new repo manager.
@@ -39,7 +82,57 @@ func NewGitManager(repoRoot, dataDir string) *GitManager {
 	if err != nil {
 		absDir = dataDir // fallback if conversion fails
 	}
-	return &GitManager{repoRoot: absRoot, dataDir: absDir}
+	return &GitManager{repoRoot: absRoot, dataDir: absDir, isolation: IsolationWorktree}
+}
+
+// SetIsolationMode selects between shared worktrees and independent clones.
+// Container mode uses an independent clone so the mounted directory is self-contained.
+func (m *GitManager) SetIsolationMode(mode IsolationMode) {
+	m.isolation = mode
+}
+
+// SetMaxCloneSize refuses clone workspaces for repositories whose object
+// store exceeds maxBytes, so one huge repo can't fill the data dir. 0 disables
+// the check. Worktrees share the base objects and are not affected.
+func (m *GitManager) SetMaxCloneSize(maxBytes int64) {
+	m.maxCloneSize = maxBytes
+}
+
+// SetSSHKey makes fetches and pushes authenticate with the given private key
+// through GIT_SSH_COMMAND, so no token needs to be embedded in the remote URL.
+// It only applies to SSH remotes. An empty path uses git's default.
+func (m *GitManager) SetSSHKey(path string) {
+	m.sshKeyPath = path
+}
+
+// SetSigningKey sets the key that signs commits whose CommitIdentity asks
+// for it. format is git's gpg.format: ssh (the default), openpgp or x509.
+// The key never leaves the server's config; only the per-project switch is
+// stored.
+func (m *GitManager) SetSigningKey(key, format string) {
+	if format == "" {
+		format = "ssh"
+	}
+	m.signingKey = key
+	m.signingFormat = format
+}
+
+// remoteCommand returns a git command that talks to a remote, with the
+// configured SSH identity applied.
+func (m *GitManager) remoteCommand(ctx context.Context, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	if m.sshKeyPath != "" {
+		cmd.Env = append(os.Environ(), "GIT_SSH_COMMAND="+sshCommand(m.sshKeyPath))
+	}
+	return cmd
+}
+
+// sshCommand builds a GIT_SSH_COMMAND that uses only keyPath. New host keys
+// are accepted on first use so headless setups don't hang on the prompt;
+// changed keys are still refused.
+func sshCommand(keyPath string) string {
+	quoted := "'" + strings.ReplaceAll(keyPath, "'", `'\''`) + "'"
+	return "ssh -i " + quoted + " -o IdentitiesOnly=yes -o StrictHostKeyChecking=accept-new"
 }
 
 func (m *GitManager) Kind() RepoKind {
@@ -55,23 +148,45 @@ func (m *GitManager) workspacePath(taskID string) string {
 	return filepath.Join(m.dataDir, "worktrees", "task-"+taskID)
 }
 
+// removePartialClones deletes clones left half-made by a previous process
+// that was killed mid-clone. Call it at startup, before any task runs.
+func (m *GitManager) removePartialClones() {
+	paths, _ := filepath.Glob(filepath.Join(m.dataDir, "worktrees", partialClonePrefix+"*"))
+	for _, path := range paths {
+		if err := os.RemoveAll(path); err != nil {
+			slog.Warn("[GIT] Failed to remove partial clone", "path", path, "error", err)
+			continue
+		}
+		slog.Info("[GIT] Removed partial clone", "path", path)
+	}
+}
+
 // WorkspacePath returns the workspace path for a given task (exported).
 func (m *GitManager) WorkspacePath(taskID string) string {
 	return m.workspacePath(taskID)
 }
 
 // CreateWorkspace creates an isolated workspace for a task.
-// Returns the workspace path.
-func (m *GitManager) CreateWorkspace(ctx context.Context, taskID, branchName string) (string, error) {
+// A new branch starts from origin/<base> when base is set, otherwise from the
+// base repo's checkout. Returns the workspace path.
+func (m *GitManager) CreateWorkspace(ctx context.Context, taskID, branchName, base string) (string, error) {
+	logger := taskLogger(ctx, taskID)
 	repoPath := m.repoRoot
 	workspacePath := m.workspacePath(taskID)
 
-	slog.Info("[GIT] Creating workspace", "task_id", taskID, "repo_path", repoPath, "workspace_path", workspacePath, "branch", branchName)
+	logger.Info("[GIT] Creating workspace", "repo_path", repoPath, "workspace_path", workspacePath, "branch", branchName, "base", base)
 
 	// Check if workspace already exists
 	if _, err := os.Stat(workspacePath); err == nil {
-		slog.Info("[GIT] Workspace already exists", "path", workspacePath)
-		return workspacePath, nil
+		linkErr := m.checkWorktree(ctx, workspacePath)
+		if linkErr == nil {
+			logger.Info("[GIT] Workspace already exists", "path", workspacePath)
+			return workspacePath, nil
+		}
+		logger.Warn("[GIT] Worktree is no longer linked to the base repo, recreating it", "path", workspacePath, "error", linkErr)
+		if err := m.dropBrokenWorktree(ctx, taskID, branchName, linkErr); err != nil {
+			return "", err
+		}
 	}
 
 	// Ensure worktrees directory exists
@@ -79,68 +194,361 @@ func (m *GitManager) CreateWorkspace(ctx context.Context, taskID, branchName str
 		return "", fmt.Errorf("failed to create worktrees dir: %w", err)
 	}
 
-	slog.Info("[GIT] Executing: git worktree add -b", "branch", branchName, "path", workspacePath, "dir", repoPath)
+	if m.isolation.independentWorkspace() {
+		return m.createClone(ctx, taskID, branchName, workspacePath, base)
+	}
+
+	addArgs := []string{"worktree", "add", "-b", branchName, workspacePath}
+	if base != "" {
+		cmd := m.remoteCommand(ctx, "fetch", "origin", base)
+		cmd.Dir = repoPath
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return "", fmt.Errorf("failed to fetch base branch %s: %w\nOutput: %s", base, err, string(output))
+		}
+		addArgs = append(addArgs, "origin/"+base)
+	}
+
+	logger.Info("[GIT] Executing: git worktree add -b", "branch", branchName, "path", workspacePath, "dir", repoPath)
 
 	// Create new branch and workspace
-	cmd := exec.CommandContext(ctx, "git", "worktree", "add", "-b", branchName, workspacePath)
+	cmd := exec.CommandContext(ctx, "git", addArgs...)
 	cmd.Dir = repoPath
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		slog.Warn("[GIT] First attempt failed, trying without -b", "error", err, "output", string(output))
+		logger.Warn("[GIT] First attempt failed, trying without -b", "error", err, "output", string(output))
 		// Branch might already exist, try without -b
 		cmd = exec.CommandContext(ctx, "git", "worktree", "add", workspacePath, branchName)
 		cmd.Dir = repoPath
 		output, err = cmd.CombinedOutput()
 		if err != nil {
-			slog.Error("[GIT] Worktree creation failed", "error", err, "output", string(output))
+			logger.Error("[GIT] Worktree creation failed", "error", err, "output", string(output))
 			return "", fmt.Errorf("git worktree add failed: %w\nOutput: %s", err, string(output))
 		}
 	}
+	if err := m.recordBase(ctx, workspacePath, branchName, base); err != nil {
+		return "", err
+	}
+
+	logger.Info("[GIT] Created workspace successfully", "path", workspacePath, "branch", branchName)
+	return workspacePath, nil
+}
+
+// ErrWorktreeBroken is returned when a task's worktree no longer resolves to
+// the base repo, e.g. after the repo was re-cloned, and its branch is gone
+// too, so the worktree can't be recreated.
+type ErrWorktreeBroken struct {
+	Path   string
+	Branch string
+	Cause  error
+}
+
+func (e ErrWorktreeBroken) Error() string {
+	return fmt.Sprintf("worktree %s is no longer linked to the repository (%v) and branch %s was found neither locally nor on origin; delete the worktree directory or restart the task from scratch",
+		e.Path, e.Cause, e.Branch)
+}
+
+func (e ErrWorktreeBroken) Unwrap() error {
+	return e.Cause
+}
+
+// checkWorktree verifies that the worktree at workspacePath still resolves to
+// the base repo. Clones have their own .git directory and always pass.
+func (m *GitManager) checkWorktree(ctx context.Context, workspacePath string) error {
+	if info, err := os.Lstat(filepath.Join(workspacePath, ".git")); err != nil || !info.Mode().IsRegular() {
+		return nil
+	}
+	got, err := gitCommonDir(ctx, workspacePath)
+	if err != nil {
+		return err
+	}
+	want, err := gitCommonDir(ctx, m.repoRoot)
+	if err != nil {
+		return fmt.Errorf("base repository: %w", err)
+	}
+	if got != want {
+		return fmt.Errorf("linked to %s instead of %s", got, want)
+	}
+	return nil
+}
+
+// gitCommonDir returns the resolved repository directory shared by dir and
+// the other worktrees of its repo.
+func gitCommonDir(ctx context.Context, dir string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", "rev-parse", "--path-format=absolute", "--git-common-dir")
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return filepath.EvalSymlinks(strings.TrimSpace(string(output)))
+}
+
+// dropBrokenWorktree removes the task's worktree after it failed checkWorktree
+// so it can be added again from branchName. The branch is fetched from origin when the base
+// repo no longer has it; without it anywhere, ErrWorktreeBroken is returned
+// and the worktree is left alone. Uncommitted changes in it are lost.
+func (m *GitManager) dropBrokenWorktree(ctx context.Context, taskID, branchName string, cause error) error {
+	workspacePath := m.workspacePath(taskID)
+	cmd := exec.CommandContext(ctx, "git", "rev-parse", "--verify", "--quiet", "refs/heads/"+branchName)
+	cmd.Dir = m.repoRoot
+	if err := cmd.Run(); err != nil {
+		if exists, err := m.RemoteBranchExists(ctx, branchName); err != nil || !exists {
+			return ErrWorktreeBroken{Path: workspacePath, Branch: branchName, Cause: cause}
+		}
+		cmd := m.remoteCommand(ctx, "fetch", "origin", branchName+":"+branchName)
+		cmd.Dir = m.repoRoot
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to fetch branch %s: %w\nOutput: %s", branchName, err, string(output))
+		}
+	}
+
+	if err := os.RemoveAll(workspacePath); err != nil {
+		return fmt.Errorf("failed to remove broken worktree: %w", err)
+	}
+	cmd = exec.CommandContext(ctx, "git", "worktree", "prune")
+	cmd.Dir = m.repoRoot
+	if output, err := cmd.CombinedOutput(); err != nil {
+		taskLogger(ctx, taskID).Warn("[GIT] Failed to prune workspaces", "error", err, "output", string(output))
+	}
+	return nil
+}
+
+// baseConfigKey is the git config key holding the base branch a task branch
+// was created from, so diffs and file filtering later compare against it.
+func baseConfigKey(branchName string) string {
+	return "branch." + branchName + ".counterspell-base"
+}
+
+// recordBase stores base as the task branch's base branch. No base leaves
+// the branch compared against main.
+func (m *GitManager) recordBase(ctx context.Context, workspacePath, branchName, base string) error {
+	if base == "" {
+		return nil
+	}
+	cmd := exec.CommandContext(ctx, "git", "config", baseConfigKey(branchName), base)
+	cmd.Dir = workspacePath
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to record base branch: %w\nOutput: %s", err, string(output))
+	}
+	return nil
+}
+
+// baseRefs returns the refs a task branch is compared against, most
+// specific first: its recorded base branch, or main/master.
+func (m *GitManager) baseRefs(ctx context.Context, workspacePath, branchName string) []string {
+	cmd := exec.CommandContext(ctx, "git", "config", "--get", baseConfigKey(branchName))
+	cmd.Dir = workspacePath
+	if output, err := cmd.Output(); err == nil {
+		if base := strings.TrimSpace(string(output)); base != "" {
+			return []string{"origin/" + base, base}
+		}
+	}
+	return []string{"origin/main", "main", "origin/master", "master"}
+}
+
+// RemoteBranchExists reports whether origin has a branch named branch.
+func (m *GitManager) RemoteBranchExists(ctx context.Context, branch string) (bool, error) {
+	cmd := m.remoteCommand(ctx, "ls-remote", "--exit-code", "--heads", "origin", "refs/heads/"+branch)
+	cmd.Dir = m.repoRoot
+	output, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 2 {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("git ls-remote failed: %w\nOutput: %s", err, string(output))
+	}
+	return true, nil
+}
+
+// partialClonePrefix names the directories createClone builds a clone in
+// before moving it into place.
+const partialClonePrefix = ".partial-"
+
+// createClone creates an independent clone for a task. Unlike a worktree it
+// shares no objects or refs with the base repo, so nothing the agent does in it
+// can corrupt the base clone. origin is repointed at the base repo's upstream
+// so pushes and PRs behave the same as with worktrees.
+//
+// The clone is built in a temporary directory and renamed into place once it
+// is complete, so an interrupted clone never leaves a half-made workspace
+// that the next CreateWorkspace would take for a finished one; the next
+// attempt simply clones again.
+func (m *GitManager) createClone(ctx context.Context, taskID, branchName, workspacePath, base string) (string, error) {
+	logger := taskLogger(ctx, taskID)
+	if m.maxCloneSize > 0 {
+		size, err := m.objectStoreSize(ctx)
+		if err != nil {
+			logger.Warn("[GIT] Could not determine repo size, cloning anyway", "error", err)
+		} else if size > m.maxCloneSize {
+			return "", ErrRepoTooLarge{Size: size, Limit: m.maxCloneSize}
+		}
+	}
+
+	tmpPath, err := os.MkdirTemp(filepath.Dir(workspacePath), partialClonePrefix+filepath.Base(workspacePath)+"-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create clone dir: %w", err)
+	}
+	done := false
+	defer func() {
+		if !done {
+			_ = os.RemoveAll(tmpPath)
+		}
+	}()
+
+	logger.Info("[GIT] Executing: git clone --no-hardlinks", "path", tmpPath, "source", m.repoRoot)
+	cmd := exec.CommandContext(ctx, "git", "clone", "--no-hardlinks", m.repoRoot, tmpPath)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		logger.Error("[GIT] Clone creation failed", "error", err, "output", string(output))
+		return "", fmt.Errorf("git clone failed: %w\nOutput: %s", err, string(output))
+	}
+
+	// Reuse the task branch if the base repo already has it (e.g. after a cleanup)
+	newBranch := false
+	cmd = exec.CommandContext(ctx, "git", "checkout", "-b", branchName, "origin/"+branchName)
+	cmd.Dir = tmpPath
+	if _, err := cmd.CombinedOutput(); err != nil {
+		newBranch = true
+		cmd = exec.CommandContext(ctx, "git", "checkout", "-b", branchName)
+		cmd.Dir = tmpPath
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return "", fmt.Errorf("git checkout -b failed: %w\nOutput: %s", err, string(output))
+		}
+	}
+
+	cmd = exec.CommandContext(ctx, "git", "remote", "get-url", "origin")
+	cmd.Dir = m.repoRoot
+	if upstream, err := cmd.Output(); err == nil && strings.TrimSpace(string(upstream)) != "" {
+		cmd = exec.CommandContext(ctx, "git", "remote", "set-url", "origin", strings.TrimSpace(string(upstream)))
+		cmd.Dir = tmpPath
+		if output, err := cmd.CombinedOutput(); err != nil {
+			logger.Warn("[GIT] Failed to repoint clone origin", "error", err, "output", string(output))
+		}
+	}
+
+	// A new branch starts from the base branch as it is upstream
+	if base != "" && newBranch {
+		cmd = m.remoteCommand(ctx, "fetch", "origin", base)
+		cmd.Dir = tmpPath
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return "", fmt.Errorf("failed to fetch base branch %s: %w\nOutput: %s", base, err, string(output))
+		}
+		cmd = exec.CommandContext(ctx, "git", "reset", "--hard", "FETCH_HEAD")
+		cmd.Dir = tmpPath
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return "", fmt.Errorf("git reset to base branch failed: %w\nOutput: %s", err, string(output))
+		}
+	}
+	if err := m.recordBase(ctx, tmpPath, branchName, base); err != nil {
+		return "", err
+	}
+
+	if err := os.Rename(tmpPath, workspacePath); err != nil {
+		return "", fmt.Errorf("failed to move clone into place: %w", err)
+	}
+	done = true
 
-	slog.Info("[GIT] Created workspace successfully", "task_id", taskID, "path", workspacePath, "branch", branchName)
+	logger.Info("[GIT] Created clone workspace successfully", "path", workspacePath, "branch", branchName)
 	return workspacePath, nil
 }
 
-// Commit stages and commits changes without pushing.
-func (m *GitManager) Commit(ctx context.Context, taskID, message string) error {
+// ErrRepoTooLarge is returned when a clone workspace would exceed the
+// configured size limit.
+type ErrRepoTooLarge struct {
+	Size  int64
+	Limit int64
+}
+
+func (e ErrRepoTooLarge) Error() string {
+	return fmt.Sprintf("repository is %d MB, over the %d MB clone limit (MAX_REPO_SIZE_MB); use worktree isolation or raise the limit",
+		e.Size>>20, e.Limit>>20)
+}
+
+// objectStoreSize returns the on-disk size of the base repo's objects (loose
+// and packed), which is roughly what a --no-hardlinks clone copies.
+func (m *GitManager) objectStoreSize(ctx context.Context) (int64, error) {
+	cmd := exec.CommandContext(ctx, "git", "count-objects", "-v")
+	cmd.Dir = m.repoRoot
+	output, err := cmd.Output()
+	if err != nil {
+		return 0, fmt.Errorf("git count-objects failed: %w", err)
+	}
+	var kib int64
+	for _, line := range strings.Split(string(output), "\n") {
+		key, value, ok := strings.Cut(line, ": ")
+		if !ok || (key != "size" && key != "size-pack") {
+			continue
+		}
+		n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("unexpected count-objects output %q: %w", line, err)
+		}
+		kib += n
+	}
+	return kib << 10, nil
+}
+
+// Commit stages and commits changes without pushing, as author.
+func (m *GitManager) Commit(ctx context.Context, taskID, message string, author CommitIdentity) error {
+	logger := taskLogger(ctx, taskID)
 	workspacePath := m.workspacePath(taskID)
 
-	slog.Info("[GIT] Commit called", "task_id", taskID, "workspace_path", workspacePath)
+	logger.Info("[GIT] Commit called", "workspace_path", workspacePath)
 
 	// Stage all changes
 	cmd := exec.CommandContext(ctx, "git", "add", "-A")
 	cmd.Dir = workspacePath
-	slog.Info("[GIT] Executing: git add -A", "dir", workspacePath)
+	logger.Info("[GIT] Executing: git add -A", "dir", workspacePath)
 	if output, err := cmd.CombinedOutput(); err != nil {
-		slog.Error("[GIT] git add failed", "error", err, "output", string(output))
+		logger.Error("[GIT] git add failed", "error", err, "output", string(output))
 		return fmt.Errorf("git add failed: %w\nOutput: %s", err, string(output))
 	}
-	slog.Info("[GIT] git add successful")
+	logger.Info("[GIT] git add successful")
 
 	// Check if there are changes to commit
 	cmd = exec.CommandContext(ctx, "git", "diff", "--cached", "--quiet")
 	cmd.Dir = workspacePath
 	if err := cmd.Run(); err == nil {
-		slog.Info("No changes to commit", "task_id", taskID)
+		logger.Info("No changes to commit")
 		return nil
 	}
 
 	// Commit
-	cmd = exec.CommandContext(ctx, "git", "commit", "-m", message)
+	args, err := m.commitArgs(author, message)
+	if err != nil {
+		return err
+	}
+	cmd = exec.CommandContext(ctx, "git", args...)
 	cmd.Dir = workspacePath
-	slog.Info("[GIT] Executing: git commit", "dir", workspacePath)
+	logger.Info("[GIT] Executing: git commit", "dir", workspacePath, "author", author.orDefault(), "signed", author.Sign)
 	if output, err := cmd.CombinedOutput(); err != nil {
-		slog.Error("[GIT] git commit failed", "error", err, "output", string(output))
+		logger.Error("[GIT] git commit failed", "error", err, "output", string(output))
 		return fmt.Errorf("git commit failed: %w\nOutput: %s", err, string(output))
 	}
 
-	slog.Info("[GIT] Committed successfully", "task_id", taskID)
+	logger.Info("[GIT] Committed successfully")
 	return nil
 }
 
-// CommitAndPush commits changes and pushes the branch.
-func (m *GitManager) CommitAndPush(ctx context.Context, taskID, message string) error {
-	if err := m.Commit(ctx, taskID, message); err != nil {
+// commitArgs returns the git arguments that commit with message, with author
+// as both author and committer whatever the repo or global config says, and
+// signed when author asks for it.
+func (m *GitManager) commitArgs(author CommitIdentity, message string) ([]string, error) {
+	author = author.orDefault()
+	args := []string{"-c", "user.name=" + author.Name, "-c", "user.email=" + author.Email}
+	if !author.Sign {
+		return append(args, "commit", "-m", message), nil
+	}
+	if m.signingKey == "" {
+		return nil, ErrSigningKeyMissing
+	}
+	args = append(args, "-c", "gpg.format="+m.signingFormat, "-c", "user.signingkey="+m.signingKey)
+	return append(args, "commit", "-S", "-m", message), nil
+}
+
+// CommitAndPush commits changes as author and pushes the branch.
+func (m *GitManager) CommitAndPush(ctx context.Context, taskID, message string, author CommitIdentity) error {
+	if err := m.Commit(ctx, taskID, message, author); err != nil {
 		return err
 	}
 	return m.PushBranch(ctx, taskID)
@@ -148,17 +556,18 @@ func (m *GitManager) CommitAndPush(ctx context.Context, taskID, message string)
 
 // PushBranch pushes the current branch to remote without committing.
 func (m *GitManager) PushBranch(ctx context.Context, taskID string) error {
+	logger := taskLogger(ctx, taskID)
 	workspacePath := m.workspacePath(taskID)
 
-	cmd := exec.CommandContext(ctx, "git", "push", "-u", "origin", "HEAD")
+	cmd := m.remoteCommand(ctx, "push", "-u", "origin", "HEAD")
 	cmd.Dir = workspacePath
-	slog.Info("[GIT] Executing: git push -u origin HEAD", "dir", workspacePath)
+	logger.Info("[GIT] Executing: git push -u origin HEAD", "dir", workspacePath)
 	if output, err := cmd.CombinedOutput(); err != nil {
-		slog.Error("[GIT] git push failed", "error", err, "output", string(output))
-		return fmt.Errorf("git push failed: %w\nOutput: %s", err, string(output))
+		logger.Error("[GIT] git push failed", "error", err, "output", string(output))
+		return pushError("git push failed", err, output)
 	}
 
-	slog.Info("[GIT] Pushed branch successfully", "task_id", taskID)
+	logger.Info("[GIT] Pushed branch successfully")
 	return nil
 }
 
@@ -176,77 +585,165 @@ func (m *GitManager) GetCurrentBranch(ctx context.Context, taskID string) (strin
 	return string(output), nil
 }
 
+// HeadCommit returns the SHA of the commit checked out in a task's workspace.
+func (m *GitManager) HeadCommit(ctx context.Context, taskID string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", "rev-parse", "HEAD")
+	cmd.Dir = m.workspacePath(taskID)
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git rev-parse failed: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
 // GetDiff returns the git diff for a task's workspace.
 // Shows diff between main branch and HEAD (all changes on the feature branch).
 func (m *GitManager) GetDiff(ctx context.Context, taskID string) (string, error) {
+	logger := taskLogger(ctx, taskID)
 	workspacePath := m.workspacePath(taskID)
 	if _, err := os.Stat(workspacePath); os.IsNotExist(err) {
-		slog.Warn("[GIT] Workspace missing, returning empty diff", "task_id", taskID, "path", workspacePath)
+		logger.Warn("[GIT] Workspace missing, returning empty diff", "path", workspacePath)
 		return "", nil
 	}
 
-	// slog.Info("[GIT] GetDiff called", "task_id", taskID, "workspace_path", workspacePath)
+	// slog.Info("[GIT] GetDiff called", "workspace_path", workspacePath)
 
 	// Get current branch name
 	branchCmd := exec.CommandContext(ctx, "git", "branch", "--show-current")
 	branchCmd.Dir = workspacePath
 	branchOutput, err := branchCmd.Output()
 	if err != nil {
-		slog.Error("[GIT] Failed to get branch name", "error", err)
+		logger.Error("[GIT] Failed to get branch name", "error", err)
 		return "", fmt.Errorf("git branch failed: %w", err)
 	}
 	currentBranch := strings.TrimSpace(string(branchOutput))
 
-	// Try origin/main first (remote tracking branch)
-	cmd := exec.CommandContext(ctx, "git", "diff", "origin/main", currentBranch)
-	cmd.Dir = workspacePath
-	output, err := cmd.CombinedOutput()
+	// Compare against the remote tracking branch first, then the local one
+	var output []byte
+	for _, ref := range m.baseRefs(ctx, workspacePath, currentBranch) {
+		cmd := exec.CommandContext(ctx, "git", "diff", ref, currentBranch)
+		cmd.Dir = workspacePath
+		output, err = cmd.CombinedOutput()
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		logger.Error("[GIT] GetDiff failed for all branches", "error", err)
+		return "", fmt.Errorf("git diff failed: %w\nOutput: %s", err, string(output))
+	}
+
+	logger.Info("[GIT] GetDiff successful", "diff_size", len(output))
+	return string(output), nil
+}
+
+// DiffStat returns per-file line counts of the same diff GetDiff shows, or
+// nil when the workspace is gone.
+func (m *GitManager) DiffStat(ctx context.Context, taskID string) (*DiffStat, error) {
+	workspacePath := m.workspacePath(taskID)
+	if _, err := os.Stat(workspacePath); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	branchCmd := exec.CommandContext(ctx, "git", "branch", "--show-current")
+	branchCmd.Dir = workspacePath
+	branchOutput, err := branchCmd.Output()
 	if err != nil {
-		// slog.Warn("[GIT] GetDiff origin/main failed, trying main", "error", err)
-		// Fallback to local main branch
-		cmd = exec.CommandContext(ctx, "git", "diff", "main", currentBranch)
+		return nil, fmt.Errorf("git branch failed: %w", err)
+	}
+	currentBranch := strings.TrimSpace(string(branchOutput))
+
+	var output []byte
+	for _, ref := range m.baseRefs(ctx, workspacePath, currentBranch) {
+		cmd := exec.CommandContext(ctx, "git", "diff", "--numstat", ref, currentBranch)
 		cmd.Dir = workspacePath
 		output, err = cmd.CombinedOutput()
-		if err != nil {
-			// slog.Warn("[GIT] GetDiff main failed, trying master", "error", err)
-			// Try master branch
-			cmd = exec.CommandContext(ctx, "git", "diff", "master", currentBranch)
-			cmd.Dir = workspacePath
-			output, err = cmd.CombinedOutput()
-			if err != nil {
-				slog.Error("[GIT] GetDiff failed for all branches", "error", err)
-				return "", fmt.Errorf("git diff failed: %w\nOutput: %s", err, string(output))
-			}
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("git diff --numstat failed: %w\nOutput: %s", err, string(output))
+	}
+	return ParseNumstat(string(output)), nil
+}
+
+// FileDiff returns the diff of one file in a task's workspace against the
+// task's base, edits not committed yet included, so a run's changes can be
+// shown while it goes on. path is relative to the workspace or absolute
+// inside it.
+func (m *GitManager) FileDiff(ctx context.Context, taskID, path string) (string, error) {
+	workspacePath := m.workspacePath(taskID)
+	rel, err := workspaceRelPath(workspacePath, path)
+	if err != nil {
+		return "", err
+	}
+	branch, err := m.GetCurrentBranch(ctx, taskID)
+	if err != nil {
+		return "", err
+	}
+
+	var output []byte
+	for _, ref := range m.baseRefs(ctx, workspacePath, strings.TrimSpace(branch)) {
+		cmd := exec.CommandContext(ctx, "git", "diff", ref, "--", rel)
+		cmd.Dir = workspacePath
+		output, err = cmd.CombinedOutput()
+		if err == nil {
+			break
 		}
 	}
+	if err != nil {
+		return "", fmt.Errorf("git diff failed: %w\nOutput: %s", err, string(output))
+	}
+	if len(output) > 0 {
+		return string(output), nil
+	}
 
-	slog.Info("[GIT] GetDiff successful", "task_id", taskID, "diff_size", len(output))
+	// New files stay untracked until the run's commit
+	cmd := exec.CommandContext(ctx, "git", "ls-files", "--others", "--exclude-standard", "--", rel)
+	cmd.Dir = workspacePath
+	untracked, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git ls-files failed: %w", err)
+	}
+	if strings.TrimSpace(string(untracked)) == "" {
+		return "", nil
+	}
+	cmd = exec.CommandContext(ctx, "git", "diff", "--no-index", "--", os.DevNull, rel)
+	cmd.Dir = workspacePath
+	output, err = cmd.Output()
+	// --no-index exits 1 when the files differ
+	var exitErr *exec.ExitError
+	if err != nil && !(errors.As(err, &exitErr) && exitErr.ExitCode() == 1) {
+		return "", fmt.Errorf("git diff --no-index failed: %w", err)
+	}
 	return string(output), nil
 }
 
 // PullMainIntoWorktree pulls the latest main into the workspace and merges.
 // If there's a merge conflict, returns ErrMergeConflict with the conflicted files.
 func (m *GitManager) PullMainIntoWorktree(ctx context.Context, taskID string) error {
+	logger := taskLogger(ctx, taskID)
 	workspacePath := m.workspacePath(taskID)
 	repoPath := m.repoRoot
 
-	slog.Info("[GIT] PullMainIntoWorktree called", "task_id", taskID, "workspace_path", workspacePath)
+	logger.Info("[GIT] PullMainIntoWorktree called", "workspace_path", workspacePath)
 
 	// Fetch latest from origin in workspace
-	cmd := exec.CommandContext(ctx, "git", "fetch", "origin", "main")
+	cmd := m.remoteCommand(ctx, "fetch", "origin", "main")
 	cmd.Dir = workspacePath
 	if _, err := cmd.CombinedOutput(); err != nil {
 		// Try master
-		cmd = exec.CommandContext(ctx, "git", "fetch", "origin", "master")
+		cmd = m.remoteCommand(ctx, "fetch", "origin", "master")
 		cmd.Dir = workspacePath
 		if output, err := cmd.CombinedOutput(); err != nil {
-			slog.Warn("[GIT] Fetch failed", "error", err, "output", string(output))
+			logger.Warn("[GIT] Fetch failed", "error", err, "output", string(output))
 		}
 	}
-	slog.Info("[GIT] Fetched latest from origin")
+	logger.Info("[GIT] Fetched latest from origin")
 
 	// Also fetch in main repo to keep it updated
-	cmd = exec.CommandContext(ctx, "git", "fetch", "origin")
+	cmd = m.remoteCommand(ctx, "fetch", "origin")
 	cmd.Dir = repoPath
 	_ = cmd.Run()
 
@@ -267,7 +764,7 @@ func (m *GitManager) PullMainIntoWorktree(ctx context.Context, taskID string) er
 					conflictedFiles = append(conflictedFiles, f)
 				}
 			}
-			slog.Info("[GIT] Merge conflict detected", "files", conflictedFiles)
+			logger.Info("[GIT] Merge conflict detected", "files", conflictedFiles)
 			return &ErrMergeConflict{
 				ConflictedFiles: conflictedFiles,
 				RepoPath:        workspacePath,
@@ -276,12 +773,13 @@ func (m *GitManager) PullMainIntoWorktree(ctx context.Context, taskID string) er
 		return fmt.Errorf("failed to merge origin/main: %w\nOutput: %s", err, string(output))
 	}
 
-	slog.Info("[GIT] Merged origin/main into workspace successfully")
+	logger.Info("[GIT] Merged origin/main into workspace successfully")
 	return nil
 }
 
-// CommitMergeResolution commits after merge conflict resolution.
-func (m *GitManager) CommitMergeResolution(ctx context.Context, taskID, message string) error {
+// CommitMergeResolution commits after merge conflict resolution, as author.
+func (m *GitManager) CommitMergeResolution(ctx context.Context, taskID, message string, author CommitIdentity) error {
+	logger := taskLogger(ctx, taskID)
 	workspacePath := m.workspacePath(taskID)
 
 	// Stage all changes
@@ -292,25 +790,44 @@ func (m *GitManager) CommitMergeResolution(ctx context.Context, taskID, message
 	}
 
 	// Commit
-	cmd = exec.CommandContext(ctx, "git", "commit", "-m", message)
+	args, err := m.commitArgs(author, message)
+	if err != nil {
+		return err
+	}
+	cmd = exec.CommandContext(ctx, "git", args...)
 	cmd.Dir = workspacePath
 	if output, err := cmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("git commit failed: %w\nOutput: %s", err, string(output))
 	}
 
 	// Push
-	cmd = exec.CommandContext(ctx, "git", "push", "-u", "origin", "HEAD")
+	cmd = m.remoteCommand(ctx, "push", "-u", "origin", "HEAD")
 	cmd.Dir = workspacePath
 	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("git push failed: %w\nOutput: %s", err, string(output))
+		return pushError("git push failed", err, output)
 	}
 
-	slog.Info("[GIT] Committed and pushed merge resolution", "task_id", taskID)
+	logger.Info("[GIT] Committed and pushed merge resolution")
+	return nil
+}
+
+// StageFiles stages paths in the task's workspace, marking conflicted
+// files as resolved without committing.
+func (m *GitManager) StageFiles(ctx context.Context, taskID string, paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	cmd := exec.CommandContext(ctx, "git", append([]string{"add", "--"}, paths...)...)
+	cmd.Dir = m.workspacePath(taskID)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git add failed: %w\nOutput: %s", err, string(output))
+	}
 	return nil
 }
 
 // AbortMerge aborts an in-progress merge.
 func (m *GitManager) AbortMerge(ctx context.Context, taskID string) error {
+	logger := taskLogger(ctx, taskID)
 	workspacePath := m.workspacePath(taskID)
 
 	cmd := exec.CommandContext(ctx, "git", "merge", "--abort")
@@ -319,20 +836,100 @@ func (m *GitManager) AbortMerge(ctx context.Context, taskID string) error {
 		return fmt.Errorf("git merge --abort failed: %w\nOutput: %s", err, string(output))
 	}
 
-	slog.Info("[GIT] Aborted merge", "task_id", taskID)
+	logger.Info("[GIT] Aborted merge")
 	return nil
 }
 
-// MergeToMain merges the task branch to main and pushes.
-// Returns the branch name that was merged.
-func (m *GitManager) MergeToMain(ctx context.Context, taskID string) (string, error) {
+// KeepOnlyFiles drops every change on the task branch except those to paths,
+// committing the excluded files back to their state at the fork point from
+// the base branch so a following merge leaves them untouched. The commit is
+// made as author, like the agent's own. It returns the excluded paths,
+// sorted.
+func (m *GitManager) KeepOnlyFiles(ctx context.Context, taskID string, paths []string, author CommitIdentity) ([]string, error) {
+	logger := taskLogger(ctx, taskID)
+	workspacePath := m.workspacePath(taskID)
+
+	git := func(args ...string) (string, error) {
+		cmd := exec.CommandContext(ctx, "git", args...)
+		cmd.Dir = workspacePath
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			return "", fmt.Errorf("git %s failed: %w\nOutput: %s", args[0], err, string(output))
+		}
+		return strings.TrimSpace(string(output)), nil
+	}
+
+	branchName, err := git("branch", "--show-current")
+	if err != nil {
+		return nil, err
+	}
+	var base string
+	for _, ref := range m.baseRefs(ctx, workspacePath, branchName) {
+		if mergeBase, err := git("merge-base", "HEAD", ref); err == nil {
+			base = mergeBase
+			break
+		}
+	}
+	if base == "" {
+		return nil, fmt.Errorf("no base branch to compare task %s against", taskID)
+	}
+
+	changed, err := git("diff", "--name-only", "--no-renames", base, "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	keep := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		keep[path.Clean(p)] = true
+	}
+	excluded := []string{}
+	for _, f := range strings.Split(changed, "\n") {
+		if f != "" && !keep[f] {
+			excluded = append(excluded, f)
+		}
+	}
+	if len(excluded) == 0 {
+		return excluded, nil
+	}
+	slices.Sort(excluded)
+
+	for _, f := range excluded {
+		// Files the branch added don't exist at base and are removed instead
+		if _, err := git("cat-file", "-e", base+":"+f); err == nil {
+			_, err = git("checkout", base, "--", f)
+			if err != nil {
+				return nil, err
+			}
+		} else if _, err := git("rm", "-q", "--", f); err != nil {
+			return nil, err
+		}
+	}
+	message := fmt.Sprintf("Exclude %d unapproved file(s) from merge", len(excluded))
+	args, err := m.commitArgs(author, message)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = workspacePath
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("git commit failed: %w\nOutput: %s", err, string(output))
+	}
+
+	logger.Info("[GIT] Excluded unapproved files", "files", excluded)
+	return excluded, nil
+}
+
+// MergeToMain merges the task branch to main, or to base when set, and
+// pushes. Returns the branch name that was merged.
+func (m *GitManager) MergeToMain(ctx context.Context, taskID, base string) (string, error) {
+	logger := taskLogger(ctx, taskID)
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	repoPath := m.repoRoot
 	workspacePath := m.workspacePath(taskID)
 
-	slog.Info("[GIT] MergeToMain called", "task_id", taskID, "repo_path", repoPath, "workspace_path", workspacePath)
+	logger.Info("[GIT] MergeToMain called", "repo_path", repoPath, "workspace_path", workspacePath)
 
 	// Get the branch name from the workspace
 	cmd := exec.CommandContext(ctx, "git", "branch", "--show-current")
@@ -342,33 +939,25 @@ func (m *GitManager) MergeToMain(ctx context.Context, taskID string) (string, er
 		return "", fmt.Errorf("failed to get branch name: %w", err)
 	}
 	branchName := strings.TrimSpace(string(branchOutput))
-	slog.Info("[GIT] Task branch", "branch", branchName)
+	logger.Info("[GIT] Task branch", "branch", branchName)
 
-	// Checkout main in the main repo
-	cmd = exec.CommandContext(ctx, "git", "checkout", "main")
-	cmd.Dir = repoPath
-	if _, err := cmd.CombinedOutput(); err != nil {
-		// Try master
-		cmd = exec.CommandContext(ctx, "git", "checkout", "master")
-		cmd.Dir = repoPath
-		if output, err := cmd.CombinedOutput(); err != nil {
-			return "", fmt.Errorf("failed to checkout main/master: %w\nOutput: %s", err, string(output))
+	if base != "" {
+		if err := m.checkoutBase(ctx, taskID, base); err != nil {
+			return "", err
 		}
+	} else if err := m.checkoutMain(ctx, taskID); err != nil {
+		return "", err
 	}
-	slog.Info("[GIT] Checked out main branch")
+	logger.Info("[GIT] Checked out and pulled target branch", "base", base)
 
-	// Pull latest main
-	cmd = exec.CommandContext(ctx, "git", "pull", "origin", "main")
-	cmd.Dir = repoPath
-	if _, err := cmd.CombinedOutput(); err != nil {
-		// Try master
-		cmd = exec.CommandContext(ctx, "git", "pull", "origin", "master")
+	// Independent clones keep the branch to themselves; bring it into the base repo first
+	if m.isolation.independentWorkspace() {
+		cmd = exec.CommandContext(ctx, "git", "fetch", workspacePath, "+"+branchName+":"+branchName)
 		cmd.Dir = repoPath
 		if output, err := cmd.CombinedOutput(); err != nil {
-			slog.Warn("[GIT] Pull failed, continuing anyway", "error", err, "output", string(output))
+			return "", fmt.Errorf("failed to fetch branch %s from clone: %w\nOutput: %s", branchName, err, string(output))
 		}
 	}
-	slog.Info("[GIT] Pulled latest main")
 
 	// Merge the task branch
 	cmd = exec.CommandContext(ctx, "git", "merge", branchName, "--no-edit")
@@ -392,7 +981,7 @@ func (m *GitManager) MergeToMain(ctx context.Context, taskID string) (string, er
 					}
 				}
 			}
-			slog.Info("[GIT] Merge conflict detected in MergeToMain", "files", conflictedFiles)
+			logger.Info("[GIT] Merge conflict detected in MergeToMain", "files", conflictedFiles)
 			return "", &ErrMergeConflict{
 				ConflictedFiles: conflictedFiles,
 				RepoPath:        workspacePath,
@@ -400,76 +989,157 @@ func (m *GitManager) MergeToMain(ctx context.Context, taskID string) (string, er
 		}
 		return "", fmt.Errorf("failed to merge branch %s: %w\nOutput: %s", branchName, err, string(output))
 	}
-	slog.Info("[GIT] Merged branch", "branch", branchName)
+	logger.Info("[GIT] Merged branch", "branch", branchName)
 
 	// Push to origin
-	cmd = exec.CommandContext(ctx, "git", "push", "origin", "main")
-	cmd.Dir = repoPath
-	if _, err := cmd.CombinedOutput(); err != nil {
-		// Try master
-		cmd = exec.CommandContext(ctx, "git", "push", "origin", "master")
+	if base != "" {
+		cmd = m.remoteCommand(ctx, "push", "origin", base)
+		cmd.Dir = repoPath
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return "", pushError("failed to push to "+base, err, output)
+		}
+	} else {
+		cmd = m.remoteCommand(ctx, "push", "origin", "main")
+		cmd.Dir = repoPath
+		if output, err := cmd.CombinedOutput(); err != nil {
+			if signingRejected(output) {
+				return "", pushError("failed to push to main", err, output)
+			}
+			// Try master
+			cmd = m.remoteCommand(ctx, "push", "origin", "master")
+			cmd.Dir = repoPath
+			if output, err := cmd.CombinedOutput(); err != nil {
+				return "", pushError("failed to push to main", err, output)
+			}
+		}
+	}
+	logger.Info("[GIT] Pushed to origin", "base", base)
+
+	// New tasks without a base branch start from the base repo's checkout,
+	// so go back to the branch that was checked out before
+	if base != "" {
+		cmd = exec.CommandContext(ctx, "git", "checkout", "-")
 		cmd.Dir = repoPath
 		if output, err := cmd.CombinedOutput(); err != nil {
-			return "", fmt.Errorf("failed to push to main: %w\nOutput: %s", err, string(output))
+			logger.Warn("[GIT] Failed to switch back from base branch", "base", base, "error", err, "output", string(output))
 		}
 	}
-	slog.Info("[GIT] Pushed to origin main")
 
 	// Delete the remote branch (optional, don't fail if this errors)
-	cmd = exec.CommandContext(ctx, "git", "push", "origin", "--delete", branchName)
+	cmd = m.remoteCommand(ctx, "push", "origin", "--delete", branchName)
 	cmd.Dir = repoPath
 	if output, err := cmd.CombinedOutput(); err != nil {
-		slog.Warn("[GIT] Failed to delete remote branch (may not exist)", "branch", branchName, "error", err, "output", string(output))
+		logger.Warn("[GIT] Failed to delete remote branch (may not exist)", "branch", branchName, "error", err, "output", string(output))
 	} else {
-		slog.Info("[GIT] Deleted remote branch", "branch", branchName)
+		logger.Info("[GIT] Deleted remote branch", "branch", branchName)
 	}
 
 	// Remove the workspace first (branch cannot be deleted while used by workspace)
 	if err := os.RemoveAll(workspacePath); err != nil {
-		slog.Warn("[GIT] Failed to remove workspace directory", "error", err)
+		logger.Warn("[GIT] Failed to remove workspace directory", "error", err)
 	} else {
-		slog.Info("[GIT] Removed workspace directory", "path", workspacePath)
+		logger.Info("[GIT] Removed workspace directory", "path", workspacePath)
 	}
 
 	// Prune workspaces to clean up git's internal state
 	cmd = exec.CommandContext(ctx, "git", "worktree", "prune")
 	cmd.Dir = repoPath
 	if output, err := cmd.CombinedOutput(); err != nil {
-		slog.Warn("[GIT] Failed to prune workspaces", "error", err, "output", string(output))
+		logger.Warn("[GIT] Failed to prune workspaces", "error", err, "output", string(output))
 	} else {
-		slog.Info("[GIT] Pruned workspaces")
+		logger.Info("[GIT] Pruned workspaces")
 	}
 
 	// Delete the local branch
 	cmd = exec.CommandContext(ctx, "git", "branch", "-d", branchName)
 	cmd.Dir = repoPath
 	if output, err := cmd.CombinedOutput(); err != nil {
-		slog.Warn("[GIT] Failed to delete local branch", "branch", branchName, "error", err, "output", string(output))
+		logger.Warn("[GIT] Failed to delete local branch", "branch", branchName, "error", err, "output", string(output))
 	} else {
-		slog.Info("[GIT] Deleted local branch", "branch", branchName)
+		logger.Info("[GIT] Deleted local branch", "branch", branchName)
 	}
 
-	slog.Info("[GIT] MergeToMain completed successfully", "task_id", taskID, "branch", branchName)
+	logger.Info("[GIT] MergeToMain completed successfully", "branch", branchName)
 	return branchName, nil
 }
 
+// checkoutMain checks out and pulls main, or master, in the base repo.
+func (m *GitManager) checkoutMain(ctx context.Context, taskID string) error {
+	logger := taskLogger(ctx, taskID)
+	cmd := exec.CommandContext(ctx, "git", "checkout", "main")
+	cmd.Dir = m.repoRoot
+	if _, err := cmd.CombinedOutput(); err != nil {
+		// Try master
+		cmd = exec.CommandContext(ctx, "git", "checkout", "master")
+		cmd.Dir = m.repoRoot
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to checkout main/master: %w\nOutput: %s", err, string(output))
+		}
+	}
+
+	cmd = m.remoteCommand(ctx, "pull", "origin", "main")
+	cmd.Dir = m.repoRoot
+	if _, err := cmd.CombinedOutput(); err != nil {
+		// Try master
+		cmd = m.remoteCommand(ctx, "pull", "origin", "master")
+		cmd.Dir = m.repoRoot
+		if output, err := cmd.CombinedOutput(); err != nil {
+			logger.Warn("[GIT] Pull failed, continuing anyway", "error", err, "output", string(output))
+		}
+	}
+	return nil
+}
+
+// checkoutBase checks out base in the base repo, creating it from
+// origin/<base> when it only exists upstream, and pulls it.
+func (m *GitManager) checkoutBase(ctx context.Context, taskID, base string) error {
+	cmd := m.remoteCommand(ctx, "fetch", "origin", base)
+	cmd.Dir = m.repoRoot
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to fetch base branch %s: %w\nOutput: %s", base, err, string(output))
+	}
+	cmd = exec.CommandContext(ctx, "git", "checkout", base)
+	cmd.Dir = m.repoRoot
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to checkout %s: %w\nOutput: %s", base, err, string(output))
+	}
+	cmd = m.remoteCommand(ctx, "pull", "origin", base)
+	cmd.Dir = m.repoRoot
+	if output, err := cmd.CombinedOutput(); err != nil {
+		taskLogger(ctx, taskID).Warn("[GIT] Pull failed, continuing anyway", "error", err, "output", string(output))
+	}
+	return nil
+}
+
 // RemoveWorkspace removes the workspace for a task.
 func (m *GitManager) RemoveWorkspace(ctx context.Context, taskID string) error {
+	logger := taskLogger(ctx, taskID)
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	workspacePath := m.workspacePath(taskID)
-	slog.Info("[GIT] Removing workspace", "task_id", taskID, "path", workspacePath)
+	logger.Info("[GIT] Removing workspace", "path", workspacePath)
 
 	// Check if workspace exists
 	if _, err := os.Stat(workspacePath); os.IsNotExist(err) {
-		slog.Info("[GIT] Workspace does not exist, nothing to remove", "task_id", taskID)
+		logger.Info("[GIT] Workspace does not exist, nothing to remove")
 		return nil
 	}
 
+	// A clone holds the only copy of the task branch; keep it in the base repo
+	// so removing the workspace never loses commits (as with worktrees).
+	if m.isolation.independentWorkspace() {
+		branchName := TaskBranchName(taskID)
+		cmd := exec.CommandContext(ctx, "git", "fetch", workspacePath, "+"+branchName+":"+branchName)
+		cmd.Dir = m.repoRoot
+		if output, err := cmd.CombinedOutput(); err != nil {
+			logger.Warn("[GIT] Failed to keep task branch from clone", "error", err, "output", string(output))
+		}
+	}
+
 	// Remove the workspace directory first
 	if err := os.RemoveAll(workspacePath); err != nil {
-		slog.Error("[GIT] Failed to remove workspace directory", "error", err)
+		logger.Error("[GIT] Failed to remove workspace directory", "error", err)
 		return fmt.Errorf("failed to remove workspace directory: %w", err)
 	}
 
@@ -477,9 +1147,35 @@ func (m *GitManager) RemoveWorkspace(ctx context.Context, taskID string) error {
 	cmd := exec.CommandContext(ctx, "git", "worktree", "prune")
 	cmd.Dir = m.repoRoot
 	if output, err := cmd.CombinedOutput(); err != nil {
-		slog.Warn("[GIT] Failed to prune workspaces", "error", err, "output", string(output))
+		logger.Warn("[GIT] Failed to prune workspaces", "error", err, "output", string(output))
 	}
 
-	slog.Info("[GIT] Workspace removed", "task_id", taskID)
+	logger.Info("[GIT] Workspace removed")
+	return nil
+}
+
+// ArchiveWorkspace removes the task's workspace and renames its branch to
+// archiveName, so the next CreateWorkspace starts a fresh branch from the base
+// while the previous attempt stays available for comparison.
+func (m *GitManager) ArchiveWorkspace(ctx context.Context, taskID, archiveName string) error {
+	logger := taskLogger(ctx, taskID)
+	if err := m.RemoveWorkspace(ctx, taskID); err != nil {
+		return err
+	}
+
+	branchName := TaskBranchName(taskID)
+	cmd := exec.CommandContext(ctx, "git", "rev-parse", "--verify", "--quiet", "refs/heads/"+branchName)
+	cmd.Dir = m.repoRoot
+	if err := cmd.Run(); err != nil {
+		// No branch yet (the task never got a workspace), nothing to archive
+		return nil
+	}
+
+	cmd = exec.CommandContext(ctx, "git", "branch", "-m", branchName, archiveName)
+	cmd.Dir = m.repoRoot
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git branch rename failed: %w\nOutput: %s", err, string(output))
+	}
+	logger.Info("[GIT] Archived task branch", "branch", archiveName)
 	return nil
 }
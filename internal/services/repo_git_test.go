@@ -2,6 +2,10 @@ package services
 
 import (
 	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -13,3 +17,275 @@ func TestGitManagerGetDiffMissingWorkspace(t *testing.T) {
 	require.NoError(t, err)
 	require.Empty(t, diff)
 }
+
+// initTestGitRepo creates a git repository with a single commit on main.
+func initTestGitRepo(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	runGit(t, dir, "init", "-b", "main")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("hello\n"), 0644))
+	runGit(t, dir, "add", "-A")
+	runGit(t, dir, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-m", "init")
+	return dir
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(output))
+	return strings.TrimSpace(string(output))
+}
+
+func TestGitManagerCloneIsolation(t *testing.T) {
+	repoRoot := initTestGitRepo(t)
+	gm := NewGitManager(repoRoot, t.TempDir())
+	gm.SetIsolationMode(IsolationClone)
+
+	ctx := context.Background()
+	path, err := gm.CreateWorkspace(ctx, "task-1", TaskBranchName("task-1"), "")
+	require.NoError(t, err)
+
+	// A clone has its own .git directory rather than a worktree's .git file
+	info, err := os.Stat(filepath.Join(path, ".git"))
+	require.NoError(t, err)
+	require.True(t, info.IsDir())
+	require.Equal(t, TaskBranchName("task-1"), runGit(t, path, "branch", "--show-current"))
+
+	// The base repo knows nothing about the task until merge time
+	require.Len(t, strings.Split(runGit(t, repoRoot, "worktree", "list"), "\n"), 1)
+	require.Empty(t, runGit(t, repoRoot, "branch", "--list", TaskBranchName("task-1")))
+	require.NoError(t, gm.RemoveWorkspace(ctx, "task-1"))
+	require.NoDirExists(t, path)
+}
+
+func TestGitManagerCloneLeavesNoPartialWorkspace(t *testing.T) {
+	repoRoot := initTestGitRepo(t)
+	gm := NewGitManager(repoRoot, t.TempDir())
+	gm.SetIsolationMode(IsolationClone)
+
+	// An interrupted clone leaves nothing the next attempt could mistake
+	// for a finished workspace
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := gm.CreateWorkspace(ctx, "task-1", TaskBranchName("task-1"), "")
+	require.Error(t, err)
+	require.NoDirExists(t, gm.WorkspacePath("task-1"))
+	partial, _ := filepath.Glob(filepath.Join(gm.dataDir, "worktrees", partialClonePrefix+"*"))
+	require.Empty(t, partial)
+
+	path, err := gm.CreateWorkspace(context.Background(), "task-1", TaskBranchName("task-1"), "")
+	require.NoError(t, err)
+	require.Equal(t, TaskBranchName("task-1"), runGit(t, path, "branch", "--show-current"))
+
+	// Leftovers of a killed process are removed at startup
+	leftover := filepath.Join(gm.dataDir, "worktrees", partialClonePrefix+"task-task-2-123")
+	require.NoError(t, os.MkdirAll(filepath.Join(leftover, ".git"), 0755))
+	gm.removePartialClones()
+	require.NoDirExists(t, leftover)
+	require.DirExists(t, path)
+}
+
+func TestGitManagerCloneSizeLimit(t *testing.T) {
+	repoRoot := initTestGitRepo(t)
+	gm := NewGitManager(repoRoot, t.TempDir())
+	gm.SetIsolationMode(IsolationClone)
+	gm.SetMaxCloneSize(1) // any repo with a commit is bigger than one byte
+
+	_, err := gm.CreateWorkspace(context.Background(), "task-1", TaskBranchName("task-1"), "")
+	var tooLarge ErrRepoTooLarge
+	require.ErrorAs(t, err, &tooLarge)
+	require.Positive(t, tooLarge.Size)
+	require.NoDirExists(t, gm.WorkspacePath("task-1"))
+
+	// Worktrees share the base objects and ignore the limit
+	gm.SetIsolationMode(IsolationWorktree)
+	_, err = gm.CreateWorkspace(context.Background(), "task-1", TaskBranchName("task-1"), "")
+	require.NoError(t, err)
+
+	usage, err := GetDataDirUsage(gm.dataDir)
+	require.NoError(t, err)
+	require.Positive(t, usage.WorkspaceBytes)
+	require.GreaterOrEqual(t, usage.TotalBytes, usage.WorkspaceBytes)
+}
+
+func TestGitManagerArchiveWorkspace(t *testing.T) {
+	repoRoot := initTestGitRepo(t)
+	gm := NewGitManager(repoRoot, t.TempDir())
+	ctx := context.Background()
+	branch := TaskBranchName("task-1")
+
+	path, err := gm.CreateWorkspace(ctx, "task-1", branch, "")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(path, "first.txt"), []byte("first attempt\n"), 0644))
+	runGit(t, path, "add", "-A")
+	runGit(t, path, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-m", "first")
+
+	require.NoError(t, gm.ArchiveWorkspace(ctx, "task-1", branch+"-run-1"))
+	require.Equal(t, "first", runGit(t, repoRoot, "log", "-1", "--format=%s", branch+"-run-1"))
+
+	// The next workspace starts from the base again
+	path, err = gm.CreateWorkspace(ctx, "task-1", branch, "")
+	require.NoError(t, err)
+	require.NoFileExists(t, filepath.Join(path, "first.txt"))
+	require.Equal(t, "init", runGit(t, path, "log", "-1", "--format=%s"))
+}
+
+func TestGitManagerRecreatesBrokenWorktree(t *testing.T) {
+	repoRoot := initTestGitRepo(t)
+	gm := NewGitManager(repoRoot, t.TempDir())
+	ctx := context.Background()
+	branch := TaskBranchName("task-1")
+
+	path, err := gm.CreateWorkspace(ctx, "task-1", branch, "")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(path, "work.txt"), []byte("work\n"), 0644))
+	runGit(t, path, "add", "-A")
+	runGit(t, path, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-m", "work")
+
+	// Losing the base repo's record of the worktree, as a re-clone does,
+	// leaves its .git file pointing nowhere
+	require.NoError(t, os.RemoveAll(filepath.Join(repoRoot, ".git", "worktrees")))
+	require.Error(t, exec.Command("git", "-C", path, "status").Run())
+
+	// The next run recreates it from the branch
+	path, err = gm.CreateWorkspace(ctx, "task-1", branch, "")
+	require.NoError(t, err)
+	require.Equal(t, branch, runGit(t, path, "branch", "--show-current"))
+	require.Equal(t, "work", runGit(t, path, "log", "-1", "--format=%s"))
+	require.FileExists(t, filepath.Join(path, "work.txt"))
+
+	// Without the branch there is nothing to recreate it from
+	require.NoError(t, os.RemoveAll(filepath.Join(repoRoot, ".git", "worktrees")))
+	runGit(t, repoRoot, "branch", "-D", branch)
+	_, err = gm.CreateWorkspace(ctx, "task-1", branch, "")
+	var broken ErrWorktreeBroken
+	require.ErrorAs(t, err, &broken)
+	require.Equal(t, path, broken.Path)
+	require.DirExists(t, path)
+}
+
+func TestGitManagerKeepOnlyFiles(t *testing.T) {
+	repoRoot := initTestGitRepo(t)
+	gm := NewGitManager(repoRoot, t.TempDir())
+
+	ctx := context.Background()
+	path, err := gm.CreateWorkspace(ctx, "task-1", TaskBranchName("task-1"), "")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(path, "README.md"), []byte("changed\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(path, "keep.go"), []byte("package keep\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(path, "drop.go"), []byte("package drop\n"), 0644))
+	require.NoError(t, gm.Commit(ctx, "task-1", "agent changes", CommitIdentity{}))
+
+	// No git identity is configured; the exclusion commit uses the agent's
+	author := CommitIdentity{Name: "Agent", Email: "agent@example.com"}
+	excluded, err := gm.KeepOnlyFiles(ctx, "task-1", []string{"keep.go"}, author)
+	require.NoError(t, err)
+	require.Equal(t, []string{"README.md", "drop.go"}, excluded)
+	require.Equal(t, "Agent <agent@example.com>|Agent <agent@example.com>", runGit(t, path, "log", "-1", "--format=%an <%ae>|%cn <%ce>"))
+
+	// Only the approved file still differs from main
+	require.Equal(t, "keep.go", runGit(t, path, "diff", "--name-only", "main", "HEAD"))
+	require.NoFileExists(t, filepath.Join(path, "drop.go"))
+	readme, err := os.ReadFile(filepath.Join(path, "README.md"))
+	require.NoError(t, err)
+	require.Equal(t, "hello\n", string(readme))
+
+	// Approving everything that's left is a no-op
+	excluded, err = gm.KeepOnlyFiles(ctx, "task-1", []string{"keep.go"}, author)
+	require.NoError(t, err)
+	require.Empty(t, excluded)
+}
+
+func TestGitManagerCommitIdentity(t *testing.T) {
+	repoRoot := initTestGitRepo(t)
+	runGit(t, repoRoot, "config", "user.name", "server user")
+	runGit(t, repoRoot, "config", "user.email", "server@example.com")
+	gm := NewGitManager(repoRoot, t.TempDir())
+
+	ctx := context.Background()
+	path, err := gm.CreateWorkspace(ctx, "task-1", TaskBranchName("task-1"), "")
+	require.NoError(t, err)
+
+	// The repo's own identity is overridden for author and committer
+	require.NoError(t, os.WriteFile(filepath.Join(path, "a.txt"), []byte("a\n"), 0644))
+	require.NoError(t, gm.Commit(ctx, "task-1", "first", CommitIdentity{}))
+	require.Equal(t, "Counterspell Agent <agent@counterspell>|Counterspell Agent <agent@counterspell>",
+		runGit(t, path, "log", "-1", "--format=%an <%ae>|%cn <%ce>"))
+
+	require.NoError(t, os.WriteFile(filepath.Join(path, "b.txt"), []byte("b\n"), 0644))
+	require.NoError(t, gm.Commit(ctx, "task-1", "second", CommitIdentity{Name: "Team Bot", Email: "bot@example.com"}))
+	require.Equal(t, "Team Bot <bot@example.com>|Team Bot <bot@example.com>",
+		runGit(t, path, "log", "-1", "--format=%an <%ae>|%cn <%ce>"))
+}
+
+func TestGitManagerSignedCommit(t *testing.T) {
+	if _, err := exec.LookPath("ssh-keygen"); err != nil {
+		t.Skip("ssh-keygen not installed")
+	}
+	repoRoot := initTestGitRepo(t)
+	ctx := context.Background()
+
+	// Without a key a signed commit fails up front
+	gm := NewGitManager(repoRoot, t.TempDir())
+	path, err := gm.CreateWorkspace(ctx, "task-1", TaskBranchName("task-1"), "")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(path, "a.txt"), []byte("a\n"), 0644))
+	require.ErrorIs(t, gm.Commit(ctx, "task-1", "signed", CommitIdentity{Sign: true}), ErrSigningKeyMissing)
+
+	key := filepath.Join(t.TempDir(), "signing_key")
+	output, err := exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-f", key).CombinedOutput()
+	require.NoError(t, err, string(output))
+	gm.SetSigningKey(key, "")
+
+	require.NoError(t, gm.Commit(ctx, "task-1", "signed", CommitIdentity{Sign: true}))
+	require.Contains(t, runGit(t, path, "cat-file", "commit", "HEAD"), "BEGIN SSH SIGNATURE")
+
+	require.NoError(t, os.WriteFile(filepath.Join(path, "b.txt"), []byte("b\n"), 0644))
+	require.NoError(t, gm.Commit(ctx, "task-1", "unsigned", CommitIdentity{}))
+	require.NotContains(t, runGit(t, path, "cat-file", "commit", "HEAD"), "SIGNATURE")
+}
+
+func TestGitManagerPushRejectedUnsigned(t *testing.T) {
+	remote := filepath.Join(t.TempDir(), "remote.git")
+	runGit(t, initTestGitRepo(t), "clone", "--bare", ".", remote)
+	// What GitHub answers when branch protection requires signatures
+	hook := "#!/bin/sh\necho 'error: GH006: Protected branch update failed.' >&2\necho 'error: Commits must have verified signatures.' >&2\nexit 1\n"
+	require.NoError(t, os.WriteFile(filepath.Join(remote, "hooks", "pre-receive"), []byte(hook), 0755))
+	repoRoot := filepath.Join(t.TempDir(), "root")
+	runGit(t, filepath.Dir(repoRoot), "clone", remote, repoRoot)
+
+	gm := NewGitManager(repoRoot, t.TempDir())
+	ctx := context.Background()
+	path, err := gm.CreateWorkspace(ctx, "task-1", TaskBranchName("task-1"), "")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(path, "a.txt"), []byte("a\n"), 0644))
+	require.NoError(t, gm.Commit(ctx, "task-1", "unsigned", CommitIdentity{}))
+
+	require.ErrorIs(t, gm.PushBranch(ctx, "task-1"), ErrCommitsMustBeSigned)
+}
+
+func TestParseIsolationMode(t *testing.T) {
+	mode, err := ParseIsolationMode("")
+	require.NoError(t, err)
+	require.Equal(t, IsolationWorktree, mode)
+
+	mode, err = ParseIsolationMode("Container")
+	require.NoError(t, err)
+	require.Equal(t, IsolationContainer, mode)
+
+	_, err = ParseIsolationMode("vm")
+	require.Error(t, err)
+}
+
+func TestGitManagerRemoteCommandSSHKey(t *testing.T) {
+	gm := NewGitManager(t.TempDir(), t.TempDir())
+	cmd := gm.remoteCommand(context.Background(), "fetch", "origin")
+	require.Nil(t, cmd.Env, "no key keeps the inherited environment")
+
+	gm.SetSSHKey("/keys/it's deploy")
+	cmd = gm.remoteCommand(context.Background(), "fetch", "origin")
+	require.Contains(t, cmd.Env, `GIT_SSH_COMMAND=ssh -i '/keys/it'\''s deploy' -o IdentitiesOnly=yes -o StrictHostKeyChecking=accept-new`)
+	require.Equal(t, []string{"git", "fetch", "origin"}, cmd.Args)
+}
@@ -3,7 +3,6 @@ package services
 import (
 	"context"
 	"fmt"
-	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
@@ -47,11 +46,15 @@ func (m *JJManager) WorkspacePath(taskID string) string {
 	return filepath.Join(m.workspaceBase, filepath.FromSlash(name))
 }
 
-func (m *JJManager) CreateWorkspace(ctx context.Context, taskID, name string) (string, error) {
+func (m *JJManager) CreateWorkspace(ctx context.Context, taskID, name, base string) (string, error) {
+	if base != "" {
+		return "", ErrUnsupported{Kind: RepoKindJJ, Op: "base_branch"}
+	}
+	logger := taskLogger(ctx, taskID)
 	workspacePath := m.WorkspacePath(taskID)
 
 	if _, err := os.Stat(workspacePath); err == nil {
-		slog.Info("[JJ] Workspace already exists", "task_id", taskID, "path", workspacePath)
+		logger.Info("[JJ] Workspace already exists", "path", workspacePath)
 		return workspacePath, nil
 	}
 
@@ -68,41 +71,70 @@ func (m *JJManager) CreateWorkspace(ctx context.Context, taskID, name string) (s
 		return "", err
 	}
 
-	slog.Info("[JJ] Created workspace", "task_id", taskID, "path", workspacePath, "name", name)
+	logger.Info("[JJ] Created workspace", "path", workspacePath, "name", name)
 	return workspacePath, nil
 }
 
 func (m *JJManager) RemoveWorkspace(ctx context.Context, taskID string) error {
+	logger := taskLogger(ctx, taskID)
 	workspacePath := m.WorkspacePath(taskID)
 	name := m.workspaceName(taskID)
 
 	if _, err := os.Stat(workspacePath); os.IsNotExist(err) {
-		slog.Info("[JJ] Workspace does not exist, nothing to remove", "task_id", taskID)
+		logger.Info("[JJ] Workspace does not exist, nothing to remove")
 		return nil
 	}
 
 	if output, err := m.runner.Run(ctx, m.repoRoot, "jj", "workspace", "forget", name); err != nil {
-		slog.Warn("[JJ] Failed to forget workspace", "task_id", taskID, "error", err, "output", string(output))
+		logger.Warn("[JJ] Failed to forget workspace", "error", err, "output", string(output))
 	}
 
 	if err := os.RemoveAll(workspacePath); err != nil {
 		return fmt.Errorf("failed to remove workspace directory: %w", err)
 	}
 
-	slog.Info("[JJ] Removed workspace", "task_id", taskID, "path", workspacePath)
+	logger.Info("[JJ] Removed workspace", "path", workspacePath)
 	return nil
 }
 
-func (m *JJManager) Commit(ctx context.Context, taskID, message string) error {
+// ArchiveWorkspace forgets the task's workspace and renames its bookmark to
+// archiveName, so the next CreateWorkspace starts fresh.
+func (m *JJManager) ArchiveWorkspace(ctx context.Context, taskID, archiveName string) error {
+	logger := taskLogger(ctx, taskID)
+	if err := m.RemoveWorkspace(ctx, taskID); err != nil {
+		return err
+	}
+	name := m.workspaceName(taskID)
+	if output, err := m.runner.Run(ctx, m.repoRoot, "jj", "bookmark", "rename", name, archiveName); err != nil {
+		return fmt.Errorf("jj bookmark rename failed: %w\nOutput: %s", err, string(output))
+	}
+	logger.Info("[JJ] Archived task bookmark", "bookmark", archiveName)
+	return nil
+}
+
+// Commit describes the working-copy change with message, resetting its
+// author to author.
+func (m *JJManager) Commit(ctx context.Context, taskID, message string, author CommitIdentity) error {
+	if author.Sign {
+		return ErrUnsupported{Kind: RepoKindJJ, Op: "commit signing"}
+	}
 	workspacePath := m.WorkspacePath(taskID)
-	if output, err := m.runner.Run(ctx, workspacePath, "jj", "describe", "-m", message); err != nil {
+	author = author.orDefault()
+	if output, err := m.runner.Run(ctx, workspacePath, "jj",
+		"--config", "user.name="+author.Name, "--config", "user.email="+author.Email,
+		"describe", "--reset-author", "-m", message); err != nil {
 		return fmt.Errorf("jj describe failed: %w\nOutput: %s", err, string(output))
 	}
 	return nil
 }
 
-func (m *JJManager) CommitMergeResolution(ctx context.Context, taskID, message string) error {
-	return m.Commit(ctx, taskID, message)
+func (m *JJManager) CommitMergeResolution(ctx context.Context, taskID, message string, author CommitIdentity) error {
+	return m.Commit(ctx, taskID, message, author)
+}
+
+// StageFiles is a no-op: jj snapshots the working copy on every command.
+func (m *JJManager) StageFiles(ctx context.Context, taskID string, paths []string) error {
+	return nil
 }
 
 func (m *JJManager) AbortMerge(ctx context.Context, taskID string) error {
@@ -126,6 +158,16 @@ func (m *JJManager) GetCurrentBranch(ctx context.Context, taskID string) (string
 	return changeID, nil
 }
 
+// HeadCommit returns the git commit ID of the working copy's parent, the last
+// commit that can exist on the remote.
+func (m *JJManager) HeadCommit(ctx context.Context, taskID string) (string, error) {
+	output, err := m.runner.Run(ctx, m.WorkspacePath(taskID), "jj", "log", "-r", "@-", "--no-graph", "-T", "commit_id")
+	if err != nil {
+		return "", fmt.Errorf("jj log commit_id failed: %w\nOutput: %s", err, string(output))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
 func (m *JJManager) PushBranch(ctx context.Context, taskID string) error {
 	workspacePath := m.WorkspacePath(taskID)
 	bookmark, err := m.currentBookmark(ctx, workspacePath)
@@ -146,9 +188,10 @@ func (m *JJManager) PushBranch(ctx context.Context, taskID string) error {
 }
 
 func (m *JJManager) GetDiff(ctx context.Context, taskID string) (string, error) {
+	logger := taskLogger(ctx, taskID)
 	workspacePath := m.WorkspacePath(taskID)
 	if _, err := os.Stat(workspacePath); os.IsNotExist(err) {
-		slog.Warn("[JJ] Workspace missing, returning empty diff", "task_id", taskID, "path", workspacePath)
+		logger.Warn("[JJ] Workspace missing, returning empty diff", "path", workspacePath)
 		return "", nil
 	}
 
@@ -159,7 +202,46 @@ func (m *JJManager) GetDiff(ctx context.Context, taskID string) (string, error)
 	return string(output), nil
 }
 
-func (m *JJManager) MergeToMain(ctx context.Context, taskID string) (string, error) {
+func (m *JJManager) DiffStat(ctx context.Context, taskID string) (*DiffStat, error) {
+	workspacePath := m.WorkspacePath(taskID)
+	if _, err := os.Stat(workspacePath); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	output, err := m.runner.Run(ctx, workspacePath, "jj", "diff", "--git", "-r", "@")
+	if err != nil {
+		return nil, fmt.Errorf("jj diff failed: %w\nOutput: %s", err, string(output))
+	}
+	return DiffStatFromPatch(string(output)), nil
+}
+
+// FileDiff returns the diff of one file in the task's working-copy change.
+func (m *JJManager) FileDiff(ctx context.Context, taskID, path string) (string, error) {
+	workspacePath := m.WorkspacePath(taskID)
+	rel, err := workspaceRelPath(workspacePath, path)
+	if err != nil {
+		return "", err
+	}
+	output, err := m.runner.Run(ctx, workspacePath, "jj", "diff", "--git", "-r", "@", "--", rel)
+	if err != nil {
+		return "", fmt.Errorf("jj diff failed: %w\nOutput: %s", err, string(output))
+	}
+	return string(output), nil
+}
+
+func (m *JJManager) RemoteBranchExists(ctx context.Context, branch string) (bool, error) {
+	return false, ErrUnsupported{Kind: RepoKindJJ, Op: "base_branch"}
+}
+
+func (m *JJManager) KeepOnlyFiles(ctx context.Context, taskID string, paths []string, author CommitIdentity) ([]string, error) {
+	return nil, ErrUnsupported{Kind: RepoKindJJ, Op: "keep_only_files"}
+}
+
+func (m *JJManager) MergeToMain(ctx context.Context, taskID, base string) (string, error) {
+	if base != "" {
+		return "", ErrUnsupported{Kind: RepoKindJJ, Op: "base_branch"}
+	}
+	logger := taskLogger(ctx, taskID)
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -187,11 +269,11 @@ func (m *JJManager) MergeToMain(ctx context.Context, taskID string) (string, err
 
 	branchName := m.workspaceName(taskID)
 	if output, err := m.runner.Run(ctx, m.repoRoot, "jj", "bookmark", "delete", branchName); err != nil {
-		slog.Warn("[JJ] Failed to delete task bookmark", "task_id", taskID, "error", err, "output", string(output))
+		logger.Warn("[JJ] Failed to delete task bookmark", "error", err, "output", string(output))
 	}
 
 	if err := m.RemoveWorkspace(ctx, taskID); err != nil {
-		slog.Warn("[JJ] Failed to remove workspace after merge", "task_id", taskID, "error", err)
+		logger.Warn("[JJ] Failed to remove workspace after merge", "error", err)
 	}
 
 	return branchName, nil
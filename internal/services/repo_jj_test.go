@@ -60,7 +60,7 @@ func TestJJMergeToMain(t *testing.T) {
 		runner.EXPECT().Run(gomock.Any(), repoRoot, "jj", "workspace", "forget", branchName).Return([]byte(""), nil),
 	)
 
-	merged, err := jm.MergeToMain(context.Background(), taskID)
+	merged, err := jm.MergeToMain(context.Background(), taskID, "")
 	require.NoError(t, err)
 	require.Equal(t, branchName, merged)
 }
@@ -3,8 +3,11 @@ package services
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 )
 
 // RepoKind identifies the VCS backend in use.
@@ -20,15 +23,52 @@ type RepoManager interface {
 	Kind() RepoKind
 	RootPath() string
 	WorkspacePath(taskID string) string
-	CreateWorkspace(ctx context.Context, taskID, name string) (string, error)
+	CreateWorkspace(ctx context.Context, taskID, name, base string) (string, error)
 	RemoveWorkspace(ctx context.Context, taskID string) error
-	Commit(ctx context.Context, taskID, message string) error
-	CommitMergeResolution(ctx context.Context, taskID, message string) error
+	ArchiveWorkspace(ctx context.Context, taskID, archiveName string) error
+	Commit(ctx context.Context, taskID, message string, author CommitIdentity) error
+	CommitMergeResolution(ctx context.Context, taskID, message string, author CommitIdentity) error
+	StageFiles(ctx context.Context, taskID string, paths []string) error
 	AbortMerge(ctx context.Context, taskID string) error
 	GetCurrentBranch(ctx context.Context, taskID string) (string, error)
+	HeadCommit(ctx context.Context, taskID string) (string, error)
 	PushBranch(ctx context.Context, taskID string) error
 	GetDiff(ctx context.Context, taskID string) (string, error)
-	MergeToMain(ctx context.Context, taskID string) (string, error)
+	DiffStat(ctx context.Context, taskID string) (*DiffStat, error)
+	FileDiff(ctx context.Context, taskID, path string) (string, error)
+	KeepOnlyFiles(ctx context.Context, taskID string, paths []string, author CommitIdentity) ([]string, error)
+	MergeToMain(ctx context.Context, taskID, base string) (string, error)
+	RemoteBranchExists(ctx context.Context, branch string) (bool, error)
+}
+
+// DefaultCommitIdentity is the author and committer of agent commits when
+// none is configured.
+var DefaultCommitIdentity = CommitIdentity{Name: "Counterspell Agent", Email: "agent@counterspell"}
+
+// CommitIdentity is the author and committer set on agent commits, so they
+// are attributed to the agent rather than the server's git config.
+type CommitIdentity struct {
+	Name  string
+	Email string
+	// Sign signs the commit with the repo manager's signing key, for
+	// projects whose branch protection requires signed commits.
+	Sign bool
+}
+
+// orDefault fills empty fields from DefaultCommitIdentity.
+func (id CommitIdentity) orDefault() CommitIdentity {
+	if strings.TrimSpace(id.Name) == "" {
+		id.Name = DefaultCommitIdentity.Name
+	}
+	if strings.TrimSpace(id.Email) == "" {
+		id.Email = DefaultCommitIdentity.Email
+	}
+	return id
+}
+
+// String formats the identity as "Name <email>".
+func (id CommitIdentity) String() string {
+	return fmt.Sprintf("%s <%s>", id.Name, id.Email)
 }
 
 // TaskBranchName returns the branch/workspace name for a task.
@@ -36,6 +76,23 @@ func TaskBranchName(taskID string) string {
 	return "agent/task-" + taskID
 }
 
+// workspaceRelPath returns path, relative to the workspace or absolute
+// inside it, as a clean path relative to the workspace.
+func workspaceRelPath(workspacePath, path string) (string, error) {
+	if filepath.IsAbs(path) {
+		rel, err := filepath.Rel(workspacePath, path)
+		if err != nil {
+			return "", err
+		}
+		path = rel
+	}
+	path = filepath.Clean(path)
+	if path == "." || path == ".." || strings.HasPrefix(path, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside the workspace", path)
+	}
+	return path, nil
+}
+
 // ErrRepoRootNotFound is returned when no repo root is discovered.
 type ErrRepoRootNotFound struct {
 	Start string
@@ -55,8 +112,26 @@ func (e ErrUnsupported) Error() string {
 	return fmt.Sprintf("%s: %s is unsupported", e.Kind, e.Op)
 }
 
+// RepoOptions configures workspace creation for NewRepoManager.
+type RepoOptions struct {
+	// Isolation selects how task workspaces are created; jj only supports its
+	// own workspaces and ignores clone isolation.
+	Isolation IsolationMode
+	// MaxCloneSize refuses clone workspaces when the base repository's object
+	// store is larger than this many bytes. 0 disables the check.
+	MaxCloneSize int64
+	// SSHKeyPath is a private key used for fetches and pushes over SSH
+	// remotes instead of the user's default identity.
+	SSHKeyPath string
+	// SigningKey signs commits of projects with signing enabled: a key path
+	// (or "key::<public key>") for ssh, a key ID for openpgp and x509.
+	SigningKey string
+	// SigningFormat is git's gpg.format for SigningKey; empty means ssh.
+	SigningFormat string
+}
+
 // NewRepoManager detects the repo kind from the current working directory.
-func NewRepoManager(dataDir string) (RepoManager, error) {
+func NewRepoManager(dataDir string, opts RepoOptions) (RepoManager, error) {
 	cwd, err := os.Getwd()
 	if err != nil {
 		return nil, err
@@ -65,11 +140,44 @@ func NewRepoManager(dataDir string) (RepoManager, error) {
 	if err != nil {
 		return nil, err
 	}
+	if opts.SSHKeyPath != "" {
+		if _, err := os.Stat(opts.SSHKeyPath); err != nil {
+			return nil, fmt.Errorf("ssh key: %w", err)
+		}
+	}
+	if opts.SigningKey != "" {
+		if opts.SigningFormat == "" {
+			opts.SigningFormat = "ssh"
+		}
+		if !slices.Contains([]string{"ssh", "openpgp", "x509"}, opts.SigningFormat) {
+			return nil, fmt.Errorf("invalid signing format %q (must be one of: ssh, openpgp, x509)", opts.SigningFormat)
+		}
+		if opts.SigningFormat == "ssh" && !strings.HasPrefix(opts.SigningKey, "key::") {
+			if _, err := os.Stat(opts.SigningKey); err != nil {
+				return nil, fmt.Errorf("signing key: %w", err)
+			}
+		}
+	}
 	switch kind {
 	case RepoKindJJ:
+		if opts.Isolation.independentWorkspace() {
+			slog.Warn("[JJ] jj repositories use jj workspaces; clone isolation is not applied", "isolation", opts.Isolation)
+		}
+		if opts.SSHKeyPath != "" {
+			slog.Warn("[JJ] GIT_SSH_KEY is not applied to jj; configure the key in jj's ssh settings")
+		}
+		if opts.SigningKey != "" {
+			slog.Warn("[JJ] GIT_SIGNING_KEY is not applied to jj; projects with commit signing will fail to commit")
+		}
 		return NewJJManager(root, ExecCommandRunner{}), nil
 	case RepoKindGit:
-		return NewGitManager(root, dataDir), nil
+		gm := NewGitManager(root, dataDir)
+		gm.SetIsolationMode(opts.Isolation)
+		gm.SetMaxCloneSize(opts.MaxCloneSize)
+		gm.SetSSHKey(opts.SSHKeyPath)
+		gm.SetSigningKey(opts.SigningKey, opts.SigningFormat)
+		gm.removePartialClones()
+		return gm, nil
 	default:
 		return nil, fmt.Errorf("unsupported repo kind: %s", kind)
 	}
@@ -7,20 +7,43 @@ type stubRepoManager struct{}
 func (stubRepoManager) Kind() RepoKind                     { return RepoKindGit }
 func (stubRepoManager) RootPath() string                   { return "" }
 func (stubRepoManager) WorkspacePath(taskID string) string { return "" }
-func (stubRepoManager) CreateWorkspace(ctx context.Context, taskID, name string) (string, error) {
+func (stubRepoManager) CreateWorkspace(ctx context.Context, taskID, name, base string) (string, error) {
 	return "", nil
 }
 func (stubRepoManager) RemoveWorkspace(ctx context.Context, taskID string) error { return nil }
-func (stubRepoManager) Commit(ctx context.Context, taskID, message string) error { return nil }
-func (stubRepoManager) CommitMergeResolution(ctx context.Context, taskID, message string) error {
+func (stubRepoManager) ArchiveWorkspace(ctx context.Context, taskID, archiveName string) error {
+	return nil
+}
+func (stubRepoManager) Commit(ctx context.Context, taskID, message string, author CommitIdentity) error {
+	return nil
+}
+func (stubRepoManager) CommitMergeResolution(ctx context.Context, taskID, message string, author CommitIdentity) error {
+	return nil
+}
+func (stubRepoManager) StageFiles(ctx context.Context, taskID string, paths []string) error {
 	return nil
 }
 func (stubRepoManager) AbortMerge(ctx context.Context, taskID string) error { return nil }
 func (stubRepoManager) GetCurrentBranch(ctx context.Context, taskID string) (string, error) {
 	return "", nil
 }
+func (stubRepoManager) HeadCommit(ctx context.Context, taskID string) (string, error) {
+	return "", nil
+}
 func (stubRepoManager) PushBranch(ctx context.Context, taskID string) error        { return nil }
 func (stubRepoManager) GetDiff(ctx context.Context, taskID string) (string, error) { return "", nil }
-func (stubRepoManager) MergeToMain(ctx context.Context, taskID string) (string, error) {
+func (stubRepoManager) FileDiff(ctx context.Context, taskID, path string) (string, error) {
+	return "", nil
+}
+func (stubRepoManager) DiffStat(ctx context.Context, taskID string) (*DiffStat, error) {
+	return nil, nil
+}
+func (stubRepoManager) KeepOnlyFiles(ctx context.Context, taskID string, paths []string, author CommitIdentity) ([]string, error) {
+	return nil, nil
+}
+func (stubRepoManager) MergeToMain(ctx context.Context, taskID, base string) (string, error) {
 	return "", nil
 }
+func (stubRepoManager) RemoteBranchExists(ctx context.Context, branch string) (bool, error) {
+	return true, nil
+}
@@ -2,16 +2,22 @@ package services
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"slices"
 	"strings"
 	"time"
 
 	"github.com/lithammer/shortuuid/v4"
 	"github.com/revrost/counterspell/internal/agent"
+	"github.com/revrost/counterspell/internal/agent/tools"
 	"github.com/revrost/counterspell/internal/db"
 	"github.com/revrost/counterspell/internal/db/sqlc"
 	"github.com/revrost/counterspell/internal/models"
@@ -141,21 +147,131 @@ func (s *Repository) ListByStatus(ctx context.Context, status string) ([]*models
 	return result, nil
 }
 
-// UpdateStatus updates task status with validation.
-func (s *Repository) UpdateStatus(ctx context.Context, id, status string) error {
-	// Validate status
-	validStatuses := []string{"pending", "planning", "in_progress", "review", "done", "failed"}
+// ErrStatusConflict is returned by UpdateStatusIfVersion when the task's
+// status changed after the caller observed it. The update is not applied.
+type ErrStatusConflict struct {
+	TaskID  string
+	Status  string // status the caller tried to set
+	Version int64  // version the caller expected
+}
+
+func (e ErrStatusConflict) Error() string {
+	return fmt.Sprintf("task %s changed since version %d; not setting status %s", e.TaskID, e.Version, e.Status)
+}
+
+// ErrInvalidTransition is returned when a status change is not allowed from
+// the task's current status.
+type ErrInvalidTransition struct {
+	TaskID string
+	From   string
+	To     string
+}
+
+func (e ErrInvalidTransition) Error() string {
+	return fmt.Sprintf("task %s: cannot move from %s to %s", e.TaskID, e.From, e.To)
+}
+
+// validStatuses mirrors the CHECK constraint on tasks.status.
+var validStatuses = []string{"pending", "planning", "in_progress", "review", "done", "failed"}
+
+// statusTransitions lists the statuses each status may move to. Setting a
+// task to its current status is always allowed.
+var statusTransitions = map[string][]string{
+	"pending":     {"planning", "in_progress", "failed"},
+	"planning":    {"pending", "in_progress", "failed"},
+	"in_progress": {"review", "failed"},
+	"review":      {"in_progress", "done"},    // follow-up chat, merge/PR
+	"failed":      {"pending", "in_progress"}, // retry
+	"done":        {},
+}
+
+// IsValidStatus reports whether status is a known task status.
+func IsValidStatus(status string) bool {
+	return slices.Contains(validStatuses, status)
+}
+
+// CanTransition reports whether a task may move from one status to another.
+func CanTransition(from, to string) bool {
+	return from == to || slices.Contains(statusTransitions[from], to)
+}
+
+// UpdateStatus moves a task to status and returns its new version. Illegal
+// transitions fail with ErrInvalidTransition; use UpdateStatusIfVersion from
+// code that may be acting on stale state.
+func (s *Repository) UpdateStatus(ctx context.Context, id, status string) (int64, error) {
+	for attempt := 0; ; attempt++ {
+		task, err := s.db.Queries.GetTask(ctx, id)
+		if err != nil {
+			return 0, err
+		}
+		version, err := s.UpdateStatusIfVersion(ctx, id, status, task.Version)
+		// Lost a race with another writer: re-check the transition against
+		// the status it wrote.
+		var conflict ErrStatusConflict
+		if errors.As(err, &conflict) && attempt < 2 {
+			continue
+		}
+		return version, err
+	}
+}
+
+// UpdateStatusIfVersion sets the task status only if the task is still at
+// version (compare-and-set). It returns ErrStatusConflict when another write
+// got there first, e.g. a result from a cancelled run arriving after a merge.
+func (s *Repository) UpdateStatusIfVersion(ctx context.Context, id, status string, version int64) (int64, error) {
+	if !slices.Contains(validStatuses, status) {
+		return 0, fmt.Errorf("invalid status: %s", status)
+	}
+
+	task, err := s.db.Queries.GetTask(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	if task.Version != version {
+		return 0, ErrStatusConflict{TaskID: id, Status: status, Version: version}
+	}
+	if !CanTransition(task.Status, status) {
+		return 0, ErrInvalidTransition{TaskID: id, From: task.Status, To: status}
+	}
+
+	newVersion, err := s.db.Queries.UpdateTaskStatusIfVersion(ctx, sqlc.UpdateTaskStatusIfVersionParams{
+		Status:  status,
+		ID:      id,
+		Version: version,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrStatusConflict{TaskID: id, Status: status, Version: version}
+	}
+	return newVersion, err
+}
+
+// ForceStatus sets a task's status without checking the transition or
+// version. Only for admin and recovery paths such as ResetInProgress.
+func (s *Repository) ForceStatus(ctx context.Context, id, status string) (int64, error) {
 	if !slices.Contains(validStatuses, status) {
-		return fmt.Errorf("invalid status: %s", status)
+		return 0, fmt.Errorf("invalid status: %s", status)
 	}
 
-	if err := s.db.Queries.UpdateTaskStatus(ctx, sqlc.UpdateTaskStatusParams{
+	return s.db.Queries.UpdateTaskStatus(ctx, sqlc.UpdateTaskStatusParams{
 		Status: status,
 		ID:     id,
-	}); err != nil {
-		return err
+	})
+}
+
+// ResetInProgress marks tasks left in_progress by a previous process as
+// failed, since no worker will ever report their result. Call at startup,
+// before any task is submitted.
+func (s *Repository) ResetInProgress(ctx context.Context) (int, error) {
+	tasks, err := s.GetInProgressTasks(ctx)
+	if err != nil {
+		return 0, err
 	}
-	return nil
+	for _, task := range tasks {
+		if _, err := s.ForceStatus(ctx, task.ID, "failed"); err != nil {
+			return 0, fmt.Errorf("failed to reset task %s: %w", task.ID, err)
+		}
+	}
+	return len(tasks), nil
 }
 
 // GetTaskBySessionID retrieves a task by session ID.
@@ -185,6 +301,50 @@ func (s *Repository) UpdateTaskTitleIntent(ctx context.Context, taskID, title, i
 	})
 }
 
+// UpdateTaskBaseBranch records the branch a task starts from and merges into.
+func (s *Repository) UpdateTaskBaseBranch(ctx context.Context, taskID, baseBranch string) error {
+	return s.db.Queries.UpdateTaskBaseBranch(ctx, sqlc.UpdateTaskBaseBranchParams{
+		BaseBranch: sql.NullString{String: baseBranch, Valid: baseBranch != ""},
+		ID:         taskID,
+	})
+}
+
+// UpdateTaskRepository moves a task to another project. The base branch
+// belonged to the old repository, so it is cleared.
+func (s *Repository) UpdateTaskRepository(ctx context.Context, taskID, projectID string) error {
+	return s.db.Queries.UpdateTaskRepository(ctx, sqlc.UpdateTaskRepositoryParams{
+		RepositoryID: sql.NullString{String: projectID, Valid: true},
+		ID:           taskID,
+	})
+}
+
+// UpdateTaskPRURL records the pull request opened for a task.
+func (s *Repository) UpdateTaskPRURL(ctx context.Context, taskID, prURL string) error {
+	return s.db.Queries.UpdateTaskPRURL(ctx, sqlc.UpdateTaskPRURLParams{
+		PrUrl: sql.NullString{String: prURL, Valid: prURL != ""},
+		ID:    taskID,
+	})
+}
+
+// UpdateTaskPRState records the state GitHub reports for a task's pull
+// request.
+func (s *Repository) UpdateTaskPRState(ctx context.Context, taskID, state string) error {
+	return s.db.Queries.UpdateTaskPRState(ctx, sqlc.UpdateTaskPRStateParams{
+		PrState: sql.NullString{String: state, Valid: state != ""},
+		ID:      taskID,
+	})
+}
+
+// UpdateTaskNoChanges records whether a task's last successful run left
+// its diff empty.
+func (s *Repository) UpdateTaskNoChanges(ctx context.Context, taskID string, noChanges bool) error {
+	var v int64
+	if noChanges {
+		v = 1
+	}
+	return s.db.Queries.UpdateTaskNoChanges(ctx, sqlc.UpdateTaskNoChangesParams{NoChanges: v, ID: taskID})
+}
+
 // Delete removes a task.
 func (s *Repository) Delete(ctx context.Context, id string) error {
 	if err := s.db.Queries.DeleteTask(ctx, id); err != nil {
@@ -216,6 +376,11 @@ func sqlcTaskToModel(task *sqlc.Task) *models.Task {
 		Position:         nullableInt64(task.Position),
 		CreatedAt:        task.CreatedAt,
 		UpdatedAt:        task.UpdatedAt,
+		Version:          task.Version,
+		PRURL:            nullableString(task.PrUrl),
+		PRState:          nullableString(task.PrState),
+		BaseBranch:       nullableString(task.BaseBranch),
+		NoChanges:        task.NoChanges != 0,
 	}
 }
 
@@ -240,6 +405,7 @@ func sqlcTaskWithRepoToModel(task *sqlc.ListTasksWithRepositoryRow) *models.Task
 		LastAssistantMessage: lastMsg,
 		CreatedAt:            task.CreatedAt,
 		UpdatedAt:            task.UpdatedAt,
+		PRURL:                nullableString(task.PrUrl),
 	}
 }
 
@@ -257,6 +423,11 @@ func sqlcGetTaskRowToModel(task *sqlc.GetTaskRow) *models.Task {
 		Position:         nullableInt64(task.Position),
 		CreatedAt:        task.CreatedAt,
 		UpdatedAt:        task.UpdatedAt,
+		Version:          task.Version,
+		PRURL:            nullableString(task.PrUrl),
+		PRState:          nullableString(task.PrState),
+		BaseBranch:       nullableString(task.BaseBranch),
+		NoChanges:        task.NoChanges != 0,
 	}
 }
 
@@ -298,7 +469,9 @@ func (s *Repository) CreateMessage(ctx context.Context, taskID, runID, role, con
 }
 
 // CreateMessageWithParts creates a new message with structured parts.
-func (s *Repository) CreateMessageWithParts(ctx context.Context, taskID, runID, role, content, parts string) error {
+// artifactPath, when set, is the file holding the full content of a message
+// whose content was truncated.
+func (s *Repository) CreateMessageWithParts(ctx context.Context, taskID, runID, role, content, parts, artifactPath string) error {
 	id := shortuuid.New()
 	now := time.Now().UnixMilli()
 	if strings.TrimSpace(parts) == "" {
@@ -306,22 +479,63 @@ func (s *Repository) CreateMessageWithParts(ctx context.Context, taskID, runID,
 	}
 
 	return s.db.Queries.CreateMessage(ctx, sqlc.CreateMessageParams{
-		ID:        id,
-		TaskID:    taskID,
-		RunID:     runID,
-		Role:      role,
-		Content:   content,
-		Parts:     parts,
-		CreatedAt: now,
-		UpdatedAt: now,
+		ID:           id,
+		TaskID:       taskID,
+		RunID:        runID,
+		Role:         role,
+		Content:      content,
+		Parts:        parts,
+		ArtifactPath: sql.NullString{String: artifactPath, Valid: artifactPath != ""},
+		CreatedAt:    now,
+		UpdatedAt:    now,
 	})
 }
 
+// GetMessage retrieves a single message.
+func (s *Repository) GetMessage(ctx context.Context, id string) (*sqlc.Message, error) {
+	msg, err := s.db.Queries.GetMessage(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
 // GetMessagesByTask retrieves all messages for a task.
 func (s *Repository) GetMessagesByTask(ctx context.Context, taskID string) ([]sqlc.Message, error) {
 	return s.db.Queries.GetMessagesByTask(ctx, taskID)
 }
 
+// GetMessagesByRun retrieves the messages of one agent run, oldest first.
+func (s *Repository) GetMessagesByRun(ctx context.Context, runID string) ([]models.Message, error) {
+	rows, err := s.db.Queries.GetMessagesByRun(ctx, runID)
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]models.Message, len(rows))
+	for i, msg := range rows {
+		messages[i] = messageFromRow(msg)
+	}
+	return messages, nil
+}
+
+func messageFromRow(msg sqlc.Message) models.Message {
+	return models.Message{
+		ID:           msg.ID,
+		TaskID:       msg.TaskID,
+		RunID:        &msg.RunID,
+		Role:         msg.Role,
+		Parts:        msg.Parts,
+		Model:        nullableString(msg.Model),
+		Provider:     nullableString(msg.Provider),
+		Content:      msg.Content,
+		ToolID:       nullableString(msg.ToolID),
+		CreatedAt:    msg.CreatedAt,
+		UpdatedAt:    msg.UpdatedAt,
+		FinishedAt:   nullableInt64(msg.FinishedAt),
+		ArtifactPath: nullableString(msg.ArtifactPath),
+	}
+}
+
 // --- Agent Run Operations ---
 
 // GetTaskWithDetails retrieves a task with all related data for TaskResponse.
@@ -360,20 +574,7 @@ func (s *Repository) GetTaskWithDetails(ctx context.Context, taskID string) (*mo
 		runMessages := make([]models.Message, 0)
 		for _, msg := range messages {
 			if msg.RunID == ar.ID {
-				runMessages = append(runMessages, models.Message{
-					ID:         msg.ID,
-					TaskID:     msg.TaskID,
-					RunID:      &msg.RunID,
-					Role:       msg.Role,
-					Parts:      msg.Parts,
-					Model:      nullableString(msg.Model),
-					Provider:   nullableString(msg.Provider),
-					Content:    msg.Content,
-					ToolID:     nullableString(msg.ToolID),
-					CreatedAt:  msg.CreatedAt,
-					UpdatedAt:  msg.UpdatedAt,
-					FinishedAt: nullableInt64(msg.FinishedAt),
-				})
+				runMessages = append(runMessages, messageFromRow(msg))
 			}
 		}
 
@@ -404,6 +605,7 @@ func (s *Repository) GetTaskWithDetails(ctx context.Context, taskID string) (*mo
 			PromptTokens:     ar.PromptTokens,
 			CompletionTokens: ar.CompletionTokens,
 			CompletedAt:      nullableInt64FromTime(ar.CompletedAt),
+			LimitReached:     nullableString(ar.LimitReached),
 			CreatedAt:        ar.CreatedAt,
 			UpdatedAt:        ar.UpdatedAt,
 			Messages:         runMessages,
@@ -414,20 +616,7 @@ func (s *Repository) GetTaskWithDetails(ctx context.Context, taskID string) (*mo
 	// Top-level messages should include ALL messages for the task
 	taskMessages := make([]models.Message, len(messages))
 	for i, msg := range messages {
-		taskMessages[i] = models.Message{
-			ID:         msg.ID,
-			TaskID:     msg.TaskID,
-			RunID:      &msg.RunID,
-			Role:       msg.Role,
-			Parts:      msg.Parts,
-			Model:      nullableString(msg.Model),
-			Provider:   nullableString(msg.Provider),
-			Content:    msg.Content,
-			ToolID:     nullableString(msg.ToolID),
-			CreatedAt:  msg.CreatedAt,
-			UpdatedAt:  msg.UpdatedAt,
-			FinishedAt: nullableInt64(msg.FinishedAt),
-		}
+		taskMessages[i] = messageFromRow(msg)
 	}
 
 	// Convert artifacts to models
@@ -444,14 +633,298 @@ func (s *Repository) GetTaskWithDetails(ctx context.Context, taskID string) (*mo
 		}
 	}
 
+	logs, err := s.GetLogs(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+
 	return &models.TaskResponse{
 		Task:      *sqlcGetTaskRowToModel(&task),
 		Messages:  taskMessages,
 		Artifacts: taskArtifacts,
 		AgentRuns: agentRunsWithDetails,
+		Logs:      logs,
+	}, nil
+}
+
+// AppendLog persists an activity log entry for a task.
+func (s *Repository) AppendLog(ctx context.Context, taskID, level, message string) (*models.LogEntry, error) {
+	row, err := s.db.Queries.CreateTaskLog(ctx, sqlc.CreateTaskLogParams{
+		TaskID:    taskID,
+		Level:     level,
+		Message:   message,
+		CreatedAt: time.Now().UnixMilli(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to append task log: %w", err)
+	}
+	entry := sqlcTaskLogToModel(&row)
+	return &entry, nil
+}
+
+// GetLogs returns the full activity log for a task, oldest first.
+func (s *Repository) GetLogs(ctx context.Context, taskID string) ([]models.LogEntry, error) {
+	rows, err := s.db.Queries.ListTaskLogs(ctx, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list task logs: %w", err)
+	}
+	logs := make([]models.LogEntry, len(rows))
+	for i := range rows {
+		logs[i] = sqlcTaskLogToModel(&rows[i])
+	}
+	return logs, nil
+}
+
+func sqlcTaskLogToModel(row *sqlc.TaskLog) models.LogEntry {
+	return models.LogEntry{
+		ID:        row.ID,
+		TaskID:    row.TaskID,
+		Level:     row.Level,
+		Message:   row.Message,
+		Timestamp: row.CreatedAt,
+	}
+}
+
+// SaveTodos stores the latest todo list for a task, replacing any previous one.
+func (s *Repository) SaveTodos(ctx context.Context, taskID string, todos []tools.TodoItem) error {
+	data, err := json.Marshal(todos)
+	if err != nil {
+		return fmt.Errorf("failed to marshal todos: %w", err)
+	}
+	return s.db.Queries.UpsertTaskTodos(ctx, sqlc.UpsertTaskTodosParams{
+		TaskID:    taskID,
+		Todos:     string(data),
+		UpdatedAt: time.Now().UnixMilli(),
+	})
+}
+
+// GetTodos returns the latest todo list for a task, or an empty list if the
+// agent has not reported one yet.
+func (s *Repository) GetTodos(ctx context.Context, taskID string) ([]tools.TodoItem, error) {
+	row, err := s.db.Queries.GetTaskTodos(ctx, taskID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return []tools.TodoItem{}, nil
+		}
+		return nil, fmt.Errorf("failed to get todos: %w", err)
+	}
+	todos := []tools.TodoItem{}
+	if err := json.Unmarshal([]byte(row.Todos), &todos); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal todos: %w", err)
+	}
+	return todos, nil
+}
+
+// ListOpenTaskProgress returns the status and todo progress of every task
+// that is not done or failed, keyed by task ID.
+func (s *Repository) ListOpenTaskProgress(ctx context.Context) (map[string]models.TaskProgress, error) {
+	rows, err := s.db.Queries.ListOpenTaskProgress(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list task progress: %w", err)
+	}
+	progress := make(map[string]models.TaskProgress, len(rows))
+	for _, row := range rows {
+		p := models.TaskProgress{
+			RepositoryID: nullableString(row.RepositoryID),
+			Status:       row.Status,
+			Version:      row.Version,
+		}
+		var todos []tools.TodoItem
+		if row.Todos.Valid {
+			if err := json.Unmarshal([]byte(row.Todos.String), &todos); err != nil {
+				slog.Warn("ignoring malformed todos", "task_id", row.ID, "error", err)
+			}
+		}
+		for _, todo := range todos {
+			if todo.Status == tools.TodoStatusCompleted {
+				p.TodosDone++
+			}
+		}
+		p.TodosTotal = len(todos)
+		progress[row.ID] = p
+	}
+	return progress, nil
+}
+
+// SaveDiff stores the latest diff produced for a task so it stays viewable
+// after the task's workspace is removed. artifactPath, when set, is the file
+// holding the full diff when diff was truncated. stat, when set, is the full
+// diff's numstat, kept so its size can be checked without reading the diff.
+func (s *Repository) SaveDiff(ctx context.Context, taskID, diff, artifactPath string, stat *DiffStat) error {
+	statJSON, err := encodeDiffStat(stat)
+	if err != nil {
+		return err
+	}
+	return s.db.Queries.UpsertTaskDiff(ctx, sqlc.UpsertTaskDiffParams{
+		TaskID:       taskID,
+		Diff:         diff,
+		ArtifactPath: sql.NullString{String: artifactPath, Valid: artifactPath != ""},
+		Stat:         statJSON,
+		UpdatedAt:    time.Now().UnixMilli(),
+	})
+}
+
+// GetSavedDiffStat returns the numstat saved with a task's diff, or nil if
+// no diff was saved or it was saved without one.
+func (s *Repository) GetSavedDiffStat(ctx context.Context, taskID string) (*DiffStat, error) {
+	raw, err := s.db.Queries.GetTaskDiffStat(ctx, taskID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get diff stat: %w", err)
+	}
+	if !raw.Valid {
+		return nil, nil
+	}
+	var stat DiffStat
+	if err := json.Unmarshal([]byte(raw.String), &stat); err != nil {
+		return nil, fmt.Errorf("failed to decode diff stat: %w", err)
+	}
+	return &stat, nil
+}
+
+// SetSavedDiffStat records the numstat of a diff saved without one.
+func (s *Repository) SetSavedDiffStat(ctx context.Context, taskID string, stat *DiffStat) error {
+	statJSON, err := encodeDiffStat(stat)
+	if err != nil {
+		return err
+	}
+	return s.db.Queries.SetTaskDiffStat(ctx, sqlc.SetTaskDiffStatParams{Stat: statJSON, TaskID: taskID})
+}
+
+// encodeDiffStat returns stat as JSON, or NULL when stat is nil.
+func encodeDiffStat(stat *DiffStat) (sql.NullString, error) {
+	if stat == nil {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(stat)
+	if err != nil {
+		return sql.NullString{}, fmt.Errorf("failed to encode diff stat: %w", err)
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+// GetSavedDiff returns the last stored diff for a task, or "" if none was saved.
+func (s *Repository) GetSavedDiff(ctx context.Context, taskID string) (string, error) {
+	row, err := s.db.Queries.GetTaskDiff(ctx, taskID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get diff: %w", err)
+	}
+	return row.Diff, nil
+}
+
+// GetSavedDiffArtifact returns the file holding the full saved diff of a task,
+// or "" if the saved diff wasn't truncated.
+func (s *Repository) GetSavedDiffArtifact(ctx context.Context, taskID string) (string, error) {
+	row, err := s.db.Queries.GetTaskDiff(ctx, taskID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get diff: %w", err)
+	}
+	return row.ArtifactPath.String, nil
+}
+
+// SaveArtifact stores a file produced by a run. Writing a path the task
+// already has an artifact for adds a new version rather than replacing it.
+func (s *Repository) SaveArtifact(ctx context.Context, taskID, runID, path, content string) (*models.Artifact, error) {
+	latest, err := s.db.Queries.GetLatestArtifactVersion(ctx, sqlc.GetLatestArtifactVersionParams{
+		TaskID: taskID,
+		Path:   path,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get artifact version: %w", err)
+	}
+
+	now := time.Now().UnixMilli()
+	artifact := &models.Artifact{
+		ID:        shortuuid.New(),
+		RunID:     runID,
+		Path:      path,
+		Content:   content,
+		Version:   latest + 1,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.db.Queries.CreateArtifact(ctx, sqlc.CreateArtifactParams{
+		ID:        artifact.ID,
+		RunID:     artifact.RunID,
+		Path:      artifact.Path,
+		Content:   artifact.Content,
+		Version:   artifact.Version,
+		CreatedAt: artifact.CreatedAt,
+		UpdatedAt: artifact.UpdatedAt,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to create artifact: %w", err)
+	}
+	return artifact, nil
+}
+
+// ListArtifacts returns every artifact version of a task, oldest first,
+// without their content.
+func (s *Repository) ListArtifacts(ctx context.Context, taskID string) ([]models.Artifact, error) {
+	rows, err := s.db.Queries.GetArtifactsByTask(ctx, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list artifacts: %w", err)
+	}
+	artifacts := make([]models.Artifact, len(rows))
+	for i, row := range rows {
+		artifacts[i] = models.Artifact{
+			ID:        row.ID,
+			RunID:     row.RunID,
+			Path:      row.Path,
+			Version:   row.Version,
+			CreatedAt: row.CreatedAt,
+			UpdatedAt: row.UpdatedAt,
+		}
+	}
+	return artifacts, nil
+}
+
+// GetArtifact returns an artifact of a task with its content, or nil if the
+// task has no artifact with that ID.
+func (s *Repository) GetArtifact(ctx context.Context, taskID, artifactID string) (*models.Artifact, error) {
+	row, err := s.db.Queries.GetTaskArtifact(ctx, sqlc.GetTaskArtifactParams{
+		ID:     artifactID,
+		TaskID: taskID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get artifact: %w", err)
+	}
+	return &models.Artifact{
+		ID:        row.ID,
+		RunID:     row.RunID,
+		Path:      row.Path,
+		Content:   row.Content,
+		Version:   row.Version,
+		CreatedAt: row.CreatedAt,
+		UpdatedAt: row.UpdatedAt,
 	}, nil
 }
 
+// ListStaleWorkspaceTasks returns review/done/failed tasks whose diff was last
+// saved before cutoff. Tasks without a saved diff are never returned, so their
+// workspace is the only copy of the changes and must be kept.
+func (s *Repository) ListStaleWorkspaceTasks(ctx context.Context, cutoff time.Time) ([]string, error) {
+	rows, err := s.db.Queries.ListTasksWithStaleWorkspace(ctx, cutoff.UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ID
+	}
+	return ids, nil
+}
+
 // CreateAgentRun creates a new agent run.
 func (s *Repository) CreateAgentRun(ctx context.Context, taskID, prompt, agentBackend, provider, model string) (string, error) {
 	id := shortuuid.New()
@@ -490,6 +963,32 @@ func (s *Repository) UpdateAgentRunBackendSessionID(ctx context.Context, runID,
 	})
 }
 
+// UpdateAgentRunModel records the provider and model a run resolved to.
+func (s *Repository) UpdateAgentRunModel(ctx context.Context, runID, provider, model string) error {
+	return s.db.Queries.UpdateAgentRunModel(ctx, sqlc.UpdateAgentRunModelParams{
+		Provider: sql.NullString{String: provider, Valid: provider != ""},
+		Model:    sql.NullString{String: model, Valid: model != ""},
+		ID:       runID,
+	})
+}
+
+// UpdateAgentRunSystemPrompt records the system prompt a run was given.
+func (s *Repository) UpdateAgentRunSystemPrompt(ctx context.Context, runID, systemPrompt string) error {
+	return s.db.Queries.UpdateAgentRunSystemPrompt(ctx, sqlc.UpdateAgentRunSystemPromptParams{
+		SystemPrompt: sql.NullString{String: systemPrompt, Valid: systemPrompt != ""},
+		ID:           runID,
+	})
+}
+
+// UpdateAgentRunLimitReached records why a run was stopped by its iteration
+// or tool call limit.
+func (s *Repository) UpdateAgentRunLimitReached(ctx context.Context, runID, notice string) error {
+	return s.db.Queries.UpdateAgentRunLimitReached(ctx, sqlc.UpdateAgentRunLimitReachedParams{
+		LimitReached: sql.NullString{String: notice, Valid: notice != ""},
+		ID:           runID,
+	})
+}
+
 // GetLatestAgentRun retrieves the most recent agent run for a task.
 func (s *Repository) GetLatestAgentRun(ctx context.Context, taskID string) (*sqlc.AgentRun, error) {
 	run, err := s.db.Queries.GetLatestRun(ctx, taskID)
@@ -547,12 +1046,7 @@ func (s *Repository) GetAgentRunByBackendSessionID(ctx context.Context, backend,
 
 // ConvertMessagesToJSON converts sqlc.Message to JSON format for agent state restoration.
 func ConvertMessagesToJSON(messages []sqlc.Message) (string, error) {
-	type Message struct {
-		Role    string               `json:"role"`
-		Content []agent.ContentBlock `json:"content"`
-	}
-
-	result := make([]Message, 0, len(messages))
+	result := make([]agent.Message, 0, len(messages))
 	for _, msg := range messages {
 		var blocks []agent.ContentBlock
 		if strings.TrimSpace(msg.Parts) != "" && strings.TrimSpace(msg.Parts) != "[]" {
@@ -597,19 +1091,59 @@ func ConvertMessagesToJSON(messages []sqlc.Message) (string, error) {
 			}
 		}
 
-		result = append(result, Message{
+		result = append(result, agent.Message{
 			Role:    role,
 			Content: filtered,
 		})
 	}
 
-	jsonData, err := json.Marshal(result)
+	jsonData, err := json.Marshal(repairHistory(result))
 	if err != nil {
 		return "", fmt.Errorf("failed to marshal messages: %w", err)
 	}
 	return string(jsonData), nil
 }
 
+// repairHistory makes persisted messages valid as LLM input for a resumed
+// run. A run cut short leaves tool calls without results, which providers
+// reject, and a failed run leaves the user's intent without a reply, so
+// unanswered tool calls are dropped and consecutive same-role messages are
+// merged.
+func repairHistory(messages []agent.Message) []agent.Message {
+	used, answered := map[string]bool{}, map[string]bool{}
+	for _, msg := range messages {
+		for _, block := range msg.Content {
+			switch block.Type {
+			case "tool_use":
+				used[block.ID] = true
+			case "tool_result":
+				answered[block.ToolUseID] = true
+			}
+		}
+	}
+
+	result := make([]agent.Message, 0, len(messages))
+	for _, msg := range messages {
+		var content []agent.ContentBlock
+		for _, block := range msg.Content {
+			if (block.Type == "tool_use" && !answered[block.ID]) ||
+				(block.Type == "tool_result" && !used[block.ToolUseID]) {
+				continue
+			}
+			content = append(content, block)
+		}
+		if len(content) == 0 {
+			continue
+		}
+		if n := len(result); n > 0 && result[n-1].Role == msg.Role {
+			result[n-1].Content = append(result[n-1].Content, content...)
+			continue
+		}
+		result = append(result, agent.Message{Role: msg.Role, Content: content})
+	}
+	return result
+}
+
 // nullableInt64FromTime converts sql.NullTime to *int64.
 func nullableInt64FromTime(t sql.NullTime) *int64 {
 	if t.Valid {
@@ -619,6 +1153,416 @@ func nullableInt64FromTime(t sql.NullTime) *int64 {
 	return nil
 }
 
+// --- Issue Watch Operations ---
+
+// SetIssueWatch turns issue polling for a project on or off.
+func (s *Repository) SetIssueWatch(ctx context.Context, projectID string, enabled bool) error {
+	if !enabled {
+		return s.db.Queries.DisableIssueWatch(ctx, projectID)
+	}
+	return s.db.Queries.EnableIssueWatch(ctx, sqlc.EnableIssueWatchParams{
+		RepositoryID: projectID,
+		CreatedAt:    time.Now().UnixMilli(),
+	})
+}
+
+// ListWatchedRepositories returns the projects with issue polling enabled.
+func (s *Repository) ListWatchedRepositories(ctx context.Context) ([]sqlc.ListWatchedRepositoriesRow, error) {
+	return s.db.Queries.ListWatchedRepositories(ctx)
+}
+
+// HasIssueTask reports whether a task was already created, or is being
+// created, for an issue.
+func (s *Repository) HasIssueTask(ctx context.Context, projectID string, issueNumber int64) (bool, error) {
+	_, err := s.db.Queries.GetIssueTaskID(ctx, sqlc.GetIssueTaskIDParams{
+		RepositoryID: projectID,
+		IssueNumber:  issueNumber,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ClaimIssueTask reserves an issue before its task is started, so it gets
+// one task even if recording the task's ID fails. It returns false when the
+// issue was already claimed.
+func (s *Repository) ClaimIssueTask(ctx context.Context, projectID string, issueNumber int64) (bool, error) {
+	n, err := s.db.Queries.ClaimIssueTask(ctx, sqlc.ClaimIssueTaskParams{
+		RepositoryID: projectID,
+		IssueNumber:  issueNumber,
+		CreatedAt:    time.Now().UnixMilli(),
+	})
+	return n > 0, err
+}
+
+// RecordIssueTask links a claimed issue to the task created for it.
+func (s *Repository) RecordIssueTask(ctx context.Context, projectID string, issueNumber int64, taskID string) error {
+	return s.db.Queries.SetIssueTaskID(ctx, sqlc.SetIssueTaskIDParams{
+		TaskID:       taskID,
+		RepositoryID: projectID,
+		IssueNumber:  issueNumber,
+	})
+}
+
+// ReleaseIssueTask drops the claim on an issue whose task couldn't be
+// started, so a later poll tries again. Issues linked to a task are kept.
+func (s *Repository) ReleaseIssueTask(ctx context.Context, projectID string, issueNumber int64) error {
+	return s.db.Queries.ReleaseIssueTask(ctx, sqlc.ReleaseIssueTaskParams{
+		RepositoryID: projectID,
+		IssueNumber:  issueNumber,
+	})
+}
+
+// ListIssueTasksAwaitingComment returns issue tasks that have a PR whose
+// link wasn't posted back to the issue yet.
+func (s *Repository) ListIssueTasksAwaitingComment(ctx context.Context) ([]sqlc.ListIssueTasksAwaitingCommentRow, error) {
+	return s.db.Queries.ListIssueTasksAwaitingComment(ctx)
+}
+
+// MarkIssueCommented records that an issue's PR link was posted.
+func (s *Repository) MarkIssueCommented(ctx context.Context, projectID string, issueNumber int64) error {
+	return s.db.Queries.MarkIssueTaskCommented(ctx, sqlc.MarkIssueTaskCommentedParams{
+		CommentedAt:  sql.NullInt64{Int64: time.Now().UnixMilli(), Valid: true},
+		RepositoryID: projectID,
+		IssueNumber:  issueNumber,
+	})
+}
+
+// CountActiveTasks returns the number of pending, planning and running tasks.
+func (s *Repository) CountActiveTasks(ctx context.Context) (int64, error) {
+	return s.db.Queries.CountActiveTasks(ctx)
+}
+
+// --- Commit Status Operations ---
+
+// SetCommitStatuses turns GitHub commit statuses for a project's agent runs
+// on or off.
+func (s *Repository) SetCommitStatuses(ctx context.Context, projectID string, enabled bool) error {
+	if !enabled {
+		return s.db.Queries.DisableCommitStatuses(ctx, projectID)
+	}
+	return s.db.Queries.EnableCommitStatuses(ctx, sqlc.EnableCommitStatusesParams{
+		RepositoryID: projectID,
+		CreatedAt:    time.Now().UnixMilli(),
+	})
+}
+
+// CommitStatusesEnabled reports whether a project gets commit statuses.
+func (s *Repository) CommitStatusesEnabled(ctx context.Context, projectID string) (bool, error) {
+	n, err := s.db.Queries.CountCommitStatusRepository(ctx, projectID)
+	return n > 0, err
+}
+
+// ListCommitStatusProjects returns the IDs of projects with commit statuses
+// enabled.
+func (s *Repository) ListCommitStatusProjects(ctx context.Context) ([]string, error) {
+	return s.db.Queries.ListCommitStatusRepositoryIDs(ctx)
+}
+
+// --- Commit Signing Operations ---
+
+// SetCommitSigning turns signing of a project's agent commits on or off.
+func (s *Repository) SetCommitSigning(ctx context.Context, projectID string, enabled bool) error {
+	if !enabled {
+		return s.db.Queries.DisableCommitSigning(ctx, projectID)
+	}
+	return s.db.Queries.EnableCommitSigning(ctx, sqlc.EnableCommitSigningParams{
+		RepositoryID: projectID,
+		CreatedAt:    time.Now().UnixMilli(),
+	})
+}
+
+// CommitSigningEnabled reports whether a project's agent commits are signed.
+func (s *Repository) CommitSigningEnabled(ctx context.Context, projectID string) (bool, error) {
+	n, err := s.db.Queries.CountCommitSigningRepository(ctx, projectID)
+	return n > 0, err
+}
+
+// ListCommitSigningProjects returns the IDs of projects with commit signing
+// enabled.
+func (s *Repository) ListCommitSigningProjects(ctx context.Context) ([]string, error) {
+	return s.db.Queries.ListCommitSigningRepositoryIDs(ctx)
+}
+
+// --- Auto-merge Operations ---
+
+// SetAutoMerge turns merging of a project's task pull requests on green
+// checks on or off.
+func (s *Repository) SetAutoMerge(ctx context.Context, projectID string, enabled bool) error {
+	if !enabled {
+		return s.db.Queries.DisableAutoMerge(ctx, projectID)
+	}
+	return s.db.Queries.EnableAutoMerge(ctx, sqlc.EnableAutoMergeParams{
+		RepositoryID: projectID,
+		CreatedAt:    time.Now().UnixMilli(),
+	})
+}
+
+// AutoMergeEnabled reports whether a project's task pull requests are
+// merged once their checks pass.
+func (s *Repository) AutoMergeEnabled(ctx context.Context, projectID string) (bool, error) {
+	n, err := s.db.Queries.CountAutoMergeRepository(ctx, projectID)
+	return n > 0, err
+}
+
+// ListAutoMergeProjects returns the IDs of projects with auto-merge enabled.
+func (s *Repository) ListAutoMergeProjects(ctx context.Context) ([]string, error) {
+	return s.db.Queries.ListAutoMergeRepositoryIDs(ctx)
+}
+
+// --- Slack Operations ---
+
+// LinkSlackUser allows a Slack user to run slash commands.
+func (s *Repository) LinkSlackUser(ctx context.Context, teamID, userID, userName string) error {
+	return s.db.Queries.LinkSlackUser(ctx, sqlc.LinkSlackUserParams{
+		TeamID:    teamID,
+		UserID:    userID,
+		UserName:  userName,
+		CreatedAt: time.Now().UnixMilli(),
+	})
+}
+
+// UnlinkSlackUser revokes a Slack user's link. It reports whether the user
+// was linked.
+func (s *Repository) UnlinkSlackUser(ctx context.Context, teamID, userID string) (bool, error) {
+	n, err := s.db.Queries.UnlinkSlackUser(ctx, sqlc.UnlinkSlackUserParams{TeamID: teamID, UserID: userID})
+	return n > 0, err
+}
+
+// IsSlackUserLinked reports whether a Slack user may run slash commands.
+func (s *Repository) IsSlackUserLinked(ctx context.Context, teamID, userID string) (bool, error) {
+	_, err := s.db.Queries.GetSlackUser(ctx, sqlc.GetSlackUserParams{TeamID: teamID, UserID: userID})
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// FindRepositoriesByName returns the projects whose full name (owner/name)
+// or name is name, ignoring case.
+func (s *Repository) FindRepositoriesByName(ctx context.Context, name string) ([]sqlc.Repository, error) {
+	return s.db.Queries.FindRepositoriesByName(ctx, name)
+}
+
+// RecordSlackTask remembers where to report a task started from Slack.
+func (s *Repository) RecordSlackTask(ctx context.Context, taskID, responseURL string) error {
+	return s.db.Queries.CreateSlackTask(ctx, sqlc.CreateSlackTaskParams{
+		TaskID:         taskID,
+		ResponseUrl:    responseURL,
+		NotifiedStatus: "pending",
+		CreatedAt:      time.Now().UnixMilli(),
+	})
+}
+
+// ListSlackTaskUpdates returns Slack tasks that reached review, done or
+// failed since their status was last reported.
+func (s *Repository) ListSlackTaskUpdates(ctx context.Context) ([]sqlc.ListSlackTaskUpdatesRow, error) {
+	return s.db.Queries.ListSlackTaskUpdates(ctx)
+}
+
+// MarkSlackTaskNotified records the status last reported for a Slack task.
+func (s *Repository) MarkSlackTaskNotified(ctx context.Context, taskID, status string) error {
+	return s.db.Queries.MarkSlackTaskNotified(ctx, sqlc.MarkSlackTaskNotifiedParams{
+		NotifiedStatus: status,
+		TaskID:         taskID,
+	})
+}
+
+// DeleteSlackTask stops reporting a task to Slack.
+func (s *Repository) DeleteSlackTask(ctx context.Context, taskID string) error {
+	return s.db.Queries.DeleteSlackTask(ctx, taskID)
+}
+
+// --- Task Share Operations ---
+
+// Share link lifetimes: the default, and the longest a link may be asked for.
+const (
+	DefaultShareTTL = 7 * 24 * time.Hour
+	MaxShareTTL     = 30 * 24 * time.Hour
+)
+
+// ErrShareNotFound is returned for a share token that is unknown, expired
+// or revoked.
+var ErrShareNotFound = errors.New("share link not found or expired")
+
+// CreateTaskShare creates a read-only link to a task valid for ttl. The
+// returned share holds the token, which can't be recovered later.
+func (s *Repository) CreateTaskShare(ctx context.Context, taskID string, ttl time.Duration) (*models.TaskShare, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate share token: %w", err)
+	}
+	now := time.Now()
+	share := &models.TaskShare{
+		ID:        shortuuid.New(),
+		TaskID:    taskID,
+		Token:     base64.RawURLEncoding.EncodeToString(secret),
+		ExpiresAt: now.Add(ttl).UnixMilli(),
+		CreatedAt: now.UnixMilli(),
+	}
+	if err := s.db.Queries.CreateTaskShare(ctx, sqlc.CreateTaskShareParams{
+		ID:        share.ID,
+		TaskID:    taskID,
+		TokenHash: hashToken(share.Token),
+		ExpiresAt: share.ExpiresAt,
+		CreatedAt: share.CreatedAt,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to create share: %w", err)
+	}
+	return share, nil
+}
+
+// ListTaskShares returns a task's unexpired shares, newest first, without
+// their tokens.
+func (s *Repository) ListTaskShares(ctx context.Context, taskID string) ([]models.TaskShare, error) {
+	rows, err := s.db.Queries.ListTaskShares(ctx, sqlc.ListTaskSharesParams{
+		TaskID:    taskID,
+		ExpiresAt: time.Now().UnixMilli(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list shares: %w", err)
+	}
+	shares := make([]models.TaskShare, len(rows))
+	for i, row := range rows {
+		shares[i] = models.TaskShare{
+			ID:        row.ID,
+			TaskID:    row.TaskID,
+			ExpiresAt: row.ExpiresAt,
+			CreatedAt: row.CreatedAt,
+		}
+	}
+	return shares, nil
+}
+
+// RevokeTaskShare deletes a share of a task, reporting whether it existed.
+func (s *Repository) RevokeTaskShare(ctx context.Context, taskID, shareID string) (bool, error) {
+	n, err := s.db.Queries.DeleteTaskShare(ctx, sqlc.DeleteTaskShareParams{ID: shareID, TaskID: taskID})
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke share: %w", err)
+	}
+	return n > 0, nil
+}
+
+// ResolveTaskShare returns the ID of the task a share token gives access to,
+// or ErrShareNotFound.
+func (s *Repository) ResolveTaskShare(ctx context.Context, token string) (string, error) {
+	row, err := s.db.Queries.GetTaskShareByTokenHash(ctx, hashToken(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrShareNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve share: %w", err)
+	}
+	if time.Now().UnixMilli() >= row.ExpiresAt {
+		return "", ErrShareNotFound
+	}
+	return row.TaskID, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// --- API Token Operations ---
+
+// APITokenPrefix starts every API token, so a leaked one is recognizable
+// and middleware can tell it from other bearer credentials.
+const APITokenPrefix = "cs_"
+
+// ErrAPITokenInvalid is returned for an API token that is unknown or was
+// revoked.
+var ErrAPITokenInvalid = errors.New("invalid API token")
+
+// CreateAPIToken creates a named API token for userID. The returned token
+// holds the secret, which can't be recovered later.
+func (s *Repository) CreateAPIToken(ctx context.Context, userID, name string) (*models.APIToken, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate API token: %w", err)
+	}
+	token := &models.APIToken{
+		ID:        shortuuid.New(),
+		UserID:    userID,
+		Name:      name,
+		Token:     APITokenPrefix + base64.RawURLEncoding.EncodeToString(secret),
+		CreatedAt: time.Now().UnixMilli(),
+	}
+	if err := s.db.Queries.CreateAPIToken(ctx, sqlc.CreateAPITokenParams{
+		ID:        token.ID,
+		UserID:    userID,
+		Name:      name,
+		TokenHash: hashToken(token.Token),
+		CreatedAt: token.CreatedAt,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to create API token: %w", err)
+	}
+	return token, nil
+}
+
+// ListAPITokens returns userID's API tokens, newest first, without their
+// secrets.
+func (s *Repository) ListAPITokens(ctx context.Context, userID string) ([]models.APIToken, error) {
+	rows, err := s.db.Queries.ListAPITokens(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API tokens: %w", err)
+	}
+	tokens := make([]models.APIToken, len(rows))
+	for i, row := range rows {
+		tokens[i] = apiTokenFromRow(row)
+	}
+	return tokens, nil
+}
+
+// RevokeAPIToken deletes one of userID's API tokens, reporting whether it
+// existed.
+func (s *Repository) RevokeAPIToken(ctx context.Context, userID, tokenID string) (bool, error) {
+	n, err := s.db.Queries.DeleteAPIToken(ctx, sqlc.DeleteAPITokenParams{ID: tokenID, UserID: userID})
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke API token: %w", err)
+	}
+	return n > 0, nil
+}
+
+// ResolveAPIToken returns the API token matching secret and records that it
+// was used, or ErrAPITokenInvalid.
+func (s *Repository) ResolveAPIToken(ctx context.Context, secret string) (*models.APIToken, error) {
+	if !strings.HasPrefix(secret, APITokenPrefix) {
+		return nil, ErrAPITokenInvalid
+	}
+	row, err := s.db.Queries.GetAPITokenByHash(ctx, hashToken(secret))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAPITokenInvalid
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve API token: %w", err)
+	}
+	now := time.Now().UnixMilli()
+	if err := s.db.Queries.TouchAPIToken(ctx, sqlc.TouchAPITokenParams{
+		LastUsedAt: sql.NullInt64{Int64: now, Valid: true},
+		ID:         row.ID,
+	}); err != nil {
+		slog.Warn("failed to record API token use", "token_id", row.ID, "error", err)
+	}
+	token := apiTokenFromRow(row)
+	token.LastUsedAt = &now
+	return &token, nil
+}
+
+func apiTokenFromRow(row sqlc.ApiToken) models.APIToken {
+	token := models.APIToken{
+		ID:        row.ID,
+		UserID:    row.UserID,
+		Name:      row.Name,
+		CreatedAt: row.CreatedAt,
+	}
+	if row.LastUsedAt.Valid {
+		token.LastUsedAt = &row.LastUsedAt.Int64
+	}
+	return token
+}
+
 // --- Session Operations ---
 
 // CreateSession inserts a new session.
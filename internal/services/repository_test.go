@@ -2,10 +2,16 @@ package services
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/revrost/counterspell/internal/agent"
+	"github.com/revrost/counterspell/internal/agent/tools"
 	"github.com/revrost/counterspell/internal/db/sqlc"
+	"github.com/revrost/counterspell/internal/models"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -153,3 +159,403 @@ func TestGetLatestAgentRun_SessionID(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, sessionID1, firstRun.BackendSessionID.String, "First run should still have its original session ID")
 }
+
+// createTestTask creates a github connection, repository and task to satisfy FK constraints.
+func createTestTask(t *testing.T, repo *Repository) *models.Task {
+	t.Helper()
+	ctx := context.Background()
+
+	conn, err := repo.db.Queries.CreateGithubConnection(ctx, sqlc.CreateGithubConnectionParams{
+		ID:           "conn-1",
+		GithubUserID: "user-1",
+		AccessToken:  "token",
+		Username:     "testuser",
+	})
+	require.NoError(t, err)
+
+	repoRow, err := repo.db.Queries.CreateRepository(ctx, sqlc.CreateRepositoryParams{
+		ID:           "repo-1",
+		ConnectionID: conn.ID,
+		Name:         "test-repo",
+		FullName:     "test/test-repo",
+		Owner:        "test",
+	})
+	require.NoError(t, err)
+
+	task, err := repo.Create(ctx, repoRow.ID, "test task")
+	require.NoError(t, err)
+	return task
+}
+
+func TestSaveTodos_ReplacesLatestList(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	repo := NewRepository(testDB)
+	ctx := context.Background()
+	task := createTestTask(t, repo)
+
+	todos, err := repo.GetTodos(ctx, task.ID)
+	require.NoError(t, err)
+	assert.Empty(t, todos)
+
+	require.NoError(t, repo.SaveTodos(ctx, task.ID, []tools.TodoItem{
+		{Content: "write code", Status: tools.TodoStatusInProgress},
+		{Content: "write tests", Status: tools.TodoStatusPending},
+	}))
+	require.NoError(t, repo.SaveTodos(ctx, task.ID, []tools.TodoItem{
+		{Content: "write code", Status: tools.TodoStatusCompleted},
+	}))
+
+	todos, err = repo.GetTodos(ctx, task.ID)
+	require.NoError(t, err)
+	require.Len(t, todos, 1)
+	assert.Equal(t, tools.TodoStatusCompleted, todos[0].Status)
+}
+
+func TestListOpenTaskProgress(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	repo := NewRepository(testDB)
+	ctx := context.Background()
+	task := createTestTask(t, repo)
+	finished, err := repo.Create(ctx, "repo-1", "finished task")
+	require.NoError(t, err)
+	_, err = repo.ForceStatus(ctx, finished.ID, "done")
+	require.NoError(t, err)
+
+	// No todo list yet
+	progress, err := repo.ListOpenTaskProgress(ctx)
+	require.NoError(t, err)
+	require.Len(t, progress, 1)
+	assert.Equal(t, "pending", progress[task.ID].Status)
+	assert.Zero(t, progress[task.ID].TodosTotal)
+
+	_, err = repo.UpdateStatus(ctx, task.ID, "in_progress")
+	require.NoError(t, err)
+	require.NoError(t, repo.SaveTodos(ctx, task.ID, []tools.TodoItem{
+		{Content: "write code", Status: tools.TodoStatusCompleted},
+		{Content: "write tests", Status: tools.TodoStatusInProgress},
+	}))
+
+	progress, err = repo.ListOpenTaskProgress(ctx)
+	require.NoError(t, err)
+	got := progress[task.ID]
+	assert.Equal(t, "in_progress", got.Status)
+	assert.Equal(t, 1, got.TodosDone)
+	assert.Equal(t, 2, got.TodosTotal)
+	require.NotNil(t, got.RepositoryID)
+	assert.Equal(t, "repo-1", *got.RepositoryID)
+	assert.NotContains(t, progress, finished.ID)
+}
+
+func TestAppendLog_ReturnsOrderedHistory(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	repo := NewRepository(testDB)
+	ctx := context.Background()
+	task := createTestTask(t, repo)
+
+	_, err := repo.AppendLog(ctx, task.ID, LogLevelInfo, "Workspace ready")
+	require.NoError(t, err)
+	entry, err := repo.AppendLog(ctx, task.ID, LogLevelSuccess, "Ready for review")
+	require.NoError(t, err)
+	assert.NotZero(t, entry.Timestamp)
+
+	logs, err := repo.GetLogs(ctx, task.ID)
+	require.NoError(t, err)
+	require.Len(t, logs, 2)
+	assert.Equal(t, "Workspace ready", logs[0].Message)
+	assert.Equal(t, LogLevelSuccess, logs[1].Level)
+
+	details, err := repo.GetTaskWithDetails(ctx, task.ID)
+	require.NoError(t, err)
+	assert.Len(t, details.Logs, 2)
+}
+
+func TestUpdateStatusIfVersion_RejectsStaleWrite(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	repo := NewRepository(testDB)
+	ctx := context.Background()
+	task := createTestTask(t, repo)
+
+	// A run starts and records the version it observed.
+	runVersion, err := repo.UpdateStatus(ctx, task.ID, "in_progress")
+	require.NoError(t, err)
+	assert.Equal(t, task.Version+1, runVersion)
+
+	// A newer run takes over while the first is still shutting down.
+	_, err = repo.UpdateStatus(ctx, task.ID, "in_progress")
+	require.NoError(t, err)
+
+	// The first run's late failure must not clobber the newer run.
+	_, err = repo.UpdateStatusIfVersion(ctx, task.ID, "failed", runVersion)
+	var conflict ErrStatusConflict
+	require.ErrorAs(t, err, &conflict)
+	assert.Equal(t, "failed", conflict.Status)
+
+	got, err := repo.Get(ctx, task.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "in_progress", got.Status)
+
+	// A write at the current version goes through.
+	_, err = repo.UpdateStatusIfVersion(ctx, task.ID, "review", got.Version)
+	require.NoError(t, err)
+
+	_, err = repo.UpdateStatusIfVersion(ctx, "missing", "review", 1)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestUpdateStatus_EnforcesTransitions(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	repo := NewRepository(testDB)
+	ctx := context.Background()
+	task := createTestTask(t, repo)
+
+	for _, status := range []string{"in_progress", "review", "in_progress", "review", "done"} {
+		_, err := repo.UpdateStatus(ctx, task.ID, status)
+		require.NoError(t, err, "-> %s", status)
+	}
+
+	_, err := repo.UpdateStatus(ctx, task.ID, "in_progress")
+	var invalid ErrInvalidTransition
+	require.ErrorAs(t, err, &invalid)
+	assert.Equal(t, "done", invalid.From)
+
+	// Recovery paths can still force a status.
+	_, err = repo.ForceStatus(ctx, task.ID, "failed")
+	require.NoError(t, err)
+	_, err = repo.UpdateStatus(ctx, task.ID, "review")
+	require.ErrorAs(t, err, &invalid)
+}
+
+func TestResetInProgress(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	repo := NewRepository(testDB)
+	ctx := context.Background()
+	task := createTestTask(t, repo)
+	_, err := repo.UpdateStatus(ctx, task.ID, "in_progress")
+	require.NoError(t, err)
+
+	n, err := repo.ResetInProgress(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	got, err := repo.Get(ctx, task.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "failed", got.Status)
+}
+
+func TestCostSummary_GroupsRunsAndReportsZeros(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	repo := NewRepository(testDB)
+	ctx := context.Background()
+	task := createTestTask(t, repo)
+	orphan, err := repo.Create(ctx, "", "no project")
+	require.NoError(t, err)
+
+	run1, err := repo.CreateAgentRun(ctx, task.ID, "a", "native", "anthropic", "claude-opus-4-5")
+	require.NoError(t, err)
+	_, err = repo.CreateAgentRun(ctx, task.ID, "b", "native", "anthropic", "claude-opus-4-5")
+	require.NoError(t, err)
+	_, err = repo.CreateAgentRun(ctx, orphan.ID, "c", "claude-code", "zai", "glm-4.7")
+	require.NoError(t, err)
+	_, err = testDB.DB.ExecContext(ctx, "UPDATE agent_runs SET cost = 1.5, prompt_tokens = 100, completion_tokens = 20 WHERE id = ?", run1)
+	require.NoError(t, err)
+
+	buckets, err := repo.CostSummary(ctx, time.Now().Add(-time.Hour), CostGroupProject)
+	require.NoError(t, err)
+	require.Len(t, buckets, 2)
+	assert.Equal(t, "test/test-repo", buckets[0].Label)
+	assert.Equal(t, int64(2), buckets[0].RunCount)
+	assert.Equal(t, 1.5, buckets[0].Cost)
+	assert.Equal(t, int64(100), buckets[0].PromptTokens)
+	assert.Equal(t, "(no project)", buckets[1].Label)
+	assert.Equal(t, 0.0, buckets[1].Cost)
+
+	buckets, err = repo.CostSummary(ctx, time.Now().Add(-time.Hour), CostGroupModel)
+	require.NoError(t, err)
+	require.Len(t, buckets, 2)
+	assert.Equal(t, "anthropic/claude-opus-4-5", buckets[0].Key)
+
+	buckets, err = repo.CostSummary(ctx, time.Now().Add(time.Hour), CostGroupProject)
+	require.NoError(t, err)
+	assert.Empty(t, buckets)
+}
+
+func TestUpdateTaskPRURL(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	repo := NewRepository(testDB)
+	ctx := context.Background()
+
+	task, err := repo.Create(ctx, "", "open a pr")
+	require.NoError(t, err)
+	assert.Nil(t, task.PRURL)
+
+	require.NoError(t, repo.UpdateTaskPRURL(ctx, task.ID, "https://github.com/o/r/pull/7"))
+
+	got, err := repo.Get(ctx, task.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got.PRURL)
+	assert.Equal(t, "https://github.com/o/r/pull/7", *got.PRURL)
+
+	tasks, err := repo.ListWithRepository(ctx)
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	require.NotNil(t, tasks[0].PRURL)
+	assert.Equal(t, "https://github.com/o/r/pull/7", *tasks[0].PRURL)
+}
+
+func TestConvertMessagesToJSON_RepairsHistoryForResume(t *testing.T) {
+	msg := func(role, parts string) sqlc.Message {
+		return sqlc.Message{Role: role, Parts: parts}
+	}
+	messages := []sqlc.Message{
+		{Role: "user", Content: "fix the build"},
+		msg("assistant", `[{"type":"text","text":"Looking"},{"type":"tool_use","id":"t1","name":"read"},{"type":"tool_use","id":"t2","name":"ls"}]`),
+		msg("tool", `[{"type":"tool_result","tool_use_id":"t1","content":"a"}]`),
+		msg("tool", `[{"type":"tool_result","tool_use_id":"t2","content":"b"}]`),
+		msg("assistant", `[{"type":"thinking","text":"hmm"}]`),
+		// The run was cut short before this call returned
+		msg("assistant", `[{"type":"tool_use","id":"t3","name":"bash"}]`),
+		{Role: "user", Content: "try again"},
+	}
+
+	historyJSON, err := ConvertMessagesToJSON(messages)
+	require.NoError(t, err)
+	var history []agent.Message
+	require.NoError(t, json.Unmarshal([]byte(historyJSON), &history))
+
+	require.Len(t, history, 3)
+	assert.Equal(t, "user", history[0].Role)
+	assert.Equal(t, "assistant", history[1].Role)
+	assert.Len(t, history[1].Content, 3)
+	// Parallel tool results go back in one user message. The unanswered
+	// call and the thinking-only turn are dropped, so the follow-up joins it.
+	assert.Equal(t, "user", history[2].Role)
+	require.Len(t, history[2].Content, 3)
+	assert.Equal(t, "t1", history[2].Content[0].ToolUseID)
+	assert.Equal(t, "t2", history[2].Content[1].ToolUseID)
+	assert.Equal(t, "try again", history[2].Content[2].Text)
+}
+
+func TestTaskShares(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+	repo := NewRepository(testDB)
+	ctx := context.Background()
+
+	task, err := repo.Create(ctx, "", "share me")
+	require.NoError(t, err)
+	other, err := repo.Create(ctx, "", "private")
+	require.NoError(t, err)
+
+	share, err := repo.CreateTaskShare(ctx, task.ID, time.Hour)
+	require.NoError(t, err)
+	require.NotEmpty(t, share.Token)
+
+	taskID, err := repo.ResolveTaskShare(ctx, share.Token)
+	require.NoError(t, err)
+	assert.Equal(t, task.ID, taskID)
+	_, err = repo.ResolveTaskShare(ctx, share.Token+"x")
+	assert.ErrorIs(t, err, ErrShareNotFound)
+
+	// Listing never gives the token back
+	shares, err := repo.ListTaskShares(ctx, task.ID)
+	require.NoError(t, err)
+	require.Len(t, shares, 1)
+	assert.Empty(t, shares[0].Token)
+
+	// A share can only be revoked through its own task
+	revoked, err := repo.RevokeTaskShare(ctx, other.ID, share.ID)
+	require.NoError(t, err)
+	assert.False(t, revoked)
+	revoked, err = repo.RevokeTaskShare(ctx, task.ID, share.ID)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+	_, err = repo.ResolveTaskShare(ctx, share.Token)
+	assert.ErrorIs(t, err, ErrShareNotFound)
+
+	// Expired links stop resolving and drop off the list
+	expired, err := repo.CreateTaskShare(ctx, task.ID, -time.Minute)
+	require.NoError(t, err)
+	_, err = repo.ResolveTaskShare(ctx, expired.Token)
+	assert.ErrorIs(t, err, ErrShareNotFound)
+	shares, err = repo.ListTaskShares(ctx, task.ID)
+	require.NoError(t, err)
+	assert.Empty(t, shares)
+}
+
+func TestAPITokens(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+	repo := NewRepository(testDB)
+	ctx := context.Background()
+
+	token, err := repo.CreateAPIToken(ctx, "default", "ci")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(token.Token, APITokenPrefix))
+
+	resolved, err := repo.ResolveAPIToken(ctx, token.Token)
+	require.NoError(t, err)
+	assert.Equal(t, token.ID, resolved.ID)
+	assert.Equal(t, "default", resolved.UserID)
+	_, err = repo.ResolveAPIToken(ctx, token.Token+"x")
+	assert.ErrorIs(t, err, ErrAPITokenInvalid)
+
+	// Listing never gives the secret back, but shows when it was last used
+	tokens, err := repo.ListAPITokens(ctx, "default")
+	require.NoError(t, err)
+	require.Len(t, tokens, 1)
+	assert.Empty(t, tokens[0].Token)
+	assert.NotNil(t, tokens[0].LastUsedAt)
+
+	// A token can only be revoked by its owner
+	revoked, err := repo.RevokeAPIToken(ctx, "someone-else", token.ID)
+	require.NoError(t, err)
+	assert.False(t, revoked)
+	revoked, err = repo.RevokeAPIToken(ctx, "default", token.ID)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+	_, err = repo.ResolveAPIToken(ctx, token.Token)
+	assert.ErrorIs(t, err, ErrAPITokenInvalid)
+}
+
+func TestGetMessagesByRun(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	repo := NewRepository(testDB)
+	ctx := context.Background()
+	task := createTestTask(t, repo)
+
+	first, err := repo.CreateAgentRun(ctx, task.ID, "first", "native", "anthropic", "claude-3")
+	require.NoError(t, err)
+	second, err := repo.CreateAgentRun(ctx, task.ID, "second", "native", "anthropic", "claude-3")
+	require.NoError(t, err)
+	require.NoError(t, repo.CreateMessage(ctx, task.ID, first, "user", "first run"))
+	require.NoError(t, repo.CreateMessage(ctx, task.ID, second, "user", "second run"))
+
+	messages, err := repo.GetMessagesByRun(ctx, first)
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	assert.Equal(t, "first run", messages[0].Content)
+	require.NotNil(t, messages[0].RunID)
+	assert.Equal(t, first, *messages[0].RunID)
+
+	messages, err = repo.GetMessagesByRun(ctx, "missing")
+	require.NoError(t, err)
+	assert.Empty(t, messages)
+}
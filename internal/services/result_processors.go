@@ -0,0 +1,98 @@
+package services
+
+import (
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// queuedResult is a run result waiting for its processor.
+type queuedResult struct {
+	TaskResult
+	queuedAt time.Time
+}
+
+// resultStats tracks how far the result processors are behind.
+type resultStats struct {
+	processed atomic.Int64
+	lastLag   atomic.Int64 // nanoseconds
+	maxLag    atomic.Int64
+}
+
+func (s *resultStats) record(lag time.Duration) {
+	s.processed.Add(1)
+	s.lastLag.Store(int64(lag))
+	for {
+		current := s.maxLag.Load()
+		if int64(lag) <= current || s.maxLag.CompareAndSwap(current, int64(lag)) {
+			return
+		}
+	}
+}
+
+// ResultProcessorStats reports the backlog of finished runs not yet recorded.
+type ResultProcessorStats struct {
+	Processors int   `json:"processors"`
+	Queued     int   `json:"queued"`      // results waiting for a processor
+	Processed  int64 `json:"processed"`   // since startup
+	LastLagMS  int64 `json:"last_lag_ms"` // wait of the latest result processed
+	MaxLagMS   int64 `json:"max_lag_ms"`  // longest wait since startup
+}
+
+// startResultProcessors starts the goroutines recording run results. A
+// dispatcher hands each result from resultCh to the processor its task ID
+// hashes to, so one task's results never race each other. Closing resultCh
+// drains every processor, then closes resultsDone.
+func (o *Orchestrator) startResultProcessors() {
+	var wg sync.WaitGroup
+	o.resultQueues = make([]chan queuedResult, o.resultProcessors)
+	for i := range o.resultQueues {
+		queue := make(chan queuedResult, cap(o.resultCh))
+		o.resultQueues[i] = queue
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for queued := range queue {
+				lag := time.Since(queued.queuedAt)
+				o.processResult(queued.TaskResult)
+				o.resultStats.record(lag)
+			}
+		}()
+	}
+
+	o.resultsDone = make(chan struct{})
+	go func() {
+		for result := range o.resultCh {
+			queue := o.resultQueues[resultQueueIndex(result.TaskID, len(o.resultQueues))]
+			queue <- queuedResult{TaskResult: result, queuedAt: time.Now()}
+		}
+		for _, queue := range o.resultQueues {
+			close(queue)
+		}
+		wg.Wait()
+		close(o.resultsDone)
+	}()
+}
+
+// resultQueueIndex picks the processor for a task's results.
+func resultQueueIndex(taskID string, n int) int {
+	h := fnv.New32a()
+	h.Write([]byte(taskID))
+	return int(h.Sum32() % uint32(n))
+}
+
+// ResultProcessorStats returns the result processors' backlog and lag.
+func (o *Orchestrator) ResultProcessorStats() ResultProcessorStats {
+	queued := len(o.resultCh)
+	for _, queue := range o.resultQueues {
+		queued += len(queue)
+	}
+	return ResultProcessorStats{
+		Processors: len(o.resultQueues),
+		Queued:     queued,
+		Processed:  o.resultStats.processed.Load(),
+		LastLagMS:  time.Duration(o.resultStats.lastLag.Load()).Milliseconds(),
+		MaxLagMS:   time.Duration(o.resultStats.maxLag.Load()).Milliseconds(),
+	}
+}
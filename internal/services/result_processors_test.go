@@ -0,0 +1,54 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResultProcessors_ShutdownDrains(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	orch, err := NewOrchestrator(NewRepository(testDB), NewEventBus(), nil, nil, stubRepoManager{},
+		WithResultProcessors(3))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	var taskIDs []string
+	for range 10 {
+		task, err := orch.repo.Create(ctx, "", "fix the bug")
+		require.NoError(t, err)
+		_, err = orch.repo.UpdateStatus(ctx, task.ID, "in_progress")
+		require.NoError(t, err)
+		taskIDs = append(taskIDs, task.ID)
+	}
+	for _, taskID := range taskIDs {
+		orch.resultCh <- TaskResult{TaskID: taskID, Success: true}
+	}
+
+	// Every queued result is recorded before Shutdown returns
+	orch.Shutdown()
+	for _, taskID := range taskIDs {
+		task, err := orch.repo.Get(ctx, taskID)
+		require.NoError(t, err)
+		assert.Equal(t, "review", task.Status)
+	}
+	stats := orch.ResultProcessorStats()
+	assert.Equal(t, 3, stats.Processors)
+	assert.Equal(t, int64(len(taskIDs)), stats.Processed)
+	assert.Zero(t, stats.Queued)
+}
+
+func TestResultQueueIndex(t *testing.T) {
+	// A task always maps to the same processor
+	for _, taskID := range []string{"a", "task-1", "3xYkq"} {
+		index := resultQueueIndex(taskID, 4)
+		assert.Equal(t, index, resultQueueIndex(taskID, 4))
+		assert.GreaterOrEqual(t, index, 0)
+		assert.Less(t, index, 4)
+	}
+	assert.Zero(t, resultQueueIndex("task-1", 1))
+}
@@ -208,7 +208,17 @@ func (s *SessionService) Promote(ctx context.Context, sessionID string) (*models
 
 func (s *SessionService) summarizeSession(ctx context.Context, session *models.Session, messages []models.SessionMessage) (string, string, error) {
 	prompt := buildSummaryPrompt(messages)
-	backend, cleanup, err := s.buildBackend(ctx, session, "", false)
+
+	// A configured summary model answers directly through the native backend:
+	// a summary needs no tools, and the session's CLI may not take the model.
+	summarySession := session
+	modelID := s.settings.SummaryModel(ctx)
+	if modelID != "" {
+		native := *session
+		native.AgentBackend = "native"
+		summarySession = &native
+	}
+	backend, cleanup, err := s.buildBackend(ctx, summarySession, modelID, false)
 	if err != nil {
 		return "", "", err
 	}
@@ -299,23 +309,9 @@ func (s *SessionService) buildBackend(
 }
 
 func (s *SessionService) resolveProvider(ctx context.Context, modelID string) (string, string, string, error) {
-	provider := ""
-	model := ""
-
-	if modelID != "" {
-		parts := strings.SplitN(modelID, "#", 2)
-		if len(parts) == 2 {
-			provider = parts[0]
-			model = parts[1]
-			switch provider {
-			case "o":
-				provider = "openrouter"
-			case "zai":
-				provider = "zai"
-			}
-		} else {
-			model = parts[0]
-		}
+	provider, model, err := llm.ParseModelID(modelID)
+	if err != nil {
+		return "", "", "", err
 	}
 
 	apiKey, actualProvider, actualModel, err := s.settings.GetAPIKeyForProvider(ctx, provider)
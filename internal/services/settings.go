@@ -3,9 +3,11 @@ package services
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
+	"regexp"
 	"slices"
 	"strings"
 	"time"
@@ -21,14 +23,80 @@ type SettingsService struct {
 
 // Settings represents application settings.
 type Settings struct {
-	OpenRouterKey string    `json:"openrouter_key,omitempty"`
-	ZaiKey        string    `json:"zai_key,omitempty"`
-	AnthropicKey  string    `json:"anthropic_key,omitempty"`
-	OpenAIKey     string    `json:"openai_key,omitempty"`
-	AgentBackend  string    `json:"agent_backend"` // "native", "claude-code", "codex"
-	Provider      *string   `json:"provider"`      // "anthropic", "openrouter", etc.
-	Model         *string   `json:"model"`         // "claude-opus-4-5", etc.
-	UpdatedAt     time.Time `json:"updated_at"`
+	OpenRouterKey string  `json:"openrouter_key,omitempty"`
+	ZaiKey        string  `json:"zai_key,omitempty"`
+	AnthropicKey  string  `json:"anthropic_key,omitempty"`
+	OpenAIKey     string  `json:"openai_key,omitempty"`
+	AgentBackend  string  `json:"agent_backend"` // "native", "claude-code", "codex"
+	Provider      *string `json:"provider"`      // "anthropic", "openrouter", etc.
+	Model         *string `json:"model"`         // "claude-opus-4-5", etc.
+	// FallbackModels are tried in order when the primary model fails with a
+	// transient error (overloaded, rate limited). Native backend only. When
+	// saving, nil keeps the stored chain and an empty list clears it.
+	FallbackModels []FallbackModel `json:"fallback_models"`
+	// ProjectEnv maps a project ID to extra environment variables for agent
+	// processes working on that project. When saving, nil keeps the stored
+	// value and an empty map clears it.
+	ProjectEnv map[string]map[string]string `json:"project_env,omitempty"`
+	// SummaryModel ("provider#model", like a task's model ID) runs auxiliary
+	// LLM calls such as session summaries, so a cheap model can handle them
+	// while an expensive one does the coding. Empty uses the task model.
+	SummaryModel string `json:"summary_model"`
+	// AutoResolveConflicts lets the LLM attempt merge conflict resolution
+	// when asked; results are staged for review, never committed.
+	AutoResolveConflicts bool `json:"auto_resolve_conflicts"`
+	// ShowThinking keeps the agent's thinking and reasoning blocks and shows
+	// them, de-emphasized, in the task thread. Off drops Codex reasoning and
+	// hides thinking blocks.
+	ShowThinking bool `json:"show_thinking"`
+	// MaxIterations and MaxToolCalls stop a native agent run after that many
+	// LLM calls or tool calls. Zero uses the agent's defaults.
+	MaxIterations int `json:"max_iterations"`
+	MaxToolCalls  int `json:"max_tool_calls"`
+	// CommitAuthorName and CommitAuthorEmail are the author and committer of
+	// agent commits. Empty uses DefaultCommitIdentity.
+	CommitAuthorName  string    `json:"commit_author_name"`
+	CommitAuthorEmail string    `json:"commit_author_email"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// LogValue keeps API keys and project env values out of logs.
+func (s *Settings) LogValue() slog.Value {
+	envCount := 0
+	for _, env := range s.ProjectEnv {
+		envCount += len(env)
+	}
+	attrs := []slog.Attr{
+		slog.String("agent_backend", s.AgentBackend),
+		slog.Int("fallback_models", len(s.FallbackModels)),
+		slog.Int("project_env_vars", envCount),
+		slog.Bool("auto_resolve_conflicts", s.AutoResolveConflicts),
+		slog.Bool("show_thinking", s.ShowThinking),
+		slog.Int("max_iterations", s.MaxIterations),
+		slog.Int("max_tool_calls", s.MaxToolCalls),
+	}
+	if s.CommitAuthorName != "" || s.CommitAuthorEmail != "" {
+		attrs = append(attrs, slog.String("commit_author", CommitIdentity{Name: s.CommitAuthorName, Email: s.CommitAuthorEmail}.String()))
+	}
+	if s.Provider != nil {
+		attrs = append(attrs, slog.String("provider", *s.Provider))
+	}
+	if s.Model != nil {
+		attrs = append(attrs, slog.String("model", *s.Model))
+	}
+	if s.SummaryModel != "" {
+		attrs = append(attrs, slog.String("summary_model", s.SummaryModel))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// envNamePattern matches portable environment variable names.
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// FallbackModel is one entry of the provider fallback chain.
+type FallbackModel struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
 }
 
 // NewSettingsService creates a new Settings service.
@@ -46,18 +114,121 @@ func (s *SettingsService) GetSettings(ctx context.Context) (*Settings, error) {
 		return nil, fmt.Errorf("failed to get settings: %w", err)
 	}
 
+	fallbacks := []FallbackModel{}
+	if row.FallbackModels.Valid && row.FallbackModels.String != "" {
+		if err := json.Unmarshal([]byte(row.FallbackModels.String), &fallbacks); err != nil {
+			slog.Warn("ignoring malformed fallback_models setting", "error", err)
+		}
+	}
+
+	var projectEnv map[string]map[string]string
+	if row.ProjectEnv.Valid && row.ProjectEnv.String != "" {
+		if err := json.Unmarshal([]byte(row.ProjectEnv.String), &projectEnv); err != nil {
+			slog.Warn("ignoring malformed project_env setting", "error", err)
+		}
+	}
+
 	return &Settings{
-		OpenRouterKey: row.OpenrouterKey.String,
-		ZaiKey:        row.ZaiKey.String,
-		AnthropicKey:  row.AnthropicKey.String,
-		OpenAIKey:     row.OpenaiKey.String,
-		AgentBackend:  row.AgentBackend,
-		Provider:      &row.Provider,
-		Model:         &row.Model,
-		UpdatedAt:     time.UnixMilli(row.UpdatedAt),
+		OpenRouterKey:        row.OpenrouterKey.String,
+		ZaiKey:               row.ZaiKey.String,
+		AnthropicKey:         row.AnthropicKey.String,
+		OpenAIKey:            row.OpenaiKey.String,
+		AgentBackend:         row.AgentBackend,
+		Provider:             &row.Provider,
+		Model:                &row.Model,
+		FallbackModels:       fallbacks,
+		ProjectEnv:           projectEnv,
+		SummaryModel:         row.SummaryModel.String,
+		AutoResolveConflicts: row.AutoResolveConflicts != 0,
+		ShowThinking:         row.ShowThinking != 0,
+		MaxIterations:        int(row.MaxIterations),
+		MaxToolCalls:         int(row.MaxToolCalls),
+		CommitAuthorName:     row.CommitAuthorName.String,
+		CommitAuthorEmail:    row.CommitAuthorEmail.String,
+		UpdatedAt:            time.UnixMilli(row.UpdatedAt),
 	}, nil
 }
 
+// ProjectEnv returns the extra environment for agents working on projectID
+// as sorted NAME=value pairs, or nil when none is configured.
+func (s *SettingsService) ProjectEnv(ctx context.Context, projectID string) []string {
+	if projectID == "" {
+		return nil
+	}
+	settings, err := s.GetSettings(ctx)
+	if err != nil || settings == nil {
+		return nil
+	}
+	vars := settings.ProjectEnv[projectID]
+	env := make([]string, 0, len(vars))
+	for name, value := range vars {
+		env = append(env, name+"="+value)
+	}
+	slices.Sort(env)
+	return env
+}
+
+// SummaryModel returns the model ID for auxiliary LLM calls, or "" to use
+// the task model.
+func (s *SettingsService) SummaryModel(ctx context.Context) string {
+	settings, err := s.GetSettings(ctx)
+	if err != nil || settings == nil {
+		return ""
+	}
+	return strings.TrimSpace(settings.SummaryModel)
+}
+
+// AutoResolveConflicts reports whether LLM conflict resolution is enabled.
+func (s *SettingsService) AutoResolveConflicts(ctx context.Context) bool {
+	settings, err := s.GetSettings(ctx)
+	if err != nil || settings == nil {
+		return false
+	}
+	return settings.AutoResolveConflicts
+}
+
+// ShowThinking reports whether agent thinking and reasoning is kept and
+// shown.
+func (s *SettingsService) ShowThinking(ctx context.Context) bool {
+	settings, err := s.GetSettings(ctx)
+	if err != nil || settings == nil {
+		return false
+	}
+	return settings.ShowThinking
+}
+
+// IterationLimits returns the configured per-run LLM call and tool call
+// limits for the native agent; zero means the agent's default.
+func (s *SettingsService) IterationLimits(ctx context.Context) (maxIterations, maxToolCalls int) {
+	settings, err := s.GetSettings(ctx)
+	if err != nil || settings == nil {
+		return 0, 0
+	}
+	return settings.MaxIterations, settings.MaxToolCalls
+}
+
+// CommitIdentity returns the author and committer for agent commits,
+// filling unset fields from DefaultCommitIdentity.
+func (s *SettingsService) CommitIdentity(ctx context.Context) CommitIdentity {
+	settings, err := s.GetSettings(ctx)
+	if err != nil || settings == nil {
+		return DefaultCommitIdentity
+	}
+	return CommitIdentity{
+		Name:  strings.TrimSpace(settings.CommitAuthorName),
+		Email: strings.TrimSpace(settings.CommitAuthorEmail),
+	}.orDefault()
+}
+
+// AgentBackend returns the configured agent backend, defaulting to "native".
+func (s *SettingsService) AgentBackend(ctx context.Context) string {
+	settings, err := s.GetSettings(ctx)
+	if err != nil || settings == nil || settings.AgentBackend == "" {
+		return "native"
+	}
+	return settings.AgentBackend
+}
+
 // UpdateSettings updates settings with validation.
 func (s *SettingsService) UpdateSettings(ctx context.Context, settings *Settings) error {
 	// Validate settings
@@ -75,17 +246,44 @@ func (s *SettingsService) UpdateSettings(ctx context.Context, settings *Settings
 		model = *settings.Model
 	}
 
+	fallbacks, err := s.encodeFallbackModels(ctx, settings.FallbackModels)
+	if err != nil {
+		return err
+	}
+
+	projectEnv, err := s.encodeProjectEnv(ctx, settings.ProjectEnv)
+	if err != nil {
+		return err
+	}
+
+	var autoResolve, showThinking int64
+	if settings.AutoResolveConflicts {
+		autoResolve = 1
+	}
+	if settings.ShowThinking {
+		showThinking = 1
+	}
+
 	slog.Info("upserting settings", slog.String("provider", provider), slog.String("model", model), "settings", settings)
 
-	err := s.db.Queries.UpsertSettings(ctx, sqlc.UpsertSettingsParams{
-		OpenrouterKey: sql.NullString{String: settings.OpenRouterKey, Valid: settings.OpenRouterKey != ""},
-		ZaiKey:        sql.NullString{String: settings.ZaiKey, Valid: settings.ZaiKey != ""},
-		AnthropicKey:  sql.NullString{String: settings.AnthropicKey, Valid: settings.AnthropicKey != ""},
-		OpenaiKey:     sql.NullString{String: settings.OpenAIKey, Valid: settings.OpenAIKey != ""},
-		AgentBackend:  settings.AgentBackend,
-		Provider:      sql.NullString{String: provider, Valid: provider != ""},
-		Model:         sql.NullString{String: model, Valid: model != ""},
-		UpdatedAt:     time.Now().UnixMilli(),
+	err = s.db.Queries.UpsertSettings(ctx, sqlc.UpsertSettingsParams{
+		OpenrouterKey:        sql.NullString{String: settings.OpenRouterKey, Valid: settings.OpenRouterKey != ""},
+		ZaiKey:               sql.NullString{String: settings.ZaiKey, Valid: settings.ZaiKey != ""},
+		AnthropicKey:         sql.NullString{String: settings.AnthropicKey, Valid: settings.AnthropicKey != ""},
+		OpenaiKey:            sql.NullString{String: settings.OpenAIKey, Valid: settings.OpenAIKey != ""},
+		AgentBackend:         settings.AgentBackend,
+		Provider:             sql.NullString{String: provider, Valid: provider != ""},
+		Model:                sql.NullString{String: model, Valid: model != ""},
+		FallbackModels:       fallbacks,
+		ProjectEnv:           projectEnv,
+		SummaryModel:         sql.NullString{String: settings.SummaryModel, Valid: settings.SummaryModel != ""},
+		AutoResolveConflicts: autoResolve,
+		ShowThinking:         showThinking,
+		MaxIterations:        int64(settings.MaxIterations),
+		MaxToolCalls:         int64(settings.MaxToolCalls),
+		CommitAuthorName:     sql.NullString{String: settings.CommitAuthorName, Valid: settings.CommitAuthorName != ""},
+		CommitAuthorEmail:    sql.NullString{String: settings.CommitAuthorEmail, Valid: settings.CommitAuthorEmail != ""},
+		UpdatedAt:            time.Now().UnixMilli(),
 	})
 	if err != nil {
 		return fmt.Errorf("failed to update settings: %w", err)
@@ -93,6 +291,47 @@ func (s *SettingsService) UpdateSettings(ctx context.Context, settings *Settings
 	return nil
 }
 
+// encodeFallbackModels returns the fallback_models column for fallbacks. nil
+// keeps the stored value, so clients that don't send the chain don't wipe
+// it.
+func (s *SettingsService) encodeFallbackModels(ctx context.Context, fallbacks []FallbackModel) (sql.NullString, error) {
+	if fallbacks == nil {
+		row, err := s.db.Queries.GetSettings(ctx)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return sql.NullString{}, fmt.Errorf("failed to get settings: %w", err)
+		}
+		return row.FallbackModels, nil
+	}
+	if len(fallbacks) == 0 {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(fallbacks)
+	if err != nil {
+		return sql.NullString{}, fmt.Errorf("failed to encode fallback models: %w", err)
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+// encodeProjectEnv returns the project_env column for env. nil keeps the
+// stored value, so clients that don't know about project env don't wipe it.
+func (s *SettingsService) encodeProjectEnv(ctx context.Context, env map[string]map[string]string) (sql.NullString, error) {
+	if env == nil {
+		row, err := s.db.Queries.GetSettings(ctx)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return sql.NullString{}, fmt.Errorf("failed to get settings: %w", err)
+		}
+		return row.ProjectEnv, nil
+	}
+	if len(env) == 0 {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(env)
+	if err != nil {
+		return sql.NullString{}, fmt.Errorf("failed to encode project env: %w", err)
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
 // ValidateSettings validates settings values.
 func (s *SettingsService) ValidateSettings(settings *Settings) error {
 	// Validate agent backend
@@ -110,6 +349,40 @@ func (s *SettingsService) ValidateSettings(settings *Settings) error {
 		return fmt.Errorf("invalid provider: %s (must be one of: %s)", *settings.Provider, strings.Join(validProviders, ", "))
 	}
 
+	// Validate fallback chain. OpenAI is excluded: the native backend has no
+	// OpenAI provider to fall back to.
+	fallbackProviders := []string{"anthropic", "openrouter", "zai"}
+	for i, fb := range settings.FallbackModels {
+		if !slices.Contains(fallbackProviders, fb.Provider) {
+			return fmt.Errorf("invalid fallback_models[%d].provider: %s (must be one of: %s)", i, fb.Provider, strings.Join(fallbackProviders, ", "))
+		}
+		if strings.TrimSpace(fb.Model) == "" {
+			return fmt.Errorf("fallback_models[%d].model is required", i)
+		}
+	}
+
+	if settings.MaxIterations < 0 {
+		return fmt.Errorf("max_iterations must not be negative")
+	}
+	if settings.MaxToolCalls < 0 {
+		return fmt.Errorf("max_tool_calls must not be negative")
+	}
+	// git rejects these in an ident; catch them here rather than on every commit
+	if strings.ContainsAny(settings.CommitAuthorName, "<>\n") {
+		return fmt.Errorf("commit_author_name must not contain '<', '>' or newlines")
+	}
+	if strings.ContainsAny(settings.CommitAuthorEmail, "<>\n") {
+		return fmt.Errorf("commit_author_email must not contain '<', '>' or newlines")
+	}
+
+	for projectID, env := range settings.ProjectEnv {
+		for name := range env {
+			if !envNamePattern.MatchString(name) {
+				return fmt.Errorf("invalid project_env[%s] variable name: %q", projectID, name)
+			}
+		}
+	}
+
 	// Validate that selected backend has a corresponding API key
 	// provider := "anthropic"
 	// if settings.Provider != nil {
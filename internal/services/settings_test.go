@@ -224,3 +224,112 @@ func TestGetAPIKey(t *testing.T) {
 		})
 	}
 }
+
+func TestUpdateSettings_FallbackModels(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	svc := NewSettingsService(testDB)
+	ctx := context.Background()
+
+	err := svc.UpdateSettings(ctx, &Settings{
+		AgentBackend: "native",
+		FallbackModels: []FallbackModel{
+			{Provider: "openrouter", Model: "anthropic/claude-sonnet-4.5"},
+			{Provider: "zai", Model: "glm-4.7"},
+		},
+	})
+	require.NoError(t, err)
+
+	settings, err := svc.GetSettings(ctx)
+	require.NoError(t, err)
+	require.Len(t, settings.FallbackModels, 2)
+	assert.Equal(t, FallbackModel{Provider: "openrouter", Model: "anthropic/claude-sonnet-4.5"}, settings.FallbackModels[0])
+	assert.Equal(t, "zai", settings.FallbackModels[1].Provider)
+
+	// Saving without the chain keeps it; an empty list clears it
+	require.NoError(t, svc.UpdateSettings(ctx, &Settings{AgentBackend: "native"}))
+	settings, err = svc.GetSettings(ctx)
+	require.NoError(t, err)
+	assert.Len(t, settings.FallbackModels, 2)
+	require.NoError(t, svc.UpdateSettings(ctx, &Settings{AgentBackend: "native", FallbackModels: []FallbackModel{}}))
+	settings, err = svc.GetSettings(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, settings.FallbackModels)
+
+	err = svc.UpdateSettings(ctx, &Settings{
+		AgentBackend:   "native",
+		FallbackModels: []FallbackModel{{Provider: "openai", Model: "gpt-5"}},
+	})
+	assert.Error(t, err)
+	err = svc.UpdateSettings(ctx, &Settings{
+		AgentBackend:   "native",
+		FallbackModels: []FallbackModel{{Provider: "zai"}},
+	})
+	assert.Error(t, err)
+}
+
+func TestSummaryModel(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	svc := NewSettingsService(testDB)
+	ctx := context.Background()
+
+	require.NoError(t, svc.UpdateSettings(ctx, &Settings{AgentBackend: "native"}))
+	assert.Equal(t, "", svc.SummaryModel(ctx), "unset falls back to the task model")
+
+	require.NoError(t, svc.UpdateSettings(ctx, &Settings{
+		AgentBackend: "claude-code",
+		SummaryModel: "anthropic#claude-haiku-4-5",
+	}))
+	assert.Equal(t, "anthropic#claude-haiku-4-5", svc.SummaryModel(ctx))
+}
+
+func TestCommitIdentity(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	svc := NewSettingsService(testDB)
+	ctx := context.Background()
+
+	assert.Equal(t, DefaultCommitIdentity, svc.CommitIdentity(ctx), "no settings row yet")
+
+	require.NoError(t, svc.UpdateSettings(ctx, &Settings{AgentBackend: "native", CommitAuthorEmail: "bot@example.com"}))
+	assert.Equal(t, CommitIdentity{Name: "Counterspell Agent", Email: "bot@example.com"}, svc.CommitIdentity(ctx))
+
+	err := svc.UpdateSettings(ctx, &Settings{AgentBackend: "native", CommitAuthorName: "Bot <bot@example.com>"})
+	assert.Error(t, err)
+}
+
+func TestUpdateSettings_ProjectEnv(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	svc := NewSettingsService(testDB)
+	ctx := context.Background()
+
+	err := svc.UpdateSettings(ctx, &Settings{
+		AgentBackend: "native",
+		ProjectEnv: map[string]map[string]string{
+			"repo-1": {"DATABASE_URL": "postgres://fake", "CI": "true"},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"CI=true", "DATABASE_URL=postgres://fake"}, svc.ProjectEnv(ctx, "repo-1"))
+	assert.Empty(t, svc.ProjectEnv(ctx, "repo-2"))
+
+	// Saving without project_env keeps it
+	require.NoError(t, svc.UpdateSettings(ctx, &Settings{AgentBackend: "codex"}))
+	assert.Len(t, svc.ProjectEnv(ctx, "repo-1"), 2)
+
+	// An empty map clears it
+	require.NoError(t, svc.UpdateSettings(ctx, &Settings{AgentBackend: "codex", ProjectEnv: map[string]map[string]string{}}))
+	assert.Empty(t, svc.ProjectEnv(ctx, "repo-1"))
+
+	err = svc.UpdateSettings(ctx, &Settings{
+		AgentBackend: "native",
+		ProjectEnv:   map[string]map[string]string{"repo-1": {"BAD-NAME": "x"}},
+	})
+	assert.Error(t, err)
+}
@@ -0,0 +1,371 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrSlackSignature is returned for a slash command request whose signature
+// doesn't match the Slack app's signing secret, or that is too old.
+var ErrSlackSignature = errors.New("invalid Slack request signature")
+
+// slackMaxSkew is how old a Slack request may be, as Slack recommends, so a
+// captured request can't be replayed later.
+const slackMaxSkew = 5 * time.Minute
+
+// slackLinkCodeTTL is how long a code from NewLinkCode can be used.
+const slackLinkCodeTTL = 10 * time.Minute
+
+// slackHelp answers unknown subcommands.
+const slackHelp = "Usage:\n" +
+	"• `/counterspell run <repo> <intent>` starts a task on a project (owner/name or name)\n" +
+	"• `/counterspell link <code>` links your Slack account, with a code from Counterspell\n" +
+	"• `/counterspell unlink` removes the link"
+
+// VerifySlackRequest checks the X-Slack-Signature header of a Slack request:
+// "v0=" and the HMAC-SHA256 of "v0:<timestamp>:<body>" keyed with the
+// signing secret. timestamp is the X-Slack-Request-Timestamp header and
+// must be within five minutes of now.
+func VerifySlackRequest(secret string, body []byte, timestamp, signature string, now time.Time) error {
+	hexSig, ok := strings.CutPrefix(signature, "v0=")
+	if !ok || secret == "" {
+		return ErrSlackSignature
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrSlackSignature
+	}
+	if skew := now.Sub(time.Unix(ts, 0)); skew > slackMaxSkew || skew < -slackMaxSkew {
+		return ErrSlackSignature
+	}
+	got, err := hex.DecodeString(hexSig)
+	if err != nil {
+		return ErrSlackSignature
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + timestamp + ":"))
+	mac.Write(body)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return ErrSlackSignature
+	}
+	return nil
+}
+
+// SlackCommand is the part of a slash command request the integration uses.
+type SlackCommand struct {
+	TeamID      string
+	UserID      string
+	UserName    string
+	Text        string
+	ResponseURL string
+}
+
+// SlackResponse is a slash command reply, returned directly or posted to
+// the command's response_url.
+type SlackResponse struct {
+	ResponseType    string `json:"response_type"`
+	ReplaceOriginal bool   `json:"replace_original,omitempty"`
+	Text            string `json:"text"`
+}
+
+func ephemeral(format string, args ...any) SlackResponse {
+	return SlackResponse{ResponseType: "ephemeral", Text: fmt.Sprintf(format, args...)}
+}
+
+// SlackOptions configures a SlackIntegration.
+type SlackOptions struct {
+	// Interval is how often tasks started from Slack are checked for
+	// progress (default 15s)
+	Interval time.Duration
+	// Client posts to response URLs (default http.DefaultClient)
+	Client *http.Client
+}
+
+// SlackIntegration runs the /counterspell slash command: linked Slack users
+// start tasks with it, and the command's reply is updated through its
+// response_url as the task reaches review and done, with its PR link.
+type SlackIntegration struct {
+	repo   *Repository
+	tasks  taskStarter
+	client *http.Client
+	opts   SlackOptions
+
+	// codes are the unused link codes and their expiry
+	codes   map[string]time.Time
+	codesMu sync.Mutex
+
+	stopCh chan struct{}
+	pollMu sync.Mutex
+}
+
+// NewSlackIntegration creates the Slack integration. orch is usually the
+// shared Orchestrator.
+func NewSlackIntegration(repo *Repository, orch taskStarter, opts SlackOptions) *SlackIntegration {
+	if opts.Interval <= 0 {
+		opts.Interval = 15 * time.Second
+	}
+	client := opts.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &SlackIntegration{
+		repo:   repo,
+		tasks:  orch,
+		client: client,
+		opts:   opts,
+		codes:  make(map[string]time.Time),
+		stopCh: make(chan struct{}),
+	}
+}
+
+// NewLinkCode returns a one-time code for `/counterspell link <code>`,
+// valid for ten minutes. Whoever runs the command with it gets to start
+// tasks, so it is handed out to authenticated users only.
+func (s *SlackIntegration) NewLinkCode() (string, time.Time, error) {
+	b := make([]byte, 5)
+	if _, err := rand.Read(b); err != nil {
+		return "", time.Time{}, err
+	}
+	code := base32.StdEncoding.EncodeToString(b)
+	expiresAt := time.Now().Add(slackLinkCodeTTL)
+
+	s.codesMu.Lock()
+	defer s.codesMu.Unlock()
+	for c, exp := range s.codes {
+		if time.Now().After(exp) {
+			delete(s.codes, c)
+		}
+	}
+	s.codes[code] = expiresAt
+	return code, expiresAt, nil
+}
+
+// useLinkCode consumes code, reporting whether it was valid.
+func (s *SlackIntegration) useLinkCode(code string) bool {
+	code = strings.ToUpper(code)
+	s.codesMu.Lock()
+	defer s.codesMu.Unlock()
+	exp, ok := s.codes[code]
+	delete(s.codes, code)
+	return ok && time.Now().Before(exp)
+}
+
+// HandleCommand runs a slash command and returns the reply. Starting a task
+// can take longer than Slack waits for a reply, so `run` answers at once and
+// reports the new task on the response_url.
+func (s *SlackIntegration) HandleCommand(ctx context.Context, cmd SlackCommand) SlackResponse {
+	sub, args, _ := strings.Cut(strings.TrimSpace(cmd.Text), " ")
+	args = strings.TrimSpace(args)
+
+	switch strings.ToLower(sub) {
+	case "link":
+		if !s.useLinkCode(args) {
+			return ephemeral("That link code is invalid or expired. Create a new one in Counterspell.")
+		}
+		if err := s.repo.LinkSlackUser(ctx, cmd.TeamID, cmd.UserID, cmd.UserName); err != nil {
+			slog.Error("[SLACK] Failed to link user", "user", cmd.UserID, "error", err)
+			return ephemeral("Failed to link your account, try again.")
+		}
+		slog.Info("[SLACK] Linked user", "team", cmd.TeamID, "user", cmd.UserID, "name", cmd.UserName)
+		return ephemeral("Linked. You can now start tasks with `/counterspell run <repo> <intent>`.")
+
+	case "unlink":
+		unlinked, err := s.repo.UnlinkSlackUser(ctx, cmd.TeamID, cmd.UserID)
+		if err != nil {
+			slog.Error("[SLACK] Failed to unlink user", "user", cmd.UserID, "error", err)
+			return ephemeral("Failed to unlink your account, try again.")
+		}
+		if !unlinked {
+			return ephemeral("Your account is not linked.")
+		}
+		return ephemeral("Unlinked.")
+
+	case "run":
+		linked, err := s.repo.IsSlackUserLinked(ctx, cmd.TeamID, cmd.UserID)
+		if err != nil {
+			slog.Error("[SLACK] Failed to look up user", "user", cmd.UserID, "error", err)
+			return ephemeral("Failed to check your account, try again.")
+		}
+		if !linked {
+			return ephemeral("Your Slack account is not linked yet. Create a link code in Counterspell, then run `/counterspell link <code>`.")
+		}
+		name, intent, _ := strings.Cut(args, " ")
+		intent = strings.TrimSpace(intent)
+		if name == "" || intent == "" {
+			return ephemeral("Usage: `/counterspell run <repo> <intent>`")
+		}
+		project, reply := s.findProject(ctx, name)
+		if project == "" {
+			return reply
+		}
+		go s.startTask(cmd, project, intent)
+		return ephemeral("Starting a task on %s: %s", name, intent)
+
+	default:
+		return ephemeral("%s", slackHelp)
+	}
+}
+
+// findProject resolves a project name from a command to its ID. Without a
+// single match it returns an empty ID and the reply explaining why.
+func (s *SlackIntegration) findProject(ctx context.Context, name string) (string, SlackResponse) {
+	repos, err := s.repo.FindRepositoriesByName(ctx, name)
+	if err != nil {
+		slog.Error("[SLACK] Failed to look up project", "name", name, "error", err)
+		return "", ephemeral("Failed to look up %s, try again.", name)
+	}
+	switch len(repos) {
+	case 0:
+		return "", ephemeral("No project named %s. Add it in Counterspell first.", name)
+	case 1:
+		return repos[0].ID, SlackResponse{}
+	default:
+		names := make([]string, len(repos))
+		for i, r := range repos {
+			names[i] = r.FullName
+		}
+		return "", ephemeral("%s matches several projects, use one of: %s", name, strings.Join(names, ", "))
+	}
+}
+
+// startTask starts a task for a run command and reports the outcome on its
+// response_url, which then also gets the task's progress.
+func (s *SlackIntegration) startTask(cmd SlackCommand, projectID, intent string) {
+	ctx := context.Background()
+	taskID, err := s.tasks.StartTask(ctx, projectID, intent, "")
+	if err != nil {
+		slog.Error("[SLACK] Failed to start task", "project_id", projectID, "user", cmd.UserID, "error", err)
+		s.post(ctx, cmd.ResponseURL, ephemeral("Failed to start the task: %v", err))
+		return
+	}
+	slog.Info("[SLACK] Started task", "task_id", taskID, "user", cmd.UserID)
+	if err := s.repo.RecordSlackTask(ctx, taskID, cmd.ResponseURL); err != nil {
+		slog.Error("[SLACK] Failed to record task", "task_id", taskID, "error", err)
+	}
+	s.post(ctx, cmd.ResponseURL, ephemeral("Task %s started. I'll post here when it is ready for review.", taskID))
+}
+
+// Start checks tasks started from Slack every Interval until ctx is
+// cancelled or Shutdown is called.
+func (s *SlackIntegration) Start(ctx context.Context) {
+	slog.Info("[SLACK] starting", "interval", s.opts.Interval.String())
+
+	go func() {
+		ticker := time.NewTicker(s.opts.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.stopCh:
+				return
+			case <-ticker.C:
+				s.Poll(ctx)
+			}
+		}
+	}()
+}
+
+// Shutdown stops the progress checks.
+func (s *SlackIntegration) Shutdown() {
+	close(s.stopCh)
+}
+
+// Poll posts the progress of Slack tasks that reached review, done or failed
+// since the last check. A task is forgotten once done, or once Slack stops
+// accepting updates for it (response URLs expire after 30 minutes).
+func (s *SlackIntegration) Poll(ctx context.Context) {
+	s.pollMu.Lock()
+	defer s.pollMu.Unlock()
+
+	updates, err := s.repo.ListSlackTaskUpdates(ctx)
+	if err != nil {
+		slog.Error("[SLACK] Failed to list task updates", "error", err)
+		return
+	}
+	for _, u := range updates {
+		msg := ephemeral("%s", slackStatusText(u.Title, u.Status, u.PrUrl.String))
+		if err := s.post(ctx, u.ResponseUrl, msg); err != nil {
+			var rejected slackPostError
+			if errors.As(err, &rejected) {
+				_ = s.repo.DeleteSlackTask(ctx, u.TaskID)
+			}
+			continue
+		}
+		if u.Status == "done" {
+			err = s.repo.DeleteSlackTask(ctx, u.TaskID)
+		} else {
+			err = s.repo.MarkSlackTaskNotified(ctx, u.TaskID, u.Status)
+		}
+		if err != nil {
+			slog.Error("[SLACK] Failed to record task update", "task_id", u.TaskID, "error", err)
+		}
+	}
+}
+
+// slackStatusText describes a task reaching status.
+func slackStatusText(title, status, prURL string) string {
+	var text string
+	switch status {
+	case "review":
+		text = fmt.Sprintf("Task \"%s\" is ready for review", title)
+	case "done":
+		text = fmt.Sprintf("Task \"%s\" is done", title)
+	default:
+		return fmt.Sprintf("Task \"%s\" failed. Check its logs in Counterspell.", title)
+	}
+	if prURL != "" {
+		text += ": " + prURL
+	}
+	return text
+}
+
+// slackPostError is a response URL refusing a message, e.g. once it expired.
+type slackPostError struct {
+	Status int
+}
+
+func (e slackPostError) Error() string {
+	return fmt.Sprintf("Slack rejected the message: HTTP %d", e.Status)
+}
+
+// post replaces the command's reply with msg, so the user sees one message
+// tracking the task.
+func (s *SlackIntegration) post(ctx context.Context, responseURL string, msg SlackResponse) error {
+	msg.ReplaceOriginal = true
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, responseURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		slog.Warn("[SLACK] Failed to post message", "error", err)
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		err := slackPostError{Status: resp.StatusCode}
+		slog.Warn("[SLACK] Failed to post message", "error", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/revrost/counterspell/internal/db/sqlc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifySlackRequest(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte("command=%2Fcounterspell&text=run")
+	sign := func(secret, timestamp string) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte("v0:" + timestamp + ":"))
+		mac.Write(body)
+		return "v0=" + hex.EncodeToString(mac.Sum(nil))
+	}
+	ts := strconv.FormatInt(now.Unix(), 10)
+
+	assert.NoError(t, VerifySlackRequest("s3cret", body, ts, sign("s3cret", ts), now))
+	assert.ErrorIs(t, VerifySlackRequest("other", body, ts, sign("s3cret", ts), now), ErrSlackSignature)
+	assert.ErrorIs(t, VerifySlackRequest("s3cret", []byte("text=other"), ts, sign("s3cret", ts), now), ErrSlackSignature)
+	assert.ErrorIs(t, VerifySlackRequest("", body, ts, sign("", ts), now), ErrSlackSignature)
+	// Replays of old requests are refused even with a valid signature
+	old := strconv.FormatInt(now.Add(-6*time.Minute).Unix(), 10)
+	assert.ErrorIs(t, VerifySlackRequest("s3cret", body, old, sign("s3cret", old), now), ErrSlackSignature)
+	assert.ErrorIs(t, VerifySlackRequest("s3cret", body, "soon", sign("s3cret", "soon"), now), ErrSlackSignature)
+}
+
+func TestSlackIntegration_RunAndReport(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	ctx := context.Background()
+	repo := NewRepository(testDB)
+	conn, err := testDB.Queries.CreateGithubConnection(ctx, sqlc.CreateGithubConnectionParams{
+		ID:           "conn-1",
+		GithubUserID: "user-1",
+		AccessToken:  "token",
+		Username:     "testuser",
+	})
+	require.NoError(t, err)
+	_, err = testDB.Queries.CreateRepository(ctx, sqlc.CreateRepositoryParams{
+		ID:           "repo-1",
+		ConnectionID: conn.ID,
+		Name:         "repo-1",
+		FullName:     "test/repo-1",
+		Owner:        "test",
+	})
+	require.NoError(t, err)
+
+	posted := make(chan SlackResponse, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg SlackResponse
+		_ = json.NewDecoder(r.Body).Decode(&msg)
+		posted <- msg
+	}))
+	defer server.Close()
+
+	slack := NewSlackIntegration(repo, &fakeTaskStarter{repo: repo}, SlackOptions{})
+	cmd := func(text string) SlackResponse {
+		return slack.HandleCommand(ctx, SlackCommand{
+			TeamID:      "T1",
+			UserID:      "U1",
+			UserName:    "alice",
+			Text:        text,
+			ResponseURL: server.URL,
+		})
+	}
+
+	// Unlinked users can't run tasks, and a link code works once
+	assert.Contains(t, cmd("run repo-1 Fix login").Text, "not linked")
+	assert.Contains(t, cmd("link NOPE").Text, "invalid or expired")
+	code, _, err := slack.NewLinkCode()
+	require.NoError(t, err)
+	assert.Contains(t, cmd("link "+strings.ToLower(code)).Text, "Linked")
+	assert.Contains(t, cmd("link "+code).Text, "invalid or expired")
+
+	assert.Contains(t, cmd("run repo-1").Text, "Usage")
+	assert.Contains(t, cmd("run missing Fix login").Text, "No project named missing")
+	reply := cmd("run test/repo-1 Fix login")
+	assert.Equal(t, "ephemeral", reply.ResponseType)
+	assert.Contains(t, reply.Text, "Starting a task")
+
+	// The task is reported once started, replacing the first reply
+	msg := <-posted
+	assert.True(t, msg.ReplaceOriginal)
+	assert.Contains(t, msg.Text, "started")
+	var taskID string
+	require.NoError(t, testDB.DB.QueryRowContext(ctx, "SELECT task_id FROM slack_tasks").Scan(&taskID))
+
+	// Then on review with its PR, and on done
+	for _, status := range []string{"in_progress", "review"} {
+		_, err = repo.UpdateStatus(ctx, taskID, status)
+		require.NoError(t, err)
+	}
+	require.NoError(t, repo.UpdateTaskPRURL(ctx, taskID, "https://github.com/test/repo-1/pull/7"))
+	slack.Poll(ctx)
+	msg = <-posted
+	assert.Contains(t, msg.Text, "ready for review: https://github.com/test/repo-1/pull/7")
+	slack.Poll(ctx)
+	assert.Empty(t, posted, "an unchanged status is not reported again")
+
+	_, err = repo.UpdateStatus(ctx, taskID, "done")
+	require.NoError(t, err)
+	slack.Poll(ctx)
+	msg = <-posted
+	assert.Contains(t, msg.Text, "is done")
+	updates, err := repo.ListSlackTaskUpdates(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, updates)
+
+	assert.Equal(t, "Unlinked.", cmd("unlink").Text)
+	assert.Contains(t, cmd("run repo-1 Fix login").Text, "not linked")
+}
@@ -1,8 +1,12 @@
 import type {
+  Artifact,
   Project,
   Task,
   TaskResponse,
   FeedData,
+  ActiveRows,
+  TaskProgress,
+  DonePage,
   UserSettings,
   Message,
   LogEntry,
@@ -12,6 +16,11 @@ import type {
   ConflictResponse,
   Session,
   SessionResponse,
+  Todo,
+  DiffFile,
+  DiffStat,
+  TaskShare,
+  SharedTask,
 } from '$lib/types';
 
 // API base URL - uses proxy in dev, relative path in prod
@@ -175,11 +184,17 @@ export const projectsAPI = {
     const feedData = await fetchAPI<{ projects: Record<string, Project> }>('/api/v1/tasks');
     return feedData.projects || {};
   },
-  async activate(owner: string, repo: string): Promise<void> {
-    const formData = new FormData();
-    formData.append('owner', owner);
-    formData.append('repo', repo);
-    await postFormNoResponse('/api/v1/project/activate', formData);
+  // Registers a GitHub repository the token can read, without a full sync
+  async create(owner: string, repo: string): Promise<GitHubRepo> {
+    return fetchAPI<GitHubRepo>('/api/v1/projects', {
+      method: 'POST',
+      body: JSON.stringify({ owner, repo }),
+    });
+  },
+
+  // Removes a project; its tasks are kept
+  async delete(projectId: string): Promise<void> {
+    await fetchAPI(`/api/v1/projects/${projectId}`, { method: 'DELETE' });
   },
 };
 
@@ -189,28 +204,152 @@ export const githubAPI = {
   async listRepos(): Promise<GitHubRepo[]> {
     return fetchAPI<GitHubRepo[]>('/api/v1/github/repos');
   },
+
+  async listIssueWatches(): Promise<string[]> {
+    const data = await fetchAPI<{ project_ids: string[] }>('/api/v1/github/issue-watch');
+    return data.project_ids || [];
+  },
+
+  async setIssueWatch(projectId: string, enabled: boolean): Promise<void> {
+    await fetchAPI(`/api/v1/github/repos/${projectId}/issue-watch`, {
+      method: 'PUT',
+      body: JSON.stringify({ enabled }),
+    });
+  },
+
+  async listCommitStatuses(): Promise<string[]> {
+    const data = await fetchAPI<{ project_ids: string[] }>('/api/v1/github/commit-statuses');
+    return data.project_ids || [];
+  },
+
+  async setCommitStatuses(projectId: string, enabled: boolean): Promise<void> {
+    await fetchAPI(`/api/v1/github/repos/${projectId}/commit-statuses`, {
+      method: 'PUT',
+      body: JSON.stringify({ enabled }),
+    });
+  },
+
+  // Projects whose agent commits are signed with the server's GIT_SIGNING_KEY
+  async listCommitSigning(): Promise<string[]> {
+    const data = await fetchAPI<{ project_ids: string[] }>('/api/v1/github/commit-signing');
+    return data.project_ids || [];
+  },
+
+  async setCommitSigning(projectId: string, enabled: boolean): Promise<void> {
+    await fetchAPI(`/api/v1/github/repos/${projectId}/commit-signing`, {
+      method: 'PUT',
+      body: JSON.stringify({ enabled }),
+    });
+  },
+
+  // Projects whose task PRs are merged once GitHub reports their checks green
+  async listAutoMerge(): Promise<string[]> {
+    const data = await fetchAPI<{ project_ids: string[] }>('/api/v1/github/auto-merge');
+    return data.project_ids || [];
+  },
+
+  async setAutoMerge(projectId: string, enabled: boolean): Promise<void> {
+    await fetchAPI(`/api/v1/github/repos/${projectId}/auto-merge`, {
+      method: 'PUT',
+      body: JSON.stringify({ enabled }),
+    });
+  },
 };
 
 // ==================== TASKS ====================
 
 export const tasksAPI = {
-  async getFeed(): Promise<FeedData> {
-    return fetchAPI<FeedData>('/api/v1/tasks');
+  async getFeed(projectIds: string[] = []): Promise<FeedData> {
+    const params = new URLSearchParams();
+    for (const id of projectIds) params.append('project', id);
+    const query = params.toString();
+    return fetchAPI<FeedData>(query ? `/api/v1/tasks?${query}` : '/api/v1/tasks');
+  },
+
+  // Older finished tasks, a page at a time, starting from the feed's done_cursor.
+  async getDone(before: string, projectIds: string[] = []): Promise<DonePage> {
+    const params = new URLSearchParams({ before });
+    for (const id of projectIds) params.append('project', id);
+    return fetchAPI<DonePage>(`/api/v1/tasks/done?${params}`);
+  },
+
+  // Active, planning and review rows only, for polling when SSE can't connect
+  async getActive(projectIds: string[] = []): Promise<ActiveRows> {
+    const params = new URLSearchParams();
+    for (const id of projectIds) params.append('project', id);
+    const query = params.toString();
+    return fetchAPI<ActiveRows>(query ? `/api/v1/tasks/active?${query}` : '/api/v1/tasks/active');
+  },
+
+  // Status and todo progress of every unfinished task, keyed by task ID: one
+  // small request to reconcile the feed after a reconnect or while polling
+  async getFeedStatus(projectIds: string[] = []): Promise<Record<string, TaskProgress>> {
+    const params = new URLSearchParams();
+    for (const id of projectIds) params.append('project', id);
+    const query = params.toString();
+    return fetchAPI<Record<string, TaskProgress>>(query ? `/api/v1/feed/status?${query}` : '/api/v1/feed/status');
   },
 
   async get(id: string): Promise<TaskResponse> {
     return fetchAPI<TaskResponse>(`/api/v1/tasks/${id}`);
   },
 
-  async getDiff(id: string): Promise<{ git_diff: string }> {
-    return fetchAPI<{ git_diff: string }>(`/api/v1/tasks/${id}/diff`);
+  // too_large: the diff changes more lines than the server renders inline,
+  // so only its stat is sent
+  async getDiff(id: string): Promise<{
+    git_diff: string;
+    files: DiffFile[];
+    truncated?: boolean;
+    too_large?: boolean;
+    max_lines?: number;
+    stat?: DiffStat;
+  }> {
+    return fetchAPI(`/api/v1/tasks/${id}/diff`);
+  },
+
+  async getDiffStat(id: string): Promise<DiffStat> {
+    return fetchAPI<DiffStat>(`/api/v1/tasks/${id}/diff/stat`);
+  },
+
+  // Download URLs for the untruncated diff and message content
+  fullDiffUrl(id: string): string {
+    return `/api/v1/tasks/${id}/diff/full`;
+  },
+
+  fullMessageUrl(taskId: string, messageId: string): string {
+    return `/api/v1/tasks/${taskId}/messages/${messageId}/full`;
+  },
+
+  // Download URL for the raw stdout/stderr of a CLI backend run (latest run by default)
+  rawLogUrl(id: string, runId?: string): string {
+    const base = `/api/v1/tasks/${id}/raw-log`;
+    return runId ? `${base}?run=${encodeURIComponent(runId)}` : base;
+  },
+
+  // Files the task's runs handed back, every version of each
+  async getArtifacts(id: string): Promise<{ artifacts: Artifact[] }> {
+    return fetchAPI<{ artifacts: Artifact[] }>(`/api/v1/tasks/${id}/artifacts`);
+  },
+
+  artifactDownloadUrl(taskId: string, artifactId: string): string {
+    return `/api/v1/tasks/${taskId}/artifacts/${artifactId}/download`;
   },
 
-  async create(intent: string, projectId: string, modelId: string): Promise<APIResponse> {
+  // System prompt the agent was given for one run of a task
+  async runPrompt(taskId: string, runId: string): Promise<{ run_id: string; system_prompt: string }> {
+    return fetchAPI<{ run_id: string; system_prompt: string }>(`/api/v1/tasks/${taskId}/runs/${runId}/prompt`);
+  },
+
+  async getTodos(id: string): Promise<{ todos: Todo[] }> {
+    return fetchAPI<{ todos: Todo[] }>(`/api/v1/tasks/${id}/todos`);
+  },
+
+  async create(intent: string, projectId: string, modelId: string, baseBranch?: string): Promise<APIResponse> {
     return postJsonWithResponse('/api/v1/tasks', {
       intent: intent,
       project_id: projectId,
       model_id: modelId,
+      base_branch: baseBranch || '',
     });
   },
 
@@ -222,15 +361,48 @@ export const tasksAPI = {
     });
   },
 
-  async retry(taskId: string): Promise<APIResponse> {
+  // Sends a message to the running agent: mid-run when its backend can be
+  // steered, otherwise by stopping the run and continuing with the message
+  async interrupt(taskId: string, message: string): Promise<{ task_id: string; mode: 'injected' | 'restarted' }> {
+    const response = await fetch(`${API_BASE}/api/v1/tasks/${taskId}/interrupt`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ message }),
+      credentials: 'include',
+    });
+    const data = await response.json().catch(() => ({ message: 'Unknown error' }));
+    if (!response.ok) {
+      throw new Error(data.message || `API error: ${response.status}`);
+    }
+    return data;
+  },
+
+  // With an intent, reruns this task with the edited intent instead of
+  // starting a new task with the same one
+  async retry(taskId: string, intent?: string): Promise<APIResponse> {
+    if (intent) {
+      return postJsonWithResponse(`/api/v1/tasks/${taskId}/retry`, { intent });
+    }
     return postAction(`/api/v1/tasks/${taskId}/retry`);
   },
 
+  // A systemPrompt replaces the assembled system prompt for the new run
+  async rerun(taskId: string, modelId?: string, systemPrompt?: string): Promise<APIResponse> {
+    return postJsonWithResponse(`/api/v1/tasks/${taskId}/rerun`, {
+      model_id: modelId || '',
+      system_prompt: systemPrompt || '',
+    });
+  },
+
   async clear(taskId: string): Promise<APIResponse> {
     return postAction(`/api/v1/tasks/${taskId}/clear`);
   },
 
-  async merge(taskId: string): Promise<APIResponse | ConflictResponse> {
+  // With files, merges only the changes to those files and drops the rest
+  async merge(taskId: string, files?: string[]): Promise<APIResponse | ConflictResponse> {
+    if (files) {
+      return postJsonWithResponse(`/api/v1/tasks/${taskId}/merge`, { files });
+    }
     return postAction(`/api/v1/tasks/${taskId}/merge`);
   },
 
@@ -241,8 +413,41 @@ export const tasksAPI = {
   async discard(taskId: string): Promise<APIResponse> {
     return postAction(`/api/v1/tasks/${taskId}/discard`);
   },
+
+  // Only tasks that haven't run yet (or were reset) can move.
+  async moveRepo(taskId: string, projectId: string): Promise<APIResponse> {
+    return postJsonWithResponse(`/api/v1/tasks/${taskId}/move-repo`, { project_id: projectId });
+  },
+
+  // Read-only links for people without access; the URL is only returned here
+  async createShare(taskId: string, expiresInHours?: number): Promise<{ share: TaskShare; url: string }> {
+    const query = expiresInHours ? `?expires_in_hours=${expiresInHours}` : '';
+    return fetchAPI<{ share: TaskShare; url: string }>(`/api/v1/tasks/${taskId}/share${query}`, {
+      method: 'POST',
+    });
+  },
+
+  async listShares(taskId: string): Promise<{ shares: TaskShare[] }> {
+    return fetchAPI<{ shares: TaskShare[] }>(`/api/v1/tasks/${taskId}/shares`);
+  },
+
+  async revokeShare(taskId: string, shareId: string): Promise<void> {
+    await fetchAPI(`/api/v1/tasks/${taskId}/shares/${shareId}`, { method: 'DELETE' });
+  },
+
+  // The task behind a share token; needs no login
+  async getShared(token: string): Promise<SharedTask> {
+    return fetchAPI<SharedTask>(`/api/v1/shared/${encodeURIComponent(token)}`);
+  },
+
+  // Runs any task action through one route, for keyboard shortcuts.
+  async command(action: TaskCommand, taskId: string, extra: Record<string, string> = {}): Promise<APIResponse> {
+    return postJsonWithResponse('/api/v1/action/command', { ...extra, action, task_id: taskId });
+  },
 };
 
+export type TaskCommand = 'merge' | 'pr' | 'retry' | 'rerun' | 'discard' | 'chat';
+
 // ==================== SESSIONS ====================
 
 export const sessionsAPI = {
@@ -277,9 +482,39 @@ export const sessionsAPI = {
 
 // ==================== SETTINGS ====================
 
+// Settings as the server sends them (snake_case)
+interface SettingsResponse {
+  agent_backend: UserSettings['agentBackend'];
+  openrouter_key?: string;
+  zai_key?: string;
+  anthropic_key?: string;
+  openai_key?: string;
+  summary_model?: string;
+  auto_resolve_conflicts?: boolean;
+  show_thinking?: boolean;
+  max_iterations?: number;
+  max_tool_calls?: number;
+  commit_author_name?: string;
+  commit_author_email?: string;
+}
+
 export const settingsAPI = {
   async get(): Promise<UserSettings> {
-    return fetchAPI<UserSettings>('/api/v1/settings');
+    const s = await fetchAPI<SettingsResponse>('/api/v1/settings');
+    return {
+      agentBackend: s.agent_backend,
+      openRouterKey: s.openrouter_key,
+      zaiKey: s.zai_key,
+      anthropicKey: s.anthropic_key,
+      openAiKey: s.openai_key,
+      summaryModel: s.summary_model,
+      autoResolveConflicts: s.auto_resolve_conflicts,
+      showThinking: s.show_thinking,
+      maxIterations: s.max_iterations,
+      maxToolCalls: s.max_tool_calls,
+      commitAuthorName: s.commit_author_name,
+      commitAuthorEmail: s.commit_author_email,
+    };
   },
 
   async save(settings: UserSettings): Promise<void> {
@@ -291,6 +526,13 @@ export const settingsAPI = {
         zai_key: settings.zaiKey || '',
         anthropic_key: settings.anthropicKey || '',
         openai_key: settings.openAiKey || '',
+        summary_model: settings.summaryModel || '',
+        auto_resolve_conflicts: settings.autoResolveConflicts || false,
+        show_thinking: settings.showThinking || false,
+        max_iterations: settings.maxIterations || 0,
+        max_tool_calls: settings.maxToolCalls || 0,
+        commit_author_name: settings.commitAuthorName || '',
+        commit_author_email: settings.commitAuthorEmail || '',
       }),
     });
   },
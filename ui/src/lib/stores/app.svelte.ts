@@ -29,6 +29,8 @@ class AppState {
   activeProjectId = $state("");
   activeProjectName = $state("");
   projects = $state<Project[]>([]);
+  // Projects shown in the feed; empty means all
+  feedProjectIds = $state<string[]>([]);
   repos = $state<GitHubRepo[]>([]);
 
   // Model
@@ -62,6 +64,13 @@ class AppState {
         localStorage.getItem("counterspell_active_project_name") || "";
       this.activeModelId =
         localStorage.getItem("counterspell_model") || MODELS[0].id;
+      try {
+        this.feedProjectIds = JSON.parse(
+          localStorage.getItem("counterspell_feed_project_ids") || "[]",
+        );
+      } catch {
+        this.feedProjectIds = [];
+      }
     }
   }
 
@@ -136,7 +145,7 @@ class AppState {
     // 	const repo = this.repos.find((r) => r.id.toString() === id);
     // 	if (repo) {
     // 		try {
-    // 			await projectsAPI.activate(repo.owner, repo.name);
+    // 			await projectsAPI.create(repo.owner, repo.name);
     // 			// After activation, we need to reload projects to get the actual project ID
     // 			await this.loadProjects();
     // 			const project = this.projects.find((p) => p.name === repo.full_name);
@@ -160,6 +169,18 @@ class AppState {
     this.projectMenuOpen = false;
   }
 
+  toggleFeedProject(id: string) {
+    const ids = this.feedProjectIds.includes(id)
+      ? this.feedProjectIds.filter((p) => p !== id)
+      : [...this.feedProjectIds, id];
+    this.setFeedProjects(ids);
+  }
+
+  setFeedProjects(ids: string[]) {
+    this.feedProjectIds = ids;
+    localStorage.setItem("counterspell_feed_project_ids", JSON.stringify(ids));
+  }
+
   setModel(id: string) {
     this.activeModelId = id;
     localStorage.setItem("counterspell_model", id);
@@ -224,6 +245,7 @@ class AppState {
     this.activeProjectId = "";
     this.activeProjectName = "";
     this.projects = [];
+    this.feedProjectIds = [];
     this.repos = [];
 
     // Reset Auth
@@ -240,6 +262,7 @@ class AppState {
     if (typeof window !== "undefined") {
       localStorage.removeItem("counterspell_active_project_id");
       localStorage.removeItem("counterspell_active_project_name");
+      localStorage.removeItem("counterspell_feed_project_ids");
       localStorage.removeItem("counterspell_model");
       // Clear any other app-specific keys if they exist
       sessionStorage.clear();
@@ -1,4 +1,4 @@
-import type { Task, FeedData, Todo } from '$lib/types';
+import type { Task, FeedData, Todo, DiffFile } from '$lib/types';
 
 // Task timers for elapsed time tracking
 export const taskTimers: Record<string, number> = {};
@@ -8,6 +8,8 @@ class TaskStore {
 	currentTask = $state<Task | null>(null);
 	todos = $state<Todo[]>([]);
 	reviewCount = $state(0);
+	// Per-file diffs of the running task, replaced by the final diff when it ends
+	liveDiff = $state<{ taskId: string; files: Record<string, DiffFile[]> } | null>(null);
 
 	get completedCount(): number {
 		return this.todos.filter((t) => t.status === 'completed').length;
@@ -26,6 +28,15 @@ class TaskStore {
 		}
 	}
 
+	setLiveDiff(taskId: string, path: string, files: DiffFile[]) {
+		const current = this.liveDiff?.taskId === taskId ? this.liveDiff.files : {};
+		this.liveDiff = { taskId, files: { ...current, [path]: files } };
+	}
+
+	clearLiveDiff() {
+		this.liveDiff = null;
+	}
+
 	updateTask(task: Partial<Task>) {
 		if (this.currentTask && task.id === this.currentTask.id) {
 			this.currentTask = { ...this.currentTask, ...task };
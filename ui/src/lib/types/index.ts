@@ -14,6 +14,7 @@ export interface GitHubRepo {
   default_branch: string;
   private: boolean;
   language: string;
+  size_kb: number;
   updated_at: string;
   is_favorite: boolean;
 }
@@ -29,6 +30,11 @@ export interface Task {
   last_assistant_message?: string;
   created_at: number;
   updated_at: number;
+  version?: number; // bumped on every status change
+  pr_url?: string;
+  pr_state?: 'open' | 'merged' | 'closed'; // from GitHub webhooks, if configured
+  base_branch?: string; // branch the task starts from and merges into
+  no_changes?: boolean; // the last successful run left the diff empty
   gitDiff?: string;
   git_diff?: string;
 }
@@ -49,6 +55,7 @@ export interface Message {
   created_at: number;
   updated_at: number;
   finished_at?: number | null;
+  artifact_path?: string | null; // set when content was truncated
 }
 
 export interface ContentBlock {
@@ -84,6 +91,7 @@ export interface AgentRun {
   prompt_tokens: number;
   completion_tokens: number;
   completed_at?: number | null;
+  limit_reached?: string | null; // why the iteration limit stopped the run
   created_at: number;
   updated_at: number;
   messages?: Message[];
@@ -94,7 +102,8 @@ export interface Artifact {
   id: string;
   run_id: string;
   path: string;
-  content: string;
+  // Omitted when listing; download the artifact for its content
+  content?: string;
   version: number;
   created_at: number;
   updated_at: number;
@@ -111,9 +120,77 @@ export interface TaskResponse {
 }
 
 export interface LogEntry {
-  type: 'info' | 'error' | 'success' | 'plan' | 'code';
+  id: number;
+  task_id: string;
+  level: 'info' | 'success' | 'warn' | 'error';
   message: string;
-  timestamp: string;
+  timestamp: number; // Unix ms
+}
+
+export interface DiffLine {
+  kind: 'context' | 'add' | 'del' | 'collapsed';
+  content?: string;
+  old_line?: number;
+  new_line?: number;
+  count?: number; // collapsed only
+  hidden?: DiffLine[]; // collapsed only
+  segments?: DiffSegment[]; // add/del lines paired with a line on the other side
+}
+
+export interface DiffSegment {
+  text: string;
+  changed?: boolean;
+}
+
+export interface DiffHunk {
+  header: string;
+  old_start: number;
+  new_start: number;
+  lines: DiffLine[];
+}
+
+export interface DiffFile {
+  old_path: string;
+  new_path: string;
+  status?: 'renamed' | 'copied';
+  similarity?: number; // percent kept by a rename or copy
+  binary?: boolean; // no hunks, only a change marker
+  hunks: DiffHunk[];
+}
+
+// One file's diff against the base, sent while the agent is still running
+export interface DiffUpdate {
+  path: string;
+  files?: DiffFile[];
+  truncated?: boolean; // too large to send; the final diff will have it
+  too_large?: boolean; // the task's diff is past max_lines and won't be rendered
+  max_lines?: number;
+}
+
+// Read-only link to one task; token is only returned on creation
+export interface TaskShare {
+  id: string;
+  task_id: string;
+  token?: string;
+  expires_at: number;
+  created_at: number;
+}
+
+// What a share link shows: the task without any actions
+export interface SharedTask {
+  task: Task;
+  agent_runs?: AgentRun[];
+  messages: Message[];
+  logs: LogEntry[];
+  files: DiffFile[];
+  truncated: boolean;
+}
+
+// Per-file line counts of a task's diff, for summaries
+export interface DiffStat {
+  files: { path: string; additions: number; deletions: number; binary?: boolean }[];
+  total_additions: number;
+  total_deletions: number;
 }
 
 export interface Todo {
@@ -128,6 +205,18 @@ export interface UserSettings {
   zaiKey?: string;
   anthropicKey?: string;
   openAiKey?: string;
+  // "provider#model" for summaries and other auxiliary calls; empty = task model
+  summaryModel?: string;
+  // let the LLM attempt merge conflict resolution; results are staged for review
+  autoResolveConflicts?: boolean;
+  // show the agent's thinking in the thread, labeled and de-emphasized
+  showThinking?: boolean;
+  // per-run caps for the native agent; 0 = default
+  maxIterations?: number;
+  maxToolCalls?: number;
+  // author/committer of agent commits; empty = "Counterspell Agent <agent@counterspell>"
+  commitAuthorName?: string;
+  commitAuthorEmail?: string;
 }
 
 export interface Session {
@@ -174,6 +263,28 @@ export interface FeedData {
   done: Task[];
   todo: Task[];
   planning: Task[];
+  done_cursor?: string; // set when older finished tasks can be loaded
+}
+
+// Live state of an unfinished task, from GET /api/v1/feed/status
+export interface TaskProgress {
+  repository_id?: string;
+  status: TaskStatus;
+  version: number;
+  todos_done: number;
+  todos_total: number;
+}
+
+// Feed rows that change while tasks run; polled when SSE is unavailable
+export interface ActiveRows {
+  active: Task[];
+  planning: Task[];
+  reviews: Task[];
+}
+
+export interface DonePage {
+  tasks: Task[];
+  cursor?: string;
 }
 
 export interface Model {
@@ -196,6 +307,7 @@ export interface APIResponse {
   status: 'success' | 'error' | 'conflict';
   message?: string;
   pr_url?: string;
+  excluded?: string[]; // files left out of a partial merge
 }
 
 export interface ConflictFile {
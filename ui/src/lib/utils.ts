@@ -1,5 +1,6 @@
 import { clsx, type ClassValue } from 'clsx';
 import { twMerge } from 'tailwind-merge';
+import type { DiffFile } from './types';
 
 export function cn(...inputs: ClassValue[]) {
 	return twMerge(clsx(inputs));
@@ -11,11 +12,28 @@ export function formatDuration(secs: number): string {
 	return `${m}:${s < 10 ? '0' : ''}${s}`;
 }
 
+// Formats a size in KB, as GitHub reports repository sizes
+export function formatRepoSize(kb: number): string {
+	if (kb < 1024) return `${kb} KB`;
+	if (kb < 1024 * 1024) return `${(kb / 1024).toFixed(1)} MB`;
+	return `${(kb / 1024 / 1024).toFixed(1)} GB`;
+}
+
+// Repositories from this size (1 GB) get a warning in the project picker,
+// as cloning them takes a while and a lot of disk
+export const LARGE_REPO_KB = 1024 * 1024;
+
 export function getInitial(text: string | null | undefined): string {
 	if (!text) return '?';
 	return text[0].toUpperCase();
 }
 
+export function formatAbsoluteTime(timestamp: number): string {
+	if (!timestamp) return '';
+	const ts = timestamp < 1e12 ? timestamp * 1000 : timestamp;
+	return new Date(ts).toLocaleString();
+}
+
 export function formatRelativeTime(timestamp: number): string {
 	if (!timestamp) return '';
 
@@ -35,3 +53,21 @@ export function formatRelativeTime(timestamp: number): string {
 	if (seconds > 0) return `${seconds}s ago`;
 	return 'Just now';
 }
+
+// Describes a diff file change that isn't only lines: a rename or copy, or a
+// binary file, which has no lines at all. Empty for plain edits.
+export function diffFileMarker(file: DiffFile): string {
+	const parts: string[] = [];
+	if (file.status) {
+		const verb = file.status === 'renamed' ? 'Renamed' : 'Copied';
+		const similarity =
+			file.similarity !== undefined && file.similarity < 100 ? ` (${file.similarity}% similar)` : '';
+		parts.push(`${verb} ${file.old_path} → ${file.new_path}${similarity}`);
+	}
+	if (file.binary) {
+		if (file.old_path === '/dev/null') parts.push('Binary file added');
+		else if (file.new_path === '/dev/null') parts.push('Binary file deleted');
+		else parts.push('Binary file changed');
+	}
+	return parts.join(' · ');
+}
@@ -1,4 +1,4 @@
-import type { Todo } from '$lib/types';
+import type { DiffUpdate, Todo } from '$lib/types';
 import { taskStore } from '$lib/stores/tasks.svelte';
 
 export enum EventType {
@@ -40,6 +40,17 @@ export function createTaskSSE(taskId: string, callbacks: SSECallbacks = {}): Eve
   });
 
   eventSource.addEventListener('diff_update', (event) => {
+    try {
+      const envelope = JSON.parse(event.data);
+      const update = JSON.parse(envelope.data) as DiffUpdate;
+      // Truncated files are left out; the final diff has them. Past the
+      // inline limit the diff view shows only the stat, so the partial
+      // live diff is dropped
+      if (update.too_large) taskStore.clearLiveDiff();
+      else if (!update.truncated) taskStore.setLiveDiff(taskId, update.path, update.files ?? []);
+    } catch (e) {
+      console.error('Failed to parse diff update:', e);
+    }
     callbacks.onDiffUpdate?.(event.data);
   });
 
@@ -103,6 +114,11 @@ export function createFeedSSE(onUpdate: () => void, onError?: (error: Event) =>
     onUpdate();
   });
 
+  // Sent on reconnect when the server no longer has every missed event.
+  eventSource.addEventListener('resync', () => {
+    onUpdate();
+  });
+
   eventSource.onerror = (error) => {
     console.error('Feed SSE Error:', error);
     onError?.(error);
@@ -110,3 +126,85 @@ export function createFeedSSE(onUpdate: () => void, onError?: (error: Event) =>
 
   return eventSource;
 }
+
+// The feed stream sends a ping as soon as it connects. A proxy that buffers
+// the stream holds it back, so no ping in time means SSE isn't getting through.
+const FEED_PING_TIMEOUT_MS = 5000;
+// Consecutive errors without a ping in between before giving up on SSE.
+const FEED_MAX_SSE_ERRORS = 3;
+export const FEED_POLL_INTERVAL_MS = 3000;
+// With SSE_HEARTBEAT_EVENTS the server sends heartbeats every interval_ms;
+// this many missed in a row means the connection is dead.
+const FEED_MISSED_HEARTBEATS = 3;
+
+export interface FeedWatcher {
+  close: () => void;
+}
+
+// watchFeed calls onUpdate on every feed event, like createFeedSSE, and
+// reconcile after the stream reconnects. It switches to calling reconcile
+// every FEED_POLL_INTERVAL_MS when the stream is buffered or keeps failing,
+// and reconnects when heartbeats stop arriving.
+// SSE stays the default; polling is the fallback.
+export function watchFeed(onUpdate: () => void, reconcile: () => void): FeedWatcher {
+  let eventSource: EventSource | null = null;
+  let pollTimer: ReturnType<typeof setInterval> | null = null;
+  let heartbeatTimer: ReturnType<typeof setTimeout> | undefined;
+  let errors = 0;
+
+  const fallBackToPolling = (reason: string) => {
+    if (pollTimer) return;
+    console.warn(`Feed SSE unavailable (${reason}); polling every ${FEED_POLL_INTERVAL_MS}ms`);
+    eventSource?.close();
+    eventSource = null;
+    clearTimeout(pingTimer);
+    clearTimeout(heartbeatTimer);
+    pollTimer = setInterval(reconcile, FEED_POLL_INTERVAL_MS);
+    // Catch up on whatever the stream missed
+    onUpdate();
+  };
+
+  const pingTimer = setTimeout(() => fallBackToPolling('no ping'), FEED_PING_TIMEOUT_MS);
+
+  const connect = () => {
+    eventSource = createFeedSSE(onUpdate, () => {
+      errors++;
+      if (errors >= FEED_MAX_SSE_ERRORS || eventSource?.readyState === EventSource.CLOSED) {
+        fallBackToPolling('connection errors');
+      }
+    });
+    eventSource.addEventListener('ping', () => {
+      // Each connection pings once; after errors this is a reconnect
+      if (errors > 0) reconcile();
+      errors = 0;
+      clearTimeout(pingTimer);
+    });
+    eventSource.addEventListener('heartbeat', (event) => {
+      clearTimeout(heartbeatTimer);
+      let intervalMs = 0;
+      try {
+        intervalMs = JSON.parse(event.data).interval_ms;
+      } catch {
+        return;
+      }
+      if (!intervalMs) return;
+      heartbeatTimer = setTimeout(() => {
+        console.warn('Feed SSE missed heartbeats; reconnecting');
+        eventSource?.close();
+        // The new connection's ping reconciles what was missed meanwhile
+        errors = 1;
+        connect();
+      }, intervalMs * FEED_MISSED_HEARTBEATS);
+    });
+  };
+  connect();
+
+  return {
+    close: () => {
+      clearTimeout(pingTimer);
+      clearTimeout(heartbeatTimer);
+      if (pollTimer) clearInterval(pollTimer);
+      eventSource?.close();
+    },
+  };
+}